@@ -67,6 +67,65 @@ var AciGwModalView = React.createClass({
 
 module.exports.AciGwSummaryView = AciGwSummaryView
 module.exports.AciGwModalView = AciGwModalView
+var AddressSetSummaryView = React.createClass({
+  	render: function() {
+		var self = this
+
+		// Walk thru all objects
+		var addressSetListView = self.props.addressSets.map(function(addressSet){
+			return (
+				<ModalTrigger modal={<AddressSetModalView addressSet={ addressSet }/>}>
+					<tr key={ addressSet.key } className="info">
+						
+						   
+					</tr>
+				</ModalTrigger>
+			);
+		});
+
+		return (
+        <div>
+			<Table hover>
+				<thead>
+					<tr>
+					
+					   
+					</tr>
+				</thead>
+				<tbody>
+            		{ addressSetListView }
+				</tbody>
+			</Table>
+        </div>
+    	);
+	}
+});
+
+var AddressSetModalView = React.createClass({
+	render() {
+		var obj = this.props.addressSet
+	    return (
+	      <Modal {...this.props} bsStyle='primary' bsSize='large' title='AddressSet' animation={false}>
+	        <div className='modal-body' style={ {margin: '5%',} }>
+			
+			
+				<Input type='text' label='Address Set Name' ref='addressSetName' defaultValue={obj.addressSetName} placeholder='Address Set Name' />
+			
+				<Input type='text' label='IPv4 and IPv6 CIDRs in the address set' ref='addresses' defaultValue={obj.addresses} placeholder='IPv4 and IPv6 CIDRs in the address set' />
+			
+				<Input type='text' label='Tenant Name' ref='tenantName' defaultValue={obj.tenantName} placeholder='Tenant Name' />
+			
+			</div>
+	        <div className='modal-footer'>
+				<Button onClick={this.props.onRequestHide}>Close</Button>
+	        </div>
+	      </Modal>
+	    );
+  	}
+});
+
+module.exports.AddressSetSummaryView = AddressSetSummaryView
+module.exports.AddressSetModalView = AddressSetModalView
 var AppProfileSummaryView = React.createClass({
   	render: function() {
 		var self = this
@@ -416,6 +475,10 @@ var GlobalModalView = React.createClass({
 			
 				<Input type='text' label='ARP Mode' ref='arpMode' defaultValue={obj.arpMode} placeholder='ARP Mode' />
 			
+				<Input type='text' label='Baseline policy' ref='baselinePolicy' defaultValue={obj.baselinePolicy} placeholder='Baseline policy' />
+			
+				<Input type='text' label='Tunnel encryption' ref='encryption' defaultValue={obj.encryption} placeholder='Tunnel encryption' />
+			
 				<Input type='text' label='Forwarding Mode' ref='fwdMode' defaultValue={obj.fwdMode} placeholder='Forwarding Mode' />
 			
 				<Input type='text' label='name of this block(must be 'global')' ref='name' defaultValue={obj.name} placeholder='name of this block(must be 'global')' />
@@ -439,6 +502,79 @@ var GlobalModalView = React.createClass({
 
 module.exports.GlobalSummaryView = GlobalSummaryView
 module.exports.GlobalModalView = GlobalModalView
+var MirrorSessionSummaryView = React.createClass({
+  	render: function() {
+		var self = this
+
+		// Walk thru all objects
+		var mirrorSessionListView = self.props.mirrorSessions.map(function(mirrorSession){
+			return (
+				<ModalTrigger modal={<MirrorSessionModalView mirrorSession={ mirrorSession }/>}>
+					<tr key={ mirrorSession.key } className="info">
+						
+						   
+					</tr>
+				</ModalTrigger>
+			);
+		});
+
+		return (
+        <div>
+			<Table hover>
+				<thead>
+					<tr>
+					
+					   
+					</tr>
+				</thead>
+				<tbody>
+            		{ mirrorSessionListView }
+				</tbody>
+			</Table>
+        </div>
+    	);
+	}
+});
+
+var MirrorSessionModalView = React.createClass({
+	render() {
+		var obj = this.props.mirrorSession
+	    return (
+	      <Modal {...this.props} bsStyle='primary' bsSize='large' title='MirrorSession' animation={false}>
+	        <div className='modal-body' style={ {margin: '5%',} }>
+			
+			
+				<Input type='text' label='Remote collector IP address' ref='collectorIp' defaultValue={obj.collectorIp} placeholder='Remote collector IP address' />
+			
+				<Input type='text' label='Local OVS port receiving the mirrored traffic' ref='destinationPort' defaultValue={obj.destinationPort} placeholder='Local OVS port receiving the mirrored traffic' />
+			
+				<Input type='text' label='Mirror destination type' ref='destinationType' defaultValue={obj.destinationType} placeholder='Mirror destination type' />
+			
+				<Input type='text' label='Mirrored traffic direction' ref='direction' defaultValue={obj.direction} placeholder='Mirrored traffic direction' />
+			
+				<Input type='text' label='Endpoint group to mirror' ref='endpointGroup' defaultValue={obj.endpointGroup} placeholder='Endpoint group to mirror' />
+			
+				<Input type='text' label='Endpoints to mirror, by endpoint id, container id or container name' ref='endpoints' defaultValue={obj.endpoints} placeholder='Endpoints to mirror, by endpoint id, container id or container name' />
+			
+				<Input type='text' label='ERSPAN session id' ref='erspanId' defaultValue={obj.erspanId} placeholder='ERSPAN session id' />
+			
+				<Input type='text' label='Network to mirror' ref='networkName' defaultValue={obj.networkName} placeholder='Network to mirror' />
+			
+				<Input type='text' label='Mirror Session Name' ref='sessionName' defaultValue={obj.sessionName} placeholder='Mirror Session Name' />
+			
+				<Input type='text' label='Tenant Name' ref='tenantName' defaultValue={obj.tenantName} placeholder='Tenant Name' />
+			
+			</div>
+	        <div className='modal-footer'>
+				<Button onClick={this.props.onRequestHide}>Close</Button>
+	        </div>
+	      </Modal>
+	    );
+  	}
+});
+
+module.exports.MirrorSessionSummaryView = MirrorSessionSummaryView
+module.exports.MirrorSessionModalView = MirrorSessionModalView
 var NetprofileSummaryView = React.createClass({
   	render: function() {
 		var self = this
@@ -487,6 +623,12 @@ var NetprofileModalView = React.createClass({
 			
 				<Input type='text' label='burst size' ref='burst' defaultValue={obj.burst} placeholder='burst size' />
 			
+				<Input type='text' label='Egress minimum rate' ref='egressMinRate' defaultValue={obj.egressMinRate} placeholder='Egress minimum rate' />
+			
+				<Input type='text' label='Egress rate' ref='egressRate' defaultValue={obj.egressRate} placeholder='Egress rate' />
+			
+				<Input type='text' label='Ingress rate' ref='ingressRate' defaultValue={obj.ingressRate} placeholder='Ingress rate' />
+			
 				<Input type='text' label='Network profile name' ref='profileName' defaultValue={obj.profileName} placeholder='Network profile name' />
 			
 				<Input type='text' label='Tenant name' ref='tenantName' defaultValue={obj.tenantName} placeholder='Tenant name' />
@@ -554,6 +696,8 @@ var NetworkModalView = React.createClass({
 			
 				<Input type='text' label='IPv6Subnet' ref='ipv6Subnet' defaultValue={obj.ipv6Subnet} placeholder='IPv6Subnet' />
 			
+				<Input type='text' label='MTU of the network endpoints' ref='mtu' defaultValue={obj.mtu} placeholder='MTU of the network endpoints' />
+			
 				<Input type='text' label='Network name' ref='networkName' defaultValue={obj.networkName} placeholder='Network name' />
 			
 				<Input type='text' label='Network Type' ref='nwType' defaultValue={obj.nwType} placeholder='Network Type' />
@@ -617,6 +761,8 @@ var PolicyModalView = React.createClass({
 	        <div className='modal-body' style={ {margin: '5%',} }>
 			
 			
+				<Input type='text' label='Policy Mode' ref='mode' defaultValue={obj.mode} placeholder='Policy Mode' />
+			
 				<Input type='text' label='Policy Name' ref='policyName' defaultValue={obj.policyName} placeholder='Policy Name' />
 			
 				<Input type='text' label='Tenant Name' ref='tenantName' defaultValue={obj.tenantName} placeholder='Tenant Name' />
@@ -678,6 +824,8 @@ var RuleModalView = React.createClass({
 			
 				<Input type='text' label='Direction' ref='direction' defaultValue={obj.direction} placeholder='Direction' />
 			
+				<Input type='text' label='From Address Set' ref='fromAddressSet' defaultValue={obj.fromAddressSet} placeholder='From Address Set' />
+			
 				<Input type='text' label='From Endpoint Group' ref='fromEndpointGroup' defaultValue={obj.fromEndpointGroup} placeholder='From Endpoint Group' />
 			
 				<Input type='text' label='IP Address' ref='fromIpAddress' defaultValue={obj.fromIpAddress} placeholder='IP Address' />
@@ -688,6 +836,8 @@ var RuleModalView = React.createClass({
 			
 				<Input type='text' label='Port No' ref='port' defaultValue={obj.port} placeholder='Port No' />
 			
+				<Input type='text' label='Port List' ref='ports' defaultValue={obj.ports} placeholder='Port List' />
+			
 				<Input type='text' label='Priority' ref='priority' defaultValue={obj.priority} placeholder='Priority' />
 			
 				<Input type='text' label='Protocol' ref='protocol' defaultValue={obj.protocol} placeholder='Protocol' />
@@ -696,6 +846,8 @@ var RuleModalView = React.createClass({
 			
 				<Input type='text' label='Tenant Name' ref='tenantName' defaultValue={obj.tenantName} placeholder='Tenant Name' />
 			
+				<Input type='text' label='To Address Set' ref='toAddressSet' defaultValue={obj.toAddressSet} placeholder='To Address Set' />
+			
 				<Input type='text' label='To Endpoint Group' ref='toEndpointGroup' defaultValue={obj.toEndpointGroup} placeholder='To Endpoint Group' />
 			
 				<Input type='text' label='IP Address' ref='toIpAddress' defaultValue={obj.toIpAddress} placeholder='IP Address' />
@@ -822,6 +974,8 @@ var TenantModalView = React.createClass({
 			
 				<Input type='text' label='Network name' ref='defaultNetwork' defaultValue={obj.defaultNetwork} placeholder='Network name' />
 			
+				<Input type='text' label='Default policy' ref='defaultPolicy' defaultValue={obj.defaultPolicy} placeholder='Default policy' />
+			
 				<Input type='text' label='Tenant Name' ref='tenantName' defaultValue={obj.tenantName} placeholder='Tenant Name' />
 			
 			</div>
@@ -380,6 +380,35 @@ type AciGwInspect struct {
 	Oper AciGwOper
 }
 
+// AddressSet object
+type AddressSet struct {
+	// every object has a key
+	Key string `json:"key,omitempty"`
+
+	AddressSetName string   `json:"addressSetName,omitempty"` // Address Set Name
+	Addresses      []string `json:"addresses,omitempty"`
+	TenantName     string   `json:"tenantName,omitempty"` // Tenant Name
+
+	// add link-sets and links
+	LinkSets AddressSetLinkSets `json:"link-sets,omitempty"`
+	Links    AddressSetLinks    `json:"links,omitempty"`
+}
+
+// AddressSetLinkSets list of internal links
+type AddressSetLinkSets struct {
+	Rules map[string]Link `json:"Rules,omitempty"`
+}
+
+// AddressSetLinks internal links to other object
+type AddressSetLinks struct {
+	Tenant Link `json:"Tenant,omitempty"`
+}
+
+// AddressSetInspect inspect information
+type AddressSetInspect struct {
+	Config AddressSet
+}
+
 // AppProfile object
 type AppProfile struct {
 	// every object has a key
@@ -545,6 +574,8 @@ type Global struct {
 	Key string `json:"key,omitempty"`
 
 	ArpMode          string `json:"arpMode,omitempty"`          // ARP Mode
+	BaselinePolicy   string `json:"baselinePolicy,omitempty"`   // Baseline policy
+	Encryption       string `json:"encryption,omitempty"`       // Tunnel encryption
 	FwdMode          string `json:"fwdMode,omitempty"`          // Forwarding Mode
 	Name             string `json:"name,omitempty"`             // name of this block(must be 'global')
 	NetworkInfraType string `json:"networkInfraType,omitempty"` // Network infrastructure type
@@ -556,12 +587,14 @@ type Global struct {
 
 // GlobalOper runtime operations
 type GlobalOper struct {
-	ClusterMode     string `json:"clusterMode,omitempty"`     //
-	DefaultNetwork  string `json:"defaultNetwork,omitempty"`  //
-	FreeVXLANsStart int    `json:"freeVXLANsStart,omitempty"` //
-	NumNetworks     int    `json:"numNetworks,omitempty"`     //
-	VlansInUse      string `json:"vlansInUse,omitempty"`      //
-	VxlansInUse     string `json:"vxlansInUse,omitempty"`     //
+	ClusterMode             string   `json:"clusterMode,omitempty"`             //
+	DefaultNetwork          string   `json:"defaultNetwork,omitempty"`          //
+	EncryptionKeyGeneration int      `json:"encryptionKeyGeneration,omitempty"` //
+	EncryptionStatus        []string `json:"encryptionStatus,omitempty"`
+	FreeVXLANsStart         int      `json:"freeVXLANsStart,omitempty"` //
+	NumNetworks             int      `json:"numNetworks,omitempty"`     //
+	VlansInUse              string   `json:"vlansInUse,omitempty"`      //
+	VxlansInUse             string   `json:"vxlansInUse,omitempty"`     //
 
 }
 
@@ -572,16 +605,49 @@ type GlobalInspect struct {
 	Oper GlobalOper
 }
 
+// MirrorSession object
+type MirrorSession struct {
+	// every object has a key
+	Key string `json:"key,omitempty"`
+
+	CollectorIp     string   `json:"collectorIp,omitempty"`     // Remote collector IP address
+	DestinationPort string   `json:"destinationPort,omitempty"` // Local OVS port receiving the mirrored traffic
+	DestinationType string   `json:"destinationType,omitempty"` // Mirror destination type
+	Direction       string   `json:"direction,omitempty"`       // Mirrored traffic direction
+	EndpointGroup   string   `json:"endpointGroup,omitempty"`   // Endpoint group to mirror
+	Endpoints       []string `json:"endpoints,omitempty"`
+	ErspanId        int      `json:"erspanId,omitempty"`    // ERSPAN session id
+	NetworkName     string   `json:"networkName,omitempty"` // Network to mirror
+	SessionName     string   `json:"sessionName,omitempty"` // Mirror Session Name
+	TenantName      string   `json:"tenantName,omitempty"`  // Tenant Name
+
+	// add link-sets and links
+	Links MirrorSessionLinks `json:"links,omitempty"`
+}
+
+// MirrorSessionLinks internal links to other object
+type MirrorSessionLinks struct {
+	Tenant Link `json:"Tenant,omitempty"`
+}
+
+// MirrorSessionInspect inspect information
+type MirrorSessionInspect struct {
+	Config MirrorSession
+}
+
 // Netprofile object
 type Netprofile struct {
 	// every object has a key
 	Key string `json:"key,omitempty"`
 
-	DSCP        int    `json:"DSCP,omitempty"`        // DSCP
-	Bandwidth   string `json:"bandwidth,omitempty"`   // Allocated bandwidth
-	Burst       int    `json:"burst,omitempty"`       // burst size
-	ProfileName string `json:"profileName,omitempty"` // Network profile name
-	TenantName  string `json:"tenantName,omitempty"`  // Tenant name
+	DSCP          int    `json:"DSCP,omitempty"`          // DSCP
+	Bandwidth     string `json:"bandwidth,omitempty"`     // Allocated bandwidth
+	Burst         int    `json:"burst,omitempty"`         // burst size
+	EgressMinRate string `json:"egressMinRate,omitempty"` // Egress minimum rate
+	EgressRate    string `json:"egressRate,omitempty"`    // Egress rate
+	IngressRate   string `json:"ingressRate,omitempty"`   // Ingress rate
+	ProfileName   string `json:"profileName,omitempty"`   // Network profile name
+	TenantName    string `json:"tenantName,omitempty"`    // Tenant name
 
 	// add link-sets and links
 	LinkSets NetprofileLinkSets `json:"link-sets,omitempty"`
@@ -613,6 +679,7 @@ type Network struct {
 	Gateway     string `json:"gateway,omitempty"`     // Gateway
 	Ipv6Gateway string `json:"ipv6Gateway,omitempty"` // IPv6Gateway
 	Ipv6Subnet  string `json:"ipv6Subnet,omitempty"`  // IPv6Subnet
+	Mtu         int    `json:"mtu,omitempty"`         // MTU of the network endpoints
 	NetworkName string `json:"networkName,omitempty"` // Network name
 	NwType      string `json:"nwType,omitempty"`      // Network Type
 	PktTag      int    `json:"pktTag,omitempty"`      // Vlan/Vxlan Tag
@@ -661,6 +728,7 @@ type Policy struct {
 	// every object has a key
 	Key string `json:"key,omitempty"`
 
+	Mode       string `json:"mode,omitempty"`       // Policy Mode
 	PolicyName string `json:"policyName,omitempty"` // Policy Name
 	TenantName string `json:"tenantName,omitempty"` // Tenant Name
 
@@ -685,7 +753,8 @@ type PolicyOper struct {
 	Endpoints        []EndpointOper `json:"endpoints,omitempty"`
 	NumEndpoints     int            `json:"numEndpoints,omitempty"`     // number of endpoints
 	PolicyViolations int            `json:"policyViolations,omitempty"` // number of policyViolations
-
+	RuleStats        []RuleOper     `json:"ruleStats,omitempty"`
+	UnreachableHosts []string       `json:"unreachableHosts,omitempty"` // hosts whose counters could not be read
 }
 
 // PolicyInspect inspect information
@@ -702,15 +771,18 @@ type Rule struct {
 
 	Action            string `json:"action,omitempty"`            // Action
 	Direction         string `json:"direction,omitempty"`         // Direction
+	FromAddressSet    string `json:"fromAddressSet,omitempty"`    // From Address Set
 	FromEndpointGroup string `json:"fromEndpointGroup,omitempty"` // From Endpoint Group
 	FromIpAddress     string `json:"fromIpAddress,omitempty"`     // IP Address
 	FromNetwork       string `json:"fromNetwork,omitempty"`       // From Network
 	PolicyName        string `json:"policyName,omitempty"`        // Policy Name
 	Port              int    `json:"port,omitempty"`              // Port No
+	Ports             string `json:"ports,omitempty"`             // Port List
 	Priority          int    `json:"priority,omitempty"`          // Priority
 	Protocol          string `json:"protocol,omitempty"`          // Protocol
 	RuleID            string `json:"ruleId,omitempty"`            // Rule Id
 	TenantName        string `json:"tenantName,omitempty"`        // Tenant Name
+	ToAddressSet      string `json:"toAddressSet,omitempty"`      // To Address Set
 	ToEndpointGroup   string `json:"toEndpointGroup,omitempty"`   // To Endpoint Group
 	ToIpAddress       string `json:"toIpAddress,omitempty"`       // IP Address
 	ToNetwork         string `json:"toNetwork,omitempty"`         // To Network
@@ -730,9 +802,21 @@ type RuleLinks struct {
 	MatchEndpointGroup Link `json:"MatchEndpointGroup,omitempty"`
 }
 
+// RuleOper runtime operations
+type RuleOper struct {
+	Bytes            int      `json:"bytes,omitempty"`            // bytes matched by the rule
+	Host             string   `json:"host,omitempty"`             // host reporting the counters
+	Packets          int      `json:"packets,omitempty"`          // packets matched by the rule
+	RuleID           string   `json:"ruleId,omitempty"`           // Rule Id
+	UnreachableHosts []string `json:"unreachableHosts,omitempty"` // hosts whose counters could not be read
+
+}
+
 // RuleInspect inspect information
 type RuleInspect struct {
 	Config Rule
+
+	Oper RuleOper
 }
 
 // ServiceLB object
@@ -777,6 +861,7 @@ type Tenant struct {
 	Key string `json:"key,omitempty"`
 
 	DefaultNetwork string `json:"defaultNetwork,omitempty"` // Network name
+	DefaultPolicy  string `json:"defaultPolicy,omitempty"`  // Default policy
 	TenantName     string `json:"tenantName,omitempty"`     // Tenant Name
 
 	// add link-sets and links
@@ -785,8 +870,10 @@ type Tenant struct {
 
 // TenantLinkSets list of internal links
 type TenantLinkSets struct {
+	AddressSets    map[string]Link `json:"AddressSets,omitempty"`
 	AppProfiles    map[string]Link `json:"AppProfiles,omitempty"`
 	EndpointGroups map[string]Link `json:"EndpointGroups,omitempty"`
+	MirrorSessions map[string]Link `json:"MirrorSessions,omitempty"`
 	NetProfiles    map[string]Link `json:"NetProfiles,omitempty"`
 	Networks       map[string]Link `json:"Networks,omitempty"`
 	Policies       map[string]Link `json:"Policies,omitempty"`
@@ -965,6 +1052,88 @@ func (c *ContivClient) AciGwInspect(name string) (*AciGwInspect, error) {
 	return &obj, nil
 }
 
+// AddressSetPost posts the addressSet object
+func (c *ContivClient) AddressSetPost(obj *AddressSet) error {
+	// build key and URL
+	keyStr := obj.TenantName + ":" + obj.AddressSetName
+	url := c.baseURL + "/api/v1/addressSets/" + keyStr + "/"
+
+	// http post the object
+	err := c.httpPost(url, obj)
+	if err != nil {
+		log.Debugf("Error creating addressSet %+v. Err: %v", obj, err)
+		return err
+	}
+
+	return nil
+}
+
+// AddressSetList lists all addressSet objects
+func (c *ContivClient) AddressSetList() (*[]*AddressSet, error) {
+	// build key and URL
+	url := c.baseURL + "/api/v1/addressSets/"
+
+	// http get the object
+	var objList []*AddressSet
+	err := c.httpGet(url, &objList)
+	if err != nil {
+		log.Debugf("Error getting addressSets. Err: %v", err)
+		return nil, err
+	}
+
+	return &objList, nil
+}
+
+// AddressSetGet gets the addressSet object
+func (c *ContivClient) AddressSetGet(tenantName string, addressSetName string) (*AddressSet, error) {
+	// build key and URL
+	keyStr := tenantName + ":" + addressSetName
+	url := c.baseURL + "/api/v1/addressSets/" + keyStr + "/"
+
+	// http get the object
+	var obj AddressSet
+	err := c.httpGet(url, &obj)
+	if err != nil {
+		log.Debugf("Error getting addressSet %+v. Err: %v", keyStr, err)
+		return nil, err
+	}
+
+	return &obj, nil
+}
+
+// AddressSetDelete deletes the addressSet object
+func (c *ContivClient) AddressSetDelete(tenantName string, addressSetName string) error {
+	// build key and URL
+	keyStr := tenantName + ":" + addressSetName
+	url := c.baseURL + "/api/v1/addressSets/" + keyStr + "/"
+
+	// http get the object
+	err := c.httpDelete(url)
+	if err != nil {
+		log.Debugf("Error deleting addressSet %s. Err: %v", keyStr, err)
+		return err
+	}
+
+	return nil
+}
+
+// AddressSetInspect gets the addressSetInspect object
+func (c *ContivClient) AddressSetInspect(tenantName string, addressSetName string) (*AddressSetInspect, error) {
+	// build key and URL
+	keyStr := tenantName + ":" + addressSetName
+	url := c.baseURL + "/api/v1/inspect/addressSets/" + keyStr + "/"
+
+	// http get the object
+	var obj AddressSetInspect
+	err := c.httpGet(url, &obj)
+	if err != nil {
+		log.Debugf("Error getting addressSet %+v. Err: %v", keyStr, err)
+		return nil, err
+	}
+
+	return &obj, nil
+}
+
 // AppProfilePost posts the appProfile object
 func (c *ContivClient) AppProfilePost(obj *AppProfile) error {
 	// build key and URL
@@ -1392,6 +1561,88 @@ func (c *ContivClient) GlobalInspect(name string) (*GlobalInspect, error) {
 	return &obj, nil
 }
 
+// MirrorSessionPost posts the mirrorSession object
+func (c *ContivClient) MirrorSessionPost(obj *MirrorSession) error {
+	// build key and URL
+	keyStr := obj.TenantName + ":" + obj.SessionName
+	url := c.baseURL + "/api/v1/mirrorSessions/" + keyStr + "/"
+
+	// http post the object
+	err := c.httpPost(url, obj)
+	if err != nil {
+		log.Debugf("Error creating mirrorSession %+v. Err: %v", obj, err)
+		return err
+	}
+
+	return nil
+}
+
+// MirrorSessionList lists all mirrorSession objects
+func (c *ContivClient) MirrorSessionList() (*[]*MirrorSession, error) {
+	// build key and URL
+	url := c.baseURL + "/api/v1/mirrorSessions/"
+
+	// http get the object
+	var objList []*MirrorSession
+	err := c.httpGet(url, &objList)
+	if err != nil {
+		log.Debugf("Error getting mirrorSessions. Err: %v", err)
+		return nil, err
+	}
+
+	return &objList, nil
+}
+
+// MirrorSessionGet gets the mirrorSession object
+func (c *ContivClient) MirrorSessionGet(tenantName string, sessionName string) (*MirrorSession, error) {
+	// build key and URL
+	keyStr := tenantName + ":" + sessionName
+	url := c.baseURL + "/api/v1/mirrorSessions/" + keyStr + "/"
+
+	// http get the object
+	var obj MirrorSession
+	err := c.httpGet(url, &obj)
+	if err != nil {
+		log.Debugf("Error getting mirrorSession %+v. Err: %v", keyStr, err)
+		return nil, err
+	}
+
+	return &obj, nil
+}
+
+// MirrorSessionDelete deletes the mirrorSession object
+func (c *ContivClient) MirrorSessionDelete(tenantName string, sessionName string) error {
+	// build key and URL
+	keyStr := tenantName + ":" + sessionName
+	url := c.baseURL + "/api/v1/mirrorSessions/" + keyStr + "/"
+
+	// http get the object
+	err := c.httpDelete(url)
+	if err != nil {
+		log.Debugf("Error deleting mirrorSession %s. Err: %v", keyStr, err)
+		return err
+	}
+
+	return nil
+}
+
+// MirrorSessionInspect gets the mirrorSessionInspect object
+func (c *ContivClient) MirrorSessionInspect(tenantName string, sessionName string) (*MirrorSessionInspect, error) {
+	// build key and URL
+	keyStr := tenantName + ":" + sessionName
+	url := c.baseURL + "/api/v1/inspect/mirrorSessions/" + keyStr + "/"
+
+	// http get the object
+	var obj MirrorSessionInspect
+	err := c.httpGet(url, &obj)
+	if err != nil {
+		log.Debugf("Error getting mirrorSession %+v. Err: %v", keyStr, err)
+		return nil, err
+	}
+
+	return &obj, nil
+}
+
 // NetprofilePost posts the netprofile object
 func (c *ContivClient) NetprofilePost(obj *Netprofile) error {
 	// build key and URL
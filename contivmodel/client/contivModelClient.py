@@ -123,6 +123,43 @@ class objmodelClient:
 	    return json.loads(retData)
 
 
+	# Create addressSet
+	def createAddressSet(self, obj):
+	    postUrl = self.baseUrl + '/api/v1/addressSets/' + obj.tenantName + ":" + obj.addressSetName  + '/'
+
+	    jdata = json.dumps({ 
+			"addressSetName": obj.addressSetName, 
+			"addresses": obj.addresses, 
+			"tenantName": obj.tenantName, 
+	    })
+
+	    # Post the data
+	    response = httpPost(postUrl, jdata)
+
+	    if response == "Error":
+	        errorExit("AddressSet create failure")
+
+	# Delete addressSet
+	def deleteAddressSet(self, tenantName, addressSetName):
+	    # Delete AddressSet
+	    deleteUrl = self.baseUrl + '/api/v1/addressSets/' + tenantName + ":" + addressSetName  + '/'
+	    response = httpDelete(deleteUrl)
+
+	    if response == "Error":
+	        errorExit("AddressSet create failure")
+
+	# List all addressSet objects
+	def listAddressSet(self):
+	    # Get a list of addressSet objects
+	    retDate = urllib2.urlopen(self.baseUrl + '/api/v1/addressSets/')
+	    if retData == "Error":
+	        errorExit("list AddressSet failed")
+
+	    return json.loads(retData)
+
+
+
+
 	# Create appProfile
 	def createAppProfile(self, obj):
 	    postUrl = self.baseUrl + '/api/v1/appProfiles/' + obj.tenantName + ":" + obj.appProfileName  + '/'
@@ -318,6 +355,8 @@ class objmodelClient:
 
 	    jdata = json.dumps({ 
 			"arpMode": obj.arpMode, 
+			"baselinePolicy": obj.baselinePolicy, 
+			"encryption": obj.encryption, 
 			"fwdMode": obj.fwdMode, 
 			"name": obj.name, 
 			"networkInfraType": obj.networkInfraType, 
@@ -363,6 +402,50 @@ class objmodelClient:
 	    return json.loads(retData)
 
 
+	# Create mirrorSession
+	def createMirrorSession(self, obj):
+	    postUrl = self.baseUrl + '/api/v1/mirrorSessions/' + obj.tenantName + ":" + obj.sessionName  + '/'
+
+	    jdata = json.dumps({ 
+			"collectorIp": obj.collectorIp, 
+			"destinationPort": obj.destinationPort, 
+			"destinationType": obj.destinationType, 
+			"direction": obj.direction, 
+			"endpointGroup": obj.endpointGroup, 
+			"endpoints": obj.endpoints, 
+			"erspanId": obj.erspanId, 
+			"networkName": obj.networkName, 
+			"sessionName": obj.sessionName, 
+			"tenantName": obj.tenantName, 
+	    })
+
+	    # Post the data
+	    response = httpPost(postUrl, jdata)
+
+	    if response == "Error":
+	        errorExit("MirrorSession create failure")
+
+	# Delete mirrorSession
+	def deleteMirrorSession(self, tenantName, sessionName):
+	    # Delete MirrorSession
+	    deleteUrl = self.baseUrl + '/api/v1/mirrorSessions/' + tenantName + ":" + sessionName  + '/'
+	    response = httpDelete(deleteUrl)
+
+	    if response == "Error":
+	        errorExit("MirrorSession create failure")
+
+	# List all mirrorSession objects
+	def listMirrorSession(self):
+	    # Get a list of mirrorSession objects
+	    retDate = urllib2.urlopen(self.baseUrl + '/api/v1/mirrorSessions/')
+	    if retData == "Error":
+	        errorExit("list MirrorSession failed")
+
+	    return json.loads(retData)
+
+
+
+
 	# Create netprofile
 	def createNetprofile(self, obj):
 	    postUrl = self.baseUrl + '/api/v1/netprofiles/' + obj.tenantName + ":" + obj.profileName  + '/'
@@ -371,6 +454,9 @@ class objmodelClient:
 			"DSCP": obj.DSCP, 
 			"bandwidth": obj.bandwidth, 
 			"burst": obj.burst, 
+			"egressMinRate": obj.egressMinRate, 
+			"egressRate": obj.egressRate, 
+			"ingressRate": obj.ingressRate, 
 			"profileName": obj.profileName, 
 			"tenantName": obj.tenantName, 
 	    })
@@ -412,6 +498,7 @@ class objmodelClient:
 			"gateway": obj.gateway, 
 			"ipv6Gateway": obj.ipv6Gateway, 
 			"ipv6Subnet": obj.ipv6Subnet, 
+			"mtu": obj.mtu, 
 			"networkName": obj.networkName, 
 			"nwType": obj.nwType, 
 			"pktTag": obj.pktTag, 
@@ -461,6 +548,7 @@ class objmodelClient:
 	    postUrl = self.baseUrl + '/api/v1/policys/' + obj.tenantName + ":" + obj.policyName  + '/'
 
 	    jdata = json.dumps({ 
+			"mode": obj.mode, 
 			"policyName": obj.policyName, 
 			"tenantName": obj.tenantName, 
 	    })
@@ -509,15 +597,18 @@ class objmodelClient:
 	    jdata = json.dumps({ 
 			"action": obj.action, 
 			"direction": obj.direction, 
+			"fromAddressSet": obj.fromAddressSet, 
 			"fromEndpointGroup": obj.fromEndpointGroup, 
 			"fromIpAddress": obj.fromIpAddress, 
 			"fromNetwork": obj.fromNetwork, 
 			"policyName": obj.policyName, 
 			"port": obj.port, 
+			"ports": obj.ports, 
 			"priority": obj.priority, 
 			"protocol": obj.protocol, 
 			"ruleId": obj.ruleId, 
 			"tenantName": obj.tenantName, 
+			"toAddressSet": obj.toAddressSet, 
 			"toEndpointGroup": obj.toEndpointGroup, 
 			"toIpAddress": obj.toIpAddress, 
 			"toNetwork": obj.toNetwork, 
@@ -606,6 +697,7 @@ class objmodelClient:
 
 	    jdata = json.dumps({ 
 			"defaultNetwork": obj.defaultNetwork, 
+			"defaultPolicy": obj.defaultPolicy, 
 			"tenantName": obj.tenantName, 
 	    })
 
@@ -41,6 +41,31 @@ type AciGwInspect struct {
 	Oper AciGwOper
 }
 
+type AddressSet struct {
+	// every object has a key
+	Key string `json:"key,omitempty"`
+
+	AddressSetName string   `json:"addressSetName,omitempty"` // Address Set Name
+	Addresses      []string `json:"addresses,omitempty"`
+	TenantName     string   `json:"tenantName,omitempty"` // Tenant Name
+
+	// add link-sets and links
+	LinkSets AddressSetLinkSets `json:"link-sets,omitempty"`
+	Links    AddressSetLinks    `json:"links,omitempty"`
+}
+
+type AddressSetLinkSets struct {
+	Rules map[string]modeldb.Link `json:"Rules,omitempty"`
+}
+
+type AddressSetLinks struct {
+	Tenant modeldb.Link `json:"Tenant,omitempty"`
+}
+
+type AddressSetInspect struct {
+	Config AddressSet
+}
+
 type AppProfile struct {
 	// every object has a key
 	Key string `json:"key,omitempty"`
@@ -198,6 +223,8 @@ type Global struct {
 	Key string `json:"key,omitempty"`
 
 	ArpMode          string `json:"arpMode,omitempty"`          // ARP Mode
+	BaselinePolicy   string `json:"baselinePolicy,omitempty"`   // Baseline policy
+	Encryption       string `json:"encryption,omitempty"`       // Tunnel encryption
 	FwdMode          string `json:"fwdMode,omitempty"`          // Forwarding Mode
 	Name             string `json:"name,omitempty"`             // name of this block(must be 'global')
 	NetworkInfraType string `json:"networkInfraType,omitempty"` // Network infrastructure type
@@ -208,12 +235,14 @@ type Global struct {
 }
 
 type GlobalOper struct {
-	ClusterMode     string `json:"clusterMode,omitempty"`     //
-	DefaultNetwork  string `json:"defaultNetwork,omitempty"`  //
-	FreeVXLANsStart int    `json:"freeVXLANsStart,omitempty"` //
-	NumNetworks     int    `json:"numNetworks,omitempty"`     //
-	VlansInUse      string `json:"vlansInUse,omitempty"`      //
-	VxlansInUse     string `json:"vxlansInUse,omitempty"`     //
+	ClusterMode             string   `json:"clusterMode,omitempty"`             //
+	DefaultNetwork          string   `json:"defaultNetwork,omitempty"`          //
+	EncryptionKeyGeneration int      `json:"encryptionKeyGeneration,omitempty"` //
+	EncryptionStatus        []string `json:"encryptionStatus,omitempty"`
+	FreeVXLANsStart         int      `json:"freeVXLANsStart,omitempty"` //
+	NumNetworks             int      `json:"numNetworks,omitempty"`     //
+	VlansInUse              string   `json:"vlansInUse,omitempty"`      //
+	VxlansInUse             string   `json:"vxlansInUse,omitempty"`     //
 
 }
 
@@ -223,15 +252,45 @@ type GlobalInspect struct {
 	Oper GlobalOper
 }
 
+type MirrorSession struct {
+	// every object has a key
+	Key string `json:"key,omitempty"`
+
+	CollectorIp     string   `json:"collectorIp,omitempty"`     // Remote collector IP address
+	DestinationPort string   `json:"destinationPort,omitempty"` // Local OVS port receiving the mirrored traffic
+	DestinationType string   `json:"destinationType,omitempty"` // Mirror destination type
+	Direction       string   `json:"direction,omitempty"`       // Mirrored traffic direction
+	EndpointGroup   string   `json:"endpointGroup,omitempty"`   // Endpoint group to mirror
+	Endpoints       []string `json:"endpoints,omitempty"`
+	ErspanId        int      `json:"erspanId,omitempty"`    // ERSPAN session id
+	NetworkName     string   `json:"networkName,omitempty"` // Network to mirror
+	SessionName     string   `json:"sessionName,omitempty"` // Mirror Session Name
+	TenantName      string   `json:"tenantName,omitempty"`  // Tenant Name
+
+	// add link-sets and links
+	Links MirrorSessionLinks `json:"links,omitempty"`
+}
+
+type MirrorSessionLinks struct {
+	Tenant modeldb.Link `json:"Tenant,omitempty"`
+}
+
+type MirrorSessionInspect struct {
+	Config MirrorSession
+}
+
 type Netprofile struct {
 	// every object has a key
 	Key string `json:"key,omitempty"`
 
-	DSCP        int    `json:"DSCP,omitempty"`        // DSCP
-	Bandwidth   string `json:"bandwidth,omitempty"`   // Allocated bandwidth
-	Burst       int    `json:"burst,omitempty"`       // burst size
-	ProfileName string `json:"profileName,omitempty"` // Network profile name
-	TenantName  string `json:"tenantName,omitempty"`  // Tenant name
+	DSCP          int    `json:"DSCP,omitempty"`          // DSCP
+	Bandwidth     string `json:"bandwidth,omitempty"`     // Allocated bandwidth
+	Burst         int    `json:"burst,omitempty"`         // burst size
+	EgressMinRate string `json:"egressMinRate,omitempty"` // Egress minimum rate
+	EgressRate    string `json:"egressRate,omitempty"`    // Egress rate
+	IngressRate   string `json:"ingressRate,omitempty"`   // Ingress rate
+	ProfileName   string `json:"profileName,omitempty"`   // Network profile name
+	TenantName    string `json:"tenantName,omitempty"`    // Tenant name
 
 	// add link-sets and links
 	LinkSets NetprofileLinkSets `json:"link-sets,omitempty"`
@@ -259,6 +318,7 @@ type Network struct {
 	Gateway     string `json:"gateway,omitempty"`     // Gateway
 	Ipv6Gateway string `json:"ipv6Gateway,omitempty"` // IPv6Gateway
 	Ipv6Subnet  string `json:"ipv6Subnet,omitempty"`  // IPv6Subnet
+	Mtu         int    `json:"mtu,omitempty"`         // MTU of the network endpoints
 	NetworkName string `json:"networkName,omitempty"` // Network name
 	NwType      string `json:"nwType,omitempty"`      // Network Type
 	PktTag      int    `json:"pktTag,omitempty"`      // Vlan/Vxlan Tag
@@ -304,6 +364,7 @@ type Policy struct {
 	// every object has a key
 	Key string `json:"key,omitempty"`
 
+	Mode       string `json:"mode,omitempty"`       // Policy Mode
 	PolicyName string `json:"policyName,omitempty"` // Policy Name
 	TenantName string `json:"tenantName,omitempty"` // Tenant Name
 
@@ -326,7 +387,8 @@ type PolicyOper struct {
 	Endpoints        []EndpointOper `json:"endpoints,omitempty"`
 	NumEndpoints     int            `json:"numEndpoints,omitempty"`     // number of endpoints
 	PolicyViolations int            `json:"policyViolations,omitempty"` // number of policyViolations
-
+	RuleStats        []RuleOper     `json:"ruleStats,omitempty"`
+	UnreachableHosts []string       `json:"unreachableHosts,omitempty"` // hosts whose counters could not be read
 }
 
 type PolicyInspect struct {
@@ -341,15 +403,18 @@ type Rule struct {
 
 	Action            string `json:"action,omitempty"`            // Action
 	Direction         string `json:"direction,omitempty"`         // Direction
+	FromAddressSet    string `json:"fromAddressSet,omitempty"`    // From Address Set
 	FromEndpointGroup string `json:"fromEndpointGroup,omitempty"` // From Endpoint Group
 	FromIpAddress     string `json:"fromIpAddress,omitempty"`     // IP Address
 	FromNetwork       string `json:"fromNetwork,omitempty"`       // From Network
 	PolicyName        string `json:"policyName,omitempty"`        // Policy Name
 	Port              int    `json:"port,omitempty"`              // Port No
+	Ports             string `json:"ports,omitempty"`             // Port List
 	Priority          int    `json:"priority,omitempty"`          // Priority
 	Protocol          string `json:"protocol,omitempty"`          // Protocol
 	RuleID            string `json:"ruleId,omitempty"`            // Rule Id
 	TenantName        string `json:"tenantName,omitempty"`        // Tenant Name
+	ToAddressSet      string `json:"toAddressSet,omitempty"`      // To Address Set
 	ToEndpointGroup   string `json:"toEndpointGroup,omitempty"`   // To Endpoint Group
 	ToIpAddress       string `json:"toIpAddress,omitempty"`       // IP Address
 	ToNetwork         string `json:"toNetwork,omitempty"`         // To Network
@@ -367,8 +432,19 @@ type RuleLinks struct {
 	MatchEndpointGroup modeldb.Link `json:"MatchEndpointGroup,omitempty"`
 }
 
+type RuleOper struct {
+	Bytes            int      `json:"bytes,omitempty"`            // bytes matched by the rule
+	Host             string   `json:"host,omitempty"`             // host reporting the counters
+	Packets          int      `json:"packets,omitempty"`          // packets matched by the rule
+	RuleID           string   `json:"ruleId,omitempty"`           // Rule Id
+	UnreachableHosts []string `json:"unreachableHosts,omitempty"` // hosts whose counters could not be read
+
+}
+
 type RuleInspect struct {
 	Config Rule
+
+	Oper RuleOper
 }
 
 type ServiceLB struct {
@@ -409,6 +485,7 @@ type Tenant struct {
 	Key string `json:"key,omitempty"`
 
 	DefaultNetwork string `json:"defaultNetwork,omitempty"` // Network name
+	DefaultPolicy  string `json:"defaultPolicy,omitempty"`  // Default policy
 	TenantName     string `json:"tenantName,omitempty"`     // Tenant Name
 
 	// add link-sets and links
@@ -416,10 +493,14 @@ type Tenant struct {
 }
 
 type TenantLinkSets struct {
+	AddressSets map[string]modeldb.Link `json:"AddressSets,omitempty"`
+
 	AppProfiles map[string]modeldb.Link `json:"AppProfiles,omitempty"`
 
 	EndpointGroups map[string]modeldb.Link `json:"EndpointGroups,omitempty"`
 
+	MirrorSessions map[string]modeldb.Link `json:"MirrorSessions,omitempty"`
+
 	NetProfiles map[string]modeldb.Link `json:"NetProfiles,omitempty"`
 
 	Networks map[string]modeldb.Link `json:"Networks,omitempty"`
@@ -514,6 +595,9 @@ type Collections struct {
 	aciGwMutex sync.Mutex
 	aciGws     map[string]*AciGw
 
+	addressSetMutex sync.Mutex
+	addressSets     map[string]*AddressSet
+
 	appProfileMutex sync.Mutex
 	appProfiles     map[string]*AppProfile
 
@@ -529,6 +613,9 @@ type Collections struct {
 	globalMutex sync.Mutex
 	globals     map[string]*Global
 
+	mirrorSessionMutex sync.Mutex
+	mirrorSessions     map[string]*MirrorSession
+
 	netprofileMutex sync.Mutex
 	netprofiles     map[string]*Netprofile
 
@@ -564,6 +651,12 @@ type AciGwCallbacks interface {
 	AciGwDelete(aciGw *AciGw) error
 }
 
+type AddressSetCallbacks interface {
+	AddressSetCreate(addressSet *AddressSet) error
+	AddressSetUpdate(addressSet, params *AddressSet) error
+	AddressSetDelete(addressSet *AddressSet) error
+}
+
 type AppProfileCallbacks interface {
 	AppProfileCreate(appProfile *AppProfile) error
 	AppProfileUpdate(appProfile, params *AppProfile) error
@@ -604,6 +697,12 @@ type GlobalCallbacks interface {
 	GlobalDelete(global *Global) error
 }
 
+type MirrorSessionCallbacks interface {
+	MirrorSessionCreate(mirrorSession *MirrorSession) error
+	MirrorSessionUpdate(mirrorSession, params *MirrorSession) error
+	MirrorSessionDelete(mirrorSession *MirrorSession) error
+}
+
 type NetprofileCallbacks interface {
 	NetprofileCreate(netprofile *Netprofile) error
 	NetprofileUpdate(netprofile, params *Netprofile) error
@@ -627,6 +726,8 @@ type PolicyCallbacks interface {
 }
 
 type RuleCallbacks interface {
+	RuleGetOper(rule *RuleInspect) error
+
 	RuleCreate(rule *Rule) error
 	RuleUpdate(rule, params *Rule) error
 	RuleDelete(rule *Rule) error
@@ -662,12 +763,14 @@ type VolumeProfileCallbacks interface {
 
 type CallbackHandlers struct {
 	AciGwCb             AciGwCallbacks
+	AddressSetCb        AddressSetCallbacks
 	AppProfileCb        AppProfileCallbacks
 	BgpCb               BgpCallbacks
 	EndpointCb          EndpointCallbacks
 	EndpointGroupCb     EndpointGroupCallbacks
 	ExtContractsGroupCb ExtContractsGroupCallbacks
 	GlobalCb            GlobalCallbacks
+	MirrorSessionCb     MirrorSessionCallbacks
 	NetprofileCb        NetprofileCallbacks
 	NetworkCb           NetworkCallbacks
 	PolicyCb            PolicyCallbacks
@@ -684,6 +787,8 @@ func Init() {
 
 	collections.aciGws = make(map[string]*AciGw)
 
+	collections.addressSets = make(map[string]*AddressSet)
+
 	collections.appProfiles = make(map[string]*AppProfile)
 
 	collections.Bgps = make(map[string]*Bgp)
@@ -694,6 +799,8 @@ func Init() {
 
 	collections.globals = make(map[string]*Global)
 
+	collections.mirrorSessions = make(map[string]*MirrorSession)
+
 	collections.netprofiles = make(map[string]*Netprofile)
 
 	collections.networks = make(map[string]*Network)
@@ -711,12 +818,14 @@ func Init() {
 	collections.volumeProfiles = make(map[string]*VolumeProfile)
 
 	restoreAciGw()
+	restoreAddressSet()
 	restoreAppProfile()
 	restoreBgp()
 
 	restoreEndpointGroup()
 	restoreExtContractsGroup()
 	restoreGlobal()
+	restoreMirrorSession()
 	restoreNetprofile()
 	restoreNetwork()
 	restorePolicy()
@@ -732,6 +841,10 @@ func GetAciGwCount() int {
 	return len(collections.aciGws)
 }
 
+func GetAddressSetCount() int {
+	return len(collections.addressSets)
+}
+
 func GetAppProfileCount() int {
 	return len(collections.appProfiles)
 }
@@ -752,6 +865,10 @@ func GetGlobalCount() int {
 	return len(collections.globals)
 }
 
+func GetMirrorSessionCount() int {
+	return len(collections.mirrorSessions)
+}
+
 func GetNetprofileCount() int {
 	return len(collections.netprofiles)
 }
@@ -788,6 +905,10 @@ func RegisterAciGwCallbacks(handler AciGwCallbacks) {
 	objCallbackHandler.AciGwCb = handler
 }
 
+func RegisterAddressSetCallbacks(handler AddressSetCallbacks) {
+	objCallbackHandler.AddressSetCb = handler
+}
+
 func RegisterAppProfileCallbacks(handler AppProfileCallbacks) {
 	objCallbackHandler.AppProfileCb = handler
 }
@@ -812,6 +933,10 @@ func RegisterGlobalCallbacks(handler GlobalCallbacks) {
 	objCallbackHandler.GlobalCb = handler
 }
 
+func RegisterMirrorSessionCallbacks(handler MirrorSessionCallbacks) {
+	objCallbackHandler.MirrorSessionCb = handler
+}
+
 func RegisterNetprofileCallbacks(handler NetprofileCallbacks) {
 	objCallbackHandler.NetprofileCb = handler
 }
@@ -896,6 +1021,19 @@ func AddRoutes(router *mux.Router) {
 	inspectRoute = "/api/v1/inspect/aciGws/{key}/"
 	router.Path(inspectRoute).Methods("GET").HandlerFunc(makeHttpHandler(httpInspectAciGw))
 
+	// Register addressSet
+	route = "/api/v1/addressSets/{key}/"
+	listRoute = "/api/v1/addressSets/"
+	log.Infof("Registering %s", route)
+	router.Path(listRoute).Methods("GET").HandlerFunc(makeHttpHandler(httpListAddressSets))
+	router.Path(route).Methods("GET").HandlerFunc(makeHttpHandler(httpGetAddressSet))
+	router.Path(route).Methods("POST").HandlerFunc(makeHttpHandler(httpCreateAddressSet))
+	router.Path(route).Methods("PUT").HandlerFunc(makeHttpHandler(httpCreateAddressSet))
+	router.Path(route).Methods("DELETE").HandlerFunc(makeHttpHandler(httpDeleteAddressSet))
+
+	inspectRoute = "/api/v1/inspect/addressSets/{key}/"
+	router.Path(inspectRoute).Methods("GET").HandlerFunc(makeHttpHandler(httpInspectAddressSet))
+
 	// Register appProfile
 	route = "/api/v1/appProfiles/{key}/"
 	listRoute = "/api/v1/appProfiles/"
@@ -964,6 +1102,19 @@ func AddRoutes(router *mux.Router) {
 	inspectRoute = "/api/v1/inspect/globals/{key}/"
 	router.Path(inspectRoute).Methods("GET").HandlerFunc(makeHttpHandler(httpInspectGlobal))
 
+	// Register mirrorSession
+	route = "/api/v1/mirrorSessions/{key}/"
+	listRoute = "/api/v1/mirrorSessions/"
+	log.Infof("Registering %s", route)
+	router.Path(listRoute).Methods("GET").HandlerFunc(makeHttpHandler(httpListMirrorSessions))
+	router.Path(route).Methods("GET").HandlerFunc(makeHttpHandler(httpGetMirrorSession))
+	router.Path(route).Methods("POST").HandlerFunc(makeHttpHandler(httpCreateMirrorSession))
+	router.Path(route).Methods("PUT").HandlerFunc(makeHttpHandler(httpCreateMirrorSession))
+	router.Path(route).Methods("DELETE").HandlerFunc(makeHttpHandler(httpDeleteMirrorSession))
+
+	inspectRoute = "/api/v1/inspect/mirrorSessions/{key}/"
+	router.Path(inspectRoute).Methods("GET").HandlerFunc(makeHttpHandler(httpInspectMirrorSession))
+
 	// Register netprofile
 	route = "/api/v1/netprofiles/{key}/"
 	listRoute = "/api/v1/netprofiles/"
@@ -1438,18 +1589,18 @@ func ValidateAciGw(obj *AciGw) error {
 }
 
 // GET Oper REST call
-func httpInspectAppProfile(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	var obj AppProfileInspect
-	log.Debugf("Received httpInspectAppProfile: %+v", vars)
+func httpInspectAddressSet(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	var obj AddressSetInspect
+	log.Debugf("Received httpInspectAddressSet: %+v", vars)
 
 	key := vars["key"]
 
-	collections.appProfileMutex.Lock()
-	defer collections.appProfileMutex.Unlock()
-	objConfig := collections.appProfiles[key]
+	collections.addressSetMutex.Lock()
+	defer collections.addressSetMutex.Unlock()
+	objConfig := collections.addressSets[key]
 	if objConfig == nil {
-		log.Errorf("appProfile %s not found", key)
-		return nil, errors.New("appProfile not found")
+		log.Errorf("addressSet %s not found", key)
+		return nil, errors.New("addressSet not found")
 	}
 	obj.Config = *objConfig
 
@@ -1458,13 +1609,13 @@ func httpInspectAppProfile(w http.ResponseWriter, r *http.Request, vars map[stri
 }
 
 // LIST REST call
-func httpListAppProfiles(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpListAppProfiles: %+v", vars)
+func httpListAddressSets(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpListAddressSets: %+v", vars)
 
-	list := make([]*AppProfile, 0)
-	collections.appProfileMutex.Lock()
-	defer collections.appProfileMutex.Unlock()
-	for _, obj := range collections.appProfiles {
+	list := make([]*AddressSet, 0)
+	collections.addressSetMutex.Lock()
+	defer collections.addressSetMutex.Unlock()
+	for _, obj := range collections.addressSets {
 		list = append(list, obj)
 	}
 
@@ -1473,17 +1624,17 @@ func httpListAppProfiles(w http.ResponseWriter, r *http.Request, vars map[string
 }
 
 // GET REST call
-func httpGetAppProfile(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpGetAppProfile: %+v", vars)
+func httpGetAddressSet(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetAddressSet: %+v", vars)
 
 	key := vars["key"]
 
-	collections.appProfileMutex.Lock()
-	defer collections.appProfileMutex.Unlock()
-	obj := collections.appProfiles[key]
+	collections.addressSetMutex.Lock()
+	defer collections.addressSetMutex.Unlock()
+	obj := collections.addressSets[key]
 	if obj == nil {
-		log.Infof("appProfile %s not found", key)
-		return nil, errors.New("appProfile not found")
+		log.Infof("addressSet %s not found", key)
+		return nil, errors.New("addressSet not found")
 	}
 
 	// Return the obj
@@ -1491,16 +1642,16 @@ func httpGetAppProfile(w http.ResponseWriter, r *http.Request, vars map[string]s
 }
 
 // CREATE REST call
-func httpCreateAppProfile(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpGetAppProfile: %+v", vars)
+func httpCreateAddressSet(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetAddressSet: %+v", vars)
 
-	var obj AppProfile
+	var obj AddressSet
 	key := vars["key"]
 
 	// Get object from the request
 	err := json.NewDecoder(r.Body).Decode(&obj)
 	if err != nil {
-		log.Errorf("Error decoding appProfile create request. Err %v", err)
+		log.Errorf("Error decoding addressSet create request. Err %v", err)
 		return nil, err
 	}
 
@@ -1508,9 +1659,9 @@ func httpCreateAppProfile(w http.ResponseWriter, r *http.Request, vars map[strin
 	obj.Key = key
 
 	// Create the object
-	err = CreateAppProfile(&obj)
+	err = CreateAddressSet(&obj)
 	if err != nil {
-		log.Errorf("CreateAppProfile error for: %+v. Err: %v", obj, err)
+		log.Errorf("CreateAddressSet error for: %+v. Err: %v", obj, err)
 		return nil, err
 	}
 
@@ -1519,15 +1670,15 @@ func httpCreateAppProfile(w http.ResponseWriter, r *http.Request, vars map[strin
 }
 
 // DELETE rest call
-func httpDeleteAppProfile(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpDeleteAppProfile: %+v", vars)
+func httpDeleteAddressSet(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpDeleteAddressSet: %+v", vars)
 
 	key := vars["key"]
 
 	// Delete the object
-	err := DeleteAppProfile(key)
+	err := DeleteAddressSet(key)
 	if err != nil {
-		log.Errorf("DeleteAppProfile error for: %s. Err: %v", key, err)
+		log.Errorf("DeleteAddressSet error for: %s. Err: %v", key, err)
 		return nil, err
 	}
 
@@ -1535,75 +1686,75 @@ func httpDeleteAppProfile(w http.ResponseWriter, r *http.Request, vars map[strin
 	return key, nil
 }
 
-// Create a appProfile object
-func CreateAppProfile(obj *AppProfile) error {
+// Create a addressSet object
+func CreateAddressSet(obj *AddressSet) error {
 	// Validate parameters
-	err := ValidateAppProfile(obj)
+	err := ValidateAddressSet(obj)
 	if err != nil {
-		log.Errorf("ValidateAppProfile retruned error for: %+v. Err: %v", obj, err)
+		log.Errorf("ValidateAddressSet retruned error for: %+v. Err: %v", obj, err)
 		return err
 	}
 
 	// Check if we handle this object
-	if objCallbackHandler.AppProfileCb == nil {
-		log.Errorf("No callback registered for appProfile object")
+	if objCallbackHandler.AddressSetCb == nil {
+		log.Errorf("No callback registered for addressSet object")
 		return errors.New("Invalid object type")
 	}
 
 	saveObj := obj
 
-	collections.appProfileMutex.Lock()
-	key := collections.appProfiles[obj.Key]
-	collections.appProfileMutex.Unlock()
+	collections.addressSetMutex.Lock()
+	key := collections.addressSets[obj.Key]
+	collections.addressSetMutex.Unlock()
 
 	// Check if object already exists
 	if key != nil {
 		// Perform Update callback
-		err = objCallbackHandler.AppProfileCb.AppProfileUpdate(collections.appProfiles[obj.Key], obj)
+		err = objCallbackHandler.AddressSetCb.AddressSetUpdate(collections.addressSets[obj.Key], obj)
 		if err != nil {
-			log.Errorf("AppProfileUpdate retruned error for: %+v. Err: %v", obj, err)
+			log.Errorf("AddressSetUpdate retruned error for: %+v. Err: %v", obj, err)
 			return err
 		}
 
 		// save the original object after update
-		collections.appProfileMutex.Lock()
-		saveObj = collections.appProfiles[obj.Key]
-		collections.appProfileMutex.Unlock()
+		collections.addressSetMutex.Lock()
+		saveObj = collections.addressSets[obj.Key]
+		collections.addressSetMutex.Unlock()
 	} else {
 		// save it in cache
-		collections.appProfileMutex.Lock()
-		collections.appProfiles[obj.Key] = obj
-		collections.appProfileMutex.Unlock()
+		collections.addressSetMutex.Lock()
+		collections.addressSets[obj.Key] = obj
+		collections.addressSetMutex.Unlock()
 
 		// Perform Create callback
-		err = objCallbackHandler.AppProfileCb.AppProfileCreate(obj)
+		err = objCallbackHandler.AddressSetCb.AddressSetCreate(obj)
 		if err != nil {
-			log.Errorf("AppProfileCreate retruned error for: %+v. Err: %v", obj, err)
-			collections.appProfileMutex.Lock()
-			delete(collections.appProfiles, obj.Key)
-			collections.appProfileMutex.Unlock()
+			log.Errorf("AddressSetCreate retruned error for: %+v. Err: %v", obj, err)
+			collections.addressSetMutex.Lock()
+			delete(collections.addressSets, obj.Key)
+			collections.addressSetMutex.Unlock()
 			return err
 		}
 	}
 
 	// Write it to modeldb
-	collections.appProfileMutex.Lock()
+	collections.addressSetMutex.Lock()
 	err = saveObj.Write()
-	collections.appProfileMutex.Unlock()
+	collections.addressSetMutex.Unlock()
 	if err != nil {
-		log.Errorf("Error saving appProfile %s to db. Err: %v", saveObj.Key, err)
+		log.Errorf("Error saving addressSet %s to db. Err: %v", saveObj.Key, err)
 		return err
 	}
 
 	return nil
 }
 
-// Return a pointer to appProfile from collection
-func FindAppProfile(key string) *AppProfile {
-	collections.appProfileMutex.Lock()
-	defer collections.appProfileMutex.Unlock()
+// Return a pointer to addressSet from collection
+func FindAddressSet(key string) *AddressSet {
+	collections.addressSetMutex.Lock()
+	defer collections.addressSetMutex.Unlock()
 
-	obj := collections.appProfiles[key]
+	obj := collections.addressSets[key]
 	if obj == nil {
 		return nil
 	}
@@ -1611,126 +1762,126 @@ func FindAppProfile(key string) *AppProfile {
 	return obj
 }
 
-// Delete a appProfile object
-func DeleteAppProfile(key string) error {
-	collections.appProfileMutex.Lock()
-	obj := collections.appProfiles[key]
-	collections.appProfileMutex.Unlock()
+// Delete a addressSet object
+func DeleteAddressSet(key string) error {
+	collections.addressSetMutex.Lock()
+	obj := collections.addressSets[key]
+	collections.addressSetMutex.Unlock()
 	if obj == nil {
-		log.Errorf("appProfile %s not found", key)
-		return errors.New("appProfile not found")
+		log.Errorf("addressSet %s not found", key)
+		return errors.New("addressSet not found")
 	}
 
 	// Check if we handle this object
-	if objCallbackHandler.AppProfileCb == nil {
-		log.Errorf("No callback registered for appProfile object")
+	if objCallbackHandler.AddressSetCb == nil {
+		log.Errorf("No callback registered for addressSet object")
 		return errors.New("Invalid object type")
 	}
 
 	// Perform callback
-	err := objCallbackHandler.AppProfileCb.AppProfileDelete(obj)
+	err := objCallbackHandler.AddressSetCb.AddressSetDelete(obj)
 	if err != nil {
-		log.Errorf("AppProfileDelete retruned error for: %+v. Err: %v", obj, err)
+		log.Errorf("AddressSetDelete retruned error for: %+v. Err: %v", obj, err)
 		return err
 	}
 
 	// delete it from modeldb
-	collections.appProfileMutex.Lock()
+	collections.addressSetMutex.Lock()
 	err = obj.Delete()
-	collections.appProfileMutex.Unlock()
+	collections.addressSetMutex.Unlock()
 	if err != nil {
-		log.Errorf("Error deleting appProfile %s. Err: %v", obj.Key, err)
+		log.Errorf("Error deleting addressSet %s. Err: %v", obj.Key, err)
 	}
 
 	// delete it from cache
-	collections.appProfileMutex.Lock()
-	delete(collections.appProfiles, key)
-	collections.appProfileMutex.Unlock()
+	collections.addressSetMutex.Lock()
+	delete(collections.addressSets, key)
+	collections.addressSetMutex.Unlock()
 
 	return nil
 }
 
-func (self *AppProfile) GetType() string {
-	return "appProfile"
+func (self *AddressSet) GetType() string {
+	return "addressSet"
 }
 
-func (self *AppProfile) GetKey() string {
+func (self *AddressSet) GetKey() string {
 	return self.Key
 }
 
-func (self *AppProfile) Read() error {
+func (self *AddressSet) Read() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to read appProfile object")
+		log.Errorf("Empty key while trying to read addressSet object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.ReadObj("appProfile", self.Key, self)
+	return modeldb.ReadObj("addressSet", self.Key, self)
 }
 
-func (self *AppProfile) Write() error {
+func (self *AddressSet) Write() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to Write appProfile object")
+		log.Errorf("Empty key while trying to Write addressSet object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.WriteObj("appProfile", self.Key, self)
+	return modeldb.WriteObj("addressSet", self.Key, self)
 }
 
-func (self *AppProfile) Delete() error {
+func (self *AddressSet) Delete() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to Delete appProfile object")
+		log.Errorf("Empty key while trying to Delete addressSet object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.DeleteObj("appProfile", self.Key)
+	return modeldb.DeleteObj("addressSet", self.Key)
 }
 
-func restoreAppProfile() error {
-	collections.appProfileMutex.Lock()
-	defer collections.appProfileMutex.Unlock()
+func restoreAddressSet() error {
+	collections.addressSetMutex.Lock()
+	defer collections.addressSetMutex.Unlock()
 
-	strList, err := modeldb.ReadAllObj("appProfile")
+	strList, err := modeldb.ReadAllObj("addressSet")
 	if err != nil {
-		log.Errorf("Error reading appProfile list. Err: %v", err)
+		log.Errorf("Error reading addressSet list. Err: %v", err)
 	}
 
 	for _, objStr := range strList {
 		// Parse the json model
-		var appProfile AppProfile
-		err = json.Unmarshal([]byte(objStr), &appProfile)
+		var addressSet AddressSet
+		err = json.Unmarshal([]byte(objStr), &addressSet)
 		if err != nil {
 			log.Errorf("Error parsing object %s, Err %v", objStr, err)
 			return err
 		}
 
 		// add it to the collection
-		collections.appProfiles[appProfile.Key] = &appProfile
+		collections.addressSets[addressSet.Key] = &addressSet
 	}
 
 	return nil
 }
 
-// Validate a appProfile object
-func ValidateAppProfile(obj *AppProfile) error {
-	collections.appProfileMutex.Lock()
-	defer collections.appProfileMutex.Unlock()
+// Validate a addressSet object
+func ValidateAddressSet(obj *AddressSet) error {
+	collections.addressSetMutex.Lock()
+	defer collections.addressSetMutex.Unlock()
 
 	// Validate key is correct
-	keyStr := obj.TenantName + ":" + obj.AppProfileName
+	keyStr := obj.TenantName + ":" + obj.AddressSetName
 	if obj.Key != keyStr {
-		log.Errorf("Expecting AppProfile Key: %s. Got: %s", keyStr, obj.Key)
+		log.Errorf("Expecting AddressSet Key: %s. Got: %s", keyStr, obj.Key)
 		return errors.New("Invalid Key")
 	}
 
 	// Validate each field
 
-	if len(obj.AppProfileName) > 64 {
-		return errors.New("appProfileName string too long")
+	if len(obj.AddressSetName) > 64 {
+		return errors.New("addressSetName string too long")
 	}
 
-	appProfileNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
-	if appProfileNameMatch.MatchString(obj.AppProfileName) == false {
-		return errors.New("appProfileName string invalid format")
+	addressSetNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
+	if addressSetNameMatch.MatchString(obj.AddressSetName) == false {
+		return errors.New("addressSetName string invalid format")
 	}
 
 	if len(obj.TenantName) > 64 {
@@ -1746,56 +1897,33 @@ func ValidateAppProfile(obj *AppProfile) error {
 }
 
 // GET Oper REST call
-func httpInspectBgp(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	var obj BgpInspect
-	log.Debugf("Received httpInspectBgp: %+v", vars)
+func httpInspectAppProfile(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	var obj AppProfileInspect
+	log.Debugf("Received httpInspectAppProfile: %+v", vars)
 
 	key := vars["key"]
 
-	collections.BgpMutex.Lock()
-	defer collections.BgpMutex.Unlock()
-	objConfig := collections.Bgps[key]
+	collections.appProfileMutex.Lock()
+	defer collections.appProfileMutex.Unlock()
+	objConfig := collections.appProfiles[key]
 	if objConfig == nil {
-		log.Errorf("Bgp %s not found", key)
-		return nil, errors.New("Bgp not found")
+		log.Errorf("appProfile %s not found", key)
+		return nil, errors.New("appProfile not found")
 	}
 	obj.Config = *objConfig
 
-	if err := GetOperBgp(&obj); err != nil {
-		log.Errorf("GetBgp error for: %+v. Err: %v", obj, err)
-		return nil, err
-	}
-
 	// Return the obj
 	return &obj, nil
 }
 
-// Get a BgpOper object
-func GetOperBgp(obj *BgpInspect) error {
-	// Check if we handle this object
-	if objCallbackHandler.BgpCb == nil {
-		log.Errorf("No callback registered for Bgp object")
-		return errors.New("Invalid object type")
-	}
-
-	// Perform callback
-	err := objCallbackHandler.BgpCb.BgpGetOper(obj)
-	if err != nil {
-		log.Errorf("BgpDelete retruned error for: %+v. Err: %v", obj, err)
-		return err
-	}
-
-	return nil
-}
-
 // LIST REST call
-func httpListBgps(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpListBgps: %+v", vars)
+func httpListAppProfiles(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpListAppProfiles: %+v", vars)
 
-	list := make([]*Bgp, 0)
-	collections.BgpMutex.Lock()
-	defer collections.BgpMutex.Unlock()
-	for _, obj := range collections.Bgps {
+	list := make([]*AppProfile, 0)
+	collections.appProfileMutex.Lock()
+	defer collections.appProfileMutex.Unlock()
+	for _, obj := range collections.appProfiles {
 		list = append(list, obj)
 	}
 
@@ -1804,17 +1932,17 @@ func httpListBgps(w http.ResponseWriter, r *http.Request, vars map[string]string
 }
 
 // GET REST call
-func httpGetBgp(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpGetBgp: %+v", vars)
+func httpGetAppProfile(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetAppProfile: %+v", vars)
 
 	key := vars["key"]
 
-	collections.BgpMutex.Lock()
-	defer collections.BgpMutex.Unlock()
-	obj := collections.Bgps[key]
+	collections.appProfileMutex.Lock()
+	defer collections.appProfileMutex.Unlock()
+	obj := collections.appProfiles[key]
 	if obj == nil {
-		log.Infof("Bgp %s not found", key)
-		return nil, errors.New("Bgp not found")
+		log.Infof("appProfile %s not found", key)
+		return nil, errors.New("appProfile not found")
 	}
 
 	// Return the obj
@@ -1822,16 +1950,16 @@ func httpGetBgp(w http.ResponseWriter, r *http.Request, vars map[string]string)
 }
 
 // CREATE REST call
-func httpCreateBgp(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpGetBgp: %+v", vars)
+func httpCreateAppProfile(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetAppProfile: %+v", vars)
 
-	var obj Bgp
+	var obj AppProfile
 	key := vars["key"]
 
 	// Get object from the request
 	err := json.NewDecoder(r.Body).Decode(&obj)
 	if err != nil {
-		log.Errorf("Error decoding Bgp create request. Err %v", err)
+		log.Errorf("Error decoding appProfile create request. Err %v", err)
 		return nil, err
 	}
 
@@ -1839,9 +1967,9 @@ func httpCreateBgp(w http.ResponseWriter, r *http.Request, vars map[string]strin
 	obj.Key = key
 
 	// Create the object
-	err = CreateBgp(&obj)
+	err = CreateAppProfile(&obj)
 	if err != nil {
-		log.Errorf("CreateBgp error for: %+v. Err: %v", obj, err)
+		log.Errorf("CreateAppProfile error for: %+v. Err: %v", obj, err)
 		return nil, err
 	}
 
@@ -1850,15 +1978,15 @@ func httpCreateBgp(w http.ResponseWriter, r *http.Request, vars map[string]strin
 }
 
 // DELETE rest call
-func httpDeleteBgp(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpDeleteBgp: %+v", vars)
+func httpDeleteAppProfile(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpDeleteAppProfile: %+v", vars)
 
 	key := vars["key"]
 
 	// Delete the object
-	err := DeleteBgp(key)
+	err := DeleteAppProfile(key)
 	if err != nil {
-		log.Errorf("DeleteBgp error for: %s. Err: %v", key, err)
+		log.Errorf("DeleteAppProfile error for: %s. Err: %v", key, err)
 		return nil, err
 	}
 
@@ -1866,75 +1994,75 @@ func httpDeleteBgp(w http.ResponseWriter, r *http.Request, vars map[string]strin
 	return key, nil
 }
 
-// Create a Bgp object
-func CreateBgp(obj *Bgp) error {
+// Create a appProfile object
+func CreateAppProfile(obj *AppProfile) error {
 	// Validate parameters
-	err := ValidateBgp(obj)
+	err := ValidateAppProfile(obj)
 	if err != nil {
-		log.Errorf("ValidateBgp retruned error for: %+v. Err: %v", obj, err)
+		log.Errorf("ValidateAppProfile retruned error for: %+v. Err: %v", obj, err)
 		return err
 	}
 
 	// Check if we handle this object
-	if objCallbackHandler.BgpCb == nil {
-		log.Errorf("No callback registered for Bgp object")
+	if objCallbackHandler.AppProfileCb == nil {
+		log.Errorf("No callback registered for appProfile object")
 		return errors.New("Invalid object type")
 	}
 
 	saveObj := obj
 
-	collections.BgpMutex.Lock()
-	key := collections.Bgps[obj.Key]
-	collections.BgpMutex.Unlock()
+	collections.appProfileMutex.Lock()
+	key := collections.appProfiles[obj.Key]
+	collections.appProfileMutex.Unlock()
 
 	// Check if object already exists
 	if key != nil {
 		// Perform Update callback
-		err = objCallbackHandler.BgpCb.BgpUpdate(collections.Bgps[obj.Key], obj)
+		err = objCallbackHandler.AppProfileCb.AppProfileUpdate(collections.appProfiles[obj.Key], obj)
 		if err != nil {
-			log.Errorf("BgpUpdate retruned error for: %+v. Err: %v", obj, err)
+			log.Errorf("AppProfileUpdate retruned error for: %+v. Err: %v", obj, err)
 			return err
 		}
 
 		// save the original object after update
-		collections.BgpMutex.Lock()
-		saveObj = collections.Bgps[obj.Key]
-		collections.BgpMutex.Unlock()
+		collections.appProfileMutex.Lock()
+		saveObj = collections.appProfiles[obj.Key]
+		collections.appProfileMutex.Unlock()
 	} else {
 		// save it in cache
-		collections.BgpMutex.Lock()
-		collections.Bgps[obj.Key] = obj
-		collections.BgpMutex.Unlock()
+		collections.appProfileMutex.Lock()
+		collections.appProfiles[obj.Key] = obj
+		collections.appProfileMutex.Unlock()
 
 		// Perform Create callback
-		err = objCallbackHandler.BgpCb.BgpCreate(obj)
+		err = objCallbackHandler.AppProfileCb.AppProfileCreate(obj)
 		if err != nil {
-			log.Errorf("BgpCreate retruned error for: %+v. Err: %v", obj, err)
-			collections.BgpMutex.Lock()
-			delete(collections.Bgps, obj.Key)
-			collections.BgpMutex.Unlock()
+			log.Errorf("AppProfileCreate retruned error for: %+v. Err: %v", obj, err)
+			collections.appProfileMutex.Lock()
+			delete(collections.appProfiles, obj.Key)
+			collections.appProfileMutex.Unlock()
 			return err
 		}
 	}
 
 	// Write it to modeldb
-	collections.BgpMutex.Lock()
+	collections.appProfileMutex.Lock()
 	err = saveObj.Write()
-	collections.BgpMutex.Unlock()
+	collections.appProfileMutex.Unlock()
 	if err != nil {
-		log.Errorf("Error saving Bgp %s to db. Err: %v", saveObj.Key, err)
+		log.Errorf("Error saving appProfile %s to db. Err: %v", saveObj.Key, err)
 		return err
 	}
 
 	return nil
 }
 
-// Return a pointer to Bgp from collection
-func FindBgp(key string) *Bgp {
-	collections.BgpMutex.Lock()
-	defer collections.BgpMutex.Unlock()
+// Return a pointer to appProfile from collection
+func FindAppProfile(key string) *AppProfile {
+	collections.appProfileMutex.Lock()
+	defer collections.appProfileMutex.Unlock()
 
-	obj := collections.Bgps[key]
+	obj := collections.appProfiles[key]
 	if obj == nil {
 		return nil
 	}
@@ -1942,162 +2070,158 @@ func FindBgp(key string) *Bgp {
 	return obj
 }
 
-// Delete a Bgp object
-func DeleteBgp(key string) error {
-	collections.BgpMutex.Lock()
-	obj := collections.Bgps[key]
-	collections.BgpMutex.Unlock()
+// Delete a appProfile object
+func DeleteAppProfile(key string) error {
+	collections.appProfileMutex.Lock()
+	obj := collections.appProfiles[key]
+	collections.appProfileMutex.Unlock()
 	if obj == nil {
-		log.Errorf("Bgp %s not found", key)
-		return errors.New("Bgp not found")
+		log.Errorf("appProfile %s not found", key)
+		return errors.New("appProfile not found")
 	}
 
 	// Check if we handle this object
-	if objCallbackHandler.BgpCb == nil {
-		log.Errorf("No callback registered for Bgp object")
+	if objCallbackHandler.AppProfileCb == nil {
+		log.Errorf("No callback registered for appProfile object")
 		return errors.New("Invalid object type")
 	}
 
 	// Perform callback
-	err := objCallbackHandler.BgpCb.BgpDelete(obj)
+	err := objCallbackHandler.AppProfileCb.AppProfileDelete(obj)
 	if err != nil {
-		log.Errorf("BgpDelete retruned error for: %+v. Err: %v", obj, err)
+		log.Errorf("AppProfileDelete retruned error for: %+v. Err: %v", obj, err)
 		return err
 	}
 
 	// delete it from modeldb
-	collections.BgpMutex.Lock()
+	collections.appProfileMutex.Lock()
 	err = obj.Delete()
-	collections.BgpMutex.Unlock()
+	collections.appProfileMutex.Unlock()
 	if err != nil {
-		log.Errorf("Error deleting Bgp %s. Err: %v", obj.Key, err)
+		log.Errorf("Error deleting appProfile %s. Err: %v", obj.Key, err)
 	}
 
 	// delete it from cache
-	collections.BgpMutex.Lock()
-	delete(collections.Bgps, key)
-	collections.BgpMutex.Unlock()
+	collections.appProfileMutex.Lock()
+	delete(collections.appProfiles, key)
+	collections.appProfileMutex.Unlock()
 
 	return nil
 }
 
-func (self *Bgp) GetType() string {
-	return "Bgp"
+func (self *AppProfile) GetType() string {
+	return "appProfile"
 }
 
-func (self *Bgp) GetKey() string {
+func (self *AppProfile) GetKey() string {
 	return self.Key
 }
 
-func (self *Bgp) Read() error {
+func (self *AppProfile) Read() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to read Bgp object")
+		log.Errorf("Empty key while trying to read appProfile object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.ReadObj("Bgp", self.Key, self)
+	return modeldb.ReadObj("appProfile", self.Key, self)
 }
 
-func (self *Bgp) Write() error {
+func (self *AppProfile) Write() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to Write Bgp object")
+		log.Errorf("Empty key while trying to Write appProfile object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.WriteObj("Bgp", self.Key, self)
+	return modeldb.WriteObj("appProfile", self.Key, self)
 }
 
-func (self *Bgp) Delete() error {
+func (self *AppProfile) Delete() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to Delete Bgp object")
+		log.Errorf("Empty key while trying to Delete appProfile object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.DeleteObj("Bgp", self.Key)
+	return modeldb.DeleteObj("appProfile", self.Key)
 }
 
-func restoreBgp() error {
-	collections.BgpMutex.Lock()
-	defer collections.BgpMutex.Unlock()
+func restoreAppProfile() error {
+	collections.appProfileMutex.Lock()
+	defer collections.appProfileMutex.Unlock()
 
-	strList, err := modeldb.ReadAllObj("Bgp")
+	strList, err := modeldb.ReadAllObj("appProfile")
 	if err != nil {
-		log.Errorf("Error reading Bgp list. Err: %v", err)
+		log.Errorf("Error reading appProfile list. Err: %v", err)
 	}
 
 	for _, objStr := range strList {
 		// Parse the json model
-		var Bgp Bgp
-		err = json.Unmarshal([]byte(objStr), &Bgp)
+		var appProfile AppProfile
+		err = json.Unmarshal([]byte(objStr), &appProfile)
 		if err != nil {
 			log.Errorf("Error parsing object %s, Err %v", objStr, err)
 			return err
 		}
 
 		// add it to the collection
-		collections.Bgps[Bgp.Key] = &Bgp
+		collections.appProfiles[appProfile.Key] = &appProfile
 	}
 
 	return nil
 }
 
-// Validate a Bgp object
-func ValidateBgp(obj *Bgp) error {
-	collections.BgpMutex.Lock()
-	defer collections.BgpMutex.Unlock()
+// Validate a appProfile object
+func ValidateAppProfile(obj *AppProfile) error {
+	collections.appProfileMutex.Lock()
+	defer collections.appProfileMutex.Unlock()
 
 	// Validate key is correct
-	keyStr := obj.Hostname
+	keyStr := obj.TenantName + ":" + obj.AppProfileName
 	if obj.Key != keyStr {
-		log.Errorf("Expecting Bgp Key: %s. Got: %s", keyStr, obj.Key)
+		log.Errorf("Expecting AppProfile Key: %s. Got: %s", keyStr, obj.Key)
 		return errors.New("Invalid Key")
 	}
 
 	// Validate each field
 
-	if len(obj.As) > 64 {
-		return errors.New("as string too long")
-	}
-
-	if len(obj.Hostname) > 256 {
-		return errors.New("hostname string too long")
-	}
-
-	hostnameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
-	if hostnameMatch.MatchString(obj.Hostname) == false {
-		return errors.New("hostname string invalid format")
-	}
-
-	if len(obj.Neighbor) > 15 {
-		return errors.New("neighbor string too long")
+	if len(obj.AppProfileName) > 64 {
+		return errors.New("appProfileName string too long")
 	}
 
-	neighborMatch := regexp.MustCompile("^((25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])(\\.(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])){3})?$")
-	if neighborMatch.MatchString(obj.Neighbor) == false {
-		return errors.New("neighbor string invalid format")
+	appProfileNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
+	if appProfileNameMatch.MatchString(obj.AppProfileName) == false {
+		return errors.New("appProfileName string invalid format")
 	}
 
-	if len(obj.NeighborAs) > 64 {
-		return errors.New("neighbor-as string too long")
+	if len(obj.TenantName) > 64 {
+		return errors.New("tenantName string too long")
 	}
 
-	routeripMatch := regexp.MustCompile("^((25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])(\\.(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])){3})(\\-(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9]))?/(3[0-1]|2[0-9]|1[0-9]|[1-9])$")
-	if routeripMatch.MatchString(obj.Routerip) == false {
-		return errors.New("routerip string invalid format")
+	tenantNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
+	if tenantNameMatch.MatchString(obj.TenantName) == false {
+		return errors.New("tenantName string invalid format")
 	}
 
 	return nil
 }
 
 // GET Oper REST call
-func httpInspectEndpoint(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	var obj EndpointInspect
-	log.Debugf("Received httpInspectEndpoint: %+v", vars)
+func httpInspectBgp(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	var obj BgpInspect
+	log.Debugf("Received httpInspectBgp: %+v", vars)
 
-	obj.Oper.Key = vars["key"]
+	key := vars["key"]
 
-	if err := GetOperEndpoint(&obj); err != nil {
-		log.Errorf("GetEndpoint error for: %+v. Err: %v", obj, err)
+	collections.BgpMutex.Lock()
+	defer collections.BgpMutex.Unlock()
+	objConfig := collections.Bgps[key]
+	if objConfig == nil {
+		log.Errorf("Bgp %s not found", key)
+		return nil, errors.New("Bgp not found")
+	}
+	obj.Config = *objConfig
+
+	if err := GetOperBgp(&obj); err != nil {
+		log.Errorf("GetBgp error for: %+v. Err: %v", obj, err)
 		return nil, err
 	}
 
@@ -2105,75 +2229,745 @@ func httpInspectEndpoint(w http.ResponseWriter, r *http.Request, vars map[string
 	return &obj, nil
 }
 
-// Get a endpointOper object
-func GetOperEndpoint(obj *EndpointInspect) error {
+// Get a BgpOper object
+func GetOperBgp(obj *BgpInspect) error {
 	// Check if we handle this object
-	if objCallbackHandler.EndpointCb == nil {
-		log.Errorf("No callback registered for endpoint object")
+	if objCallbackHandler.BgpCb == nil {
+		log.Errorf("No callback registered for Bgp object")
 		return errors.New("Invalid object type")
 	}
 
 	// Perform callback
-	err := objCallbackHandler.EndpointCb.EndpointGetOper(obj)
+	err := objCallbackHandler.BgpCb.BgpGetOper(obj)
 	if err != nil {
-		log.Errorf("EndpointDelete retruned error for: %+v. Err: %v", obj, err)
+		log.Errorf("BgpDelete retruned error for: %+v. Err: %v", obj, err)
 		return err
 	}
 
 	return nil
 }
 
-// GET Oper REST call
-func httpInspectEndpointGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	var obj EndpointGroupInspect
-	log.Debugf("Received httpInspectEndpointGroup: %+v", vars)
-
-	key := vars["key"]
+// LIST REST call
+func httpListBgps(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpListBgps: %+v", vars)
 
-	collections.endpointGroupMutex.Lock()
-	defer collections.endpointGroupMutex.Unlock()
-	objConfig := collections.endpointGroups[key]
-	if objConfig == nil {
-		log.Errorf("endpointGroup %s not found", key)
-		return nil, errors.New("endpointGroup not found")
+	list := make([]*Bgp, 0)
+	collections.BgpMutex.Lock()
+	defer collections.BgpMutex.Unlock()
+	for _, obj := range collections.Bgps {
+		list = append(list, obj)
 	}
-	obj.Config = *objConfig
 
-	if err := GetOperEndpointGroup(&obj); err != nil {
-		log.Errorf("GetEndpointGroup error for: %+v. Err: %v", obj, err)
-		return nil, err
+	// Return the list
+	return list, nil
+}
+
+// GET REST call
+func httpGetBgp(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetBgp: %+v", vars)
+
+	key := vars["key"]
+
+	collections.BgpMutex.Lock()
+	defer collections.BgpMutex.Unlock()
+	obj := collections.Bgps[key]
+	if obj == nil {
+		log.Infof("Bgp %s not found", key)
+		return nil, errors.New("Bgp not found")
 	}
 
 	// Return the obj
-	return &obj, nil
+	return obj, nil
 }
 
-// Get a endpointGroupOper object
-func GetOperEndpointGroup(obj *EndpointGroupInspect) error {
-	// Check if we handle this object
-	if objCallbackHandler.EndpointGroupCb == nil {
-		log.Errorf("No callback registered for endpointGroup object")
-		return errors.New("Invalid object type")
+// CREATE REST call
+func httpCreateBgp(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetBgp: %+v", vars)
+
+	var obj Bgp
+	key := vars["key"]
+
+	// Get object from the request
+	err := json.NewDecoder(r.Body).Decode(&obj)
+	if err != nil {
+		log.Errorf("Error decoding Bgp create request. Err %v", err)
+		return nil, err
+	}
+
+	// set the key
+	obj.Key = key
+
+	// Create the object
+	err = CreateBgp(&obj)
+	if err != nil {
+		log.Errorf("CreateBgp error for: %+v. Err: %v", obj, err)
+		return nil, err
+	}
+
+	// Return the obj
+	return obj, nil
+}
+
+// DELETE rest call
+func httpDeleteBgp(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpDeleteBgp: %+v", vars)
+
+	key := vars["key"]
+
+	// Delete the object
+	err := DeleteBgp(key)
+	if err != nil {
+		log.Errorf("DeleteBgp error for: %s. Err: %v", key, err)
+		return nil, err
+	}
+
+	// Return the obj
+	return key, nil
+}
+
+// Create a Bgp object
+func CreateBgp(obj *Bgp) error {
+	// Validate parameters
+	err := ValidateBgp(obj)
+	if err != nil {
+		log.Errorf("ValidateBgp retruned error for: %+v. Err: %v", obj, err)
+		return err
+	}
+
+	// Check if we handle this object
+	if objCallbackHandler.BgpCb == nil {
+		log.Errorf("No callback registered for Bgp object")
+		return errors.New("Invalid object type")
+	}
+
+	saveObj := obj
+
+	collections.BgpMutex.Lock()
+	key := collections.Bgps[obj.Key]
+	collections.BgpMutex.Unlock()
+
+	// Check if object already exists
+	if key != nil {
+		// Perform Update callback
+		err = objCallbackHandler.BgpCb.BgpUpdate(collections.Bgps[obj.Key], obj)
+		if err != nil {
+			log.Errorf("BgpUpdate retruned error for: %+v. Err: %v", obj, err)
+			return err
+		}
+
+		// save the original object after update
+		collections.BgpMutex.Lock()
+		saveObj = collections.Bgps[obj.Key]
+		collections.BgpMutex.Unlock()
+	} else {
+		// save it in cache
+		collections.BgpMutex.Lock()
+		collections.Bgps[obj.Key] = obj
+		collections.BgpMutex.Unlock()
+
+		// Perform Create callback
+		err = objCallbackHandler.BgpCb.BgpCreate(obj)
+		if err != nil {
+			log.Errorf("BgpCreate retruned error for: %+v. Err: %v", obj, err)
+			collections.BgpMutex.Lock()
+			delete(collections.Bgps, obj.Key)
+			collections.BgpMutex.Unlock()
+			return err
+		}
+	}
+
+	// Write it to modeldb
+	collections.BgpMutex.Lock()
+	err = saveObj.Write()
+	collections.BgpMutex.Unlock()
+	if err != nil {
+		log.Errorf("Error saving Bgp %s to db. Err: %v", saveObj.Key, err)
+		return err
+	}
+
+	return nil
+}
+
+// Return a pointer to Bgp from collection
+func FindBgp(key string) *Bgp {
+	collections.BgpMutex.Lock()
+	defer collections.BgpMutex.Unlock()
+
+	obj := collections.Bgps[key]
+	if obj == nil {
+		return nil
+	}
+
+	return obj
+}
+
+// Delete a Bgp object
+func DeleteBgp(key string) error {
+	collections.BgpMutex.Lock()
+	obj := collections.Bgps[key]
+	collections.BgpMutex.Unlock()
+	if obj == nil {
+		log.Errorf("Bgp %s not found", key)
+		return errors.New("Bgp not found")
+	}
+
+	// Check if we handle this object
+	if objCallbackHandler.BgpCb == nil {
+		log.Errorf("No callback registered for Bgp object")
+		return errors.New("Invalid object type")
+	}
+
+	// Perform callback
+	err := objCallbackHandler.BgpCb.BgpDelete(obj)
+	if err != nil {
+		log.Errorf("BgpDelete retruned error for: %+v. Err: %v", obj, err)
+		return err
+	}
+
+	// delete it from modeldb
+	collections.BgpMutex.Lock()
+	err = obj.Delete()
+	collections.BgpMutex.Unlock()
+	if err != nil {
+		log.Errorf("Error deleting Bgp %s. Err: %v", obj.Key, err)
+	}
+
+	// delete it from cache
+	collections.BgpMutex.Lock()
+	delete(collections.Bgps, key)
+	collections.BgpMutex.Unlock()
+
+	return nil
+}
+
+func (self *Bgp) GetType() string {
+	return "Bgp"
+}
+
+func (self *Bgp) GetKey() string {
+	return self.Key
+}
+
+func (self *Bgp) Read() error {
+	if self.Key == "" {
+		log.Errorf("Empty key while trying to read Bgp object")
+		return errors.New("Empty key")
+	}
+
+	return modeldb.ReadObj("Bgp", self.Key, self)
+}
+
+func (self *Bgp) Write() error {
+	if self.Key == "" {
+		log.Errorf("Empty key while trying to Write Bgp object")
+		return errors.New("Empty key")
+	}
+
+	return modeldb.WriteObj("Bgp", self.Key, self)
+}
+
+func (self *Bgp) Delete() error {
+	if self.Key == "" {
+		log.Errorf("Empty key while trying to Delete Bgp object")
+		return errors.New("Empty key")
+	}
+
+	return modeldb.DeleteObj("Bgp", self.Key)
+}
+
+func restoreBgp() error {
+	collections.BgpMutex.Lock()
+	defer collections.BgpMutex.Unlock()
+
+	strList, err := modeldb.ReadAllObj("Bgp")
+	if err != nil {
+		log.Errorf("Error reading Bgp list. Err: %v", err)
+	}
+
+	for _, objStr := range strList {
+		// Parse the json model
+		var Bgp Bgp
+		err = json.Unmarshal([]byte(objStr), &Bgp)
+		if err != nil {
+			log.Errorf("Error parsing object %s, Err %v", objStr, err)
+			return err
+		}
+
+		// add it to the collection
+		collections.Bgps[Bgp.Key] = &Bgp
+	}
+
+	return nil
+}
+
+// Validate a Bgp object
+func ValidateBgp(obj *Bgp) error {
+	collections.BgpMutex.Lock()
+	defer collections.BgpMutex.Unlock()
+
+	// Validate key is correct
+	keyStr := obj.Hostname
+	if obj.Key != keyStr {
+		log.Errorf("Expecting Bgp Key: %s. Got: %s", keyStr, obj.Key)
+		return errors.New("Invalid Key")
+	}
+
+	// Validate each field
+
+	if len(obj.As) > 64 {
+		return errors.New("as string too long")
+	}
+
+	if len(obj.Hostname) > 256 {
+		return errors.New("hostname string too long")
+	}
+
+	hostnameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
+	if hostnameMatch.MatchString(obj.Hostname) == false {
+		return errors.New("hostname string invalid format")
+	}
+
+	if len(obj.Neighbor) > 15 {
+		return errors.New("neighbor string too long")
+	}
+
+	neighborMatch := regexp.MustCompile("^((25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])(\\.(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])){3})?$")
+	if neighborMatch.MatchString(obj.Neighbor) == false {
+		return errors.New("neighbor string invalid format")
+	}
+
+	if len(obj.NeighborAs) > 64 {
+		return errors.New("neighbor-as string too long")
+	}
+
+	routeripMatch := regexp.MustCompile("^((25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])(\\.(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])){3})(\\-(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9]))?/(3[0-1]|2[0-9]|1[0-9]|[1-9])$")
+	if routeripMatch.MatchString(obj.Routerip) == false {
+		return errors.New("routerip string invalid format")
+	}
+
+	return nil
+}
+
+// GET Oper REST call
+func httpInspectEndpoint(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	var obj EndpointInspect
+	log.Debugf("Received httpInspectEndpoint: %+v", vars)
+
+	obj.Oper.Key = vars["key"]
+
+	if err := GetOperEndpoint(&obj); err != nil {
+		log.Errorf("GetEndpoint error for: %+v. Err: %v", obj, err)
+		return nil, err
+	}
+
+	// Return the obj
+	return &obj, nil
+}
+
+// Get a endpointOper object
+func GetOperEndpoint(obj *EndpointInspect) error {
+	// Check if we handle this object
+	if objCallbackHandler.EndpointCb == nil {
+		log.Errorf("No callback registered for endpoint object")
+		return errors.New("Invalid object type")
+	}
+
+	// Perform callback
+	err := objCallbackHandler.EndpointCb.EndpointGetOper(obj)
+	if err != nil {
+		log.Errorf("EndpointDelete retruned error for: %+v. Err: %v", obj, err)
+		return err
+	}
+
+	return nil
+}
+
+// GET Oper REST call
+func httpInspectEndpointGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	var obj EndpointGroupInspect
+	log.Debugf("Received httpInspectEndpointGroup: %+v", vars)
+
+	key := vars["key"]
+
+	collections.endpointGroupMutex.Lock()
+	defer collections.endpointGroupMutex.Unlock()
+	objConfig := collections.endpointGroups[key]
+	if objConfig == nil {
+		log.Errorf("endpointGroup %s not found", key)
+		return nil, errors.New("endpointGroup not found")
+	}
+	obj.Config = *objConfig
+
+	if err := GetOperEndpointGroup(&obj); err != nil {
+		log.Errorf("GetEndpointGroup error for: %+v. Err: %v", obj, err)
+		return nil, err
+	}
+
+	// Return the obj
+	return &obj, nil
+}
+
+// Get a endpointGroupOper object
+func GetOperEndpointGroup(obj *EndpointGroupInspect) error {
+	// Check if we handle this object
+	if objCallbackHandler.EndpointGroupCb == nil {
+		log.Errorf("No callback registered for endpointGroup object")
+		return errors.New("Invalid object type")
+	}
+
+	// Perform callback
+	err := objCallbackHandler.EndpointGroupCb.EndpointGroupGetOper(obj)
+	if err != nil {
+		log.Errorf("EndpointGroupDelete retruned error for: %+v. Err: %v", obj, err)
+		return err
+	}
+
+	return nil
+}
+
+// LIST REST call
+func httpListEndpointGroups(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpListEndpointGroups: %+v", vars)
+
+	list := make([]*EndpointGroup, 0)
+	collections.endpointGroupMutex.Lock()
+	defer collections.endpointGroupMutex.Unlock()
+	for _, obj := range collections.endpointGroups {
+		list = append(list, obj)
+	}
+
+	// Return the list
+	return list, nil
+}
+
+// GET REST call
+func httpGetEndpointGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetEndpointGroup: %+v", vars)
+
+	key := vars["key"]
+
+	collections.endpointGroupMutex.Lock()
+	defer collections.endpointGroupMutex.Unlock()
+	obj := collections.endpointGroups[key]
+	if obj == nil {
+		log.Infof("endpointGroup %s not found", key)
+		return nil, errors.New("endpointGroup not found")
+	}
+
+	// Return the obj
+	return obj, nil
+}
+
+// CREATE REST call
+func httpCreateEndpointGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetEndpointGroup: %+v", vars)
+
+	var obj EndpointGroup
+	key := vars["key"]
+
+	// Get object from the request
+	err := json.NewDecoder(r.Body).Decode(&obj)
+	if err != nil {
+		log.Errorf("Error decoding endpointGroup create request. Err %v", err)
+		return nil, err
+	}
+
+	// set the key
+	obj.Key = key
+
+	// Create the object
+	err = CreateEndpointGroup(&obj)
+	if err != nil {
+		log.Errorf("CreateEndpointGroup error for: %+v. Err: %v", obj, err)
+		return nil, err
+	}
+
+	// Return the obj
+	return obj, nil
+}
+
+// DELETE rest call
+func httpDeleteEndpointGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpDeleteEndpointGroup: %+v", vars)
+
+	key := vars["key"]
+
+	// Delete the object
+	err := DeleteEndpointGroup(key)
+	if err != nil {
+		log.Errorf("DeleteEndpointGroup error for: %s. Err: %v", key, err)
+		return nil, err
+	}
+
+	// Return the obj
+	return key, nil
+}
+
+// Create a endpointGroup object
+func CreateEndpointGroup(obj *EndpointGroup) error {
+	// Validate parameters
+	err := ValidateEndpointGroup(obj)
+	if err != nil {
+		log.Errorf("ValidateEndpointGroup retruned error for: %+v. Err: %v", obj, err)
+		return err
+	}
+
+	// Check if we handle this object
+	if objCallbackHandler.EndpointGroupCb == nil {
+		log.Errorf("No callback registered for endpointGroup object")
+		return errors.New("Invalid object type")
+	}
+
+	saveObj := obj
+
+	collections.endpointGroupMutex.Lock()
+	key := collections.endpointGroups[obj.Key]
+	collections.endpointGroupMutex.Unlock()
+
+	// Check if object already exists
+	if key != nil {
+		// Perform Update callback
+		err = objCallbackHandler.EndpointGroupCb.EndpointGroupUpdate(collections.endpointGroups[obj.Key], obj)
+		if err != nil {
+			log.Errorf("EndpointGroupUpdate retruned error for: %+v. Err: %v", obj, err)
+			return err
+		}
+
+		// save the original object after update
+		collections.endpointGroupMutex.Lock()
+		saveObj = collections.endpointGroups[obj.Key]
+		collections.endpointGroupMutex.Unlock()
+	} else {
+		// save it in cache
+		collections.endpointGroupMutex.Lock()
+		collections.endpointGroups[obj.Key] = obj
+		collections.endpointGroupMutex.Unlock()
+
+		// Perform Create callback
+		err = objCallbackHandler.EndpointGroupCb.EndpointGroupCreate(obj)
+		if err != nil {
+			log.Errorf("EndpointGroupCreate retruned error for: %+v. Err: %v", obj, err)
+			collections.endpointGroupMutex.Lock()
+			delete(collections.endpointGroups, obj.Key)
+			collections.endpointGroupMutex.Unlock()
+			return err
+		}
+	}
+
+	// Write it to modeldb
+	collections.endpointGroupMutex.Lock()
+	err = saveObj.Write()
+	collections.endpointGroupMutex.Unlock()
+	if err != nil {
+		log.Errorf("Error saving endpointGroup %s to db. Err: %v", saveObj.Key, err)
+		return err
+	}
+
+	return nil
+}
+
+// Return a pointer to endpointGroup from collection
+func FindEndpointGroup(key string) *EndpointGroup {
+	collections.endpointGroupMutex.Lock()
+	defer collections.endpointGroupMutex.Unlock()
+
+	obj := collections.endpointGroups[key]
+	if obj == nil {
+		return nil
+	}
+
+	return obj
+}
+
+// Delete a endpointGroup object
+func DeleteEndpointGroup(key string) error {
+	collections.endpointGroupMutex.Lock()
+	obj := collections.endpointGroups[key]
+	collections.endpointGroupMutex.Unlock()
+	if obj == nil {
+		log.Errorf("endpointGroup %s not found", key)
+		return errors.New("endpointGroup not found")
+	}
+
+	// Check if we handle this object
+	if objCallbackHandler.EndpointGroupCb == nil {
+		log.Errorf("No callback registered for endpointGroup object")
+		return errors.New("Invalid object type")
+	}
+
+	// Perform callback
+	err := objCallbackHandler.EndpointGroupCb.EndpointGroupDelete(obj)
+	if err != nil {
+		log.Errorf("EndpointGroupDelete retruned error for: %+v. Err: %v", obj, err)
+		return err
+	}
+
+	// delete it from modeldb
+	collections.endpointGroupMutex.Lock()
+	err = obj.Delete()
+	collections.endpointGroupMutex.Unlock()
+	if err != nil {
+		log.Errorf("Error deleting endpointGroup %s. Err: %v", obj.Key, err)
+	}
+
+	// delete it from cache
+	collections.endpointGroupMutex.Lock()
+	delete(collections.endpointGroups, key)
+	collections.endpointGroupMutex.Unlock()
+
+	return nil
+}
+
+func (self *EndpointGroup) GetType() string {
+	return "endpointGroup"
+}
+
+func (self *EndpointGroup) GetKey() string {
+	return self.Key
+}
+
+func (self *EndpointGroup) Read() error {
+	if self.Key == "" {
+		log.Errorf("Empty key while trying to read endpointGroup object")
+		return errors.New("Empty key")
+	}
+
+	return modeldb.ReadObj("endpointGroup", self.Key, self)
+}
+
+func (self *EndpointGroup) Write() error {
+	if self.Key == "" {
+		log.Errorf("Empty key while trying to Write endpointGroup object")
+		return errors.New("Empty key")
+	}
+
+	return modeldb.WriteObj("endpointGroup", self.Key, self)
+}
+
+func (self *EndpointGroup) Delete() error {
+	if self.Key == "" {
+		log.Errorf("Empty key while trying to Delete endpointGroup object")
+		return errors.New("Empty key")
+	}
+
+	return modeldb.DeleteObj("endpointGroup", self.Key)
+}
+
+func restoreEndpointGroup() error {
+	collections.endpointGroupMutex.Lock()
+	defer collections.endpointGroupMutex.Unlock()
+
+	strList, err := modeldb.ReadAllObj("endpointGroup")
+	if err != nil {
+		log.Errorf("Error reading endpointGroup list. Err: %v", err)
+	}
+
+	for _, objStr := range strList {
+		// Parse the json model
+		var endpointGroup EndpointGroup
+		err = json.Unmarshal([]byte(objStr), &endpointGroup)
+		if err != nil {
+			log.Errorf("Error parsing object %s, Err %v", objStr, err)
+			return err
+		}
+
+		// add it to the collection
+		collections.endpointGroups[endpointGroup.Key] = &endpointGroup
+	}
+
+	return nil
+}
+
+// Validate a endpointGroup object
+func ValidateEndpointGroup(obj *EndpointGroup) error {
+	collections.endpointGroupMutex.Lock()
+	defer collections.endpointGroupMutex.Unlock()
+
+	// Validate key is correct
+	keyStr := obj.TenantName + ":" + obj.GroupName
+	if obj.Key != keyStr {
+		log.Errorf("Expecting EndpointGroup Key: %s. Got: %s", keyStr, obj.Key)
+		return errors.New("Invalid Key")
+	}
+
+	// Validate each field
+
+	if len(obj.CfgdTag) > 128 {
+		return errors.New("cfgdTag string too long")
+	}
+
+	cfgdTagMatch := regexp.MustCompile("^((([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9]))?$")
+	if cfgdTagMatch.MatchString(obj.CfgdTag) == false {
+		return errors.New("cfgdTag string invalid format")
+	}
+
+	if len(obj.GroupName) > 64 {
+		return errors.New("groupName string too long")
+	}
+
+	groupNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
+	if groupNameMatch.MatchString(obj.GroupName) == false {
+		return errors.New("groupName string invalid format")
+	}
+
+	ipPoolMatch := regexp.MustCompile("^$|^((25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])(\\.(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])){3})(\\-((25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])(\\.(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])){3}))?$")
+	if ipPoolMatch.MatchString(obj.IpPool) == false {
+		return errors.New("ipPool string invalid format")
+	}
+
+	if len(obj.NetProfile) > 64 {
+		return errors.New("netProfile string too long")
+	}
+
+	if len(obj.NetworkName) > 64 {
+		return errors.New("networkName string too long")
+	}
+
+	networkNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
+	if networkNameMatch.MatchString(obj.NetworkName) == false {
+		return errors.New("networkName string invalid format")
+	}
+
+	if len(obj.TenantName) > 64 {
+		return errors.New("tenantName string too long")
+	}
+
+	tenantNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
+	if tenantNameMatch.MatchString(obj.TenantName) == false {
+		return errors.New("tenantName string invalid format")
 	}
 
-	// Perform callback
-	err := objCallbackHandler.EndpointGroupCb.EndpointGroupGetOper(obj)
-	if err != nil {
-		log.Errorf("EndpointGroupDelete retruned error for: %+v. Err: %v", obj, err)
-		return err
+	return nil
+}
+
+// GET Oper REST call
+func httpInspectExtContractsGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	var obj ExtContractsGroupInspect
+	log.Debugf("Received httpInspectExtContractsGroup: %+v", vars)
+
+	key := vars["key"]
+
+	collections.extContractsGroupMutex.Lock()
+	defer collections.extContractsGroupMutex.Unlock()
+	objConfig := collections.extContractsGroups[key]
+	if objConfig == nil {
+		log.Errorf("extContractsGroup %s not found", key)
+		return nil, errors.New("extContractsGroup not found")
 	}
+	obj.Config = *objConfig
 
-	return nil
+	// Return the obj
+	return &obj, nil
 }
 
 // LIST REST call
-func httpListEndpointGroups(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpListEndpointGroups: %+v", vars)
+func httpListExtContractsGroups(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpListExtContractsGroups: %+v", vars)
 
-	list := make([]*EndpointGroup, 0)
-	collections.endpointGroupMutex.Lock()
-	defer collections.endpointGroupMutex.Unlock()
-	for _, obj := range collections.endpointGroups {
+	list := make([]*ExtContractsGroup, 0)
+	collections.extContractsGroupMutex.Lock()
+	defer collections.extContractsGroupMutex.Unlock()
+	for _, obj := range collections.extContractsGroups {
 		list = append(list, obj)
 	}
 
@@ -2182,17 +2976,17 @@ func httpListEndpointGroups(w http.ResponseWriter, r *http.Request, vars map[str
 }
 
 // GET REST call
-func httpGetEndpointGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpGetEndpointGroup: %+v", vars)
+func httpGetExtContractsGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetExtContractsGroup: %+v", vars)
 
 	key := vars["key"]
 
-	collections.endpointGroupMutex.Lock()
-	defer collections.endpointGroupMutex.Unlock()
-	obj := collections.endpointGroups[key]
+	collections.extContractsGroupMutex.Lock()
+	defer collections.extContractsGroupMutex.Unlock()
+	obj := collections.extContractsGroups[key]
 	if obj == nil {
-		log.Infof("endpointGroup %s not found", key)
-		return nil, errors.New("endpointGroup not found")
+		log.Infof("extContractsGroup %s not found", key)
+		return nil, errors.New("extContractsGroup not found")
 	}
 
 	// Return the obj
@@ -2200,16 +2994,16 @@ func httpGetEndpointGroup(w http.ResponseWriter, r *http.Request, vars map[strin
 }
 
 // CREATE REST call
-func httpCreateEndpointGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpGetEndpointGroup: %+v", vars)
+func httpCreateExtContractsGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetExtContractsGroup: %+v", vars)
 
-	var obj EndpointGroup
+	var obj ExtContractsGroup
 	key := vars["key"]
 
 	// Get object from the request
 	err := json.NewDecoder(r.Body).Decode(&obj)
 	if err != nil {
-		log.Errorf("Error decoding endpointGroup create request. Err %v", err)
+		log.Errorf("Error decoding extContractsGroup create request. Err %v", err)
 		return nil, err
 	}
 
@@ -2217,9 +3011,9 @@ func httpCreateEndpointGroup(w http.ResponseWriter, r *http.Request, vars map[st
 	obj.Key = key
 
 	// Create the object
-	err = CreateEndpointGroup(&obj)
+	err = CreateExtContractsGroup(&obj)
 	if err != nil {
-		log.Errorf("CreateEndpointGroup error for: %+v. Err: %v", obj, err)
+		log.Errorf("CreateExtContractsGroup error for: %+v. Err: %v", obj, err)
 		return nil, err
 	}
 
@@ -2228,15 +3022,15 @@ func httpCreateEndpointGroup(w http.ResponseWriter, r *http.Request, vars map[st
 }
 
 // DELETE rest call
-func httpDeleteEndpointGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpDeleteEndpointGroup: %+v", vars)
+func httpDeleteExtContractsGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpDeleteExtContractsGroup: %+v", vars)
 
 	key := vars["key"]
 
 	// Delete the object
-	err := DeleteEndpointGroup(key)
+	err := DeleteExtContractsGroup(key)
 	if err != nil {
-		log.Errorf("DeleteEndpointGroup error for: %s. Err: %v", key, err)
+		log.Errorf("DeleteExtContractsGroup error for: %s. Err: %v", key, err)
 		return nil, err
 	}
 
@@ -2244,75 +3038,75 @@ func httpDeleteEndpointGroup(w http.ResponseWriter, r *http.Request, vars map[st
 	return key, nil
 }
 
-// Create a endpointGroup object
-func CreateEndpointGroup(obj *EndpointGroup) error {
+// Create a extContractsGroup object
+func CreateExtContractsGroup(obj *ExtContractsGroup) error {
 	// Validate parameters
-	err := ValidateEndpointGroup(obj)
+	err := ValidateExtContractsGroup(obj)
 	if err != nil {
-		log.Errorf("ValidateEndpointGroup retruned error for: %+v. Err: %v", obj, err)
+		log.Errorf("ValidateExtContractsGroup retruned error for: %+v. Err: %v", obj, err)
 		return err
 	}
 
 	// Check if we handle this object
-	if objCallbackHandler.EndpointGroupCb == nil {
-		log.Errorf("No callback registered for endpointGroup object")
+	if objCallbackHandler.ExtContractsGroupCb == nil {
+		log.Errorf("No callback registered for extContractsGroup object")
 		return errors.New("Invalid object type")
 	}
 
 	saveObj := obj
 
-	collections.endpointGroupMutex.Lock()
-	key := collections.endpointGroups[obj.Key]
-	collections.endpointGroupMutex.Unlock()
+	collections.extContractsGroupMutex.Lock()
+	key := collections.extContractsGroups[obj.Key]
+	collections.extContractsGroupMutex.Unlock()
 
 	// Check if object already exists
 	if key != nil {
 		// Perform Update callback
-		err = objCallbackHandler.EndpointGroupCb.EndpointGroupUpdate(collections.endpointGroups[obj.Key], obj)
+		err = objCallbackHandler.ExtContractsGroupCb.ExtContractsGroupUpdate(collections.extContractsGroups[obj.Key], obj)
 		if err != nil {
-			log.Errorf("EndpointGroupUpdate retruned error for: %+v. Err: %v", obj, err)
+			log.Errorf("ExtContractsGroupUpdate retruned error for: %+v. Err: %v", obj, err)
 			return err
 		}
 
 		// save the original object after update
-		collections.endpointGroupMutex.Lock()
-		saveObj = collections.endpointGroups[obj.Key]
-		collections.endpointGroupMutex.Unlock()
+		collections.extContractsGroupMutex.Lock()
+		saveObj = collections.extContractsGroups[obj.Key]
+		collections.extContractsGroupMutex.Unlock()
 	} else {
 		// save it in cache
-		collections.endpointGroupMutex.Lock()
-		collections.endpointGroups[obj.Key] = obj
-		collections.endpointGroupMutex.Unlock()
+		collections.extContractsGroupMutex.Lock()
+		collections.extContractsGroups[obj.Key] = obj
+		collections.extContractsGroupMutex.Unlock()
 
 		// Perform Create callback
-		err = objCallbackHandler.EndpointGroupCb.EndpointGroupCreate(obj)
+		err = objCallbackHandler.ExtContractsGroupCb.ExtContractsGroupCreate(obj)
 		if err != nil {
-			log.Errorf("EndpointGroupCreate retruned error for: %+v. Err: %v", obj, err)
-			collections.endpointGroupMutex.Lock()
-			delete(collections.endpointGroups, obj.Key)
-			collections.endpointGroupMutex.Unlock()
+			log.Errorf("ExtContractsGroupCreate retruned error for: %+v. Err: %v", obj, err)
+			collections.extContractsGroupMutex.Lock()
+			delete(collections.extContractsGroups, obj.Key)
+			collections.extContractsGroupMutex.Unlock()
 			return err
 		}
 	}
 
 	// Write it to modeldb
-	collections.endpointGroupMutex.Lock()
+	collections.extContractsGroupMutex.Lock()
 	err = saveObj.Write()
-	collections.endpointGroupMutex.Unlock()
+	collections.extContractsGroupMutex.Unlock()
 	if err != nil {
-		log.Errorf("Error saving endpointGroup %s to db. Err: %v", saveObj.Key, err)
+		log.Errorf("Error saving extContractsGroup %s to db. Err: %v", saveObj.Key, err)
 		return err
 	}
 
 	return nil
 }
 
-// Return a pointer to endpointGroup from collection
-func FindEndpointGroup(key string) *EndpointGroup {
-	collections.endpointGroupMutex.Lock()
-	defer collections.endpointGroupMutex.Unlock()
+// Return a pointer to extContractsGroup from collection
+func FindExtContractsGroup(key string) *ExtContractsGroup {
+	collections.extContractsGroupMutex.Lock()
+	defer collections.extContractsGroupMutex.Unlock()
 
-	obj := collections.endpointGroups[key]
+	obj := collections.extContractsGroups[key]
 	if obj == nil {
 		return nil
 	}
@@ -2320,153 +3114,126 @@ func FindEndpointGroup(key string) *EndpointGroup {
 	return obj
 }
 
-// Delete a endpointGroup object
-func DeleteEndpointGroup(key string) error {
-	collections.endpointGroupMutex.Lock()
-	obj := collections.endpointGroups[key]
-	collections.endpointGroupMutex.Unlock()
+// Delete a extContractsGroup object
+func DeleteExtContractsGroup(key string) error {
+	collections.extContractsGroupMutex.Lock()
+	obj := collections.extContractsGroups[key]
+	collections.extContractsGroupMutex.Unlock()
 	if obj == nil {
-		log.Errorf("endpointGroup %s not found", key)
-		return errors.New("endpointGroup not found")
+		log.Errorf("extContractsGroup %s not found", key)
+		return errors.New("extContractsGroup not found")
 	}
 
 	// Check if we handle this object
-	if objCallbackHandler.EndpointGroupCb == nil {
-		log.Errorf("No callback registered for endpointGroup object")
+	if objCallbackHandler.ExtContractsGroupCb == nil {
+		log.Errorf("No callback registered for extContractsGroup object")
 		return errors.New("Invalid object type")
 	}
 
 	// Perform callback
-	err := objCallbackHandler.EndpointGroupCb.EndpointGroupDelete(obj)
+	err := objCallbackHandler.ExtContractsGroupCb.ExtContractsGroupDelete(obj)
 	if err != nil {
-		log.Errorf("EndpointGroupDelete retruned error for: %+v. Err: %v", obj, err)
+		log.Errorf("ExtContractsGroupDelete retruned error for: %+v. Err: %v", obj, err)
 		return err
 	}
 
 	// delete it from modeldb
-	collections.endpointGroupMutex.Lock()
+	collections.extContractsGroupMutex.Lock()
 	err = obj.Delete()
-	collections.endpointGroupMutex.Unlock()
+	collections.extContractsGroupMutex.Unlock()
 	if err != nil {
-		log.Errorf("Error deleting endpointGroup %s. Err: %v", obj.Key, err)
+		log.Errorf("Error deleting extContractsGroup %s. Err: %v", obj.Key, err)
 	}
 
 	// delete it from cache
-	collections.endpointGroupMutex.Lock()
-	delete(collections.endpointGroups, key)
-	collections.endpointGroupMutex.Unlock()
+	collections.extContractsGroupMutex.Lock()
+	delete(collections.extContractsGroups, key)
+	collections.extContractsGroupMutex.Unlock()
 
 	return nil
 }
 
-func (self *EndpointGroup) GetType() string {
-	return "endpointGroup"
+func (self *ExtContractsGroup) GetType() string {
+	return "extContractsGroup"
 }
 
-func (self *EndpointGroup) GetKey() string {
+func (self *ExtContractsGroup) GetKey() string {
 	return self.Key
 }
 
-func (self *EndpointGroup) Read() error {
+func (self *ExtContractsGroup) Read() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to read endpointGroup object")
+		log.Errorf("Empty key while trying to read extContractsGroup object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.ReadObj("endpointGroup", self.Key, self)
+	return modeldb.ReadObj("extContractsGroup", self.Key, self)
 }
 
-func (self *EndpointGroup) Write() error {
+func (self *ExtContractsGroup) Write() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to Write endpointGroup object")
+		log.Errorf("Empty key while trying to Write extContractsGroup object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.WriteObj("endpointGroup", self.Key, self)
+	return modeldb.WriteObj("extContractsGroup", self.Key, self)
 }
 
-func (self *EndpointGroup) Delete() error {
+func (self *ExtContractsGroup) Delete() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to Delete endpointGroup object")
+		log.Errorf("Empty key while trying to Delete extContractsGroup object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.DeleteObj("endpointGroup", self.Key)
+	return modeldb.DeleteObj("extContractsGroup", self.Key)
 }
 
-func restoreEndpointGroup() error {
-	collections.endpointGroupMutex.Lock()
-	defer collections.endpointGroupMutex.Unlock()
+func restoreExtContractsGroup() error {
+	collections.extContractsGroupMutex.Lock()
+	defer collections.extContractsGroupMutex.Unlock()
 
-	strList, err := modeldb.ReadAllObj("endpointGroup")
+	strList, err := modeldb.ReadAllObj("extContractsGroup")
 	if err != nil {
-		log.Errorf("Error reading endpointGroup list. Err: %v", err)
+		log.Errorf("Error reading extContractsGroup list. Err: %v", err)
 	}
 
 	for _, objStr := range strList {
 		// Parse the json model
-		var endpointGroup EndpointGroup
-		err = json.Unmarshal([]byte(objStr), &endpointGroup)
+		var extContractsGroup ExtContractsGroup
+		err = json.Unmarshal([]byte(objStr), &extContractsGroup)
 		if err != nil {
 			log.Errorf("Error parsing object %s, Err %v", objStr, err)
 			return err
 		}
 
 		// add it to the collection
-		collections.endpointGroups[endpointGroup.Key] = &endpointGroup
+		collections.extContractsGroups[extContractsGroup.Key] = &extContractsGroup
 	}
 
 	return nil
 }
 
-// Validate a endpointGroup object
-func ValidateEndpointGroup(obj *EndpointGroup) error {
-	collections.endpointGroupMutex.Lock()
-	defer collections.endpointGroupMutex.Unlock()
+// Validate a extContractsGroup object
+func ValidateExtContractsGroup(obj *ExtContractsGroup) error {
+	collections.extContractsGroupMutex.Lock()
+	defer collections.extContractsGroupMutex.Unlock()
 
 	// Validate key is correct
-	keyStr := obj.TenantName + ":" + obj.GroupName
+	keyStr := obj.TenantName + ":" + obj.ContractsGroupName
 	if obj.Key != keyStr {
-		log.Errorf("Expecting EndpointGroup Key: %s. Got: %s", keyStr, obj.Key)
+		log.Errorf("Expecting ExtContractsGroup Key: %s. Got: %s", keyStr, obj.Key)
 		return errors.New("Invalid Key")
 	}
 
 	// Validate each field
 
-	if len(obj.CfgdTag) > 128 {
-		return errors.New("cfgdTag string too long")
-	}
-
-	cfgdTagMatch := regexp.MustCompile("^((([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9]))?$")
-	if cfgdTagMatch.MatchString(obj.CfgdTag) == false {
-		return errors.New("cfgdTag string invalid format")
-	}
-
-	if len(obj.GroupName) > 64 {
-		return errors.New("groupName string too long")
-	}
-
-	groupNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
-	if groupNameMatch.MatchString(obj.GroupName) == false {
-		return errors.New("groupName string invalid format")
-	}
-
-	ipPoolMatch := regexp.MustCompile("^$|^((25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])(\\.(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])){3})(\\-((25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])(\\.(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])){3}))?$")
-	if ipPoolMatch.MatchString(obj.IpPool) == false {
-		return errors.New("ipPool string invalid format")
-	}
-
-	if len(obj.NetProfile) > 64 {
-		return errors.New("netProfile string too long")
-	}
-
-	if len(obj.NetworkName) > 64 {
-		return errors.New("networkName string too long")
+	if len(obj.ContractsGroupName) > 64 {
+		return errors.New("contractsGroupName string too long")
 	}
 
-	networkNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
-	if networkNameMatch.MatchString(obj.NetworkName) == false {
-		return errors.New("networkName string invalid format")
+	contractsGroupNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
+	if contractsGroupNameMatch.MatchString(obj.ContractsGroupName) == false {
+		return errors.New("contractsGroupName string invalid format")
 	}
 
 	if len(obj.TenantName) > 64 {
@@ -2482,33 +3249,56 @@ func ValidateEndpointGroup(obj *EndpointGroup) error {
 }
 
 // GET Oper REST call
-func httpInspectExtContractsGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	var obj ExtContractsGroupInspect
-	log.Debugf("Received httpInspectExtContractsGroup: %+v", vars)
+func httpInspectGlobal(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	var obj GlobalInspect
+	log.Debugf("Received httpInspectGlobal: %+v", vars)
 
 	key := vars["key"]
 
-	collections.extContractsGroupMutex.Lock()
-	defer collections.extContractsGroupMutex.Unlock()
-	objConfig := collections.extContractsGroups[key]
+	collections.globalMutex.Lock()
+	defer collections.globalMutex.Unlock()
+	objConfig := collections.globals[key]
 	if objConfig == nil {
-		log.Errorf("extContractsGroup %s not found", key)
-		return nil, errors.New("extContractsGroup not found")
+		log.Errorf("global %s not found", key)
+		return nil, errors.New("global not found")
 	}
 	obj.Config = *objConfig
 
+	if err := GetOperGlobal(&obj); err != nil {
+		log.Errorf("GetGlobal error for: %+v. Err: %v", obj, err)
+		return nil, err
+	}
+
 	// Return the obj
 	return &obj, nil
 }
 
+// Get a globalOper object
+func GetOperGlobal(obj *GlobalInspect) error {
+	// Check if we handle this object
+	if objCallbackHandler.GlobalCb == nil {
+		log.Errorf("No callback registered for global object")
+		return errors.New("Invalid object type")
+	}
+
+	// Perform callback
+	err := objCallbackHandler.GlobalCb.GlobalGetOper(obj)
+	if err != nil {
+		log.Errorf("GlobalDelete retruned error for: %+v. Err: %v", obj, err)
+		return err
+	}
+
+	return nil
+}
+
 // LIST REST call
-func httpListExtContractsGroups(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpListExtContractsGroups: %+v", vars)
+func httpListGlobals(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpListGlobals: %+v", vars)
 
-	list := make([]*ExtContractsGroup, 0)
-	collections.extContractsGroupMutex.Lock()
-	defer collections.extContractsGroupMutex.Unlock()
-	for _, obj := range collections.extContractsGroups {
+	list := make([]*Global, 0)
+	collections.globalMutex.Lock()
+	defer collections.globalMutex.Unlock()
+	for _, obj := range collections.globals {
 		list = append(list, obj)
 	}
 
@@ -2517,17 +3307,17 @@ func httpListExtContractsGroups(w http.ResponseWriter, r *http.Request, vars map
 }
 
 // GET REST call
-func httpGetExtContractsGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpGetExtContractsGroup: %+v", vars)
+func httpGetGlobal(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetGlobal: %+v", vars)
 
 	key := vars["key"]
 
-	collections.extContractsGroupMutex.Lock()
-	defer collections.extContractsGroupMutex.Unlock()
-	obj := collections.extContractsGroups[key]
+	collections.globalMutex.Lock()
+	defer collections.globalMutex.Unlock()
+	obj := collections.globals[key]
 	if obj == nil {
-		log.Infof("extContractsGroup %s not found", key)
-		return nil, errors.New("extContractsGroup not found")
+		log.Infof("global %s not found", key)
+		return nil, errors.New("global not found")
 	}
 
 	// Return the obj
@@ -2535,16 +3325,16 @@ func httpGetExtContractsGroup(w http.ResponseWriter, r *http.Request, vars map[s
 }
 
 // CREATE REST call
-func httpCreateExtContractsGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpGetExtContractsGroup: %+v", vars)
+func httpCreateGlobal(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetGlobal: %+v", vars)
 
-	var obj ExtContractsGroup
+	var obj Global
 	key := vars["key"]
 
 	// Get object from the request
 	err := json.NewDecoder(r.Body).Decode(&obj)
 	if err != nil {
-		log.Errorf("Error decoding extContractsGroup create request. Err %v", err)
+		log.Errorf("Error decoding global create request. Err %v", err)
 		return nil, err
 	}
 
@@ -2552,9 +3342,9 @@ func httpCreateExtContractsGroup(w http.ResponseWriter, r *http.Request, vars ma
 	obj.Key = key
 
 	// Create the object
-	err = CreateExtContractsGroup(&obj)
+	err = CreateGlobal(&obj)
 	if err != nil {
-		log.Errorf("CreateExtContractsGroup error for: %+v. Err: %v", obj, err)
+		log.Errorf("CreateGlobal error for: %+v. Err: %v", obj, err)
 		return nil, err
 	}
 
@@ -2563,15 +3353,15 @@ func httpCreateExtContractsGroup(w http.ResponseWriter, r *http.Request, vars ma
 }
 
 // DELETE rest call
-func httpDeleteExtContractsGroup(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpDeleteExtContractsGroup: %+v", vars)
+func httpDeleteGlobal(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpDeleteGlobal: %+v", vars)
 
 	key := vars["key"]
 
 	// Delete the object
-	err := DeleteExtContractsGroup(key)
+	err := DeleteGlobal(key)
 	if err != nil {
-		log.Errorf("DeleteExtContractsGroup error for: %s. Err: %v", key, err)
+		log.Errorf("DeleteGlobal error for: %s. Err: %v", key, err)
 		return nil, err
 	}
 
@@ -2579,75 +3369,75 @@ func httpDeleteExtContractsGroup(w http.ResponseWriter, r *http.Request, vars ma
 	return key, nil
 }
 
-// Create a extContractsGroup object
-func CreateExtContractsGroup(obj *ExtContractsGroup) error {
+// Create a global object
+func CreateGlobal(obj *Global) error {
 	// Validate parameters
-	err := ValidateExtContractsGroup(obj)
+	err := ValidateGlobal(obj)
 	if err != nil {
-		log.Errorf("ValidateExtContractsGroup retruned error for: %+v. Err: %v", obj, err)
+		log.Errorf("ValidateGlobal retruned error for: %+v. Err: %v", obj, err)
 		return err
 	}
 
 	// Check if we handle this object
-	if objCallbackHandler.ExtContractsGroupCb == nil {
-		log.Errorf("No callback registered for extContractsGroup object")
+	if objCallbackHandler.GlobalCb == nil {
+		log.Errorf("No callback registered for global object")
 		return errors.New("Invalid object type")
 	}
 
 	saveObj := obj
 
-	collections.extContractsGroupMutex.Lock()
-	key := collections.extContractsGroups[obj.Key]
-	collections.extContractsGroupMutex.Unlock()
+	collections.globalMutex.Lock()
+	key := collections.globals[obj.Key]
+	collections.globalMutex.Unlock()
 
 	// Check if object already exists
 	if key != nil {
 		// Perform Update callback
-		err = objCallbackHandler.ExtContractsGroupCb.ExtContractsGroupUpdate(collections.extContractsGroups[obj.Key], obj)
+		err = objCallbackHandler.GlobalCb.GlobalUpdate(collections.globals[obj.Key], obj)
 		if err != nil {
-			log.Errorf("ExtContractsGroupUpdate retruned error for: %+v. Err: %v", obj, err)
+			log.Errorf("GlobalUpdate retruned error for: %+v. Err: %v", obj, err)
 			return err
 		}
 
 		// save the original object after update
-		collections.extContractsGroupMutex.Lock()
-		saveObj = collections.extContractsGroups[obj.Key]
-		collections.extContractsGroupMutex.Unlock()
+		collections.globalMutex.Lock()
+		saveObj = collections.globals[obj.Key]
+		collections.globalMutex.Unlock()
 	} else {
 		// save it in cache
-		collections.extContractsGroupMutex.Lock()
-		collections.extContractsGroups[obj.Key] = obj
-		collections.extContractsGroupMutex.Unlock()
+		collections.globalMutex.Lock()
+		collections.globals[obj.Key] = obj
+		collections.globalMutex.Unlock()
 
 		// Perform Create callback
-		err = objCallbackHandler.ExtContractsGroupCb.ExtContractsGroupCreate(obj)
+		err = objCallbackHandler.GlobalCb.GlobalCreate(obj)
 		if err != nil {
-			log.Errorf("ExtContractsGroupCreate retruned error for: %+v. Err: %v", obj, err)
-			collections.extContractsGroupMutex.Lock()
-			delete(collections.extContractsGroups, obj.Key)
-			collections.extContractsGroupMutex.Unlock()
+			log.Errorf("GlobalCreate retruned error for: %+v. Err: %v", obj, err)
+			collections.globalMutex.Lock()
+			delete(collections.globals, obj.Key)
+			collections.globalMutex.Unlock()
 			return err
 		}
 	}
 
 	// Write it to modeldb
-	collections.extContractsGroupMutex.Lock()
+	collections.globalMutex.Lock()
 	err = saveObj.Write()
-	collections.extContractsGroupMutex.Unlock()
+	collections.globalMutex.Unlock()
 	if err != nil {
-		log.Errorf("Error saving extContractsGroup %s to db. Err: %v", saveObj.Key, err)
+		log.Errorf("Error saving global %s to db. Err: %v", saveObj.Key, err)
 		return err
 	}
 
 	return nil
 }
 
-// Return a pointer to extContractsGroup from collection
-func FindExtContractsGroup(key string) *ExtContractsGroup {
-	collections.extContractsGroupMutex.Lock()
-	defer collections.extContractsGroupMutex.Unlock()
+// Return a pointer to global from collection
+func FindGlobal(key string) *Global {
+	collections.globalMutex.Lock()
+	defer collections.globalMutex.Unlock()
 
-	obj := collections.extContractsGroups[key]
+	obj := collections.globals[key]
 	if obj == nil {
 		return nil
 	}
@@ -2655,191 +3445,219 @@ func FindExtContractsGroup(key string) *ExtContractsGroup {
 	return obj
 }
 
-// Delete a extContractsGroup object
-func DeleteExtContractsGroup(key string) error {
-	collections.extContractsGroupMutex.Lock()
-	obj := collections.extContractsGroups[key]
-	collections.extContractsGroupMutex.Unlock()
+// Delete a global object
+func DeleteGlobal(key string) error {
+	collections.globalMutex.Lock()
+	obj := collections.globals[key]
+	collections.globalMutex.Unlock()
 	if obj == nil {
-		log.Errorf("extContractsGroup %s not found", key)
-		return errors.New("extContractsGroup not found")
+		log.Errorf("global %s not found", key)
+		return errors.New("global not found")
 	}
 
 	// Check if we handle this object
-	if objCallbackHandler.ExtContractsGroupCb == nil {
-		log.Errorf("No callback registered for extContractsGroup object")
+	if objCallbackHandler.GlobalCb == nil {
+		log.Errorf("No callback registered for global object")
 		return errors.New("Invalid object type")
 	}
 
 	// Perform callback
-	err := objCallbackHandler.ExtContractsGroupCb.ExtContractsGroupDelete(obj)
+	err := objCallbackHandler.GlobalCb.GlobalDelete(obj)
 	if err != nil {
-		log.Errorf("ExtContractsGroupDelete retruned error for: %+v. Err: %v", obj, err)
+		log.Errorf("GlobalDelete retruned error for: %+v. Err: %v", obj, err)
 		return err
 	}
 
 	// delete it from modeldb
-	collections.extContractsGroupMutex.Lock()
+	collections.globalMutex.Lock()
 	err = obj.Delete()
-	collections.extContractsGroupMutex.Unlock()
+	collections.globalMutex.Unlock()
 	if err != nil {
-		log.Errorf("Error deleting extContractsGroup %s. Err: %v", obj.Key, err)
+		log.Errorf("Error deleting global %s. Err: %v", obj.Key, err)
 	}
 
 	// delete it from cache
-	collections.extContractsGroupMutex.Lock()
-	delete(collections.extContractsGroups, key)
-	collections.extContractsGroupMutex.Unlock()
+	collections.globalMutex.Lock()
+	delete(collections.globals, key)
+	collections.globalMutex.Unlock()
 
 	return nil
 }
 
-func (self *ExtContractsGroup) GetType() string {
-	return "extContractsGroup"
+func (self *Global) GetType() string {
+	return "global"
 }
 
-func (self *ExtContractsGroup) GetKey() string {
+func (self *Global) GetKey() string {
 	return self.Key
 }
 
-func (self *ExtContractsGroup) Read() error {
+func (self *Global) Read() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to read extContractsGroup object")
+		log.Errorf("Empty key while trying to read global object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.ReadObj("extContractsGroup", self.Key, self)
+	return modeldb.ReadObj("global", self.Key, self)
 }
 
-func (self *ExtContractsGroup) Write() error {
+func (self *Global) Write() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to Write extContractsGroup object")
+		log.Errorf("Empty key while trying to Write global object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.WriteObj("extContractsGroup", self.Key, self)
+	return modeldb.WriteObj("global", self.Key, self)
 }
 
-func (self *ExtContractsGroup) Delete() error {
+func (self *Global) Delete() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to Delete extContractsGroup object")
+		log.Errorf("Empty key while trying to Delete global object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.DeleteObj("extContractsGroup", self.Key)
+	return modeldb.DeleteObj("global", self.Key)
 }
 
-func restoreExtContractsGroup() error {
-	collections.extContractsGroupMutex.Lock()
-	defer collections.extContractsGroupMutex.Unlock()
+func restoreGlobal() error {
+	collections.globalMutex.Lock()
+	defer collections.globalMutex.Unlock()
 
-	strList, err := modeldb.ReadAllObj("extContractsGroup")
+	strList, err := modeldb.ReadAllObj("global")
 	if err != nil {
-		log.Errorf("Error reading extContractsGroup list. Err: %v", err)
+		log.Errorf("Error reading global list. Err: %v", err)
 	}
 
 	for _, objStr := range strList {
 		// Parse the json model
-		var extContractsGroup ExtContractsGroup
-		err = json.Unmarshal([]byte(objStr), &extContractsGroup)
+		var global Global
+		err = json.Unmarshal([]byte(objStr), &global)
 		if err != nil {
 			log.Errorf("Error parsing object %s, Err %v", objStr, err)
 			return err
 		}
 
 		// add it to the collection
-		collections.extContractsGroups[extContractsGroup.Key] = &extContractsGroup
+		collections.globals[global.Key] = &global
 	}
 
 	return nil
 }
 
-// Validate a extContractsGroup object
-func ValidateExtContractsGroup(obj *ExtContractsGroup) error {
-	collections.extContractsGroupMutex.Lock()
-	defer collections.extContractsGroupMutex.Unlock()
+// Validate a global object
+func ValidateGlobal(obj *Global) error {
+	collections.globalMutex.Lock()
+	defer collections.globalMutex.Unlock()
 
 	// Validate key is correct
-	keyStr := obj.TenantName + ":" + obj.ContractsGroupName
+	keyStr := obj.Name
 	if obj.Key != keyStr {
-		log.Errorf("Expecting ExtContractsGroup Key: %s. Got: %s", keyStr, obj.Key)
+		log.Errorf("Expecting Global Key: %s. Got: %s", keyStr, obj.Key)
 		return errors.New("Invalid Key")
 	}
 
-	// Validate each field
+	// Validate each field
+
+	if len(obj.ArpMode) > 64 {
+		return errors.New("arpMode string too long")
+	}
+
+	arpModeMatch := regexp.MustCompile("^(proxy|flood)?$")
+	if arpModeMatch.MatchString(obj.ArpMode) == false {
+		return errors.New("arpMode string invalid format")
+	}
+
+	if len(obj.BaselinePolicy) > 64 {
+		return errors.New("baselinePolicy string too long")
+	}
+
+	baselinePolicyMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])?$")
+	if baselinePolicyMatch.MatchString(obj.BaselinePolicy) == false {
+		return errors.New("baselinePolicy string invalid format")
+	}
+
+	if len(obj.Encryption) > 64 {
+		return errors.New("encryption string too long")
+	}
+
+	encryptionMatch := regexp.MustCompile("^(none|ipsec)?$")
+	if encryptionMatch.MatchString(obj.Encryption) == false {
+		return errors.New("encryption string invalid format")
+	}
+
+	if len(obj.FwdMode) > 64 {
+		return errors.New("fwdMode string too long")
+	}
+
+	fwdModeMatch := regexp.MustCompile("^(bridge|routing)?$")
+	if fwdModeMatch.MatchString(obj.FwdMode) == false {
+		return errors.New("fwdMode string invalid format")
+	}
+
+	if len(obj.Name) > 64 {
+		return errors.New("name string too long")
+	}
+
+	nameMatch := regexp.MustCompile("^(global)$")
+	if nameMatch.MatchString(obj.Name) == false {
+		return errors.New("name string invalid format")
+	}
+
+	if len(obj.NetworkInfraType) > 64 {
+		return errors.New("networkInfraType string too long")
+	}
 
-	if len(obj.ContractsGroupName) > 64 {
-		return errors.New("contractsGroupName string too long")
+	networkInfraTypeMatch := regexp.MustCompile("^(aci|aci-opflex|default)?$")
+	if networkInfraTypeMatch.MatchString(obj.NetworkInfraType) == false {
+		return errors.New("networkInfraType string invalid format")
 	}
 
-	contractsGroupNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
-	if contractsGroupNameMatch.MatchString(obj.ContractsGroupName) == false {
-		return errors.New("contractsGroupName string invalid format")
+	pvtSubnetMatch := regexp.MustCompile("^((25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])(\\.(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])){3})/16$")
+	if pvtSubnetMatch.MatchString(obj.PvtSubnet) == false {
+		return errors.New("pvtSubnet string invalid format")
 	}
 
-	if len(obj.TenantName) > 64 {
-		return errors.New("tenantName string too long")
+	vlansMatch := regexp.MustCompile("^([0-9]{1,4}?-[0-9]{1,4}?)$")
+	if vlansMatch.MatchString(obj.Vlans) == false {
+		return errors.New("vlans string invalid format")
 	}
 
-	tenantNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
-	if tenantNameMatch.MatchString(obj.TenantName) == false {
-		return errors.New("tenantName string invalid format")
+	vxlansMatch := regexp.MustCompile("^([0-9]{1,8}?-[0-9]{1,8}?)$")
+	if vxlansMatch.MatchString(obj.Vxlans) == false {
+		return errors.New("vxlans string invalid format")
 	}
 
 	return nil
 }
 
 // GET Oper REST call
-func httpInspectGlobal(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	var obj GlobalInspect
-	log.Debugf("Received httpInspectGlobal: %+v", vars)
+func httpInspectMirrorSession(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	var obj MirrorSessionInspect
+	log.Debugf("Received httpInspectMirrorSession: %+v", vars)
 
 	key := vars["key"]
 
-	collections.globalMutex.Lock()
-	defer collections.globalMutex.Unlock()
-	objConfig := collections.globals[key]
+	collections.mirrorSessionMutex.Lock()
+	defer collections.mirrorSessionMutex.Unlock()
+	objConfig := collections.mirrorSessions[key]
 	if objConfig == nil {
-		log.Errorf("global %s not found", key)
-		return nil, errors.New("global not found")
+		log.Errorf("mirrorSession %s not found", key)
+		return nil, errors.New("mirrorSession not found")
 	}
 	obj.Config = *objConfig
 
-	if err := GetOperGlobal(&obj); err != nil {
-		log.Errorf("GetGlobal error for: %+v. Err: %v", obj, err)
-		return nil, err
-	}
-
 	// Return the obj
 	return &obj, nil
 }
 
-// Get a globalOper object
-func GetOperGlobal(obj *GlobalInspect) error {
-	// Check if we handle this object
-	if objCallbackHandler.GlobalCb == nil {
-		log.Errorf("No callback registered for global object")
-		return errors.New("Invalid object type")
-	}
-
-	// Perform callback
-	err := objCallbackHandler.GlobalCb.GlobalGetOper(obj)
-	if err != nil {
-		log.Errorf("GlobalDelete retruned error for: %+v. Err: %v", obj, err)
-		return err
-	}
-
-	return nil
-}
-
 // LIST REST call
-func httpListGlobals(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpListGlobals: %+v", vars)
+func httpListMirrorSessions(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpListMirrorSessions: %+v", vars)
 
-	list := make([]*Global, 0)
-	collections.globalMutex.Lock()
-	defer collections.globalMutex.Unlock()
-	for _, obj := range collections.globals {
+	list := make([]*MirrorSession, 0)
+	collections.mirrorSessionMutex.Lock()
+	defer collections.mirrorSessionMutex.Unlock()
+	for _, obj := range collections.mirrorSessions {
 		list = append(list, obj)
 	}
 
@@ -2848,17 +3666,17 @@ func httpListGlobals(w http.ResponseWriter, r *http.Request, vars map[string]str
 }
 
 // GET REST call
-func httpGetGlobal(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpGetGlobal: %+v", vars)
+func httpGetMirrorSession(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetMirrorSession: %+v", vars)
 
 	key := vars["key"]
 
-	collections.globalMutex.Lock()
-	defer collections.globalMutex.Unlock()
-	obj := collections.globals[key]
+	collections.mirrorSessionMutex.Lock()
+	defer collections.mirrorSessionMutex.Unlock()
+	obj := collections.mirrorSessions[key]
 	if obj == nil {
-		log.Infof("global %s not found", key)
-		return nil, errors.New("global not found")
+		log.Infof("mirrorSession %s not found", key)
+		return nil, errors.New("mirrorSession not found")
 	}
 
 	// Return the obj
@@ -2866,16 +3684,16 @@ func httpGetGlobal(w http.ResponseWriter, r *http.Request, vars map[string]strin
 }
 
 // CREATE REST call
-func httpCreateGlobal(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpGetGlobal: %+v", vars)
+func httpCreateMirrorSession(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpGetMirrorSession: %+v", vars)
 
-	var obj Global
+	var obj MirrorSession
 	key := vars["key"]
 
 	// Get object from the request
 	err := json.NewDecoder(r.Body).Decode(&obj)
 	if err != nil {
-		log.Errorf("Error decoding global create request. Err %v", err)
+		log.Errorf("Error decoding mirrorSession create request. Err %v", err)
 		return nil, err
 	}
 
@@ -2883,9 +3701,9 @@ func httpCreateGlobal(w http.ResponseWriter, r *http.Request, vars map[string]st
 	obj.Key = key
 
 	// Create the object
-	err = CreateGlobal(&obj)
+	err = CreateMirrorSession(&obj)
 	if err != nil {
-		log.Errorf("CreateGlobal error for: %+v. Err: %v", obj, err)
+		log.Errorf("CreateMirrorSession error for: %+v. Err: %v", obj, err)
 		return nil, err
 	}
 
@@ -2894,15 +3712,15 @@ func httpCreateGlobal(w http.ResponseWriter, r *http.Request, vars map[string]st
 }
 
 // DELETE rest call
-func httpDeleteGlobal(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
-	log.Debugf("Received httpDeleteGlobal: %+v", vars)
+func httpDeleteMirrorSession(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	log.Debugf("Received httpDeleteMirrorSession: %+v", vars)
 
 	key := vars["key"]
 
 	// Delete the object
-	err := DeleteGlobal(key)
+	err := DeleteMirrorSession(key)
 	if err != nil {
-		log.Errorf("DeleteGlobal error for: %s. Err: %v", key, err)
+		log.Errorf("DeleteMirrorSession error for: %s. Err: %v", key, err)
 		return nil, err
 	}
 
@@ -2910,75 +3728,75 @@ func httpDeleteGlobal(w http.ResponseWriter, r *http.Request, vars map[string]st
 	return key, nil
 }
 
-// Create a global object
-func CreateGlobal(obj *Global) error {
+// Create a mirrorSession object
+func CreateMirrorSession(obj *MirrorSession) error {
 	// Validate parameters
-	err := ValidateGlobal(obj)
+	err := ValidateMirrorSession(obj)
 	if err != nil {
-		log.Errorf("ValidateGlobal retruned error for: %+v. Err: %v", obj, err)
+		log.Errorf("ValidateMirrorSession retruned error for: %+v. Err: %v", obj, err)
 		return err
 	}
 
 	// Check if we handle this object
-	if objCallbackHandler.GlobalCb == nil {
-		log.Errorf("No callback registered for global object")
+	if objCallbackHandler.MirrorSessionCb == nil {
+		log.Errorf("No callback registered for mirrorSession object")
 		return errors.New("Invalid object type")
 	}
 
 	saveObj := obj
 
-	collections.globalMutex.Lock()
-	key := collections.globals[obj.Key]
-	collections.globalMutex.Unlock()
+	collections.mirrorSessionMutex.Lock()
+	key := collections.mirrorSessions[obj.Key]
+	collections.mirrorSessionMutex.Unlock()
 
 	// Check if object already exists
 	if key != nil {
 		// Perform Update callback
-		err = objCallbackHandler.GlobalCb.GlobalUpdate(collections.globals[obj.Key], obj)
+		err = objCallbackHandler.MirrorSessionCb.MirrorSessionUpdate(collections.mirrorSessions[obj.Key], obj)
 		if err != nil {
-			log.Errorf("GlobalUpdate retruned error for: %+v. Err: %v", obj, err)
+			log.Errorf("MirrorSessionUpdate retruned error for: %+v. Err: %v", obj, err)
 			return err
 		}
 
 		// save the original object after update
-		collections.globalMutex.Lock()
-		saveObj = collections.globals[obj.Key]
-		collections.globalMutex.Unlock()
+		collections.mirrorSessionMutex.Lock()
+		saveObj = collections.mirrorSessions[obj.Key]
+		collections.mirrorSessionMutex.Unlock()
 	} else {
 		// save it in cache
-		collections.globalMutex.Lock()
-		collections.globals[obj.Key] = obj
-		collections.globalMutex.Unlock()
+		collections.mirrorSessionMutex.Lock()
+		collections.mirrorSessions[obj.Key] = obj
+		collections.mirrorSessionMutex.Unlock()
 
 		// Perform Create callback
-		err = objCallbackHandler.GlobalCb.GlobalCreate(obj)
+		err = objCallbackHandler.MirrorSessionCb.MirrorSessionCreate(obj)
 		if err != nil {
-			log.Errorf("GlobalCreate retruned error for: %+v. Err: %v", obj, err)
-			collections.globalMutex.Lock()
-			delete(collections.globals, obj.Key)
-			collections.globalMutex.Unlock()
+			log.Errorf("MirrorSessionCreate retruned error for: %+v. Err: %v", obj, err)
+			collections.mirrorSessionMutex.Lock()
+			delete(collections.mirrorSessions, obj.Key)
+			collections.mirrorSessionMutex.Unlock()
 			return err
 		}
 	}
 
 	// Write it to modeldb
-	collections.globalMutex.Lock()
+	collections.mirrorSessionMutex.Lock()
 	err = saveObj.Write()
-	collections.globalMutex.Unlock()
+	collections.mirrorSessionMutex.Unlock()
 	if err != nil {
-		log.Errorf("Error saving global %s to db. Err: %v", saveObj.Key, err)
+		log.Errorf("Error saving mirrorSession %s to db. Err: %v", saveObj.Key, err)
 		return err
 	}
 
 	return nil
 }
 
-// Return a pointer to global from collection
-func FindGlobal(key string) *Global {
-	collections.globalMutex.Lock()
-	defer collections.globalMutex.Unlock()
+// Return a pointer to mirrorSession from collection
+func FindMirrorSession(key string) *MirrorSession {
+	collections.mirrorSessionMutex.Lock()
+	defer collections.mirrorSessionMutex.Unlock()
 
-	obj := collections.globals[key]
+	obj := collections.mirrorSessions[key]
 	if obj == nil {
 		return nil
 	}
@@ -2986,168 +3804,189 @@ func FindGlobal(key string) *Global {
 	return obj
 }
 
-// Delete a global object
-func DeleteGlobal(key string) error {
-	collections.globalMutex.Lock()
-	obj := collections.globals[key]
-	collections.globalMutex.Unlock()
+// Delete a mirrorSession object
+func DeleteMirrorSession(key string) error {
+	collections.mirrorSessionMutex.Lock()
+	obj := collections.mirrorSessions[key]
+	collections.mirrorSessionMutex.Unlock()
 	if obj == nil {
-		log.Errorf("global %s not found", key)
-		return errors.New("global not found")
+		log.Errorf("mirrorSession %s not found", key)
+		return errors.New("mirrorSession not found")
 	}
 
 	// Check if we handle this object
-	if objCallbackHandler.GlobalCb == nil {
-		log.Errorf("No callback registered for global object")
+	if objCallbackHandler.MirrorSessionCb == nil {
+		log.Errorf("No callback registered for mirrorSession object")
 		return errors.New("Invalid object type")
 	}
 
 	// Perform callback
-	err := objCallbackHandler.GlobalCb.GlobalDelete(obj)
+	err := objCallbackHandler.MirrorSessionCb.MirrorSessionDelete(obj)
 	if err != nil {
-		log.Errorf("GlobalDelete retruned error for: %+v. Err: %v", obj, err)
+		log.Errorf("MirrorSessionDelete retruned error for: %+v. Err: %v", obj, err)
 		return err
 	}
 
 	// delete it from modeldb
-	collections.globalMutex.Lock()
+	collections.mirrorSessionMutex.Lock()
 	err = obj.Delete()
-	collections.globalMutex.Unlock()
+	collections.mirrorSessionMutex.Unlock()
 	if err != nil {
-		log.Errorf("Error deleting global %s. Err: %v", obj.Key, err)
+		log.Errorf("Error deleting mirrorSession %s. Err: %v", obj.Key, err)
 	}
 
 	// delete it from cache
-	collections.globalMutex.Lock()
-	delete(collections.globals, key)
-	collections.globalMutex.Unlock()
+	collections.mirrorSessionMutex.Lock()
+	delete(collections.mirrorSessions, key)
+	collections.mirrorSessionMutex.Unlock()
 
 	return nil
 }
 
-func (self *Global) GetType() string {
-	return "global"
+func (self *MirrorSession) GetType() string {
+	return "mirrorSession"
 }
 
-func (self *Global) GetKey() string {
+func (self *MirrorSession) GetKey() string {
 	return self.Key
 }
 
-func (self *Global) Read() error {
+func (self *MirrorSession) Read() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to read global object")
+		log.Errorf("Empty key while trying to read mirrorSession object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.ReadObj("global", self.Key, self)
+	return modeldb.ReadObj("mirrorSession", self.Key, self)
 }
 
-func (self *Global) Write() error {
+func (self *MirrorSession) Write() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to Write global object")
+		log.Errorf("Empty key while trying to Write mirrorSession object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.WriteObj("global", self.Key, self)
+	return modeldb.WriteObj("mirrorSession", self.Key, self)
 }
 
-func (self *Global) Delete() error {
+func (self *MirrorSession) Delete() error {
 	if self.Key == "" {
-		log.Errorf("Empty key while trying to Delete global object")
+		log.Errorf("Empty key while trying to Delete mirrorSession object")
 		return errors.New("Empty key")
 	}
 
-	return modeldb.DeleteObj("global", self.Key)
+	return modeldb.DeleteObj("mirrorSession", self.Key)
 }
 
-func restoreGlobal() error {
-	collections.globalMutex.Lock()
-	defer collections.globalMutex.Unlock()
+func restoreMirrorSession() error {
+	collections.mirrorSessionMutex.Lock()
+	defer collections.mirrorSessionMutex.Unlock()
 
-	strList, err := modeldb.ReadAllObj("global")
+	strList, err := modeldb.ReadAllObj("mirrorSession")
 	if err != nil {
-		log.Errorf("Error reading global list. Err: %v", err)
+		log.Errorf("Error reading mirrorSession list. Err: %v", err)
 	}
 
 	for _, objStr := range strList {
 		// Parse the json model
-		var global Global
-		err = json.Unmarshal([]byte(objStr), &global)
+		var mirrorSession MirrorSession
+		err = json.Unmarshal([]byte(objStr), &mirrorSession)
 		if err != nil {
 			log.Errorf("Error parsing object %s, Err %v", objStr, err)
 			return err
 		}
 
 		// add it to the collection
-		collections.globals[global.Key] = &global
+		collections.mirrorSessions[mirrorSession.Key] = &mirrorSession
 	}
 
 	return nil
 }
 
-// Validate a global object
-func ValidateGlobal(obj *Global) error {
-	collections.globalMutex.Lock()
-	defer collections.globalMutex.Unlock()
+// Validate a mirrorSession object
+func ValidateMirrorSession(obj *MirrorSession) error {
+	collections.mirrorSessionMutex.Lock()
+	defer collections.mirrorSessionMutex.Unlock()
 
 	// Validate key is correct
-	keyStr := obj.Name
+	keyStr := obj.TenantName + ":" + obj.SessionName
 	if obj.Key != keyStr {
-		log.Errorf("Expecting Global Key: %s. Got: %s", keyStr, obj.Key)
+		log.Errorf("Expecting MirrorSession Key: %s. Got: %s", keyStr, obj.Key)
 		return errors.New("Invalid Key")
 	}
 
 	// Validate each field
 
-	if len(obj.ArpMode) > 64 {
-		return errors.New("arpMode string too long")
+	collectorIpMatch := regexp.MustCompile("^((25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])(\\.(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])){3})?$")
+	if collectorIpMatch.MatchString(obj.CollectorIp) == false {
+		return errors.New("collectorIp string invalid format")
 	}
 
-	arpModeMatch := regexp.MustCompile("^(proxy|flood)?$")
-	if arpModeMatch.MatchString(obj.ArpMode) == false {
-		return errors.New("arpMode string invalid format")
+	if len(obj.DestinationPort) > 64 {
+		return errors.New("destinationPort string too long")
 	}
 
-	if len(obj.FwdMode) > 64 {
-		return errors.New("fwdMode string too long")
+	destinationPortMatch := regexp.MustCompile("^([a-zA-Z0-9][a-zA-Z0-9_.\\-]*)?$")
+	if destinationPortMatch.MatchString(obj.DestinationPort) == false {
+		return errors.New("destinationPort string invalid format")
 	}
 
-	fwdModeMatch := regexp.MustCompile("^(bridge|routing)?$")
-	if fwdModeMatch.MatchString(obj.FwdMode) == false {
-		return errors.New("fwdMode string invalid format")
+	if obj.DestinationType == "" {
+		obj.DestinationType = "local"
 	}
 
-	if len(obj.Name) > 64 {
-		return errors.New("name string too long")
+	destinationTypeMatch := regexp.MustCompile("^(local|gre|erspan)$")
+	if destinationTypeMatch.MatchString(obj.DestinationType) == false {
+		return errors.New("destinationType string invalid format")
 	}
 
-	nameMatch := regexp.MustCompile("^(global)$")
-	if nameMatch.MatchString(obj.Name) == false {
-		return errors.New("name string invalid format")
+	if obj.Direction == "" {
+		obj.Direction = "both"
 	}
 
-	if len(obj.NetworkInfraType) > 64 {
-		return errors.New("networkInfraType string too long")
+	directionMatch := regexp.MustCompile("^(both|ingress|egress)$")
+	if directionMatch.MatchString(obj.Direction) == false {
+		return errors.New("direction string invalid format")
 	}
 
-	networkInfraTypeMatch := regexp.MustCompile("^(aci|aci-opflex|default)?$")
-	if networkInfraTypeMatch.MatchString(obj.NetworkInfraType) == false {
-		return errors.New("networkInfraType string invalid format")
+	if len(obj.EndpointGroup) > 64 {
+		return errors.New("endpointGroup string too long")
 	}
 
-	pvtSubnetMatch := regexp.MustCompile("^((25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])(\\.(25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])){3})/16$")
-	if pvtSubnetMatch.MatchString(obj.PvtSubnet) == false {
-		return errors.New("pvtSubnet string invalid format")
+	endpointGroupMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])?$")
+	if endpointGroupMatch.MatchString(obj.EndpointGroup) == false {
+		return errors.New("endpointGroup string invalid format")
 	}
 
-	vlansMatch := regexp.MustCompile("^([0-9]{1,4}?-[0-9]{1,4}?)$")
-	if vlansMatch.MatchString(obj.Vlans) == false {
-		return errors.New("vlans string invalid format")
+	if obj.ErspanId > 1023 {
+		return errors.New("erspanId Value Out of bound")
 	}
 
-	vxlansMatch := regexp.MustCompile("^([0-9]{1,8}?-[0-9]{1,8}?)$")
-	if vxlansMatch.MatchString(obj.Vxlans) == false {
-		return errors.New("vxlans string invalid format")
+	if len(obj.NetworkName) > 64 {
+		return errors.New("networkName string too long")
+	}
+
+	networkNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])?$")
+	if networkNameMatch.MatchString(obj.NetworkName) == false {
+		return errors.New("networkName string invalid format")
+	}
+
+	if len(obj.SessionName) > 64 {
+		return errors.New("sessionName string too long")
+	}
+
+	sessionNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
+	if sessionNameMatch.MatchString(obj.SessionName) == false {
+		return errors.New("sessionName string invalid format")
+	}
+
+	if len(obj.TenantName) > 64 {
+		return errors.New("tenantName string too long")
+	}
+
+	tenantNameMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])$")
+	if tenantNameMatch.MatchString(obj.TenantName) == false {
+		return errors.New("tenantName string invalid format")
 	}
 
 	return nil
@@ -3461,6 +4300,33 @@ func ValidateNetprofile(obj *Netprofile) error {
 		return errors.New("burst Value Out of bound")
 	}
 
+	if len(obj.EgressMinRate) > 64 {
+		return errors.New("egressMinRate string too long")
+	}
+
+	egressMinRateMatch := regexp.MustCompile("^([1-9][0-9]* (([kmgKMG{1}]bps)|[kmgKMG{1}]|(kb|Kb|Gb|gb|Mb|mb)))?$|^([1-9][0-9]*(((k|m|g|K|G|M)bps)|(k|m|g|K|M|G)|(kb|Kb|Gb|gb|Mb|mb)))?$")
+	if egressMinRateMatch.MatchString(obj.EgressMinRate) == false {
+		return errors.New("egressMinRate string invalid format")
+	}
+
+	if len(obj.EgressRate) > 64 {
+		return errors.New("egressRate string too long")
+	}
+
+	egressRateMatch := regexp.MustCompile("^([1-9][0-9]* (([kmgKMG{1}]bps)|[kmgKMG{1}]|(kb|Kb|Gb|gb|Mb|mb)))?$|^([1-9][0-9]*(((k|m|g|K|G|M)bps)|(k|m|g|K|M|G)|(kb|Kb|Gb|gb|Mb|mb)))?$")
+	if egressRateMatch.MatchString(obj.EgressRate) == false {
+		return errors.New("egressRate string invalid format")
+	}
+
+	if len(obj.IngressRate) > 64 {
+		return errors.New("ingressRate string too long")
+	}
+
+	ingressRateMatch := regexp.MustCompile("^([1-9][0-9]* (([kmgKMG{1}]bps)|[kmgKMG{1}]|(kb|Kb|Gb|gb|Mb|mb)))?$|^([1-9][0-9]*(((k|m|g|K|G|M)bps)|(k|m|g|K|M|G)|(kb|Kb|Gb|gb|Mb|mb)))?$")
+	if ingressRateMatch.MatchString(obj.IngressRate) == false {
+		return errors.New("ingressRate string invalid format")
+	}
+
 	if len(obj.ProfileName) > 64 {
 		return errors.New("profileName string too long")
 	}
@@ -3787,7 +4653,7 @@ func ValidateNetwork(obj *Network) error {
 		return errors.New("cfgdTag string invalid format")
 	}
 
-	encapMatch := regexp.MustCompile("^(vlan|vxlan)$")
+	encapMatch := regexp.MustCompile("^(vlan|vxlan|geneve)$")
 	if encapMatch.MatchString(obj.Encap) == false {
 		return errors.New("encap string invalid format")
 	}
@@ -3807,6 +4673,10 @@ func ValidateNetwork(obj *Network) error {
 		return errors.New("ipv6Subnet string invalid format")
 	}
 
+	if obj.Mtu > 9000 {
+		return errors.New("mtu Value Out of bound")
+	}
+
 	if len(obj.NetworkName) > 64 {
 		return errors.New("networkName string too long")
 	}
@@ -4156,6 +5026,15 @@ func ValidatePolicy(obj *Policy) error {
 
 	// Validate each field
 
+	if obj.Mode == "" {
+		obj.Mode = "enforce"
+	}
+
+	modeMatch := regexp.MustCompile("^(enforce|audit)$")
+	if modeMatch.MatchString(obj.Mode) == false {
+		return errors.New("mode string invalid format")
+	}
+
 	if len(obj.PolicyName) > 64 {
 		return errors.New("policyName string too long")
 	}
@@ -4193,10 +5072,33 @@ func httpInspectRule(w http.ResponseWriter, r *http.Request, vars map[string]str
 	}
 	obj.Config = *objConfig
 
+	if err := GetOperRule(&obj); err != nil {
+		log.Errorf("GetRule error for: %+v. Err: %v", obj, err)
+		return nil, err
+	}
+
 	// Return the obj
 	return &obj, nil
 }
 
+// Get a ruleOper object
+func GetOperRule(obj *RuleInspect) error {
+	// Check if we handle this object
+	if objCallbackHandler.RuleCb == nil {
+		log.Errorf("No callback registered for rule object")
+		return errors.New("Invalid object type")
+	}
+
+	// Perform callback
+	err := objCallbackHandler.RuleCb.RuleGetOper(obj)
+	if err != nil {
+		log.Errorf("RuleDelete retruned error for: %+v. Err: %v", obj, err)
+		return err
+	}
+
+	return nil
+}
+
 // LIST REST call
 func httpListRules(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
 	log.Debugf("Received httpListRules: %+v", vars)
@@ -4474,6 +5376,15 @@ func ValidateRule(obj *Rule) error {
 		return errors.New("direction string invalid format")
 	}
 
+	if len(obj.FromAddressSet) > 64 {
+		return errors.New("fromAddressSet string too long")
+	}
+
+	fromAddressSetMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])?$")
+	if fromAddressSetMatch.MatchString(obj.FromAddressSet) == false {
+		return errors.New("fromAddressSet string invalid format")
+	}
+
 	if len(obj.FromEndpointGroup) > 64 {
 		return errors.New("fromEndpointGroup string too long")
 	}
@@ -4510,6 +5421,15 @@ func ValidateRule(obj *Rule) error {
 		return errors.New("port Value Out of bound")
 	}
 
+	if len(obj.Ports) > 256 {
+		return errors.New("ports string too long")
+	}
+
+	portsMatch := regexp.MustCompile("^([0-9]{1,5}(-[0-9]{1,5})?(,[0-9]{1,5}(-[0-9]{1,5})?)*)?$")
+	if portsMatch.MatchString(obj.Ports) == false {
+		return errors.New("ports string invalid format")
+	}
+
 	if obj.Priority == 0 {
 		obj.Priority = 1
 	}
@@ -4545,6 +5465,15 @@ func ValidateRule(obj *Rule) error {
 		return errors.New("tenantName string invalid format")
 	}
 
+	if len(obj.ToAddressSet) > 64 {
+		return errors.New("toAddressSet string too long")
+	}
+
+	toAddressSetMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])?$")
+	if toAddressSetMatch.MatchString(obj.ToAddressSet) == false {
+		return errors.New("toAddressSet string invalid format")
+	}
+
 	if len(obj.ToEndpointGroup) > 64 {
 		return errors.New("toEndpointGroup string too long")
 	}
@@ -5239,6 +6168,15 @@ func ValidateTenant(obj *Tenant) error {
 		return errors.New("defaultNetwork string invalid format")
 	}
 
+	if len(obj.DefaultPolicy) > 64 {
+		return errors.New("defaultPolicy string too long")
+	}
+
+	defaultPolicyMatch := regexp.MustCompile("^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\\-]*[A-Za-z0-9])?$")
+	if defaultPolicyMatch.MatchString(obj.DefaultPolicy) == false {
+		return errors.New("defaultPolicy string invalid format")
+	}
+
 	if len(obj.TenantName) > 64 {
 		return errors.New("tenantName string too long")
 	}
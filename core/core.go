@@ -87,6 +87,8 @@ type InstanceInfo struct {
 	PluginMode   string      `json:"plugin-mode"`
 	HostPvtNW    int         `json:"host-pvt-nw"`
 	VxlanUDPPort int         `json:"vxlan-port"`
+	FlowLog      string      `json:"flow-log"`
+	UplinkRate   string      `json:"uplink-rate"`
 }
 
 // PortSpec defines protocol/port info required to host the service
@@ -113,6 +115,8 @@ type NetworkDriver interface {
 	Init(instInfo *InstanceInfo) error
 	Deinit()
 	CreateNetwork(id string) error
+	// Apply the subnet and gateway changes of an existing network
+	UpdateNetwork(id, prevGateway, prevIPv6Gateway string) error
 	DeleteNetwork(id, subnet, nwType, encap string, pktTag, extPktTag int, gateway string, tenant string) error
 	CreateEndpoint(id string) error
 	UpdateEndpointGroup(id string) error
@@ -135,6 +139,8 @@ type NetworkDriver interface {
 	SvcProviderUpdate(svcName string, providers []string)
 	// Get endpoint stats
 	GetEndpointStats() ([]byte, error)
+	// Get policy rule hit counters
+	GetPolicyRuleStats() ([]byte, error)
 	// return current state in json form
 	InspectState() ([]byte, error)
 	// return bgp in json form
@@ -144,6 +150,16 @@ type NetworkDriver interface {
 	InspectNameserver() ([]byte, error)
 	AddPolicyRule(id string) error
 	DelPolicyRule(id string) error
+	// Update the encryption of the tunnels to the peers
+	UpdateEncryption(id string) error
+	// Get the encryption status of the tunnels in json form
+	GetEncryptionStatus() ([]byte, error)
+	// Reconcile the data path with the state
+	Reconcile() error
+	// Add or update a mirror session
+	AddMirrorSession(id string) error
+	// Delete a mirror session
+	DelMirrorSession(id string) error
 }
 
 // WatchState is used to provide a difference between core.State structs by
@@ -176,15 +192,29 @@ type StateDriver interface {
 		unmarshal func([]byte, interface{}) error) error
 	ReadAllState(baseKey string, stateType State,
 		unmarshal func([]byte, interface{}) error) ([]State, error)
-	// WatchAllState returns changes to a state from the point watch is started.
+	// WatchAllState first returns the existing state under baseKey as
+	// creates, followed by a WatchState with neither Curr nor Prev set to
+	// mark the end of the existing state. It then returns the changes made
+	// after the existing state was read, so that no updates are missed.
 	// It's a blocking call.
-	// XXX: This specification introduces a small time window where a few
-	// updates might be missed that occurred just before watch was started.
-	// May be watch shall return all existing state first and then subsequent
-	// updates. Revisit if this enhancement is needed.
 	WatchAllState(baseKey string, stateType State,
 		unmarshal func([]byte, interface{}) error, rsps chan WatchState) error
 	ClearState(key string) error
+
+	// ReadVersion reads a key along with its version. The version changes
+	// every time the key is written.
+	ReadVersion(key string) ([]byte, uint64, error)
+	// WriteCAS writes a key only if it is still at version, a version of 0
+	// meaning the key must not exist. It returns ErrStateChanged otherwise.
+	WriteCAS(key string, value []byte, version uint64) error
+	// Commit applies all ops atomically, if every key is still at the
+	// version of its op. It returns ErrStateChanged, without making any
+	// changes, otherwise.
+	Commit(ops []StateOp) error
+	ReadStateVersion(key string, value State,
+		unmarshal func([]byte, interface{}) error) (uint64, error)
+	WriteStateCAS(key string, value State, version uint64,
+		marshal func(interface{}) ([]byte, error)) error
 }
 
 // Resource defines a allocatable unit. A resource is uniquely identified
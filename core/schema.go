@@ -0,0 +1,432 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SchemaVersionField is the field of a state record that holds the schema
+// version the record was written with. Records written before states were
+// versioned don't have it, and are at version 0.
+const SchemaVersionField = "schemaVersion"
+
+// Migration upgrades a state record, decoded as a json object, from the
+// previous schema version to Version. Migrate can be nil when the new
+// version only adds fields.
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(record map[string]interface{}) error
+}
+
+// schema is the state type stored under a key prefix, and the migrations
+// of its records in increasing version order
+type schema struct {
+	keyPrefix  string
+	stateType  State
+	migrations []Migration
+}
+
+// version is the schema version records are written with. Version 1 is the
+// first versioned schema.
+func (s *schema) version() int {
+	if len(s.migrations) == 0 || s.migrations[len(s.migrations)-1].Version < 1 {
+		return 1
+	}
+
+	return s.migrations[len(s.migrations)-1].Version
+}
+
+// pending returns the migrations a record at version needs
+func (s *schema) pending(version int) []Migration {
+	migrations := []Migration{}
+	for _, m := range s.migrations {
+		if m.Version > version {
+			migrations = append(migrations, m)
+		}
+	}
+
+	return migrations
+}
+
+var (
+	schemaMutex sync.RWMutex
+	schemas     = make(map[string]*schema)
+)
+
+// RegisterSchema registers the state type stored under keyPrefix, along with
+// the migrations of its records in increasing version order. Records are
+// stamped with the schema version when they are written, and older records
+// are upgraded when they are read.
+func RegisterSchema(keyPrefix string, stateType State, migrations ...Migration) error {
+	lastVersion := 0
+	for _, m := range migrations {
+		if m.Version <= lastVersion {
+			return Errorf("migrations of %s are not in increasing version order", keyPrefix)
+		}
+		lastVersion = m.Version
+	}
+
+	schemaMutex.Lock()
+	defer schemaMutex.Unlock()
+
+	if _, ok := schemas[keyPrefix]; ok {
+		return Errorf("schema of %s is already registered", keyPrefix)
+	}
+	schemas[keyPrefix] = &schema{
+		keyPrefix:  keyPrefix,
+		stateType:  stateType,
+		migrations: migrations,
+	}
+
+	return nil
+}
+
+// findSchema returns the schema with the longest prefix of key, or nil
+func findSchema(key string) *schema {
+	schemaMutex.RLock()
+	defer schemaMutex.RUnlock()
+
+	var found *schema
+	for prefix, s := range schemas {
+		if strings.HasPrefix(key, prefix) &&
+			(found == nil || len(prefix) > len(found.keyPrefix)) {
+			found = s
+		}
+	}
+
+	return found
+}
+
+// recordVersion returns the schema version of a json record. ok is false
+// for records that are not json objects.
+func recordVersion(record []byte) (version int, ok bool) {
+	fields := struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}{}
+	if err := json.Unmarshal(record, &fields); err != nil {
+		return 0, false
+	}
+
+	return fields.SchemaVersion, true
+}
+
+// upgradeRecord applies the pending migrations to a record
+func (s *schema) upgradeRecord(key string, record []byte) ([]byte, error) {
+	version, ok := recordVersion(record)
+	if !ok || version == s.version() {
+		return record, nil
+	}
+
+	if version > s.version() {
+		return nil, Errorf("%s was written with schema version %d, newer than the supported version %d",
+			key, version, s.version())
+	}
+
+	// numbers are kept as is, so that large integers like bitset words
+	// don't lose precision
+	fields := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(record))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	for _, m := range s.pending(version) {
+		if m.Migrate == nil {
+			continue
+		}
+		if err := m.Migrate(fields); err != nil {
+			return nil, Errorf("error migrating %s to schema version %d. Error: %v",
+				key, m.Version, err)
+		}
+	}
+	fields[SchemaVersionField] = s.version()
+
+	return json.Marshal(fields)
+}
+
+// EncodeState marshals a state to be written into key, and stamps it with
+// the schema version of the key.
+func EncodeState(key string, value State,
+	marshal func(interface{}) ([]byte, error)) ([]byte, error) {
+	encodedState, err := marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	s := findSchema(key)
+	if s == nil || len(encodedState) < 2 || encodedState[0] != '{' {
+		return encodedState, nil
+	}
+
+	stamp := fmt.Sprintf(`{"%s":%d`, SchemaVersionField, s.version())
+	if encodedState[1] != '}' {
+		stamp += ","
+	}
+
+	return append([]byte(stamp), encodedState[1:]...), nil
+}
+
+// DecodeState upgrades a record read from key to the schema version of the
+// key, and unmarshals it into value.
+func DecodeState(key string, encodedState []byte, value interface{},
+	unmarshal func([]byte, interface{}) error) error {
+	if s := findSchema(key); s != nil {
+		var err error
+		encodedState, err = s.upgradeRecord(key, encodedState)
+		if err != nil {
+			return err
+		}
+	}
+
+	return unmarshal(encodedState, value)
+}
+
+// SchemaPrefixes returns the registered key prefixes, sorted
+func SchemaPrefixes() []string {
+	schemaMutex.RLock()
+	defer schemaMutex.RUnlock()
+
+	prefixes := []string{}
+	for prefix := range schemas {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	return prefixes
+}
+
+// DecodeStates decodes records read from a registered key prefix into the
+// state type of the prefix, upgrading them to its schema version. The states
+// use sd, so that their Write stores them again.
+func DecodeStates(sd StateDriver, keyPrefix string, records [][]byte) ([]State, error) {
+	s := findSchema(keyPrefix)
+	if s == nil || s.keyPrefix != keyPrefix {
+		return nil, Errorf("no schema is registered for %s", keyPrefix)
+	}
+
+	stateType := reflect.TypeOf(s.stateType).Elem()
+	states := []State{}
+	for _, record := range records {
+		value := reflect.New(stateType)
+		if err := DecodeState(keyPrefix, record, value.Interface(), json.Unmarshal); err != nil {
+			return nil, err
+		}
+
+		// every core.State embeds core.CommonState
+		value.Elem().FieldByName("StateDriver").Set(reflect.ValueOf(sd))
+		states = append(states, value.Interface().(State))
+	}
+
+	return states, nil
+}
+
+// SchemaStatus is the migration status of the records under a key prefix
+type SchemaStatus struct {
+	KeyPrefix   string
+	Version     int         // schema version of the key prefix
+	NumRecords  int         // number of records under the key prefix
+	NumOutdated int         // number of records older than Version
+	Pending     []Migration // migrations the oldest record needs
+}
+
+// GetSchemaStatus returns the migration status of every registered key
+// prefix, sorted by prefix.
+func GetSchemaStatus(sd StateDriver) ([]SchemaStatus, error) {
+	statuses := []SchemaStatus{}
+	for _, prefix := range SchemaPrefixes() {
+		s := findSchema(prefix)
+		records, err := sd.ReadAll(prefix)
+		if err != nil && ErrIfKeyExists(err) != nil {
+			return nil, err
+		}
+
+		status := SchemaStatus{
+			KeyPrefix:  prefix,
+			Version:    s.version(),
+			NumRecords: len(records),
+			Pending:    []Migration{},
+		}
+		oldest := s.version()
+		for _, record := range records {
+			version, ok := recordVersion(record)
+			if !ok {
+				continue
+			}
+			if version > s.version() {
+				return nil, Errorf("records under %s were written with schema version %d, newer than the supported version %d",
+					prefix, version, s.version())
+			}
+			if version < s.version() {
+				status.NumOutdated++
+			}
+			if version < oldest {
+				oldest = version
+			}
+		}
+		if status.NumOutdated > 0 {
+			status.Pending = s.pending(oldest)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// hasMigrations returns true if any of migrations changes records, rather
+// than only adding fields
+func hasMigrations(migrations []Migration) bool {
+	for _, m := range migrations {
+		if m.Migrate != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckSchemas returns an error if any records in the store are older than
+// their schema version, and need to be migrated. Records that only need to
+// be stamped with the schema version, like the ones written before states
+// were versioned, are read as is and don't fail the check.
+func CheckSchemas(sd StateDriver) error {
+	statuses, err := GetSchemaStatus(sd)
+	if err != nil {
+		return err
+	}
+
+	outdated := []string{}
+	for _, status := range statuses {
+		if status.NumOutdated > 0 && hasMigrations(status.Pending) {
+			outdated = append(outdated, status.KeyPrefix)
+		}
+	}
+	if len(outdated) > 0 {
+		return Errorf("state store has records older than the schema version under %s",
+			strings.Join(outdated, ", "))
+	}
+
+	return nil
+}
+
+// rewriteStates reads and writes back all states under a key prefix, which
+// upgrades and stamps them with the schema version of the prefix
+func rewriteStates(sd StateDriver, keyPrefix string) error {
+	s := findSchema(keyPrefix)
+	states, err := sd.ReadAllState(keyPrefix, s.stateType, json.Unmarshal)
+	if err != nil {
+		return err
+	}
+
+	for _, state := range states {
+		if err := rewriteState(sd, state); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rewriteState writes back the current version of a state with a versioned
+// write, so that changes made after the prefix was read, for example by
+// the agents, are not overwritten. States deleted meanwhile are skipped.
+func rewriteState(sd StateDriver, state State) error {
+	// find the key of the state by writing it in a transaction that is
+	// never committed
+	keyTxn := &stateTxn{StateDriver: sd, versions: make(map[string]uint64), ops: make(map[string]*txnOp)}
+	reflect.ValueOf(state).Elem().FieldByName("StateDriver").Set(reflect.ValueOf(keyTxn))
+	err := state.Write()
+	reflect.ValueOf(state).Elem().FieldByName("StateDriver").Set(reflect.ValueOf(sd))
+	if err != nil {
+		return err
+	}
+	if len(keyTxn.keys) != 1 {
+		return Errorf("state %+v is not written to a single key", state)
+	}
+	key := keyTxn.keys[0]
+
+	stateType := reflect.TypeOf(state).Elem()
+	return UpdateStates(sd, func(txn StateDriver) error {
+		current := reflect.New(stateType).Interface().(State)
+		err := txn.ReadState(key, current, json.Unmarshal)
+		if err != nil && ErrIfKeyExists(err) == nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return txn.WriteState(key, current, json.Marshal)
+	})
+}
+
+// StampSchemas stamps the outdated records under the key prefixes that have
+// nothing to migrate with the schema version of the prefix. Records changed
+// while they are stamped are read again, so it can run while agents write
+// their state. It returns the status of the stamped prefixes before they
+// were stamped.
+func StampSchemas(sd StateDriver) ([]SchemaStatus, error) {
+	statuses, err := GetSchemaStatus(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	stamped := []SchemaStatus{}
+	for _, status := range statuses {
+		if status.NumOutdated == 0 || hasMigrations(status.Pending) {
+			continue
+		}
+
+		if err := rewriteStates(sd, status.KeyPrefix); err != nil {
+			return nil, err
+		}
+		stamped = append(stamped, status)
+	}
+
+	return stamped, nil
+}
+
+// MigrateStates upgrades the records under the key prefixes that have
+// outdated records, by reading and writing back all of their states. It
+// returns the status of the prefixes before the migration. Nothing else
+// should write to the store while it runs.
+func MigrateStates(sd StateDriver) ([]SchemaStatus, error) {
+	statuses, err := GetSchemaStatus(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range statuses {
+		if status.NumOutdated == 0 {
+			continue
+		}
+
+		if err := rewriteStates(sd, status.KeyPrefix); err != nil {
+			return nil, err
+		}
+	}
+
+	return statuses, nil
+}
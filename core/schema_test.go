@@ -0,0 +1,339 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// schemaTestDriver is an in memory store with the calls the schema
+// functions use. Other calls panic on the nil StateDriver.
+type schemaTestDriver struct {
+	StateDriver
+	kvs      map[string][]byte
+	versions map[string]uint64
+	version  uint64
+	// beforeCAS is called before a versioned write, tests use it to change
+	// the key concurrently
+	beforeCAS func(key string)
+}
+
+func (d *schemaTestDriver) Write(key string, value []byte) error {
+	if d.versions == nil {
+		d.versions = make(map[string]uint64)
+	}
+
+	d.version++
+	d.kvs[key] = value
+	d.versions[key] = d.version
+	return nil
+}
+
+func (d *schemaTestDriver) ReadStateVersion(key string, value State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	if err := d.ReadState(key, value, unmarshal); err != nil {
+		return 0, err
+	}
+
+	return d.versions[key], nil
+}
+
+func (d *schemaTestDriver) WriteStateCAS(key string, value State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	if d.beforeCAS != nil {
+		d.beforeCAS(key)
+	}
+	if d.versions[key] != version {
+		return ErrStateChanged
+	}
+
+	return d.WriteState(key, value, marshal)
+}
+
+func (d *schemaTestDriver) Read(key string) ([]byte, error) {
+	value, ok := d.kvs[key]
+	if !ok {
+		return []byte{}, Errorf("key not found")
+	}
+
+	return value, nil
+}
+
+func (d *schemaTestDriver) ReadAll(baseKey string) ([][]byte, error) {
+	values := [][]byte{}
+	for key, value := range d.kvs {
+		if strings.HasPrefix(key, baseKey) {
+			values = append(values, value)
+		}
+	}
+
+	return values, nil
+}
+
+func (d *schemaTestDriver) WriteState(key string, value State,
+	marshal func(interface{}) ([]byte, error)) error {
+	encodedState, err := EncodeState(key, value, marshal)
+	if err != nil {
+		return err
+	}
+
+	return d.Write(key, encodedState)
+}
+
+func (d *schemaTestDriver) ReadState(key string, value State,
+	unmarshal func([]byte, interface{}) error) error {
+	encodedState, err := d.Read(key)
+	if err != nil {
+		return err
+	}
+
+	return DecodeState(key, encodedState, value, unmarshal)
+}
+
+// ReadAllState only reads schemaTestStates
+func (d *schemaTestDriver) ReadAllState(baseKey string, stateType State,
+	unmarshal func([]byte, interface{}) error) ([]State, error) {
+	states := []State{}
+	for key, value := range d.kvs {
+		if !strings.HasPrefix(key, baseKey) {
+			continue
+		}
+
+		s := &schemaTestState{prefix: baseKey}
+		if err := DecodeState(baseKey, value, s, unmarshal); err != nil {
+			return nil, err
+		}
+		s.StateDriver = d
+		states = append(states, s)
+	}
+
+	return states, nil
+}
+
+type schemaTestState struct {
+	CommonState
+	prefix string
+	Name   string `json:"name"`
+	Count  uint64 `json:"count"`
+}
+
+func (s *schemaTestState) Write() error {
+	return s.StateDriver.WriteState(s.prefix+s.ID, s, json.Marshal)
+}
+
+func (s *schemaTestState) Read(id string) error {
+	return s.StateDriver.ReadState(s.prefix+id, s, json.Unmarshal)
+}
+
+func (s *schemaTestState) ReadAll() ([]State, error) {
+	return nil, Errorf("Should not be called!!")
+}
+
+func (s *schemaTestState) Clear() error {
+	return Errorf("Should not be called!!")
+}
+
+// renameTitle is a version 2 migration that renames the title field
+var renameTitle = Migration{
+	Version:     2,
+	Description: "rename title to name",
+	Migrate: func(record map[string]interface{}) error {
+		record["name"] = record["title"]
+		delete(record, "title")
+		return nil
+	},
+}
+
+func TestRegisterSchema(t *testing.T) {
+	if err := RegisterSchema("/test/register/", &schemaTestState{}); err != nil {
+		t.Fatalf("Error registering schema. Error: %s", err)
+	}
+	if err := RegisterSchema("/test/register/", &schemaTestState{}); err == nil {
+		t.Fatalf("Registering a schema twice succeeded")
+	}
+	if err := RegisterSchema("/test/register/order/", &schemaTestState{},
+		renameTitle, Migration{Version: 1}); err == nil {
+		t.Fatalf("Registering migrations out of order succeeded")
+	}
+}
+
+func TestSchemaVersionStamp(t *testing.T) {
+	RegisterSchema("/test/stamp/", &schemaTestState{})
+	d := &schemaTestDriver{kvs: make(map[string][]byte)}
+
+	s := &schemaTestState{prefix: "/test/stamp/", Name: "foo"}
+	s.StateDriver = d
+	s.ID = "a"
+	if err := s.Write(); err != nil {
+		t.Fatalf("Error writing state. Error: %s", err)
+	}
+	if !strings.HasPrefix(string(d.kvs["/test/stamp/a"]), `{"schemaVersion":1,"id":"a"`) {
+		t.Fatalf("State was not stamped with its schema version: %s", d.kvs["/test/stamp/a"])
+	}
+
+	// keys without a schema are written as is
+	s.prefix = "/test/unversioned/"
+	if err := s.Write(); err != nil {
+		t.Fatalf("Error writing state. Error: %s", err)
+	}
+	if strings.Contains(string(d.kvs["/test/unversioned/a"]), SchemaVersionField) {
+		t.Fatalf("State without a schema was stamped: %s", d.kvs["/test/unversioned/a"])
+	}
+
+	readState := &schemaTestState{prefix: "/test/stamp/"}
+	readState.StateDriver = d
+	if err := readState.Read("a"); err != nil || readState.Name != "foo" {
+		t.Fatalf("Error reading stamped state %+v. Error: %v", readState, err)
+	}
+}
+
+func TestSchemaUpgradeOnRead(t *testing.T) {
+	RegisterSchema("/test/upgrade/", &schemaTestState{}, renameTitle)
+	d := &schemaTestDriver{kvs: make(map[string][]byte)}
+	d.Write("/test/upgrade/a", []byte(`{"id":"a","title":"foo","count":18446744073709551615}`))
+	d.Write("/test/upgrade/b", []byte(`{"schemaVersion":3,"id":"b","name":"bar"}`))
+
+	s := &schemaTestState{prefix: "/test/upgrade/"}
+	s.StateDriver = d
+	if err := s.Read("a"); err != nil {
+		t.Fatalf("Error reading unversioned state. Error: %s", err)
+	}
+	if s.Name != "foo" || s.Count != 18446744073709551615 {
+		t.Fatalf("State was not upgraded correctly: %+v", s)
+	}
+
+	if err := s.Read("b"); err == nil {
+		t.Fatalf("Reading a state with a newer schema version succeeded")
+	}
+}
+
+func TestMigrateStates(t *testing.T) {
+	RegisterSchema("/test/migrate/", &schemaTestState{}, renameTitle)
+	d := &schemaTestDriver{kvs: make(map[string][]byte)}
+	d.Write("/test/migrate/a", []byte(`{"id":"a","title":"foo"}`))
+	d.Write("/test/migrate/b", []byte(`{"schemaVersion":1,"id":"b","title":"bar"}`))
+	d.Write("/test/migrate/c", []byte(`{"schemaVersion":2,"id":"c","name":"baz"}`))
+
+	if err := CheckSchemas(d); err == nil {
+		t.Fatalf("Store with outdated records passed the schema check")
+	}
+
+	statuses, err := GetSchemaStatus(d)
+	if err != nil {
+		t.Fatalf("Error getting schema status. Error: %s", err)
+	}
+	found := false
+	for _, status := range statuses {
+		if status.KeyPrefix != "/test/migrate/" {
+			continue
+		}
+		found = true
+		if status.Version != 2 || status.NumRecords != 3 || status.NumOutdated != 2 ||
+			len(status.Pending) != 1 {
+			t.Fatalf("Unexpected schema status: %+v", status)
+		}
+	}
+	if !found {
+		t.Fatalf("Schema status is missing /test/migrate/")
+	}
+
+	if _, err := MigrateStates(d); err != nil {
+		t.Fatalf("Error migrating states. Error: %s", err)
+	}
+	if err := CheckSchemas(d); err != nil {
+		t.Fatalf("Schema check failed after the migration. Error: %s", err)
+	}
+	if string(d.kvs["/test/migrate/a"]) != `{"schemaVersion":2,"id":"a","name":"foo","count":0}` {
+		t.Fatalf("State was not migrated: %s", d.kvs["/test/migrate/a"])
+	}
+}
+
+func TestStampSchemas(t *testing.T) {
+	RegisterSchema("/test/nostamp/", &schemaTestState{})
+	RegisterSchema("/test/addfield/", &schemaTestState{},
+		Migration{Version: 2, Description: "add count"})
+	RegisterSchema("/test/rename/", &schemaTestState{}, renameTitle)
+	d := &schemaTestDriver{kvs: make(map[string][]byte)}
+	d.Write("/test/nostamp/a", []byte(`{"id":"a","name":"foo"}`))
+	d.Write("/test/addfield/b", []byte(`{"schemaVersion":1,"id":"b","name":"bar"}`))
+
+	// records that only lack the schema version dont need a migration
+	if err := CheckSchemas(d); err != nil {
+		t.Fatalf("Store without migrations failed the schema check. Error: %s", err)
+	}
+
+	stamped, err := StampSchemas(d)
+	if err != nil {
+		t.Fatalf("Error stamping states. Error: %s", err)
+	}
+	if len(stamped) != 2 || stamped[0].KeyPrefix != "/test/addfield/" ||
+		stamped[1].KeyPrefix != "/test/nostamp/" {
+		t.Fatalf("Unexpected stamped prefixes: %+v", stamped)
+	}
+	if string(d.kvs["/test/nostamp/a"]) != `{"schemaVersion":1,"id":"a","name":"foo","count":0}` {
+		t.Fatalf("State was not stamped: %s", d.kvs["/test/nostamp/a"])
+	}
+	if string(d.kvs["/test/addfield/b"]) != `{"schemaVersion":2,"id":"b","name":"bar","count":0}` {
+		t.Fatalf("State was not stamped: %s", d.kvs["/test/addfield/b"])
+	}
+
+	// a record written while it is stamped is read again, not overwritten
+	d.Write("/test/nostamp/d", []byte(`{"id":"d","name":"old"}`))
+	d.beforeCAS = func(key string) {
+		if key == "/test/nostamp/d" {
+			d.beforeCAS = nil
+			d.Write(key, []byte(`{"id":"d","name":"new","count":1}`))
+		}
+	}
+	if _, err := StampSchemas(d); err != nil {
+		t.Fatalf("Error stamping states. Error: %s", err)
+	}
+	if string(d.kvs["/test/nostamp/d"]) != `{"schemaVersion":1,"id":"d","name":"new","count":1}` {
+		t.Fatalf("Concurrent write was overwritten: %s", d.kvs["/test/nostamp/d"])
+	}
+
+	// records with a pending migration are left to cfgtool
+	d.Write("/test/rename/c", []byte(`{"id":"c","title":"baz"}`))
+	if err := CheckSchemas(d); err == nil {
+		t.Fatalf("Store with outdated records passed the schema check")
+	}
+	if stamped, err := StampSchemas(d); err != nil || len(stamped) != 0 {
+		t.Fatalf("Stamped %+v with a pending migration. Error: %v", stamped, err)
+	}
+	if string(d.kvs["/test/rename/c"]) != `{"id":"c","title":"baz"}` {
+		t.Fatalf("State with a pending migration was rewritten: %s", d.kvs["/test/rename/c"])
+	}
+}
+
+func TestDecodeStates(t *testing.T) {
+	RegisterSchema("/test/decode/", &schemaTestState{}, renameTitle)
+	d := &schemaTestDriver{kvs: make(map[string][]byte)}
+
+	states, err := DecodeStates(d, "/test/decode/", [][]byte{[]byte(`{"id":"a","title":"foo"}`)})
+	if err != nil || len(states) != 1 {
+		t.Fatalf("Error decoding states %+v. Error: %v", states, err)
+	}
+	s := states[0].(*schemaTestState)
+	if s.Name != "foo" || s.StateDriver != d {
+		t.Fatalf("State was not decoded correctly: %+v", s)
+	}
+
+	if _, err := DecodeStates(d, "/test/unregistered/", [][]byte{}); err == nil {
+		t.Fatalf("Decoding states of an unregistered prefix succeeded")
+	}
+}
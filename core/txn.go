@@ -0,0 +1,218 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import "errors"
+
+// ErrStateChanged is returned by the versioned writes of a StateDriver when
+// a key was changed after it was read
+var ErrStateChanged = errors.New("state was changed since it was read")
+
+// max times UpdateStates retries an update after a conflict
+const maxUpdateRetries = 10
+
+// StateOp is a write or delete of a key in a StateDriver.Commit
+type StateOp struct {
+	Key     string
+	Value   []byte // nil deletes the key
+	Version uint64 // version the key was read at, 0 if it must not exist
+}
+
+// txnOp is a change buffered by a stateTxn. Writes of a core.State keep
+// the state, so that single key updates can be passed to WriteStateCAS.
+type txnOp struct {
+	StateOp
+	state   State
+	marshal func(interface{}) ([]byte, error)
+}
+
+// stateTxn is the StateDriver UpdateStates passes to updates. Reads record
+// the version of the keys, and writes are buffered until the commit. Calls
+// not overridden here, like ReadAll, go to the state driver directly.
+type stateTxn struct {
+	StateDriver
+	versions map[string]uint64
+	ops      map[string]*txnOp
+	keys     []string // written keys, in order
+}
+
+// UpdateStates runs update with a state driver that remembers the version
+// of the keys it reads and holds back writes, and then commits the writes
+// atomically. If any key was changed since update read it, update runs
+// again on the new state. This keeps read-modify-write cycles, such as
+// allocations from a bitset, safe from concurrent writers. Keys written or
+// cleared without being read first must not exist when the update commits.
+func UpdateStates(sd StateDriver, update func(txn StateDriver) error) error {
+	var err error
+
+	for i := 0; i < maxUpdateRetries; i++ {
+		txn := &stateTxn{
+			StateDriver: sd,
+			versions:    make(map[string]uint64),
+			ops:         make(map[string]*txnOp),
+		}
+
+		if err = update(txn); err != nil {
+			return err
+		}
+
+		err = txn.commit()
+		if err != ErrStateChanged {
+			return err
+		}
+	}
+
+	return err
+}
+
+// recordVersion keeps the version a key was first read at
+func (txn *stateTxn) recordVersion(key string, version uint64, err error) {
+	if _, ok := txn.versions[key]; ok {
+		return
+	}
+
+	if err == nil {
+		txn.versions[key] = version
+	} else if ErrIfKeyExists(err) == nil {
+		// key not found, it must still be missing when committed
+		txn.versions[key] = 0
+	}
+}
+
+func (txn *stateTxn) addOp(op *txnOp) {
+	if _, ok := txn.ops[op.Key]; !ok {
+		txn.keys = append(txn.keys, op.Key)
+	}
+	txn.ops[op.Key] = op
+}
+
+// Read returns the value written in the transaction, or reads the key
+func (txn *stateTxn) Read(key string) ([]byte, error) {
+	if op, ok := txn.ops[key]; ok {
+		if op.Value == nil {
+			return []byte{}, Errorf("key not found")
+		}
+		return op.Value, nil
+	}
+
+	value, version, err := txn.StateDriver.ReadVersion(key)
+	txn.recordVersion(key, version, err)
+
+	return value, err
+}
+
+// ReadVersion reads a key and records its version
+func (txn *stateTxn) ReadVersion(key string) ([]byte, uint64, error) {
+	value, err := txn.Read(key)
+	return value, txn.versions[key], err
+}
+
+// ReadState returns the state written in the transaction, or reads it
+func (txn *stateTxn) ReadState(key string, value State,
+	unmarshal func([]byte, interface{}) error) error {
+	if _, ok := txn.ops[key]; ok {
+		encodedState, err := txn.Read(key)
+		if err != nil {
+			return err
+		}
+		return DecodeState(key, encodedState, value, unmarshal)
+	}
+
+	version, err := txn.StateDriver.ReadStateVersion(key, value, unmarshal)
+	txn.recordVersion(key, version, err)
+
+	return err
+}
+
+// ReadStateVersion reads a state and records its version
+func (txn *stateTxn) ReadStateVersion(key string, value State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	err := txn.ReadState(key, value, unmarshal)
+	return txn.versions[key], err
+}
+
+// Write holds back a write until the commit
+func (txn *stateTxn) Write(key string, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	txn.addOp(&txnOp{StateOp: StateOp{Key: key, Value: value}})
+	return nil
+}
+
+// WriteState holds back a write until the commit
+func (txn *stateTxn) WriteState(key string, value State,
+	marshal func(interface{}) ([]byte, error)) error {
+	encodedState, err := EncodeState(key, value, marshal)
+	if err != nil {
+		return err
+	}
+
+	txn.addOp(&txnOp{StateOp: StateOp{Key: key, Value: encodedState},
+		state: value, marshal: marshal})
+	return nil
+}
+
+// ClearState holds back a delete until the commit
+func (txn *stateTxn) ClearState(key string) error {
+	txn.addOp(&txnOp{StateOp: StateOp{Key: key}})
+	return nil
+}
+
+// WriteCAS is not supported within an update, the commit checks versions
+func (txn *stateTxn) WriteCAS(key string, value []byte, version uint64) error {
+	return Errorf("versioned writes are not supported in an update")
+}
+
+// WriteStateCAS is not supported within an update, the commit checks
+// versions
+func (txn *stateTxn) WriteStateCAS(key string, value State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return Errorf("versioned writes are not supported in an update")
+}
+
+// Commit is not supported within an update, writes are committed when the
+// update returns
+func (txn *stateTxn) Commit(ops []StateOp) error {
+	return Errorf("commits are not supported in an update")
+}
+
+// commit writes the changes, if the keys are still at the versions they
+// were read at. Keys written without being read must not exist.
+func (txn *stateTxn) commit() error {
+	if len(txn.keys) == 0 {
+		return nil
+	}
+
+	ops := []StateOp{}
+	for _, key := range txn.keys {
+		op := txn.ops[key]
+		op.Version = txn.versions[key]
+		ops = append(ops, op.StateOp)
+	}
+
+	// a single write doesnt need a multi-key commit
+	if len(ops) == 1 && ops[0].Value != nil {
+		op := txn.ops[ops[0].Key]
+		if op.state != nil {
+			return txn.StateDriver.WriteStateCAS(op.Key, op.state, op.Version, op.marshal)
+		}
+		return txn.StateDriver.WriteCAS(op.Key, op.Value, op.Version)
+	}
+
+	return txn.StateDriver.Commit(ops)
+}
@@ -0,0 +1,229 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type txnTestValue struct {
+	data    []byte
+	version uint64
+}
+
+// txnTestDriver is an in memory store with the versioned calls UpdateStates
+// uses. Other calls panic on the nil StateDriver.
+type txnTestDriver struct {
+	StateDriver
+	kvs       map[string]txnTestValue
+	version   uint64
+	numCAS    int
+	numCommit int
+}
+
+func (d *txnTestDriver) put(key string, value []byte) {
+	d.version++
+	d.kvs[key] = txnTestValue{data: value, version: d.version}
+}
+
+func (d *txnTestDriver) ReadVersion(key string) ([]byte, uint64, error) {
+	value, ok := d.kvs[key]
+	if !ok {
+		return []byte{}, 0, Errorf("key not found")
+	}
+
+	return value.data, value.version, nil
+}
+
+func (d *txnTestDriver) ReadStateVersion(key string, value State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	data, version, err := d.ReadVersion(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, unmarshal(data, value)
+}
+
+func (d *txnTestDriver) WriteStateCAS(key string, value State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	d.numCAS++
+	if d.kvs[key].version != version {
+		return ErrStateChanged
+	}
+
+	data, err := marshal(value)
+	if err != nil {
+		return err
+	}
+	d.put(key, data)
+
+	return nil
+}
+
+func (d *txnTestDriver) Commit(ops []StateOp) error {
+	d.numCommit++
+	for _, op := range ops {
+		if d.kvs[op.Key].version != op.Version {
+			return ErrStateChanged
+		}
+	}
+
+	for _, op := range ops {
+		if op.Value == nil {
+			delete(d.kvs, op.Key)
+		} else {
+			d.put(op.Key, op.Value)
+		}
+	}
+
+	return nil
+}
+
+type txnTestState struct {
+	CommonState
+	Count int `json:"count"`
+}
+
+func (s *txnTestState) Write() error {
+	return s.StateDriver.WriteState(s.ID, s, json.Marshal)
+}
+
+func (s *txnTestState) Read(id string) error {
+	return s.StateDriver.ReadState(id, s, json.Unmarshal)
+}
+
+func (s *txnTestState) ReadAll() ([]State, error) {
+	return nil, Errorf("Should not be called!!")
+}
+
+func (s *txnTestState) Clear() error {
+	return s.StateDriver.ClearState(s.ID)
+}
+
+func TestUpdateStatesRetry(t *testing.T) {
+	d := &txnTestDriver{kvs: make(map[string]txnTestValue)}
+	d.put("a", []byte(`{"id":"a","count":1}`))
+
+	numRuns := 0
+	err := UpdateStates(d, func(txn StateDriver) error {
+		numRuns++
+		s := &txnTestState{}
+		s.StateDriver = txn
+		if err := s.Read("a"); err != nil {
+			return err
+		}
+
+		// another writer changes the state before the first commit
+		if numRuns == 1 {
+			d.put("a", []byte(`{"id":"a","count":10}`))
+		}
+
+		s.Count++
+		return s.Write()
+	})
+	if err != nil {
+		t.Fatalf("Update failed. Error: %s", err)
+	}
+	if numRuns != 2 || d.numCAS != 2 || d.numCommit != 0 {
+		t.Fatalf("Unexpected update runs: %d, CAS writes: %d, commits: %d",
+			numRuns, d.numCAS, d.numCommit)
+	}
+
+	s := &txnTestState{}
+	s.StateDriver = d
+	if _, err := d.ReadStateVersion("a", s, json.Unmarshal); err != nil || s.Count != 11 {
+		t.Fatalf("Update was lost. State: %+v, Error: %v", s, err)
+	}
+}
+
+func TestUpdateStatesMultiKey(t *testing.T) {
+	d := &txnTestDriver{kvs: make(map[string]txnTestValue)}
+	d.put("a", []byte(`{"id":"a","count":1}`))
+	d.put("b", []byte(`{"id":"b","count":1}`))
+
+	err := UpdateStates(d, func(txn StateDriver) error {
+		a := &txnTestState{}
+		a.StateDriver = txn
+		if err := a.Read("a"); err != nil {
+			return err
+		}
+
+		// the update sees its own writes
+		a.Count++
+		if err := a.Write(); err != nil {
+			return err
+		}
+		if err := a.Read("a"); err != nil || a.Count != 2 {
+			t.Fatalf("Update doesnt see its write. State: %+v, Error: %v", a, err)
+		}
+
+		// c is created, so it must not exist
+		c := &txnTestState{Count: 5}
+		c.StateDriver = txn
+		c.ID = "c"
+		if err := c.Write(); err != nil {
+			return err
+		}
+
+		b := &txnTestState{}
+		b.StateDriver = txn
+		if err := b.Read("b"); err != nil {
+			return err
+		}
+		return b.Clear()
+	})
+	if err != nil {
+		t.Fatalf("Update failed. Error: %s", err)
+	}
+	if d.numCommit != 1 {
+		t.Fatalf("Multi-key update committed %d times, expected once", d.numCommit)
+	}
+	if _, ok := d.kvs["b"]; ok {
+		t.Fatalf("Cleared key was not deleted")
+	}
+	if _, ok := d.kvs["c"]; !ok {
+		t.Fatalf("New key was not written")
+	}
+
+	// creating c again conflicts every time
+	err = UpdateStates(d, func(txn StateDriver) error {
+		c := &txnTestState{}
+		c.StateDriver = txn
+		c.ID = "c"
+		return c.Write()
+	})
+	if err != ErrStateChanged {
+		t.Fatalf("Creating an existing key returned %v, expected a conflict", err)
+	}
+}
+
+func TestUpdateStatesError(t *testing.T) {
+	d := &txnTestDriver{kvs: make(map[string]txnTestValue)}
+
+	err := UpdateStates(d, func(txn StateDriver) error {
+		s := &txnTestState{}
+		s.StateDriver = txn
+		return s.Read("missing")
+	})
+	if ErrIfKeyExists(err) != nil || err == nil {
+		t.Fatalf("Read of a missing key returned %v, expected key not found", err)
+	}
+	if d.numCAS != 0 || d.numCommit != 0 {
+		t.Fatalf("Failed update was committed")
+	}
+}
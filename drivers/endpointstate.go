@@ -37,6 +37,11 @@ type OperEndpointState struct {
 	IntfName    string `json:"intfName"`
 	PortName    string `json:"portName"`
 	VtepIP      string `json:"vtepIP"`
+	Mtu         int    `json:"mtu"`
+}
+
+func init() {
+	core.RegisterSchema(endpointOperPathPrefix, &OperEndpointState{})
 }
 
 // Matches matches the fields updated from configuration state
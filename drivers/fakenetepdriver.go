@@ -35,6 +35,11 @@ func (d *FakeNetEpDriver) CreateEndpoint(id string) error {
 	return core.Errorf("Not implemented")
 }
 
+// UpdateNetwork is not implemented.
+func (d *FakeNetEpDriver) UpdateNetwork(id, prevGateway, prevIPv6Gateway string) error {
+	return core.Errorf("Not implemented")
+}
+
 //UpdateEndpointGroup is not implemented.
 func (d *FakeNetEpDriver) UpdateEndpointGroup(id string) error {
 	return core.Errorf("Not implemented")
@@ -114,6 +119,11 @@ func (d *FakeNetEpDriver) GetEndpointStats() ([]byte, error) {
 	return []byte{}, core.Errorf("Not implemented")
 }
 
+// GetPolicyRuleStats is not implemented
+func (d *FakeNetEpDriver) GetPolicyRuleStats() ([]byte, error) {
+	return []byte{}, core.Errorf("Not implemented")
+}
+
 // InspectState is not implemented
 func (d *FakeNetEpDriver) InspectState() ([]byte, error) {
 	return []byte{}, core.Errorf("Not implemented")
@@ -143,3 +153,28 @@ func (d *FakeNetEpDriver) AddPolicyRule(id string) error {
 func (d *FakeNetEpDriver) DelPolicyRule(id string) error {
 	return core.Errorf("Not implemented")
 }
+
+// UpdateEncryption is not implemented
+func (d *FakeNetEpDriver) UpdateEncryption(id string) error {
+	return core.Errorf("Not implemented")
+}
+
+// GetEncryptionStatus is not implemented
+func (d *FakeNetEpDriver) GetEncryptionStatus() ([]byte, error) {
+	return []byte{}, core.Errorf("Not implemented")
+}
+
+// Reconcile is not implemented
+func (d *FakeNetEpDriver) Reconcile() error {
+	return core.Errorf("Not implemented")
+}
+
+// AddMirrorSession is not implemented
+func (d *FakeNetEpDriver) AddMirrorSession(id string) error {
+	return core.Errorf("Not implemented")
+}
+
+// DelMirrorSession is not implemented
+func (d *FakeNetEpDriver) DelMirrorSession(id string) error {
+	return core.Errorf("Not implemented")
+}
@@ -0,0 +1,50 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package linuxd
+
+import (
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+)
+
+const (
+	// StateOperPath is the path to the operations stored in state.
+	linuxOperPathPrefix = mastercfg.StateOperPath + "linux-driver/"
+	linuxOperPath       = linuxOperPathPrefix + "%s"
+
+	maxIntfRetry = 100
+	maxPortNum   = 0xffff
+
+	// names of the links of a network, suffixed with its bridge number
+	bridgePrefix = "contivbr"
+	vxlanPrefix  = "contivvx"
+	vlanPrefix   = "contivvl"
+
+	// vrf of a tenant, suffixed with its number, which is added to the base
+	// of the routing tables of the vrfs
+	vrfPrefix    = "contivvrf"
+	vrfTableBase = 0xc000
+
+	// present once br_netfilter is loaded
+	bridgeNetfilterPath = "/proc/sys/net/bridge"
+
+	defaultVxlanPort = 4789
+
+	// nftables table the policy rules are programmed in, in the inet family
+	nftTableName = "contiv"
+
+	// nftables table of the services, in the ip family
+	nftSvcTableName = "contivsvc"
+)
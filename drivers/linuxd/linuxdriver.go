@@ -0,0 +1,1181 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package linuxd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/drivers"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/utils/netutils"
+	"github.com/contiv/ofnet"
+	"github.com/vishvananda/netlink"
+)
+
+// LinuxNetwork is a network programmed in the kernel
+type LinuxNetwork struct {
+	Tenant     string `json:"tenant"`
+	Bridge     string `json:"bridge"`
+	PktTagType string `json:"pktTagType"`
+	PktTag     int    `json:"pktTag"`
+	ExtPktTag  int    `json:"extPktTag"`
+	Uplink     string `json:"uplink"` // vxlan device or vlan sub-interface of the network
+	Mtu        int    `json:"mtu"`    // mtu of the endpoints
+	Routed     bool   `json:"routed"` // vxlan network routed to the other hosts
+}
+
+// LinuxTenant is the vrf the bridges of a tenant are in
+type LinuxTenant struct {
+	Vrf   string `json:"vrf"`
+	Table int    `json:"table"` // routing table of the vrf
+}
+
+// LinuxRemoteEndpoint is an endpoint of another host, routed to its vtep
+// in the routing mode
+type LinuxRemoteEndpoint struct {
+	NetID     string `json:"netID"`
+	IPAddress string `json:"ipAddress"`
+	VtepIP    string `json:"vtepIP"`
+}
+
+// LinuxEndpoint is a local endpoint attached to a bridge
+type LinuxEndpoint struct {
+	NetID           string `json:"netID"`
+	IntfName        string `json:"intfName"` // container side of the veth pair
+	PortName        string `json:"portName"` // bridge side of the veth pair
+	EndpointGroupID int    `json:"endpointGroupId"`
+	IPAddress       string `json:"ipAddress"`
+}
+
+// LinuxDriverOperState carries operational state of the LinuxDriver.
+type LinuxDriverOperState struct {
+	core.CommonState
+
+	// used to allocate port, bridge and vrf names
+	CurrPortNum     int                               `json:"currPortNum"`
+	CurrBridgeNum   int                               `json:"currBridgeNum"`
+	CurrVrfNum      int                               `json:"currVrfNum"`
+	FwdMode         string                            `json:"fwdMode"` // forwarding mode of the networks
+	Peers           []string                          `json:"peers"`   // vteps of the other hosts
+	Tenants         map[string]*LinuxTenant           `json:"tenants"`
+	Networks        map[string]*LinuxNetwork          `json:"networks"`
+	Endpoints       map[string]*LinuxEndpoint         `json:"endpoints"`
+	RemoteEndpoints map[string]*LinuxRemoteEndpoint   `json:"remoteEndpoints"`
+	PolicyRules     map[string]*ofnet.OfnetPolicyRule `json:"policyRules"`
+	Services        map[string]*LinuxService          `json:"services"`
+}
+
+func init() {
+	core.RegisterSchema(linuxOperPathPrefix, &LinuxDriverOperState{})
+}
+
+// Write the state
+func (s *LinuxDriverOperState) Write() error {
+	key := fmt.Sprintf(linuxOperPath, s.ID)
+	return s.StateDriver.WriteState(key, s, json.Marshal)
+}
+
+// Read the state given an ID.
+func (s *LinuxDriverOperState) Read(id string) error {
+	key := fmt.Sprintf(linuxOperPath, id)
+	return s.StateDriver.ReadState(key, s, json.Unmarshal)
+}
+
+// ReadAll reads all the state
+func (s *LinuxDriverOperState) ReadAll() ([]core.State, error) {
+	return s.StateDriver.ReadAllState(linuxOperPathPrefix, s, json.Unmarshal)
+}
+
+// Clear removes the state.
+func (s *LinuxDriverOperState) Clear() error {
+	key := fmt.Sprintf(linuxOperPath, s.ID)
+	return s.StateDriver.ClearState(key)
+}
+
+// LinuxDriver implements the network driver interface with the kernel, for
+// hosts that can't run open-vswitch. Networks are linux bridges, joined to
+// the other hosts by vxlan devices or to the uplink by vlan sub-interfaces.
+// Endpoints are veth pairs in the bridges, and policy rules and services
+// are nftables rules. The bridges of each tenant are in a vrf of its own,
+// with the gateways of its networks. In the routing mode, the vxlan devices
+// are in the vrf instead of the bridges, and the remote endpoints are routed
+// to their hosts.
+type LinuxDriver struct {
+	oper      LinuxDriverOperState // Oper state of the driver
+	localIP   string               // Local IP address
+	vxlanPort int                  // udp port of the vxlan devices
+	uplink    string               // parent of the vlan sub-interfaces
+	uplinkMtu int                  // lowest mtu of the host interfaces
+	ruleset   string               // last nftables ruleset applied
+	lock      sync.Mutex           // lock for modifying shared state
+}
+
+// linkExists checks if a link exists
+func linkExists(name string) bool {
+	_, err := netlink.LinkByName(name)
+	return err == nil || !strings.Contains(err.Error(), "not found")
+}
+
+// deleteLink deletes a link if it exists
+func deleteLink(name string) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil
+		}
+		return err
+	}
+
+	return netlink.LinkDel(link)
+}
+
+// addLink creates a link and sets it up
+func addLink(link netlink.Link) error {
+	if err := netlink.LinkAdd(link); err != nil {
+		log.Errorf("Error creating link %s. Err: %v", link.Attrs().Name, err)
+		return err
+	}
+
+	return netlink.LinkSetUp(link)
+}
+
+// runCommand runs a command of the host, tests replace it
+var runCommand = func(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return core.Errorf("%s %s failed. Err: %v, output: %s",
+			name, strings.Join(args, " "), err, out)
+	}
+	return nil
+}
+
+// setIntfSysctl sets an ipv4 sysctl of an interface
+func setIntfSysctl(intf, name, value string) error {
+	path := filepath.Join("/proc/sys/net/ipv4/conf", intf, name)
+	return ioutil.WriteFile(path, []byte(value), 0644)
+}
+
+// loadBridgeNetfilter loads br_netfilter, for the bridged traffic to go
+// through the policy rules and for the replies of services to be translated
+// back
+func loadBridgeNetfilter() error {
+	if _, err := os.Stat(bridgeNetfilterPath); err == nil {
+		return nil
+	}
+	if err := runCommand("modprobe", "br_netfilter"); err != nil {
+		log.Errorf("Error loading br_netfilter. Err: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// gatewayMac returns the mac address of the gateway of a network. It is the
+// same on all hosts, so that endpoints keep it when they move.
+func gatewayMac(gateway net.IP) net.HardwareAddr {
+	ip := gateway.To4()
+	return net.HardwareAddr{0x02, 0x02, ip[0], ip[1], ip[2], ip[3]}
+}
+
+// routerMac returns the mac address of the vxlan devices of a host in the
+// routing mode
+func routerMac(vtep string) net.HardwareAddr {
+	ip := net.ParseIP(vtep).To4()
+	if ip == nil {
+		return nil
+	}
+	return net.HardwareAddr{0x02, 0x03, ip[0], ip[1], ip[2], ip[3]}
+}
+
+// peerFdbEntry returns the fdb entry that floods the broadcast and unknown
+// traffic of a vxlan device to a peer
+func peerFdbEntry(vxlanIndex int, peer string) *netlink.Neigh {
+	return &netlink.Neigh{
+		LinkIndex:    vxlanIndex,
+		Family:       syscall.AF_BRIDGE,
+		State:        netlink.NUD_PERMANENT,
+		Flags:        netlink.NTF_SELF,
+		IP:           net.ParseIP(peer),
+		HardwareAddr: make(net.HardwareAddr, 6),
+	}
+}
+
+// peerRouterEntries returns the neighbor entry of the router of a peer
+// and the fdb entry that sends its mac address to the peer, in the routing
+// mode
+func peerRouterEntries(vxlanIndex int, peer string) (*netlink.Neigh, *netlink.Neigh, error) {
+	mac := routerMac(peer)
+	if mac == nil {
+		return nil, nil, core.Errorf("Invalid peer %q", peer)
+	}
+
+	neigh := &netlink.Neigh{
+		LinkIndex:    vxlanIndex,
+		Family:       netlink.FAMILY_V4,
+		State:        netlink.NUD_PERMANENT,
+		IP:           net.ParseIP(peer),
+		HardwareAddr: mac,
+	}
+	fdb := &netlink.Neigh{
+		LinkIndex:    vxlanIndex,
+		Family:       syscall.AF_BRIDGE,
+		State:        netlink.NUD_PERMANENT,
+		Flags:        netlink.NTF_SELF,
+		IP:           net.ParseIP(peer),
+		HardwareAddr: mac,
+	}
+	return neigh, fdb, nil
+}
+
+// updatePeer adds or deletes the fdb entry of a peer on a vxlan network,
+// and its router entries on a routed one
+func (d *LinuxDriver) updatePeer(nw *LinuxNetwork, peer string, add bool) error {
+	link, err := netlink.LinkByName(nw.Uplink)
+	if err != nil {
+		return err
+	}
+
+	if nw.Routed {
+		neigh, fdb, err := peerRouterEntries(link.Attrs().Index, peer)
+		if err != nil {
+			return err
+		}
+		if add {
+			if err := netlink.NeighSet(neigh); err != nil {
+				return err
+			}
+			return netlink.NeighAppend(fdb)
+		}
+		if err := netlink.NeighDel(neigh); err != nil {
+			return err
+		}
+		return netlink.NeighDel(fdb)
+	}
+
+	if add {
+		return netlink.NeighAppend(peerFdbEntry(link.Attrs().Index, peer))
+	}
+	return netlink.NeighDel(peerFdbEntry(link.Attrs().Index, peer))
+}
+
+// remoteEndpointRoute returns the route of a remote endpoint to its vtep,
+// through the vxlan device of a routed network
+func remoteEndpointRoute(vxlanIndex, table int, ep *LinuxRemoteEndpoint) (*netlink.Route, error) {
+	ip := net.ParseIP(ep.IPAddress)
+	vtep := net.ParseIP(ep.VtepIP)
+	if ip == nil || ip.To4() == nil || vtep == nil {
+		return nil, core.Errorf("Invalid address %q or vtep %q of remote endpoint", ep.IPAddress, ep.VtepIP)
+	}
+
+	route := &netlink.Route{
+		LinkIndex: vxlanIndex,
+		Dst:       &net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)},
+		Gw:        vtep,
+		Table:     table,
+	}
+	route.SetFlag(netlink.FLAG_ONLINK)
+	return route, nil
+}
+
+// updateRemoteRoute adds or deletes the route of a remote endpoint on its
+// routed network
+func (d *LinuxDriver) updateRemoteRoute(nw *LinuxNetwork, ep *LinuxRemoteEndpoint, add bool) error {
+	link, err := netlink.LinkByName(nw.Uplink)
+	if err != nil {
+		return err
+	}
+	route, err := remoteEndpointRoute(link.Attrs().Index, d.oper.Tenants[nw.Tenant].Table, ep)
+	if err != nil {
+		return err
+	}
+
+	if add {
+		if err := netlink.RouteAdd(route); err != nil && err != syscall.EEXIST {
+			return err
+		}
+		return nil
+	}
+	return netlink.RouteDel(route)
+}
+
+// tenantVrf returns the vrf of a tenant, and creates it for the first
+// network of the tenant
+func (d *LinuxDriver) tenantVrf(tenant string) (netlink.Link, error) {
+	t, found := d.oper.Tenants[tenant]
+	if !found {
+		d.oper.CurrVrfNum++
+		t = &LinuxTenant{
+			Vrf:   fmt.Sprintf("%s%d", vrfPrefix, d.oper.CurrVrfNum),
+			Table: vrfTableBase + d.oper.CurrVrfNum,
+		}
+		// the vendored netlink has no vrf links
+		err := runCommand("ip", "link", "add", t.Vrf, "type", "vrf", "table", strconv.Itoa(t.Table))
+		if err != nil {
+			log.Errorf("Error creating vrf of tenant %s. Err: %v", tenant, err)
+			return nil, err
+		}
+		d.oper.Tenants[tenant] = t
+	}
+
+	vrf, err := netlink.LinkByName(t.Vrf)
+	if err != nil {
+		log.Errorf("Vrf %s of tenant %s not found. Err: %v", t.Vrf, tenant, err)
+		return nil, err
+	}
+
+	return vrf, netlink.LinkSetUp(vrf)
+}
+
+// releaseTenantVrf deletes the vrf of a tenant once it has no network
+func (d *LinuxDriver) releaseTenantVrf(tenant string) error {
+	t, found := d.oper.Tenants[tenant]
+	if !found {
+		return nil
+	}
+	for _, nw := range d.oper.Networks {
+		if nw.Tenant == tenant {
+			return nil
+		}
+	}
+
+	if err := deleteLink(t.Vrf); err != nil {
+		log.Errorf("Error deleting vrf %s of tenant %s. Err: %v", t.Vrf, tenant, err)
+		return err
+	}
+	delete(d.oper.Tenants, tenant)
+
+	return nil
+}
+
+// getIntfName returns the names of the next free veth pair
+func (d *LinuxDriver) getIntfName() (string, string, error) {
+	for i := 0; i < maxIntfRetry; i++ {
+		d.oper.CurrPortNum++
+		if d.oper.CurrPortNum >= maxPortNum {
+			d.oper.CurrPortNum = 1 // roll over
+		}
+		intfName := fmt.Sprintf("vport%d", d.oper.CurrPortNum)
+		portName := "v" + intfName
+
+		if !linkExists(intfName) && !linkExists(portName) {
+			return intfName, portName, d.oper.Write()
+		}
+	}
+
+	return "", "", core.Errorf("Could not get intf name. Max retry exceeded")
+}
+
+// Init restores the driver's state, and applies its policy rules
+func (d *LinuxDriver) Init(info *core.InstanceInfo) error {
+	if info == nil || info.StateDriver == nil {
+		return core.Errorf("Invalid arguments. instance-info: %+v", info)
+	}
+
+	log.Infof("Initializing linuxdriver")
+
+	d.oper.StateDriver = info.StateDriver
+	d.localIP = info.VtepIP
+	d.vxlanPort = info.VxlanUDPPort
+	if d.vxlanPort == 0 {
+		d.vxlanPort = defaultVxlanPort
+	}
+	if len(info.UplinkIntf) != 0 {
+		if len(info.UplinkIntf) > 1 {
+			log.Warnf("linux driver only uses the first uplink %s", info.UplinkIntf[0])
+		}
+		d.uplink = info.UplinkIntf[0]
+	}
+
+	uplinkMtu, err := netutils.GetHostLowestLinkMtu()
+	if err != nil {
+		log.Errorf("Failed to get the host mtu. Err: %v", err)
+		return err
+	}
+	d.uplinkMtu = uplinkMtu
+
+	err = d.oper.Read(info.HostLabel)
+	if core.ErrIfKeyExists(err) != nil {
+		log.Errorf("Failed to read driver oper state for key %q. Error: %s",
+			info.HostLabel, err)
+		return err
+	} else if err != nil {
+		// create the oper state as it is first time start up
+		d.oper.ID = info.HostLabel
+	}
+	if d.oper.Networks == nil {
+		d.oper.Networks = make(map[string]*LinuxNetwork)
+		d.oper.Endpoints = make(map[string]*LinuxEndpoint)
+		d.oper.PolicyRules = make(map[string]*ofnet.OfnetPolicyRule)
+	}
+	if d.oper.Tenants == nil {
+		d.oper.Tenants = make(map[string]*LinuxTenant)
+		d.oper.RemoteEndpoints = make(map[string]*LinuxRemoteEndpoint)
+		d.oper.Services = make(map[string]*LinuxService)
+	}
+	if err = d.setFwdMode(info.FwdMode); err != nil {
+		return err
+	}
+	if err = loadBridgeNetfilter(); err != nil {
+		return err
+	}
+	if err = d.oper.Write(); err != nil {
+		return err
+	}
+
+	return d.syncPolicy()
+}
+
+// setFwdMode sets the forwarding mode of the networks. It can't change while
+// there are networks, they are connected differently in each mode.
+func (d *LinuxDriver) setFwdMode(fwdMode string) error {
+	if fwdMode == "" {
+		fwdMode = "bridge"
+	}
+	currMode := d.oper.FwdMode
+	if currMode == "" && len(d.oper.Networks) != 0 {
+		currMode = "bridge"
+	}
+
+	if currMode != "" && currMode != fwdMode && len(d.oper.Networks) != 0 {
+		return core.Errorf("linux driver networks are in the %s forwarding mode, delete them before changing it to %s",
+			currMode, fwdMode)
+	}
+	d.oper.FwdMode = fwdMode
+
+	return nil
+}
+
+// Deinit leaves the bridges and rules in place, for the driver to find
+// them when it restarts
+func (d *LinuxDriver) Deinit() {
+	log.Infof("Cleaning up linuxdriver")
+}
+
+// CreateNetwork creates the bridge of a network, and connects it to the
+// other hosts or to the uplink
+func (d *LinuxDriver) CreateNetwork(id string) error {
+	cfgNw := mastercfg.CfgNetworkState{}
+	cfgNw.StateDriver = d.oper.StateDriver
+	err := cfgNw.Read(id)
+	if err != nil {
+		log.Errorf("Failed to read net %s \n", cfgNw.ID)
+		return err
+	}
+	log.Infof("create net %+v \n", cfgNw)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, found := d.oper.Networks[id]; found {
+		log.Infof("Network %s already exists", id)
+		return nil
+	}
+
+	if cfgNw.PktTagType != "vxlan" && cfgNw.PktTagType != "vlan" {
+		return core.Errorf("linux driver doesn't support %s networks", cfgNw.PktTagType)
+	}
+	if cfgNw.PktTagType == "vlan" && d.uplink == "" {
+		return core.Errorf("vlan network %s needs an uplink", id)
+	}
+	vrf, err := d.tenantVrf(cfgNw.Tenant)
+	if err != nil {
+		return err
+	}
+
+	d.oper.CurrBridgeNum++
+	nw := &LinuxNetwork{
+		Tenant:     cfgNw.Tenant,
+		Bridge:     fmt.Sprintf("%s%d", bridgePrefix, d.oper.CurrBridgeNum),
+		PktTagType: cfgNw.PktTagType,
+		PktTag:     cfgNw.PktTag,
+		ExtPktTag:  cfgNw.ExtPktTag,
+		Mtu:        netutils.GetNetworkMtu(cfgNw.Mtu, d.uplinkMtu, cfgNw.PktTagType),
+		Routed:     cfgNw.PktTagType == "vxlan" && d.oper.FwdMode == "routing",
+	}
+	bridge := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{
+		Name:        nw.Bridge,
+		MasterIndex: vrf.Attrs().Index,
+	}}
+	if err = addLink(bridge); err != nil {
+		d.releaseTenantVrf(cfgNw.Tenant)
+		d.oper.Write()
+		return err
+	}
+
+	// the network is saved before connecting it, for DeleteNetwork to undo
+	// what was done on failures
+	d.oper.Networks[id] = nw
+	err = d.connectNetwork(id, nw, bridge, vrf, &cfgNw)
+	if werr := d.oper.Write(); err == nil {
+		err = werr
+	}
+
+	return err
+}
+
+// connectNetwork connects the bridge of a vxlan network to the other hosts,
+// and the one of a vlan network to the uplink
+func (d *LinuxDriver) connectNetwork(id string, nw *LinuxNetwork, bridge *netlink.Bridge,
+	vrf netlink.Link, cfgNw *mastercfg.CfgNetworkState) error {
+	// bridged traffic goes through the inet table of the policy rules and
+	// services
+	err := runCommand("ip", "link", "set", "dev", nw.Bridge, "type", "bridge",
+		"nf_call_iptables", "1", "nf_call_ip6tables", "1")
+	if err != nil {
+		log.Errorf("Error enabling netfilter on bridge %s. Err: %v", nw.Bridge, err)
+		return err
+	}
+
+	if nw.PktTagType == "vlan" {
+		parent, err := netlink.LinkByName(d.uplink)
+		if err != nil {
+			log.Errorf("Uplink %s not found. Err: %v", d.uplink, err)
+			return err
+		}
+
+		nw.Uplink = fmt.Sprintf("%s%d", vlanPrefix, d.oper.CurrBridgeNum)
+		return addLink(&netlink.Vlan{
+			LinkAttrs: netlink.LinkAttrs{
+				Name:        nw.Uplink,
+				ParentIndex: parent.Attrs().Index,
+				MasterIndex: bridge.Attrs().Index,
+			},
+			VlanId: nw.PktTag,
+		})
+	}
+
+	nw.Uplink = fmt.Sprintf("%s%d", vxlanPrefix, d.oper.CurrBridgeNum)
+	if nw.Routed {
+		return d.connectRoutedNetwork(id, nw, bridge, vrf, cfgNw)
+	}
+	err = addLink(&netlink.Vxlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        nw.Uplink,
+			MTU:         nw.Mtu,
+			MasterIndex: bridge.Attrs().Index,
+		},
+		VxlanId:  nw.ExtPktTag,
+		SrcAddr:  net.ParseIP(d.localIP),
+		Port:     d.vxlanPort,
+		Learning: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, peer := range d.oper.Peers {
+		if err := d.updatePeer(nw, peer, true); err != nil {
+			log.Errorf("Error adding peer %s to net %s. Err: %v", peer, id, err)
+			return err
+		}
+	}
+
+	return setBridgeGateway(id, bridge, cfgNw)
+}
+
+// connectRoutedNetwork routes a vxlan network to the other hosts. Its vxlan
+// device is in the vrf of the tenant, with the router mac of the host, and
+// the bridge answers the arp requests of the endpoints for the remote ones.
+func (d *LinuxDriver) connectRoutedNetwork(id string, nw *LinuxNetwork, bridge *netlink.Bridge,
+	vrf netlink.Link, cfgNw *mastercfg.CfgNetworkState) error {
+	mac := routerMac(d.localIP)
+	if mac == nil {
+		return core.Errorf("Invalid vtep %q for the routing mode", d.localIP)
+	}
+	vxlan := &netlink.Vxlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        nw.Uplink,
+			MTU:         nw.Mtu,
+			MasterIndex: vrf.Attrs().Index,
+		},
+		VxlanId: nw.ExtPktTag,
+		SrcAddr: net.ParseIP(d.localIP),
+		Port:    d.vxlanPort,
+	}
+	if err := addLink(vxlan); err != nil {
+		return err
+	}
+	if err := netlink.LinkSetHardwareAddr(vxlan, mac); err != nil {
+		return err
+	}
+
+	// the endpoints of the other networks of the tenant come in on this
+	// device too, the reverse path check must be loose
+	if err := setIntfSysctl(nw.Uplink, "rp_filter", "2"); err != nil {
+		log.Errorf("Error setting rp_filter of %s. Err: %v", nw.Uplink, err)
+		return err
+	}
+	if err := setIntfSysctl(nw.Bridge, "proxy_arp", "1"); err != nil {
+		log.Errorf("Error setting proxy_arp of %s. Err: %v", nw.Bridge, err)
+		return err
+	}
+
+	for _, peer := range d.oper.Peers {
+		if err := d.updatePeer(nw, peer, true); err != nil {
+			log.Errorf("Error adding peer %s to net %s. Err: %v", peer, id, err)
+			return err
+		}
+	}
+	if err := setBridgeGateway(id, bridge, cfgNw); err != nil {
+		return err
+	}
+
+	for epID, ep := range d.oper.RemoteEndpoints {
+		if ep.NetID != id {
+			continue
+		}
+		if err := d.updateRemoteRoute(nw, ep, true); err != nil {
+			log.Errorf("Error adding route of remote endpoint %s. Err: %v", epID, err)
+		}
+	}
+
+	return nil
+}
+
+// setBridgeGateway adds the gateway of a vxlan network to its bridge. Every
+// host is the gateway of its endpoints, with the same mac address.
+func setBridgeGateway(id string, bridge netlink.Link, cfgNw *mastercfg.CfgNetworkState) error {
+	if cfgNw.Gateway == "" {
+		return nil
+	}
+
+	gateway := net.ParseIP(cfgNw.Gateway)
+	if gateway == nil || gateway.To4() == nil {
+		return core.Errorf("Invalid gateway %q of net %s", cfgNw.Gateway, id)
+	}
+	if err := netlink.LinkSetHardwareAddr(bridge, gatewayMac(gateway)); err != nil {
+		return err
+	}
+	addr := &netlink.Addr{IPNet: &net.IPNet{
+		IP:   gateway,
+		Mask: net.CIDRMask(int(cfgNw.SubnetLen), 32),
+	}}
+	if err := netlink.AddrAdd(bridge, addr); err != nil {
+		log.Errorf("Error adding gateway %s to net %s. Err: %v", cfgNw.Gateway, id, err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateNetwork replaces the gateway address of the bridge of a vxlan
+// network with the one of the updated subnet and gateway
+func (d *LinuxDriver) UpdateNetwork(id, prevGateway, prevIPv6Gateway string) error {
+	cfgNw := mastercfg.CfgNetworkState{}
+	cfgNw.StateDriver = d.oper.StateDriver
+	err := cfgNw.Read(id)
+	if err != nil {
+		log.Errorf("Failed to read net %s \n", id)
+		return err
+	}
+	log.Infof("update net %+v \n", cfgNw)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	nw, found := d.oper.Networks[id]
+	if !found {
+		return core.Errorf("Network %s not found", id)
+	}
+	if nw.PktTagType != "vxlan" {
+		return nil
+	}
+
+	bridge, err := netlink.LinkByName(nw.Bridge)
+	if err != nil {
+		log.Errorf("Bridge %s of net %s not found. Err: %v", nw.Bridge, id, err)
+		return err
+	}
+	addrs, err := netlink.AddrList(bridge, netlink.FAMILY_V4)
+	if err != nil {
+		return err
+	}
+	for idx := range addrs {
+		if err := netlink.AddrDel(bridge, &addrs[idx]); err != nil {
+			log.Errorf("Error deleting address %s of net %s. Err: %v", addrs[idx].IPNet, id, err)
+			return err
+		}
+	}
+
+	return setBridgeGateway(id, bridge, &cfgNw)
+}
+
+// DeleteNetwork deletes the bridge of a network and its uplink
+func (d *LinuxDriver) DeleteNetwork(id, subnet, nwType, encap string, pktTag, extPktTag int, gateway string, tenant string) error {
+	log.Infof("delete net %s, nwType %s, encap %s, tags: %d/%d", id, nwType, encap, pktTag, extPktTag)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	nw, found := d.oper.Networks[id]
+	if !found {
+		log.Infof("Network %s not found", id)
+		return nil
+	}
+
+	if nw.Uplink != "" {
+		if err := deleteLink(nw.Uplink); err != nil {
+			log.Errorf("Error deleting uplink %s of net %s. Err: %v", nw.Uplink, id, err)
+		}
+	}
+	if err := deleteLink(nw.Bridge); err != nil {
+		log.Errorf("Error deleting bridge %s of net %s. Err: %v", nw.Bridge, id, err)
+		return err
+	}
+
+	delete(d.oper.Networks, id)
+	if err := d.releaseTenantVrf(nw.Tenant); err != nil {
+		return err
+	}
+	return d.oper.Write()
+}
+
+// CreateEndpoint creates a veth pair for an endpoint in the bridge of its
+// network
+func (d *LinuxDriver) CreateEndpoint(id string) error {
+	cfgEp := &mastercfg.CfgEndpointState{}
+	cfgEp.StateDriver = d.oper.StateDriver
+	err := cfgEp.Read(id)
+	if err != nil {
+		return err
+	}
+
+	operEp := &drivers.OperEndpointState{}
+	operEp.StateDriver = d.oper.StateDriver
+	err = operEp.Read(id)
+	if core.ErrIfKeyExists(err) != nil {
+		return err
+	} else if err == nil {
+		// check if oper state matches cfg state. In case of mismatch cleanup
+		// up the EP and continue add new one. In case of match just return.
+		if operEp.Matches(cfgEp) {
+			log.Printf("Found matching oper state for ep %s, noop", id)
+			return nil
+		}
+		log.Printf("Found mismatching oper state for Ep, cleaning it. Config: %+v, Oper: %+v",
+			cfgEp, operEp)
+		d.DeleteEndpoint(operEp.ID)
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	nw, found := d.oper.Networks[cfgEp.NetID]
+	if !found {
+		return core.Errorf("Network %s of endpoint %s not found", cfgEp.NetID, id)
+	}
+	bridge, err := netlink.LinkByName(nw.Bridge)
+	if err != nil {
+		return err
+	}
+
+	intfName, portName, err := d.getIntfName()
+	if err != nil {
+		return err
+	}
+	log.Infof("Creating Veth pairs with name: %s, %s", intfName, portName)
+	err = addLink(&netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: portName, MTU: nw.Mtu, MasterIndex: bridge.Attrs().Index},
+		PeerName:  intfName,
+	})
+	if err != nil {
+		return err
+	}
+	if cfgEp.MacAddress != "" {
+		if err = netutils.SetInterfaceMac(intfName, cfgEp.MacAddress); err != nil {
+			log.Errorf("Error setting interface Mac %s on port %s", cfgEp.MacAddress, intfName)
+			deleteLink(portName)
+			return err
+		}
+	}
+
+	d.oper.Endpoints[id] = &LinuxEndpoint{
+		NetID:           cfgEp.NetID,
+		IntfName:        intfName,
+		PortName:        portName,
+		EndpointGroupID: cfgEp.EndpointGroupID,
+		IPAddress:       cfgEp.IPAddress,
+	}
+	if err = d.oper.Write(); err != nil {
+		return err
+	}
+
+	// Save the oper state
+	operEp = &drivers.OperEndpointState{
+		NetID:       cfgEp.NetID,
+		EndpointID:  cfgEp.EndpointID,
+		ServiceName: cfgEp.ServiceName,
+		IPAddress:   cfgEp.IPAddress,
+		IPv6Address: cfgEp.IPv6Address,
+		MacAddress:  cfgEp.MacAddress,
+		IntfName:    cfgEp.IntfName,
+		PortName:    intfName,
+		HomingHost:  cfgEp.HomingHost,
+		VtepIP:      cfgEp.VtepIP,
+		Mtu:         nw.Mtu}
+	operEp.StateDriver = d.oper.StateDriver
+	operEp.ID = id
+	if err = operEp.Write(); err != nil {
+		return err
+	}
+
+	// the endpoint's address joins the sets of its group
+	if err := d.syncPolicy(); err != nil {
+		log.Errorf("Error updating policy rules for endpoint %s. Err: %v", id, err)
+	}
+
+	return nil
+}
+
+// UpdateEndpointGroup is a noop, the linux driver doesn't support bandwidth
+// limits yet
+func (d *LinuxDriver) UpdateEndpointGroup(id string) error {
+	log.Infof("Received endpoint group update for %s", id)
+	return nil
+}
+
+// DeleteEndpoint deletes the veth pair of an endpoint
+func (d *LinuxDriver) DeleteEndpoint(id string) error {
+	epOper := drivers.OperEndpointState{}
+	epOper.StateDriver = d.oper.StateDriver
+	err := epOper.Read(id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		epOper.Clear()
+	}()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	ep, found := d.oper.Endpoints[id]
+	if !found {
+		log.Infof("Endpoint %s not found", id)
+		return nil
+	}
+
+	log.Infof("Deleting Veth pairs with name: %s, %s", ep.IntfName, ep.PortName)
+	if err = deleteLink(ep.PortName); err != nil {
+		log.Errorf("Error deleting veth pair %s. Err: %v", ep.PortName, err)
+	}
+
+	delete(d.oper.Endpoints, id)
+	if err = d.oper.Write(); err != nil {
+		return err
+	}
+
+	if err := d.syncPolicy(); err != nil {
+		log.Errorf("Error updating policy rules for endpoint %s. Err: %v", id, err)
+	}
+
+	return nil
+}
+
+// CreateRemoteEndpoint adds the address of a remote endpoint to the sets
+// of its group. In the bridge mode, the bridges learn its mac address from
+// the vxlan devices. In the routing mode, it is routed to its host.
+func (d *LinuxDriver) CreateRemoteEndpoint(id string) error {
+	cfgEp := &mastercfg.CfgEndpointState{}
+	cfgEp.StateDriver = d.oper.StateDriver
+	if err := cfgEp.Read(id); err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.oper.FwdMode == "routing" && cfgEp.IPAddress != "" && cfgEp.VtepIP != "" {
+		ep := &LinuxRemoteEndpoint{
+			NetID:     cfgEp.NetID,
+			IPAddress: cfgEp.IPAddress,
+			VtepIP:    cfgEp.VtepIP,
+		}
+		// the route is added with the network when it isn't created yet
+		if nw, found := d.oper.Networks[ep.NetID]; found && nw.Routed {
+			if err := d.updateRemoteRoute(nw, ep, true); err != nil {
+				log.Errorf("Error adding route of remote endpoint %s. Err: %v", id, err)
+				return err
+			}
+		}
+		d.oper.RemoteEndpoints[id] = ep
+		if err := d.oper.Write(); err != nil {
+			return err
+		}
+	}
+
+	return d.syncPolicy()
+}
+
+// DeleteRemoteEndpoint removes the address of a remote endpoint from the
+// sets of its group, and its route in the routing mode
+func (d *LinuxDriver) DeleteRemoteEndpoint(id string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if ep, found := d.oper.RemoteEndpoints[id]; found {
+		if nw, found := d.oper.Networks[ep.NetID]; found && nw.Routed {
+			if err := d.updateRemoteRoute(nw, ep, false); err != nil {
+				log.Errorf("Error deleting route of remote endpoint %s. Err: %v", id, err)
+			}
+		}
+		delete(d.oper.RemoteEndpoints, id)
+		if err := d.oper.Write(); err != nil {
+			return err
+		}
+	}
+
+	return d.syncPolicy()
+}
+
+// CreateHostAccPort is not supported by the linux driver
+func (d *LinuxDriver) CreateHostAccPort(id, a string, nw int) (string, error) {
+	return "", core.Errorf("linux driver doesn't support host access ports")
+}
+
+// DeleteHostAccPort is not supported by the linux driver
+func (d *LinuxDriver) DeleteHostAccPort(id string) (err error) {
+	return core.Errorf("linux driver doesn't support host access ports")
+}
+
+// AddPeerHost adds the fdb entries of a new host to all the vxlan networks
+func (d *LinuxDriver) AddPeerHost(node core.ServiceInfo) error {
+	// Nothing to do if this is our own IP
+	if node.HostAddr == d.localIP {
+		return nil
+	}
+
+	log.Infof("CreatePeerHost for %+v", node)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, peer := range d.oper.Peers {
+		if peer == node.HostAddr {
+			return nil
+		}
+	}
+	d.oper.Peers = append(d.oper.Peers, node.HostAddr)
+
+	for id, nw := range d.oper.Networks {
+		if nw.PktTagType != "vxlan" {
+			continue
+		}
+		if err := d.updatePeer(nw, node.HostAddr, true); err != nil {
+			log.Errorf("Error adding peer %s to net %s. Err: %v", node.HostAddr, id, err)
+		}
+	}
+
+	return d.oper.Write()
+}
+
+// DeletePeerHost deletes the fdb entries of a host
+func (d *LinuxDriver) DeletePeerHost(node core.ServiceInfo) error {
+	// Nothing to do if this is our own IP
+	if node.HostAddr == d.localIP {
+		return nil
+	}
+
+	log.Infof("DeletePeerHost for %+v", node)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	found := false
+	for i, peer := range d.oper.Peers {
+		if peer == node.HostAddr {
+			d.oper.Peers = append(d.oper.Peers[:i], d.oper.Peers[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	for id, nw := range d.oper.Networks {
+		if nw.PktTagType != "vxlan" {
+			continue
+		}
+		if err := d.updatePeer(nw, node.HostAddr, false); err != nil {
+			log.Errorf("Error deleting peer %s from net %s. Err: %v", node.HostAddr, id, err)
+		}
+	}
+
+	return d.oper.Write()
+}
+
+// AddMaster is not implemented
+func (d *LinuxDriver) AddMaster(node core.ServiceInfo) error {
+	log.Infof("Not implemented")
+	return nil
+}
+
+// DeleteMaster is not implemented
+func (d *LinuxDriver) DeleteMaster(node core.ServiceInfo) error {
+	log.Infof("Not implemented")
+	return nil
+}
+
+// AddBgp is not implemented.
+func (d *LinuxDriver) AddBgp(id string) (err error) {
+	log.Infof("Not implemented")
+	return nil
+}
+
+// DeleteBgp is not implemented.
+func (d *LinuxDriver) DeleteBgp(id string) (err error) {
+	log.Infof("Not implemented")
+	return nil
+}
+
+// LinuxEndpointStats are the counters of the bridge side of the veth pair
+// of an endpoint. Packets the endpoint sends are rx packets of the port.
+type LinuxEndpointStats struct {
+	EndpointID string `json:"endpointID"`
+	NetID      string `json:"netID"`
+	IPAddress  string `json:"ipAddress"`
+	PortName   string `json:"portName"`
+	RxPackets  uint64 `json:"rxPackets"`
+	RxBytes    uint64 `json:"rxBytes"`
+	RxDropped  uint64 `json:"rxDropped"`
+	TxPackets  uint64 `json:"txPackets"`
+	TxBytes    uint64 `json:"txBytes"`
+	TxDropped  uint64 `json:"txDropped"`
+}
+
+// GetEndpointStats returns the link counters of the local endpoints
+func (d *LinuxDriver) GetEndpointStats() ([]byte, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	epStats := make(map[string]*LinuxEndpointStats)
+	for id, ep := range d.oper.Endpoints {
+		link, err := netlink.LinkByName(ep.PortName)
+		if err != nil {
+			log.Errorf("Error getting stats of endpoint %s. Err: %v", id, err)
+			return []byte{}, err
+		}
+
+		stats := &LinuxEndpointStats{
+			EndpointID: id,
+			NetID:      ep.NetID,
+			IPAddress:  ep.IPAddress,
+			PortName:   ep.PortName,
+		}
+		if counters := link.Attrs().Statistics; counters != nil {
+			stats.RxPackets = uint64(counters.RxPackets)
+			stats.RxBytes = uint64(counters.RxBytes)
+			stats.RxDropped = uint64(counters.RxDropped)
+			stats.TxPackets = uint64(counters.TxPackets)
+			stats.TxBytes = uint64(counters.TxBytes)
+			stats.TxDropped = uint64(counters.TxDropped)
+		}
+		epStats[id] = stats
+	}
+
+	jsonStats, err := json.Marshal(epStats)
+	if err != nil {
+		log.Errorf("Error encoding epstats. Err: %v", err)
+		return jsonStats, err
+	}
+
+	return jsonStats, nil
+}
+
+// InspectState returns the networks, endpoints and policy rules of the
+// driver
+func (d *LinuxDriver) InspectState() ([]byte, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	jsonState, err := json.Marshal(&d.oper)
+	if err != nil {
+		log.Errorf("Error encoding linux driver state. Err: %v", err)
+		return []byte{}, err
+	}
+
+	return jsonState, nil
+}
+
+// InspectBgp is not implemented
+func (d *LinuxDriver) InspectBgp() ([]byte, error) {
+	log.Infof("Not implemented")
+	return []byte{}, nil
+}
+
+// GlobalConfigUpdate changes the forwarding mode, when there is no network
+func (d *LinuxDriver) GlobalConfigUpdate(inst core.InstanceInfo) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err := d.setFwdMode(inst.FwdMode); err != nil {
+		return err
+	}
+	return d.oper.Write()
+}
+
+// InspectNameserver is not implemented
+func (d *LinuxDriver) InspectNameserver() ([]byte, error) {
+	log.Infof("Not implemented")
+	return []byte{}, nil
+}
+
+// UpdateEncryption fails when the tunnels are to be encrypted, the linux
+// driver doesn't support tunnel encryption
+func (d *LinuxDriver) UpdateEncryption(id string) error {
+	encCfg := mastercfg.CfgEncryptionState{}
+	encCfg.StateDriver = d.oper.StateDriver
+	if err := encCfg.Read(id); err == nil && encCfg.Enabled() {
+		return core.Errorf("tunnel encryption is not supported by the linux driver")
+	}
+
+	return nil
+}
+
+// GetEncryptionStatus returns no tunnel, the linux driver doesn't support
+// tunnel encryption
+func (d *LinuxDriver) GetEncryptionStatus() ([]byte, error) {
+	return json.Marshal([]mastercfg.EncryptionPeerStatus{})
+}
+
+// Reconcile is not implemented
+func (d *LinuxDriver) Reconcile() error {
+	log.Infof("Not implemented")
+	return nil
+}
+
+// AddMirrorSession fails, the linux driver doesn't support traffic mirroring
+func (d *LinuxDriver) AddMirrorSession(id string) error {
+	return core.Errorf("traffic mirroring is not supported by the linux driver")
+}
+
+// DelMirrorSession is a no-op, the linux driver never programs mirror sessions
+func (d *LinuxDriver) DelMirrorSession(id string) error {
+	return nil
+}
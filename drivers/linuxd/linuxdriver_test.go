@@ -0,0 +1,616 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package linuxd
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/drivers"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/state"
+	"github.com/contiv/ofnet"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	testHostLabel = "testHost"
+	testVtepIP    = "10.0.0.1"
+	testPeerIP    = "10.0.0.2"
+	testUplink    = "contivtestup"
+	testNetID     = "net1.default"
+	testVlanNetID = "net2.default"
+	testTenNetID  = "net3.tenant2"
+	testEpID      = "net1.default-ep1"
+	testGroupID   = 10
+)
+
+// testRulesets records the scripts applied with nft, which isn't needed to
+// test the driver
+var testRulesets []string
+
+func init() {
+	nftCommand = func(stdin string, args ...string) ([]byte, error) {
+		if stdin != "" {
+			testRulesets = append(testRulesets, stdin)
+		}
+		return []byte{}, nil
+	}
+}
+
+func initLinuxDriver(t *testing.T, fwdMode string) (*LinuxDriver, func()) {
+	if os.Getuid() != 0 {
+		t.Skip("creating links needs root")
+	}
+	uplink := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: testUplink}}
+	if err := netlink.LinkAdd(uplink); err != nil {
+		t.Skipf("Error creating uplink %s. Err: %v", testUplink, err)
+	}
+
+	stateDriver := &state.FakeStateDriver{}
+	stateDriver.Init(nil)
+	if err := createCommonState(stateDriver); err != nil {
+		t.Fatalf("common state creation failed. Error: %s", err)
+	}
+
+	testRulesets = nil
+	driver := &LinuxDriver{}
+	err := driver.Init(&core.InstanceInfo{HostLabel: testHostLabel, VtepIP: testVtepIP,
+		UplinkIntf: []string{testUplink}, FwdMode: fwdMode, StateDriver: stateDriver})
+	if err != nil {
+		t.Fatalf("driver init failed. Error: %s", err)
+	}
+
+	return driver, func() {
+		driver.Deinit()
+		for _, nw := range driver.oper.Networks {
+			deleteLink(nw.Uplink)
+			deleteLink(nw.Bridge)
+		}
+		for _, ep := range driver.oper.Endpoints {
+			deleteLink(ep.PortName)
+		}
+		for _, tenant := range driver.oper.Tenants {
+			deleteLink(tenant.Vrf)
+		}
+		deleteLink(testUplink)
+	}
+}
+
+// skipWithoutVrf skips the tests that create networks, when the kernel
+// can't create their vrfs
+func skipWithoutVrf(t *testing.T) {
+	if err := runCommand("ip", "link", "add", "contivtestvrf", "type", "vrf", "table", "4000"); err != nil {
+		t.Skipf("kernel doesn't support vrf links. Err: %v", err)
+	}
+	deleteLink("contivtestvrf")
+}
+
+func createCommonState(stateDriver core.StateDriver) error {
+	for _, nw := range []*mastercfg.CfgNetworkState{
+		{Tenant: "default", NetworkName: "net1", PktTagType: "vxlan", PktTag: 1, ExtPktTag: 10001,
+			Gateway: "20.1.1.254", SubnetLen: 24},
+		{Tenant: "default", NetworkName: "net2", PktTagType: "vlan", PktTag: 100},
+		{Tenant: "tenant2", NetworkName: "net3", PktTagType: "vxlan", PktTag: 2, ExtPktTag: 10002,
+			Gateway: "20.1.1.254", SubnetLen: 24},
+	} {
+		nw.StateDriver = stateDriver
+		nw.ID = nw.NetworkName + "." + nw.Tenant
+		if err := nw.Write(); err != nil {
+			return err
+		}
+	}
+
+	for _, ep := range []*mastercfg.CfgEndpointState{
+		{NetID: testNetID, EndpointID: "ep1", IPAddress: "20.1.1.1", HomingHost: testHostLabel,
+			MacAddress: "02:02:14:01:01:01", EndpointGroupID: testGroupID},
+		{NetID: testNetID, EndpointID: "ep2", IPAddress: "20.1.1.2", HomingHost: "otherHost",
+			VtepIP: testPeerIP, EndpointGroupID: testGroupID + 1},
+	} {
+		ep.StateDriver = stateDriver
+		ep.ID = ep.NetID + "-" + ep.EndpointID
+		if err := ep.Write(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// peerFdbEntries returns the peers of the fdb entries of a vxlan device
+func peerFdbEntries(t *testing.T, name string) []string {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		t.Fatalf("vxlan device %s not found. Err: %v", name, err)
+	}
+	neighs, err := netlink.NeighList(link.Attrs().Index, syscall.AF_BRIDGE)
+	if err != nil {
+		t.Fatalf("Error listing fdb entries. Err: %v", err)
+	}
+
+	peers := []string{}
+	for _, neigh := range neighs {
+		if neigh.IP != nil && neigh.HardwareAddr.String() == "00:00:00:00:00:00" {
+			peers = append(peers, neigh.IP.String())
+		}
+	}
+	return peers
+}
+
+func TestLinuxDriverNetwork(t *testing.T) {
+	skipWithoutVrf(t)
+	driver, cleanup := initLinuxDriver(t, "bridge")
+	defer cleanup()
+
+	if err := driver.AddPeerHost(core.ServiceInfo{HostAddr: testPeerIP}); err != nil {
+		t.Fatalf("Error adding peer. Err: %v", err)
+	}
+	if err := driver.CreateNetwork(testNetID); err != nil {
+		t.Fatalf("Error creating vxlan network. Err: %v", err)
+	}
+
+	nw := driver.oper.Networks[testNetID]
+	bridge, err := netlink.LinkByName(nw.Bridge)
+	if err != nil {
+		t.Fatalf("Bridge of %s not found. Err: %v", testNetID, err)
+	}
+	if bridge.Attrs().HardwareAddr.String() != "02:02:14:01:01:fe" {
+		t.Fatalf("Unexpected gateway mac %s", bridge.Attrs().HardwareAddr)
+	}
+	vxlan, err := netlink.LinkByName(nw.Uplink)
+	if err != nil || vxlan.(*netlink.Vxlan).VxlanId != 10001 ||
+		vxlan.Attrs().MasterIndex != bridge.Attrs().Index {
+		t.Fatalf("Unexpected vxlan device %+v. Err: %v", vxlan, err)
+	}
+	if peers := peerFdbEntries(t, nw.Uplink); !reflect.DeepEqual(peers, []string{testPeerIP}) {
+		t.Fatalf("Unexpected peers %v", peers)
+	}
+	vrf, err := netlink.LinkByName(driver.oper.Tenants["default"].Vrf)
+	if err != nil || bridge.Attrs().MasterIndex != vrf.Attrs().Index {
+		t.Fatalf("Bridge of %s is not in the vrf of its tenant. Err: %v", testNetID, err)
+	}
+
+	if err := driver.DeletePeerHost(core.ServiceInfo{HostAddr: testPeerIP}); err != nil {
+		t.Fatalf("Error deleting peer. Err: %v", err)
+	}
+	if peers := peerFdbEntries(t, nw.Uplink); len(peers) != 0 {
+		t.Fatalf("Peers were not deleted: %v", peers)
+	}
+
+	if err := driver.DeleteNetwork(testNetID, "", "", "vxlan", 1, 10001, "", "default"); err != nil {
+		t.Fatalf("Error deleting network. Err: %v", err)
+	}
+	for _, name := range []string{nw.Bridge, nw.Uplink, vrf.Attrs().Name} {
+		if linkExists(name) {
+			t.Fatalf("Link %s was not deleted", name)
+		}
+	}
+}
+
+func TestLinuxDriverTenants(t *testing.T) {
+	skipWithoutVrf(t)
+	driver, cleanup := initLinuxDriver(t, "bridge")
+	defer cleanup()
+
+	// the networks of both tenants have the same subnet, in their own vrf
+	for _, id := range []string{testNetID, testVlanNetID, testTenNetID} {
+		if err := driver.CreateNetwork(id); err != nil {
+			t.Fatalf("Error creating network %s. Err: %v", id, err)
+		}
+	}
+	tenant1, tenant2 := driver.oper.Tenants["default"], driver.oper.Tenants["tenant2"]
+	if tenant1 == nil || tenant2 == nil || tenant1.Vrf == tenant2.Vrf || tenant1.Table == tenant2.Table {
+		t.Fatalf("Unexpected tenant vrfs %+v", driver.oper.Tenants)
+	}
+	for id, tenant := range map[string]*LinuxTenant{testNetID: tenant1, testVlanNetID: tenant1, testTenNetID: tenant2} {
+		bridge, _ := netlink.LinkByName(driver.oper.Networks[id].Bridge)
+		vrf, _ := netlink.LinkByName(tenant.Vrf)
+		if bridge == nil || vrf == nil || bridge.Attrs().MasterIndex != vrf.Attrs().Index {
+			t.Fatalf("Bridge of %s is not in vrf %s", id, tenant.Vrf)
+		}
+	}
+
+	// the vrf of a tenant is kept until its last network is deleted
+	if err := driver.DeleteNetwork(testNetID, "", "", "vxlan", 1, 10001, "", "default"); err != nil {
+		t.Fatalf("Error deleting network. Err: %v", err)
+	}
+	if !linkExists(tenant1.Vrf) || driver.oper.Tenants["default"] == nil {
+		t.Fatalf("vrf %s was deleted with networks left", tenant1.Vrf)
+	}
+	if err := driver.DeleteNetwork(testVlanNetID, "", "", "vlan", 100, 0, "", "default"); err != nil {
+		t.Fatalf("Error deleting network. Err: %v", err)
+	}
+	if linkExists(tenant1.Vrf) || driver.oper.Tenants["default"] != nil {
+		t.Fatalf("vrf %s was not deleted", tenant1.Vrf)
+	}
+	if !linkExists(tenant2.Vrf) {
+		t.Fatalf("vrf %s of the other tenant was deleted", tenant2.Vrf)
+	}
+}
+
+func TestLinuxDriverRoutedNetwork(t *testing.T) {
+	skipWithoutVrf(t)
+	driver, cleanup := initLinuxDriver(t, "routing")
+	defer cleanup()
+
+	if err := driver.AddPeerHost(core.ServiceInfo{HostAddr: testPeerIP}); err != nil {
+		t.Fatalf("Error adding peer. Err: %v", err)
+	}
+	// the remote endpoint is routed once its network is created
+	if err := driver.CreateRemoteEndpoint("net1.default-ep2"); err != nil {
+		t.Fatalf("Error creating remote endpoint. Err: %v", err)
+	}
+	if err := driver.CreateNetwork(testNetID); err != nil {
+		t.Fatalf("Error creating network. Err: %v", err)
+	}
+
+	nw := driver.oper.Networks[testNetID]
+	tenant := driver.oper.Tenants["default"]
+	vrf, _ := netlink.LinkByName(tenant.Vrf)
+	vxlan, err := netlink.LinkByName(nw.Uplink)
+	if err != nil || !nw.Routed || vxlan.Attrs().MasterIndex != vrf.Attrs().Index ||
+		vxlan.Attrs().HardwareAddr.String() != "02:03:0a:00:00:01" {
+		t.Fatalf("Unexpected vxlan device %+v. Err: %v", vxlan, err)
+	}
+	neighs, err := netlink.NeighList(vxlan.Attrs().Index, netlink.FAMILY_V4)
+	if err != nil || len(neighs) != 1 || neighs[0].HardwareAddr.String() != "02:03:0a:00:00:02" {
+		t.Fatalf("Unexpected router neighbors %+v. Err: %v", neighs, err)
+	}
+
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Table: tenant.Table},
+		netlink.RT_FILTER_TABLE)
+	if err != nil {
+		t.Fatalf("Error listing routes. Err: %v", err)
+	}
+	found := false
+	for _, route := range routes {
+		if route.Dst != nil && route.Dst.String() == "20.1.1.2/32" && route.Gw.String() == testPeerIP {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Route of the remote endpoint not found in %+v", routes)
+	}
+
+	if err := driver.DeleteRemoteEndpoint("net1.default-ep2"); err != nil {
+		t.Fatalf("Error deleting remote endpoint. Err: %v", err)
+	}
+	if len(driver.oper.RemoteEndpoints) != 0 {
+		t.Fatalf("Remote endpoint was not deleted")
+	}
+}
+
+func TestLinuxDriverFwdMode(t *testing.T) {
+	stateDriver := &state.FakeStateDriver{}
+	stateDriver.Init(nil)
+
+	driver := &LinuxDriver{}
+	err := driver.Init(&core.InstanceInfo{HostLabel: testHostLabel, VtepIP: testVtepIP,
+		FwdMode: "routing", StateDriver: stateDriver})
+	if err != nil {
+		t.Skipf("driver init failed. Err: %v", err)
+	}
+	if driver.oper.FwdMode != "routing" {
+		t.Fatalf("Unexpected forwarding mode %s", driver.oper.FwdMode)
+	}
+	if err := driver.GlobalConfigUpdate(core.InstanceInfo{FwdMode: "bridge"}); err != nil {
+		t.Fatalf("Error changing the forwarding mode without networks. Err: %v", err)
+	}
+
+	driver.oper.Networks[testNetID] = &LinuxNetwork{Tenant: "default"}
+	if err := driver.GlobalConfigUpdate(core.InstanceInfo{FwdMode: "routing"}); err == nil {
+		t.Fatalf("forwarding mode changed with networks")
+	}
+	if err := driver.GlobalConfigUpdate(core.InstanceInfo{FwdMode: "bridge"}); err != nil {
+		t.Fatalf("Error keeping the forwarding mode. Err: %v", err)
+	}
+}
+
+func TestLinuxDriverService(t *testing.T) {
+	stateDriver := &state.FakeStateDriver{}
+	stateDriver.Init(nil)
+
+	testRulesets = nil
+	driver := &LinuxDriver{}
+	err := driver.Init(&core.InstanceInfo{HostLabel: testHostLabel, VtepIP: testVtepIP,
+		StateDriver: stateDriver})
+	if err != nil {
+		t.Skipf("driver init failed. Err: %v", err)
+	}
+
+	// providers can be known before the service
+	driver.SvcProviderUpdate("svc1", []string{"20.1.1.2", "20.1.1.1"})
+	spec := &core.ServiceSpec{IPAddress: "10.254.0.10", ExternalIPs: []string{"192.168.2.10"},
+		Ports: []core.PortSpec{{Protocol: "UDP", SvcPort: 53, ProvPort: 5353}}}
+	if err := driver.AddSvcSpec("svc1", spec); err != nil {
+		t.Fatalf("Error adding service. Err: %v", err)
+	}
+	ruleset := testRulesets[len(testRulesets)-1]
+	for _, expected := range []string{
+		"table ip contivsvc\ndelete table ip contivsvc\n",
+		"chain svc0_0_0 {\n\t\tdnat to 20.1.1.1:5353\n\t}",
+		"ip daddr { 10.254.0.10, 192.168.2.10 } udp dport 53 jump svc0_0",
+	} {
+		if !strings.Contains(ruleset, expected) {
+			t.Fatalf("%q not found in ruleset:\n%s", expected, ruleset)
+		}
+	}
+
+	if err := driver.AddSvcSpec("svc2", &core.ServiceSpec{IPAddress: "svc2"}); err == nil {
+		t.Fatalf("service with an invalid address was added")
+	}
+
+	if err := driver.DelSvcSpec("svc1", spec); err != nil {
+		t.Fatalf("Error deleting service. Err: %v", err)
+	}
+	if strings.Contains(testRulesets[len(testRulesets)-1], "svc0") {
+		t.Fatalf("Service was not deleted:\n%s", testRulesets[len(testRulesets)-1])
+	}
+}
+
+func TestServiceRuleset(t *testing.T) {
+	services := map[string]*LinuxService{
+		"svc1": {
+			Spec: core.ServiceSpec{IPAddress: "10.254.0.10",
+				Ports: []core.PortSpec{{Protocol: "TCP", SvcPort: 80, ProvPort: 8080}}},
+			Providers: []string{"20.1.1.2", "20.1.1.1"},
+		},
+		"svc2": {
+			Spec: core.ServiceSpec{IPAddress: "10.254.0.11",
+				Ports: []core.PortSpec{{Protocol: "TCP", SvcPort: 443}}},
+		},
+		"svc3": {Providers: []string{"20.1.1.3"}},
+	}
+
+	expected := `table ip contivsvc {
+	chain svc0_0_0 {
+		dnat to 20.1.1.1:8080
+	}
+	chain svc0_0_1 {
+		dnat to 20.1.1.2:8080
+	}
+	chain svc0_0 {
+		numgen random mod 2 vmap { 0 : goto svc0_0_0, 1 : goto svc0_0_1 }
+	}
+	chain prerouting {
+		type nat hook prerouting priority -100; policy accept;
+		ip daddr { 10.254.0.10 } tcp dport 80 jump svc0_0 comment "svc1"
+		ip daddr { 10.254.0.11 } tcp dport 443 drop comment "svc2"
+	}
+}
+`
+	if ruleset := serviceRuleset(services); ruleset != expected {
+		t.Fatalf("Unexpected service ruleset:\n%s", ruleset)
+	}
+}
+
+func TestRoutedEntries(t *testing.T) {
+	neigh, fdb, err := peerRouterEntries(5, testPeerIP)
+	if err != nil || neigh.IP.String() != testPeerIP || neigh.HardwareAddr.String() != "02:03:0a:00:00:02" ||
+		fdb.Family != syscall.AF_BRIDGE || fdb.HardwareAddr.String() != neigh.HardwareAddr.String() {
+		t.Fatalf("Unexpected router entries %+v, %+v. Err: %v", neigh, fdb, err)
+	}
+	if _, _, err := peerRouterEntries(5, "2001:db8::1"); err == nil {
+		t.Fatalf("router entries of an ipv6 peer were returned")
+	}
+
+	route, err := remoteEndpointRoute(5, vrfTableBase+1,
+		&LinuxRemoteEndpoint{IPAddress: "20.1.1.2", VtepIP: testPeerIP})
+	if err != nil || route.Dst.String() != "20.1.1.2/32" || route.Gw.String() != testPeerIP ||
+		route.Table != vrfTableBase+1 || route.LinkIndex != 5 || route.Flags&int(netlink.FLAG_ONLINK) == 0 {
+		t.Fatalf("Unexpected remote endpoint route %+v. Err: %v", route, err)
+	}
+}
+
+func TestLinuxDriverVlanNetwork(t *testing.T) {
+	skipWithoutVrf(t)
+	driver, cleanup := initLinuxDriver(t, "bridge")
+	defer cleanup()
+
+	if err := driver.CreateNetwork(testVlanNetID); err != nil {
+		if strings.Contains(err.Error(), "not supported") {
+			t.Skipf("kernel doesn't support vlan links")
+		}
+		t.Fatalf("Error creating vlan network. Err: %v", err)
+	}
+
+	nw := driver.oper.Networks[testVlanNetID]
+	uplink, _ := netlink.LinkByName(testUplink)
+	vlan, err := netlink.LinkByName(nw.Uplink)
+	if err != nil || vlan.(*netlink.Vlan).VlanId != 100 || vlan.Attrs().ParentIndex != uplink.Attrs().Index {
+		t.Fatalf("Unexpected vlan sub-interface %+v. Err: %v", vlan, err)
+	}
+
+	if err := driver.DeleteNetwork(testVlanNetID, "", "", "vlan", 100, 0, "", "default"); err != nil {
+		t.Fatalf("Error deleting network. Err: %v", err)
+	}
+	for _, name := range []string{nw.Bridge, nw.Uplink} {
+		if linkExists(name) {
+			t.Fatalf("Link %s was not deleted", name)
+		}
+	}
+}
+
+func TestLinuxDriverEndpoint(t *testing.T) {
+	skipWithoutVrf(t)
+	driver, cleanup := initLinuxDriver(t, "bridge")
+	defer cleanup()
+
+	if err := driver.CreateNetwork(testNetID); err != nil {
+		t.Fatalf("Error creating network. Err: %v", err)
+	}
+	if err := driver.CreateEndpoint(testEpID); err != nil {
+		t.Fatalf("Error creating endpoint. Err: %v", err)
+	}
+
+	operEp := &drivers.OperEndpointState{}
+	operEp.StateDriver = driver.oper.StateDriver
+	if err := operEp.Read(testEpID); err != nil || !linkExists(operEp.PortName) {
+		t.Fatalf("Unexpected endpoint oper state %+v. Err: %v", operEp, err)
+	}
+
+	ep := driver.oper.Endpoints[testEpID]
+	intf, err := netlink.LinkByName(ep.IntfName)
+	if err != nil || intf.Attrs().HardwareAddr.String() != "02:02:14:01:01:01" {
+		t.Fatalf("Unexpected endpoint interface %+v. Err: %v", intf, err)
+	}
+	bridge, _ := netlink.LinkByName(driver.oper.Networks[testNetID].Bridge)
+	port, err := netlink.LinkByName(ep.PortName)
+	if err != nil || port.Attrs().MasterIndex != bridge.Attrs().Index {
+		t.Fatalf("Unexpected endpoint port %+v. Err: %v", port, err)
+	}
+
+	jsonStats, err := driver.GetEndpointStats()
+	if err != nil {
+		t.Fatalf("Error getting endpoint stats. Err: %v", err)
+	}
+	epStats := make(map[string]*LinuxEndpointStats)
+	if err := json.Unmarshal(jsonStats, &epStats); err != nil || epStats[testEpID] == nil ||
+		epStats[testEpID].PortName != ep.PortName {
+		t.Fatalf("Unexpected endpoint stats %s. Err: %v", jsonStats, err)
+	}
+
+	if err := driver.DeleteEndpoint(testEpID); err != nil {
+		t.Fatalf("Error deleting endpoint. Err: %v", err)
+	}
+	if linkExists(ep.PortName) || linkExists(ep.IntfName) {
+		t.Fatalf("veth pair of the endpoint was not deleted")
+	}
+	if err := operEp.Read(testEpID); err == nil {
+		t.Fatalf("Endpoint oper state was not cleared")
+	}
+}
+
+func TestLinuxDriverPolicyRule(t *testing.T) {
+	driver, cleanup := initLinuxDriver(t, "bridge")
+	defer cleanup()
+
+	rule := &mastercfg.CfgPolicyRule{OfnetPolicyRule: ofnet.OfnetPolicyRule{
+		RuleId:           "rule1",
+		Priority:         10,
+		SrcEndpointGroup: testGroupID + 1,
+		DstEndpointGroup: testGroupID,
+		IpProtocol:       6,
+		DstPort:          80,
+		TcpFlags:         "syn,!ack",
+		Action:           "deny",
+	}}
+	rule.StateDriver = driver.oper.StateDriver
+	rule.ID = rule.RuleId
+	if err := rule.Write(); err != nil {
+		t.Fatalf("Error writing policy rule. Err: %v", err)
+	}
+
+	if err := driver.AddPolicyRule("rule1"); err != nil {
+		t.Fatalf("Error adding policy rule. Err: %v", err)
+	}
+	ruleset := testRulesets[len(testRulesets)-1]
+	for _, expected := range []string{
+		"table bridge contiv\ndelete table bridge contiv\n",
+		"table inet contiv {\n",
+		"set epg10_v4 {\n\t\ttype ipv4_addr\n\t\telements = { 20.1.1.1 }\n\t}",
+		"set epg11_v4 {\n\t\ttype ipv4_addr\n\t\telements = { 20.1.1.2 }\n\t}",
+		"ip saddr @epg11_v4 ip daddr @epg10_v4 tcp dport 80 tcp flags & (syn | ack) == syn counter drop comment \"rule1\"",
+		"ip6 saddr @epg11_v6 ip6 daddr @epg10_v6 tcp dport 80",
+	} {
+		if !strings.Contains(ruleset, expected) {
+			t.Fatalf("%q not found in ruleset:\n%s", expected, ruleset)
+		}
+	}
+
+	// the same rules aren't applied again
+	applied := len(testRulesets)
+	if err := driver.CreateRemoteEndpoint("net1.default-ep2"); err != nil || len(testRulesets) != applied {
+		t.Fatalf("Unchanged ruleset was applied again. Err: %v", err)
+	}
+
+	if err := driver.DelPolicyRule("rule1"); err != nil {
+		t.Fatalf("Error deleting policy rule. Err: %v", err)
+	}
+	if strings.Contains(testRulesets[len(testRulesets)-1], "rule1") {
+		t.Fatalf("Policy rule was not deleted:\n%s", testRulesets[len(testRulesets)-1])
+	}
+}
+
+func TestPolicyRuleLines(t *testing.T) {
+	for _, test := range []struct {
+		rule  ofnet.OfnetPolicyRule
+		lines []string
+	}{
+		{
+			ofnet.OfnetPolicyRule{RuleId: "r1", Action: "deny"},
+			[]string{`counter drop comment "r1"`},
+		},
+		{
+			ofnet.OfnetPolicyRule{RuleId: "r2", SrcIpAddr: "10.1.0.0/16", IpProtocol: 17,
+				SrcPort: 1024, SrcPortMask: 0xfc00, Action: "allow"},
+			[]string{`ip saddr 10.1.0.0/16 udp sport 1024-2047 counter accept comment "r2"`},
+		},
+		{
+			ofnet.OfnetPolicyRule{RuleId: "r3", DstEndpointGroup: 5, IpProtocol: 1, Action: "deny"},
+			[]string{
+				`ip daddr @epg5_v4 meta l4proto 1 counter drop comment "r3"`,
+				`ip6 daddr @epg5_v6 meta l4proto 1 counter drop comment "r3"`,
+			},
+		},
+		{
+			ofnet.OfnetPolicyRule{RuleId: "r4", SrcIpAddr: "2001:db8::1", DstEndpointGroup: 5,
+				IpProtocol: 6, Action: "allow"},
+			[]string{`ip6 saddr 2001:db8::1 ip6 daddr @epg5_v6 meta l4proto tcp counter accept comment "r4"`},
+		},
+	} {
+		lines, err := policyRuleLines(&test.rule)
+		if err != nil || !reflect.DeepEqual(lines, test.lines) {
+			t.Fatalf("Unexpected lines of rule %+v: %q. Err: %v", test.rule, lines, err)
+		}
+	}
+
+	if _, err := policyRuleLines(&ofnet.OfnetPolicyRule{IpProtocol: 6, TcpFlags: "fin"}); err == nil {
+		t.Fatalf("Unknown tcp flags were accepted")
+	}
+}
+
+func TestParseRuleCounters(t *testing.T) {
+	out := `table inet contiv {
+	chain forward {
+		type filter hook forward priority 0; policy accept;
+		ip saddr @epg11_v4 ip daddr @epg10_v4 counter packets 3 bytes 180 drop comment "rule1"
+		ip6 saddr @epg11_v6 ip6 daddr @epg10_v6 counter packets 1 bytes 80 drop comment "rule1"
+		counter packets 7 bytes 700 accept comment "rule2"
+	}
+}`
+	stats := map[string]*ofnet.OfnetPolicyRuleStats{}
+	parseRuleCounters(out, stats)
+	expected := map[string]*ofnet.OfnetPolicyRuleStats{
+		"rule1": {RuleId: "rule1", PacketCount: 4, ByteCount: 260},
+		"rule2": {RuleId: "rule2", PacketCount: 7, ByteCount: 700},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Fatalf("Unexpected rule counters %+v", stats)
+	}
+}
+
+func TestGatewayMac(t *testing.T) {
+	if mac := gatewayMac(net.ParseIP("10.1.2.3")).String(); mac != "02:02:0a:01:02:03" {
+		t.Fatalf("Unexpected gateway mac %s", mac)
+	}
+}
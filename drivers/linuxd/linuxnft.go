@@ -0,0 +1,415 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package linuxd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/ofnet"
+)
+
+// Policy rules are programmed as one nftables ruleset, in the forward chain
+// of an inet table. br_netfilter makes the traffic within a network go
+// through it too, along the traffic routed between networks. Endpoint groups are sets of the
+// addresses of their members, on all hosts. The rules are ordered by
+// priority and the first that matches applies, like in ofnet.
+
+const (
+	tcpFlagSyn = 0x02
+	tcpFlagAck = 0x10
+)
+
+// nftCommand runs nft with a script on its standard input, tests replace it
+var nftCommand = func(stdin string, args ...string) ([]byte, error) {
+	cmd := exec.Command("nft", args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return out, core.Errorf("nft %s failed. Err: %v, output: %s",
+			strings.Join(args, " "), err, out)
+	}
+	return out, nil
+}
+
+// portMatch returns the match of a port and mask of a policy rule. A zero
+// port matches any port, and a zero mask the exact port.
+func portMatch(dir string, port, mask uint16) string {
+	switch {
+	case port == 0:
+		return ""
+	case mask == 0 || mask == 0xffff:
+		return fmt.Sprintf(" %s %d", dir, port)
+	default:
+		return fmt.Sprintf(" %s %d-%d", dir, port&mask, port&mask|^mask)
+	}
+}
+
+// tcpFlagsMatch returns the match of the tcp flags of a policy rule
+func tcpFlagsMatch(flags string) (string, error) {
+	names := func(flags uint8) string {
+		switch flags {
+		case tcpFlagSyn:
+			return "syn"
+		case tcpFlagAck:
+			return "ack"
+		}
+		return "syn | ack"
+	}
+
+	var value, mask uint8
+	switch flags {
+	case "":
+		return "", nil
+	case "syn":
+		value, mask = tcpFlagSyn, tcpFlagSyn
+	case "syn,ack":
+		value, mask = tcpFlagSyn|tcpFlagAck, tcpFlagSyn|tcpFlagAck
+	case "ack":
+		value, mask = tcpFlagAck, tcpFlagAck
+	case "syn,!ack":
+		value, mask = tcpFlagSyn, tcpFlagSyn|tcpFlagAck
+	case "!syn,ack":
+		value, mask = tcpFlagAck, tcpFlagSyn|tcpFlagAck
+	default:
+		return "", core.Errorf("Unknown TCP flags %q", flags)
+	}
+
+	return fmt.Sprintf(" tcp flags & (%s) == %s", names(mask), names(value)), nil
+}
+
+// addrMatch returns the matches of a side of a rule in each address family.
+// A match is missing from the map when the rule can't match that family.
+func addrMatch(dir, addr string, group int) (map[string]string, error) {
+	if addr != "" {
+		ip, ipNet, err := net.ParseCIDR(addr)
+		if err != nil {
+			if ip = net.ParseIP(addr); ip == nil {
+				return nil, core.Errorf("Invalid address %q", addr)
+			}
+		} else {
+			ip = ipNet.IP
+			addr = ipNet.String()
+		}
+		if ip.To4() != nil {
+			return map[string]string{"ip": fmt.Sprintf(" ip %s %s", dir, addr)}, nil
+		}
+		return map[string]string{"ip6": fmt.Sprintf(" ip6 %s %s", dir, addr)}, nil
+	}
+
+	if group == 0 {
+		return map[string]string{"ip": "", "ip6": ""}, nil
+	}
+	return map[string]string{
+		"ip":  fmt.Sprintf(" ip %s @epg%d_v4", dir, group),
+		"ip6": fmt.Sprintf(" ip6 %s @epg%d_v6", dir, group),
+	}, nil
+}
+
+// policyRuleLines converts a policy rule to nft rules, one per address
+// family it matches
+func policyRuleLines(rule *ofnet.OfnetPolicyRule) ([]string, error) {
+	src, err := addrMatch("saddr", rule.SrcIpAddr, rule.SrcEndpointGroup)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := addrMatch("daddr", rule.DstIpAddr, rule.DstEndpointGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	proto := ""
+	switch rule.IpProtocol {
+	case 0:
+	case 6, 17:
+		name := "tcp"
+		if rule.IpProtocol == 17 {
+			name = "udp"
+		}
+		ports := portMatch(name+" sport", rule.SrcPort, rule.SrcPortMask) +
+			portMatch(name+" dport", rule.DstPort, rule.DstPortMask)
+		if ports == "" {
+			ports = fmt.Sprintf(" meta l4proto %s", name)
+		}
+		proto = ports
+		if rule.IpProtocol == 6 {
+			flags, err := tcpFlagsMatch(rule.TcpFlags)
+			if err != nil {
+				return nil, err
+			}
+			proto += flags
+		}
+	default:
+		proto = fmt.Sprintf(" meta l4proto %d", rule.IpProtocol)
+	}
+
+	verdict := "accept"
+	if rule.Action == "deny" {
+		verdict = "drop"
+	}
+
+	matches := []string{}
+	if src["ip"] == "" && src["ip6"] == "" && dst["ip"] == "" && dst["ip6"] == "" &&
+		len(src) == 2 && len(dst) == 2 {
+		// a rule without addresses matches both families at once
+		matches = append(matches, "")
+	} else {
+		for _, family := range []string{"ip", "ip6"} {
+			srcMatch, srcOk := src[family]
+			dstMatch, dstOk := dst[family]
+			if srcOk && dstOk {
+				matches = append(matches, srcMatch+dstMatch)
+			}
+		}
+	}
+
+	lines := []string{}
+	for _, match := range matches {
+		line := fmt.Sprintf("%s%s counter %s comment %q", match, proto, verdict, rule.RuleId)
+		lines = append(lines, strings.TrimPrefix(line, " "))
+	}
+
+	return lines, nil
+}
+
+// policyRuleList orders policy rules by priority, highest first
+type policyRuleList []*ofnet.OfnetPolicyRule
+
+func (l policyRuleList) Len() int      { return len(l) }
+func (l policyRuleList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l policyRuleList) Less(i, j int) bool {
+	if l[i].Priority != l[j].Priority {
+		return l[i].Priority > l[j].Priority
+	}
+	return l[i].RuleId < l[j].RuleId
+}
+
+// readGroupMembers returns the addresses of the endpoints of each group, by
+// address family
+func (d *LinuxDriver) readGroupMembers() (map[int][]string, map[int][]string, error) {
+	v4, v6 := make(map[int][]string), make(map[int][]string)
+
+	cfgEp := &mastercfg.CfgEndpointState{}
+	cfgEp.StateDriver = d.oper.StateDriver
+	epCfgs, err := cfgEp.ReadAll()
+	if core.ErrIfKeyExists(err) != nil {
+		return nil, nil, err
+	}
+	for _, epCfg := range epCfgs {
+		ep := epCfg.(*mastercfg.CfgEndpointState)
+		if ep.EndpointGroupID == 0 {
+			continue
+		}
+		if ep.IPAddress != "" {
+			v4[ep.EndpointGroupID] = append(v4[ep.EndpointGroupID], ep.IPAddress)
+		}
+		if ep.IPv6Address != "" {
+			v6[ep.EndpointGroupID] = append(v6[ep.EndpointGroupID], ep.IPv6Address)
+		}
+	}
+
+	return v4, v6, nil
+}
+
+// buildRuleset returns the nft script of the policy rules and services. It
+// replaces the tables of the driver, and is the same for the same rules,
+// members and services.
+func (d *LinuxDriver) buildRuleset() (string, error) {
+	v4, v6, err := d.readGroupMembers()
+	if err != nil {
+		return "", err
+	}
+
+	groups := map[int]bool{}
+	rules := policyRuleList{}
+	for _, rule := range d.oper.PolicyRules {
+		rules = append(rules, rule)
+		for _, group := range []int{rule.SrcEndpointGroup, rule.DstEndpointGroup} {
+			if group != 0 {
+				groups[group] = true
+			}
+		}
+	}
+	sort.Sort(rules)
+	groupIDs := []int{}
+	for group := range groups {
+		groupIDs = append(groupIDs, group)
+	}
+	sort.Ints(groupIDs)
+
+	body := &bytes.Buffer{}
+	for _, group := range groupIDs {
+		for _, set := range []struct {
+			suffix, addrType string
+			members          []string
+		}{
+			{"v4", "ipv4_addr", v4[group]},
+			{"v6", "ipv6_addr", v6[group]},
+		} {
+			fmt.Fprintf(body, "\tset epg%d_%s {\n\t\ttype %s\n", group, set.suffix, set.addrType)
+			if len(set.members) != 0 {
+				sort.Strings(set.members)
+				fmt.Fprintf(body, "\t\telements = { %s }\n", strings.Join(set.members, ", "))
+			}
+			fmt.Fprintf(body, "\t}\n")
+		}
+	}
+	fmt.Fprintf(body, "\tchain forward {\n\t\ttype filter hook forward priority 0; policy accept;\n")
+	for _, rule := range rules {
+		lines, err := policyRuleLines(rule)
+		if err != nil {
+			log.Errorf("Error converting policy rule %s to nft rules. Err: %v", rule.RuleId, err)
+			continue
+		}
+		for _, line := range lines {
+			fmt.Fprintf(body, "\t\t%s\n", line)
+		}
+	}
+	fmt.Fprintf(body, "\t}\n")
+
+	ruleset := &bytes.Buffer{}
+	// creating the tables first lets the deletes succeed on the first run.
+	// The bridge table is only deleted, the policy rules were in it before
+	// br_netfilter was used.
+	for _, table := range []string{"bridge " + nftTableName, "inet " + nftTableName, "ip " + nftSvcTableName} {
+		fmt.Fprintf(ruleset, "table %s\ndelete table %s\n", table, table)
+	}
+	fmt.Fprintf(ruleset, "table inet %s {\n%s}\n", nftTableName, body.String())
+	ruleset.WriteString(serviceRuleset(d.oper.Services))
+
+	return ruleset.String(), nil
+}
+
+// syncPolicy applies the policy rules with the current group members, when
+// they changed since they were last applied
+func (d *LinuxDriver) syncPolicy() error {
+	ruleset, err := d.buildRuleset()
+	if err != nil {
+		return err
+	}
+	if ruleset == d.ruleset {
+		return nil
+	}
+
+	if _, err := nftCommand(ruleset, "-f", "-"); err != nil {
+		log.Errorf("Error applying policy rules. Err: %v", err)
+		return err
+	}
+	d.ruleset = ruleset
+
+	return nil
+}
+
+// AddPolicyRule adds a policy rule to the nftables ruleset
+func (d *LinuxDriver) AddPolicyRule(id string) error {
+	log.Infof("Adding policy rule %s", id)
+
+	ruleCfg := &mastercfg.CfgPolicyRule{}
+	ruleCfg.StateDriver = d.oper.StateDriver
+	if err := ruleCfg.Read(id); err != nil {
+		log.Errorf("Failed to read config for policy rule '%s' \n", id)
+		return err
+	}
+	if _, err := policyRuleLines(&ruleCfg.OfnetPolicyRule); err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	rule := ruleCfg.OfnetPolicyRule
+	d.oper.PolicyRules[id] = &rule
+	if err := d.oper.Write(); err != nil {
+		return err
+	}
+
+	return d.syncPolicy()
+}
+
+// DelPolicyRule removes a policy rule from the nftables ruleset
+func (d *LinuxDriver) DelPolicyRule(id string) error {
+	log.Infof("Deleting policy rule %s", id)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, found := d.oper.PolicyRules[id]; !found {
+		log.Infof("Policy rule %s not found", id)
+		return nil
+	}
+
+	delete(d.oper.PolicyRules, id)
+	if err := d.oper.Write(); err != nil {
+		return err
+	}
+
+	return d.syncPolicy()
+}
+
+var ruleCounterRe = regexp.MustCompile(`counter packets (\d+) bytes (\d+) .*comment "([^"]*)"`)
+
+// parseRuleCounters adds the counters of the rules in nft list output to
+// the stats of their policy rules
+func parseRuleCounters(out string, stats map[string]*ofnet.OfnetPolicyRuleStats) {
+	for _, line := range strings.Split(out, "\n") {
+		m := ruleCounterRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		packets, _ := strconv.ParseUint(m[1], 10, 64)
+		bytes, _ := strconv.ParseUint(m[2], 10, 64)
+
+		ruleStats, found := stats[m[3]]
+		if !found {
+			ruleStats = &ofnet.OfnetPolicyRuleStats{RuleId: m[3]}
+			stats[m[3]] = ruleStats
+		}
+		ruleStats.PacketCount += packets
+		ruleStats.ByteCount += bytes
+	}
+}
+
+// GetPolicyRuleStats returns the counters of the nft rules of each policy
+// rule
+func (d *LinuxDriver) GetPolicyRuleStats() ([]byte, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	stats := make(map[string]*ofnet.OfnetPolicyRuleStats)
+	for id := range d.oper.PolicyRules {
+		stats[id] = &ofnet.OfnetPolicyRuleStats{RuleId: id}
+	}
+	out, err := nftCommand("", "list", "table", "inet", nftTableName)
+	if err != nil {
+		log.Errorf("Error reading policy rule counters. Err: %v", err)
+		return []byte{}, err
+	}
+	parseRuleCounters(string(out), stats)
+
+	return json.Marshal(stats)
+}
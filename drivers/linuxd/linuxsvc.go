@@ -0,0 +1,175 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package linuxd
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+)
+
+// Services are programmed in the nat prerouting chain of an ip table, next
+// to the policy rules. Traffic to the address or an external address of a
+// service port is translated to one of its providers at random. The replies
+// are translated back by conntrack, br_netfilter lets it see the replies of
+// the providers in the same network.
+
+// LinuxService is a service and its providers
+type LinuxService struct {
+	Spec      core.ServiceSpec `json:"spec"`
+	Providers []string         `json:"providers"`
+}
+
+// serviceRuleset returns the nft table of the services. Each port of a
+// service has a chain that picks a provider chain at random.
+func serviceRuleset(services map[string]*LinuxService) string {
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	chains := &bytes.Buffer{}
+	prerouting := &bytes.Buffer{}
+	for i, name := range names {
+		svc := services[name]
+		if svc.Spec.IPAddress == "" {
+			continue
+		}
+		addrs := append([]string{svc.Spec.IPAddress}, svc.Spec.ExternalIPs...)
+		providers := append([]string{}, svc.Providers...)
+		sort.Strings(providers)
+
+		for j, port := range svc.Spec.Ports {
+			proto := strings.ToLower(port.Protocol)
+			if proto == "" {
+				proto = "tcp"
+			}
+			match := fmt.Sprintf("ip daddr { %s } %s dport %d", strings.Join(addrs, ", "), proto, port.SvcPort)
+			if len(providers) == 0 {
+				fmt.Fprintf(prerouting, "\t\t%s drop comment %q\n", match, name)
+				continue
+			}
+
+			provPort := port.ProvPort
+			if provPort == 0 {
+				provPort = port.SvcPort
+			}
+			portChain := fmt.Sprintf("svc%d_%d", i, j)
+			verdicts := []string{}
+			for k, provider := range providers {
+				provChain := fmt.Sprintf("%s_%d", portChain, k)
+				fmt.Fprintf(chains, "\tchain %s {\n\t\tdnat to %s:%d\n\t}\n", provChain, provider, provPort)
+				verdicts = append(verdicts, fmt.Sprintf("%d : goto %s", k, provChain))
+			}
+			fmt.Fprintf(chains, "\tchain %s {\n\t\tnumgen random mod %d vmap { %s }\n\t}\n",
+				portChain, len(providers), strings.Join(verdicts, ", "))
+			fmt.Fprintf(prerouting, "\t\t%s jump %s comment %q\n", match, portChain, name)
+		}
+	}
+
+	return fmt.Sprintf("table ip %s {\n%s\tchain prerouting {\n\t\ttype nat hook prerouting priority -100; policy accept;\n%s\t}\n}\n",
+		nftSvcTableName, chains.String(), prerouting.String())
+}
+
+// checkServiceSpec checks the addresses of a service, they are ipv4 ones
+func checkServiceSpec(spec *core.ServiceSpec) error {
+	for _, addr := range append([]string{spec.IPAddress}, spec.ExternalIPs...) {
+		if ip := net.ParseIP(addr); ip == nil || ip.To4() == nil {
+			return core.Errorf("Invalid service address %q", addr)
+		}
+	}
+
+	return nil
+}
+
+// AddSvcSpec adds or updates a service
+func (d *LinuxDriver) AddSvcSpec(svcName string, spec *core.ServiceSpec) error {
+	log.Infof("AddSvcSpec: %s", svcName)
+
+	if err := checkServiceSpec(spec); err != nil {
+		return err
+	}
+	for _, port := range spec.Ports {
+		if port.NodePort != 0 {
+			// node ports need a host access port, like in the ovs driver
+			log.Errorf("linux driver doesn't support host access ports, node port %d of service %s is not exposed",
+				port.NodePort, svcName)
+		}
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	svc, found := d.oper.Services[svcName]
+	if !found {
+		svc = &LinuxService{}
+		d.oper.Services[svcName] = svc
+	}
+	svc.Spec = *spec
+	if err := d.oper.Write(); err != nil {
+		return err
+	}
+
+	return d.syncPolicy()
+}
+
+// DelSvcSpec deletes a service
+func (d *LinuxDriver) DelSvcSpec(svcName string, spec *core.ServiceSpec) error {
+	log.Infof("DelSvcSpec: %s", svcName)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, found := d.oper.Services[svcName]; !found {
+		return nil
+	}
+	delete(d.oper.Services, svcName)
+	if err := d.oper.Write(); err != nil {
+		return err
+	}
+
+	return d.syncPolicy()
+}
+
+// SvcProviderUpdate replaces the providers of a service. They are kept
+// until the service is added, if it isn't yet.
+func (d *LinuxDriver) SvcProviderUpdate(svcName string, providers []string) {
+	log.Infof("SvcProviderUpdate: %s %v", svcName, providers)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	svc, found := d.oper.Services[svcName]
+	if !found {
+		svc = &LinuxService{}
+		d.oper.Services[svcName] = svc
+	}
+	svc.Providers = append([]string{}, providers...)
+	if err := d.oper.Write(); err != nil {
+		log.Errorf("Error saving providers of service %s. Err: %v", svcName, err)
+		return
+	}
+
+	if err := d.syncPolicy(); err != nil {
+		log.Errorf("Error updating providers of service %s. Err: %v", svcName, err)
+	}
+}
@@ -0,0 +1,247 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsd
+
+import (
+	"encoding/json"
+	"io"
+	"log/syslog"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/ofnet"
+)
+
+const (
+	flowLogQueueLen     = 1024            // records waiting to be written
+	flowLogEpgRefresh   = 5 * time.Second // min interval between epg reloads
+	flowLogSyslogTag    = "netplugin-flowlog"
+	flowLogSyslogTarget = "syslog"
+
+	// Packets of new connections are sent to the controller, the switch
+	// rate limits them so that a flood of new connections can't overload it
+	flowLogControllerRate  = 1000 // packets per second
+	flowLogControllerBurst = 250  // packets queued above the rate
+)
+
+// FlowLogEntry is a flow log record, written as a json line
+type FlowLogEntry struct {
+	Timestamp         time.Time `json:"timestamp"`
+	Host              string    `json:"host"`
+	TenantName        string    `json:"tenantName"`
+	PolicyName        string    `json:"policyName"`
+	RuleID            string    `json:"ruleId"`
+	Verdict           string    `json:"verdict"`
+	FromEndpointGroup string    `json:"fromEndpointGroup"`
+	ToEndpointGroup   string    `json:"toEndpointGroup"`
+	SrcIPAddress      string    `json:"srcIpAddress"`
+	DstIPAddress      string    `json:"dstIpAddress"`
+	Protocol          int       `json:"protocol"`
+	SrcPort           int       `json:"srcPort"`
+	DstPort           int       `json:"dstPort"`
+}
+
+// FlowLogger writes the first packet of connections hitting policy rules
+// to a file or syslog
+type FlowLogger struct {
+	written     uint64 // records written, first for 64-bit alignment
+	dropped     uint64 // records dropped because the queue was full
+	host        string
+	stateDriver core.StateDriver
+	writer      io.WriteCloser
+	records     chan *ofnet.OfnetFlowLogRecord
+	stop        chan bool
+
+	// owned by the writer goroutine
+	epgs       map[int]*mastercfg.EndpointGroupState
+	epgsReadAt time.Time
+}
+
+// NewFlowLogger creates a flow logger. target is syslog for the local
+// syslog, a syslog url in the protocol://ip:port format or a file path.
+func NewFlowLogger(stateDriver core.StateDriver, host, target string) (*FlowLogger, error) {
+	writer, err := openFlowLogWriter(target)
+	if err != nil {
+		log.Errorf("Error opening flow log %s. Err: %v", target, err)
+		return nil, err
+	}
+
+	fl := newFlowLogger(stateDriver, host, writer)
+	go fl.run()
+
+	log.Infof("Writing policy flow log to %s", target)
+
+	return fl, nil
+}
+
+func newFlowLogger(stateDriver core.StateDriver, host string, writer io.WriteCloser) *FlowLogger {
+	return &FlowLogger{
+		host:        host,
+		stateDriver: stateDriver,
+		writer:      writer,
+		records:     make(chan *ofnet.OfnetFlowLogRecord, flowLogQueueLen),
+		stop:        make(chan bool),
+		epgs:        make(map[int]*mastercfg.EndpointGroupState),
+	}
+}
+
+// openFlowLogWriter opens the file or syslog connection records are written to
+func openFlowLogWriter(target string) (io.WriteCloser, error) {
+	priority := syslog.LOG_INFO | syslog.LOG_DAEMON
+
+	if target == flowLogSyslogTarget {
+		return syslog.New(priority, flowLogSyslogTag)
+	}
+
+	if strings.Contains(target, "://") {
+		syslogURL, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+
+		return syslog.Dial(syslogURL.Scheme, syslogURL.Host, priority, flowLogSyslogTag)
+	}
+
+	return os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+// LogFlow queues a record to be written. Records are dropped when the
+// writer cant keep up, so that the switch event loop is never blocked.
+func (fl *FlowLogger) LogFlow(record *ofnet.OfnetFlowLogRecord) {
+	select {
+	case fl.records <- record:
+	default:
+		atomic.AddUint64(&fl.dropped, 1)
+	}
+}
+
+// Close stops writing records and closes the file or syslog connection
+func (fl *FlowLogger) Close() {
+	close(fl.stop)
+}
+
+// Stats returns the number of records written and dropped
+func (fl *FlowLogger) Stats() map[string]uint64 {
+	return map[string]uint64{
+		"written": atomic.LoadUint64(&fl.written),
+		"dropped": atomic.LoadUint64(&fl.dropped),
+	}
+}
+
+// run writes queued records until the logger is closed
+func (fl *FlowLogger) run() {
+	defer fl.writer.Close()
+
+	for {
+		select {
+		case <-fl.stop:
+			return
+		case record := <-fl.records:
+			if err := fl.writeRecord(record); err != nil {
+				log.Errorf("Error writing flow log record %+v. Err: %v", record, err)
+			}
+		}
+	}
+}
+
+// writeRecord writes a record as a json line
+func (fl *FlowLogger) writeRecord(record *ofnet.OfnetFlowLogRecord) error {
+	entry := fl.newEntry(record)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fl.writer.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&fl.written, 1)
+	return nil
+}
+
+// newEntry resolves the rule and endpoint groups of a record to their names
+func (fl *FlowLogger) newEntry(record *ofnet.OfnetFlowLogRecord) *FlowLogEntry {
+	entry := &FlowLogEntry{
+		Timestamp:    record.Timestamp,
+		Host:         fl.host,
+		RuleID:       record.RuleId,
+		Verdict:      record.Action,
+		SrcIPAddress: record.SrcIpAddr,
+		DstIPAddress: record.DstIpAddr,
+		Protocol:     int(record.IpProtocol),
+		SrcPort:      int(record.SrcPort),
+		DstPort:      int(record.DstPort),
+	}
+
+	// rule key is tenant:policy:ruleId
+	_, ruleKey, err := mastercfg.ParseOfnetRuleID(record.RuleId)
+	if err != nil {
+		log.Warnf("Error parsing flow log rule id. Err: %v", err)
+	} else {
+		fields := strings.SplitN(ruleKey, ":", 3)
+		entry.TenantName, entry.PolicyName, entry.RuleID = fields[0], fields[1], fields[2]
+	}
+
+	if epg := fl.endpointGroup(record.SrcEndpointGroup); epg != nil {
+		entry.FromEndpointGroup = epg.GroupName
+	}
+	if epg := fl.endpointGroup(record.DstEndpointGroup); epg != nil {
+		entry.ToEndpointGroup = epg.GroupName
+	}
+
+	return entry
+}
+
+// endpointGroup finds an endpoint group by id. Groups are re-read from the
+// state store when a group is not known yet.
+func (fl *FlowLogger) endpointGroup(epgID int) *mastercfg.EndpointGroupState {
+	if epgID == 0 {
+		return nil
+	}
+	if epg, ok := fl.epgs[epgID]; ok {
+		return epg
+	}
+
+	// dont hit the state store for every record of an unknown group
+	if time.Since(fl.epgsReadAt) < flowLogEpgRefresh {
+		return nil
+	}
+	fl.epgsReadAt = time.Now()
+
+	readEpg := &mastercfg.EndpointGroupState{}
+	readEpg.StateDriver = fl.stateDriver
+	epgCfgs, err := readEpg.ReadAll()
+	if core.ErrIfKeyExists(err) != nil {
+		log.Errorf("Error reading endpoint groups. Err: %v", err)
+		return nil
+	}
+
+	fl.epgs = make(map[int]*mastercfg.EndpointGroupState)
+	for _, epgCfg := range epgCfgs {
+		epg := epgCfg.(*mastercfg.EndpointGroupState)
+		fl.epgs[epg.EndpointGroupID] = epg
+	}
+
+	return fl.epgs[epgID]
+}
@@ -0,0 +1,110 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/state"
+	"github.com/contiv/ofnet"
+)
+
+type testFlowLogWriter struct {
+	bytes.Buffer
+}
+
+func (w *testFlowLogWriter) Close() error {
+	return nil
+}
+
+func TestFlowLogRecord(t *testing.T) {
+	stateDriver := &state.FakeStateDriver{}
+	stateDriver.Init(nil)
+
+	for id, name := range map[int]string{1: "web", 2: "db"} {
+		epg := &mastercfg.EndpointGroupState{
+			GroupName:       name,
+			TenantName:      testTenant,
+			EndpointGroupID: id,
+		}
+		epg.StateDriver = stateDriver
+		epg.ID = testTenant + ":" + name
+		if err := epg.Write(); err != nil {
+			t.Fatalf("Error writing epg state. Err: %v", err)
+		}
+	}
+
+	writer := &testFlowLogWriter{}
+	fl := newFlowLogger(stateDriver, testHostLabel, writer)
+
+	ts := time.Date(2017, 3, 1, 10, 0, 0, 0, time.UTC)
+	record := &ofnet.OfnetFlowLogRecord{
+		Timestamp:        ts,
+		RuleId:           "default:db:default:dbPolicy:default:dbPolicy:1:inRx",
+		Action:           "deny",
+		SrcEndpointGroup: 1,
+		DstEndpointGroup: 2,
+		SrcIpAddr:        "10.1.1.1",
+		DstIpAddr:        "10.1.1.2",
+		IpProtocol:       6,
+		SrcPort:          34567,
+		DstPort:          3306,
+	}
+	if err := fl.writeRecord(record); err != nil {
+		t.Fatalf("Error writing flow log record. Err: %v", err)
+	}
+
+	line, err := writer.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("Flow log record is not a line. Err: %v", err)
+	}
+
+	var entry FlowLogEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		t.Fatalf("Error decoding flow log record %s. Err: %v", line, err)
+	}
+
+	expEntry := FlowLogEntry{
+		Timestamp:         ts,
+		Host:              testHostLabel,
+		TenantName:        "default",
+		PolicyName:        "dbPolicy",
+		RuleID:            "1",
+		Verdict:           "deny",
+		FromEndpointGroup: "web",
+		ToEndpointGroup:   "db",
+		SrcIPAddress:      "10.1.1.1",
+		DstIPAddress:      "10.1.1.2",
+		Protocol:          6,
+		SrcPort:           34567,
+		DstPort:           3306,
+	}
+	if !entry.Timestamp.Equal(expEntry.Timestamp) {
+		t.Fatalf("Flow log timestamp %v, expected %v", entry.Timestamp, expEntry.Timestamp)
+	}
+	entry.Timestamp = expEntry.Timestamp
+	if entry != expEntry {
+		t.Fatalf("Flow log record %+v, expected %+v", entry, expEntry)
+	}
+
+	if stats := fl.Stats(); stats["written"] != 1 || stats["dropped"] != 0 {
+		t.Fatalf("Unexpected flow log stats %v", stats)
+	}
+}
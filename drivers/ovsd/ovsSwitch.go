@@ -16,13 +16,18 @@ limitations under the License.
 package ovsd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/contiv/netplugin/core"
@@ -50,13 +55,25 @@ const (
 
 // OvsSwitch represents on OVS bridge instance
 type OvsSwitch struct {
-	bridgeName    string
-	netType       string
-	uplinkDb      cmap.ConcurrentMap
-	ovsdbDriver   *OvsdbDriver
-	ofnetAgent    *ofnet.OfnetAgent
-	hostPvtNW     int
-	vxlanEncapMtu int
+	bridgeName  string
+	netType     string
+	uplinkDb    cmap.ConcurrentMap
+	ovsdbDriver *OvsdbDriver
+	ofnetAgent  *ofnet.OfnetAgent
+	hostPvtNW   int
+	uplinkMtu   int
+	fwdMode     string
+	localIP     string
+
+	encryptionMutex sync.Mutex                                   // protects the encryption fields
+	encryption      *mastercfg.CfgEncryptionState                // encryption of the tunnels
+	encryptionKeys  map[int]string                               // private keys of the host, by key generation
+	encryptionHosts map[string]*mastercfg.CfgEncryptionHostState // public keys of the hosts, by VTEP IP
+	downVteps       map[string]bool                              // VTEP intfs kept out of ofnet until they have a key
+
+	qosMutex     sync.Mutex             // protects egressQueues
+	egressQueues map[uint32]QueueConfig // egress shaping of the shaped endpoints, by ofp port
+	uplinkRate   int64                  // rate of the uplinks whose speed is unknown and of the egress shaper, in bps
 }
 
 // getPvtIP returns a private IP for the port
@@ -99,9 +116,13 @@ func NewOvsSwitch(bridgeName, netType, localIP, fwdMode string,
 	sw := new(OvsSwitch)
 	sw.bridgeName = bridgeName
 	sw.netType = netType
+	sw.fwdMode = fwdMode
+	sw.localIP = localIP
 	sw.uplinkDb = cmap.New()
+	sw.egressQueues = make(map[uint32]QueueConfig)
+	sw.downVteps = make(map[string]bool)
 	sw.hostPvtNW = hostPvtNW
-	sw.vxlanEncapMtu, err = netutils.GetHostLowestLinkMtu()
+	sw.uplinkMtu, err = netutils.GetHostLowestLinkMtu()
 	if err != nil {
 		log.Fatalf("Failed to get Host Node MTU. Err: %v", err)
 	}
@@ -198,10 +219,10 @@ func (sw *OvsSwitch) Delete() {
 }
 
 // CreateNetwork creates a new network/vlan
-func (sw *OvsSwitch) CreateNetwork(pktTag uint16, extPktTag uint32, defaultGw string, Vrf string) error {
+func (sw *OvsSwitch) CreateNetwork(pktTag uint16, extPktTag uint32, defaultGw string, Vrf string, encap string) error {
 	// Add the vlan/vni to ofnet
 	if sw.ofnetAgent != nil {
-		err := sw.ofnetAgent.AddNetwork(pktTag, extPktTag, defaultGw, Vrf)
+		err := sw.ofnetAgent.AddEncapNetwork(pktTag, extPktTag, defaultGw, Vrf, encap)
 		if err != nil {
 			log.Errorf("Error adding vlan/vni %d/%d. Err: %v", pktTag, extPktTag, err)
 			return err
@@ -210,6 +231,24 @@ func (sw *OvsSwitch) CreateNetwork(pktTag uint16, extPktTag uint32, defaultGw st
 	return nil
 }
 
+// UpdateNetworkGateway moves the ipv4 and ipv6 gateways of a network/vlan
+func (sw *OvsSwitch) UpdateNetworkGateway(pktTag uint16, prevGw, gw, prevIPv6Gw, ipv6Gw string) error {
+	if sw.ofnetAgent == nil {
+		return nil
+	}
+
+	if err := sw.ofnetAgent.UpdateNetworkGateway(pktTag, prevGw, gw); err != nil {
+		log.Errorf("Error updating gateway of vlan %d. Err: %v", pktTag, err)
+		return err
+	}
+	if err := sw.ofnetAgent.UpdateNetworkGateway(pktTag, prevIPv6Gw, ipv6Gw); err != nil {
+		log.Errorf("Error updating ipv6 gateway of vlan %d. Err: %v", pktTag, err)
+		return err
+	}
+
+	return nil
+}
+
 // DeleteNetwork deletes a network/vlan
 func (sw *OvsSwitch) DeleteNetwork(pktTag uint16, extPktTag uint32, gateway string, Vrf string) error {
 	// Delete vlan/vni mapping
@@ -300,7 +339,7 @@ func getOvsPortName(intfName string, skipVethPair bool) string {
 }
 
 // CreatePort creates a port in ovs switch
-func (sw *OvsSwitch) CreatePort(intfName string, cfgEp *mastercfg.CfgEndpointState, pktTag, nwPktTag, burst, dscp int, skipVethPair bool, bandwidth int64) error {
+func (sw *OvsSwitch) CreatePort(intfName string, cfgEp *mastercfg.CfgEndpointState, pktTag, nwPktTag, burst, dscp int, skipVethPair bool, bandwidth int64, mtu int, qos endpointQos) error {
 	var ovsIntfType string
 	var err error
 	vethCreated := false
@@ -362,14 +401,8 @@ func (sw *OvsSwitch) CreatePort(intfName string, cfgEp *mastercfg.CfgEndpointSta
 	// Wait a little for OVS to create the interface
 	time.Sleep(300 * time.Millisecond)
 
-	// Set the link mtu to 1450 to allow for 50 bytes vxlan encap
-	// (inner eth header(14) + outer IP(20) outer UDP(8) + vxlan header(8))
-	if sw.netType == "vxlan" {
-		correctMtu := sw.vxlanEncapMtu - 50 //Include Vxlan header size
-		err = setLinkMtu(intfName, correctMtu)
-	} else {
-		err = setLinkMtu(intfName, sw.vxlanEncapMtu)
-	}
+	// Set the link mtu of the network, it leaves room for the encap
+	err = setLinkMtu(intfName, mtu)
 	if err != nil {
 		log.Errorf("Error setting link %s mtu. Err: %v", intfName, err)
 		return err
@@ -383,6 +416,13 @@ func (sw *OvsSwitch) CreatePort(intfName string, cfgEp *mastercfg.CfgEndpointSta
 	}
 
 	// Add the endpoint to ofnet
+	err = sw.addLocalEndpoint(ovsPortName, cfgEp, pktTag, nwPktTag, dscp, qos)
+	return err
+}
+
+// addLocalEndpoint shapes the traffic of an OVS port and adds its endpoint
+// to ofnet
+func (sw *OvsSwitch) addLocalEndpoint(ovsPortName string, cfgEp *mastercfg.CfgEndpointState, pktTag, nwPktTag, dscp int, qos endpointQos) error {
 	// Get the openflow port number for the interface
 	ofpPort, err := sw.ovsdbDriver.GetOfpPortNo(ovsPortName)
 	if err != nil {
@@ -390,6 +430,12 @@ func (sw *OvsSwitch) CreatePort(intfName string, cfgEp *mastercfg.CfgEndpointSta
 		return err
 	}
 
+	// Shape the endpoint traffic
+	queueID, err := sw.applyEndpointQos(ovsPortName, ofpPort, qos)
+	if err != nil {
+		return err
+	}
+
 	macAddr, _ := net.ParseMAC(cfgEp.MacAddress)
 
 	// Assign an IP based on the intfnumber
@@ -405,6 +451,7 @@ func (sw *OvsSwitch) CreatePort(intfName string, cfgEp *mastercfg.CfgEndpointSta
 		EndpointGroup:     cfgEp.EndpointGroupID,
 		EndpointGroupVlan: uint16(pktTag),
 		Dscp:              dscp,
+		Queue:             queueID,
 		HostPvtIP:         pvtIP,
 	}
 
@@ -412,7 +459,6 @@ func (sw *OvsSwitch) CreatePort(intfName string, cfgEp *mastercfg.CfgEndpointSta
 
 	// Add the local port to ofnet
 	err = sw.ofnetAgent.AddLocalEndpoint(endpoint)
-
 	if err != nil {
 		log.Errorf("Error adding local port %s to ofnet. Err: %v", ovsPortName, err)
 		return err
@@ -420,8 +466,35 @@ func (sw *OvsSwitch) CreatePort(intfName string, cfgEp *mastercfg.CfgEndpointSta
 	return nil
 }
 
+// ReaddPort adds the OVS side of an endpoint veth pair back to the switch
+// when its port was removed from OVS. The veth pair is kept, its other end
+// is in the container.
+func (sw *OvsSwitch) ReaddPort(intfName string, cfgEp *mastercfg.CfgEndpointState, pktTag, nwPktTag, burst, dscp int, bandwidth int64, qos endpointQos) error {
+	ovsPortName := getOvsPortName(intfName, false)
+	if _, err := netlink.LinkByName(ovsPortName); err != nil {
+		return core.Errorf("veth %s of endpoint %s not found. Err: %v", ovsPortName, cfgEp.ID, err)
+	}
+
+	// Forget the endpoint of the removed port, its port number is stale
+	epID := sw.ofnetAgent.GetEndpointIdByIpVrf(net.ParseIP(cfgEp.IPAddress), netTenant(cfgEp.NetID))
+	if err := sw.ofnetAgent.RemoveLocalEndpointByID(epID); err != nil {
+		log.Warnf("Error removing endpoint %s of port %s from ofnet. Err: %v", epID, ovsPortName, err)
+	}
+
+	err := sw.ovsdbDriver.CreatePort(ovsPortName, "", cfgEp.ID, pktTag, burst, bandwidth)
+	if err != nil {
+		log.Errorf("Error adding port %s back to OVS. Err: %v", ovsPortName, err)
+		return err
+	}
+
+	// Wait a little for OVS to create the interface
+	time.Sleep(300 * time.Millisecond)
+
+	return sw.addLocalEndpoint(ovsPortName, cfgEp, pktTag, nwPktTag, dscp, qos)
+}
+
 // UpdateEndpoint updates endpoint state
-func (sw *OvsSwitch) UpdateEndpoint(ovsPortName string, burst, dscp int, epgBandwidth int64) error {
+func (sw *OvsSwitch) UpdateEndpoint(ovsPortName string, burst, dscp int, epgBandwidth int64, qos endpointQos) error {
 	// update bandwidth
 	err := sw.ovsdbDriver.UpdatePolicingRate(ovsPortName, burst, epgBandwidth)
 	if err != nil {
@@ -435,10 +508,17 @@ func (sw *OvsSwitch) UpdateEndpoint(ovsPortName string, burst, dscp int, epgBand
 		return err
 	}
 
+	// update shaping
+	queueID, err := sw.applyEndpointQos(ovsPortName, ofpPort, qos)
+	if err != nil {
+		return err
+	}
+
 	// Build the updated endpoint info
 	endpoint := ofnet.EndpointInfo{
 		PortNo: ofpPort,
 		Dscp:   dscp,
+		Queue:  queueID,
 	}
 
 	// update endpoint state in ofnet
@@ -452,7 +532,7 @@ func (sw *OvsSwitch) UpdateEndpoint(ovsPortName string, burst, dscp int, epgBand
 }
 
 // UpdatePort updates an OVS port without creating it
-func (sw *OvsSwitch) UpdatePort(intfName string, cfgEp *mastercfg.CfgEndpointState, pktTag, nwPktTag, dscp int, skipVethPair bool) error {
+func (sw *OvsSwitch) UpdatePort(intfName string, cfgEp *mastercfg.CfgEndpointState, pktTag, nwPktTag, dscp int, skipVethPair bool, qos endpointQos) error {
 
 	// Get OVS port name
 	ovsPortName := getOvsPortName(intfName, skipVethPair)
@@ -465,6 +545,12 @@ func (sw *OvsSwitch) UpdatePort(intfName string, cfgEp *mastercfg.CfgEndpointSta
 		return err
 	}
 
+	// Shape the endpoint traffic
+	queueID, err := sw.applyEndpointQos(ovsPortName, ofpPort, qos)
+	if err != nil {
+		return err
+	}
+
 	macAddr, _ := net.ParseMAC(cfgEp.MacAddress)
 
 	// Build the endpoint info
@@ -477,6 +563,7 @@ func (sw *OvsSwitch) UpdatePort(intfName string, cfgEp *mastercfg.CfgEndpointSta
 		EndpointGroup:     cfgEp.EndpointGroupID,
 		EndpointGroupVlan: uint16(pktTag),
 		Dscp:              dscp,
+		Queue:             queueID,
 	}
 
 	// Add the local port to ofnet
@@ -505,19 +592,15 @@ func (sw *OvsSwitch) DeletePort(epOper *drivers.OperEndpointState, skipVethPair
 	// Get the openflow port number for the interface and remove from ofnet
 	ofpPort, err := sw.ovsdbDriver.GetOfpPortNo(ovsPortName)
 	if err == nil {
+		if qerr := sw.removeEndpointQos(ofpPort); qerr != nil {
+			log.Errorf("Error removing the uplink queue of port %s. Err: %v", ovsPortName, qerr)
+		}
 		if sw.ofnetAgent != nil {
 			err = sw.ofnetAgent.RemoveLocalEndpoint(ofpPort)
 		}
 	} else {
 		if sw.ofnetAgent != nil {
-			var tenantName string
-			netParts := strings.Split(epOper.NetID, ".")
-			if len(netParts) == 2 {
-				tenantName = netParts[1]
-			} else {
-				tenantName = "default"
-			}
-			epID := sw.ofnetAgent.GetEndpointIdByIpVrf(net.ParseIP(epOper.IPAddress), tenantName)
+			epID := sw.ofnetAgent.GetEndpointIdByIpVrf(net.ParseIP(epOper.IPAddress), netTenant(epOper.NetID))
 			err = sw.ofnetAgent.RemoveLocalEndpointByID(epID)
 		}
 	}
@@ -546,26 +629,43 @@ func (sw *OvsSwitch) DeletePort(epOper *drivers.OperEndpointState, skipVethPair
 	return err
 }
 
+// netTenant returns the tenant of a network ID
+func netTenant(netID string) string {
+	netParts := strings.Split(netID, ".")
+	if len(netParts) == 2 {
+		return netParts[1]
+	}
+	return "default"
+}
+
 // vxlanIfName returns formatted vxlan interface name
 func vxlanIfName(vtepIP string) string {
 	return fmt.Sprintf(vxlanIfNameFmt, strings.Replace(vtepIP, ".", "", -1))
 }
 
-// CreateVtep creates a VTEP interface
+// geneveIfName returns formatted geneve interface name
+func geneveIfName(vtepIP string) string {
+	return fmt.Sprintf(geneveIfNameFmt, strings.Replace(vtepIP, ".", "", -1))
+}
+
+// CreateVtep creates a VTEP interface. In bridge mode, a geneve interface
+// is created to the VTEP too, for geneve networks.
 func (sw *OvsSwitch) CreateVtep(vtepIP string) error {
 	// Create interface name for VTEP
 	intfName := vxlanIfName(vtepIP)
 
 	log.Infof("Creating VTEP intf %s for IP %s", intfName, vtepIP)
 
-	// Check if it already exists
-	isPresent, vsifName := sw.ovsdbDriver.IsVtepPresent(vtepIP)
-	if !isPresent || (vsifName != intfName) {
-		// Ask ovsdb to create it
-		err := sw.ovsdbDriver.CreateVtep(intfName, vtepIP)
-		if err != nil {
-			log.Errorf("Error creating VTEP port %s. Err: %v", intfName, err)
-		}
+	// Ask ovsdb to create it, if it doesn't exist
+	err := sw.ensureVtep(intfName, "vxlan", vtepIP)
+	if err != nil {
+		log.Errorf("Error creating VTEP port %s. Err: %v", intfName, err)
+	}
+
+	// geneve networks are only supported in bridge mode. Failing to create
+	// the geneve port doesn't affect vxlan networks.
+	if sw.fwdMode == "bridge" {
+		sw.createGeneveVtep(vtepIP)
 	}
 
 	// Wait a little for OVS to create the interface
@@ -579,19 +679,493 @@ func (sw *OvsSwitch) CreateVtep(vtepIP string) error {
 	}
 
 	// Add info about VTEP port to ofnet
-	if sw.ofnetAgent != nil {
-		err = sw.ofnetAgent.AddVtepPort(ofpPort, net.ParseIP(vtepIP))
+	err = sw.addOfnetVtep(ovsVtep{intfName: intfName, intfType: "vxlan", remoteIP: vtepIP}, ofpPort)
+	if err != nil {
+		log.Errorf("Error adding VTEP port %s to ofnet. Err: %v", intfName, err)
+		return err
+	}
+
+	return nil
+}
+
+// addOfnetVtep adds a VTEP port to ofnet. While the tunnels are encrypted
+// and the tunnel to the VTEP has no key yet, the port is kept out of ofnet
+// instead, so that no traffic is sent or accepted in clear text on it.
+// SetEncryption adds it once both hosts agreed on a key.
+func (sw *OvsSwitch) addOfnetVtep(vtep ovsVtep, ofpPort uint32) error {
+	if sw.ofnetAgent == nil {
+		return nil
+	}
+
+	down := sw.vtepKeyPending(vtep.remoteIP)
+	sw.encryptionMutex.Lock()
+	if down {
+		sw.downVteps[vtep.intfName] = true
+	} else {
+		delete(sw.downVteps, vtep.intfName)
+	}
+	sw.encryptionMutex.Unlock()
+
+	if down {
+		log.Warnf("VTEP %s has no encryption key yet, keeping VTEP intf %s down",
+			vtep.remoteIP, vtep.intfName)
+		return nil
+	}
+
+	if vtep.intfType == "geneve" {
+		return sw.ofnetAgent.AddGeneveVtepPort(ofpPort, net.ParseIP(vtep.remoteIP))
+	}
+	return sw.ofnetAgent.AddVtepPort(ofpPort, net.ParseIP(vtep.remoteIP))
+}
+
+// removeOfnetVtep removes a VTEP port from ofnet, unless it is kept down
+func (sw *OvsSwitch) removeOfnetVtep(vtep ovsVtep, ofpPort uint32) error {
+	sw.encryptionMutex.Lock()
+	down := sw.downVteps[vtep.intfName]
+	sw.encryptionMutex.Unlock()
+
+	if sw.ofnetAgent == nil || down {
+		return nil
+	}
+
+	if vtep.intfType == "geneve" {
+		return sw.ofnetAgent.RemoveGeneveVtepPort(ofpPort, net.ParseIP(vtep.remoteIP))
+	}
+	return sw.ofnetAgent.RemoveVtepPort(ofpPort, net.ParseIP(vtep.remoteIP))
+}
+
+// ensureVtep creates a VTEP interface of the type, or updates the IPsec
+// pre-shared key of the existing one
+func (sw *OvsSwitch) ensureVtep(intfName, intfType, vtepIP string) error {
+	psk, _ := sw.vtepPsk(vtepIP)
+
+	isPresent, vsifName := sw.ovsdbDriver.IsVtepPresent(vtepIP, intfType)
+	if !isPresent || (vsifName != intfName) {
+		return sw.ovsdbDriver.CreateVtep(intfName, intfType, vtepIP, psk)
+	}
+
+	for _, vtep := range sw.ovsdbDriver.GetVteps() {
+		if vtep.intfName == intfName && vtep.psk != psk {
+			return sw.ovsdbDriver.UpdateVtepPsk(intfName, intfType, vtepIP, psk)
+		}
+	}
+
+	return nil
+}
+
+// encryptionKeysFile keeps the private keys of the host across restarts, so
+// that the tunnels are not rekeyed when netplugin restarts
+var encryptionKeysFile = "/var/contiv/encryption/keys.json"
+
+// loadEncryptionKeys reads the private keys of the host, by key generation
+func loadEncryptionKeys() map[int]string {
+	keys := make(map[int]string)
+
+	data, err := ioutil.ReadFile(encryptionKeysFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Error reading encryption keys from %s. Err: %v", encryptionKeysFile, err)
+		}
+		return keys
+	}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		log.Errorf("Error decoding encryption keys from %s. Err: %v", encryptionKeysFile, err)
+		return make(map[int]string)
+	}
+
+	return keys
+}
+
+// saveEncryptionKeys writes the private keys of the host, readable by root
+// only
+func saveEncryptionKeys(keys map[int]string) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(encryptionKeysFile), 0700); err != nil {
+		return err
+	}
+	tmpFile := encryptionKeysFile + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile, encryptionKeysFile)
+}
+
+// vtepPsk returns the IPsec pre-shared key of the tunnel to a VTEP, and its
+// key generation. It is the latest generation both hosts have a key for,
+// and the peer has seen the key of this host for. During a rotation the
+// tunnel keeps its key until the peer has published a new key and
+// acknowledged the new key of the host, so that both ends move to the new
+// generation together. The key is empty if the tunnels aren't encrypted,
+// or the hosts have not agreed on a key yet.
+func (sw *OvsSwitch) vtepPsk(vtepIP string) (string, int) {
+	sw.encryptionMutex.Lock()
+	defer sw.encryptionMutex.Unlock()
+
+	if sw.encryption == nil || !sw.encryption.Enabled() {
+		return "", 0
+	}
+
+	peer := sw.encryptionHosts[vtepIP]
+	if peer == nil {
+		return "", 0
+	}
+	generation := 0
+	for _, key := range peer.Keys {
+		if key.Generation > generation && key.Generation <= peer.Seen[sw.localIP] &&
+			sw.encryptionKeys[key.Generation] != "" {
+			generation = key.Generation
+		}
+	}
+	if generation == 0 {
+		return "", 0
+	}
+
+	psk, err := mastercfg.PeerKey(sw.encryptionKeys[generation], peer.PublicKey(generation))
+	if err != nil {
+		log.Errorf("Error deriving the key of the tunnel to %s. Err: %v", vtepIP, err)
+		return "", 0
+	}
+
+	return psk, generation
+}
+
+// vtepKeyPending returns true if the tunnels are encrypted, and the tunnel
+// to a VTEP has no key yet
+func (sw *OvsSwitch) vtepKeyPending(vtepIP string) bool {
+	psk, _ := sw.vtepPsk(vtepIP)
+
+	sw.encryptionMutex.Lock()
+	defer sw.encryptionMutex.Unlock()
+
+	return psk == "" && sw.encryption != nil && sw.encryption.Enabled()
+}
+
+// updateEncryptionKeys generates the private key of the current key
+// generation. The keys of the other generations are retired once every
+// peer has a key of the current one and has seen the key of the host,
+// until then the tunnels to the peers still on an older generation keep
+// using it. It returns the public keys the host is to publish, and the
+// key generations of the peers it acknowledges. Called with the encryption
+// mutex held.
+func (sw *OvsSwitch) updateEncryptionKeys(vteps []ovsVtep) ([]mastercfg.EncryptionHostKey, map[string]int, error) {
+	if sw.encryptionKeys == nil {
+		sw.encryptionKeys = loadEncryptionKeys()
+	}
+
+	changed := false
+	generation := sw.encryption.KeyGeneration
+	if sw.encryption.Enabled() {
+		if sw.encryptionKeys[generation] == "" {
+			privateKey, err := mastercfg.NewEncryptionKey()
+			if err != nil {
+				return nil, nil, err
+			}
+			sw.encryptionKeys[generation] = privateKey
+			changed = true
+
+			log.Infof("Generated the encryption key of key generation %d", generation)
+		}
+
+		retire := true
+		for _, vtep := range vteps {
+			peer := sw.encryptionHosts[vtep.remoteIP]
+			if peer == nil || peer.PublicKey(generation) == "" || peer.Seen[sw.localIP] < generation {
+				retire = false
+				break
+			}
+		}
+		for keyGeneration := range sw.encryptionKeys {
+			if retire && keyGeneration != generation {
+				delete(sw.encryptionKeys, keyGeneration)
+				changed = true
+
+				log.Infof("Retired the encryption key of key generation %d", keyGeneration)
+			}
+		}
+	}
+
+	if changed {
+		if err := saveEncryptionKeys(sw.encryptionKeys); err != nil {
+			log.Errorf("Error saving encryption keys to %s. Err: %v", encryptionKeysFile, err)
+			return nil, nil, err
+		}
+	}
+
+	hostKeys := []mastercfg.EncryptionHostKey{}
+	for keyGeneration, privateKey := range sw.encryptionKeys {
+		publicKey, err := mastercfg.EncryptionPublicKey(privateKey)
 		if err != nil {
-			log.Errorf("Error adding VTEP port %s to ofnet. Err: %v", intfName, err)
-			return err
+			return nil, nil, err
+		}
+		hostKeys = append(hostKeys, mastercfg.EncryptionHostKey{
+			Generation: keyGeneration,
+			PublicKey:  publicKey,
+		})
+	}
+	sort.Sort(hostKeyList(hostKeys))
+
+	seen := make(map[string]int)
+	for hostIP, host := range sw.encryptionHosts {
+		if generation := host.LatestGeneration(); hostIP != sw.localIP && generation > 0 {
+			seen[hostIP] = generation
 		}
 	}
 
+	return hostKeys, seen, nil
+}
+
+// hostKeyList sorts the public keys of a host by key generation
+type hostKeyList []mastercfg.EncryptionHostKey
+
+func (l hostKeyList) Len() int           { return len(l) }
+func (l hostKeyList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l hostKeyList) Less(i, j int) bool { return l[i].Generation < l[j].Generation }
+
+// SetEncryption sets the encryption of the tunnels and the public keys of
+// the hosts, by VTEP IP, and rekeys the tunnels to the existing VTEPs. The
+// tunnels without a key are kept down while encryption is enabled. It
+// returns the host state to publish, with the public keys of the host and
+// the key generations of the peers it has seen.
+func (sw *OvsSwitch) SetEncryption(encCfg *mastercfg.CfgEncryptionState,
+	hosts map[string]*mastercfg.CfgEncryptionHostState) (*mastercfg.CfgEncryptionHostState, error) {
+	vteps := sw.ovsdbDriver.GetVteps()
+
+	sw.encryptionMutex.Lock()
+	sw.encryption = encCfg
+	sw.encryptionHosts = hosts
+	hostKeys, seen, err := sw.updateEncryptionKeys(vteps)
+	sw.encryptionMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var retErr error
+	for _, vtep := range vteps {
+		psk, generation := sw.vtepPsk(vtep.remoteIP)
+		pending := psk == "" && encCfg.Enabled()
+
+		sw.encryptionMutex.Lock()
+		down := sw.downVteps[vtep.intfName]
+		sw.encryptionMutex.Unlock()
+
+		// take the tunnel down before its key is removed
+		if pending && !down {
+			log.Warnf("VTEP %s has no encryption key yet, taking VTEP intf %s down",
+				vtep.remoteIP, vtep.intfName)
+			if err := sw.setVtepDown(vtep); err != nil {
+				log.Errorf("Error taking VTEP port %s down. Err: %v", vtep.intfName, err)
+				retErr = err
+				continue
+			}
+		}
+
+		if vtep.psk != psk {
+			log.Infof("Updating the encryption of VTEP intf %s for IP %s to key generation %d",
+				vtep.intfName, vtep.remoteIP, generation)
+
+			err := sw.ovsdbDriver.UpdateVtepPsk(vtep.intfName, vtep.intfType, vtep.remoteIP, psk)
+			if err != nil {
+				log.Errorf("Error updating the encryption of VTEP port %s. Err: %v", vtep.intfName, err)
+				retErr = err
+				continue
+			}
+		}
+
+		// bring the tunnel up once it has a key
+		if !pending && down {
+			log.Infof("Bringing VTEP intf %s for IP %s up", vtep.intfName, vtep.remoteIP)
+
+			ofpPort, err := sw.ovsdbDriver.GetOfpPortNo(vtep.intfName)
+			if err == nil {
+				err = sw.addOfnetVtep(vtep, ofpPort)
+			}
+			if err != nil {
+				log.Errorf("Error bringing VTEP port %s up. Err: %v", vtep.intfName, err)
+				retErr = err
+			}
+		}
+	}
+
+	hostCfg := &mastercfg.CfgEncryptionHostState{Keys: hostKeys, Seen: seen}
+	hostCfg.ID = sw.localIP
+
+	return hostCfg, retErr
+}
+
+// setVtepDown removes a VTEP port from ofnet, and keeps it out of ofnet
+// until the tunnel has a key
+func (sw *OvsSwitch) setVtepDown(vtep ovsVtep) error {
+	ofpPort, err := sw.ovsdbDriver.GetOfpPortNo(vtep.intfName)
+	if err != nil {
+		return err
+	}
+	if err := sw.removeOfnetVtep(vtep, ofpPort); err != nil {
+		return err
+	}
+
+	sw.encryptionMutex.Lock()
+	sw.downVteps[vtep.intfName] = true
+	sw.encryptionMutex.Unlock()
+
 	return nil
 }
 
+// ipsecPeers returns the peers the host has IPsec security associations
+// with in both directions, ie. the peers the tunnels are encrypted to
+func ipsecPeers(localIP string) (map[string]bool, error) {
+	states, err := netlink.XfrmStateList(netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+
+	outbound := make(map[string]bool)
+	inbound := make(map[string]bool)
+	for _, state := range states {
+		if state.Proto != netlink.XFRM_PROTO_ESP {
+			continue
+		}
+		if state.Src.String() == localIP {
+			outbound[state.Dst.String()] = true
+		}
+		if state.Dst.String() == localIP {
+			inbound[state.Src.String()] = true
+		}
+	}
+
+	peers := make(map[string]bool)
+	for peer := range outbound {
+		if inbound[peer] {
+			peers[peer] = true
+		}
+	}
+
+	return peers, nil
+}
+
+// vtepStatusList sorts the encryption status of the tunnels by peer
+type vtepStatusList []mastercfg.EncryptionPeerStatus
+
+func (l vtepStatusList) Len() int      { return len(l) }
+func (l vtepStatusList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l vtepStatusList) Less(i, j int) bool {
+	if l[i].Peer != l[j].Peer {
+		return l[i].Peer < l[j].Peer
+	}
+	return l[i].Interface < l[j].Interface
+}
+
+// GetEncryptionStatus returns the encryption status of the tunnels to the
+// VTEPs. A tunnel is down while the hosts have not agreed on a key, and
+// pending until its key is set in ovsdb. It is only encrypted once the
+// kernel has IPsec security associations with the peer, as set up by
+// ovs-monitor-ipsec. Until then the tunnel carries no traffic, or carries
+// it in clear text if ovs-monitor-ipsec isn't running.
+func (sw *OvsSwitch) GetEncryptionStatus() []mastercfg.EncryptionPeerStatus {
+	saPeers, err := ipsecPeers(sw.localIP)
+	if err != nil {
+		log.Errorf("Error reading the IPsec security associations. Err: %v", err)
+	}
+
+	status := vtepStatusList{}
+	for _, vtep := range sw.ovsdbDriver.GetVteps() {
+		peerStatus := mastercfg.EncryptionPeerStatus{
+			Peer:      vtep.remoteIP,
+			Interface: vtep.intfName,
+		}
+
+		sw.encryptionMutex.Lock()
+		down := sw.downVteps[vtep.intfName]
+		sw.encryptionMutex.Unlock()
+
+		psk, generation := sw.vtepPsk(vtep.remoteIP)
+		switch {
+		case down:
+			peerStatus.State = "down"
+		case vtep.psk != psk:
+			peerStatus.State = "pending"
+		case psk == "":
+			peerStatus.State = "unencrypted"
+		case saPeers == nil:
+			peerStatus.State = "unknown"
+			peerStatus.KeyGeneration = generation
+		case !saPeers[vtep.remoteIP]:
+			peerStatus.State = "no-ipsec-sa"
+			peerStatus.KeyGeneration = generation
+		default:
+			peerStatus.State = "encrypted"
+			peerStatus.KeyGeneration = generation
+		}
+
+		status = append(status, peerStatus)
+	}
+
+	sort.Sort(status)
+	return status
+}
+
+// createGeneveVtep creates the geneve interface to a VTEP
+func (sw *OvsSwitch) createGeneveVtep(vtepIP string) {
+	intfName := geneveIfName(vtepIP)
+
+	log.Infof("Creating geneve VTEP intf %s for IP %s", intfName, vtepIP)
+
+	err := sw.ensureVtep(intfName, "geneve", vtepIP)
+	if err != nil {
+		log.Errorf("Error creating geneve VTEP port %s. Err: %v", intfName, err)
+	}
+
+	// Wait a little for OVS to create the interface
+	time.Sleep(300 * time.Millisecond)
+
+	ofpPort, err := sw.ovsdbDriver.GetOfpPortNo(intfName)
+	if err != nil {
+		log.Errorf("Could not find the OVS port %s. Err: %v", intfName, err)
+		return
+	}
+
+	err = sw.addOfnetVtep(ovsVtep{intfName: intfName, intfType: "geneve", remoteIP: vtepIP}, ofpPort)
+	if err != nil {
+		log.Errorf("Error adding geneve VTEP port %s to ofnet. Err: %v", intfName, err)
+	}
+}
+
+// deleteGeneveVtep deletes the geneve interface to a VTEP, if any
+func (sw *OvsSwitch) deleteGeneveVtep(vtepIP string) {
+	intfName := geneveIfName(vtepIP)
+
+	if isPresent, _ := sw.ovsdbDriver.IsVtepPresent(vtepIP, "geneve"); !isPresent {
+		return
+	}
+
+	log.Infof("Deleting geneve VTEP intf %s for IP %s", intfName, vtepIP)
+
+	ofpPort, err := sw.ovsdbDriver.GetOfpPortNo(intfName)
+	if err == nil {
+		err = sw.removeOfnetVtep(ovsVtep{intfName: intfName, intfType: "geneve", remoteIP: vtepIP}, ofpPort)
+		if err != nil {
+			log.Errorf("Error deleting geneve VTEP port %s from ofnet. Err: %v", intfName, err)
+		}
+	}
+	sw.encryptionMutex.Lock()
+	delete(sw.downVteps, intfName)
+	sw.encryptionMutex.Unlock()
+
+	err = sw.ovsdbDriver.DeleteVtep(intfName)
+	if err != nil {
+		log.Errorf("Error deleting geneve VTEP port %s. Err: %v", intfName, err)
+	}
+}
+
 // DeleteVtep deletes a VTEP
 func (sw *OvsSwitch) DeleteVtep(vtepIP string) error {
+	// geneve endpoints are uninstalled before the vxlan ones
+	sw.deleteGeneveVtep(vtepIP)
+
 	// Build vtep interface name
 	intfName := vxlanIfName(vtepIP)
 
@@ -605,13 +1179,14 @@ func (sw *OvsSwitch) DeleteVtep(vtepIP string) error {
 	}
 
 	// Add info about VTEP port to ofnet
-	if sw.ofnetAgent != nil {
-		err = sw.ofnetAgent.RemoveVtepPort(ofpPort, net.ParseIP(vtepIP))
-		if err != nil {
-			log.Errorf("Error deleting VTEP port %s to ofnet. Err: %v", intfName, err)
-			return err
-		}
+	err = sw.removeOfnetVtep(ovsVtep{intfName: intfName, intfType: "vxlan", remoteIP: vtepIP}, ofpPort)
+	if err != nil {
+		log.Errorf("Error deleting VTEP port %s to ofnet. Err: %v", intfName, err)
+		return err
 	}
+	sw.encryptionMutex.Lock()
+	delete(sw.downVteps, intfName)
+	sw.encryptionMutex.Unlock()
 
 	// ask ovsdb to delete the VTEP
 	return sw.ovsdbDriver.DeleteVtep(intfName)
@@ -743,6 +1318,14 @@ func (sw *OvsSwitch) AddUplink(uplinkName string, intfList []string) error {
 	sw.uplinkDb.Set(uplinkName, intfList)
 	log.Infof("Added uplink %s to OVS switch %s.", intfList, sw.bridgeName)
 
+	// Set the queues of the shaped endpoints on the uplink, or clear a
+	// stale QoS. The uplink still forwards without them.
+	sw.qosMutex.Lock()
+	if qerr := sw.syncUplinkQos(); qerr != nil {
+		log.Errorf("Error shaping uplink %s. Err: %v", uplinkName, qerr)
+	}
+	sw.qosMutex.Unlock()
+
 	defer func() {
 		if err != nil {
 			sw.uplinkDb.Remove(uplinkName)
@@ -1053,6 +1636,21 @@ func (sw *OvsSwitch) GetEndpointStats() (map[string]*ofnet.OfnetEndpointStats, e
 	return stats, nil
 }
 
+// GetPolicyRuleStats invokes ofnetAgent api
+func (sw *OvsSwitch) GetPolicyRuleStats() (map[string]*ofnet.OfnetPolicyRuleStats, error) {
+	if sw.ofnetAgent == nil {
+		return nil, errors.New("no ofnet agent")
+	}
+
+	stats, err := sw.ofnetAgent.GetPolicyRuleStats()
+	if err != nil {
+		log.Errorf("Error getting policy rule stats: %v", err)
+		return nil, err
+	}
+
+	return stats, nil
+}
+
 // InspectState ireturns ofnet state in json form
 func (sw *OvsSwitch) InspectState() (interface{}, error) {
 	if sw.ofnetAgent == nil {
@@ -1083,3 +1681,25 @@ func (sw *OvsSwitch) AddNameServer(ns ofnet.NameServer) {
 		sw.ofnetAgent.AddNameServer(ns)
 	}
 }
+
+// AddFlowLogger enables the policy flow log on the switch. Packets sent to
+// the controller are rate limited first.
+func (sw *OvsSwitch) AddFlowLogger(fl ofnet.FlowLogger) error {
+	if sw.ofnetAgent == nil {
+		return nil
+	}
+
+	ctrlrPort := vxlanCtrlerPort
+	if sw.netType == "vlan" {
+		ctrlrPort = vlanCtrlerPort
+	}
+	target := fmt.Sprintf("tcp:127.0.0.1:%d", ctrlrPort)
+	err := sw.ovsdbDriver.SetControllerRateLimit(target, flowLogControllerRate, flowLogControllerBurst)
+	if err != nil {
+		log.Errorf("Error rate limiting controller %s. Err: %v", target, err)
+		return err
+	}
+
+	sw.ofnetAgent.AddFlowLogger(fl)
+	return nil
+}
@@ -0,0 +1,124 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+)
+
+// encryptionTestHost is a host of the encryption key exchange test
+type encryptionTestHost struct {
+	sw   *OvsSwitch
+	peer string
+}
+
+// update applies the encryption state and the published host states to a
+// host, and publishes its own state
+func (h *encryptionTestHost) update(t *testing.T, generation int,
+	hosts map[string]*mastercfg.CfgEncryptionHostState) {
+	h.sw.encryptionMutex.Lock()
+	defer h.sw.encryptionMutex.Unlock()
+
+	h.sw.encryption = &mastercfg.CfgEncryptionState{
+		Mode:          mastercfg.EncryptionModeIPsec,
+		KeyGeneration: generation,
+	}
+	h.sw.encryptionHosts = make(map[string]*mastercfg.CfgEncryptionHostState)
+	for hostIP, host := range hosts {
+		h.sw.encryptionHosts[hostIP] = host
+	}
+
+	hostKeys, seen, err := h.sw.updateEncryptionKeys([]ovsVtep{{remoteIP: h.peer}})
+	if err != nil {
+		t.Fatalf("Error updating the encryption keys of %s. Err: %v", h.sw.localIP, err)
+	}
+	hosts[h.sw.localIP] = &mastercfg.CfgEncryptionHostState{Keys: hostKeys, Seen: seen}
+}
+
+// checkPsk verifies the key generation of the tunnel to the peer
+func (h *encryptionTestHost) checkPsk(t *testing.T, generation int) string {
+	psk, pskGeneration := h.sw.vtepPsk(h.peer)
+	if pskGeneration != generation || (psk == "") != (generation == 0) {
+		t.Fatalf("Tunnel of %s to %s is on key generation %d, expected %d",
+			h.sw.localIP, h.peer, pskGeneration, generation)
+	}
+	if pending := h.sw.vtepKeyPending(h.peer); pending != (generation == 0) {
+		t.Fatalf("Tunnel of %s to %s pending: %v", h.sw.localIP, h.peer, pending)
+	}
+
+	return psk
+}
+
+func TestVtepPskRotation(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "encryption")
+	if err != nil {
+		t.Fatalf("Error creating temp dir. Err: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	hostA := &encryptionTestHost{sw: &OvsSwitch{localIP: "10.1.1.1"}, peer: "10.1.1.2"}
+	hostB := &encryptionTestHost{sw: &OvsSwitch{localIP: "10.1.1.2"}, peer: "10.1.1.1"}
+
+	savedKeysFile := encryptionKeysFile
+	defer func() { encryptionKeysFile = savedKeysFile }()
+
+	// each host has its own keys file
+	update := func(h *encryptionTestHost, generation int, hosts map[string]*mastercfg.CfgEncryptionHostState) {
+		encryptionKeysFile = filepath.Join(tmpDir, h.sw.localIP, "keys.json")
+		h.update(t, generation, hosts)
+	}
+	hosts := make(map[string]*mastercfg.CfgEncryptionHostState)
+
+	// the tunnel is kept down until both hosts have seen the key of the other
+	update(hostA, 1, hosts)
+	hostA.checkPsk(t, 0)
+	update(hostB, 1, hosts)
+	hostB.checkPsk(t, 0)
+	update(hostA, 1, hosts)
+	psk := hostA.checkPsk(t, 1)
+	update(hostB, 1, hosts)
+	if hostB.checkPsk(t, 1) != psk {
+		t.Fatalf("Hosts derived different keys of key generation 1")
+	}
+
+	// on rotation, the host that sees the new key of its peer first keeps
+	// the old key until the peer has acknowledged its new key too
+	update(hostA, 2, hosts)
+	hostA.checkPsk(t, 1)
+	update(hostB, 2, hosts)
+	hostB.checkPsk(t, 1)
+	if len(hosts[hostB.sw.localIP].Keys) != 2 {
+		t.Fatalf("Old key retired before the peer moved to the new key generation: %+v",
+			hosts[hostB.sw.localIP])
+	}
+	update(hostA, 2, hosts)
+	psk = hostA.checkPsk(t, 2)
+	update(hostB, 2, hosts)
+	if hostB.checkPsk(t, 2) != psk {
+		t.Fatalf("Hosts derived different keys of key generation 2")
+	}
+
+	for _, h := range []*encryptionTestHost{hostA, hostB} {
+		if keys := hosts[h.sw.localIP].Keys; len(keys) != 1 || keys[0].Generation != 2 {
+			t.Fatalf("Keys of %s not retired: %+v", h.sw.localIP, keys)
+		}
+	}
+}
@@ -17,10 +17,15 @@ const (
 	bridgeTable     = "Bridge"
 	portTable       = "Port"
 	interfaceTable  = "Interface"
+	mirrorTable     = "Mirror"
+	qosTable        = "QoS"
+	queueTable      = "Queue"
 	vlanBridgeName  = "contivVlanBridge"
 	vxlanBridgeName = "contivVxlanBridge"
 	portNameFmt     = "port%d"
 	vxlanIfNameFmt  = "vxif%s"
+	geneveIfNameFmt = "gnvif%s"
+	mirrorIfNameFmt = "mir%08x"
 	maxPortNum      = 0xfffe
 	hostPvtSubnet   = "172.20.0.0/16"
 
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -410,6 +411,39 @@ func (d *OvsdbDriver) GetInterfacesInPort(portName string) []string {
 	return intfList
 }
 
+// GetBridgePorts returns the names of the ports of the bridge
+func (d *OvsdbDriver) GetBridgePorts() []string {
+	d.cacheLock.RLock()
+	defer d.cacheLock.RUnlock()
+
+	var portUUIDs []interface{}
+	for _, row := range d.cache[bridgeTable] {
+		if row.Fields["name"] != d.bridgeName {
+			continue
+		}
+		switch ports := row.Fields["ports"].(type) {
+		case libovsdb.UUID: // single port
+			portUUIDs = append(portUUIDs, ports)
+		case libovsdb.OvsSet:
+			portUUIDs = append(portUUIDs, ports.GoSet...)
+		}
+	}
+
+	portNames := []string{}
+	for _, portUUID := range portUUIDs {
+		uuid, ok := portUUID.(libovsdb.UUID)
+		if !ok {
+			continue
+		}
+		if name, ok := d.cache[portTable][uuid].Fields["name"].(string); ok {
+			portNames = append(portNames, name)
+		}
+	}
+
+	sort.Strings(portNames)
+	return portNames
+}
+
 // GetIntfInfo gets interface information from "Interface" table
 func (d *OvsdbDriver) GetIntfInfo(uuid libovsdb.UUID) libovsdb.Row {
 	d.cacheLock.RLock()
@@ -579,6 +613,338 @@ func (d *OvsdbDriver) UpdatePolicingRate(intfName string, burst int, bandwidth i
 
 }
 
+// QueueConfig is the shaping of the traffic sent to an OVS queue. Rates are
+// in bits per second, zero leaves them unset.
+type QueueConfig struct {
+	MinRate  int64 // rate guaranteed to the queue
+	MaxRate  int64 // rate the queue is shaped to
+	Priority int   // linux-htb priority, 0 is the highest
+}
+
+// ovsRef returns the uuid held by a reference. libovsdb leaves the
+// references nested in a map in their json form.
+func ovsRef(ref interface{}) (libovsdb.UUID, bool) {
+	switch ref := ref.(type) {
+	case libovsdb.UUID:
+		return ref, true
+	case []interface{}:
+		if len(ref) == 2 && ref[0] == "uuid" {
+			if uuid, ok := ref[1].(string); ok {
+				return libovsdb.UUID{GoUuid: uuid}, true
+			}
+		}
+	}
+
+	return libovsdb.UUID{}, false
+}
+
+// qosDeleteOps returns the operations deleting the QoS of a port and its
+// queues. Both are root tables, ovsdb doesn't garbage collect them.
+func (d *OvsdbDriver) qosDeleteOps(portName string) []libovsdb.Operation {
+	d.cacheLock.RLock()
+	defer d.cacheLock.RUnlock()
+
+	ops := []libovsdb.Operation{}
+	for _, row := range d.cache[portTable] {
+		if row.Fields["name"] != portName {
+			continue
+		}
+		qosUUID, found := ovsRef(row.Fields["qos"])
+		if !found {
+			break
+		}
+
+		if queues, ok := d.cache[qosTable][qosUUID].Fields["queues"].(libovsdb.OvsMap); ok {
+			for _, queueRef := range queues.GoMap {
+				if queueUUID, found := ovsRef(queueRef); found {
+					ops = append(ops, libovsdb.Operation{
+						Op:    "delete",
+						Table: queueTable,
+						Where: []interface{}{libovsdb.NewCondition("_uuid", "==", queueUUID)},
+					})
+				}
+			}
+		}
+		ops = append(ops, libovsdb.Operation{
+			Op:    "delete",
+			Table: qosTable,
+			Where: []interface{}{libovsdb.NewCondition("_uuid", "==", qosUUID)},
+		})
+		break
+	}
+
+	return ops
+}
+
+// ovsQos is the QoS of a port in the OVSDB cache, with its queues by queue id
+type ovsQos struct {
+	uuid    libovsdb.UUID
+	maxRate string
+	queues  map[uint32]ovsQueue
+}
+
+// ovsQueue is a queue of a QoS in the OVSDB cache
+type ovsQueue struct {
+	uuid   libovsdb.UUID
+	config map[string]string
+}
+
+// ovsStringMap converts a string map read from the OVSDB cache
+func ovsStringMap(value interface{}) map[string]string {
+	strMap := make(map[string]string)
+	if ovsMap, ok := value.(libovsdb.OvsMap); ok {
+		for key, val := range ovsMap.GoMap {
+			keyStr, keyOk := key.(string)
+			valStr, valOk := val.(string)
+			if keyOk && valOk {
+				strMap[keyStr] = valStr
+			}
+		}
+	}
+
+	return strMap
+}
+
+// portQos returns the QoS of a port from the cache, nil when it has none
+func (d *OvsdbDriver) portQos(portName string) *ovsQos {
+	d.cacheLock.RLock()
+	defer d.cacheLock.RUnlock()
+
+	for _, row := range d.cache[portTable] {
+		if row.Fields["name"] != portName {
+			continue
+		}
+		qosUUID, found := ovsRef(row.Fields["qos"])
+		if !found {
+			return nil
+		}
+
+		qosRow := d.cache[qosTable][qosUUID]
+		qos := &ovsQos{
+			uuid:    qosUUID,
+			maxRate: ovsStringMap(qosRow.Fields["other_config"])["max-rate"],
+			queues:  make(map[uint32]ovsQueue),
+		}
+		if queues, ok := qosRow.Fields["queues"].(libovsdb.OvsMap); ok {
+			for queueID, queueRef := range queues.GoMap {
+				// integers are decoded from the json updates as floats
+				id, ok := queueID.(float64)
+				queueUUID, found := ovsRef(queueRef)
+				if !ok || !found {
+					continue
+				}
+				qos.queues[uint32(id)] = ovsQueue{
+					uuid:   queueUUID,
+					config: ovsStringMap(d.cache[queueTable][queueUUID].Fields["other_config"]),
+				}
+			}
+		}
+		return qos
+	}
+
+	return nil
+}
+
+// queueOtherConfig returns the other_config column of a queue
+func queueOtherConfig(queueCfg QueueConfig) map[string]string {
+	queueConfig := map[string]string{
+		"priority": strconv.Itoa(queueCfg.Priority),
+	}
+	if queueCfg.MinRate != 0 {
+		queueConfig["min-rate"] = strconv.FormatInt(queueCfg.MinRate, 10)
+	}
+	if queueCfg.MaxRate != 0 {
+		queueConfig["max-rate"] = strconv.FormatInt(queueCfg.MaxRate, 10)
+	}
+
+	return queueConfig
+}
+
+// queueInsertOp returns the operation inserting a queue, it is referred to
+// by the returned uuid name
+func queueInsertOp(queueID uint32, queueCfg QueueConfig) (libovsdb.Operation, libovsdb.UUID, error) {
+	queueUUIDStr := fmt.Sprintf("contivQueue%d", queueID)
+	otherConfig, err := libovsdb.NewOvsMap(queueOtherConfig(queueCfg))
+	if err != nil {
+		return libovsdb.Operation{}, libovsdb.UUID{}, err
+	}
+
+	return libovsdb.Operation{
+		Op:       "insert",
+		Table:    queueTable,
+		Row:      map[string]interface{}{"other_config": otherConfig},
+		UUIDName: queueUUIDStr,
+	}, libovsdb.UUID{GoUuid: queueUUIDStr}, nil
+}
+
+// qosUpdateOps returns the operations updating a QoS in place. Only the
+// queues that changed are written, the QoS row is mutated so that the other
+// queues keep their classes.
+func qosUpdateOps(qos *ovsQos, maxRate int64, queues map[uint32]QueueConfig) ([]libovsdb.Operation, error) {
+	ops := []libovsdb.Operation{}
+	addRefs := make(map[interface{}]interface{})
+	delRefs := make(map[interface{}]interface{})
+
+	for queueID, queueCfg := range queues {
+		oldQueue, found := qos.queues[queueID]
+		if !found {
+			insertOp, queueUUID, err := queueInsertOp(queueID, queueCfg)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, insertOp)
+			addRefs[queueID] = queueUUID
+			continue
+		}
+
+		queueConfig := queueOtherConfig(queueCfg)
+		if reflect.DeepEqual(oldQueue.config, queueConfig) {
+			continue
+		}
+		otherConfig, err := libovsdb.NewOvsMap(queueConfig)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, libovsdb.Operation{
+			Op:    "update",
+			Table: queueTable,
+			Row:   map[string]interface{}{"other_config": otherConfig},
+			Where: []interface{}{libovsdb.NewCondition("_uuid", "==", oldQueue.uuid)},
+		})
+	}
+
+	// queues are a root table, the removed ones are deleted
+	deleteOps := []libovsdb.Operation{}
+	for queueID, oldQueue := range qos.queues {
+		if _, found := queues[queueID]; found {
+			continue
+		}
+		delRefs[queueID] = oldQueue.uuid
+		deleteOps = append(deleteOps, libovsdb.Operation{
+			Op:    "delete",
+			Table: queueTable,
+			Where: []interface{}{libovsdb.NewCondition("_uuid", "==", oldQueue.uuid)},
+		})
+	}
+
+	mutations := []interface{}{}
+	if len(delRefs) != 0 {
+		mutations = append(mutations, libovsdb.NewMutation("queues", "delete", &libovsdb.OvsMap{GoMap: delRefs}))
+	}
+	if len(addRefs) != 0 {
+		mutations = append(mutations, libovsdb.NewMutation("queues", "insert", &libovsdb.OvsMap{GoMap: addRefs}))
+	}
+
+	maxRateStr := ""
+	if maxRate != 0 {
+		maxRateStr = strconv.FormatInt(maxRate, 10)
+	}
+	if maxRateStr != qos.maxRate {
+		// the old rate is deleted by key, an empty map would be marshalled
+		// as null
+		mutations = append(mutations, libovsdb.NewMutation("other_config", "delete",
+			&libovsdb.OvsSet{GoSet: []interface{}{"max-rate"}}))
+		if maxRateStr != "" {
+			otherConfig, err := libovsdb.NewOvsMap(map[string]string{"max-rate": maxRateStr})
+			if err != nil {
+				return nil, err
+			}
+			mutations = append(mutations, libovsdb.NewMutation("other_config", "insert", otherConfig))
+		}
+	}
+
+	if len(mutations) != 0 {
+		ops = append(ops, libovsdb.Operation{
+			Op:        "mutate",
+			Table:     qosTable,
+			Mutations: mutations,
+			Where:     []interface{}{libovsdb.NewCondition("_uuid", "==", qos.uuid)},
+		})
+	}
+
+	return append(ops, deleteOps...), nil
+}
+
+// SetPortQos shapes the traffic sent on a port with a linux-htb QoS. The
+// queues are keyed by queue id. maxRate caps the port, zero leaves it at the
+// link speed. A QoS the port already has is updated in place.
+func (d *OvsdbDriver) SetPortQos(portName string, maxRate int64, queues map[uint32]QueueConfig) error {
+	if qos := d.portQos(portName); qos != nil {
+		ops, err := qosUpdateOps(qos, maxRate, queues)
+		if err != nil || len(ops) == 0 {
+			return err
+		}
+		return d.performOvsdbOps(ops)
+	}
+
+	var err error
+	ops := []libovsdb.Operation{}
+
+	// insert the queues
+	queueRefs := make(map[interface{}]interface{})
+	for queueID, queueCfg := range queues {
+		insertOp, queueUUID, err := queueInsertOp(queueID, queueCfg)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, insertOp)
+		queueRefs[queueID] = queueUUID
+	}
+
+	// insert the QoS, an empty map must not be marshalled as null
+	qosUUIDStr := "contivQos"
+	qos := make(map[string]interface{})
+	qos["type"] = "linux-htb"
+	if maxRate != 0 {
+		qos["other_config"], err = libovsdb.NewOvsMap(map[string]string{
+			"max-rate": strconv.FormatInt(maxRate, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(queueRefs) != 0 {
+		qos["queues"] = &libovsdb.OvsMap{GoMap: queueRefs}
+	}
+	ops = append(ops, libovsdb.Operation{
+		Op:       "insert",
+		Table:    qosTable,
+		Row:      qos,
+		UUIDName: qosUUIDStr,
+	})
+
+	// attach it to the port
+	port := make(map[string]interface{})
+	port["qos"] = libovsdb.UUID{GoUuid: qosUUIDStr}
+	ops = append(ops, libovsdb.Operation{
+		Op:    "update",
+		Table: portTable,
+		Row:   port,
+		Where: []interface{}{libovsdb.NewCondition("name", "==", portName)},
+	})
+
+	return d.performOvsdbOps(ops)
+}
+
+// ClearPortQos removes the QoS of a port
+func (d *OvsdbDriver) ClearPortQos(portName string) error {
+	deleteOps := d.qosDeleteOps(portName)
+	if len(deleteOps) == 0 {
+		return nil
+	}
+
+	port := make(map[string]interface{})
+	port["qos"] = &libovsdb.OvsSet{GoSet: []interface{}{}}
+	updateOp := libovsdb.Operation{
+		Op:    "update",
+		Table: portTable,
+		Row:   port,
+		Where: []interface{}{libovsdb.NewCondition("name", "==", portName)},
+	}
+
+	return d.performOvsdbOps(append([]libovsdb.Operation{updateOp}, deleteOps...))
+}
+
 // DeletePort deletes a port from OVS
 func (d *OvsdbDriver) DeletePort(intfName string) error {
 	portUUIDStr := intfName
@@ -623,19 +989,43 @@ func (d *OvsdbDriver) DeletePort(intfName string) error {
 		Where:     []interface{}{condition},
 	}
 
-	// Perform OVS transaction
+	// Perform OVS transaction, the QoS of the port goes with it
 	operations := []libovsdb.Operation{intfOp, portOp, mutateOp}
+	operations = append(operations, d.qosDeleteOps(intfName)...)
 	return d.performOvsdbOps(operations)
 }
 
-// CreateVtep creates a VTEP port on the OVS
-func (d *OvsdbDriver) CreateVtep(intfName string, vtepRemoteIP string) error {
+// vtepOptions returns the options of a VTEP interface. A pre-shared key
+// makes ovs-monitor-ipsec encrypt the tunnel in IPsec transport mode.
+func (d *OvsdbDriver) vtepOptions(intfType, vtepRemoteIP, psk string) (*libovsdb.OvsMap, error) {
+	intfOptions := make(map[string]interface{})
+	intfOptions["remote_ip"] = vtepRemoteIP
+	intfOptions["key"] = "flow"    // Insert VNI per flow
+	intfOptions["tos"] = "inherit" // Copy DSCP from inner to outer IP header
+	if intfType == "vxlan" {
+		intfOptions["dst_port"] = d.vxlanUDPPort // Set the UDP port for VXLAN
+	}
+	if psk != "" {
+		intfOptions["psk"] = psk
+	}
+
+	options, err := libovsdb.NewOvsMap(intfOptions)
+	if err != nil {
+		log.Errorf("error '%s' creating options from %v \n", err, intfOptions)
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// CreateVtep creates a VTEP port on the OVS. intfType is vxlan or geneve,
+// the tunnel is encrypted if psk is set
+func (d *OvsdbDriver) CreateVtep(intfName, intfType, vtepRemoteIP, psk string) error {
 	portUUIDStr := intfName
 	intfUUIDStr := fmt.Sprintf("Intf%s", intfName)
 	portUUID := []libovsdb.UUID{{GoUuid: portUUIDStr}}
 	intfUUID := []libovsdb.UUID{{GoUuid: intfUUIDStr}}
 	opStr := "insert"
-	intfType := "vxlan"
 	var err error
 
 	// insert/delete a row in Interface table
@@ -644,15 +1034,8 @@ func (d *OvsdbDriver) CreateVtep(intfName string, vtepRemoteIP string) error {
 	intf["type"] = intfType
 
 	// Special handling for VTEP ports
-	intfOptions := make(map[string]interface{})
-	intfOptions["remote_ip"] = vtepRemoteIP
-	intfOptions["key"] = "flow"              // Insert VNI per flow
-	intfOptions["tos"] = "inherit"           // Copy DSCP from inner to outer IP header
-	intfOptions["dst_port"] = d.vxlanUDPPort // Set the UDP port for VXLAN
-
-	intf["options"], err = libovsdb.NewOvsMap(intfOptions)
+	intf["options"], err = d.vtepOptions(intfType, vtepRemoteIP, psk)
 	if err != nil {
-		log.Errorf("error '%s' creating options from %v \n", err, intfOptions)
 		return err
 	}
 
@@ -703,6 +1086,331 @@ func (d *OvsdbDriver) DeleteVtep(intfName string) error {
 	return d.DeletePort(intfName)
 }
 
+// UpdateVtepPsk replaces the IPsec pre-shared key of a VTEP port, an empty
+// key disables the encryption of the tunnel
+func (d *OvsdbDriver) UpdateVtepPsk(intfName, intfType, vtepRemoteIP, psk string) error {
+	options, err := d.vtepOptions(intfType, vtepRemoteIP, psk)
+	if err != nil {
+		return err
+	}
+
+	intf := make(map[string]interface{})
+	intf["options"] = options
+
+	condition := libovsdb.NewCondition("name", "==", intfName)
+	updateOp := libovsdb.Operation{
+		Op:    "update",
+		Table: interfaceTable,
+		Row:   intf,
+		Where: []interface{}{condition},
+	}
+
+	return d.performOvsdbOps([]libovsdb.Operation{updateOp})
+}
+
+// ovsVtep is a VTEP port in the ovsdb cache
+type ovsVtep struct {
+	intfName string
+	intfType string
+	remoteIP string
+	psk      string
+}
+
+// GetVteps returns the vxlan and geneve VTEP ports created by netplugin
+func (d *OvsdbDriver) GetVteps() []ovsVtep {
+	d.cacheLock.RLock()
+	defer d.cacheLock.RUnlock()
+
+	vteps := []ovsVtep{}
+	for _, row := range d.cache[interfaceTable] {
+		optMap, ok := row.Fields["options"].(libovsdb.OvsMap)
+		if !ok {
+			continue
+		}
+		remoteIP, _ := optMap.GoMap["remote_ip"].(string)
+		psk, _ := optMap.GoMap["psk"].(string)
+
+		name, _ := row.Fields["name"].(string)
+		intfType, _ := row.Fields["type"].(string)
+		if !(intfType == "vxlan" && name == vxlanIfName(remoteIP)) &&
+			!(intfType == "geneve" && name == geneveIfName(remoteIP)) {
+			continue
+		}
+
+		vteps = append(vteps, ovsVtep{
+			intfName: name,
+			intfType: intfType,
+			remoteIP: remoteIP,
+			psk:      psk,
+		})
+	}
+
+	return vteps
+}
+
+// getPortUUID returns the uuid of a port. The ovsdb cache is updated
+// asynchronously, so a port that was just created may take a moment to
+// show up.
+func (d *OvsdbDriver) getPortUUID(portName string) (libovsdb.UUID, error) {
+	for retryNo := 0; retryNo < maxOfportRetry; retryNo++ {
+		d.cacheLock.RLock()
+		for uuid, row := range d.cache[portTable] {
+			if row.Fields["name"] == portName {
+				d.cacheLock.RUnlock()
+				return uuid, nil
+			}
+		}
+		d.cacheLock.RUnlock()
+
+		time.Sleep(300 * time.Millisecond)
+	}
+
+	return libovsdb.UUID{}, core.Errorf("port %s not found", portName)
+}
+
+// portUUIDSet returns the set of uuids of ports
+func (d *OvsdbDriver) portUUIDSet(portNames []string) (*libovsdb.OvsSet, error) {
+	// an empty set must not be marshalled as null
+	uuids := []interface{}{}
+	for _, portName := range portNames {
+		uuid, err := d.getPortUUID(portName)
+		if err != nil {
+			return nil, err
+		}
+		uuids = append(uuids, uuid)
+	}
+
+	return &libovsdb.OvsSet{GoSet: uuids}, nil
+}
+
+// bridgeMirrors returns the uuids of the mirrors of the bridge. The cache
+// lock must be held.
+func (d *OvsdbDriver) bridgeMirrors() []libovsdb.UUID {
+	mirrorUUIDs := []libovsdb.UUID{}
+	for _, row := range d.cache[bridgeTable] {
+		if row.Fields["name"] != d.bridgeName {
+			continue
+		}
+		switch mirrors := row.Fields["mirrors"].(type) {
+		case libovsdb.UUID: // single mirror
+			mirrorUUIDs = append(mirrorUUIDs, mirrors)
+		case libovsdb.OvsSet:
+			for _, mirrorUUID := range mirrors.GoSet {
+				if uuid, ok := mirrorUUID.(libovsdb.UUID); ok {
+					mirrorUUIDs = append(mirrorUUIDs, uuid)
+				}
+			}
+		}
+	}
+
+	return mirrorUUIDs
+}
+
+// getMirrorUUID returns the uuid of a mirror of the bridge
+func (d *OvsdbDriver) getMirrorUUID(mirrorName string) (libovsdb.UUID, bool) {
+	d.cacheLock.RLock()
+	defer d.cacheLock.RUnlock()
+
+	for _, uuid := range d.bridgeMirrors() {
+		if d.cache[mirrorTable][uuid].Fields["name"] == mirrorName {
+			return uuid, true
+		}
+	}
+
+	return libovsdb.UUID{}, false
+}
+
+// IsMirrorPresent checks if a mirror exists on the bridge
+func (d *OvsdbDriver) IsMirrorPresent(mirrorName string) bool {
+	_, found := d.getMirrorUUID(mirrorName)
+	return found
+}
+
+// CreateMirror creates or updates a mirror of the bridge. The packets
+// received on the source ports and the packets sent on the destination
+// ports are copied to the output port.
+func (d *OvsdbDriver) CreateMirror(mirrorName string, srcPorts, dstPorts []string, outputPort string) error {
+	var err error
+
+	mirror := make(map[string]interface{})
+	mirror["name"] = mirrorName
+	mirror["select_src_port"], err = d.portUUIDSet(srcPorts)
+	if err != nil {
+		return err
+	}
+	mirror["select_dst_port"], err = d.portUUIDSet(dstPorts)
+	if err != nil {
+		return err
+	}
+	mirror["output_port"], err = d.getPortUUID(outputPort)
+	if err != nil {
+		return err
+	}
+
+	// update the mirror in place if it exists
+	if mirrorUUID, found := d.getMirrorUUID(mirrorName); found {
+		condition := libovsdb.NewCondition("_uuid", "==", mirrorUUID)
+		updateOp := libovsdb.Operation{
+			Op:    "update",
+			Table: mirrorTable,
+			Row:   mirror,
+			Where: []interface{}{condition},
+		}
+
+		return d.performOvsdbOps([]libovsdb.Operation{updateOp})
+	}
+
+	mirrorUUIDStr := "contivMirror"
+	mirrorOp := libovsdb.Operation{
+		Op:       "insert",
+		Table:    mirrorTable,
+		Row:      mirror,
+		UUIDName: mirrorUUIDStr,
+	}
+
+	// mutate the Mirrors column of the row in the Bridge table
+	mutateSet, _ := libovsdb.NewOvsSet([]libovsdb.UUID{{GoUuid: mirrorUUIDStr}})
+	mutation := libovsdb.NewMutation("mirrors", "insert", mutateSet)
+	condition := libovsdb.NewCondition("name", "==", d.bridgeName)
+	mutateOp := libovsdb.Operation{
+		Op:        "mutate",
+		Table:     bridgeTable,
+		Mutations: []interface{}{mutation},
+		Where:     []interface{}{condition},
+	}
+
+	return d.performOvsdbOps([]libovsdb.Operation{mirrorOp, mutateOp})
+}
+
+// DeleteMirror deletes a mirror of the bridge. The mirror row is garbage
+// collected by ovsdb once the bridge no longer refers to it.
+func (d *OvsdbDriver) DeleteMirror(mirrorName string) error {
+	mirrorUUID, found := d.getMirrorUUID(mirrorName)
+	if !found {
+		return nil
+	}
+
+	mutateSet, _ := libovsdb.NewOvsSet([]libovsdb.UUID{mirrorUUID})
+	mutation := libovsdb.NewMutation("mirrors", "delete", mutateSet)
+	condition := libovsdb.NewCondition("name", "==", d.bridgeName)
+	mutateOp := libovsdb.Operation{
+		Op:        "mutate",
+		Table:     bridgeTable,
+		Mutations: []interface{}{mutation},
+		Where:     []interface{}{condition},
+	}
+
+	return d.performOvsdbOps([]libovsdb.Operation{mutateOp})
+}
+
+// GetMirrors returns the names of the mirrors of the bridge
+func (d *OvsdbDriver) GetMirrors() []string {
+	d.cacheLock.RLock()
+	defer d.cacheLock.RUnlock()
+
+	mirrorNames := []string{}
+	for _, uuid := range d.bridgeMirrors() {
+		if name, ok := d.cache[mirrorTable][uuid].Fields["name"].(string); ok {
+			mirrorNames = append(mirrorNames, name)
+		}
+	}
+
+	sort.Strings(mirrorNames)
+	return mirrorNames
+}
+
+// mirrorTunnelOptions returns the options of a GRE or ERSPAN interface
+// carrying mirrored traffic to a remote collector
+func mirrorTunnelOptions(intfType, remoteIP string, erspanID int) map[string]interface{} {
+	intfOptions := make(map[string]interface{})
+	intfOptions["remote_ip"] = remoteIP
+	if intfType == "erspan" {
+		intfOptions["key"] = strconv.Itoa(erspanID) // ERSPAN session id
+		intfOptions["erspan_ver"] = "1"
+	}
+
+	return intfOptions
+}
+
+// CreateMirrorTunnel creates a GRE or ERSPAN port sending the mirrored
+// traffic to a remote collector
+func (d *OvsdbDriver) CreateMirrorTunnel(intfName, intfType, remoteIP string, erspanID int) error {
+	portUUIDStr := intfName
+	intfUUIDStr := fmt.Sprintf("Intf%s", intfName)
+	portUUID := []libovsdb.UUID{{GoUuid: portUUIDStr}}
+	intfUUID := []libovsdb.UUID{{GoUuid: intfUUIDStr}}
+	opStr := "insert"
+	var err error
+
+	intf := make(map[string]interface{})
+	intf["name"] = intfName
+	intf["type"] = intfType
+	intf["options"], err = libovsdb.NewOvsMap(mirrorTunnelOptions(intfType, remoteIP, erspanID))
+	if err != nil {
+		return err
+	}
+
+	intfOp := libovsdb.Operation{
+		Op:       opStr,
+		Table:    interfaceTable,
+		Row:      intf,
+		UUIDName: intfUUIDStr,
+	}
+
+	port := make(map[string]interface{})
+	port["name"] = intfName
+	port["interfaces"], err = libovsdb.NewOvsSet(intfUUID)
+	if err != nil {
+		return err
+	}
+
+	portOp := libovsdb.Operation{
+		Op:       opStr,
+		Table:    portTable,
+		Row:      port,
+		UUIDName: portUUIDStr,
+	}
+
+	// mutate the Ports column of the row in the Bridge table
+	mutateSet, _ := libovsdb.NewOvsSet(portUUID)
+	mutation := libovsdb.NewMutation("ports", opStr, mutateSet)
+	condition := libovsdb.NewCondition("name", "==", d.bridgeName)
+	mutateOp := libovsdb.Operation{
+		Op:        "mutate",
+		Table:     bridgeTable,
+		Mutations: []interface{}{mutation},
+		Where:     []interface{}{condition},
+	}
+
+	operations := []libovsdb.Operation{intfOp, portOp, mutateOp}
+	return d.performOvsdbOps(operations)
+}
+
+// IsMirrorTunnelPresent checks if a mirror tunnel port exists with the
+// given type, collector and ERSPAN session id
+func (d *OvsdbDriver) IsMirrorTunnelPresent(intfName, intfType, remoteIP string, erspanID int) bool {
+	d.cacheLock.RLock()
+	defer d.cacheLock.RUnlock()
+
+	for _, row := range d.cache[interfaceTable] {
+		if row.Fields["name"] != intfName {
+			continue
+		}
+		optMap, ok := row.Fields["options"].(libovsdb.OvsMap)
+		if !ok || row.Fields["type"] != intfType {
+			return false
+		}
+		for key, value := range mirrorTunnelOptions(intfType, remoteIP, erspanID) {
+			if optMap.GoMap[key] != value {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
 // AddController : Add controller configuration to OVS
 func (d *OvsdbDriver) AddController(ipAddr string, portNo uint16) error {
 	// Format target string
@@ -743,6 +1451,25 @@ func (d *OvsdbDriver) AddController(ipAddr string, portNo uint16) error {
 	return d.performOvsdbOps(operations)
 }
 
+// SetControllerRateLimit limits the packets per second OVS sends to a
+// controller, burst packets are queued above the rate
+func (d *OvsdbDriver) SetControllerRateLimit(target string, rate, burst int) error {
+	controller := make(map[string]interface{})
+	controller["controller_rate_limit"] = rate
+	controller["controller_burst_limit"] = burst
+
+	condition := libovsdb.NewCondition("target", "==", target)
+	updateOp := libovsdb.Operation{
+		Op:    "update",
+		Table: "Controller",
+		Row:   controller,
+		Where: []interface{}{condition},
+	}
+
+	operations := []libovsdb.Operation{updateOp}
+	return d.performOvsdbOps(operations)
+}
+
 // RemoveController : Remove controller configuration
 func (d *OvsdbDriver) RemoveController(target string) error {
 	// FIXME:
@@ -857,8 +1584,8 @@ func (d *OvsdbDriver) GetOfpPortNo(intfName string) (uint32, error) {
 	}
 }
 
-// IsVtepPresent checks if VTEP already exists
-func (d *OvsdbDriver) IsVtepPresent(remoteIP string) (bool, string) {
+// IsVtepPresent checks if a VTEP of the type already exists
+func (d *OvsdbDriver) IsVtepPresent(remoteIP, intfType string) (bool, string) {
 	d.cacheLock.RLock()
 	defer d.cacheLock.RUnlock()
 
@@ -869,7 +1596,7 @@ func (d *OvsdbDriver) IsVtepPresent(remoteIP string) (bool, string) {
 				options := row.Fields["options"]
 				switch optMap := options.(type) {
 				case libovsdb.OvsMap:
-					if optMap.GoMap["remote_ip"] == remoteIP {
+					if optMap.GoMap["remote_ip"] == remoteIP && row.Fields["type"] == intfType {
 						value := row.Fields["name"]
 						switch t := value.(type) {
 						case string:
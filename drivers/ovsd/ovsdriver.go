@@ -23,6 +23,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/contiv/netplugin/core"
@@ -58,6 +59,10 @@ type OvsDriverOperState struct {
 	localEpInfoMutex sync.Mutex
 }
 
+func init() {
+	core.RegisterSchema(ovsOperPathPrefix, &OvsDriverOperState{})
+}
+
 // Write the state
 func (s *OvsDriverOperState) Write() error {
 	key := fmt.Sprintf(ovsOperPath, s.ID)
@@ -90,6 +95,11 @@ type OvsDriver struct {
 	lock       sync.Mutex            // lock for modifying shared state
 	HostProxy  *NodeSvcProxy
 	nameServer *nameserver.NetpluginNameServer
+	flowLogger *FlowLogger // policy flow log, nil when disabled
+
+	peers         map[string]bool  // peer hosts, by VTEP IP
+	initTime      time.Time        // time the driver was initialized
+	lastReconcile *ReconcileReport // last reconciliation of OVS
 }
 
 func (d *OvsDriver) getIntfName() (string, error) {
@@ -133,6 +143,8 @@ func (d *OvsDriver) Init(info *core.InstanceInfo) error {
 
 	d.oper.StateDriver = info.StateDriver
 	d.localIP = info.VtepIP
+	d.peers = make(map[string]bool)
+	d.initTime = time.Now()
 	// restore the driver's runtime state if it exists
 	err := d.oper.Read(info.HostLabel)
 	if core.ErrIfKeyExists(err) != nil {
@@ -181,6 +193,8 @@ func (d *OvsDriver) Init(info *core.InstanceInfo) error {
 	if err != nil {
 		log.Fatalf("Error creating vlan switch. Err: %v", err)
 	}
+	d.switchDb["vxlan"].uplinkRate = shapingRate(info.UplinkRate)
+	d.switchDb["vlan"].uplinkRate = shapingRate(info.UplinkRate)
 
 	// Add name server
 	d.nameServer = new(nameserver.NetpluginNameServer)
@@ -189,6 +203,25 @@ func (d *OvsDriver) Init(info *core.InstanceInfo) error {
 	d.switchDb["vlan"].AddNameServer(d.nameServer)
 	log.Infof("initialized nameserver")
 
+	// Set the encryption before the tunnels to the peers are created
+	if err := d.UpdateEncryption(mastercfg.EncryptionStateID); err != nil {
+		log.Errorf("Error setting the encryption of the tunnels. Err: %v", err)
+	}
+
+	// Add flow logger before any policy rules are installed
+	if info.FlowLog != "" {
+		d.flowLogger, err = NewFlowLogger(info.StateDriver, info.HostLabel, info.FlowLog)
+		if err != nil {
+			return err
+		}
+		for _, sw := range []*OvsSwitch{d.switchDb["vxlan"], d.switchDb["vlan"]} {
+			err = sw.AddFlowLogger(d.flowLogger)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// Add uplink to VLAN switch
 	if len(info.UplinkIntf) != 0 {
 		err = d.switchDb["vlan"].AddUplink("uplinkPort", info.UplinkIntf)
@@ -269,6 +302,10 @@ func (d *OvsDriver) Deinit() {
 		d.switchDb["vxlan"].DelHostPort(hostPortName, true)
 		d.switchDb["vxlan"].Delete()
 	}
+
+	if d.flowLogger != nil {
+		d.flowLogger.Close()
+	}
 }
 
 // CreateNetwork creates a network by named identifier
@@ -283,14 +320,42 @@ func (d *OvsDriver) CreateNetwork(id string) error {
 	log.Infof("create net %+v \n", cfgNw)
 
 	// Find the switch based on network type
-	var sw *OvsSwitch
-	if cfgNw.PktTagType == "vxlan" {
-		sw = d.switchDb["vxlan"]
-	} else {
-		sw = d.switchDb["vlan"]
+	sw := d.encapSwitch(cfgNw.PktTagType)
+
+	err = sw.CreateNetwork(uint16(cfgNw.PktTag), uint32(cfgNw.ExtPktTag), cfgNw.Gateway, cfgNw.Tenant, cfgNw.PktTagType)
+	if err != nil || cfgNw.IPv6Gateway == "" {
+		return err
 	}
 
-	return sw.CreateNetwork(uint16(cfgNw.PktTag), uint32(cfgNw.ExtPktTag), cfgNw.Gateway, cfgNw.Tenant)
+	// ofnet takes the ipv4 gateway only when adding the network
+	return sw.UpdateNetworkGateway(uint16(cfgNw.PktTag), "", "", "", cfgNw.IPv6Gateway)
+}
+
+// UpdateNetwork moves the gateways of a network. Subnet changes need no
+// reprogramming, the vlan/vni of the network can't change.
+func (d *OvsDriver) UpdateNetwork(id, prevGateway, prevIPv6Gateway string) error {
+	cfgNw := mastercfg.CfgNetworkState{}
+	cfgNw.StateDriver = d.oper.StateDriver
+	err := cfgNw.Read(id)
+	if err != nil {
+		log.Errorf("Failed to read net %s \n", id)
+		return err
+	}
+	log.Infof("update net %+v \n", cfgNw)
+
+	sw := d.encapSwitch(cfgNw.PktTagType)
+
+	return sw.UpdateNetworkGateway(uint16(cfgNw.PktTag), prevGateway, cfgNw.Gateway,
+		prevIPv6Gateway, cfgNw.IPv6Gateway)
+}
+
+// encapSwitch returns the switch for networks of an encap. geneve networks
+// share the vxlan switch.
+func (d *OvsDriver) encapSwitch(encap string) *OvsSwitch {
+	if encap == "vxlan" || encap == "geneve" {
+		return d.switchDb["vxlan"]
+	}
+	return d.switchDb["vlan"]
 }
 
 // DeleteNetwork deletes a network by named identifier
@@ -298,12 +363,7 @@ func (d *OvsDriver) DeleteNetwork(id, subnet, nwType, encap string, pktTag, extP
 	log.Infof("delete net %s, nwType %s, encap %s, tags: %d/%d", id, nwType, encap, pktTag, extPktTag)
 
 	// Find the switch based on network type
-	var sw *OvsSwitch
-	if encap == "vxlan" {
-		sw = d.switchDb["vxlan"]
-	} else {
-		sw = d.switchDb["vlan"]
-	}
+	sw := d.encapSwitch(encap)
 
 	// Delete infra nw endpoint if present
 	if nwType == "infra" {
@@ -377,12 +437,7 @@ func (d *OvsDriver) CreateEndpoint(id string) error {
 	}
 
 	// Find the switch based on network type
-	var sw *OvsSwitch
-	if pktTagType == "vxlan" {
-		sw = d.switchDb["vxlan"]
-	} else {
-		sw = d.switchDb["vlan"]
-	}
+	sw := d.encapSwitch(pktTagType)
 
 	// Skip Veth pair creation for infra nw endpoints
 	skipVethPair := (cfgNw.NwType == "infra")
@@ -399,7 +454,7 @@ func (d *OvsDriver) CreateEndpoint(id string) error {
 			log.Printf("Found matching oper state for ep %s, noop", id)
 
 			// Ask the switch to update the port
-			err = sw.UpdatePort(operEp.PortName, cfgEp, pktTag, cfgNw.PktTag, dscp, skipVethPair)
+			err = sw.UpdatePort(operEp.PortName, cfgEp, pktTag, cfgNw.PktTag, dscp, skipVethPair, epgQos(cfgEpGroup))
 			if err != nil {
 				log.Errorf("Error creating port %s. Err: %v", intfName, err)
 				return err
@@ -426,8 +481,11 @@ func (d *OvsDriver) CreateEndpoint(id string) error {
 	// Get OVS port name
 	ovsPortName := getOvsPortName(intfName, skipVethPair)
 
+	// Use the mtu of the network, or the uplink mtu less the encap overhead
+	mtu := netutils.GetNetworkMtu(cfgNw.Mtu, sw.uplinkMtu, pktTagType)
+
 	// Ask the switch to create the port
-	err = sw.CreatePort(intfName, cfgEp, pktTag, cfgNw.PktTag, cfgEpGroup.Burst, dscp, skipVethPair, epgBandwidth)
+	err = sw.CreatePort(intfName, cfgEp, pktTag, cfgNw.PktTag, cfgEpGroup.Burst, dscp, skipVethPair, epgBandwidth, mtu, epgQos(cfgEpGroup))
 	if err != nil {
 		log.Errorf("Error creating port %s. Err: %v", intfName, err)
 		return err
@@ -456,7 +514,8 @@ func (d *OvsDriver) CreateEndpoint(id string) error {
 		IntfName:    cfgEp.IntfName,
 		PortName:    intfName,
 		HomingHost:  cfgEp.HomingHost,
-		VtepIP:      cfgEp.VtepIP}
+		VtepIP:      cfgEp.VtepIP,
+		Mtu:         mtu}
 	operEp.StateDriver = d.oper.StateDriver
 	operEp.ID = id
 	err = operEp.Write()
@@ -469,6 +528,9 @@ func (d *OvsDriver) CreateEndpoint(id string) error {
 			operEp.Clear()
 		}
 	}()
+
+	// mirror the new endpoint if a session selects it
+	d.updateMirrorSessions()
 	return nil
 }
 
@@ -499,14 +561,10 @@ func (d *OvsDriver) UpdateEndpointGroup(id string) error {
 			if epInfo.EpgKey == id {
 				log.Debugf("Applying bandwidth: %s on: %s ", cfgEpGroup.Bandwidth, epInfo.Ovsportname)
 				// Find the switch based on network type
-				if epInfo.BridgeType == "vxlan" {
-					sw = d.switchDb["vxlan"]
-				} else {
-					sw = d.switchDb["vlan"]
-				}
+				sw = d.encapSwitch(epInfo.BridgeType)
 
 				// update the endpoint in ovs switch
-				err = sw.UpdateEndpoint(epInfo.Ovsportname, cfgEpGroup.Burst, cfgEpGroup.DSCP, epgBandwidth)
+				err = sw.UpdateEndpoint(epInfo.Ovsportname, cfgEpGroup.Burst, cfgEpGroup.DSCP, epgBandwidth, epgQos(cfgEpGroup))
 				if err != nil {
 					log.Errorf("Error adding bandwidth %v , err: %+v", epgBandwidth, err)
 					return err
@@ -538,12 +596,7 @@ func (d *OvsDriver) DeleteEndpoint(id string) error {
 	}
 
 	// Find the switch based on network type
-	var sw *OvsSwitch
-	if cfgNw.PktTagType == "vxlan" {
-		sw = d.switchDb["vxlan"]
-	} else {
-		sw = d.switchDb["vlan"]
-	}
+	sw := d.encapSwitch(cfgNw.PktTagType)
 
 	skipVethPair := (cfgNw.NwType == "infra")
 	err = sw.DeletePort(&epOper, skipVethPair)
@@ -559,6 +612,8 @@ func (d *OvsDriver) DeleteEndpoint(id string) error {
 		return err
 	}
 
+	// remove the mirrors left without endpoints
+	d.updateMirrorSessions()
 	return nil
 }
 
@@ -584,6 +639,10 @@ func (d *OvsDriver) AddPeerHost(node core.ServiceInfo) error {
 
 	log.Infof("CreatePeerHost for %+v", node)
 
+	d.lock.Lock()
+	d.peers[node.HostAddr] = true
+	d.lock.Unlock()
+
 	// Add the VTEP for the peer in vxlan switch.
 	err := d.switchDb["vxlan"].CreateVtep(node.HostAddr)
 	if err != nil {
@@ -603,6 +662,10 @@ func (d *OvsDriver) DeletePeerHost(node core.ServiceInfo) error {
 
 	log.Infof("DeletePeerHost for %+v", node)
 
+	d.lock.Lock()
+	delete(d.peers, node.HostAddr)
+	d.lock.Unlock()
+
 	// Remove the VTEP for the peer in vxlan switch.
 	err := d.switchDb["vxlan"].DeleteVtep(node.HostAddr)
 	if err != nil {
@@ -773,6 +836,39 @@ func (d *OvsDriver) GetEndpointStats() ([]byte, error) {
 	return jsonStats, nil
 }
 
+// GetPolicyRuleStats gets policy rule hit counters from all ovs instances
+func (d *OvsDriver) GetPolicyRuleStats() ([]byte, error) {
+	vxlanStats, err := d.switchDb["vxlan"].GetPolicyRuleStats()
+	if err != nil {
+		log.Errorf("Error getting vxlan rule stats. Err: %v", err)
+		return []byte{}, err
+	}
+
+	vlanStats, err := d.switchDb["vlan"].GetPolicyRuleStats()
+	if err != nil {
+		log.Errorf("Error getting vlan rule stats. Err: %v", err)
+		return []byte{}, err
+	}
+
+	// rules are installed on both switches, add up the counters
+	for key, val := range vxlanStats {
+		if stats, ok := vlanStats[key]; ok {
+			stats.PacketCount += val.PacketCount
+			stats.ByteCount += val.ByteCount
+		} else {
+			vlanStats[key] = val
+		}
+	}
+
+	jsonStats, err := json.Marshal(vlanStats)
+	if err != nil {
+		log.Errorf("Error encoding rule stats. Err: %v", err)
+		return jsonStats, err
+	}
+
+	return jsonStats, nil
+}
+
 // InspectState returns driver state as json string
 func (d *OvsDriver) InspectState() ([]byte, error) {
 	driverState := make(map[string]interface{})
@@ -792,6 +888,22 @@ func (d *OvsDriver) InspectState() ([]byte, error) {
 	// build the map
 	driverState["vlan"] = vlanState
 	driverState["vxlan"] = vxlanState
+	if d.flowLogger != nil {
+		driverState["flowlog"] = d.flowLogger.Stats()
+	}
+
+	driverState["mirrors"] = map[string][]string{
+		"vlan":  d.switchDb["vlan"].ovsdbDriver.GetMirrors(),
+		"vxlan": d.switchDb["vxlan"].ovsdbDriver.GetMirrors(),
+	}
+
+	// report what a reconciliation would change, without changing it
+	driverState["reconcile"] = d.reconcile(true)
+	d.lock.Lock()
+	if d.lastReconcile != nil {
+		driverState["lastReconcile"] = d.lastReconcile
+	}
+	d.lock.Unlock()
 
 	// json marshall the map
 	jsonState, err := json.Marshal(driverState)
@@ -876,3 +988,93 @@ func (d *OvsDriver) DelPolicyRule(id string) error {
 	log.Debug("OVS driver ignoring PolicyRule delete as it uses ofnet sync")
 	return nil
 }
+
+// UpdateEncryption encrypts the tunnels to the peers in IPsec transport
+// mode, or disables their encryption, per the encryption state and the
+// public keys of the hosts. The public keys of the host, and the key
+// generations of the peers it has seen, are published when they change.
+func (d *OvsDriver) UpdateEncryption(id string) error {
+	encCfg := &mastercfg.CfgEncryptionState{}
+	encCfg.StateDriver = d.oper.StateDriver
+	err := encCfg.Read(id)
+	if err != nil {
+		if core.ErrIfKeyExists(err) != nil {
+			log.Errorf("Failed to read encryption state %s. Err: %v", id, err)
+			return err
+		}
+		encCfg.Mode = mastercfg.EncryptionModeNone
+	}
+
+	hostCfg := &mastercfg.CfgEncryptionHostState{}
+	hostCfg.StateDriver = d.oper.StateDriver
+	hostStates, err := hostCfg.ReadAll()
+	if err != nil && core.ErrIfKeyExists(err) != nil {
+		log.Errorf("Failed to read host encryption states. Err: %v", err)
+		return err
+	}
+	hosts := make(map[string]*mastercfg.CfgEncryptionHostState)
+	for _, state := range hostStates {
+		host := state.(*mastercfg.CfgEncryptionHostState)
+		hosts[host.ID] = host
+	}
+
+	newHostCfg, err := d.switchDb["vxlan"].SetEncryption(encCfg, hosts)
+	if newHostCfg == nil {
+		return err
+	}
+
+	published := &mastercfg.CfgEncryptionHostState{}
+	if host := hosts[d.localIP]; host != nil {
+		published = host
+	}
+	if !sameHostKeys(published.Keys, newHostCfg.Keys) || !sameSeenGenerations(published.Seen, newHostCfg.Seen) {
+		newHostCfg.StateDriver = d.oper.StateDriver
+		if err := newHostCfg.Write(); err != nil {
+			log.Errorf("Failed to publish the encryption keys. Err: %v", err)
+			return err
+		}
+	}
+
+	return err
+}
+
+// sameSeenGenerations compares the key generations two host states have
+// seen of the peers
+func sameSeenGenerations(seen1, seen2 map[string]int) bool {
+	if len(seen1) != len(seen2) {
+		return false
+	}
+	for hostIP, generation := range seen1 {
+		if seen2[hostIP] != generation {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameHostKeys compares two lists of public keys
+func sameHostKeys(keys1, keys2 []mastercfg.EncryptionHostKey) bool {
+	if len(keys1) != len(keys2) {
+		return false
+	}
+	for i := range keys1 {
+		if keys1[i] != keys2[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetEncryptionStatus returns the encryption status of the tunnels to the
+// peers
+func (d *OvsDriver) GetEncryptionStatus() ([]byte, error) {
+	jsonStatus, err := json.Marshal(d.switchDb["vxlan"].GetEncryptionStatus())
+	if err != nil {
+		log.Errorf("Error encoding encryption status. Err: %v", err)
+		return []byte{}, err
+	}
+
+	return jsonStatus, nil
+}
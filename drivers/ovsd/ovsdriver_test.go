@@ -25,6 +25,7 @@ import (
 	"github.com/contiv/netplugin/core"
 	"github.com/contiv/netplugin/netmaster/mastercfg"
 	"github.com/contiv/netplugin/state"
+	"github.com/vishvananda/netlink"
 )
 
 const (
@@ -516,6 +517,68 @@ func TestOvsDriverDeleteEndpoint(t *testing.T) {
 	}
 }
 
+func TestOvsDriverReconcile(t *testing.T) {
+	driver := initOvsDriver(t, bridgeMode, defPvtNW)
+	defer func() { driver.Deinit() }()
+	id := createEpID
+
+	// create network
+	err := driver.CreateNetwork(testOvsNwID)
+	if err != nil {
+		t.Fatalf("network creation failed. Error: %s", err)
+	}
+	defer func() {
+		driver.DeleteNetwork(testOvsNwID, "", "", "", testPktTag, testExtPktTag, testGateway, testTenant)
+	}()
+
+	// create endpoint
+	err = driver.CreateEndpoint(id)
+	if err != nil {
+		t.Fatalf("endpoint creation failed. Error: %s", err)
+	}
+	defer func() { driver.DeleteEndpoint(id) }()
+
+	// wait for the ovsdb cache to be updated, see TestOvsDriverDeleteEndpoint
+	time.Sleep(1 * time.Second)
+
+	report := driver.reconcile(true)
+	if len(report.Issues) != 0 {
+		t.Fatalf("unexpected reconcile issues: %+v", report.Issues)
+	}
+
+	// remove the endpoint port behind the driver's back
+	portName := driver.oper.LocalEpInfo[id].Ovsportname
+	output, err := exec.Command("ovs-vsctl", "del-port", portName).CombinedOutput()
+	if err != nil {
+		t.Fatalf("port deletion failed. Error: %s Output: %s", err, output)
+	}
+	time.Sleep(1 * time.Second)
+
+	report = driver.reconcile(true)
+	if len(report.Issues) != 1 || report.Issues[0].Name != id || report.Issues[0].Action != "add the port back" {
+		t.Fatalf("dry run didn't report the missing port: %+v", report.Issues)
+	}
+
+	err = driver.Reconcile()
+	if err != nil {
+		t.Fatalf("reconcile failed. Error: %s", err)
+	}
+	time.Sleep(1 * time.Second)
+
+	report = driver.reconcile(true)
+	if len(report.Issues) != 0 {
+		t.Fatalf("reconcile didn't fix the issues: %+v", report.Issues)
+	}
+
+	// the port is added back with the veth pair of the endpoint
+	if driver.oper.LocalEpInfo[id].Ovsportname != portName {
+		t.Fatalf("endpoint port %s was replaced by %s", portName, driver.oper.LocalEpInfo[id].Ovsportname)
+	}
+	if _, err := netlink.LinkByName(strings.Replace(portName, "vvport", "vport", 1)); err != nil {
+		t.Fatalf("veth of port %s was deleted. Error: %s", portName, err)
+	}
+}
+
 func TestOvsDriverUplinkBridgeMode(t *testing.T) {
 	driver := initOvsDriver(t, bridgeMode, defPvtNW)
 	defer func() { driver.Deinit() }()
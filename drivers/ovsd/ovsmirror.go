@@ -0,0 +1,223 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsd
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+)
+
+// mirrorIfName returns the name of the tunnel port carrying the traffic of
+// a mirror session to its collector
+func (sw *OvsSwitch) mirrorIfName(sessionID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(sw.bridgeName + "/" + sessionID))
+	return fmt.Sprintf(mirrorIfNameFmt, h.Sum32())
+}
+
+// isBridgePort checks if a port is on the bridge of the switch
+func (sw *OvsSwitch) isBridgePort(portName string) bool {
+	for _, name := range sw.ovsdbDriver.GetBridgePorts() {
+		if name == portName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deleteMirrorTunnel deletes the tunnel port of a mirror session, if any
+func (sw *OvsSwitch) deleteMirrorTunnel(sessionID string) error {
+	intfName := sw.mirrorIfName(sessionID)
+	if !sw.ovsdbDriver.IsPortNamePresent(intfName) {
+		return nil
+	}
+
+	return sw.ovsdbDriver.DeletePort(intfName)
+}
+
+// CreateMirror mirrors the traffic of endpoint ports to the destination of
+// a mirror session. The GRE or ERSPAN port to a collector is created, or
+// recreated when the collector changed.
+func (sw *OvsSwitch) CreateMirror(mirrorCfg *mastercfg.CfgMirrorSessionState, portNames []string) error {
+	outputPort := mirrorCfg.DestinationPort
+	switch mirrorCfg.DestinationType {
+	case mastercfg.MirrorDestLocal:
+		if !sw.isBridgePort(outputPort) {
+			return core.Errorf("mirror destination port %s is not on bridge %s", outputPort, sw.bridgeName)
+		}
+	case mastercfg.MirrorDestGRE, mastercfg.MirrorDestERSPAN:
+		outputPort = sw.mirrorIfName(mirrorCfg.ID)
+		if !sw.ovsdbDriver.IsMirrorTunnelPresent(outputPort, mirrorCfg.DestinationType,
+			mirrorCfg.CollectorIP, mirrorCfg.ErspanID) {
+			if err := sw.deleteMirrorTunnel(mirrorCfg.ID); err != nil {
+				return err
+			}
+			err := sw.ovsdbDriver.CreateMirrorTunnel(outputPort, mirrorCfg.DestinationType,
+				mirrorCfg.CollectorIP, mirrorCfg.ErspanID)
+			if err != nil {
+				log.Errorf("Error creating mirror tunnel %s to %s. Err: %v", outputPort, mirrorCfg.CollectorIP, err)
+				return err
+			}
+		}
+	default:
+		return core.Errorf("invalid mirror destination type %q", mirrorCfg.DestinationType)
+	}
+
+	// the traffic sent by the endpoints is received on their ports
+	var srcPorts, dstPorts []string
+	if mirrorCfg.Direction != mastercfg.MirrorDirectionIngress {
+		srcPorts = portNames
+	}
+	if mirrorCfg.Direction != mastercfg.MirrorDirectionEgress {
+		dstPorts = portNames
+	}
+
+	err := sw.ovsdbDriver.CreateMirror(mirrorCfg.ID, srcPorts, dstPorts, outputPort)
+	if err != nil {
+		log.Errorf("Error creating mirror %s on bridge %s. Err: %v", mirrorCfg.ID, sw.bridgeName, err)
+		return err
+	}
+
+	// a session changed to a local port no longer needs its tunnel
+	if mirrorCfg.DestinationType == mastercfg.MirrorDestLocal {
+		return sw.deleteMirrorTunnel(mirrorCfg.ID)
+	}
+
+	return nil
+}
+
+// DeleteMirror deletes the mirror of a session and its tunnel port
+func (sw *OvsSwitch) DeleteMirror(sessionID string) error {
+	err := sw.ovsdbDriver.DeleteMirror(sessionID)
+	if err != nil {
+		log.Errorf("Error deleting mirror %s on bridge %s. Err: %v", sessionID, sw.bridgeName, err)
+		return err
+	}
+
+	return sw.deleteMirrorTunnel(sessionID)
+}
+
+// mirroredPorts returns the OVS ports of the local endpoints mirrored by a
+// session, by switch type
+func (d *OvsDriver) mirroredPorts(mirrorCfg *mastercfg.CfgMirrorSessionState) (map[string][]string, error) {
+	d.oper.localEpInfoMutex.Lock()
+	epInfos := make(map[string]EpInfo)
+	for id, epInfo := range d.oper.LocalEpInfo {
+		epInfos[id] = *epInfo
+	}
+	d.oper.localEpInfoMutex.Unlock()
+
+	ports := make(map[string][]string)
+	for id, epInfo := range epInfos {
+		cfgEp := &mastercfg.CfgEndpointState{}
+		cfgEp.StateDriver = d.oper.StateDriver
+		err := cfgEp.Read(id)
+		if core.ErrIfKeyExists(err) != nil {
+			return nil, err
+		} else if err != nil {
+			// the endpoint is being deleted
+			continue
+		}
+
+		if !mirrorCfg.MatchesEndpoint(cfgEp) {
+			continue
+		}
+
+		sw := d.encapSwitch(epInfo.BridgeType)
+		ports[sw.netType] = append(ports[sw.netType], epInfo.Ovsportname)
+	}
+
+	for _, portNames := range ports {
+		sort.Strings(portNames)
+	}
+
+	return ports, nil
+}
+
+// applyMirrorSession programs the mirror of a session on the switches with
+// mirrored endpoints, and removes it from the others
+func (d *OvsDriver) applyMirrorSession(mirrorCfg *mastercfg.CfgMirrorSessionState) error {
+	ports, err := d.mirroredPorts(mirrorCfg)
+	if err != nil {
+		return err
+	}
+
+	for _, swType := range []string{"vlan", "vxlan"} {
+		sw := d.switchDb[swType]
+		if len(ports[swType]) == 0 {
+			err = sw.DeleteMirror(mirrorCfg.ID)
+		} else {
+			err = sw.CreateMirror(mirrorCfg, ports[swType])
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateMirrorSessions applies all mirror sessions after the local
+// endpoints changed, so the mirrors follow the endpoints moving between
+// hosts
+func (d *OvsDriver) updateMirrorSessions() {
+	mirrorCfg := &mastercfg.CfgMirrorSessionState{}
+	mirrorCfg.StateDriver = d.oper.StateDriver
+	sessions, err := mirrorCfg.ReadAll()
+	if err != nil {
+		if core.ErrIfKeyExists(err) != nil {
+			log.Errorf("Error reading mirror sessions. Err: %v", err)
+		}
+		return
+	}
+
+	for _, session := range sessions {
+		mirrorCfg := session.(*mastercfg.CfgMirrorSessionState)
+		if err := d.applyMirrorSession(mirrorCfg); err != nil {
+			log.Errorf("Error applying mirror session %s. Err: %v", mirrorCfg.ID, err)
+		}
+	}
+}
+
+// AddMirrorSession programs a created or updated mirror session
+func (d *OvsDriver) AddMirrorSession(id string) error {
+	mirrorCfg := &mastercfg.CfgMirrorSessionState{}
+	mirrorCfg.StateDriver = d.oper.StateDriver
+	err := mirrorCfg.Read(id)
+	if err != nil {
+		log.Errorf("Error reading mirror session %s. Err: %v", id, err)
+		return err
+	}
+
+	return d.applyMirrorSession(mirrorCfg)
+}
+
+// DelMirrorSession removes a deleted mirror session from the switches
+func (d *OvsDriver) DelMirrorSession(id string) error {
+	for _, swType := range []string{"vlan", "vxlan"} {
+		if err := d.switchDb[swType].DeleteMirror(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,409 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/utils/netutils"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// rate of an uplink whose link speed is unknown and not configured. OVS
+	// would cap it at 100Mbps, which virtual NICs easily exceed.
+	defaultUplinkRate = 10 * 1000 * 1000 * 1000
+)
+
+// endpointQos is the shaping of the traffic of an endpoint
+type endpointQos struct {
+	ingressRate int64       // rate of the traffic sent to the endpoint, in bps
+	egress      QueueConfig // shaping of the traffic sent by the endpoint
+}
+
+// egressShaped checks if the traffic sent by the endpoint is shaped
+func (q endpointQos) egressShaped() bool {
+	return q.egress.MinRate != 0 || q.egress.MaxRate != 0
+}
+
+// shapingRate converts a netprofile rate to bits per second
+func shapingRate(rate string) int64 {
+	if rate == "" {
+		return 0
+	}
+
+	return netutils.ConvertBandwidth(rate) * 1000
+}
+
+// dscpPriority maps a DSCP value to a linux-htb priority. The class selector
+// bits give eight priority classes, CS7 being served first and best effort
+// last.
+func dscpPriority(dscp int) int {
+	return 7 - (dscp>>3)&0x7
+}
+
+// epgQos returns the shaping of the endpoints of an endpoint group
+func epgQos(cfgEpGroup *mastercfg.EndpointGroupState) endpointQos {
+	return endpointQos{
+		ingressRate: shapingRate(cfgEpGroup.IngressRate),
+		egress: QueueConfig{
+			MinRate:  shapingRate(cfgEpGroup.EgressMinRate),
+			MaxRate:  shapingRate(cfgEpGroup.EgressRate),
+			Priority: dscpPriority(cfgEpGroup.DSCP),
+		},
+	}
+}
+
+// linkRate returns the speed of a link in bps, zero when it is unknown
+func linkRate(intfName string) int64 {
+	speed, err := ioutil.ReadFile(fmt.Sprintf("/sys/class/net/%s/speed", intfName))
+	if err != nil {
+		return 0
+	}
+
+	mbps, err := strconv.ParseInt(strings.TrimSpace(string(speed)), 10, 64)
+	if err != nil || mbps <= 0 {
+		return 0
+	}
+
+	return mbps * 1000 * 1000
+}
+
+// uplinkRate returns the rate of an uplink, the speed of its slowest link.
+// It is zero when the speed of a link is unknown.
+func uplinkRate(intfList []string) int64 {
+	var rate int64
+	for _, intf := range intfList {
+		intfRate := linkRate(intf)
+		if intfRate == 0 {
+			return 0
+		}
+		if rate == 0 || intfRate < rate {
+			rate = intfRate
+		}
+	}
+
+	return rate
+}
+
+// withoutMinRates returns the queues without their min rate, and whether a
+// queue had one
+func withoutMinRates(queues map[uint32]QueueConfig) (map[uint32]QueueConfig, bool) {
+	minRates := false
+	maxQueues := make(map[uint32]QueueConfig)
+	for queueID, queueCfg := range queues {
+		if queueCfg.MinRate != 0 {
+			minRates = true
+			queueCfg.MinRate = 0
+		}
+		maxQueues[queueID] = queueCfg
+	}
+
+	return maxQueues, minRates
+}
+
+// syncUplinkQos sets the queues of the shaped endpoints on the uplinks.
+// Queue 0 carries the rest of the traffic at the best effort priority. The
+// uplinks lose their QoS once no endpoint is shaped. Min rates are only
+// guaranteed on uplinks whose rate is known or configured. qosMutex must be
+// held.
+func (sw *OvsSwitch) syncUplinkQos() error {
+	queues := map[uint32]QueueConfig{0: {Priority: dscpPriority(0)}}
+	for queueID, queueCfg := range sw.egressQueues {
+		queues[queueID] = queueCfg
+	}
+
+	for uplinkObj := range sw.uplinkDb.IterBuffered() {
+		intfList := uplinkObj.Val.([]string)
+		portName := uplinkObj.Key
+		if len(intfList) == 1 {
+			portName = intfList[0]
+		}
+
+		var err error
+		if len(sw.egressQueues) == 0 {
+			err = sw.ovsdbDriver.ClearPortQos(portName)
+		} else {
+			rate := uplinkRate(intfList)
+			if rate == 0 {
+				rate = sw.uplinkRate
+			}
+
+			uplinkQueues := queues
+			if rate == 0 {
+				var minRates bool
+				rate = defaultUplinkRate
+				uplinkQueues, minRates = withoutMinRates(queues)
+				if minRates {
+					log.Warnf("Speed of uplink %s is unknown, egress min rates are not guaranteed "+
+						"unless the netplugin uplink rate is set", portName)
+				}
+			}
+			err = sw.ovsdbDriver.SetPortQos(portName, rate, uplinkQueues)
+		}
+		if err != nil {
+			log.Errorf("Error setting the QoS of uplink %s. Err: %v", portName, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Egress shapers. The traffic an endpoint sends is shaped where it enters the
+// switch, so that it is shaped on every encap and when it stays on the host.
+// The port of every shaped endpoint redirects the traffic it gets from the
+// endpoint to the ifb device of its switch, where a linux-htb class of the
+// endpoint shapes it. The classes share the rate of the shaper, and a class
+// borrows the rate the others don't use by priority. The traffic is tagged
+// with the class as its skb priority before it is redirected. The classes
+// take the ofp port of the endpoint shifted by four bits as minor number, so
+// that the priority the traffic keeps is best effort.
+const (
+	shaperPrefix    = "contivifb" // ifb device of a switch, the net type is appended
+	shaperHandle    = 2           // htb qdisc handle of the shapers, OVS takes 1:
+	shaperRootClass = 1           // class the endpoint classes share the shaper rate of
+	maxShapedPort   = 0xfff       // highest ofp port whose class fits in a minor number
+
+	// rate of the class of an endpoint without min rate, htb needs one
+	shaperMinClassRate = 8000
+)
+
+// tcCommand runs tc, tests replace it
+var tcCommand = func(args ...string) error {
+	out, err := exec.Command("tc", args...).CombinedOutput()
+	if err != nil {
+		return core.Errorf("tc %s failed. Err: %v, output: %s",
+			strings.Join(args, " "), err, out)
+	}
+	return nil
+}
+
+// shaperName returns the ifb device shaping the endpoints of a switch
+func (sw *OvsSwitch) shaperName() string {
+	return shaperPrefix + sw.netType
+}
+
+// shaperRate returns the rate the endpoints of a switch share
+func (sw *OvsSwitch) shaperRate() int64 {
+	if sw.uplinkRate != 0 {
+		return sw.uplinkRate
+	}
+
+	return defaultUplinkRate
+}
+
+// tcRate formats a rate in bps for tc
+func tcRate(rate int64) string {
+	return strconv.FormatInt(rate, 10) + "bit"
+}
+
+// shaperClass returns the htb class of an endpoint port
+func shaperClass(ofpPort uint32) string {
+	return fmt.Sprintf("%x:%x", shaperHandle, ofpPort<<4)
+}
+
+// shaperCmds returns the tc commands setting up the htb of a shaper
+func shaperCmds(shaper string, rate int64) [][]string {
+	return [][]string{
+		{"qdisc", "replace", "dev", shaper, "root", "handle", fmt.Sprintf("%x:", shaperHandle), "htb"},
+		{"class", "replace", "dev", shaper, "parent", fmt.Sprintf("%x:", shaperHandle),
+			"classid", fmt.Sprintf("%x:%x", shaperHandle, shaperRootClass),
+			"htb", "rate", tcRate(rate), "ceil", tcRate(rate)},
+	}
+}
+
+// endpointShaperCmds returns the tc commands shaping the traffic of an
+// endpoint port. direction is the tc hook of the port that sees the traffic
+// of the endpoint, ingress on the host side of a veth and egress on an
+// internal port.
+func endpointShaperCmds(shaper string, rate int64, portName, direction string,
+	ofpPort uint32, egress QueueConfig) [][]string {
+	classRate := egress.MinRate
+	if classRate == 0 {
+		classRate = shaperMinClassRate
+	}
+	ceil := egress.MaxRate
+	if ceil == 0 {
+		ceil = rate
+	}
+	if ceil < classRate {
+		ceil = classRate
+	}
+
+	return [][]string{
+		{"class", "replace", "dev", shaper, "parent", fmt.Sprintf("%x:%x", shaperHandle, shaperRootClass),
+			"classid", shaperClass(ofpPort), "htb", "rate", tcRate(classRate), "ceil", tcRate(ceil),
+			"prio", strconv.Itoa(egress.Priority)},
+		{"qdisc", "add", "dev", portName, "clsact"},
+		{"filter", "add", "dev", portName, direction, "pref", "1", "protocol", "all",
+			"u32", "match", "u32", "0", "0",
+			"action", "skbedit", "priority", shaperClass(ofpPort),
+			"action", "mirred", "egress", "redirect", "dev", shaper},
+	}
+}
+
+// setEndpointShaper redirects the traffic of an endpoint port to the class
+// of the endpoint on the shaper of the switch. The shaper is created with
+// the first shaped endpoint. qosMutex must be held.
+func (sw *OvsSwitch) setEndpointShaper(ovsPortName string, ofpPort uint32, egress QueueConfig) error {
+	shaper := sw.shaperName()
+	if _, err := netlink.LinkByName(shaper); err != nil {
+		ifb := &netlink.Ifb{LinkAttrs: netlink.LinkAttrs{Name: shaper}}
+		if err := netlink.LinkAdd(ifb); err != nil {
+			log.Errorf("Error creating the egress shaper %s. Err: %v", shaper, err)
+			return err
+		}
+	}
+	if err := setLinkUp(shaper); err != nil {
+		log.Errorf("Error setting the egress shaper %s up. Err: %v", shaper, err)
+		return err
+	}
+
+	// the traffic of a veth endpoint enters on the host side of the veth,
+	// an internal port sends it
+	link, err := netlink.LinkByName(ovsPortName)
+	if err != nil {
+		log.Errorf("Error finding the link of port %s. Err: %v", ovsPortName, err)
+		return err
+	}
+	direction := "egress"
+	if link.Type() == "veth" {
+		direction = "ingress"
+	}
+
+	// the port filter is replaced along with its qdisc
+	if err := tcCommand("qdisc", "del", "dev", ovsPortName, "clsact"); err != nil {
+		log.Debugf("Port %s had no egress shaping. Err: %v", ovsPortName, err)
+	}
+
+	rate := sw.shaperRate()
+	cmds := append(shaperCmds(shaper, rate),
+		endpointShaperCmds(shaper, rate, ovsPortName, direction, ofpPort, egress)...)
+	for _, args := range cmds {
+		if err := tcCommand(args...); err != nil {
+			log.Errorf("Error shaping the egress of port %s. Err: %v", ovsPortName, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// clearEndpointShaper removes the class of an endpoint port from the shaper
+// of the switch, and the redirect of the port when it still exists. The
+// shaper is deleted with its last class. qosMutex must be held.
+func (sw *OvsSwitch) clearEndpointShaper(ovsPortName string, ofpPort uint32) {
+	if ovsPortName != "" {
+		if err := tcCommand("qdisc", "del", "dev", ovsPortName, "clsact"); err != nil {
+			log.Errorf("Error removing the egress shaping of port %s. Err: %v", ovsPortName, err)
+		}
+	}
+
+	shaper := sw.shaperName()
+	if len(sw.egressQueues) == 0 {
+		link, err := netlink.LinkByName(shaper)
+		if err == nil {
+			err = netlink.LinkDel(link)
+		}
+		if err != nil {
+			log.Errorf("Error deleting the egress shaper %s. Err: %v", shaper, err)
+		}
+		return
+	}
+
+	if err := tcCommand("class", "del", "dev", shaper, "classid", shaperClass(ofpPort)); err != nil {
+		log.Errorf("Error removing the egress shaper class of port %s. Err: %v", ovsPortName, err)
+	}
+}
+
+// applyEndpointQos shapes the traffic of an endpoint port. The traffic sent
+// to the endpoint is shaped by a QoS on its port, the traffic it sends by the
+// egress shaper of the switch. On vlan networks the min rates are also
+// guaranteed against the other traffic of the uplinks by an uplink queue
+// whose id is the ofp port. It returns the queue the endpoint traffic goes
+// to, zero when its egress isn't shaped.
+func (sw *OvsSwitch) applyEndpointQos(ovsPortName string, ofpPort uint32, qos endpointQos) (uint32, error) {
+	var err error
+	if qos.ingressRate != 0 {
+		queues := map[uint32]QueueConfig{0: {MaxRate: qos.ingressRate}}
+		err = sw.ovsdbDriver.SetPortQos(ovsPortName, qos.ingressRate, queues)
+	} else {
+		err = sw.ovsdbDriver.ClearPortQos(ovsPortName)
+	}
+	if err != nil {
+		log.Errorf("Error setting the QoS of port %s. Err: %v", ovsPortName, err)
+		return 0, err
+	}
+
+	var queueID uint32
+	if qos.egressShaped() {
+		if ofpPort > maxShapedPort {
+			return 0, core.Errorf("egress of port %s can't be shaped, ofp port %d exceeds %d",
+				ovsPortName, ofpPort, maxShapedPort)
+		}
+		queueID = ofpPort
+	}
+
+	sw.qosMutex.Lock()
+	defer sw.qosMutex.Unlock()
+
+	oldQueue, found := sw.egressQueues[ofpPort]
+	if queueID == 0 {
+		if !found {
+			return 0, nil
+		}
+		delete(sw.egressQueues, ofpPort)
+		sw.clearEndpointShaper(ovsPortName, ofpPort)
+		return 0, sw.syncUplinkQos()
+	}
+
+	// the port may have been added again, its redirect is always set
+	sw.egressQueues[queueID] = qos.egress
+	if err := sw.setEndpointShaper(ovsPortName, ofpPort, qos.egress); err != nil {
+		if !found {
+			delete(sw.egressQueues, queueID)
+		}
+		return 0, err
+	}
+	if found && oldQueue == qos.egress {
+		return queueID, nil
+	}
+
+	return queueID, sw.syncUplinkQos()
+}
+
+// removeEndpointQos removes the egress shaping of an endpoint port. The QoS
+// of the port itself and its redirect are deleted along with the port.
+func (sw *OvsSwitch) removeEndpointQos(ofpPort uint32) error {
+	sw.qosMutex.Lock()
+	defer sw.qosMutex.Unlock()
+
+	if _, found := sw.egressQueues[ofpPort]; !found {
+		return nil
+	}
+	delete(sw.egressQueues, ofpPort)
+	sw.clearEndpointShaper("", ofpPort)
+
+	return sw.syncUplinkQos()
+}
@@ -0,0 +1,206 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/contiv/libovsdb"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+)
+
+func TestDscpPriority(t *testing.T) {
+	priorities := map[int]int{
+		0:  7, // best effort
+		10: 6, // AF11
+		26: 4, // AF31
+		34: 3, // AF41
+		46: 2, // EF
+		48: 1, // CS6
+		63: 0,
+	}
+
+	for dscp, priority := range priorities {
+		if p := dscpPriority(dscp); p != priority {
+			t.Errorf("DSCP %d mapped to priority %d, expected %d", dscp, p, priority)
+		}
+	}
+}
+
+func TestEpgQos(t *testing.T) {
+	qos := epgQos(&mastercfg.EndpointGroupState{DSCP: 46})
+	if qos.ingressRate != 0 || qos.egressShaped() {
+		t.Fatalf("Endpoint group without rates is shaped: %+v", qos)
+	}
+
+	qos = epgQos(&mastercfg.EndpointGroupState{
+		DSCP:          46,
+		IngressRate:   "2mbps",
+		EgressRate:    "10 Mbps",
+		EgressMinRate: "1kb",
+	})
+	expQos := endpointQos{
+		ingressRate: 2097000,
+		egress: QueueConfig{
+			MinRate:  1000,
+			MaxRate:  10485000,
+			Priority: 2,
+		},
+	}
+	if qos != expQos {
+		t.Fatalf("Endpoint group shaped as %+v, expected %+v", qos, expQos)
+	}
+}
+
+func TestOvsRef(t *testing.T) {
+	uuid := "0b7b9ef2-b6ec-4d0c-9a63-3e0e9a7b7a1c"
+	refs := []interface{}{
+		libovsdb.UUID{GoUuid: uuid},
+		[]interface{}{"uuid", uuid},
+	}
+	for _, ref := range refs {
+		if ovsUUID, found := ovsRef(ref); !found || ovsUUID.GoUuid != uuid {
+			t.Errorf("Reference %v read as %v", ref, ovsUUID)
+		}
+	}
+
+	if _, found := ovsRef(libovsdb.OvsSet{GoSet: []interface{}{}}); found {
+		t.Errorf("Empty reference read as a uuid")
+	}
+}
+
+func TestWithoutMinRates(t *testing.T) {
+	queues := map[uint32]QueueConfig{
+		0:  {Priority: 7},
+		10: {MinRate: 1000, MaxRate: 2000, Priority: 2},
+	}
+
+	maxQueues, minRates := withoutMinRates(queues)
+	if !minRates || maxQueues[10] != (QueueConfig{MaxRate: 2000, Priority: 2}) || maxQueues[0] != queues[0] {
+		t.Fatalf("Queues without min rates %+v, min rates %v", maxQueues, minRates)
+	}
+	if queues[10].MinRate != 1000 {
+		t.Fatalf("Min rates removed from the queues: %+v", queues)
+	}
+
+	if _, minRates := withoutMinRates(maxQueues); minRates {
+		t.Fatalf("Queues %+v have min rates", maxQueues)
+	}
+}
+
+// qosOps counts the operations on each table
+func qosOps(ops []libovsdb.Operation) map[string]int {
+	counts := make(map[string]int)
+	for _, op := range ops {
+		counts[op.Op+" "+op.Table]++
+	}
+	return counts
+}
+
+func TestQosUpdateOps(t *testing.T) {
+	qos := &ovsQos{
+		uuid:    libovsdb.UUID{GoUuid: "qos"},
+		maxRate: "10000",
+		queues: map[uint32]ovsQueue{
+			0:  {uuid: libovsdb.UUID{GoUuid: "queue0"}, config: queueOtherConfig(QueueConfig{Priority: 7})},
+			10: {uuid: libovsdb.UUID{GoUuid: "queue10"}, config: queueOtherConfig(QueueConfig{MaxRate: 1000, Priority: 2})},
+			11: {uuid: libovsdb.UUID{GoUuid: "queue11"}, config: queueOtherConfig(QueueConfig{MaxRate: 1000, Priority: 7})},
+		},
+	}
+
+	// unchanged queues are left as is
+	ops, err := qosUpdateOps(qos, 10000, map[uint32]QueueConfig{
+		0:  {Priority: 7},
+		10: {MaxRate: 1000, Priority: 2},
+		11: {MaxRate: 1000, Priority: 7},
+	})
+	if err != nil || len(ops) != 0 {
+		t.Fatalf("Unchanged QoS updated with %+v. Err: %v", ops, err)
+	}
+
+	// queue 10 changes, 11 is removed and 12 added
+	ops, err = qosUpdateOps(qos, 10000, map[uint32]QueueConfig{
+		0:  {Priority: 7},
+		10: {MinRate: 500, MaxRate: 1000, Priority: 2},
+		12: {MaxRate: 2000, Priority: 7},
+	})
+	if err != nil {
+		t.Fatalf("Error updating the QoS. Err: %v", err)
+	}
+	expOps := map[string]int{
+		"update " + queueTable: 1,
+		"insert " + queueTable: 1,
+		"mutate " + qosTable:   1,
+		"delete " + queueTable: 1,
+	}
+	if counts := qosOps(ops); !reflect.DeepEqual(counts, expOps) {
+		t.Fatalf("QoS updated with %v, expected %v", counts, expOps)
+	}
+	for _, op := range ops {
+		if op.Op == "insert" && op.Table == qosTable {
+			t.Fatalf("QoS replaced: %+v", op)
+		}
+		if op.Op == "mutate" && len(op.Mutations) != 2 {
+			t.Fatalf("QoS queues mutated with %+v", op.Mutations)
+		}
+	}
+
+	// the max rate is updated in place
+	ops, err = qosUpdateOps(qos, 20000, map[uint32]QueueConfig{
+		0:  {Priority: 7},
+		10: {MaxRate: 1000, Priority: 2},
+		11: {MaxRate: 1000, Priority: 7},
+	})
+	if err != nil || len(ops) != 1 || ops[0].Op != "mutate" || len(ops[0].Mutations) != 2 {
+		t.Fatalf("QoS rate updated with %+v. Err: %v", ops, err)
+	}
+}
+
+func TestEndpointShaperCmds(t *testing.T) {
+	rate := int64(10000000000)
+	expCmds := [][]string{
+		{"qdisc", "replace", "dev", "contivifbvxlan", "root", "handle", "2:", "htb"},
+		{"class", "replace", "dev", "contivifbvxlan", "parent", "2:", "classid", "2:1",
+			"htb", "rate", "10000000000bit", "ceil", "10000000000bit"},
+	}
+	if cmds := shaperCmds("contivifbvxlan", rate); !reflect.DeepEqual(cmds, expCmds) {
+		t.Fatalf("Shaper set up with %v, expected %v", cmds, expCmds)
+	}
+
+	// the class of the endpoint gets its min rate and priority, and its
+	// traffic is redirected from the host side of its veth
+	egress := QueueConfig{MinRate: 1000000, MaxRate: 5000000, Priority: 2}
+	expCmds = [][]string{
+		{"class", "replace", "dev", "contivifbvxlan", "parent", "2:1", "classid", "2:120",
+			"htb", "rate", "1000000bit", "ceil", "5000000bit", "prio", "2"},
+		{"qdisc", "add", "dev", "vvport3", "clsact"},
+		{"filter", "add", "dev", "vvport3", "ingress", "pref", "1", "protocol", "all",
+			"u32", "match", "u32", "0", "0",
+			"action", "skbedit", "priority", "2:120",
+			"action", "mirred", "egress", "redirect", "dev", "contivifbvxlan"},
+	}
+	cmds := endpointShaperCmds("contivifbvxlan", rate, "vvport3", "ingress", 18, egress)
+	if !reflect.DeepEqual(cmds, expCmds) {
+		t.Fatalf("Endpoint shaped with %v, expected %v", cmds, expCmds)
+	}
+
+	// an endpoint without max rate may take the whole shaper rate
+	cmds = endpointShaperCmds("contivifbvlan", rate, "port4", "egress", 4, QueueConfig{Priority: 7})
+	if cmds[0][10] != "8000bit" || cmds[0][12] != "10000000000bit" || cmds[2][4] != "egress" {
+		t.Fatalf("Endpoint without rates shaped with %v", cmds)
+	}
+}
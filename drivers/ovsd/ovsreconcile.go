@@ -0,0 +1,453 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/drivers"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/utils/netutils"
+)
+
+// VTEPs are reconciled once the peers had time to be discovered, before
+// that every VTEP would look stale
+const vtepReconcileDelay = 2 * time.Minute
+
+// endpointPortRegexp matches the OVS side of the endpoint veth pairs
+var endpointPortRegexp = regexp.MustCompile("^vvport[0-9]+$")
+
+// ReconcileIssue is a difference between OVS and the driver state
+type ReconcileIssue struct {
+	Kind   string `json:"kind"` // endpoint, port, vtep, network or flow
+	Name   string `json:"name"`
+	Issue  string `json:"issue"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ReconcileReport lists the differences found between OVS and the driver
+// state. The actions are only taken when it isn't a dry run. NotChecked
+// lists the state the reconciliation doesn't compare.
+type ReconcileReport struct {
+	Time         time.Time        `json:"time"`
+	DryRun       bool             `json:"dryRun"`
+	VtepsChecked bool             `json:"vtepsChecked"`
+	NotChecked   []string         `json:"notChecked"`
+	Issues       []ReconcileIssue `json:"issues"`
+}
+
+// notReconciled is the state the reconciliation doesn't compare
+var notReconciled = []string{
+	"openflow flow contents: flows are compared by table, cookie and priority, changes to the match or the actions of a flow are not detected",
+}
+
+// reconciliation compares the OVSDB cache with the driver state
+type reconciliation struct {
+	d      *OvsDriver
+	report *ReconcileReport
+}
+
+// addIssue records an issue, and fixes it unless it is a dry run. Issues
+// without a fix are only reported.
+func (r *reconciliation) addIssue(kind, name, issue, action string, fix func() error) {
+	ri := ReconcileIssue{
+		Kind:   kind,
+		Name:   name,
+		Issue:  issue,
+		Action: action,
+	}
+
+	if !r.report.DryRun && fix != nil {
+		log.Infof("Reconciling %s %s: %s, %s", kind, name, issue, action)
+		if err := fix(); err != nil {
+			log.Errorf("Error reconciling %s %s. Err: %v", kind, name, err)
+			ri.Error = err.Error()
+		}
+	}
+
+	r.report.Issues = append(r.report.Issues, ri)
+}
+
+// checkEndpoints checks that the local endpoints still exist and have
+// their port in OVS
+func (r *reconciliation) checkEndpoints() {
+	d := r.d
+
+	d.oper.localEpInfoMutex.Lock()
+	epInfos := make(map[string]EpInfo)
+	for id, epInfo := range d.oper.LocalEpInfo {
+		epInfos[id] = *epInfo
+	}
+	d.oper.localEpInfoMutex.Unlock()
+
+	ids := []string{}
+	for id := range epInfos {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		epID := id
+		epInfo := epInfos[id]
+
+		cfgEp := &mastercfg.CfgEndpointState{}
+		cfgEp.StateDriver = d.oper.StateDriver
+		err := cfgEp.Read(epID)
+		if core.ErrIfKeyExists(err) != nil {
+			log.Errorf("Error reading endpoint %s. Err: %v", epID, err)
+			continue
+		} else if err != nil {
+			r.addIssue("endpoint", epID, "endpoint was deleted", "delete the endpoint", func() error {
+				return d.removeLocalEndpoint(epID)
+			})
+			continue
+		}
+
+		sw := d.encapSwitch(epInfo.BridgeType)
+		if sw.ovsdbDriver.IsPortNamePresent(epInfo.Ovsportname) {
+			continue
+		}
+
+		issue := fmt.Sprintf("port %s is missing", epInfo.Ovsportname)
+		if !endpointPortRegexp.MatchString(epInfo.Ovsportname) {
+			// infra ports have no veth pair, they are created again
+			r.addIssue("endpoint", epID, issue, "recreate the endpoint", func() error {
+				d.removeLocalEndpoint(epID)
+				return d.CreateEndpoint(epID)
+			})
+			continue
+		}
+
+		// the container keeps its end of the veth pair
+		r.addIssue("endpoint", epID, issue, "add the port back", func() error {
+			return d.readdEndpointPort(epID)
+		})
+	}
+}
+
+// checkPorts checks that the endpoint ports in OVS belong to a local
+// endpoint
+func (r *reconciliation) checkPorts() {
+	d := r.d
+
+	epPorts := make(map[string]bool)
+	d.oper.localEpInfoMutex.Lock()
+	for _, epInfo := range d.oper.LocalEpInfo {
+		epPorts[epInfo.Ovsportname] = true
+	}
+	d.oper.localEpInfoMutex.Unlock()
+
+	for _, swType := range []string{"vlan", "vxlan"} {
+		sw := d.switchDb[swType]
+		for _, portName := range sw.ovsdbDriver.GetBridgePorts() {
+			if !endpointPortRegexp.MatchString(portName) || epPorts[portName] {
+				continue
+			}
+
+			ovsPortName := portName
+			r.addIssue("port", ovsPortName, "port has no endpoint", "delete the port", func() error {
+				err := sw.ovsdbDriver.DeletePort(ovsPortName)
+				if verr := deleteVethPair(ovsPortName, strings.Replace(ovsPortName, "vvport", "vport", 1)); verr != nil {
+					log.Errorf("Error deleting veth pair of port %s. Err: %v", ovsPortName, verr)
+				}
+				return err
+			})
+		}
+	}
+}
+
+// checkVteps checks that there is a VTEP to each peer host, and none to
+// other hosts
+func (r *reconciliation) checkVteps() {
+	d := r.d
+	sw := d.switchDb["vxlan"]
+
+	d.lock.Lock()
+	peers := make(map[string]bool)
+	for peer := range d.peers {
+		peers[peer] = true
+	}
+	d.lock.Unlock()
+
+	vxlanVteps := make(map[string]bool)
+	geneveVteps := make(map[string]bool)
+	for _, vtep := range sw.ovsdbDriver.GetVteps() {
+		if vtep.intfType == "geneve" {
+			geneveVteps[vtep.remoteIP] = true
+		} else {
+			vxlanVteps[vtep.remoteIP] = true
+		}
+	}
+
+	vtepIPs := []string{}
+	for vtepIP := range peers {
+		vtepIPs = append(vtepIPs, vtepIP)
+	}
+	for vtepIP := range vxlanVteps {
+		if !peers[vtepIP] {
+			vtepIPs = append(vtepIPs, vtepIP)
+		}
+	}
+	for vtepIP := range geneveVteps {
+		if !peers[vtepIP] && !vxlanVteps[vtepIP] {
+			vtepIPs = append(vtepIPs, vtepIP)
+		}
+	}
+	sort.Strings(vtepIPs)
+
+	for _, ip := range vtepIPs {
+		vtepIP := ip
+		switch {
+		case !peers[vtepIP]:
+			r.addIssue("vtep", vtepIP, "host is not a peer", "delete the vtep", func() error {
+				return sw.DeleteVtep(vtepIP)
+			})
+		case !vxlanVteps[vtepIP]:
+			r.addIssue("vtep", vtepIP, "vxlan vtep is missing", "create the vtep", func() error {
+				return sw.CreateVtep(vtepIP)
+			})
+		case sw.fwdMode == "bridge" && !geneveVteps[vtepIP]:
+			r.addIssue("vtep", vtepIP, "geneve vtep is missing", "create the vtep", func() error {
+				return sw.CreateVtep(vtepIP)
+			})
+		}
+	}
+}
+
+// checkNetworks checks that the networks have their vlan/vni in ofnet
+func (r *reconciliation) checkNetworks() {
+	d := r.d
+
+	cfgNw := &mastercfg.CfgNetworkState{}
+	cfgNw.StateDriver = d.oper.StateDriver
+	nwStates, err := cfgNw.ReadAll()
+	if core.ErrIfKeyExists(err) != nil {
+		log.Errorf("Error reading networks. Err: %v", err)
+		return
+	}
+
+	// vlans of the networks, by switch
+	nwVlans := make(map[*OvsSwitch]map[uint16]bool)
+	for _, swType := range []string{"vlan", "vxlan"} {
+		nwVlans[d.switchDb[swType]] = make(map[uint16]bool)
+	}
+
+	nws := make(map[string]*mastercfg.CfgNetworkState)
+	nwIDs := []string{}
+	for _, state := range nwStates {
+		nw := state.(*mastercfg.CfgNetworkState)
+		nws[nw.ID] = nw
+		nwIDs = append(nwIDs, nw.ID)
+	}
+	sort.Strings(nwIDs)
+
+	for _, id := range nwIDs {
+		nwID := id
+		nw := nws[nwID]
+		vlan := uint16(nw.PktTag)
+		vni := uint32(nw.ExtPktTag)
+
+		sw := d.encapSwitch(nw.PktTagType)
+		if sw.ofnetAgent == nil {
+			continue
+		}
+		nwVlans[sw][vlan] = true
+
+		ofnetVni, found := sw.ofnetAgent.GetNetworks()[vlan]
+		switch {
+		case !found:
+			issue := fmt.Sprintf("vlan %d is missing", vlan)
+			r.addIssue("network", nwID, issue, "add the network", func() error {
+				return d.CreateNetwork(nwID)
+			})
+		case ofnetVni != vni:
+			// the endpoints of the network are in the vni, it isn't moved
+			issue := fmt.Sprintf("vlan %d has vni %d, expected %d", vlan, ofnetVni, vni)
+			r.addIssue("network", nwID, issue, "none, delete and create the network", nil)
+		}
+	}
+
+	for _, swType := range []string{"vlan", "vxlan"} {
+		sw := d.switchDb[swType]
+		if sw.ofnetAgent == nil {
+			continue
+		}
+
+		vlans := []int{}
+		for vlan := range sw.ofnetAgent.GetNetworks() {
+			if !nwVlans[sw][vlan] {
+				vlans = append(vlans, int(vlan))
+			}
+		}
+		sort.Ints(vlans)
+
+		// the vrf of a stale vlan isn't known to remove it
+		for _, vlan := range vlans {
+			r.addIssue("network", fmt.Sprintf("%s vlan %d", swType, vlan), "vlan has no network",
+				"none, restart netplugin", nil)
+		}
+	}
+}
+
+// checkFlows checks that the flows ofnet installed are in OVS, and that
+// OVS has no other flows
+func (r *reconciliation) checkFlows() {
+	d := r.d
+
+	for _, swType := range []string{"vlan", "vxlan", "host"} {
+		sw, found := d.switchDb[swType]
+		if !found || sw.ofnetAgent == nil {
+			continue
+		}
+
+		diffs, err := sw.ofnetAgent.DiffFlows()
+		if err != nil {
+			log.Errorf("Error comparing the flows of the %s bridge. Err: %v", swType, err)
+			r.addIssue("flow", swType+" bridge", fmt.Sprintf("flows could not be read: %v", err),
+				"none", nil)
+			continue
+		}
+
+		for _, diff := range diffs {
+			name := fmt.Sprintf("%s table %d cookie %#x priority %d", swType, diff.TableId,
+				diff.Cookie, diff.Priority)
+			if diff.Missing {
+				r.addIssue("flow", name, "flow is missing", "add the flow", diff.Fix)
+			} else {
+				r.addIssue("flow", name, "flow was not installed by netplugin", "delete the flow", diff.Fix)
+			}
+		}
+	}
+}
+
+// readdEndpointPort adds the port of a local endpoint back to OVS, with
+// the veth pair created for the endpoint
+func (d *OvsDriver) readdEndpointPort(id string) error {
+	var (
+		burst        int
+		dscp         int
+		epgBandwidth int64
+	)
+
+	cfgEp := &mastercfg.CfgEndpointState{}
+	cfgEp.StateDriver = d.oper.StateDriver
+	if err := cfgEp.Read(id); err != nil {
+		return err
+	}
+
+	operEp := &drivers.OperEndpointState{}
+	operEp.StateDriver = d.oper.StateDriver
+	if err := operEp.Read(id); err != nil {
+		return err
+	}
+
+	cfgNw := mastercfg.CfgNetworkState{}
+	cfgNw.StateDriver = d.oper.StateDriver
+	if err := cfgNw.Read(cfgEp.NetID); err != nil {
+		return err
+	}
+
+	pktTagType := cfgNw.PktTagType
+	pktTag := cfgNw.PktTag
+	cfgEpGroup := &mastercfg.EndpointGroupState{}
+	if cfgEp.EndpointGroupKey != "" {
+		cfgEpGroup.StateDriver = d.oper.StateDriver
+		err := cfgEpGroup.Read(cfgEp.EndpointGroupKey)
+		if err == nil {
+			pktTagType = cfgEpGroup.PktTagType
+			pktTag = cfgEpGroup.PktTag
+			burst = cfgEpGroup.Burst
+			dscp = cfgEpGroup.DSCP
+			if cfgEpGroup.Bandwidth != "" {
+				epgBandwidth = netutils.ConvertBandwidth(cfgEpGroup.Bandwidth)
+			}
+		} else if core.ErrIfKeyExists(err) != nil {
+			return err
+		}
+	}
+
+	sw := d.encapSwitch(pktTagType)
+	return sw.ReaddPort(operEp.PortName, cfgEp, pktTag, cfgNw.PktTag, burst, dscp, epgBandwidth, epgQos(cfgEpGroup))
+}
+
+// removeLocalEndpoint deletes a local endpoint, and forgets it even if its
+// oper state is gone
+func (d *OvsDriver) removeLocalEndpoint(id string) error {
+	err := d.DeleteEndpoint(id)
+
+	d.oper.localEpInfoMutex.Lock()
+	_, found := d.oper.LocalEpInfo[id]
+	delete(d.oper.LocalEpInfo, id)
+	d.oper.localEpInfoMutex.Unlock()
+	if found {
+		if werr := d.oper.Write(); werr != nil {
+			return werr
+		}
+	}
+
+	return err
+}
+
+// reconcile compares the OVSDB cache with the local endpoints and the peer
+// hosts, ofnet with the networks, and the OVS flows with the flows ofnet
+// installed. The differences are fixed unless it is a dry run.
+func (d *OvsDriver) reconcile(dryRun bool) *ReconcileReport {
+	r := &reconciliation{
+		d: d,
+		report: &ReconcileReport{
+			Time:       time.Now(),
+			DryRun:     dryRun,
+			NotChecked: notReconciled,
+			Issues:     []ReconcileIssue{},
+		},
+	}
+
+	r.checkNetworks()
+	r.checkEndpoints()
+	r.checkPorts()
+	r.checkFlows()
+	if time.Since(d.initTime) >= vtepReconcileDelay {
+		r.report.VtepsChecked = true
+		r.checkVteps()
+	}
+
+	return r.report
+}
+
+// Reconcile removes or recreates the OVS ports, VTEPs, ofnet networks and
+// flows that differ from the driver state
+func (d *OvsDriver) Reconcile() error {
+	report := d.reconcile(false)
+
+	d.lock.Lock()
+	d.lastReconcile = report
+	d.lock.Unlock()
+
+	for _, issue := range report.Issues {
+		if issue.Error != "" {
+			return core.Errorf("failed to reconcile %s %s: %s", issue.Kind, issue.Name, issue.Error)
+		}
+	}
+
+	return nil
+}
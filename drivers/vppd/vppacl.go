@@ -0,0 +1,436 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vppd
+
+import (
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/drivers/vppd/vppapi"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/ofnet"
+)
+
+// Policy rules map to vpp acls as follows. vpp has no endpoint group
+// metadata, so the groups of a rule are expanded to the addresses of their
+// members, and every rule is an acl of its own. A local endpoint has a
+// single acl list with the rules that can match its traffic, those whose
+// source or destination group is its group or unset, ordered by priority.
+// vpp applies the first rule that matches in the list, so the list is set
+// as both the input and the output acls of the endpoint: traffic between
+// two endpoints meets the same rules, in the same order, on both sides.
+// An interface with acls drops what none of them permits, unlike ofnet, so
+// the default permit acl ends the lists.
+
+const (
+	tcpFlagSyn = 0x02
+	tcpFlagAck = 0x10
+)
+
+// aclPrefix is an address prefix of an acl rule, nil ip matches any address
+type aclPrefix struct {
+	ip  net.IP
+	len uint8
+}
+
+var anyPrefix = aclPrefix{}
+
+// parsePrefix parses an address or a prefix of a policy rule
+func parsePrefix(addr string) (aclPrefix, error) {
+	if !strings.Contains(addr, "/") {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return anyPrefix, core.Errorf("Invalid address %q", addr)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return aclPrefix{ip: ip4, len: 32}, nil
+		}
+		return aclPrefix{ip: ip, len: 128}, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(addr)
+	if err != nil {
+		return anyPrefix, core.Errorf("Invalid prefix %q", addr)
+	}
+	ones, _ := ipNet.Mask.Size()
+	if ip4 := ipNet.IP.To4(); ip4 != nil {
+		return aclPrefix{ip: ip4, len: uint8(ones)}, nil
+	}
+	return aclPrefix{ip: ipNet.IP, len: uint8(ones)}, nil
+}
+
+// isIPv6 returns if a prefix is an IPv6 one, and if it matches any address
+func (p aclPrefix) isIPv6() (bool, bool) {
+	if p.ip == nil {
+		return false, true
+	}
+	return len(p.ip) == net.IPv6len, false
+}
+
+// portRange converts a port and mask of a policy rule to a port range. A
+// zero port matches any port, and a zero mask the exact port.
+func portRange(port, mask uint16) (uint16, uint16) {
+	switch {
+	case port == 0:
+		return 0, 0xffff
+	case mask == 0:
+		return port, port
+	default:
+		return port & mask, port&mask | ^mask
+	}
+}
+
+// tcpFlags converts the tcp flags of a policy rule to a value and a mask
+func tcpFlags(flags string) (uint8, uint8, error) {
+	switch flags {
+	case "":
+		return 0, 0, nil
+	case "syn":
+		return tcpFlagSyn, tcpFlagSyn, nil
+	case "syn,ack":
+		return tcpFlagSyn | tcpFlagAck, tcpFlagSyn | tcpFlagAck, nil
+	case "ack":
+		return tcpFlagAck, tcpFlagAck, nil
+	case "syn,!ack":
+		return tcpFlagSyn, tcpFlagSyn | tcpFlagAck, nil
+	case "!syn,ack":
+		return tcpFlagAck, tcpFlagSyn | tcpFlagAck, nil
+	}
+
+	return 0, 0, core.Errorf("Unknown TCP flags %q", flags)
+}
+
+// rulePrefixes returns the prefixes a side of a rule matches, the group
+// being expanded to the addresses of its members
+func rulePrefixes(addr string, group int, members map[int][]string) ([]aclPrefix, error) {
+	if addr != "" {
+		prefix, err := parsePrefix(addr)
+		if err != nil {
+			return nil, err
+		}
+		return []aclPrefix{prefix}, nil
+	}
+	if group == 0 {
+		return []aclPrefix{anyPrefix}, nil
+	}
+
+	prefixes := []aclPrefix{}
+	for _, addr := range members[group] {
+		prefix, err := parsePrefix(addr)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+// policyACLRules converts a policy rule to the rules of its acl. The rules
+// are empty when the rule matches nothing, like a group without members.
+func policyACLRules(rule *ofnet.OfnetPolicyRule, members map[int][]string) ([]vppapi.ACLRule, error) {
+	srcPrefixes, err := rulePrefixes(rule.SrcIpAddr, rule.SrcEndpointGroup, members)
+	if err != nil {
+		return nil, err
+	}
+	dstPrefixes, err := rulePrefixes(rule.DstIpAddr, rule.DstEndpointGroup, members)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := vppapi.ACLRule{Proto: rule.IpProtocol}
+	if rule.Action != "deny" {
+		tmpl.IsPermit = 1
+	}
+	tmpl.SrcPortFirst, tmpl.SrcPortLast = portRange(rule.SrcPort, rule.SrcPortMask)
+	tmpl.DstPortFirst, tmpl.DstPortLast = portRange(rule.DstPort, rule.DstPortMask)
+	if rule.IpProtocol == 6 {
+		tmpl.TCPFlagsValue, tmpl.TCPFlagsMask, err = tcpFlags(rule.TcpFlags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	aclRules := []vppapi.ACLRule{}
+	for _, src := range srcPrefixes {
+		for _, dst := range dstPrefixes {
+			srcV6, srcAny := src.isIPv6()
+			dstV6, dstAny := dst.isIPv6()
+			if !srcAny && !dstAny && srcV6 != dstV6 {
+				continue
+			}
+
+			aclRule := tmpl
+			aclRule.IsIPv6 = srcV6 || dstV6
+			addrLen := net.IPv4len
+			if aclRule.IsIPv6 {
+				addrLen = net.IPv6len
+			}
+			aclRule.SrcIPAddr = make([]byte, addrLen)
+			copy(aclRule.SrcIPAddr, src.ip)
+			aclRule.SrcIPPrefixLen = src.len
+			aclRule.DstIPAddr = make([]byte, addrLen)
+			copy(aclRule.DstIPAddr, dst.ip)
+			aclRule.DstIPPrefixLen = dst.len
+			aclRules = append(aclRules, aclRule)
+		}
+	}
+
+	return aclRules, nil
+}
+
+// permitAllRules returns the rules of the default acl
+func permitAllRules() []vppapi.ACLRule {
+	return []vppapi.ACLRule{
+		{IsPermit: 1, SrcIPAddr: make([]byte, net.IPv4len), DstIPAddr: make([]byte, net.IPv4len),
+			SrcPortLast: 0xffff, DstPortLast: 0xffff},
+		{IsPermit: 1, IsIPv6: true, SrcIPAddr: make([]byte, net.IPv6len), DstIPAddr: make([]byte, net.IPv6len),
+			SrcPortLast: 0xffff, DstPortLast: 0xffff},
+	}
+}
+
+// policyRuleList orders policy rules by priority, highest first, as vpp
+// applies the first acl that matches
+type policyRuleList []*VppPolicyRule
+
+func (l policyRuleList) Len() int      { return len(l) }
+func (l policyRuleList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l policyRuleList) Less(i, j int) bool {
+	if l[i].Rule.Priority != l[j].Rule.Priority {
+		return l[i].Rule.Priority > l[j].Rule.Priority
+	}
+	return l[i].Rule.RuleId < l[j].Rule.RuleId
+}
+
+// groupMember is an endpoint in an endpoint group
+type groupMember struct {
+	group int
+	addrs []string
+}
+
+// newGroupMember returns the group and the addresses of an endpoint, or nil
+// if it isn't in a group
+func newGroupMember(ep *mastercfg.CfgEndpointState) *groupMember {
+	if ep.EndpointGroupID == 0 {
+		return nil
+	}
+
+	member := &groupMember{group: ep.EndpointGroupID}
+	for _, addr := range []string{ep.IPAddress, ep.IPv6Address} {
+		if addr != "" {
+			member.addrs = append(member.addrs, addr)
+		}
+	}
+
+	return member
+}
+
+// loadGroupMembers reads the group members of all the endpoints once, the
+// endpoint events keep them up to date afterwards
+func (d *VppDriver) loadGroupMembers() error {
+	if d.members != nil {
+		return nil
+	}
+
+	cfgEp := &mastercfg.CfgEndpointState{}
+	cfgEp.StateDriver = d.oper.StateDriver
+	epCfgs, err := cfgEp.ReadAll()
+	if core.ErrIfKeyExists(err) != nil {
+		return err
+	}
+
+	d.members = make(map[string]*groupMember)
+	for _, epCfg := range epCfgs {
+		ep := epCfg.(*mastercfg.CfgEndpointState)
+		if member := newGroupMember(ep); member != nil {
+			d.members[ep.ID] = member
+		}
+	}
+
+	return nil
+}
+
+// setGroupMember updates the group and addresses of an endpoint
+func (d *VppDriver) setGroupMember(ep *mastercfg.CfgEndpointState) {
+	if d.members == nil {
+		return
+	}
+
+	if member := newGroupMember(ep); member != nil {
+		d.members[ep.ID] = member
+	} else {
+		delete(d.members, ep.ID)
+	}
+}
+
+// delGroupMember removes an endpoint from its group
+func (d *VppDriver) delGroupMember(id string) {
+	if d.members != nil {
+		delete(d.members, id)
+	}
+}
+
+// groupMembers returns the addresses of the endpoints of each group
+func (d *VppDriver) groupMembers() (map[int][]string, error) {
+	if err := d.loadGroupMembers(); err != nil {
+		return nil, err
+	}
+
+	members := make(map[int][]string)
+	for _, member := range d.members {
+		members[member.group] = append(members[member.group], member.addrs...)
+	}
+	for _, addrs := range members {
+		sort.Strings(addrs)
+	}
+
+	return members, nil
+}
+
+// endpointACLs returns the acl list of a local endpoint
+func (d *VppDriver) endpointACLs(ep *VppEndpoint, rules policyRuleList) []uint32 {
+	acls := []uint32{}
+	for _, rule := range rules {
+		if len(rule.ACLRules) == 0 {
+			continue
+		}
+
+		srcGroup, dstGroup := rule.Rule.SrcEndpointGroup, rule.Rule.DstEndpointGroup
+		if srcGroup == 0 || srcGroup == ep.EndpointGroupID ||
+			dstGroup == 0 || dstGroup == ep.EndpointGroupID {
+			acls = append(acls, rule.ACLIndex)
+		}
+	}
+
+	if len(acls) != 0 {
+		acls = append(acls, d.oper.DefaultACLIndex)
+	}
+
+	return acls
+}
+
+// syncACLs updates the acls of the policy rules with the current group
+// members, and the acl lists of the local endpoints
+func (d *VppDriver) syncACLs() error {
+	members, err := d.groupMembers()
+	if err != nil {
+		return err
+	}
+
+	rules := policyRuleList{}
+	for _, rule := range d.oper.PolicyRules {
+		aclRules, err := policyACLRules(&rule.Rule, members)
+		if err != nil {
+			log.Errorf("Error converting policy rule %s to an acl. Err: %v", rule.Rule.RuleId, err)
+			continue
+		}
+		if len(aclRules) != 0 && (rule.ACLIndex == vppapi.InvalidIndex ||
+			!reflect.DeepEqual(aclRules, rule.ACLRules)) {
+			reply := &vppapi.ACLAddReplaceReply{}
+			err = d.api.Call(&vppapi.ACLAddReplace{
+				ACLIndex: rule.ACLIndex,
+				Tag:      rule.Rule.RuleId,
+				Rules:    aclRules,
+			}, reply)
+			if err != nil {
+				log.Errorf("Error programming acl of policy rule %s. Err: %v", rule.Rule.RuleId, err)
+				continue
+			}
+			rule.ACLIndex = reply.ACLIndex
+		}
+
+		rule.ACLRules = aclRules
+		rules = append(rules, rule)
+	}
+	sort.Sort(rules)
+
+	for id, ep := range d.oper.Endpoints {
+		acls := d.endpointACLs(ep, rules)
+		if reflect.DeepEqual(acls, ep.InputACLs) && reflect.DeepEqual(acls, ep.OutputACLs) {
+			continue
+		}
+
+		err = d.api.Call(&vppapi.ACLInterfaceSetACLList{
+			SwIfIndex: ep.SwIfIndex,
+			NInput:    uint8(len(acls)),
+			ACLs:      append(append([]uint32{}, acls...), acls...),
+		}, &vppapi.ACLInterfaceSetACLListReply{})
+		if err != nil {
+			log.Errorf("Error setting acls of endpoint %s. Err: %v", id, err)
+			continue
+		}
+		ep.InputACLs, ep.OutputACLs = acls, acls
+	}
+
+	return d.oper.Write()
+}
+
+// AddPolicyRule adds a policy rule as a vpp acl
+func (d *VppDriver) AddPolicyRule(id string) error {
+	log.Infof("Adding policy rule %s", id)
+
+	ruleCfg := &mastercfg.CfgPolicyRule{}
+	ruleCfg.StateDriver = d.oper.StateDriver
+	if err := ruleCfg.Read(id); err != nil {
+		log.Errorf("Failed to read config for policy rule '%s' \n", id)
+		return err
+	}
+	if _, _, err := tcpFlags(ruleCfg.TcpFlags); err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	rule, found := d.oper.PolicyRules[id]
+	if !found {
+		rule = &VppPolicyRule{ACLIndex: vppapi.InvalidIndex}
+		d.oper.PolicyRules[id] = rule
+	}
+	rule.Rule = ruleCfg.OfnetPolicyRule
+
+	return d.syncACLs()
+}
+
+// DelPolicyRule removes a policy rule from the endpoints, and deletes its
+// vpp acl
+func (d *VppDriver) DelPolicyRule(id string) error {
+	log.Infof("Deleting policy rule %s", id)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	rule, found := d.oper.PolicyRules[id]
+	if !found {
+		log.Infof("Policy rule %s not found in vpp", id)
+		return nil
+	}
+
+	delete(d.oper.PolicyRules, id)
+	if err := d.syncACLs(); err != nil {
+		return err
+	}
+
+	if rule.ACLIndex != vppapi.InvalidIndex {
+		return d.api.Call(&vppapi.ACLDel{ACLIndex: rule.ACLIndex}, &vppapi.ACLDelReply{})
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package vppapi is a minimal client of the vpp binary api socket. It
+// implements the messages the vpp driver uses, and a fake vpp that serves
+// them for tests.
+package vppapi
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+)
+
+const (
+	// DefaultSocket is the default path of the vpp api socket
+	DefaultSocket = "/run/vpp-api.sock"
+
+	clientName  = "contiv-netplugin"
+	callTimeout = 10 * time.Second
+)
+
+// Client is a connection to the vpp api socket
+type Client struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	clientIndex uint32
+	context     uint32
+	msgIDs      map[string]uint16 // message IDs by name, without the crc suffix
+	lock        sync.Mutex        // serializes calls
+}
+
+// Connect connects to the vpp api socket, and reads the message table. It
+// fails if vpp defines any of the messages of this package differently.
+func Connect(socket string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", socket, callTimeout)
+	if err != nil {
+		return nil, core.Errorf("Error connecting to vpp api socket %s. Err: %v", socket, err)
+	}
+
+	c := &Client{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		msgIDs: make(map[string]uint16),
+	}
+
+	if err := c.register(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	log.Infof("Connected to vpp api socket %s, client index %d, %d messages",
+		socket, c.clientIndex, len(c.msgIDs))
+	return c, nil
+}
+
+// register sends sockclnt_create. Unlike other messages, it is sent
+// without a client index, and its reply has one in front of the context.
+func (c *Client) register() error {
+	c.conn.SetDeadline(time.Now().Add(callTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	c.context++
+	e := &encoder{}
+	e.u16(sockclntCreateMsgID)
+	e.u32(c.context)
+	(&sockclntCreate{Name: clientName}).marshal(e)
+	if err := writeFrame(c.conn, e.buf); err != nil {
+		return core.Errorf("Error sending sockclnt_create. Err: %v", err)
+	}
+
+	msg, err := readFrame(c.reader)
+	if err != nil {
+		return core.Errorf("Error reading sockclnt_create reply. Err: %v", err)
+	}
+
+	d := &decoder{buf: msg}
+	d.u16() // the reply ID is only known from the table it carries
+	d.u32() // client index
+	context := d.u32()
+	reply := &sockclntCreateReply{}
+	reply.unmarshal(d)
+	if d.err != nil {
+		return core.Errorf("Error decoding sockclnt_create reply. Err: %v", d.err)
+	}
+	if context != c.context || reply.Response != 0 {
+		return core.Errorf("vpp refused the api client. context %d, response %d",
+			context, reply.Response)
+	}
+
+	c.clientIndex = reply.Index
+	mismatches := []string{}
+	for nameCrc, id := range reply.Messages {
+		name, crc := splitCrc(nameCrc)
+		c.msgIDs[name] = id
+
+		// messages whose layout changed would be encoded wrongly
+		if expCrc, ok := messageCrcs[name]; ok && crc != expCrc {
+			mismatches = append(mismatches, fmt.Sprintf("%s (crc %s, expected %s)", name, crc, expCrc))
+		}
+	}
+	if len(mismatches) > 0 {
+		sort.Strings(mismatches)
+		return core.Errorf("vpp api messages don't match the supported vpp version: %s",
+			strings.Join(mismatches, ", "))
+	}
+
+	return nil
+}
+
+// splitCrc splits the name of a message in the vpp message table into the
+// message name and its _<crc> suffix. crc is empty if there is no suffix.
+func splitCrc(nameCrc string) (name, crc string) {
+	i := strings.LastIndex(nameCrc, "_")
+	if i < 0 || len(nameCrc)-i-1 != 8 {
+		return nameCrc, ""
+	}
+	if _, err := strconv.ParseUint(nameCrc[i+1:], 16, 32); err != nil {
+		return nameCrc, ""
+	}
+
+	return nameCrc[:i], nameCrc[i+1:]
+}
+
+// Call sends a request and waits for its reply. It fails when vpp doesn't
+// know the messages, or when the reply carries a non zero return value.
+func (c *Client) Call(req Message, reply Reply) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	reqID, found := c.msgIDs[req.MessageName()]
+	if !found {
+		return core.Errorf("vpp doesn't support message %s", req.MessageName())
+	}
+	replyID, found := c.msgIDs[reply.MessageName()]
+	if !found {
+		return core.Errorf("vpp doesn't support message %s", reply.MessageName())
+	}
+
+	c.conn.SetDeadline(time.Now().Add(callTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	c.context++
+	e := &encoder{}
+	e.u16(reqID)
+	e.u32(c.clientIndex)
+	e.u32(c.context)
+	req.marshal(e)
+	if err := writeFrame(c.conn, e.buf); err != nil {
+		return core.Errorf("Error sending %s. Err: %v", req.MessageName(), err)
+	}
+
+	for {
+		msg, err := readFrame(c.reader)
+		if err != nil {
+			return core.Errorf("Error reading %s. Err: %v", reply.MessageName(), err)
+		}
+
+		d := &decoder{buf: msg}
+		msgID := d.u16()
+		context := d.u32()
+		if d.err != nil || msgID != replyID || context != c.context {
+			// events and replies to timed out requests
+			log.Debugf("Skipping vpp message %d with context %d", msgID, context)
+			continue
+		}
+
+		reply.unmarshal(d)
+		if d.err != nil {
+			return core.Errorf("Error decoding %s. Err: %v", reply.MessageName(), d.err)
+		}
+		if reply.RetVal() != 0 {
+			return core.Errorf("%s failed with return value %d", req.MessageName(), reply.RetVal())
+		}
+
+		return nil
+	}
+}
+
+// Close unregisters the client and closes the connection
+func (c *Client) Close() error {
+	if err := c.Call(&sockclntDelete{Index: c.clientIndex}, &sockclntDeleteReply{}); err != nil {
+		log.Warnf("Error unregistering from vpp. Err: %v", err)
+	}
+
+	return c.conn.Close()
+}
+
+// InterfaceCounters are the counters of an interface
+type InterfaceCounters struct {
+	RxPackets uint64 `json:"rxPackets"`
+	RxBytes   uint64 `json:"rxBytes"`
+	TxPackets uint64 `json:"txPackets"`
+	TxBytes   uint64 `json:"txBytes"`
+	Drops     uint64 `json:"drops"`
+}
+
+// GetInterfaceCounters reads the counters of an interface with the
+// "show interface" cli command, as the binary api only streams them
+func (c *Client) GetInterfaceCounters(name string) (*InterfaceCounters, error) {
+	reply := &CliInbandReply{}
+	if err := c.Call(&CliInband{Cmd: "show interface " + name}, reply); err != nil {
+		return nil, err
+	}
+
+	return parseInterfaceCounters(name, reply.Reply)
+}
+
+// parseInterfaceCounters parses the output of "show interface <name>".
+// The first line of an interface carries its name, index, state and first
+// counter; the following lines carry one counter each.
+func parseInterfaceCounters(name, output string) (*InterfaceCounters, error) {
+	counters := &InterfaceCounters{}
+	found, seen := false, false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(line) > 0 && line[0] != ' ' {
+			found = fields[0] == name
+			seen = seen || found
+			if !found || len(fields) < 3 {
+				continue
+			}
+			fields = fields[3:] // name, index and state
+		}
+		if !found || len(fields) < 2 {
+			continue
+		}
+
+		value, err := strconv.ParseUint(fields[len(fields)-1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch strings.Join(fields[:len(fields)-1], " ") {
+		case "rx packets":
+			counters.RxPackets = value
+		case "rx bytes":
+			counters.RxBytes = value
+		case "tx packets":
+			counters.TxPackets = value
+		case "tx bytes":
+			counters.TxBytes = value
+		case "drops":
+			counters.Drops = value
+		}
+	}
+
+	if !seen {
+		return nil, core.Errorf("vpp interface %s not found", name)
+	}
+
+	return counters, nil
+}
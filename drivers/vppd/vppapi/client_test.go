@@ -0,0 +1,191 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vppapi
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func startFakeServer(t *testing.T) (*FakeServer, *Client, func()) {
+	dir, err := ioutil.TempDir("", "vppapi")
+	if err != nil {
+		t.Fatalf("Error creating temp dir. Err: %v", err)
+	}
+
+	socket := filepath.Join(dir, "api.sock")
+	server, err := NewFakeServer(socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Error starting fake vpp. Err: %v", err)
+	}
+
+	client, err := Connect(socket)
+	if err != nil {
+		server.Close()
+		os.RemoveAll(dir)
+		t.Fatalf("Error connecting to fake vpp. Err: %v", err)
+	}
+
+	return server, client, func() {
+		client.Close()
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCodec(t *testing.T) {
+	rule := ACLRule{
+		IsPermit:       1,
+		SrcIPAddr:      net.ParseIP("10.1.1.1").To4(),
+		SrcIPPrefixLen: 32,
+		DstIPAddr:      net.IPv4zero.To4(),
+		Proto:          6,
+		SrcPortLast:    65535,
+		DstPortFirst:   80,
+		DstPortLast:    80,
+		TCPFlagsMask:   0x12,
+		TCPFlagsValue:  0x02,
+	}
+	req := &ACLAddReplace{ACLIndex: InvalidIndex, Tag: "contiv", Rules: []ACLRule{rule, rule}}
+
+	e := &encoder{}
+	req.marshal(e)
+	if len(e.buf) != 4+64+4+2*47 {
+		t.Fatalf("Unexpected encoded length %d", len(e.buf))
+	}
+
+	decoded := &ACLAddReplace{}
+	d := &decoder{buf: e.buf}
+	decoded.unmarshal(d)
+	if d.err != nil || !reflect.DeepEqual(req, decoded) {
+		t.Fatalf("Decoded message %+v doesn't match %+v. Err: %v", decoded, req, d.err)
+	}
+
+	d = &decoder{buf: e.buf[:70]}
+	(&ACLAddReplace{}).unmarshal(d)
+	if d.err != errShortMessage {
+		t.Fatalf("Decoding a short message didn't fail")
+	}
+}
+
+func TestSplitCrc(t *testing.T) {
+	for nameCrc, exp := range map[string][2]string{
+		"acl_del_ef34fea4":    {"acl_del", "ef34fea4"},
+		"acl_del":             {"acl_del", ""},
+		"bridge_domain_add":   {"bridge_domain_add", ""},
+		"cli_inband_zzzzzzzz": {"cli_inband_zzzzzzzz", ""},
+	} {
+		if name, crc := splitCrc(nameCrc); name != exp[0] || crc != exp[1] {
+			t.Errorf("splitCrc(%s) returned %s, %s, expected %s, %s", nameCrc, name, crc, exp[0], exp[1])
+		}
+	}
+}
+
+func TestConnectCrcMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vppapi")
+	if err != nil {
+		t.Fatalf("Error creating temp dir. Err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "api.sock")
+	server, err := NewFakeServer(socket)
+	if err != nil {
+		t.Fatalf("Error starting fake vpp. Err: %v", err)
+	}
+	defer server.Close()
+
+	server.SetMessageCrc("acl_add_replace", "01234567")
+	client, err := Connect(socket)
+	if err == nil {
+		client.Close()
+		t.Fatalf("Connecting to a vpp with a different acl_add_replace succeeded")
+	}
+	if !strings.Contains(err.Error(), "acl_add_replace (crc 01234567, expected e839997e)") {
+		t.Fatalf("Unexpected error for a crc mismatch: %v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	server, client, cleanup := startFakeServer(t)
+	defer cleanup()
+
+	bdReq := &BridgeDomainAddDel{BdID: 1, Flood: true, Forward: true, Learn: true, BdTag: "net1", IsAdd: true}
+	if err := client.Call(bdReq, &BridgeDomainAddDelReply{}); err != nil {
+		t.Fatalf("Error creating bridge domain. Err: %v", err)
+	}
+	if err := client.Call(bdReq, &BridgeDomainAddDelReply{}); err == nil {
+		t.Fatalf("Creating a bridge domain twice succeeded")
+	}
+	if server.BridgeDomains()[1] != "net1" {
+		t.Fatalf("Bridge domain was not created: %+v", server.BridgeDomains())
+	}
+
+	afReply := &AfPacketCreateReply{}
+	if err := client.Call(&AfPacketCreate{HostIfName: "vvport1", UseRandomHwAddr: true}, afReply); err != nil {
+		t.Fatalf("Error creating af_packet interface. Err: %v", err)
+	}
+	if err := client.Call(&SwInterfaceSetL2Bridge{RxSwIfIndex: afReply.SwIfIndex, BdID: 1, Enable: true},
+		&SwInterfaceSetL2BridgeReply{}); err != nil {
+		t.Fatalf("Error adding interface to bridge domain. Err: %v", err)
+	}
+
+	aclReply := &ACLAddReplaceReply{}
+	if err := client.Call(&ACLAddReplace{ACLIndex: InvalidIndex, Rules: []ACLRule{{IsPermit: 1}}}, aclReply); err != nil {
+		t.Fatalf("Error creating acl. Err: %v", err)
+	}
+	if err := client.Call(&ACLInterfaceSetACLList{SwIfIndex: afReply.SwIfIndex, NInput: 1,
+		ACLs: []uint32{aclReply.ACLIndex}}, &ACLInterfaceSetACLListReply{}); err != nil {
+		t.Fatalf("Error applying acl. Err: %v", err)
+	}
+
+	intf, found := server.Interface("host-vvport1")
+	if !found || intf.BdID != 1 || !reflect.DeepEqual(intf.InputACLs, []uint32{aclReply.ACLIndex}) {
+		t.Fatalf("Interface was not configured: %+v", intf)
+	}
+
+	if err := client.Call(&ACLDel{ACLIndex: aclReply.ACLIndex}, &ACLDelReply{}); err == nil {
+		t.Fatalf("Deleting an acl in use succeeded")
+	}
+}
+
+func TestGetInterfaceCounters(t *testing.T) {
+	server, client, cleanup := startFakeServer(t)
+	defer cleanup()
+
+	server.AddInterface("host-vvport1")
+	server.AddInterface("host-vvport10")
+	expCounters := InterfaceCounters{RxPackets: 10, RxBytes: 1000, TxPackets: 5, TxBytes: 500, Drops: 2}
+	server.SetCounters("host-vvport1", expCounters)
+
+	counters, err := client.GetInterfaceCounters("host-vvport1")
+	if err != nil {
+		t.Fatalf("Error getting counters. Err: %v", err)
+	}
+	if *counters != expCounters {
+		t.Fatalf("Got counters %+v, expected %+v", *counters, expCounters)
+	}
+
+	if _, err := client.GetInterfaceCounters("host-vvport2"); err == nil {
+		t.Fatalf("Getting counters of a missing interface succeeded")
+	}
+}
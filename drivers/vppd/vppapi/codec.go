@@ -0,0 +1,158 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vppapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// vpp api messages are encoded in network byte order, with fixed size
+// arrays padded with zeros
+
+var errShortMessage = errors.New("vpp api message is too short")
+
+// frameHeaderLen is the length of the header the socket transport puts in
+// front of each message. Bytes 8-11 hold the message length.
+const frameHeaderLen = 16
+
+// encoder appends the fields of a message to a buffer
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) u8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.u8(1)
+	} else {
+		e.u8(0)
+	}
+}
+
+func (e *encoder) u16(v uint16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) u32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) i32(v int32) {
+	e.u32(uint32(v))
+}
+
+// bytes appends a fixed size array of n bytes
+func (e *encoder) bytes(v []byte, n int) {
+	b := make([]byte, n)
+	copy(b, v)
+	e.buf = append(e.buf, b...)
+}
+
+// string appends a string as a fixed size, zero terminated array
+func (e *encoder) string(v string, n int) {
+	if len(v) >= n {
+		v = v[:n-1]
+	}
+	e.bytes([]byte(v), n)
+}
+
+// decoder reads the fields of a message. Reads past the end of the
+// message set err, and return zero values.
+type decoder struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || d.off+n > len(d.buf) {
+		d.err = errShortMessage
+		return make([]byte, n)
+	}
+
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *decoder) u8() uint8 {
+	return d.next(1)[0]
+}
+
+func (d *decoder) bool() bool {
+	return d.u8() != 0
+}
+
+func (d *decoder) u16() uint16 {
+	return binary.BigEndian.Uint16(d.next(2))
+}
+
+func (d *decoder) u32() uint32 {
+	return binary.BigEndian.Uint32(d.next(4))
+}
+
+func (d *decoder) i32() int32 {
+	return int32(d.u32())
+}
+
+func (d *decoder) bytes(n int) []byte {
+	b := make([]byte, n)
+	copy(b, d.next(n))
+	return b
+}
+
+func (d *decoder) string(n int) string {
+	b := d.next(n)
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// writeFrame writes a message with the socket transport header
+func writeFrame(w io.Writer, msg []byte) error {
+	frame := make([]byte, frameHeaderLen, frameHeaderLen+len(msg))
+	binary.BigEndian.PutUint32(frame[8:12], uint32(len(msg)))
+	frame = append(frame, msg...)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// readFrame reads a message sent with the socket transport header
+func readFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, frameHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	msg := make([]byte, binary.BigEndian.Uint32(header[8:12]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
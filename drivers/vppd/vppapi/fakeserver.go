@@ -0,0 +1,570 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vppapi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// return values of the fake vpp, as defined in vnet/api_errno.h
+const (
+	retInvalidSwIfIndex = -2
+	retNoSuchEntry      = -6
+	retValueExists      = -16
+	retInvalidValue     = -1
+)
+
+// FakeInterface is an interface of the fake vpp
+type FakeInterface struct {
+	Name       string
+	SwIfIndex  uint32
+	AdminUp    bool
+	BdID       uint32 // 0 when not in a bridge domain
+	Shg        uint8
+	VlanID     uint32 // sub-interfaces only
+	VtrOp      uint32
+	InputACLs  []uint32
+	OutputACLs []uint32
+	Tunnel     *VxlanAddDelTunnel // vxlan tunnels only
+	Counters   InterfaceCounters
+}
+
+// FakeServer is a fake vpp serving the api messages of this package on a
+// unix socket. It keeps the configuration it receives, for tests to check.
+type FakeServer struct {
+	listener   net.Listener
+	msgIDs     map[string]uint16
+	msgNames   map[uint16]string
+	msgCrcs    map[string]string
+	interfaces map[uint32]*FakeInterface
+	bds        map[uint32]string // bridge domain tags by ID
+	acls       map[uint32][]ACLRule
+	nextIfIdx  uint32
+	nextACLIdx uint32
+	lock       sync.Mutex
+}
+
+// fakeMessages are the messages the fake vpp knows, in message ID order
+var fakeMessages = []Message{
+	&sockclntCreateReply{},
+	&sockclntDelete{}, &sockclntDeleteReply{},
+	&BridgeDomainAddDel{}, &BridgeDomainAddDelReply{},
+	&VxlanAddDelTunnel{}, &VxlanAddDelTunnelReply{},
+	&SwInterfaceSetL2Bridge{}, &SwInterfaceSetL2BridgeReply{},
+	&SwInterfaceSetFlags{}, &SwInterfaceSetFlagsReply{},
+	&AfPacketCreate{}, &AfPacketCreateReply{},
+	&AfPacketDelete{}, &AfPacketDeleteReply{},
+	&CreateVlanSubif{}, &CreateVlanSubifReply{},
+	&DeleteSubif{}, &DeleteSubifReply{},
+	&L2InterfaceVlanTagRewrite{}, &L2InterfaceVlanTagRewriteReply{},
+	&ACLAddReplace{}, &ACLAddReplaceReply{},
+	&ACLDel{}, &ACLDelReply{},
+	&ACLInterfaceSetACLList{}, &ACLInterfaceSetACLListReply{},
+	&CliInband{}, &CliInbandReply{},
+}
+
+// NewFakeServer starts a fake vpp listening on socket
+func NewFakeServer(socket string) (*FakeServer, error) {
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FakeServer{
+		listener:   listener,
+		msgIDs:     make(map[string]uint16),
+		msgNames:   make(map[uint16]string),
+		msgCrcs:    make(map[string]string),
+		interfaces: make(map[uint32]*FakeInterface),
+		bds:        make(map[uint32]string),
+		acls:       make(map[uint32][]ACLRule),
+	}
+	for i, msg := range fakeMessages {
+		id := uint16(sockclntCreateMsgID + 1 + i)
+		s.msgIDs[msg.MessageName()] = id
+		s.msgNames[id] = msg.MessageName()
+		s.msgCrcs[msg.MessageName()] = messageCrcs[msg.MessageName()]
+	}
+
+	// like vpp, index 0 is the local0 interface
+	s.interfaces[0] = &FakeInterface{Name: "local0"}
+	s.nextIfIdx = 1
+
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the fake vpp
+func (s *FakeServer) Close() error {
+	return s.listener.Close()
+}
+
+func (s *FakeServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *FakeServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		msg, err := readFrame(reader)
+		if err != nil {
+			return
+		}
+
+		d := &decoder{buf: msg}
+		msgID := d.u16()
+		if msgID == sockclntCreateMsgID {
+			context := d.u32()
+			e := &encoder{}
+			e.u16(s.msgIDs["sockclnt_create_reply"])
+			e.u32(0)
+			e.u32(context)
+			s.messageTable().marshal(e)
+			writeFrame(conn, e.buf)
+			continue
+		}
+
+		d.u32() // client index
+		context := d.u32()
+		reply := s.handle(s.msgNames[msgID], d)
+		if reply == nil {
+			log.Errorf("fake vpp can't handle message %d", msgID)
+			return
+		}
+
+		e := &encoder{}
+		e.u16(s.msgIDs[reply.MessageName()])
+		e.u32(context)
+		reply.marshal(e)
+		writeFrame(conn, e.buf)
+	}
+}
+
+// SetMessageCrc changes the crc the fake vpp sends for a message, to
+// mimic a vpp version that defines it differently
+func (s *FakeServer) SetMessageCrc(name, crc string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.msgCrcs[name] = crc
+}
+
+// messageTable returns the message table, with the crc suffixes
+func (s *FakeServer) messageTable() *sockclntCreateReply {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reply := &sockclntCreateReply{Index: 1, Messages: make(map[string]uint16)}
+	for name, id := range s.msgIDs {
+		reply.Messages[name+"_"+s.msgCrcs[name]] = id
+	}
+
+	return reply
+}
+
+// handle applies a request, and returns its reply
+func (s *FakeServer) handle(name string, d *decoder) Reply {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch name {
+	case "sockclnt_delete":
+		return &sockclntDeleteReply{}
+
+	case "bridge_domain_add_del":
+		req := &BridgeDomainAddDel{}
+		req.unmarshal(d)
+		reply := &BridgeDomainAddDelReply{}
+		_, found := s.bds[req.BdID]
+		switch {
+		case req.IsAdd && found:
+			reply.Retval = retValueExists
+		case req.IsAdd:
+			s.bds[req.BdID] = req.BdTag
+		case !found:
+			reply.Retval = retNoSuchEntry
+		default:
+			delete(s.bds, req.BdID)
+			for _, intf := range s.interfaces {
+				if intf.BdID == req.BdID {
+					intf.BdID = 0
+				}
+			}
+		}
+		return reply
+
+	case "vxlan_add_del_tunnel":
+		req := &VxlanAddDelTunnel{}
+		req.unmarshal(d)
+		reply := &VxlanAddDelTunnelReply{}
+		var tunnel *FakeInterface
+		for _, intf := range s.interfaces {
+			if intf.Tunnel != nil && bytes.Equal(intf.Tunnel.SrcAddress, req.SrcAddress) &&
+				bytes.Equal(intf.Tunnel.DstAddress, req.DstAddress) && intf.Tunnel.Vni == req.Vni {
+				tunnel = intf
+			}
+		}
+		switch {
+		case req.IsAdd && tunnel != nil:
+			reply.Retval = retValueExists
+		case req.IsAdd:
+			tunnel = s.addInterface(fmt.Sprintf("vxlan_tunnel%d", s.nextIfIdx))
+			tunnel.Tunnel = req
+			reply.SwIfIndex = tunnel.SwIfIndex
+		case tunnel == nil:
+			reply.Retval = retNoSuchEntry
+		default:
+			delete(s.interfaces, tunnel.SwIfIndex)
+			reply.SwIfIndex = tunnel.SwIfIndex
+		}
+		return reply
+
+	case "sw_interface_set_l2_bridge":
+		req := &SwInterfaceSetL2Bridge{}
+		req.unmarshal(d)
+		reply := &SwInterfaceSetL2BridgeReply{}
+		intf, found := s.interfaces[req.RxSwIfIndex]
+		_, bdFound := s.bds[req.BdID]
+		switch {
+		case !found:
+			reply.Retval = retInvalidSwIfIndex
+		case req.Enable && !bdFound:
+			reply.Retval = retNoSuchEntry
+		case req.Enable:
+			intf.BdID = req.BdID
+			intf.Shg = req.Shg
+		default:
+			intf.BdID = 0
+			intf.Shg = 0
+		}
+		return reply
+
+	case "sw_interface_set_flags":
+		req := &SwInterfaceSetFlags{}
+		req.unmarshal(d)
+		reply := &SwInterfaceSetFlagsReply{}
+		if intf, found := s.interfaces[req.SwIfIndex]; found {
+			intf.AdminUp = req.AdminUpDown
+		} else {
+			reply.Retval = retInvalidSwIfIndex
+		}
+		return reply
+
+	case "af_packet_create":
+		req := &AfPacketCreate{}
+		req.unmarshal(d)
+		reply := &AfPacketCreateReply{}
+		if s.findInterface("host-"+req.HostIfName) != nil {
+			reply.Retval = retValueExists
+		} else {
+			reply.SwIfIndex = s.addInterface("host-" + req.HostIfName).SwIfIndex
+		}
+		return reply
+
+	case "af_packet_delete":
+		req := &AfPacketDelete{}
+		req.unmarshal(d)
+		reply := &AfPacketDeleteReply{}
+		if intf := s.findInterface("host-" + req.HostIfName); intf != nil {
+			delete(s.interfaces, intf.SwIfIndex)
+		} else {
+			reply.Retval = retInvalidSwIfIndex
+		}
+		return reply
+
+	case "create_vlan_subif":
+		req := &CreateVlanSubif{}
+		req.unmarshal(d)
+		reply := &CreateVlanSubifReply{}
+		parent, found := s.interfaces[req.SwIfIndex]
+		name := ""
+		if found {
+			name = fmt.Sprintf("%s.%d", parent.Name, req.VlanID)
+		}
+		switch {
+		case !found:
+			reply.Retval = retInvalidSwIfIndex
+		case s.findInterface(name) != nil:
+			reply.Retval = retValueExists
+		default:
+			subif := s.addInterface(name)
+			subif.VlanID = req.VlanID
+			reply.SwIfIndex = subif.SwIfIndex
+		}
+		return reply
+
+	case "delete_subif":
+		req := &DeleteSubif{}
+		req.unmarshal(d)
+		reply := &DeleteSubifReply{}
+		if intf, found := s.interfaces[req.SwIfIndex]; found && intf.VlanID != 0 {
+			delete(s.interfaces, req.SwIfIndex)
+		} else {
+			reply.Retval = retInvalidSwIfIndex
+		}
+		return reply
+
+	case "l2_interface_vlan_tag_rewrite":
+		req := &L2InterfaceVlanTagRewrite{}
+		req.unmarshal(d)
+		reply := &L2InterfaceVlanTagRewriteReply{}
+		if intf, found := s.interfaces[req.SwIfIndex]; found {
+			intf.VtrOp = req.VtrOp
+		} else {
+			reply.Retval = retInvalidSwIfIndex
+		}
+		return reply
+
+	case "acl_add_replace":
+		req := &ACLAddReplace{}
+		req.unmarshal(d)
+		reply := &ACLAddReplaceReply{ACLIndex: req.ACLIndex}
+		if req.ACLIndex == InvalidIndex {
+			reply.ACLIndex = s.nextACLIdx
+			s.nextACLIdx++
+		} else if _, found := s.acls[req.ACLIndex]; !found {
+			reply.Retval = retNoSuchEntry
+			return reply
+		}
+		s.acls[reply.ACLIndex] = req.Rules
+		return reply
+
+	case "acl_del":
+		req := &ACLDel{}
+		req.unmarshal(d)
+		reply := &ACLDelReply{}
+		if _, found := s.acls[req.ACLIndex]; !found {
+			reply.Retval = retNoSuchEntry
+		} else if s.aclInUse(req.ACLIndex) {
+			// vpp refuses to delete acls applied to interfaces
+			reply.Retval = retInvalidValue
+		} else {
+			delete(s.acls, req.ACLIndex)
+		}
+		return reply
+
+	case "acl_interface_set_acl_list":
+		req := &ACLInterfaceSetACLList{}
+		req.unmarshal(d)
+		reply := &ACLInterfaceSetACLListReply{}
+		intf, found := s.interfaces[req.SwIfIndex]
+		if !found {
+			reply.Retval = retInvalidSwIfIndex
+			return reply
+		}
+		for _, acl := range req.ACLs {
+			if _, found := s.acls[acl]; !found {
+				reply.Retval = retNoSuchEntry
+				return reply
+			}
+		}
+		if int(req.NInput) > len(req.ACLs) {
+			reply.Retval = retInvalidValue
+			return reply
+		}
+		intf.InputACLs = req.ACLs[:req.NInput]
+		intf.OutputACLs = req.ACLs[req.NInput:]
+		return reply
+
+	case "cli_inband":
+		req := &CliInband{}
+		req.unmarshal(d)
+		return &CliInbandReply{Reply: s.cli(req.Cmd)}
+	}
+
+	return nil
+}
+
+// cli runs the only cli command the fake vpp knows, show interface
+func (s *FakeServer) cli(cmd string) string {
+	args := strings.Fields(cmd)
+	if len(args) < 2 || args[0] != "show" || !strings.HasPrefix("interface", args[1]) {
+		return fmt.Sprintf("unknown input `%s'", cmd)
+	}
+
+	intfs := []*FakeInterface{}
+	for _, name := range args[2:] {
+		intf := s.findInterface(name)
+		if intf == nil {
+			return fmt.Sprintf("show interface: unknown input `%s'", name)
+		}
+		intfs = append(intfs, intf)
+	}
+	if len(intfs) == 0 {
+		for _, intf := range s.interfaces {
+			intfs = append(intfs, intf)
+		}
+		sort.Sort(fakeInterfaceList(intfs))
+	}
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, "%15s%20s%10s%20s%20s\n", "Name", "Idx", "State", "Counter", "Count")
+	for _, intf := range intfs {
+		state := "down"
+		if intf.AdminUp {
+			state = "up"
+		}
+		counters := []struct {
+			name  string
+			value uint64
+		}{
+			{"rx packets", intf.Counters.RxPackets},
+			{"rx bytes", intf.Counters.RxBytes},
+			{"tx packets", intf.Counters.TxPackets},
+			{"tx bytes", intf.Counters.TxBytes},
+			{"drops", intf.Counters.Drops},
+		}
+		for i, c := range counters {
+			if i == 0 {
+				fmt.Fprintf(out, "%-27s%-10d%-10s%-20s%15d\n", intf.Name, intf.SwIfIndex, state, c.name, c.value)
+			} else {
+				fmt.Fprintf(out, "%47s%-20s%15d\n", "", c.name, c.value)
+			}
+		}
+	}
+
+	return out.String()
+}
+
+type fakeInterfaceList []*FakeInterface
+
+func (l fakeInterfaceList) Len() int           { return len(l) }
+func (l fakeInterfaceList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l fakeInterfaceList) Less(i, j int) bool { return l[i].SwIfIndex < l[j].SwIfIndex }
+
+func (s *FakeServer) addInterface(name string) *FakeInterface {
+	intf := &FakeInterface{Name: name, SwIfIndex: s.nextIfIdx}
+	s.interfaces[intf.SwIfIndex] = intf
+	s.nextIfIdx++
+	return intf
+}
+
+func (s *FakeServer) findInterface(name string) *FakeInterface {
+	for _, intf := range s.interfaces {
+		if intf.Name == name {
+			return intf
+		}
+	}
+
+	return nil
+}
+
+func (s *FakeServer) aclInUse(aclIndex uint32) bool {
+	for _, intf := range s.interfaces {
+		for _, acl := range append(append([]uint32{}, intf.InputACLs...), intf.OutputACLs...) {
+			if acl == aclIndex {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// AddInterface adds a hardware interface, like an uplink, to the fake vpp
+func (s *FakeServer) AddInterface(name string) uint32 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.addInterface(name).SwIfIndex
+}
+
+// Interface returns a copy of the interface with the given name
+func (s *FakeServer) Interface(name string) (FakeInterface, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	intf := s.findInterface(name)
+	if intf == nil {
+		return FakeInterface{}, false
+	}
+
+	return *intf, true
+}
+
+// Interfaces returns copies of all the interfaces, by sw_if_index
+func (s *FakeServer) Interfaces() map[uint32]FakeInterface {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	intfs := make(map[uint32]FakeInterface)
+	for idx, intf := range s.interfaces {
+		intfs[idx] = *intf
+	}
+
+	return intfs
+}
+
+// SetCounters sets the counters of an interface
+func (s *FakeServer) SetCounters(name string, counters InterfaceCounters) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	intf := s.findInterface(name)
+	if intf == nil {
+		return false
+	}
+
+	intf.Counters = counters
+	return true
+}
+
+// BridgeDomains returns the tags of the bridge domains, by ID
+func (s *FakeServer) BridgeDomains() map[uint32]string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	bds := make(map[uint32]string)
+	for id, tag := range s.bds {
+		bds[id] = tag
+	}
+
+	return bds
+}
+
+// ACL returns the rules of an acl
+func (s *FakeServer) ACL(aclIndex uint32) ([]ACLRule, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	rules, found := s.acls[aclIndex]
+	return rules, found
+}
+
+// NumACLs returns the number of acls
+func (s *FakeServer) NumACLs() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.acls)
+}
@@ -0,0 +1,648 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vppapi
+
+// The messages below follow the definitions in the .api files of vpp
+// 18.01. Message IDs are assigned by vpp at run time, and are looked up
+// by name in the message table it sends when a client connects.
+
+// messageCrcs are the crcs of the message definitions this package
+// implements, as vpp 18.01 appends them to the names in its message table.
+// A different crc means vpp changed the layout of the message.
+var messageCrcs = map[string]string{
+	"sockclnt_create":                     "df2cf94d",
+	"sockclnt_create_reply":               "44f8d7e6",
+	"sockclnt_delete":                     "8ac76db6",
+	"sockclnt_delete_reply":               "8f38b1ee",
+	"bridge_domain_add_del":               "c6360720",
+	"bridge_domain_add_del_reply":         "e8d4e804",
+	"vxlan_add_del_tunnel":                "00f4bdd0",
+	"vxlan_add_del_tunnel_reply":          "fda5941f",
+	"sw_interface_set_l2_bridge":          "5579f809",
+	"sw_interface_set_l2_bridge_reply":    "e8d4e804",
+	"sw_interface_set_flags":              "555485f5",
+	"sw_interface_set_flags_reply":        "e8d4e804",
+	"af_packet_create":                    "6d5d30d6",
+	"af_packet_create_reply":              "fda5941f",
+	"af_packet_delete":                    "3efceda3",
+	"af_packet_delete_reply":              "e8d4e804",
+	"create_vlan_subif":                   "70cadeda",
+	"create_vlan_subif_reply":             "fda5941f",
+	"delete_subif":                        "a9a8c5a4",
+	"delete_subif_reply":                  "e8d4e804",
+	"l2_interface_vlan_tag_rewrite":       "b9dcbd39",
+	"l2_interface_vlan_tag_rewrite_reply": "e8d4e804",
+	"acl_add_replace":                     "e839997e",
+	"acl_add_replace_reply":               "ac407b0c",
+	"acl_del":                             "ef34fea4",
+	"acl_del_reply":                       "e8d4e804",
+	"acl_interface_set_acl_list":          "8baece13",
+	"acl_interface_set_acl_list_reply":    "e8d4e804",
+	"cli_inband":                          "b1ad59b3",
+	"cli_inband_reply":                    "6d3c80a4",
+}
+
+// InvalidIndex is the ~0 value vpp uses for unset interface and acl indexes
+const InvalidIndex = ^uint32(0)
+
+// VxlanDecapL2Input makes vxlan tunnels hand decapsulated packets to the
+// l2 input of their bridge domain
+const VxlanDecapL2Input = 1
+
+// VtrPop1 is the vlan tag rewrite operation that pops the outer tag
+const VtrPop1 = 3
+
+// Message is a vpp binary api message
+type Message interface {
+	// MessageName is the name of the message, without its crc suffix
+	MessageName() string
+	marshal(e *encoder)
+	unmarshal(d *decoder)
+}
+
+// Reply is a message vpp sends in response to a request
+type Reply interface {
+	Message
+	// RetVal is the return value of the request, 0 on success
+	RetVal() int32
+}
+
+// retvalReply is a reply that only carries a return value
+type retvalReply struct {
+	Retval int32
+}
+
+func (r *retvalReply) marshal(e *encoder)   { e.i32(r.Retval) }
+func (r *retvalReply) unmarshal(d *decoder) { r.Retval = d.i32() }
+
+// RetVal returns the return value of the request
+func (r *retvalReply) RetVal() int32 { return r.Retval }
+
+// swIfIndexReply is a reply that carries a return value and the index of
+// the interface the request created
+type swIfIndexReply struct {
+	Retval    int32
+	SwIfIndex uint32
+}
+
+func (r *swIfIndexReply) marshal(e *encoder) {
+	e.i32(r.Retval)
+	e.u32(r.SwIfIndex)
+}
+
+func (r *swIfIndexReply) unmarshal(d *decoder) {
+	r.Retval = d.i32()
+	r.SwIfIndex = d.u32()
+}
+
+// RetVal returns the return value of the request
+func (r *swIfIndexReply) RetVal() int32 { return r.Retval }
+
+// sockclntCreate registers a client on the api socket. vpp sends the
+// message table in the reply.
+type sockclntCreate struct {
+	Name string
+}
+
+// sockclntCreateMsgID is the fixed ID of sockclnt_create, which is sent
+// before the client knows the message table
+const sockclntCreateMsgID = 15
+
+func (*sockclntCreate) MessageName() string  { return "sockclnt_create" }
+func (m *sockclntCreate) marshal(e *encoder) { e.string(m.Name, 64) }
+func (m *sockclntCreate) unmarshal(d *decoder) {
+	m.Name = d.string(64)
+}
+
+// sockclntCreateReply carries the index of the client and the message table
+type sockclntCreateReply struct {
+	Response int32
+	Index    uint32
+	Messages map[string]uint16 // message IDs by name, with the crc suffix
+}
+
+func (*sockclntCreateReply) MessageName() string { return "sockclnt_create_reply" }
+func (r *sockclntCreateReply) RetVal() int32     { return r.Response }
+func (r *sockclntCreateReply) marshal(e *encoder) {
+	e.i32(r.Response)
+	e.u32(r.Index)
+	e.u16(uint16(len(r.Messages)))
+	for name, id := range r.Messages {
+		e.u16(id)
+		e.string(name, 64)
+	}
+}
+
+func (r *sockclntCreateReply) unmarshal(d *decoder) {
+	r.Response = d.i32()
+	r.Index = d.u32()
+	count := int(d.u16())
+	r.Messages = make(map[string]uint16)
+	for i := 0; i < count && d.err == nil; i++ {
+		id := d.u16()
+		r.Messages[d.string(64)] = id
+	}
+}
+
+// sockclntDelete unregisters a client
+type sockclntDelete struct {
+	Index uint32
+}
+
+func (*sockclntDelete) MessageName() string    { return "sockclnt_delete" }
+func (m *sockclntDelete) marshal(e *encoder)   { e.u32(m.Index) }
+func (m *sockclntDelete) unmarshal(d *decoder) { m.Index = d.u32() }
+
+type sockclntDeleteReply struct{ retvalReply }
+
+func (*sockclntDeleteReply) MessageName() string { return "sockclnt_delete_reply" }
+
+// BridgeDomainAddDel creates or deletes a bridge domain
+type BridgeDomainAddDel struct {
+	BdID    uint32
+	Flood   bool
+	UuFlood bool
+	Forward bool
+	Learn   bool
+	ArpTerm bool
+	MacAge  uint8
+	BdTag   string
+	IsAdd   bool
+}
+
+// MessageName returns the name of the message
+func (*BridgeDomainAddDel) MessageName() string { return "bridge_domain_add_del" }
+
+func (m *BridgeDomainAddDel) marshal(e *encoder) {
+	e.u32(m.BdID)
+	e.bool(m.Flood)
+	e.bool(m.UuFlood)
+	e.bool(m.Forward)
+	e.bool(m.Learn)
+	e.bool(m.ArpTerm)
+	e.u8(m.MacAge)
+	e.string(m.BdTag, 64)
+	e.bool(m.IsAdd)
+}
+
+func (m *BridgeDomainAddDel) unmarshal(d *decoder) {
+	m.BdID = d.u32()
+	m.Flood = d.bool()
+	m.UuFlood = d.bool()
+	m.Forward = d.bool()
+	m.Learn = d.bool()
+	m.ArpTerm = d.bool()
+	m.MacAge = d.u8()
+	m.BdTag = d.string(64)
+	m.IsAdd = d.bool()
+}
+
+// BridgeDomainAddDelReply is the reply to BridgeDomainAddDel
+type BridgeDomainAddDelReply struct{ retvalReply }
+
+// MessageName returns the name of the message
+func (*BridgeDomainAddDelReply) MessageName() string { return "bridge_domain_add_del_reply" }
+
+// VxlanAddDelTunnel creates or deletes a vxlan tunnel interface
+type VxlanAddDelTunnel struct {
+	IsAdd          bool
+	IsIPv6         bool
+	SrcAddress     []byte // 4 or 16 bytes
+	DstAddress     []byte
+	McastSwIfIndex uint32
+	EncapVrfID     uint32
+	DecapNextIndex uint32
+	Vni            uint32
+}
+
+// MessageName returns the name of the message
+func (*VxlanAddDelTunnel) MessageName() string { return "vxlan_add_del_tunnel" }
+
+func (m *VxlanAddDelTunnel) marshal(e *encoder) {
+	e.bool(m.IsAdd)
+	e.bool(m.IsIPv6)
+	e.bytes(m.SrcAddress, 16)
+	e.bytes(m.DstAddress, 16)
+	e.u32(m.McastSwIfIndex)
+	e.u32(m.EncapVrfID)
+	e.u32(m.DecapNextIndex)
+	e.u32(m.Vni)
+}
+
+func (m *VxlanAddDelTunnel) unmarshal(d *decoder) {
+	m.IsAdd = d.bool()
+	m.IsIPv6 = d.bool()
+	m.SrcAddress = d.bytes(16)
+	m.DstAddress = d.bytes(16)
+	m.McastSwIfIndex = d.u32()
+	m.EncapVrfID = d.u32()
+	m.DecapNextIndex = d.u32()
+	m.Vni = d.u32()
+	if !m.IsIPv6 {
+		m.SrcAddress = m.SrcAddress[:4]
+		m.DstAddress = m.DstAddress[:4]
+	}
+}
+
+// VxlanAddDelTunnelReply is the reply to VxlanAddDelTunnel
+type VxlanAddDelTunnelReply struct{ swIfIndexReply }
+
+// MessageName returns the name of the message
+func (*VxlanAddDelTunnelReply) MessageName() string { return "vxlan_add_del_tunnel_reply" }
+
+// SwInterfaceSetL2Bridge adds an interface to a bridge domain, or removes
+// it when Enable is not set
+type SwInterfaceSetL2Bridge struct {
+	RxSwIfIndex uint32
+	BdID        uint32
+	Shg         uint8 // split horizon group
+	Bvi         bool
+	Enable      bool
+}
+
+// MessageName returns the name of the message
+func (*SwInterfaceSetL2Bridge) MessageName() string { return "sw_interface_set_l2_bridge" }
+
+func (m *SwInterfaceSetL2Bridge) marshal(e *encoder) {
+	e.u32(m.RxSwIfIndex)
+	e.u32(m.BdID)
+	e.u8(m.Shg)
+	e.bool(m.Bvi)
+	e.bool(m.Enable)
+}
+
+func (m *SwInterfaceSetL2Bridge) unmarshal(d *decoder) {
+	m.RxSwIfIndex = d.u32()
+	m.BdID = d.u32()
+	m.Shg = d.u8()
+	m.Bvi = d.bool()
+	m.Enable = d.bool()
+}
+
+// SwInterfaceSetL2BridgeReply is the reply to SwInterfaceSetL2Bridge
+type SwInterfaceSetL2BridgeReply struct{ retvalReply }
+
+// MessageName returns the name of the message
+func (*SwInterfaceSetL2BridgeReply) MessageName() string { return "sw_interface_set_l2_bridge_reply" }
+
+// SwInterfaceSetFlags sets the admin state of an interface
+type SwInterfaceSetFlags struct {
+	SwIfIndex   uint32
+	AdminUpDown bool
+}
+
+// MessageName returns the name of the message
+func (*SwInterfaceSetFlags) MessageName() string { return "sw_interface_set_flags" }
+
+func (m *SwInterfaceSetFlags) marshal(e *encoder) {
+	e.u32(m.SwIfIndex)
+	e.bool(m.AdminUpDown)
+}
+
+func (m *SwInterfaceSetFlags) unmarshal(d *decoder) {
+	m.SwIfIndex = d.u32()
+	m.AdminUpDown = d.bool()
+}
+
+// SwInterfaceSetFlagsReply is the reply to SwInterfaceSetFlags
+type SwInterfaceSetFlagsReply struct{ retvalReply }
+
+// MessageName returns the name of the message
+func (*SwInterfaceSetFlagsReply) MessageName() string { return "sw_interface_set_flags_reply" }
+
+// AfPacketCreate attaches a linux interface, like one end of a veth pair,
+// to vpp as a host-<name> interface
+type AfPacketCreate struct {
+	HostIfName      string
+	HwAddr          []byte
+	UseRandomHwAddr bool
+}
+
+// MessageName returns the name of the message
+func (*AfPacketCreate) MessageName() string { return "af_packet_create" }
+
+func (m *AfPacketCreate) marshal(e *encoder) {
+	e.string(m.HostIfName, 64)
+	e.bytes(m.HwAddr, 6)
+	e.bool(m.UseRandomHwAddr)
+}
+
+func (m *AfPacketCreate) unmarshal(d *decoder) {
+	m.HostIfName = d.string(64)
+	m.HwAddr = d.bytes(6)
+	m.UseRandomHwAddr = d.bool()
+}
+
+// AfPacketCreateReply is the reply to AfPacketCreate
+type AfPacketCreateReply struct{ swIfIndexReply }
+
+// MessageName returns the name of the message
+func (*AfPacketCreateReply) MessageName() string { return "af_packet_create_reply" }
+
+// AfPacketDelete detaches a linux interface from vpp
+type AfPacketDelete struct {
+	HostIfName string
+}
+
+// MessageName returns the name of the message
+func (*AfPacketDelete) MessageName() string    { return "af_packet_delete" }
+func (m *AfPacketDelete) marshal(e *encoder)   { e.string(m.HostIfName, 64) }
+func (m *AfPacketDelete) unmarshal(d *decoder) { m.HostIfName = d.string(64) }
+
+// AfPacketDeleteReply is the reply to AfPacketDelete
+type AfPacketDeleteReply struct{ retvalReply }
+
+// MessageName returns the name of the message
+func (*AfPacketDeleteReply) MessageName() string { return "af_packet_delete_reply" }
+
+// CreateVlanSubif creates a dot1q sub-interface
+type CreateVlanSubif struct {
+	SwIfIndex uint32
+	VlanID    uint32
+}
+
+// MessageName returns the name of the message
+func (*CreateVlanSubif) MessageName() string { return "create_vlan_subif" }
+
+func (m *CreateVlanSubif) marshal(e *encoder) {
+	e.u32(m.SwIfIndex)
+	e.u32(m.VlanID)
+}
+
+func (m *CreateVlanSubif) unmarshal(d *decoder) {
+	m.SwIfIndex = d.u32()
+	m.VlanID = d.u32()
+}
+
+// CreateVlanSubifReply is the reply to CreateVlanSubif
+type CreateVlanSubifReply struct{ swIfIndexReply }
+
+// MessageName returns the name of the message
+func (*CreateVlanSubifReply) MessageName() string { return "create_vlan_subif_reply" }
+
+// DeleteSubif deletes a sub-interface
+type DeleteSubif struct {
+	SwIfIndex uint32
+}
+
+// MessageName returns the name of the message
+func (*DeleteSubif) MessageName() string    { return "delete_subif" }
+func (m *DeleteSubif) marshal(e *encoder)   { e.u32(m.SwIfIndex) }
+func (m *DeleteSubif) unmarshal(d *decoder) { m.SwIfIndex = d.u32() }
+
+// DeleteSubifReply is the reply to DeleteSubif
+type DeleteSubifReply struct{ retvalReply }
+
+// MessageName returns the name of the message
+func (*DeleteSubifReply) MessageName() string { return "delete_subif_reply" }
+
+// L2InterfaceVlanTagRewrite sets the vlan tag operation of an l2 interface
+type L2InterfaceVlanTagRewrite struct {
+	SwIfIndex uint32
+	VtrOp     uint32
+	PushDot1q uint32
+	Tag1      uint32
+	Tag2      uint32
+}
+
+// MessageName returns the name of the message
+func (*L2InterfaceVlanTagRewrite) MessageName() string { return "l2_interface_vlan_tag_rewrite" }
+
+func (m *L2InterfaceVlanTagRewrite) marshal(e *encoder) {
+	e.u32(m.SwIfIndex)
+	e.u32(m.VtrOp)
+	e.u32(m.PushDot1q)
+	e.u32(m.Tag1)
+	e.u32(m.Tag2)
+}
+
+func (m *L2InterfaceVlanTagRewrite) unmarshal(d *decoder) {
+	m.SwIfIndex = d.u32()
+	m.VtrOp = d.u32()
+	m.PushDot1q = d.u32()
+	m.Tag1 = d.u32()
+	m.Tag2 = d.u32()
+}
+
+// L2InterfaceVlanTagRewriteReply is the reply to L2InterfaceVlanTagRewrite
+type L2InterfaceVlanTagRewriteReply struct{ retvalReply }
+
+// MessageName returns the name of the message
+func (*L2InterfaceVlanTagRewriteReply) MessageName() string {
+	return "l2_interface_vlan_tag_rewrite_reply"
+}
+
+// ACLRule is a rule of an acl of the vpp acl plugin
+type ACLRule struct {
+	IsPermit       uint8 // 0 deny, 1 permit, 2 permit and reflect
+	IsIPv6         bool
+	SrcIPAddr      []byte // 4 or 16 bytes
+	SrcIPPrefixLen uint8
+	DstIPAddr      []byte
+	DstIPPrefixLen uint8
+	Proto          uint8
+	SrcPortFirst   uint16 // or icmp type
+	SrcPortLast    uint16
+	DstPortFirst   uint16 // or icmp code
+	DstPortLast    uint16
+	TCPFlagsMask   uint8
+	TCPFlagsValue  uint8
+}
+
+func (r *ACLRule) marshal(e *encoder) {
+	e.u8(r.IsPermit)
+	e.bool(r.IsIPv6)
+	e.bytes(r.SrcIPAddr, 16)
+	e.u8(r.SrcIPPrefixLen)
+	e.bytes(r.DstIPAddr, 16)
+	e.u8(r.DstIPPrefixLen)
+	e.u8(r.Proto)
+	e.u16(r.SrcPortFirst)
+	e.u16(r.SrcPortLast)
+	e.u16(r.DstPortFirst)
+	e.u16(r.DstPortLast)
+	e.u8(r.TCPFlagsMask)
+	e.u8(r.TCPFlagsValue)
+}
+
+func (r *ACLRule) unmarshal(d *decoder) {
+	r.IsPermit = d.u8()
+	r.IsIPv6 = d.bool()
+	r.SrcIPAddr = d.bytes(16)
+	r.SrcIPPrefixLen = d.u8()
+	r.DstIPAddr = d.bytes(16)
+	r.DstIPPrefixLen = d.u8()
+	r.Proto = d.u8()
+	r.SrcPortFirst = d.u16()
+	r.SrcPortLast = d.u16()
+	r.DstPortFirst = d.u16()
+	r.DstPortLast = d.u16()
+	r.TCPFlagsMask = d.u8()
+	r.TCPFlagsValue = d.u8()
+	if !r.IsIPv6 {
+		r.SrcIPAddr = r.SrcIPAddr[:4]
+		r.DstIPAddr = r.DstIPAddr[:4]
+	}
+}
+
+// ACLAddReplace creates an acl, or replaces the rules of an existing one
+// when ACLIndex is set
+type ACLAddReplace struct {
+	ACLIndex uint32 // InvalidIndex creates a new acl
+	Tag      string
+	Rules    []ACLRule
+}
+
+// MessageName returns the name of the message
+func (*ACLAddReplace) MessageName() string { return "acl_add_replace" }
+
+func (m *ACLAddReplace) marshal(e *encoder) {
+	e.u32(m.ACLIndex)
+	e.string(m.Tag, 64)
+	e.u32(uint32(len(m.Rules)))
+	for i := range m.Rules {
+		m.Rules[i].marshal(e)
+	}
+}
+
+func (m *ACLAddReplace) unmarshal(d *decoder) {
+	m.ACLIndex = d.u32()
+	m.Tag = d.string(64)
+	count := int(d.u32())
+	m.Rules = []ACLRule{}
+	for i := 0; i < count && d.err == nil; i++ {
+		rule := ACLRule{}
+		rule.unmarshal(d)
+		m.Rules = append(m.Rules, rule)
+	}
+}
+
+// ACLAddReplaceReply is the reply to ACLAddReplace
+type ACLAddReplaceReply struct {
+	ACLIndex uint32
+	Retval   int32
+}
+
+// MessageName returns the name of the message
+func (*ACLAddReplaceReply) MessageName() string { return "acl_add_replace_reply" }
+
+// RetVal returns the return value of the request
+func (r *ACLAddReplaceReply) RetVal() int32 { return r.Retval }
+
+func (r *ACLAddReplaceReply) marshal(e *encoder) {
+	e.u32(r.ACLIndex)
+	e.i32(r.Retval)
+}
+
+func (r *ACLAddReplaceReply) unmarshal(d *decoder) {
+	r.ACLIndex = d.u32()
+	r.Retval = d.i32()
+}
+
+// ACLDel deletes an acl
+type ACLDel struct {
+	ACLIndex uint32
+}
+
+// MessageName returns the name of the message
+func (*ACLDel) MessageName() string    { return "acl_del" }
+func (m *ACLDel) marshal(e *encoder)   { e.u32(m.ACLIndex) }
+func (m *ACLDel) unmarshal(d *decoder) { m.ACLIndex = d.u32() }
+
+// ACLDelReply is the reply to ACLDel
+type ACLDelReply struct{ retvalReply }
+
+// MessageName returns the name of the message
+func (*ACLDelReply) MessageName() string { return "acl_del_reply" }
+
+// ACLInterfaceSetACLList sets the acls of an interface. The first NInput
+// acls apply to the packets the interface receives, the rest to the packets
+// vpp sends out of it.
+type ACLInterfaceSetACLList struct {
+	SwIfIndex uint32
+	NInput    uint8
+	ACLs      []uint32
+}
+
+// MessageName returns the name of the message
+func (*ACLInterfaceSetACLList) MessageName() string { return "acl_interface_set_acl_list" }
+
+func (m *ACLInterfaceSetACLList) marshal(e *encoder) {
+	e.u32(m.SwIfIndex)
+	e.u8(uint8(len(m.ACLs)))
+	e.u8(m.NInput)
+	for _, acl := range m.ACLs {
+		e.u32(acl)
+	}
+}
+
+func (m *ACLInterfaceSetACLList) unmarshal(d *decoder) {
+	m.SwIfIndex = d.u32()
+	count := int(d.u8())
+	m.NInput = d.u8()
+	m.ACLs = []uint32{}
+	for i := 0; i < count && d.err == nil; i++ {
+		m.ACLs = append(m.ACLs, d.u32())
+	}
+}
+
+// ACLInterfaceSetACLListReply is the reply to ACLInterfaceSetACLList
+type ACLInterfaceSetACLListReply struct{ retvalReply }
+
+// MessageName returns the name of the message
+func (*ACLInterfaceSetACLListReply) MessageName() string {
+	return "acl_interface_set_acl_list_reply"
+}
+
+// CliInband runs a debug cli command, and returns its output
+type CliInband struct {
+	Cmd string
+}
+
+// MessageName returns the name of the message
+func (*CliInband) MessageName() string { return "cli_inband" }
+
+func (m *CliInband) marshal(e *encoder) {
+	e.u32(uint32(len(m.Cmd)))
+	e.buf = append(e.buf, m.Cmd...)
+}
+
+func (m *CliInband) unmarshal(d *decoder) {
+	m.Cmd = string(d.bytes(int(d.u32())))
+}
+
+// CliInbandReply is the reply to CliInband
+type CliInbandReply struct {
+	Retval int32
+	Reply  string
+}
+
+// MessageName returns the name of the message
+func (*CliInbandReply) MessageName() string { return "cli_inband_reply" }
+
+// RetVal returns the return value of the request
+func (r *CliInbandReply) RetVal() int32 { return r.Retval }
+
+func (r *CliInbandReply) marshal(e *encoder) {
+	e.i32(r.Retval)
+	e.u32(uint32(len(r.Reply)))
+	e.buf = append(e.buf, r.Reply...)
+}
+
+func (r *CliInbandReply) unmarshal(d *decoder) {
+	r.Retval = d.i32()
+	r.Reply = string(d.bytes(int(d.u32())))
+}
@@ -8,4 +8,18 @@ const (
 	// StateOperPath is the path to the operations stored in state.
 	vppOperPathPrefix = mastercfg.StateOperPath + "vpp-driver/"
 	vppOperPath       = vppOperPathPrefix + "%s"
+
+	maxIntfRetry = 100
+	maxPortNum   = 0xffff
+
+	// vpp names the af_packet interface of a linux interface host-<name>
+	hostIntfPrefix = "host-"
+
+	defaultVxlanPort = 4789
+
+	// tunnels of a bridge domain are in the same split horizon group, so
+	// that packets from a host are not flooded back to the other hosts
+	tunnelShg = 1
+
+	defaultACLTag = "contiv-default-permit"
 )
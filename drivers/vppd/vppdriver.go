@@ -18,18 +18,68 @@ package vppd
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"strings"
 	"sync"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/contiv/netplugin/core"
-	//vpp "github.com/ligato/vpp-agent"
+	"github.com/contiv/netplugin/drivers"
+	"github.com/contiv/netplugin/drivers/vppd/vppapi"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/utils/netutils"
+	"github.com/contiv/ofnet"
+	"github.com/vishvananda/netlink"
 )
 
+// VppNetwork is a network programmed in vpp
+type VppNetwork struct {
+	BdID       uint32            `json:"bdID"`
+	PktTagType string            `json:"pktTagType"`
+	PktTag     int               `json:"pktTag"`
+	ExtPktTag  int               `json:"extPktTag"`
+	Tunnels    map[string]uint32 `json:"tunnels"`    // vxlan tunnel sw_if_index by peer vtep
+	SubIf      uint32            `json:"subIf"`      // vlan sub-interface on the uplink
+	SubIfValid bool              `json:"subIfValid"` // the network has a sub-interface
+	Mtu        int               `json:"mtu"`        // mtu of the endpoints
+}
+
+// VppEndpoint is a local endpoint attached to vpp
+type VppEndpoint struct {
+	NetID           string   `json:"netID"`
+	IntfName        string   `json:"intfName"` // container side of the veth pair
+	PortName        string   `json:"portName"` // vpp side of the veth pair
+	SwIfIndex       uint32   `json:"swIfIndex"`
+	EndpointGroupID int      `json:"endpointGroupId"`
+	IPAddress       string   `json:"ipAddress"`
+	InputACLs       []uint32 `json:"inputACLs"`
+	OutputACLs      []uint32 `json:"outputACLs"`
+}
+
+// VppPolicyRule is a policy rule and the vpp acl it maps to
+type VppPolicyRule struct {
+	Rule     ofnet.OfnetPolicyRule `json:"rule"`
+	ACLIndex uint32                `json:"aclIndex"`
+	ACLRules []vppapi.ACLRule      `json:"aclRules"` // rules of the acl, empty when it matches nothing
+}
+
 // VppDriverOperState carries operational state of the VppDriver.
 type VppDriverOperState struct {
 	core.CommonState
 
-	// Add any vppdriver specific state for endpoint/network etc
+	// used to allocate port names and bridge domain IDs
+	CurrPortNum     int                       `json:"currPortNum"`
+	CurrBdID        uint32                    `json:"currBdID"`
+	UplinkSwIfIndex uint32                    `json:"uplinkSwIfIndex"` // 0 when there is no uplink
+	DefaultACLIndex uint32                    `json:"defaultACLIndex"` // permits what no rule matched
+	Peers           []string                  `json:"peers"`           // vteps of the other hosts
+	Networks        map[string]*VppNetwork    `json:"networks"`
+	Endpoints       map[string]*VppEndpoint   `json:"endpoints"`
+	PolicyRules     map[string]*VppPolicyRule `json:"policyRules"`
+}
+
+func init() {
+	core.RegisterSchema(vppOperPathPrefix, &VppDriverOperState{})
 }
 
 // Write the state
@@ -55,90 +105,622 @@ func (s *VppDriverOperState) Clear() error {
 	return s.StateDriver.ClearState(key)
 }
 
-// VppDriver holds the operational state of vpp driver
+// VppDriver implements the network driver interface on vpp. Networks are
+// bridge domains, joined to the other hosts with vxlan tunnels or to the
+// uplink with vlan sub-interfaces. Endpoints are veth pairs attached to vpp
+// as af_packet interfaces, and policy rules are acls of the vpp acl plugin.
 type VppDriver struct {
-	oper    VppDriverOperState // Oper state of the driver
-	localIP string             // Local IP address
-	lock    sync.Mutex         // lock for modifying shared state
+	oper      VppDriverOperState      // Oper state of the driver
+	localIP   string                  // Local IP address
+	apiSocket string                  // vpp api socket, the default one when empty
+	uplinkMtu int                     // lowest mtu of the host interfaces
+	api       *vppapi.Client          // connection to vpp
+	members   map[string]*groupMember // group members by endpoint ID, nil until read
+	lock      sync.Mutex              // lock for modifying shared state
+}
+
+// veth pair operations, the tests replace them to run without netlink
+var (
+	createVethPair = func(name1, name2 string, mtu int) error {
+		log.Infof("Creating Veth pairs with name: %s, %s", name1, name2)
+		veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: name1, MTU: mtu}, PeerName: name2}
+		return netlink.LinkAdd(veth)
+	}
+	deleteVethPair = func(name1, name2 string) error {
+		log.Infof("Deleting Veth pairs with name: %s, %s", name1, name2)
+		veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: name1}, PeerName: name2}
+		return netlink.LinkDel(veth)
+	}
+	setLinkUp = func(name string) error {
+		iface, err := netlink.LinkByName(name)
+		if err != nil {
+			return err
+		}
+		return netlink.LinkSetUp(iface)
+	}
+	linkExists = func(name string) bool {
+		_, err := netlink.LinkByName(name)
+		return err == nil || !strings.Contains(err.Error(), "not found")
+	}
+)
+
+// getIntfName returns the names of the next free veth pair
+func (d *VppDriver) getIntfName() (string, string, error) {
+	for i := 0; i < maxIntfRetry; i++ {
+		d.oper.CurrPortNum++
+		if d.oper.CurrPortNum >= maxPortNum {
+			d.oper.CurrPortNum = 1 // roll over
+		}
+		intfName := fmt.Sprintf("vport%d", d.oper.CurrPortNum)
+		portName := "v" + intfName
+
+		if !linkExists(intfName) && !linkExists(portName) {
+			return intfName, portName, d.oper.Write()
+		}
+	}
+
+	return "", "", core.Errorf("Could not get intf name. Max retry exceeded")
 }
 
-// Init is not implemented.
+// Init connects to vpp, and restores the driver's state
 func (d *VppDriver) Init(info *core.InstanceInfo) error {
-	log.Infof("Initializing vppdriver")
+	if info == nil || info.StateDriver == nil {
+		return core.Errorf("Invalid arguments. instance-info: %+v", info)
+	}
 
-	return nil
-}
+	log.Infof("Initializing vppdriver")
 
-// Deinit is not implemented.
+	d.oper.StateDriver = info.StateDriver
+	d.localIP = info.VtepIP
+
+	uplinkMtu, err := netutils.GetHostLowestLinkMtu()
+	if err != nil {
+		log.Errorf("Failed to get the host mtu. Err: %v", err)
+		return err
+	}
+	d.uplinkMtu = uplinkMtu
+
+	err = d.oper.Read(info.HostLabel)
+	if core.ErrIfKeyExists(err) != nil {
+		log.Errorf("Failed to read driver oper state for key %q. Error: %s",
+			info.HostLabel, err)
+		return err
+	} else if err != nil {
+		// create the oper state as it is first time start up
+		d.oper.ID = info.HostLabel
+	}
+	if d.oper.Networks == nil {
+		d.oper.Networks = make(map[string]*VppNetwork)
+		d.oper.Endpoints = make(map[string]*VppEndpoint)
+		d.oper.PolicyRules = make(map[string]*VppPolicyRule)
+		d.oper.DefaultACLIndex = vppapi.InvalidIndex
+	}
+
+	if info.VxlanUDPPort != 0 && info.VxlanUDPPort != defaultVxlanPort {
+		log.Warnf("vpp only supports vxlan port %d, ignoring port %d",
+			defaultVxlanPort, info.VxlanUDPPort)
+	}
+
+	socket := d.apiSocket
+	if socket == "" {
+		socket = vppapi.DefaultSocket
+	}
+	d.api, err = vppapi.Connect(socket)
+	if err != nil {
+		return err
+	}
+
+	// attach the uplink, vlan networks are sub-interfaces of it
+	if len(info.UplinkIntf) != 0 && d.oper.UplinkSwIfIndex == 0 {
+		if len(info.UplinkIntf) > 1 {
+			log.Warnf("vpp driver only uses the first uplink %s", info.UplinkIntf[0])
+		}
+		d.oper.UplinkSwIfIndex, err = d.addHostInterface(info.UplinkIntf[0])
+		if err != nil {
+			log.Errorf("Could not add uplink %s to vpp. Err: %v", info.UplinkIntf[0], err)
+			return err
+		}
+	}
+
+	if d.oper.DefaultACLIndex == vppapi.InvalidIndex {
+		reply := &vppapi.ACLAddReplaceReply{}
+		err = d.api.Call(&vppapi.ACLAddReplace{
+			ACLIndex: vppapi.InvalidIndex,
+			Tag:      defaultACLTag,
+			Rules:    permitAllRules(),
+		}, reply)
+		if err != nil {
+			return err
+		}
+		d.oper.DefaultACLIndex = reply.ACLIndex
+	}
+
+	return d.oper.Write()
+}
+
+// Deinit closes the connection to vpp. The configuration is left in vpp,
+// for the driver to find it when it restarts.
 func (d *VppDriver) Deinit() {
 	log.Infof("Cleaning up vppdriver")
-}
 
-// CreateNetwork is not implemented.
-// We get the Tenant/vrf and network/subnet info from contiv in this API
-func (d *VppDriver) CreateNetwork(id string) error {
-	log.Infof("Not implemented")
+	if d.api != nil {
+		d.api.Close()
+		d.api = nil
+	}
+}
+
+// addHostInterface attaches a linux interface to vpp and sets it up
+func (d *VppDriver) addHostInterface(name string) (uint32, error) {
+	reply := &vppapi.AfPacketCreateReply{}
+	err := d.api.Call(&vppapi.AfPacketCreate{HostIfName: name, UseRandomHwAddr: true}, reply)
+	if err != nil {
+		return 0, err
+	}
+
+	err = d.api.Call(&vppapi.SwInterfaceSetFlags{SwIfIndex: reply.SwIfIndex, AdminUpDown: true},
+		&vppapi.SwInterfaceSetFlagsReply{})
+	if err != nil {
+		d.api.Call(&vppapi.AfPacketDelete{HostIfName: name}, &vppapi.AfPacketDeleteReply{})
+		return 0, err
+	}
+
+	return reply.SwIfIndex, nil
+}
+
+// addToBridgeDomain adds an interface to a bridge domain. Interfaces in
+// the same split horizon group don't flood to each other.
+func (d *VppDriver) addToBridgeDomain(swIfIndex, bdID uint32, shg uint8) error {
+	return d.api.Call(&vppapi.SwInterfaceSetL2Bridge{
+		RxSwIfIndex: swIfIndex,
+		BdID:        bdID,
+		Shg:         shg,
+		Enable:      true,
+	}, &vppapi.SwInterfaceSetL2BridgeReply{})
+}
+
+// vxlanTunnel returns the request that adds or deletes the tunnel of a
+// network to a peer
+func (d *VppDriver) vxlanTunnel(nw *VppNetwork, peer string, isAdd bool) (*vppapi.VxlanAddDelTunnel, error) {
+	src := net.ParseIP(d.localIP).To4()
+	dst := net.ParseIP(peer).To4()
+	if src == nil || dst == nil {
+		return nil, core.Errorf("Invalid vxlan tunnel endpoints %q, %q", d.localIP, peer)
+	}
+
+	return &vppapi.VxlanAddDelTunnel{
+		IsAdd:          isAdd,
+		SrcAddress:     src,
+		DstAddress:     dst,
+		McastSwIfIndex: vppapi.InvalidIndex,
+		DecapNextIndex: vppapi.VxlanDecapL2Input,
+		Vni:            uint32(nw.ExtPktTag),
+	}, nil
+}
+
+// addTunnel adds a vxlan tunnel to a peer to the bridge domain of a network
+func (d *VppDriver) addTunnel(nw *VppNetwork, peer string) error {
+	if _, found := nw.Tunnels[peer]; found {
+		return nil
+	}
+
+	req, err := d.vxlanTunnel(nw, peer, true)
+	if err != nil {
+		return err
+	}
+	reply := &vppapi.VxlanAddDelTunnelReply{}
+	if err := d.api.Call(req, reply); err != nil {
+		return err
+	}
+	nw.Tunnels[peer] = reply.SwIfIndex
+
+	return d.addToBridgeDomain(reply.SwIfIndex, nw.BdID, tunnelShg)
+}
+
+// delTunnel deletes the vxlan tunnel of a network to a peer
+func (d *VppDriver) delTunnel(nw *VppNetwork, peer string) error {
+	if _, found := nw.Tunnels[peer]; !found {
+		return nil
+	}
+
+	req, err := d.vxlanTunnel(nw, peer, false)
+	if err != nil {
+		return err
+	}
+	if err := d.api.Call(req, &vppapi.VxlanAddDelTunnelReply{}); err != nil {
+		return err
+	}
+	delete(nw.Tunnels, peer)
+
 	return nil
 }
 
-// DeleteNetwork is not implemented.
-func (d *VppDriver) DeleteNetwork(id, subnet, nwType, encap string, pktTag, extPktTag int, gateway string, tenant string) error {
-	log.Infof("Not implemented")
+// CreateNetwork creates the bridge domain of a network, and connects it to
+// the other hosts or to the uplink
+func (d *VppDriver) CreateNetwork(id string) error {
+	cfgNw := mastercfg.CfgNetworkState{}
+	cfgNw.StateDriver = d.oper.StateDriver
+	err := cfgNw.Read(id)
+	if err != nil {
+		log.Errorf("Failed to read net %s \n", cfgNw.ID)
+		return err
+	}
+	log.Infof("create net %+v \n", cfgNw)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, found := d.oper.Networks[id]; found {
+		log.Infof("Network %s already exists in vpp", id)
+		return nil
+	}
+
+	if cfgNw.PktTagType != "vxlan" && cfgNw.PktTagType != "vlan" {
+		return core.Errorf("vpp driver doesn't support %s networks", cfgNw.PktTagType)
+	}
+	if cfgNw.PktTagType == "vlan" && d.oper.UplinkSwIfIndex == 0 {
+		return core.Errorf("vlan network %s needs an uplink", id)
+	}
+
+	d.oper.CurrBdID++
+	nw := &VppNetwork{
+		BdID:       d.oper.CurrBdID,
+		PktTagType: cfgNw.PktTagType,
+		PktTag:     cfgNw.PktTag,
+		ExtPktTag:  cfgNw.ExtPktTag,
+		Tunnels:    make(map[string]uint32),
+		Mtu:        netutils.GetNetworkMtu(cfgNw.Mtu, d.uplinkMtu, cfgNw.PktTagType),
+	}
+	err = d.api.Call(&vppapi.BridgeDomainAddDel{
+		BdID:    nw.BdID,
+		Flood:   true,
+		UuFlood: true,
+		Forward: true,
+		Learn:   true,
+		BdTag:   id,
+		IsAdd:   true,
+	}, &vppapi.BridgeDomainAddDelReply{})
+	if err != nil {
+		log.Errorf("Error creating bridge domain for net %s. Err: %v", id, err)
+		return err
+	}
+
+	// the network is saved before connecting it, for DeleteNetwork to undo
+	// what was done on failures
+	d.oper.Networks[id] = nw
+	err = d.connectNetwork(id, nw)
+	if werr := d.oper.Write(); err == nil {
+		err = werr
+	}
+
+	return err
+}
+
+// connectNetwork connects the bridge domain of a vxlan network to the other
+// hosts, and the one of a vlan network to the uplink
+func (d *VppDriver) connectNetwork(id string, nw *VppNetwork) error {
+	if nw.PktTagType == "vxlan" {
+		for _, peer := range d.oper.Peers {
+			if err := d.addTunnel(nw, peer); err != nil {
+				log.Errorf("Error adding vxlan tunnel to %s for net %s. Err: %v", peer, id, err)
+				return err
+			}
+		}
+		return nil
+	}
+
+	reply := &vppapi.CreateVlanSubifReply{}
+	err := d.api.Call(&vppapi.CreateVlanSubif{
+		SwIfIndex: d.oper.UplinkSwIfIndex,
+		VlanID:    uint32(nw.PktTag),
+	}, reply)
+	if err != nil {
+		return err
+	}
+	nw.SubIf = reply.SwIfIndex
+	nw.SubIfValid = true
+
+	// strip the tag of the packets coming in, vpp pushes it back on the way out
+	err = d.api.Call(&vppapi.L2InterfaceVlanTagRewrite{SwIfIndex: nw.SubIf, VtrOp: vppapi.VtrPop1},
+		&vppapi.L2InterfaceVlanTagRewriteReply{})
+	if err != nil {
+		return err
+	}
+	err = d.api.Call(&vppapi.SwInterfaceSetFlags{SwIfIndex: nw.SubIf, AdminUpDown: true},
+		&vppapi.SwInterfaceSetFlagsReply{})
+	if err != nil {
+		return err
+	}
+
+	return d.addToBridgeDomain(nw.SubIf, nw.BdID, 0)
+}
+
+// UpdateNetwork is a noop, the vpp driver doesn't program the subnet and
+// gateway of networks
+func (d *VppDriver) UpdateNetwork(id, prevGateway, prevIPv6Gateway string) error {
 	return nil
 }
 
-// CreateEndpoint is not implemented.
+// DeleteNetwork deletes the bridge domain of a network and its tunnels
+func (d *VppDriver) DeleteNetwork(id, subnet, nwType, encap string, pktTag, extPktTag int, gateway string, tenant string) error {
+	log.Infof("delete net %s, nwType %s, encap %s, tags: %d/%d", id, nwType, encap, pktTag, extPktTag)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	nw, found := d.oper.Networks[id]
+	if !found {
+		log.Infof("Network %s not found in vpp", id)
+		return nil
+	}
+
+	for peer := range nw.Tunnels {
+		if err := d.delTunnel(nw, peer); err != nil {
+			log.Errorf("Error deleting vxlan tunnel to %s for net %s. Err: %v", peer, id, err)
+		}
+	}
+	if nw.SubIfValid {
+		err := d.api.Call(&vppapi.DeleteSubif{SwIfIndex: nw.SubIf}, &vppapi.DeleteSubifReply{})
+		if err != nil {
+			log.Errorf("Error deleting vlan sub-interface for net %s. Err: %v", id, err)
+		}
+	}
+
+	err := d.api.Call(&vppapi.BridgeDomainAddDel{BdID: nw.BdID, IsAdd: false},
+		&vppapi.BridgeDomainAddDelReply{})
+	if err != nil {
+		log.Errorf("Error deleting bridge domain for net %s. Err: %v", id, err)
+		return err
+	}
+
+	delete(d.oper.Networks, id)
+	return d.oper.Write()
+}
+
+// CreateEndpoint creates a veth pair for an endpoint, and attaches it to
+// the bridge domain of its network
 func (d *VppDriver) CreateEndpoint(id string) error {
-	log.Infof("Not implemented")
+	cfgEp := &mastercfg.CfgEndpointState{}
+	cfgEp.StateDriver = d.oper.StateDriver
+	err := cfgEp.Read(id)
+	if err != nil {
+		return err
+	}
+
+	operEp := &drivers.OperEndpointState{}
+	operEp.StateDriver = d.oper.StateDriver
+	err = operEp.Read(id)
+	if core.ErrIfKeyExists(err) != nil {
+		return err
+	} else if err == nil {
+		// check if oper state matches cfg state. In case of mismatch cleanup
+		// up the EP and continue add new one. In case of match just return.
+		if operEp.Matches(cfgEp) {
+			log.Printf("Found matching oper state for ep %s, noop", id)
+			return nil
+		}
+		log.Printf("Found mismatching oper state for Ep, cleaning it. Config: %+v, Oper: %+v",
+			cfgEp, operEp)
+		d.DeleteEndpoint(operEp.ID)
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	nw, found := d.oper.Networks[cfgEp.NetID]
+	if !found {
+		return core.Errorf("Network %s of endpoint %s not found in vpp", cfgEp.NetID, id)
+	}
+
+	intfName, portName, err := d.getIntfName()
+	if err != nil {
+		return err
+	}
+	if err = createVethPair(intfName, portName, nw.Mtu); err != nil {
+		log.Errorf("Error creating veth pair %s. Err: %v", intfName, err)
+		return err
+	}
+	defer func() {
+		if err != nil {
+			deleteVethPair(intfName, portName)
+		}
+	}()
+	if err = setLinkUp(portName); err != nil {
+		return err
+	}
+
+	swIfIndex, err := d.addHostInterface(portName)
+	if err != nil {
+		return err
+	}
+	if err = d.addToBridgeDomain(swIfIndex, nw.BdID, 0); err != nil {
+		d.api.Call(&vppapi.AfPacketDelete{HostIfName: portName}, &vppapi.AfPacketDeleteReply{})
+		return err
+	}
+
+	d.oper.Endpoints[id] = &VppEndpoint{
+		NetID:           cfgEp.NetID,
+		IntfName:        intfName,
+		PortName:        portName,
+		SwIfIndex:       swIfIndex,
+		EndpointGroupID: cfgEp.EndpointGroupID,
+		IPAddress:       cfgEp.IPAddress,
+	}
+	if err = d.oper.Write(); err != nil {
+		return err
+	}
+
+	// Save the oper state
+	operEp = &drivers.OperEndpointState{
+		NetID:       cfgEp.NetID,
+		EndpointID:  cfgEp.EndpointID,
+		ServiceName: cfgEp.ServiceName,
+		IPAddress:   cfgEp.IPAddress,
+		IPv6Address: cfgEp.IPv6Address,
+		MacAddress:  cfgEp.MacAddress,
+		IntfName:    cfgEp.IntfName,
+		PortName:    intfName,
+		HomingHost:  cfgEp.HomingHost,
+		VtepIP:      cfgEp.VtepIP,
+		Mtu:         nw.Mtu}
+	operEp.StateDriver = d.oper.StateDriver
+	operEp.ID = id
+	if err = operEp.Write(); err != nil {
+		return err
+	}
+
+	// the endpoint joins the acls of its group, and its address the acls
+	// of the rules that refer to its group
+	d.setGroupMember(cfgEp)
+	if err := d.syncACLs(); err != nil {
+		log.Errorf("Error updating acls for endpoint %s. Err: %v", id, err)
+	}
+
 	return nil
 }
 
-//UpdateEndpointGroup is not implemented.
+// UpdateEndpointGroup is a noop, the vpp driver doesn't support bandwidth
+// limits yet
 func (d *VppDriver) UpdateEndpointGroup(id string) error {
-	log.Infof("Not implemented")
+	log.Infof("Received endpoint group update for %s", id)
 	return nil
 }
 
-// DeleteEndpoint is not implemented.
-func (d *VppDriver) DeleteEndpoint(id string) (err error) {
-	log.Infof("Not implemented")
+// DeleteEndpoint detaches an endpoint from vpp and deletes its veth pair
+func (d *VppDriver) DeleteEndpoint(id string) error {
+	epOper := drivers.OperEndpointState{}
+	epOper.StateDriver = d.oper.StateDriver
+	err := epOper.Read(id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		epOper.Clear()
+	}()
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	ep, found := d.oper.Endpoints[id]
+	if !found {
+		log.Infof("Endpoint %s not found in vpp", id)
+		return nil
+	}
+
+	// vpp drops the acl list of an interface with it
+	err = d.api.Call(&vppapi.AfPacketDelete{HostIfName: ep.PortName}, &vppapi.AfPacketDeleteReply{})
+	if err != nil {
+		log.Errorf("Error deleting vpp interface of endpoint %s. Err: %v", id, err)
+	}
+	if err = deleteVethPair(ep.IntfName, ep.PortName); err != nil {
+		log.Errorf("Error deleting veth pair %s. Err: %v", ep.IntfName, err)
+	}
+
+	delete(d.oper.Endpoints, id)
+	if err = d.oper.Write(); err != nil {
+		return err
+	}
+
+	d.delGroupMember(id)
+	if err := d.syncACLs(); err != nil {
+		log.Errorf("Error updating acls for endpoint %s. Err: %v", id, err)
+	}
+
 	return nil
 }
 
-// CreateRemoteEndpoint is not implemented.
+// CreateRemoteEndpoint updates the acls with the address of a remote
+// endpoint. vpp learns the mac address from the tunnels.
 func (d *VppDriver) CreateRemoteEndpoint(id string) error {
-	log.Infof("Not implemented")
-	return nil
+	cfgEp := &mastercfg.CfgEndpointState{}
+	cfgEp.StateDriver = d.oper.StateDriver
+	if err := cfgEp.Read(id); err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.setGroupMember(cfgEp)
+	return d.syncACLs()
 }
 
-// DeleteRemoteEndpoint is not implemented.
-func (d *VppDriver) DeleteRemoteEndpoint(id string) (err error) {
-	log.Infof("Not implemented")
-	return nil
+// DeleteRemoteEndpoint removes the address of a remote endpoint from the
+// acls
+func (d *VppDriver) DeleteRemoteEndpoint(id string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.delGroupMember(id)
+	return d.syncACLs()
 }
 
-// CreateHostAccPort is not implemented.
+// CreateHostAccPort is not supported by the vpp driver
 func (d *VppDriver) CreateHostAccPort(id, a string, nw int) (string, error) {
-	log.Infof("Not implemented")
-	return "", nil
+	return "", core.Errorf("vpp driver doesn't support host access ports")
 }
 
-// DeleteHostAccPort is not implemented.
+// DeleteHostAccPort is not supported by the vpp driver
 func (d *VppDriver) DeleteHostAccPort(id string) (err error) {
-	log.Infof("Not implemented")
-	return nil
+	return core.Errorf("vpp driver doesn't support host access ports")
 }
 
-// AddPeerHost is not implemented.
+// AddPeerHost adds vxlan tunnels to a new host to all the vxlan networks
 func (d *VppDriver) AddPeerHost(node core.ServiceInfo) error {
-	log.Infof("Not implemented")
-	return nil
+	// Nothing to do if this is our own IP
+	if node.HostAddr == d.localIP {
+		return nil
+	}
+
+	log.Infof("CreatePeerHost for %+v", node)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, peer := range d.oper.Peers {
+		if peer == node.HostAddr {
+			return nil
+		}
+	}
+	d.oper.Peers = append(d.oper.Peers, node.HostAddr)
+
+	for id, nw := range d.oper.Networks {
+		if nw.PktTagType != "vxlan" {
+			continue
+		}
+		if err := d.addTunnel(nw, node.HostAddr); err != nil {
+			log.Errorf("Error adding vxlan tunnel to %s for net %s. Err: %v", node.HostAddr, id, err)
+		}
+	}
+
+	return d.oper.Write()
 }
 
-// DeletePeerHost is not implemented.
+// DeletePeerHost deletes the vxlan tunnels to a host
 func (d *VppDriver) DeletePeerHost(node core.ServiceInfo) error {
-	log.Infof("Not implemented")
-	return nil
+	// Nothing to do if this is our own IP
+	if node.HostAddr == d.localIP {
+		return nil
+	}
+
+	log.Infof("DeletePeerHost for %+v", node)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for i, peer := range d.oper.Peers {
+		if peer == node.HostAddr {
+			d.oper.Peers = append(d.oper.Peers[:i], d.oper.Peers[i+1:]...)
+			break
+		}
+	}
+
+	for id, nw := range d.oper.Networks {
+		if err := d.delTunnel(nw, node.HostAddr); err != nil {
+			log.Errorf("Error deleting vxlan tunnel to %s for net %s. Err: %v", node.HostAddr, id, err)
+		}
+	}
+
+	return d.oper.Write()
 }
 
 // AddMaster is not implemented
@@ -181,16 +763,65 @@ func (d *VppDriver) DelSvcSpec(svcName string, spec *core.ServiceSpec) error {
 func (d *VppDriver) SvcProviderUpdate(svcName string, providers []string) {
 }
 
-// GetEndpointStats is not implemented
+// VppEndpointStats are the counters of the vpp interface of an endpoint.
+// Packets the endpoint sends are rx packets of vpp.
+type VppEndpointStats struct {
+	EndpointID string                   `json:"endpointID"`
+	NetID      string                   `json:"netID"`
+	IPAddress  string                   `json:"ipAddress"`
+	PortName   string                   `json:"portName"`
+	Counters   vppapi.InterfaceCounters `json:"counters"`
+}
+
+// GetEndpointStats returns the interface counters of the local endpoints
 func (d *VppDriver) GetEndpointStats() ([]byte, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	epStats := make(map[string]*VppEndpointStats)
+	for id, ep := range d.oper.Endpoints {
+		counters, err := d.api.GetInterfaceCounters(hostIntfPrefix + ep.PortName)
+		if err != nil {
+			log.Errorf("Error getting stats of endpoint %s. Err: %v", id, err)
+			return []byte{}, err
+		}
+
+		epStats[id] = &VppEndpointStats{
+			EndpointID: id,
+			NetID:      ep.NetID,
+			IPAddress:  ep.IPAddress,
+			PortName:   ep.PortName,
+			Counters:   *counters,
+		}
+	}
+
+	jsonStats, err := json.Marshal(epStats)
+	if err != nil {
+		log.Errorf("Error encoding epstats. Err: %v", err)
+		return jsonStats, err
+	}
+
+	return jsonStats, nil
+}
+
+// GetPolicyRuleStats is not implemented
+func (d *VppDriver) GetPolicyRuleStats() ([]byte, error) {
 	log.Infof("Not implemented")
 	return []byte{}, nil
 }
 
-// InspectState is not implemented
+// InspectState returns the networks, endpoints and acls programmed in vpp
 func (d *VppDriver) InspectState() ([]byte, error) {
-	log.Infof("Not implemented")
-	return []byte{}, nil
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	jsonState, err := json.Marshal(&d.oper)
+	if err != nil {
+		log.Errorf("Error encoding vpp state. Err: %v", err)
+		return []byte{}, err
+	}
+
+	return jsonState, nil
 }
 
 // InspectBgp is not implemented
@@ -211,14 +842,36 @@ func (d *VppDriver) InspectNameserver() ([]byte, error) {
 	return []byte{}, nil
 }
 
-// AddPolicyRule is not implemented
-func (d *VppDriver) AddPolicyRule(id string) error {
-	log.Infof("Not implemented")
+// UpdateEncryption fails when the tunnels are to be encrypted, the vpp
+// driver doesn't support tunnel encryption
+func (d *VppDriver) UpdateEncryption(id string) error {
+	encCfg := mastercfg.CfgEncryptionState{}
+	encCfg.StateDriver = d.oper.StateDriver
+	if err := encCfg.Read(id); err == nil && encCfg.Enabled() {
+		return core.Errorf("tunnel encryption is not supported by the vpp driver")
+	}
+
 	return nil
 }
 
-// DelPolicyRule is not implemented
-func (d *VppDriver) DelPolicyRule(id string) error {
+// GetEncryptionStatus returns no tunnel, the vpp driver doesn't support
+// tunnel encryption
+func (d *VppDriver) GetEncryptionStatus() ([]byte, error) {
+	return json.Marshal([]mastercfg.EncryptionPeerStatus{})
+}
+
+// Reconcile is not implemented
+func (d *VppDriver) Reconcile() error {
 	log.Infof("Not implemented")
 	return nil
 }
+
+// AddMirrorSession fails, the vpp driver doesn't support traffic mirroring
+func (d *VppDriver) AddMirrorSession(id string) error {
+	return core.Errorf("traffic mirroring is not supported by the vpp driver")
+}
+
+// DelMirrorSession is a no-op, the vpp driver never programs mirror sessions
+func (d *VppDriver) DelMirrorSession(id string) error {
+	return nil
+}
@@ -0,0 +1,351 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vppd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/drivers"
+	"github.com/contiv/netplugin/drivers/vppd/vppapi"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/state"
+	"github.com/contiv/ofnet"
+)
+
+const (
+	testHostLabel = "testHost"
+	testVtepIP    = "10.0.0.1"
+	testPeerIP    = "10.0.0.2"
+	testNetID     = "net1.default"
+	testVlanNetID = "net2.default"
+	testEpID      = "net1.default-ep1"
+	testRemoteID  = "net1.default-ep2"
+	testGroupID   = 10
+)
+
+// testLinks replaces the veth pair operations, and records the links
+var testLinks = make(map[string]bool)
+
+func init() {
+	createVethPair = func(name1, name2 string, mtu int) error {
+		testLinks[name1] = true
+		testLinks[name2] = true
+		return nil
+	}
+	deleteVethPair = func(name1, name2 string) error {
+		delete(testLinks, name1)
+		delete(testLinks, name2)
+		return nil
+	}
+	setLinkUp = func(name string) error { return nil }
+	linkExists = func(name string) bool { return testLinks[name] }
+}
+
+func initVppDriver(t *testing.T) (*VppDriver, *vppapi.FakeServer, func()) {
+	dir, err := ioutil.TempDir("", "vppd")
+	if err != nil {
+		t.Fatalf("Error creating temp dir. Err: %v", err)
+	}
+	socket := filepath.Join(dir, "api.sock")
+	server, err := vppapi.NewFakeServer(socket)
+	if err != nil {
+		t.Fatalf("Error starting fake vpp. Err: %v", err)
+	}
+	server.AddInterface("host-eth2")
+
+	stateDriver := &state.FakeStateDriver{}
+	stateDriver.Init(nil)
+	if err := createCommonState(stateDriver); err != nil {
+		t.Fatalf("common state creation failed. Error: %s", err)
+	}
+
+	driver := &VppDriver{apiSocket: socket}
+	err = driver.Init(&core.InstanceInfo{HostLabel: testHostLabel, VtepIP: testVtepIP,
+		UplinkIntf: []string{"eth1"}, StateDriver: stateDriver})
+	if err != nil {
+		t.Fatalf("driver init failed. Error: %s", err)
+	}
+
+	return driver, server, func() {
+		driver.Deinit()
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func createCommonState(stateDriver core.StateDriver) error {
+	for _, nw := range []*mastercfg.CfgNetworkState{
+		{Tenant: "default", NetworkName: "net1", PktTagType: "vxlan", PktTag: 1, ExtPktTag: 10001},
+		{Tenant: "default", NetworkName: "net2", PktTagType: "vlan", PktTag: 100},
+	} {
+		nw.StateDriver = stateDriver
+		nw.ID = nw.NetworkName + "." + nw.Tenant
+		if err := nw.Write(); err != nil {
+			return err
+		}
+	}
+
+	for _, ep := range []*mastercfg.CfgEndpointState{
+		{NetID: testNetID, EndpointID: "ep1", IPAddress: "20.1.1.1", HomingHost: testHostLabel,
+			EndpointGroupID: testGroupID},
+		{NetID: testNetID, EndpointID: "ep2", IPAddress: "20.1.1.2", HomingHost: "otherHost",
+			VtepIP: testPeerIP, EndpointGroupID: testGroupID + 1},
+	} {
+		ep.StateDriver = stateDriver
+		ep.ID = ep.NetID + "-" + ep.EndpointID
+		if err := ep.Write(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestVppDriverNetwork(t *testing.T) {
+	driver, server, cleanup := initVppDriver(t)
+	defer cleanup()
+
+	if err := driver.AddPeerHost(core.ServiceInfo{HostAddr: testPeerIP}); err != nil {
+		t.Fatalf("Error adding peer. Err: %v", err)
+	}
+	if err := driver.CreateNetwork(testNetID); err != nil {
+		t.Fatalf("Error creating vxlan network. Err: %v", err)
+	}
+	if err := driver.CreateNetwork(testVlanNetID); err != nil {
+		t.Fatalf("Error creating vlan network. Err: %v", err)
+	}
+
+	nw := driver.oper.Networks[testNetID]
+	if server.BridgeDomains()[nw.BdID] != testNetID {
+		t.Fatalf("Bridge domain of %s not found: %+v", testNetID, server.BridgeDomains())
+	}
+	tunnel := server.Interfaces()[nw.Tunnels[testPeerIP]]
+	if tunnel.Tunnel == nil || tunnel.Tunnel.Vni != 10001 || tunnel.BdID != nw.BdID || tunnel.Shg != tunnelShg ||
+		!net.IP(tunnel.Tunnel.DstAddress).Equal(net.ParseIP(testPeerIP)) {
+		t.Fatalf("Unexpected vxlan tunnel %+v", tunnel)
+	}
+
+	vlanNw := driver.oper.Networks[testVlanNetID]
+	subIf, found := server.Interface("host-eth1.100")
+	if !found || subIf.BdID != vlanNw.BdID || subIf.VtrOp != vppapi.VtrPop1 || !subIf.AdminUp {
+		t.Fatalf("Unexpected vlan sub-interface %+v", subIf)
+	}
+
+	if err := driver.DeletePeerHost(core.ServiceInfo{HostAddr: testPeerIP}); err != nil {
+		t.Fatalf("Error deleting peer. Err: %v", err)
+	}
+	if len(nw.Tunnels) != 0 {
+		t.Fatalf("Tunnels were not deleted: %+v", nw.Tunnels)
+	}
+
+	if err := driver.DeleteNetwork(testNetID, "", "", "vxlan", 1, 10001, "", "default"); err != nil {
+		t.Fatalf("Error deleting network. Err: %v", err)
+	}
+	if err := driver.DeleteNetwork(testVlanNetID, "", "", "vlan", 100, 0, "", "default"); err != nil {
+		t.Fatalf("Error deleting network. Err: %v", err)
+	}
+	if len(server.BridgeDomains()) != 0 {
+		t.Fatalf("Bridge domains were not deleted: %+v", server.BridgeDomains())
+	}
+	if _, found := server.Interface("host-eth1.100"); found {
+		t.Fatalf("vlan sub-interface was not deleted")
+	}
+}
+
+func TestVppDriverEndpoint(t *testing.T) {
+	driver, server, cleanup := initVppDriver(t)
+	defer cleanup()
+
+	if err := driver.CreateNetwork(testNetID); err != nil {
+		t.Fatalf("Error creating network. Err: %v", err)
+	}
+	if err := driver.CreateEndpoint(testEpID); err != nil {
+		t.Fatalf("Error creating endpoint. Err: %v", err)
+	}
+
+	operEp := &drivers.OperEndpointState{}
+	operEp.StateDriver = driver.oper.StateDriver
+	if err := operEp.Read(testEpID); err != nil || !testLinks[operEp.PortName] {
+		t.Fatalf("Unexpected endpoint oper state %+v. Err: %v", operEp, err)
+	}
+
+	ep := driver.oper.Endpoints[testEpID]
+	intf, found := server.Interface(hostIntfPrefix + ep.PortName)
+	if !found || intf.BdID != driver.oper.Networks[testNetID].BdID || !intf.AdminUp {
+		t.Fatalf("Unexpected endpoint interface %+v", intf)
+	}
+
+	server.SetCounters(intf.Name, vppapi.InterfaceCounters{RxPackets: 3, TxPackets: 4})
+	jsonStats, err := driver.GetEndpointStats()
+	if err != nil {
+		t.Fatalf("Error getting endpoint stats. Err: %v", err)
+	}
+	epStats := make(map[string]*VppEndpointStats)
+	if err := json.Unmarshal(jsonStats, &epStats); err != nil {
+		t.Fatalf("Error decoding endpoint stats. Err: %v", err)
+	}
+	if epStats[testEpID] == nil || epStats[testEpID].Counters.RxPackets != 3 ||
+		epStats[testEpID].Counters.TxPackets != 4 {
+		t.Fatalf("Unexpected endpoint stats %s", jsonStats)
+	}
+
+	if err := driver.DeleteEndpoint(testEpID); err != nil {
+		t.Fatalf("Error deleting endpoint. Err: %v", err)
+	}
+	if _, found := server.Interface(intf.Name); found || testLinks[ep.PortName] {
+		t.Fatalf("Endpoint interface was not deleted")
+	}
+	if err := operEp.Read(testEpID); err == nil {
+		t.Fatalf("Endpoint oper state was not deleted")
+	}
+}
+
+func TestVppDriverPolicyRule(t *testing.T) {
+	driver, server, cleanup := initVppDriver(t)
+	defer cleanup()
+
+	if err := driver.CreateNetwork(testNetID); err != nil {
+		t.Fatalf("Error creating network. Err: %v", err)
+	}
+	if err := driver.CreateEndpoint(testEpID); err != nil {
+		t.Fatalf("Error creating endpoint. Err: %v", err)
+	}
+
+	// deny tcp/80 from the remote endpoint's group to the local one
+	rule := &mastercfg.CfgPolicyRule{OfnetPolicyRule: ofnet.OfnetPolicyRule{
+		RuleId:           "rule1",
+		Priority:         10,
+		SrcEndpointGroup: testGroupID + 1,
+		DstEndpointGroup: testGroupID,
+		IpProtocol:       6,
+		DstPort:          80,
+		Action:           "deny",
+	}}
+	rule.StateDriver = driver.oper.StateDriver
+	if err := rule.Write(); err != nil {
+		t.Fatalf("Error writing policy rule. Err: %v", err)
+	}
+	if err := driver.AddPolicyRule("rule1"); err != nil {
+		t.Fatalf("Error adding policy rule. Err: %v", err)
+	}
+
+	vppRule := driver.oper.PolicyRules["rule1"]
+	aclRules, found := server.ACL(vppRule.ACLIndex)
+	if !found || len(aclRules) != 1 {
+		t.Fatalf("Unexpected acl %+v", aclRules)
+	}
+	aclRule := aclRules[0]
+	if aclRule.IsPermit != 0 || aclRule.Proto != 6 || aclRule.DstPortFirst != 80 ||
+		aclRule.DstPortLast != 80 || aclRule.SrcIPPrefixLen != 32 ||
+		!net.IP(aclRule.SrcIPAddr).Equal(net.ParseIP("20.1.1.2")) ||
+		aclRule.DstIPPrefixLen != 32 || !net.IP(aclRule.DstIPAddr).Equal(net.ParseIP("20.1.1.1")) {
+		t.Fatalf("Unexpected acl rule %+v", aclRule)
+	}
+
+	// a lower priority rule without groups is ordered after it, in both
+	// directions
+	rule2 := &mastercfg.CfgPolicyRule{OfnetPolicyRule: ofnet.OfnetPolicyRule{
+		RuleId:   "rule2",
+		Priority: 5,
+		Action:   "allow",
+	}}
+	rule2.StateDriver = driver.oper.StateDriver
+	if err := rule2.Write(); err != nil {
+		t.Fatalf("Error writing policy rule. Err: %v", err)
+	}
+	if err := driver.AddPolicyRule("rule2"); err != nil {
+		t.Fatalf("Error adding policy rule. Err: %v", err)
+	}
+
+	ep := driver.oper.Endpoints[testEpID]
+	intf, _ := server.Interface(hostIntfPrefix + ep.PortName)
+	expACLs := []uint32{vppRule.ACLIndex, driver.oper.PolicyRules["rule2"].ACLIndex,
+		driver.oper.DefaultACLIndex}
+	if !reflect.DeepEqual(intf.InputACLs, expACLs) || !reflect.DeepEqual(intf.OutputACLs, expACLs) {
+		t.Fatalf("Unexpected acls of the endpoint: %+v", intf)
+	}
+	if err := driver.DelPolicyRule("rule2"); err != nil {
+		t.Fatalf("Error deleting policy rule. Err: %v", err)
+	}
+
+	// the rule matches nothing without members in the source group
+	remoteEp := &mastercfg.CfgEndpointState{}
+	remoteEp.StateDriver = driver.oper.StateDriver
+	remoteEp.ID = testRemoteID
+	remoteEp.Clear()
+	if err := driver.DeleteRemoteEndpoint(testRemoteID); err != nil {
+		t.Fatalf("Error deleting remote endpoint. Err: %v", err)
+	}
+	if intf, _ = server.Interface(intf.Name); len(intf.InputACLs) != 0 || len(intf.OutputACLs) != 0 {
+		t.Fatalf("Acls of an empty group are still applied: %+v", intf)
+	}
+
+	if err := driver.DelPolicyRule("rule1"); err != nil {
+		t.Fatalf("Error deleting policy rule. Err: %v", err)
+	}
+	if _, found := server.ACL(vppRule.ACLIndex); found {
+		t.Fatalf("acl of the policy rule was not deleted")
+	}
+}
+
+func TestPolicyACLRules(t *testing.T) {
+	members := map[int][]string{1: {"20.1.1.1", "20.1.1.2"}, 2: {"20.1.1.3"}}
+
+	aclRules, err := policyACLRules(&ofnet.OfnetPolicyRule{
+		SrcEndpointGroup: 1,
+		DstEndpointGroup: 2,
+		DstIpAddr:        "20.1.0.0/16",
+		IpProtocol:       6,
+		SrcPort:          0x1000,
+		SrcPortMask:      0xff00,
+		TcpFlags:         "syn,!ack",
+		Action:           "allow",
+	}, members)
+	if err != nil || len(aclRules) != 2 {
+		t.Fatalf("Unexpected acl rules %+v. Err: %v", aclRules, err)
+	}
+	for _, aclRule := range aclRules {
+		if aclRule.IsPermit != 1 || aclRule.DstIPPrefixLen != 16 ||
+			aclRule.SrcPortFirst != 0x1000 || aclRule.SrcPortLast != 0x10ff ||
+			aclRule.DstPortFirst != 0 || aclRule.DstPortLast != 0xffff ||
+			aclRule.TCPFlagsValue != tcpFlagSyn || aclRule.TCPFlagsMask != tcpFlagSyn|tcpFlagAck {
+			t.Fatalf("Unexpected acl rule %+v", aclRule)
+		}
+	}
+
+	// the groups are always expanded, the acls are set in both directions
+	aclRules, err = policyACLRules(&ofnet.OfnetPolicyRule{SrcEndpointGroup: 1, Action: "deny"}, members)
+	if err != nil || len(aclRules) != 2 || aclRules[0].SrcIPPrefixLen != 32 ||
+		aclRules[0].DstIPPrefixLen != 0 {
+		t.Fatalf("Unexpected acl rules %+v. Err: %v", aclRules, err)
+	}
+
+	aclRules, err = policyACLRules(&ofnet.OfnetPolicyRule{SrcEndpointGroup: 3, DstEndpointGroup: 2}, members)
+	if err != nil || len(aclRules) != 0 {
+		t.Fatalf("Rule of an empty group matches %+v. Err: %v", aclRules, err)
+	}
+
+	if _, err = policyACLRules(&ofnet.OfnetPolicyRule{IpProtocol: 6, TcpFlags: "fin"}, members); err == nil {
+		t.Fatalf("Rule with unknown tcp flags was converted")
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/contiv/netplugin/netmaster/mastercfg"
 	"github.com/contiv/netplugin/netplugin/cluster"
 	"github.com/contiv/netplugin/utils"
+	"github.com/contiv/netplugin/utils/netutils"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	dockerclient "github.com/docker/docker/client"
@@ -322,6 +323,16 @@ func join(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the join response has no mtu, docker moves the interface into the
+	// container with the mtu of the network set on it
+	if ep.Mtu != 0 {
+		err = netutils.SetInterfaceMtu(ep.PortName, ep.Mtu)
+		if err != nil {
+			httpError(w, "Could not set the interface mtu", err)
+			return
+		}
+	}
+
 	joinResp := api.JoinResponse{
 		InterfaceName: &api.InterfaceName{
 			SrcName:   ep.PortName,
@@ -330,7 +341,7 @@ func join(w http.ResponseWriter, r *http.Request) {
 		Gateway: nw.Gateway,
 	}
 
-	log.Infof("Sending JoinResponse: {%+v}, InterfaceName: %s", joinResp, ep.PortName)
+	log.Infof("Sending JoinResponse: {%+v}, InterfaceName: %s, MTU: %d", joinResp, ep.PortName, ep.Mtu)
 
 	content, err = json.Marshal(joinResp)
 	if err != nil {
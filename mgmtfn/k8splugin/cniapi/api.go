@@ -44,6 +44,7 @@ type RspAddPod struct {
 	EndpointID  string `json:"endpointid,omitempty"`
 	IPAddress   string `json:"ipaddress,omitempty"`
 	IPv6Address string `json:"ipv6address,omitempty"`
+	Mtu         int    `json:"mtu,omitempty"`
 	ErrMsg      string `json:"errmsg,omitempty"`
 	ErrInfo     string `json:"errinfo,omitempty"`
 }
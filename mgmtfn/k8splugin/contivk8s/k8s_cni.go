@@ -27,6 +27,7 @@ import (
 	"github.com/contiv/netplugin/mgmtfn/k8splugin/contivk8s/clients"
 	"github.com/contiv/netplugin/version"
 
+	"github.com/vishvananda/netlink"
 	"github.com/vishvananda/netns"
 	logger "github.com/Sirupsen/logrus"
 	ip "github.com/containernetworking/cni/pkg/types"
@@ -79,6 +80,49 @@ func getPodInfo(ppInfo *cniapi.CNIPodAttr) error {
 	return nil
 }
 
+// setPodMtu sets the mtu of the pod interface, in the pod network namespace
+func setPodMtu(pInfo *cniapi.CNIPodAttr, mtu int) error {
+	nsHandle, err := netns.GetFromPath(pInfo.NwNameSpace)
+	if err != nil {
+		return fmt.Errorf("error getting ns %s: %s", pInfo.NwNameSpace, err)
+	}
+	defer nsHandle.Close()
+
+	nlHandle, err := netlink.NewHandleAt(nsHandle)
+	if err != nil {
+		return fmt.Errorf("error opening netlink in ns %s: %s", pInfo.NwNameSpace, err)
+	}
+	defer nlHandle.Delete()
+
+	link, err := nlHandle.LinkByName(pInfo.IntfName)
+	if err != nil {
+		return fmt.Errorf("error finding interface %s: %s", pInfo.IntfName, err)
+	}
+	if link.Attrs().MTU == mtu {
+		return nil
+	}
+
+	return nlHandle.LinkSetMTU(link, mtu)
+}
+
+// writeCNIError writes a CNI error to stdout, and exits
+func writeCNIError(code uint, msg, details string) {
+	cerr := CNIError{}
+	cerr.CNIVersion = "0.3.1"
+	cerr.Code = code
+	cerr.Msg = msg
+	cerr.Details = details
+
+	eOut, err := json.Marshal(&cerr)
+	if err == nil {
+		log.Infof("cniErr: %s", eOut)
+		fmt.Printf("%s", eOut)
+	} else {
+		log.Errorf("JSON error: %v", err)
+	}
+	os.Exit(1)
+}
+
 func addPodToContiv(nc *clients.NWClient, pInfo *cniapi.CNIPodAttr) {
 
 	// Add to contiv network
@@ -86,29 +130,21 @@ func addPodToContiv(nc *clients.NWClient, pInfo *cniapi.CNIPodAttr) {
 	if err != nil || result.Result != 0 {
 		log.Errorf("EP create failed for pod: %s/%s",
 			pInfo.K8sNameSpace, pInfo.Name)
-		cerr := CNIError{}
-		cerr.CNIVersion = "0.3.1"
-
 		if result != nil {
-			cerr.Code = result.Result
-			cerr.Msg = "Contiv:" + result.ErrMsg
-			cerr.Details = result.ErrInfo
-		} else {
-			cerr.Code = 1
-			cerr.Msg = "Contiv:" + err.Error()
+			writeCNIError(result.Result, "Contiv:"+result.ErrMsg, result.ErrInfo)
 		}
+		writeCNIError(1, "Contiv:"+err.Error(), "")
+	}
 
-		eOut, err := json.Marshal(&cerr)
-		if err == nil {
-			log.Infof("cniErr: %s", eOut)
-			fmt.Printf("%s", eOut)
-		} else {
-			log.Errorf("JSON error: %v", err)
+	log.Infof("EP created IP: %s MTU: %d\n", result.IPAddress, result.Mtu)
+
+	// The network mtu leaves room for the encap
+	if result.Mtu != 0 {
+		if err := setPodMtu(pInfo, result.Mtu); err != nil {
+			log.Errorf("Failed to set mtu %d of pod %s/%s: %v", result.Mtu, pInfo.K8sNameSpace, pInfo.Name, err)
+			writeCNIError(1, "Contiv:failed to set the interface mtu", err.Error())
 		}
-		os.Exit(1)
 	}
-
-	log.Infof("EP created IP: %s\n", result.IPAddress)
 	// Write the ip address of the created endpoint to stdout
 
 	// ParseCIDR returns a reference to IPNet
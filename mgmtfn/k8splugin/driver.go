@@ -51,6 +51,7 @@ type epAttr struct {
 	Gateway     string
 	IPv6Address string
 	IPv6Gateway string
+	Mtu         int
 }
 
 // epCleanUp deletes the ep from netplugin and netmaster
@@ -144,6 +145,7 @@ func createEP(req *epSpec) (*epAttr, error) {
 	epResponse.PortName = ep.PortName
 	epResponse.IPAddress = ep.IPAddress + "/" + strconv.Itoa(int(nw.SubnetLen))
 	epResponse.Gateway = nw.Gateway
+	epResponse.Mtu = ep.Mtu
 
 	if ep.IPv6Address != "" {
 		epResponse.IPv6Address = ep.IPv6Address + "/" + strconv.Itoa(int(nw.IPv6SubnetLen))
@@ -463,6 +465,7 @@ func addPod(w http.ResponseWriter, r *http.Request, vars map[string]string) (int
 
 	resp.Result = 0
 	resp.IPAddress = ep.IPAddress
+	resp.Mtu = ep.Mtu
 
 	if ep.IPv6Address != "" {
 		resp.IPv6Address = ep.IPv6Address
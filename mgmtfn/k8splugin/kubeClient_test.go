@@ -102,6 +102,11 @@ func (d *KubeTestNetDrv) CreateEndpoint(id string) error {
 	return nil
 }
 
+// UpdateNetwork is not implemented.
+func (d *KubeTestNetDrv) UpdateNetwork(id, prevGateway, prevIPv6Gateway string) error {
+	return nil
+}
+
 //UpdateEndpointGroup is not implemented.
 func (d *KubeTestNetDrv) UpdateEndpointGroup(id string) error {
 	return nil
@@ -172,6 +177,11 @@ func (d *KubeTestNetDrv) GetEndpointStats() ([]byte, error) {
 	return []byte{}, core.Errorf("Not implemented")
 }
 
+// GetPolicyRuleStats is not implemented
+func (d *KubeTestNetDrv) GetPolicyRuleStats() ([]byte, error) {
+	return []byte{}, core.Errorf("Not implemented")
+}
+
 // InspectState is not implemented
 func (d *KubeTestNetDrv) InspectState() ([]byte, error) {
 	return []byte{}, core.Errorf("Not implemented")
@@ -197,6 +207,31 @@ func (d *KubeTestNetDrv) DelPolicyRule(id string) error {
 	return core.Errorf("Not implemented")
 }
 
+// UpdateEncryption is not implemented
+func (d *KubeTestNetDrv) UpdateEncryption(id string) error {
+	return core.Errorf("Not implemented")
+}
+
+// GetEncryptionStatus is not implemented
+func (d *KubeTestNetDrv) GetEncryptionStatus() ([]byte, error) {
+	return []byte{}, core.Errorf("Not implemented")
+}
+
+// Reconcile is not implemented
+func (d *KubeTestNetDrv) Reconcile() error {
+	return core.Errorf("Not implemented")
+}
+
+// AddMirrorSession is not implemented
+func (d *KubeTestNetDrv) AddMirrorSession(id string) error {
+	return core.Errorf("Not implemented")
+}
+
+// DelMirrorSession is not implemented
+func (d *KubeTestNetDrv) DelMirrorSession(id string) error {
+	return core.Errorf("Not implemented")
+}
+
 // AddSvcSpec is implemented.
 func (d *KubeTestNetDrv) AddSvcSpec(svcName string, spec *core.ServiceSpec) error {
 	d.services[svcName] = spec
@@ -51,6 +51,30 @@ var Commands = []cli.Command{
 		Usage:  "authenticate to Contiv (you must specify auth_proxy's HTTPS address in the --netmaster flag)",
 		Action: login,
 	},
+	{
+		Name:      "backup",
+		Usage:     "Export the contiv objects and state to a backup file (stdout if no file is given)",
+		ArgsUsage: "[file]",
+		Action:    backupCluster,
+	},
+	{
+		Name:      "restore",
+		Usage:     "Restore a backup file into an empty cluster",
+		ArgsUsage: "[file]",
+		Action:    restoreCluster,
+	},
+	{
+		Name:  "diagnose",
+		Usage: "Check the consistency of the contiv objects, netmaster state and agents",
+		Flags: []cli.Flag{
+			jsonFlag,
+			cli.BoolFlag{
+				Name:  "repair, r",
+				Usage: "Repair the issues that can be fixed automatically",
+			},
+		},
+		Action: diagnoseCluster,
+	},
 	{
 		Name:  "group",
 		Usage: "Endpoint Group manipulation tools",
@@ -144,6 +168,18 @@ var Commands = []cli.Command{
 						Name:  "burst, s",
 						Usage: "burst size(Must be in kilobytes)",
 					},
+					cli.StringFlag{
+						Name:  "ingress-rate",
+						Usage: "Rate the inbound traffic is shaped to (e.g., 100 mbps)",
+					},
+					cli.StringFlag{
+						Name:  "egress-rate",
+						Usage: "Rate the outbound traffic is shaped to on vlan uplinks (e.g., 100 mbps)",
+					},
+					cli.StringFlag{
+						Name:  "egress-min-rate",
+						Usage: "Outbound bandwidth guaranteed on vlan uplinks (e.g., 10 mbps)",
+					},
 				},
 				Action: createNetProfile,
 			},
@@ -202,7 +238,7 @@ var Commands = []cli.Command{
 			},
 			{
 				Name:      "create",
-				Usage:     "Create a network",
+				Usage:     "Create a network or update gateway, subnet range and tag of an existing network",
 				ArgsUsage: "[network]",
 				Flags: []cli.Flag{
 					tenantFlag,
@@ -213,7 +249,7 @@ var Commands = []cli.Command{
 					},
 					cli.StringFlag{
 						Name:  "encap, e",
-						Usage: "Encap type (vlan or vxlan)",
+						Usage: "Encap type (vlan, vxlan or geneve)",
 						Value: "vxlan",
 					},
 					cli.IntFlag{
@@ -240,6 +276,10 @@ var Commands = []cli.Command{
 						Name:  "nw-tag, tag",
 						Usage: "Configured Network Tag",
 					},
+					cli.IntFlag{
+						Name:  "mtu",
+						Usage: "MTU of the endpoints (default: uplink MTU less the encap overhead)",
+					},
 				},
 				Action: createNetwork,
 			},
@@ -270,6 +310,18 @@ var Commands = []cli.Command{
 				ArgsUsage: "[tenant]",
 				Action:    createTenant,
 			},
+			{
+				Name:      "update",
+				Usage:     "Update a tenant",
+				ArgsUsage: "[tenant]",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "default-policy, p",
+						Usage: "Policy applied to all endpoint groups of the tenant, empty to remove it",
+					},
+				},
+				Action: updateTenant,
+			},
 			{
 				Name:      "inspect",
 				Usage:     "Inspect a tenant",
@@ -284,10 +336,16 @@ var Commands = []cli.Command{
 		Subcommands: []cli.Command{
 			{
 				Name:      "create",
-				Usage:     "Create a new policy",
+				Usage:     "Create a new policy or change the mode of an existing policy",
 				ArgsUsage: "[policy]",
-				Flags:     []cli.Flag{tenantFlag},
-				Action:    createPolicy,
+				Flags: []cli.Flag{
+					tenantFlag,
+					cli.StringFlag{
+						Name:  "mode, m",
+						Usage: "Policy mode (enforce or audit). Deny rules of audited policies only count traffic",
+					},
+				},
+				Action: createPolicy,
 			},
 			{
 				Name:      "rm",
@@ -328,7 +386,7 @@ var Commands = []cli.Command{
 			},
 			{
 				Name:      "rule-add",
-				Usage:     "Add a new rule to the policy",
+				Usage:     "Add a new rule to the policy or update an existing rule",
 				ArgsUsage: "[policy] [rule id]",
 				Flags: []cli.Flag{
 					tenantFlag,
@@ -366,13 +424,21 @@ var Commands = []cli.Command{
 						Name:  "to-ip-address, s",
 						Usage: "To IP address/CIDR (Valid in outgoing direction only)",
 					},
+					cli.StringFlag{
+						Name:  "from-address-set, F",
+						Usage: "From address set name (Valid in incoming direction only)",
+					},
+					cli.StringFlag{
+						Name:  "to-address-set, T",
+						Usage: "To address set name (Valid in outgoing direction only)",
+					},
 					cli.StringFlag{
 						Name:  "protocol, l",
 						Usage: "Protocol (e.g., tcp, udp, icmp)",
 					},
-					cli.IntFlag{
+					cli.StringFlag{
 						Name:  "port, P",
-						Usage: "Port",
+						Usage: "Port, port list or range (e.g., 80 or 80,443 or 30000-32767)",
 					},
 					cli.StringFlag{
 						Name:  "action, j",
@@ -382,6 +448,163 @@ var Commands = []cli.Command{
 				},
 				Action: addRule,
 			},
+			{
+				Name:      "simulate",
+				Usage:     "Check if a flow would be allowed by the policies",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					tenantFlag,
+					jsonFlag,
+					cli.StringFlag{
+						Name:  "from-endpoint",
+						Usage: "From endpoint ID",
+					},
+					cli.StringFlag{
+						Name:  "from-group, g",
+						Usage: "From Endpoint Group Name",
+					},
+					cli.StringFlag{
+						Name:  "from-ip-address, i",
+						Usage: "From IP address",
+					},
+					cli.StringFlag{
+						Name:  "to-endpoint",
+						Usage: "To endpoint ID",
+					},
+					cli.StringFlag{
+						Name:  "to-group, e",
+						Usage: "To Endpoint Group Name",
+					},
+					cli.StringFlag{
+						Name:  "to-ip-address, s",
+						Usage: "To IP address",
+					},
+					cli.StringFlag{
+						Name:  "protocol, l",
+						Usage: "Protocol (e.g., tcp, udp, icmp)",
+						Value: "tcp",
+					},
+					cli.IntFlag{
+						Name:  "port, P",
+						Usage: "Destination port",
+					},
+				},
+				Action: simulatePolicy,
+			},
+		},
+	},
+	{
+		Name:  "address-set",
+		Usage: "Address set manipulation tools",
+		Subcommands: []cli.Command{
+			{
+				Name:      "create",
+				Usage:     "Create an address set or update the addresses of an existing one",
+				ArgsUsage: "[address set]",
+				Flags: []cli.Flag{
+					tenantFlag,
+					cli.StringSliceFlag{
+						Name:  "address, a",
+						Usage: "IPv4 or IPv6 address/CIDR (can be repeated multiple times)",
+					},
+				},
+				Action: createAddressSet,
+			},
+			{
+				Name:      "rm",
+				Aliases:   []string{"delete"},
+				Usage:     "Delete an address set",
+				ArgsUsage: "[address set]",
+				Flags:     []cli.Flag{tenantFlag},
+				Action:    deleteAddressSet,
+			},
+			{
+				Name:      "ls",
+				Aliases:   []string{"list"},
+				Usage:     "List address sets",
+				ArgsUsage: " ",
+				Flags:     []cli.Flag{tenantFlag, allFlag, jsonFlag, quietFlag},
+				Action:    listAddressSets,
+			},
+			{
+				Name:      "inspect",
+				Usage:     "Inspect an address set",
+				ArgsUsage: "[address set]",
+				Flags:     []cli.Flag{tenantFlag},
+				Action:    inspectAddressSet,
+			},
+		},
+	},
+	{
+		Name:  "mirror-session",
+		Usage: "Traffic mirroring tools",
+		Subcommands: []cli.Command{
+			{
+				Name:      "create",
+				Usage:     "Create a mirror session or update an existing one",
+				ArgsUsage: "[session]",
+				Flags: []cli.Flag{
+					tenantFlag,
+					cli.StringSliceFlag{
+						Name:  "endpoint, e",
+						Usage: "Endpoint or container ID to mirror (can be repeated multiple times)",
+					},
+					cli.StringFlag{
+						Name:  "group, g",
+						Usage: "Endpoint group to mirror",
+					},
+					cli.StringFlag{
+						Name:  "network, n",
+						Usage: "Network to mirror",
+					},
+					cli.StringFlag{
+						Name:  "direction, d",
+						Usage: "Direction of the mirrored traffic (both, ingress or egress)",
+						Value: "both",
+					},
+					cli.StringFlag{
+						Name:  "destination-type, y",
+						Usage: "Mirror destination type (local, gre or erspan)",
+						Value: "local",
+					},
+					cli.StringFlag{
+						Name:  "destination-port, p",
+						Usage: "OVS port receiving the mirrored traffic of a local session",
+					},
+					cli.StringFlag{
+						Name:  "collector, c",
+						Usage: "IP address of the collector of a gre or erspan session",
+					},
+					cli.IntFlag{
+						Name:  "erspan-id, i",
+						Usage: "ERSPAN session ID (1-1023)",
+					},
+				},
+				Action: createMirrorSession,
+			},
+			{
+				Name:      "rm",
+				Aliases:   []string{"delete"},
+				Usage:     "Delete a mirror session",
+				ArgsUsage: "[session]",
+				Flags:     []cli.Flag{tenantFlag},
+				Action:    deleteMirrorSession,
+			},
+			{
+				Name:      "ls",
+				Aliases:   []string{"list"},
+				Usage:     "List mirror sessions",
+				ArgsUsage: " ",
+				Flags:     []cli.Flag{tenantFlag, allFlag, jsonFlag, quietFlag},
+				Action:    listMirrorSessions,
+			},
+			{
+				Name:      "inspect",
+				Usage:     "Inspect a mirror session",
+				ArgsUsage: "[session]",
+				Flags:     []cli.Flag{tenantFlag},
+				Action:    inspectMirrorSession,
+			},
 		},
 	},
 	{
@@ -471,9 +694,23 @@ var Commands = []cli.Command{
 						Usage: "Select a /16 private subnet for host access",
 						Value: "172.19.0.0/16",
 					},
+					cli.StringFlag{
+						Name:  "baseline-policy",
+						Usage: "Policy of the default tenant applied to all endpoint groups before tenant policies, empty to remove it",
+					},
+					cli.StringFlag{
+						Name:  "encryption",
+						Usage: "Encryption of the vxlan tunnels between the hosts (none, ipsec)",
+					},
 				},
 				Action: setGlobal,
 			},
+			{
+				Name:      "rotate-key",
+				Usage:     "Rotate the keys of the tunnel encryption",
+				ArgsUsage: " ",
+				Action:    rotateEncryptionKey,
+			},
 		},
 	},
 	{
@@ -1,6 +1,7 @@
 package netctl
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -26,6 +27,30 @@ func versionURL(ctx *cli.Context) string {
 	return fmt.Sprintf("%s/version", baseURL(ctx))
 }
 
+func policySimulateURL(ctx *cli.Context) string {
+	return fmt.Sprintf("%s/policy/simulate", baseURL(ctx))
+}
+
+func backupURL(ctx *cli.Context) string {
+	return fmt.Sprintf("%s/backup", baseURL(ctx))
+}
+
+func restoreURL(ctx *cli.Context) string {
+	return fmt.Sprintf("%s/restore", baseURL(ctx))
+}
+
+func diagnoseURL(ctx *cli.Context) string {
+	return fmt.Sprintf("%s/diagnose", baseURL(ctx))
+}
+
+func diagnoseRepairURL(ctx *cli.Context) string {
+	return fmt.Sprintf("%s/diagnose/repair", baseURL(ctx))
+}
+
+func encryptionRotateURL(ctx *cli.Context) string {
+	return fmt.Sprintf("%s/encryption/rotate", baseURL(ctx))
+}
+
 func writeBody(resp *http.Response, ctx *cli.Context) {
 	content, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -47,3 +72,20 @@ func getObject(ctx *cli.Context, url string, jdata interface{}) error {
 
 	return nil
 }
+
+func postObject(ctx *cli.Context, url string, jreq, jresp interface{}) error {
+	content, err := json.Marshal(jreq)
+	handleBasicError(ctx, err)
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(content))
+	handleBasicError(ctx, err)
+
+	respCheck(resp, ctx)
+
+	content, err = ioutil.ReadAll(resp.Body)
+	handleBasicError(ctx, err)
+
+	handleBasicError(ctx, json.Unmarshal(content, jresp))
+
+	return nil
+}
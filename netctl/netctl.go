@@ -6,11 +6,13 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 
@@ -133,8 +135,17 @@ func createPolicy(ctx *cli.Context) {
 
 	tenant := ctx.String("tenant")
 	policy := ctx.Args()[0]
+	mode := ctx.String("mode")
+
+	// keep the mode of an existing policy unless asked to change it
+	if mode == "" {
+		if pol, err := getClient(ctx).PolicyGet(tenant, policy); err == nil {
+			mode = pol.Mode
+		}
+	}
 
 	errCheck(ctx, getClient(ctx).PolicyPost(&contivClient.Policy{
+		Mode:       mode,
 		PolicyName: policy,
 		TenantName: tenant,
 	}))
@@ -205,15 +216,85 @@ func listPolicies(ctx *cli.Context) {
 	} else {
 		writer := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
 		defer writer.Flush()
-		writer.Write([]byte("Tenant\tPolicy\n"))
-		writer.Write([]byte("------\t------\n"))
+		writer.Write([]byte("Tenant\tPolicy\tMode\n"))
+		writer.Write([]byte("------\t------\t----\n"))
 
 		for _, policy := range filtered {
-			writer.Write([]byte(fmt.Sprintf("%s\t%s\n", policy.TenantName, policy.PolicyName)))
+			writer.Write([]byte(fmt.Sprintf("%s\t%s\t%s\n", policy.TenantName, policy.PolicyName, policy.Mode)))
 		}
 	}
 }
 
+// policySimulateRequest is the flow sent to the netmaster policy simulator
+type policySimulateRequest struct {
+	TenantName        string `json:"tenantName"`
+	FromEndpointID    string `json:"fromEndpointID,omitempty"`
+	FromEndpointGroup string `json:"fromEndpointGroup,omitempty"`
+	FromIpAddress     string `json:"fromIpAddress,omitempty"`
+	ToEndpointID      string `json:"toEndpointID,omitempty"`
+	ToEndpointGroup   string `json:"toEndpointGroup,omitempty"`
+	ToIpAddress       string `json:"toIpAddress,omitempty"`
+	Protocol          string `json:"protocol,omitempty"`
+	Port              int    `json:"port,omitempty"`
+}
+
+// policySimulateResponse is the verdict returned by the policy simulator
+type policySimulateResponse struct {
+	Action        string `json:"action"`
+	TenantName    string `json:"tenantName,omitempty"`
+	PolicyName    string `json:"policyName,omitempty"`
+	EndpointGroup string `json:"endpointGroup,omitempty"`
+	RuleID        string `json:"ruleId,omitempty"`
+	Priority      int    `json:"priority,omitempty"`
+	OfnetRuleID   string `json:"ofnetRuleId,omitempty"`
+}
+
+func simulatePolicy(ctx *cli.Context) {
+	if len(ctx.Args()) != 0 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+
+	simReq := policySimulateRequest{
+		TenantName:        ctx.String("tenant"),
+		FromEndpointID:    ctx.String("from-endpoint"),
+		FromEndpointGroup: ctx.String("from-group"),
+		FromIpAddress:     ctx.String("from-ip-address"),
+		ToEndpointID:      ctx.String("to-endpoint"),
+		ToEndpointGroup:   ctx.String("to-group"),
+		ToIpAddress:       ctx.String("to-ip-address"),
+		Protocol:          ctx.String("protocol"),
+		Port:              ctx.Int("port"),
+	}
+
+	if simReq.FromEndpointID == "" && simReq.FromEndpointGroup == "" && simReq.FromIpAddress == "" {
+		errExit(ctx, exitHelp, "Source endpoint, group or IP address required", true)
+	}
+	if simReq.ToEndpointID == "" && simReq.ToEndpointGroup == "" && simReq.ToIpAddress == "" {
+		errExit(ctx, exitHelp, "Destination endpoint, group or IP address required", true)
+	}
+
+	var simResp policySimulateResponse
+	postObject(ctx, policySimulateURL(ctx), &simReq, &simResp)
+
+	if ctx.Bool("json") {
+		dumpJSONList(ctx, &simResp)
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer writer.Flush()
+	writer.Write([]byte(fmt.Sprintf("Action:\t%s\n", simResp.Action)))
+	if simResp.RuleID == "" {
+		writer.Write([]byte("Rule:\tno rule matched\n"))
+		return
+	}
+	writer.Write([]byte(fmt.Sprintf("Tenant:\t%s\n", simResp.TenantName)))
+	writer.Write([]byte(fmt.Sprintf("Policy:\t%s\n", simResp.PolicyName)))
+	writer.Write([]byte(fmt.Sprintf("Group:\t%s\n", simResp.EndpointGroup)))
+	writer.Write([]byte(fmt.Sprintf("Rule:\t%s\n", simResp.RuleID)))
+	writer.Write([]byte(fmt.Sprintf("Priority:\t%d\n", simResp.Priority)))
+}
+
 func addRule(ctx *cli.Context) {
 	if len(ctx.Args()) != 2 {
 		errExit(ctx, exitHelp, "Policy name and Rule ID required", true)
@@ -232,6 +313,9 @@ func addRule(ctx *cli.Context) {
 		if ctx.String("from-group") != "" && ctx.String("from-network") != "" {
 			errExit(ctx, exitHelp, "Can't specify both from-group argument and -from-network ", false)
 		}
+		if ctx.String("to-address-set") != "" {
+			errExit(ctx, exitHelp, "Cant specify to-address-set for incoming rule", false)
+		}
 	} else if dir == "out" {
 		if ctx.String("from-group") != "" {
 			errExit(ctx, exitHelp, "Cant specify from-group for outgoing rule", false)
@@ -242,6 +326,9 @@ func addRule(ctx *cli.Context) {
 		if ctx.String("from-ip-address") != "" {
 			errExit(ctx, exitHelp, "Cant specify from-ip-address for outgoing rule", false)
 		}
+		if ctx.String("from-address-set") != "" {
+			errExit(ctx, exitHelp, "Cant specify from-address-set for outgoing rule", false)
+		}
 
 		// If to EPG is specified, make sure to network is specified too
 		if ctx.String("to-group") != "" && ctx.String("to-network") != "" {
@@ -251,12 +338,24 @@ func addRule(ctx *cli.Context) {
 		errExit(ctx, exitHelp, "Unknown direction", false)
 	}
 
+	// a single port goes in port, lists and ranges go in ports
+	port := 0
+	ports := ctx.String("port")
+	if ports != "" {
+		if p, err := strconv.Atoi(ports); err == nil {
+			port = p
+			ports = ""
+		}
+	}
+
 	errCheck(ctx, getClient(ctx).RulePost(&contivClient.Rule{
 		TenantName:        ctx.String("tenant"),
 		PolicyName:        ctx.Args()[0],
 		RuleID:            ctx.Args()[1],
 		Priority:          ctx.Int("priority"),
 		Direction:         ctx.String("direction"),
+		FromAddressSet:    ctx.String("from-address-set"),
+		ToAddressSet:      ctx.String("to-address-set"),
 		FromEndpointGroup: ctx.String("from-group"),
 		ToEndpointGroup:   ctx.String("to-group"),
 		FromNetwork:       ctx.String("from-network"),
@@ -264,7 +363,8 @@ func addRule(ctx *cli.Context) {
 		FromIpAddress:     ctx.String("from-ip-address"),
 		ToIpAddress:       ctx.String("to-ip-address"),
 		Protocol:          ctx.String("protocol"),
-		Port:              ctx.Int("port"),
+		Port:              port,
+		Ports:             ports,
 		Action:            ctx.String("action"),
 	}))
 }
@@ -337,41 +437,43 @@ func listRules(ctx *cli.Context) {
 		writer := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
 		defer writer.Flush()
 		writer.Write([]byte("Incoming Rules:\n"))
-		writer.Write([]byte("Rule\tPriority\tFrom EndpointGroup\tFrom Network\tFrom IpAddress\tTo IpAddress\tProtocol\tPort\tAction\n"))
-		writer.Write([]byte("----\t--------\t------------------\t------------\t---------\t------------\t--------\t----\t------\n"))
+		writer.Write([]byte("Rule\tPriority\tFrom EndpointGroup\tFrom Network\tFrom IpAddress\tFrom AddressSet\tTo IpAddress\tProtocol\tPort\tAction\n"))
+		writer.Write([]byte("----\t--------\t------------------\t------------\t---------\t--------------\t------------\t--------\t----\t------\n"))
 
 		for _, rule := range results {
 			if rule.Direction == "in" {
 				writer.Write([]byte(fmt.Sprintf(
-					"%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+					"%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
 					rule.RuleID,
 					rule.Priority,
 					rule.FromEndpointGroup,
 					rule.FromNetwork,
 					rule.FromIpAddress,
+					rule.FromAddressSet,
 					rule.ToIpAddress,
 					rule.Protocol,
-					rule.Port,
+					rulePorts(rule),
 					rule.Action,
 				)))
 			}
 		}
 
 		writer.Write([]byte("Outgoing Rules:\n"))
-		writer.Write([]byte("Rule\tPriority\tTo EndpointGroup\tTo Network\tTo IpAddress\tProtocol\tPort\tAction\n"))
-		writer.Write([]byte("----\t--------\t----------------\t----------\t---------\t--------\t----\t------\n"))
+		writer.Write([]byte("Rule\tPriority\tTo EndpointGroup\tTo Network\tTo IpAddress\tTo AddressSet\tProtocol\tPort\tAction\n"))
+		writer.Write([]byte("----\t--------\t----------------\t----------\t---------\t------------\t--------\t----\t------\n"))
 
 		for _, rule := range results {
 			if rule.Direction == "out" {
 				writer.Write([]byte(fmt.Sprintf(
-					"%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+					"%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
 					rule.RuleID,
 					rule.Priority,
 					rule.ToEndpointGroup,
 					rule.ToNetwork,
 					rule.ToIpAddress,
+					rule.ToAddressSet,
 					rule.Protocol,
-					rule.Port,
+					rulePorts(rule),
 					rule.Action,
 				)))
 			}
@@ -379,6 +481,227 @@ func listRules(ctx *cli.Context) {
 	}
 }
 
+// rulePorts returns the ports matched by a rule for display
+func rulePorts(rule *contivClient.Rule) string {
+	if rule.Ports == "" {
+		return strconv.Itoa(rule.Port)
+	}
+	if rule.Port != 0 {
+		return strconv.Itoa(rule.Port) + "," + rule.Ports
+	}
+	return rule.Ports
+}
+
+func createAddressSet(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		errExit(ctx, exitHelp, "Address set name required", true)
+	}
+
+	tenant := ctx.String("tenant")
+	name := ctx.Args()[0]
+
+	errCheck(ctx, getClient(ctx).AddressSetPost(&contivClient.AddressSet{
+		TenantName:     tenant,
+		AddressSetName: name,
+		Addresses:      ctx.StringSlice("address"),
+	}))
+
+	fmt.Printf("Creating address set %s:%s\n", tenant, name)
+}
+
+func deleteAddressSet(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		errExit(ctx, exitHelp, "Address set name required", true)
+	}
+
+	tenant := ctx.String("tenant")
+	name := ctx.Args()[0]
+
+	errCheck(ctx, getClient(ctx).AddressSetDelete(tenant, name))
+}
+
+func listAddressSets(ctx *cli.Context) {
+	if len(ctx.Args()) != 0 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+
+	tenant := ctx.String("tenant")
+
+	setList, err := getClient(ctx).AddressSetList()
+	errCheck(ctx, err)
+
+	filtered := []*contivClient.AddressSet{}
+
+	for _, addrSet := range *setList {
+		if addrSet.TenantName == tenant || ctx.Bool("all") {
+			filtered = append(filtered, addrSet)
+		}
+	}
+
+	if ctx.Bool("json") {
+		dumpJSONList(ctx, filtered)
+	} else if ctx.Bool("quiet") {
+		sets := ""
+		for _, addrSet := range filtered {
+			sets += addrSet.AddressSetName + "\n"
+		}
+		os.Stdout.WriteString(sets)
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+		defer writer.Flush()
+		writer.Write([]byte("Tenant\tAddress Set\tAddresses\n"))
+		writer.Write([]byte("------\t-----------\t---------\n"))
+
+		for _, addrSet := range filtered {
+			writer.Write([]byte(fmt.Sprintf("%v\t%v\t%v\n",
+				addrSet.TenantName,
+				addrSet.AddressSetName,
+				strings.Join(addrSet.Addresses, ","),
+			)))
+		}
+	}
+}
+
+func inspectAddressSet(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		errExit(ctx, exitHelp, "Address set name required", true)
+	}
+
+	tenant := ctx.String("tenant")
+	name := ctx.Args()[0]
+
+	addrSet, err := getClient(ctx).AddressSetInspect(tenant, name)
+	errCheck(ctx, err)
+
+	content, err := json.MarshalIndent(addrSet, "", "  ")
+	if err != nil {
+		errExit(ctx, exitIO, err.Error(), false)
+	}
+	os.Stdout.Write(content)
+	os.Stdout.WriteString("\n")
+}
+
+func createMirrorSession(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		errExit(ctx, exitHelp, "Mirror session name required", true)
+	}
+
+	tenant := ctx.String("tenant")
+	name := ctx.Args()[0]
+
+	errCheck(ctx, getClient(ctx).MirrorSessionPost(&contivClient.MirrorSession{
+		TenantName:      tenant,
+		SessionName:     name,
+		Endpoints:       ctx.StringSlice("endpoint"),
+		EndpointGroup:   ctx.String("group"),
+		NetworkName:     ctx.String("network"),
+		Direction:       ctx.String("direction"),
+		DestinationType: ctx.String("destination-type"),
+		DestinationPort: ctx.String("destination-port"),
+		CollectorIp:     ctx.String("collector"),
+		ErspanId:        ctx.Int("erspan-id"),
+	}))
+
+	fmt.Printf("Creating mirror session %s:%s\n", tenant, name)
+}
+
+func deleteMirrorSession(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		errExit(ctx, exitHelp, "Mirror session name required", true)
+	}
+
+	tenant := ctx.String("tenant")
+	name := ctx.Args()[0]
+
+	errCheck(ctx, getClient(ctx).MirrorSessionDelete(tenant, name))
+}
+
+// mirrorSource returns what a mirror session selects for display
+func mirrorSource(session *contivClient.MirrorSession) string {
+	if session.EndpointGroup != "" {
+		return "group:" + session.EndpointGroup
+	}
+	if session.NetworkName != "" {
+		return "network:" + session.NetworkName
+	}
+	return strings.Join(session.Endpoints, ",")
+}
+
+// mirrorDestination returns where a mirror session sends the traffic for
+// display
+func mirrorDestination(session *contivClient.MirrorSession) string {
+	switch session.DestinationType {
+	case "gre":
+		return "gre:" + session.CollectorIp
+	case "erspan":
+		return fmt.Sprintf("erspan:%s/%d", session.CollectorIp, session.ErspanId)
+	}
+	return session.DestinationPort
+}
+
+func listMirrorSessions(ctx *cli.Context) {
+	if len(ctx.Args()) != 0 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+
+	tenant := ctx.String("tenant")
+
+	sessionList, err := getClient(ctx).MirrorSessionList()
+	errCheck(ctx, err)
+
+	filtered := []*contivClient.MirrorSession{}
+
+	for _, session := range *sessionList {
+		if session.TenantName == tenant || ctx.Bool("all") {
+			filtered = append(filtered, session)
+		}
+	}
+
+	if ctx.Bool("json") {
+		dumpJSONList(ctx, filtered)
+	} else if ctx.Bool("quiet") {
+		sessions := ""
+		for _, session := range filtered {
+			sessions += session.SessionName + "\n"
+		}
+		os.Stdout.WriteString(sessions)
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+		defer writer.Flush()
+		writer.Write([]byte("Tenant\tSession\tSource\tDirection\tDestination\n"))
+		writer.Write([]byte("------\t-------\t------\t---------\t-----------\n"))
+
+		for _, session := range filtered {
+			writer.Write([]byte(fmt.Sprintf("%v\t%v\t%v\t%v\t%v\n",
+				session.TenantName,
+				session.SessionName,
+				mirrorSource(session),
+				session.Direction,
+				mirrorDestination(session),
+			)))
+		}
+	}
+}
+
+func inspectMirrorSession(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		errExit(ctx, exitHelp, "Mirror session name required", true)
+	}
+
+	tenant := ctx.String("tenant")
+	name := ctx.Args()[0]
+
+	session, err := getClient(ctx).MirrorSessionInspect(tenant, name)
+	errCheck(ctx, err)
+
+	content, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		errExit(ctx, exitIO, err.Error(), false)
+	}
+	os.Stdout.Write(content)
+	os.Stdout.WriteString("\n")
+}
+
 func createNetProfile(ctx *cli.Context) {
 	if len(ctx.Args()) != 1 {
 		errExit(ctx, exitHelp, "Net profile name required", true)
@@ -392,11 +715,14 @@ func createNetProfile(ctx *cli.Context) {
 	name := ctx.Args()[0]
 
 	errCheck(ctx, getClient(ctx).NetprofilePost(&contivClient.Netprofile{
-		Burst:       burst,
-		Bandwidth:   bandwidth,
-		DSCP:        dscp,
-		ProfileName: name,
-		TenantName:  tenant,
+		Burst:         burst,
+		Bandwidth:     bandwidth,
+		DSCP:          dscp,
+		IngressRate:   ctx.String("ingress-rate"),
+		EgressRate:    ctx.String("egress-rate"),
+		EgressMinRate: ctx.String("egress-min-rate"),
+		ProfileName:   name,
+		TenantName:    tenant,
 	}))
 	fmt.Printf("Creating netprofile %s:%s\n", tenant, name)
 }
@@ -537,6 +863,7 @@ func createNetwork(ctx *cli.Context) {
 	pktTag := ctx.Int("pkt-tag")
 	nwType := ctx.String("nw-type")
 	nwTag := ctx.String("nw-tag")
+	mtu := ctx.Int("mtu")
 
 	errCheck(ctx, getClient(ctx).NetworkPost(&contivClient.Network{
 		TenantName:  tenant,
@@ -549,6 +876,7 @@ func createNetwork(ctx *cli.Context) {
 		PktTag:      pktTag,
 		NwType:      nwType,
 		CfgdTag:     nwTag,
+		Mtu:         mtu,
 	}))
 
 	fmt.Printf("Creating network %s:%s\n", tenant, network)
@@ -657,6 +985,23 @@ func createTenant(ctx *cli.Context) {
 	fmt.Printf("Creating tenant: %s\n", tenant)
 }
 
+func updateTenant(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		errExit(ctx, exitHelp, "Tenant name required", true)
+	}
+
+	tenantName := ctx.Args()[0]
+
+	tenant, err := getClient(ctx).TenantGet(tenantName)
+	errCheck(ctx, err)
+
+	if ctx.IsSet("default-policy") {
+		tenant.DefaultPolicy = ctx.String("default-policy")
+	}
+
+	errCheck(ctx, getClient(ctx).TenantPost(tenant))
+}
+
 func deleteTenant(ctx *cli.Context) {
 	if len(ctx.Args()) != 1 {
 		errExit(ctx, exitHelp, "Tenant name required", true)
@@ -703,13 +1048,14 @@ func listTenants(ctx *cli.Context) {
 	} else {
 		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
 		defer writer.Flush()
-		writer.Write([]byte("Name\t\n"))
-		writer.Write([]byte("------\t\n"))
+		writer.Write([]byte("Name\tDefault Policy\t\n"))
+		writer.Write([]byte("------\t---------------\t\n"))
 
 		for _, tenant := range *tenantList {
 			writer.Write(
-				[]byte(fmt.Sprintf("%v\t\n",
+				[]byte(fmt.Sprintf("%v\t%v\t\n",
 					tenant.TenantName,
+					tenant.DefaultPolicy,
 				)))
 		}
 	}
@@ -967,6 +1313,8 @@ func showGlobal(ctx *cli.Context) {
 			writer.Write([]byte(fmt.Sprintf("Vlan Range: %v\n", gl.Vlans)))
 			writer.Write([]byte(fmt.Sprintf("Vxlan range: %v\n", gl.Vxlans)))
 			writer.Write([]byte(fmt.Sprintf("Private subnet: %v\n", gl.PvtSubnet)))
+			writer.Write([]byte(fmt.Sprintf("Baseline policy: %v\n", gl.BaselinePolicy)))
+			writer.Write([]byte(fmt.Sprintf("Encryption: %v\n", gl.Encryption)))
 		}
 	}
 }
@@ -1017,10 +1365,33 @@ func setGlobal(ctx *cli.Context) {
 	if ps != "" {
 		global.PvtSubnet = ps
 	}
+	if ctx.IsSet("baseline-policy") {
+		global.BaselinePolicy = ctx.String("baseline-policy")
+	}
+	if ctx.IsSet("encryption") {
+		global.Encryption = ctx.String("encryption")
+	}
 
 	errCheck(ctx, getClient(ctx).GlobalPost(global))
 }
 
+// encryptionRotateResponse is the response of the key rotation
+type encryptionRotateResponse struct {
+	Mode          string `json:"mode"`
+	KeyGeneration int    `json:"key-generation"`
+}
+
+func rotateEncryptionKey(ctx *cli.Context) {
+	if len(ctx.Args()) != 0 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+
+	var resp encryptionRotateResponse
+	postObject(ctx, encryptionRotateURL(ctx), struct{}{}, &resp)
+
+	fmt.Printf("Rotated the tunnel encryption key, key generation %d\n", resp.KeyGeneration)
+}
+
 func setAciGw(ctx *cli.Context) {
 	paths := ctx.String("path-bindings")
 	nodes := ctx.String("node-bindings")
@@ -1118,6 +1489,112 @@ func showVersion(ctx *cli.Context) {
 	}
 }
 
+// restoreResponse is the result of a restore returned by netmaster
+type restoreResponse struct {
+	NumObjects int `json:"numObjects"`
+	NumStates  int `json:"numStates"`
+}
+
+func backupCluster(ctx *cli.Context) {
+	if len(ctx.Args()) > 1 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+
+	var backup json.RawMessage
+	getObject(ctx, backupURL(ctx), &backup)
+
+	if len(ctx.Args()) == 0 {
+		os.Stdout.Write(backup)
+		return
+	}
+
+	if err := ioutil.WriteFile(ctx.Args()[0], backup, 0600); err != nil {
+		errExit(ctx, exitIO, err.Error(), false)
+	}
+	fmt.Printf("Wrote backup to %s\n", ctx.Args()[0])
+}
+
+func restoreCluster(ctx *cli.Context) {
+	if len(ctx.Args()) != 1 {
+		errExit(ctx, exitHelp, "Backup file required", true)
+	}
+
+	content, err := ioutil.ReadFile(ctx.Args()[0])
+	if err != nil {
+		errExit(ctx, exitIO, err.Error(), false)
+	}
+
+	var backup json.RawMessage
+	if err := json.Unmarshal(content, &backup); err != nil {
+		errExit(ctx, exitInvalid, fmt.Sprintf("Invalid backup file: %v", err), false)
+	}
+
+	var resp restoreResponse
+	postObject(ctx, restoreURL(ctx), &backup, &resp)
+
+	fmt.Printf("Restored %d objects and %d states\n", resp.NumObjects, resp.NumStates)
+	fmt.Printf("Restart netmaster to load the restored objects\n")
+}
+
+// diagnoseIssue is an inconsistency found by the netmaster consistency check
+type diagnoseIssue struct {
+	Kind        string `json:"kind"`
+	Object      string `json:"object"`
+	Message     string `json:"message"`
+	Repairable  bool   `json:"repairable"`
+	Repaired    bool   `json:"repaired"`
+	RepairError string `json:"repairError,omitempty"`
+}
+
+// diagnoseReport is the result of the consistency check
+type diagnoseReport struct {
+	Issues            []diagnoseIssue `json:"issues"`
+	NumRepaired       int             `json:"numRepaired"`
+	UnreachableAgents []string        `json:"unreachableAgents"`
+}
+
+func diagnoseCluster(ctx *cli.Context) {
+	if len(ctx.Args()) != 0 {
+		errExit(ctx, exitHelp, "More arguments than required", true)
+	}
+
+	var report diagnoseReport
+	if ctx.Bool("repair") {
+		postObject(ctx, diagnoseRepairURL(ctx), struct{}{}, &report)
+	} else {
+		getObject(ctx, diagnoseURL(ctx), &report)
+	}
+
+	if ctx.Bool("json") {
+		dumpJSONList(ctx, report)
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 2, 2, ' ', 0)
+	writer.Write([]byte("Kind\tObject\tIssue\tRepair\n"))
+	writer.Write([]byte("----\t------\t-----\t------\n"))
+
+	for _, issue := range report.Issues {
+		repair := "manual"
+		switch {
+		case issue.Repaired:
+			repair = "repaired"
+		case issue.RepairError != "":
+			repair = "failed: " + issue.RepairError
+		case issue.Repairable:
+			repair = "available"
+		}
+
+		writer.Write([]byte(fmt.Sprintf("%s\t%s\t%s\t%s\n", issue.Kind, issue.Object, issue.Message, repair)))
+	}
+	writer.Flush()
+
+	fmt.Printf("\nFound %d issues, repaired %d\n", len(report.Issues), report.NumRepaired)
+	if len(report.UnreachableAgents) > 0 {
+		fmt.Printf("Could not inspect the agents on: %s\n", strings.Join(report.UnreachableAgents, ", "))
+	}
+}
+
 func createAppProfile(ctx *cli.Context) {
 	if len(ctx.Args()) != 1 {
 		errExit(ctx, exitHelp, "Profile name required", true)
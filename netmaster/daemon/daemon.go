@@ -84,6 +84,20 @@ func (d *MasterDaemon) Init() {
 		log.Fatalf("Failed to init state-store: driver %q, URLs %q. Error: %s", d.ClusterStoreDriver, d.ClusterStoreURL, err)
 	}
 
+	// stamp records that only lack the schema version, and refuse to run on
+	// state that needs to be migrated
+	stamped, err := core.StampSchemas(d.stateDriver)
+	if err != nil {
+		log.Fatalf("Failed to stamp the state store with the schema versions. Error: %s", err)
+	}
+	for _, status := range stamped {
+		log.Infof("Stamped %d records under %s with schema version %d",
+			status.NumOutdated, status.KeyPrefix, status.Version)
+	}
+	if err := core.CheckSchemas(d.stateDriver); err != nil {
+		log.Fatalf("State store needs to be migrated with 'cfgtool -migrate'. Error: %s", err)
+	}
+
 	// Initialize resource manager
 	d.resmgr, err = resources.NewStateResourceManager(d.stateDriver)
 	if err != nil {
@@ -257,6 +271,26 @@ func (d *MasterDaemon) ClearEndpoints(stateDriver core.StateDriver, epCfgs *[]co
 	return nil
 }
 
+// diagnoseHandler returns a handler that checks the consistency of the
+// cluster state against the running agents, and repairs the issues it can
+// if repair is set
+func (d *MasterDaemon) diagnoseHandler(repair bool) func(http.ResponseWriter, *http.Request, map[string]string) (interface{}, error) {
+	return func(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+		stateDriver, err := utils.GetStateDriver()
+		if err != nil {
+			return nil, err
+		}
+
+		agents, err := d.objdbClient.GetService("netplugin")
+		if err != nil {
+			log.Errorf("Error getting netplugin nodes. Err: %v", err)
+			return nil, err
+		}
+
+		return master.Diagnose(stateDriver, agents, repair)
+	}
+}
+
 // registerRoutes registers HTTP route handlers
 func (d *MasterDaemon) registerRoutes(router *mux.Router) {
 	// Add REST routes
@@ -267,6 +301,10 @@ func (d *MasterDaemon) registerRoutes(router *mux.Router) {
 	s.HandleFunc("/plugin/createEndpoint", utils.MakeHTTPHandler(master.CreateEndpointHandler))
 	s.HandleFunc("/plugin/deleteEndpoint", utils.MakeHTTPHandler(master.DeleteEndpointHandler))
 	s.HandleFunc("/plugin/updateEndpoint", utils.MakeHTTPHandler(master.UpdateEndpointHandler))
+	s.HandleFunc(fmt.Sprintf("/%s", master.PolicySimulateRESTEndpoint), utils.MakeHTTPHandler(master.PolicySimulateHandler))
+	s.HandleFunc(fmt.Sprintf("/%s", master.RestoreRESTEndpoint), utils.MakeHTTPHandler(master.RestoreHandler))
+	s.HandleFunc(fmt.Sprintf("/%s", master.DiagnoseRepairRESTEndpoint), utils.MakeHTTPHandler(d.diagnoseHandler(true)))
+	s.HandleFunc(fmt.Sprintf("/%s", master.EncryptionRotateRESTEndpoint), utils.MakeHTTPHandler(master.RotateEncryptionKeyHandler))
 
 	s = router.Methods("Get").Subrouter()
 
@@ -292,6 +330,11 @@ func (d *MasterDaemon) registerRoutes(router *mux.Router) {
 		w.Write(resp)
 	})
 
+	// export a backup of the cluster store
+	s.HandleFunc(fmt.Sprintf("/%s", master.BackupRESTEndpoint), utils.MakeHTTPHandler(master.BackupHandler))
+	// check the consistency of the cluster state
+	s.HandleFunc(fmt.Sprintf("/%s", master.DiagnoseRESTEndpoint), utils.MakeHTTPHandler(d.diagnoseHandler(false)))
+
 	// services REST endpoints
 	// FIXME: we need to remove once service inspect is added
 	s.HandleFunc(fmt.Sprintf("/%s/%s", master.GetServiceRESTEndpoint, "{id}"),
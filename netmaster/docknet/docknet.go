@@ -53,6 +53,10 @@ type DnetOperState struct {
 	DocknetUUID string `json:"docknetUUID"`
 }
 
+func init() {
+	core.RegisterSchema(docknetOperPrefix, &DnetOperState{})
+}
+
 // Write the state.
 func (s *DnetOperState) Write() error {
 	key := fmt.Sprintf(docknetOperPath, s.ID)
@@ -142,7 +146,7 @@ func CreateDockNet(tenantName, networkName, serviceName string, nwCfg *mastercfg
 		netPluginOptions := make(map[string]string)
 		netPluginOptions["tenant"] = nwCfg.Tenant
 		netPluginOptions["encap"] = nwCfg.PktTagType
-		if nwCfg.PktTagType == "vxlan" {
+		if nwCfg.PktTagType == "vxlan" || nwCfg.PktTagType == "geneve" {
 			netPluginOptions["pkt-tag"] = strconv.Itoa(nwCfg.ExtPktTag)
 		} else {
 			netPluginOptions["pkt-tag"] = strconv.Itoa(nwCfg.PktTag)
@@ -236,7 +240,7 @@ func DeleteDockNet(tenantName, networkName, serviceName string) error {
 	}
 
 	err = DeleteDockNetState(tenantName, networkName, serviceName)
-	if docknetDeleted && strings.Contains(err.Error(), "key not found") {
+	if docknetDeleted && err != nil && strings.Contains(err.Error(), "key not found") {
 		// Ignore the error as docknet was already deleted
 		err = nil
 	}
@@ -58,6 +58,11 @@ type Cfg struct {
 	Auto AutoParams `json:"auto"`
 }
 
+func init() {
+	core.RegisterSchema(cfgGlobalPrefix, &Cfg{})
+	core.RegisterSchema(operGlobalPrefix, &Oper{})
+}
+
 // Oper encapsulates operations on a tenant.
 type Oper struct {
 	core.CommonState
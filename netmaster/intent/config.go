@@ -25,6 +25,7 @@ type ConfigGlobal struct {
 	FwdMode     string
 	ArpMode     string
 	PvtSubnet   string
+	Encryption  string
 }
 
 // ConfigEP encapulsates an endpoint: a leg into a network
@@ -51,6 +52,7 @@ type ConfigNetwork struct {
 	IPv6Gateway    string
 	Vrf            string
 	CfgdTag        string
+	Mtu            int
 
 	// eps associated with the network
 	Endpoints []ConfigEP
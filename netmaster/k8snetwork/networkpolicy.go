@@ -3,6 +3,8 @@ package networkpolicy
 import (
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"net"
 	"reflect"
 	"strings"
 	"time"
@@ -10,8 +12,11 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/contiv/netplugin/contivmodel/client"
 	"github.com/contiv/netplugin/utils/k8sutils"
+	core_v1 "k8s.io/api/core/v1"
 	"k8s.io/api/networking/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 )
@@ -23,10 +28,24 @@ const defaultEpgName = "ingress-group"
 const defaultPolicyName = "ingress-policy"
 const defaultRuleID = "1"
 
+// priorities of the rules derived from k8s network policies,
+// higher number wins
+const (
+	npDenyPriority  = 1
+	npAllowPriority = 2
+)
+
+// maximum length of contiv object names
+const maxNameLength = 64
+
 type k8sContext struct {
 	k8sClientSet *kubernetes.Clientset
 	contivClient *client.ContivClient
 	isLeader     func() bool
+	// network policies received from k8s, keyed by namespace/name
+	nwPolicies map[string]*v1.NetworkPolicy
+	// pod ip addresses matched by the rules of each network policy
+	nwPolicyIPs map[string]map[string]bool
 }
 
 var npLog *log.Entry
@@ -199,19 +218,23 @@ func (k8sNet *k8sContext) createRule(policyName, ruleID, action string) error {
 		}
 	}
 
-	if err := k8sNet.contivClient.RulePost(&client.Rule{
+	return k8sNet.addRule(&client.Rule{
 		TenantName: defaultTenantName,
 		PolicyName: policyName,
 		RuleID:     ruleID,
 		Direction:  "in",
 		Action:     action,
-	}); err != nil {
-		npLog.Errorf("failed to create rule-id [%s] %s", ruleID, err)
+	})
+}
+
+func (k8sNet *k8sContext) addRule(rule *client.Rule) error {
+	if err := k8sNet.contivClient.RulePost(rule); err != nil {
+		npLog.Errorf("failed to create rule-id [%s] %s", rule.RuleID, err)
 		return err
 	}
 
 	for func() error {
-		_, err := k8sNet.contivClient.RuleGet(defaultTenantName, policyName, ruleID)
+		_, err := k8sNet.contivClient.RuleGet(rule.TenantName, rule.PolicyName, rule.RuleID)
 		return err
 	}() != nil {
 		time.Sleep(time.Millisecond * 100)
@@ -312,6 +335,575 @@ func (k8sNet *k8sContext) deleteDefaultIngressPolicy(ns string) {
 	}
 }
 
+func (k8sNet *k8sContext) attachPolicy(epgName, policyName string) error {
+	epg, err := k8sNet.contivClient.EndpointGroupGet(defaultTenantName, epgName)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range epg.Policies {
+		if p == policyName {
+			return nil
+		}
+	}
+
+	npLog.Infof("attach policy %s to epg %s", policyName, epgName)
+	epg.Policies = append(epg.Policies, policyName)
+	if err := k8sNet.contivClient.EndpointGroupPost(epg); err != nil {
+		npLog.Errorf("failed to attach policy %s to epg %s, %s", policyName, epgName, err)
+		return err
+	}
+	return nil
+}
+
+// detachPolicy removes the policy from epg and returns the number of
+// policies still attached to it
+func (k8sNet *k8sContext) detachPolicy(epgName, policyName string) (int, error) {
+	epg, err := k8sNet.contivClient.EndpointGroupGet(defaultTenantName, epgName)
+	if err != nil {
+		npLog.Errorf("failed to read epg %s, %s", epgName, err)
+		return 0, err
+	}
+
+	policies := []string{}
+	for _, p := range epg.Policies {
+		if p != policyName {
+			policies = append(policies, p)
+		}
+	}
+	if len(policies) == len(epg.Policies) {
+		return len(policies), nil
+	}
+
+	npLog.Infof("detach policy %s from epg %s", policyName, epgName)
+	epg.Policies = policies
+	if err := k8sNet.contivClient.EndpointGroupPost(epg); err != nil {
+		npLog.Errorf("failed to detach policy %s from epg %s, %s", policyName, epgName, err)
+		return len(epg.Policies), err
+	}
+	return len(policies), nil
+}
+
+// getPolicyRules returns the rules configured in a contiv policy
+func (k8sNet *k8sContext) getPolicyRules(policyName string) (map[string]*client.Rule, error) {
+	ruleList, err := k8sNet.contivClient.RuleList()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make(map[string]*client.Rule)
+	for _, rule := range *ruleList {
+		if rule.TenantName == defaultTenantName && rule.PolicyName == policyName {
+			rules[rule.RuleID] = rule
+		}
+	}
+	return rules, nil
+}
+
+// syncPolicyRules applies the difference between configured and
+// required rules of a policy
+func (k8sNet *k8sContext) syncPolicyRules(policyName string, rules map[string]*client.Rule) error {
+	cfgRules, err := k8sNet.getPolicyRules(policyName)
+	if err != nil {
+		npLog.Errorf("failed to read rules of policy %s, %s", policyName, err)
+		return err
+	}
+
+	// rule-ids are derived from the rule content, a modified rule shows up
+	// as a delete of the old id followed by an add of the new one
+	for ruleID := range cfgRules {
+		if _, ok := rules[ruleID]; !ok {
+			if err := k8sNet.deleteRule(policyName, ruleID); err != nil {
+				return err
+			}
+		}
+	}
+
+	var retErr error
+	for ruleID, rule := range rules {
+		if _, ok := cfgRules[ruleID]; !ok {
+			if err := k8sNet.addRule(rule); err != nil {
+				// continue with the rest of the rules, failed
+				// rules are retried on next sync
+				retErr = err
+			}
+		}
+	}
+	return retErr
+}
+
+// getNetworkPolicyName returns contiv policy name of a k8s network policy
+func getNetworkPolicyName(np *v1.NetworkPolicy) string {
+	policyName := np.Namespace + "-np-" + np.Name
+	if len(policyName) > maxNameLength {
+		h := fnv.New32a()
+		h.Write([]byte(policyName))
+		policyName = fmt.Sprintf("%s-%08x", policyName[:maxNameLength-9], h.Sum32())
+	}
+	return policyName
+}
+
+// getNetworkPolicyKey returns the key of a network policy in local cache
+func getNetworkPolicyKey(np *v1.NetworkPolicy) string {
+	return np.Namespace + "/" + np.Name
+}
+
+// getContivIPAddress converts a k8s ip address/cidr to the format
+// accepted by contiv rules. An empty string matches any address,
+// false is returned for addresses contiv can not match (ipv6)
+func getContivIPAddress(addr string) (string, bool) {
+	ip, ipNet, err := net.ParseCIDR(addr)
+	if err != nil {
+		ip = net.ParseIP(addr)
+		if ip == nil || ip.To4() == nil {
+			return "", false
+		}
+		return ip.String(), true
+	}
+
+	if ip.To4() == nil {
+		return "", false
+	}
+
+	switch ones, _ := ipNet.Mask.Size(); ones {
+	case 0:
+		return "", true
+	case 32:
+		return ip.String(), true
+	default:
+		return ipNet.String(), true
+	}
+}
+
+// npPort is a protocol/port match of a network policy rule
+type npPort struct {
+	protocol string
+	port     int
+}
+
+// getNetworkPolicyPorts converts k8s network policy ports to contiv rule
+// protocol/ports, no ports match all traffic
+func getNetworkPolicyPorts(ports []v1.NetworkPolicyPort) []npPort {
+	if len(ports) == 0 {
+		return []npPort{{}}
+	}
+
+	npPorts := []npPort{}
+	for _, p := range ports {
+		protocol := "tcp"
+		if p.Protocol != nil {
+			protocol = strings.ToLower(string(*p.Protocol))
+		}
+
+		port := 0
+		if p.Port != nil {
+			if p.Port.Type != intstr.Int {
+				npLog.Warnf("named port %s is not supported, ignored", p.Port.String())
+				continue
+			}
+			port = p.Port.IntValue()
+		}
+		npPorts = append(npPorts, npPort{protocol: protocol, port: port})
+	}
+	return npPorts
+}
+
+// subtractCIDR returns the cidrs covering the addresses of block which
+// are not in except
+func subtractCIDR(block, except *net.IPNet) []*net.IPNet {
+	if !block.Contains(except.IP) && !except.Contains(block.IP) {
+		return []*net.IPNet{block}
+	}
+
+	blockOnes, bits := block.Mask.Size()
+	exceptOnes, _ := except.Mask.Size()
+	if exceptOnes <= blockOnes {
+		// except covers the whole block
+		return nil
+	}
+
+	// split the block along the prefixes of except, keeping the halves
+	// not containing it
+	cidrs := []*net.IPNet{}
+	for ones := blockOnes + 1; ones <= exceptOnes; ones++ {
+		mask := net.CIDRMask(ones, bits)
+		ip := except.IP.Mask(mask)
+		ip[(ones-1)/8] ^= 0x80 >> uint((ones-1)%8)
+		cidrs = append(cidrs, &net.IPNet{IP: ip, Mask: mask})
+	}
+	return cidrs
+}
+
+// getIPBlockAddresses converts a k8s ip block to contiv rule addresses,
+// the exceptions are left out of the addresses returned
+func getIPBlockAddresses(ipBlock *v1.IPBlock) []string {
+	if _, ok := getContivIPAddress(ipBlock.CIDR); !ok {
+		npLog.Warnf("ip block %s is not supported, ignored", ipBlock.CIDR)
+		return nil
+	}
+
+	_, block, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil {
+		// single ip address
+		addr, _ := getContivIPAddress(ipBlock.CIDR)
+		return []string{addr}
+	}
+	block.IP = block.IP.To4()
+
+	blocks := []*net.IPNet{block}
+	for _, exceptCIDR := range ipBlock.Except {
+		_, except, err := net.ParseCIDR(exceptCIDR)
+		if err != nil || except.IP.To4() == nil {
+			npLog.Warnf("ip block except %s is not supported, ignored", exceptCIDR)
+			continue
+		}
+		except.IP = except.IP.To4()
+
+		remaining := []*net.IPNet{}
+		for _, b := range blocks {
+			remaining = append(remaining, subtractCIDR(b, except)...)
+		}
+		blocks = remaining
+	}
+
+	addrs := []string{}
+	for _, b := range blocks {
+		if addr, ok := getContivIPAddress(b.String()); ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// getNetworkPolicyPeers converts k8s network policy peers to contiv rule
+// addresses, no peers match all addresses
+func getNetworkPolicyPeers(peers []v1.NetworkPolicyPeer,
+	podIPs func(peer *v1.NetworkPolicyPeer) []string) []string {
+	if len(peers) == 0 {
+		return []string{""}
+	}
+
+	npPeers := []string{}
+	for idx := range peers {
+		peer := &peers[idx]
+		if peer.IPBlock != nil {
+			npPeers = append(npPeers, getIPBlockAddresses(peer.IPBlock)...)
+			continue
+		}
+
+		for _, ip := range podIPs(peer) {
+			if addr, ok := getContivIPAddress(ip); ok {
+				npPeers = append(npPeers, addr)
+			}
+		}
+	}
+	return npPeers
+}
+
+// getNetworkPolicyTypes returns if the network policy applies to
+// ingress and egress traffic
+func getNetworkPolicyTypes(np *v1.NetworkPolicy) (bool, bool) {
+	if len(np.Spec.PolicyTypes) == 0 {
+		return true, len(np.Spec.Egress) > 0
+	}
+
+	ingress, egress := false, false
+	for _, t := range np.Spec.PolicyTypes {
+		switch t {
+		case v1.PolicyTypeIngress:
+			ingress = true
+		case v1.PolicyTypeEgress:
+			egress = true
+		}
+	}
+	return ingress, egress
+}
+
+// newNetworkPolicyRule creates a contiv rule, the rule-id is derived
+// from the rule content
+func newNetworkPolicyRule(policyName, direction, fromIP, toIP string,
+	port npPort, action string, priority int) *client.Rule {
+	h := fnv.New32a()
+	h.Write([]byte(fmt.Sprintf("%s|%s|%s|%s|%d|%s|%d", direction, fromIP, toIP,
+		port.protocol, port.port, action, priority)))
+
+	return &client.Rule{
+		TenantName:    defaultTenantName,
+		PolicyName:    policyName,
+		RuleID:        fmt.Sprintf("%08x", h.Sum32()),
+		Direction:     direction,
+		FromIpAddress: fromIP,
+		ToIpAddress:   toIP,
+		Protocol:      port.protocol,
+		Port:          port.port,
+		Action:        action,
+		Priority:      priority,
+	}
+}
+
+// buildNetworkPolicyRules translates a k8s network policy to contiv rules.
+// Ingress and egress rules are scoped to the ip address of each selected pod.
+func buildNetworkPolicyRules(np *v1.NetworkPolicy, policyName string, targetIPs []string,
+	podIPs func(peer *v1.NetworkPolicyPeer) []string) map[string]*client.Rule {
+	rules := make(map[string]*client.Rule)
+	addRule := func(rule *client.Rule) {
+		rules[rule.RuleID] = rule
+	}
+
+	targets := []string{}
+	for _, ip := range targetIPs {
+		if addr, ok := getContivIPAddress(ip); ok && addr != "" {
+			targets = append(targets, addr)
+		}
+	}
+	if len(targets) == 0 {
+		// policy doesn't select any pod
+		return rules
+	}
+
+	ingress, egress := getNetworkPolicyTypes(np)
+
+	if ingress {
+		for _, target := range targets {
+			// deny everything not allowed by the policy
+			addRule(newNetworkPolicyRule(policyName, "in", "", target, npPort{},
+				"deny", npDenyPriority))
+
+			for _, in := range np.Spec.Ingress {
+				ports := getNetworkPolicyPorts(in.Ports)
+				for _, peer := range getNetworkPolicyPeers(in.From, podIPs) {
+					for _, port := range ports {
+						addRule(newNetworkPolicyRule(policyName, "in", peer,
+							target, port, "allow", npAllowPriority))
+					}
+				}
+			}
+		}
+	}
+
+	if egress {
+		for _, target := range targets {
+			// deny everything not allowed by the policy
+			addRule(newNetworkPolicyRule(policyName, "out", target, "", npPort{},
+				"deny", npDenyPriority))
+
+			for _, out := range np.Spec.Egress {
+				ports := getNetworkPolicyPorts(out.Ports)
+				for _, peer := range getNetworkPolicyPeers(out.To, podIPs) {
+					for _, port := range ports {
+						addRule(newNetworkPolicyRule(policyName, "out", target,
+							peer, port, "allow", npAllowPriority))
+					}
+				}
+			}
+		}
+	}
+
+	return rules
+}
+
+// getPodIPs returns ip address of pods selected by pod and namespace selectors,
+// a nil namespace selector selects pods in namespace ns only
+func (k8sNet *k8sContext) getPodIPs(ns string, podSelector, nsSelector *meta_v1.LabelSelector) ([]string, error) {
+	namespaces := []string{ns}
+	if nsSelector != nil {
+		sel, err := meta_v1.LabelSelectorAsSelector(nsSelector)
+		if err != nil {
+			return nil, err
+		}
+
+		nsList, err := k8sNet.k8sClientSet.CoreV1().Namespaces().List(
+			meta_v1.ListOptions{LabelSelector: sel.String()})
+		if err != nil {
+			return nil, err
+		}
+
+		namespaces = []string{}
+		for _, n := range nsList.Items {
+			namespaces = append(namespaces, n.Name)
+		}
+	}
+
+	podOptions := meta_v1.ListOptions{}
+	if podSelector != nil {
+		sel, err := meta_v1.LabelSelectorAsSelector(podSelector)
+		if err != nil {
+			return nil, err
+		}
+		podOptions.LabelSelector = sel.String()
+	}
+
+	podIPs := []string{}
+	for _, n := range namespaces {
+		podList, err := k8sNet.k8sClientSet.CoreV1().Pods(n).List(podOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pod := range podList.Items {
+			if pod.Spec.HostNetwork || pod.Status.PodIP == "" {
+				continue
+			}
+			podIPs = append(podIPs, pod.Status.PodIP)
+		}
+	}
+	return podIPs, nil
+}
+
+// addNetworkPolicy creates or updates the contiv policy of a k8s network policy
+func (k8sNet *k8sContext) addNetworkPolicy(np *v1.NetworkPolicy) error {
+	nwName := np.Namespace + "-" + defaultNetworkName
+	epgName := np.Namespace + "-" + defaultEpgName
+	policyName := getNetworkPolicyName(np)
+
+	targetIPs, err := k8sNet.getPodIPs(np.Namespace, &np.Spec.PodSelector, nil)
+	if err != nil {
+		npLog.Errorf("failed to get pods of network policy %s, %s", policyName, err)
+		return err
+	}
+
+	// pods leaving the selectors are found by their ip address
+	policyIPs := make(map[string]bool)
+	for _, ip := range targetIPs {
+		policyIPs[ip] = true
+	}
+
+	rules := buildNetworkPolicyRules(np, policyName, targetIPs,
+		func(peer *v1.NetworkPolicyPeer) []string {
+			podIPs, err := k8sNet.getPodIPs(np.Namespace, peer.PodSelector, peer.NamespaceSelector)
+			if err != nil {
+				npLog.Errorf("failed to get pods of network policy %s peer, %s", policyName, err)
+			}
+			for _, ip := range podIPs {
+				policyIPs[ip] = true
+			}
+			return podIPs
+		})
+
+	if k8sNet.nwPolicyIPs == nil {
+		k8sNet.nwPolicyIPs = make(map[string]map[string]bool)
+	}
+	k8sNet.nwPolicyIPs[getNetworkPolicyKey(np)] = policyIPs
+
+	if err := k8sNet.createNetwork(nwName); err != nil {
+		npLog.Errorf("failed to update network %s, %s", nwName, err)
+		return err
+	}
+
+	if err := k8sNet.createPolicy(policyName); err != nil {
+		npLog.Errorf("failed to update policy %s, %s", policyName, err)
+		return err
+	}
+
+	if err := k8sNet.createEpg(nwName, epgName, policyName); err != nil {
+		npLog.Errorf("failed to update EPG %s, %s", epgName, err)
+		return err
+	}
+
+	// policy must be attached before adding the rules matching pod ip address
+	if err := k8sNet.attachPolicy(epgName, policyName); err != nil {
+		return err
+	}
+
+	return k8sNet.syncPolicyRules(policyName, rules)
+}
+
+// deleteNetworkPolicy removes all contiv objects derived from a k8s network policy
+func (k8sNet *k8sContext) deleteNetworkPolicy(np *v1.NetworkPolicy) error {
+	nwName := np.Namespace + "-" + defaultNetworkName
+	epgName := np.Namespace + "-" + defaultEpgName
+	policyName := getNetworkPolicyName(np)
+
+	if err := k8sNet.syncPolicyRules(policyName, map[string]*client.Rule{}); err != nil {
+		return err
+	}
+
+	numPolicies, err := k8sNet.detachPolicy(epgName, policyName)
+	if err != nil {
+		return err
+	}
+
+	if err := k8sNet.deletePolicy(policyName); err != nil {
+		return err
+	}
+
+	// remove the epg when nothing else is attached to it
+	if numPolicies == 0 {
+		return k8sNet.deleteEpg(nwName, epgName, policyName)
+	}
+	return nil
+}
+
+// selectorMatches checks if a label selector matches a set of labels,
+// a nil selector matches everything
+func selectorMatches(selector *meta_v1.LabelSelector, lbls map[string]string) bool {
+	if selector == nil {
+		return true
+	}
+
+	sel, err := meta_v1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return sel.Matches(labels.Set(lbls))
+}
+
+// networkPolicySelectsPod checks if a pod is selected by a network policy,
+// either as a target or as a peer of its rules
+func networkPolicySelectsPod(np *v1.NetworkPolicy, pod *core_v1.Pod, nsLabels map[string]string) bool {
+	if pod.Namespace == np.Namespace && selectorMatches(&np.Spec.PodSelector, pod.Labels) {
+		return true
+	}
+
+	peers := []v1.NetworkPolicyPeer{}
+	for _, in := range np.Spec.Ingress {
+		peers = append(peers, in.From...)
+	}
+	for _, out := range np.Spec.Egress {
+		peers = append(peers, out.To...)
+	}
+
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			continue
+		}
+		if peer.NamespaceSelector == nil {
+			if pod.Namespace != np.Namespace {
+				continue
+			}
+		} else if !selectorMatches(peer.NamespaceSelector, nsLabels) {
+			continue
+		}
+		if selectorMatches(peer.PodSelector, pod.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// resyncNetworkPolicies re-evaluates the network policies selecting a pod
+// or matching its ip address after pod changes
+func (k8sNet *k8sContext) resyncNetworkPolicies(pod *core_v1.Pod) {
+	nsLabels := map[string]string{}
+	if ns, err := k8sNet.k8sClientSet.CoreV1().Namespaces().Get(pod.Namespace,
+		meta_v1.GetOptions{}); err == nil {
+		nsLabels = ns.Labels
+	} else {
+		npLog.Errorf("failed to get namespace %s, %s", pod.Namespace, err)
+	}
+
+	for key, np := range k8sNet.nwPolicies {
+		if !k8sNet.nwPolicyIPs[key][pod.Status.PodIP] &&
+			!networkPolicySelectsPod(np, pod, nsLabels) {
+			continue
+		}
+
+		if err := k8sNet.addNetworkPolicy(np); err != nil {
+			npLog.Errorf("failed to sync network policy %s, %s", key, err)
+		}
+	}
+}
+
 func (k8sNet *k8sContext) processK8sNetworkPolicy(opCode watch.EventType, np *v1.NetworkPolicy) {
 	if np.Namespace == "kube-system" { // not applicable for system namespace
 		return
@@ -319,12 +911,39 @@ func (k8sNet *k8sContext) processK8sNetworkPolicy(opCode watch.EventType, np *v1
 
 	npLog.Infof("process [%s] network policy  %+v", opCode, np)
 
+	if k8sNet.nwPolicies == nil {
+		k8sNet.nwPolicies = make(map[string]*v1.NetworkPolicy)
+	}
+
 	switch opCode {
 	case watch.Added, watch.Modified:
+		k8sNet.nwPolicies[getNetworkPolicyKey(np)] = np
+		if err := k8sNet.addNetworkPolicy(np); err != nil {
+			npLog.Errorf("failed to add network policy %s, %s", getNetworkPolicyKey(np), err)
+		}
 	case watch.Deleted:
+		delete(k8sNet.nwPolicies, getNetworkPolicyKey(np))
+		delete(k8sNet.nwPolicyIPs, getNetworkPolicyKey(np))
+		if err := k8sNet.deleteNetworkPolicy(np); err != nil {
+			npLog.Errorf("failed to delete network policy %s, %s", getNetworkPolicyKey(np), err)
+		}
 	}
 }
 
+func (k8sNet *k8sContext) processK8sPod(opCode watch.EventType, pod *core_v1.Pod) {
+	if pod.Namespace == "kube-system" || pod.Spec.HostNetwork {
+		return
+	}
+
+	// pods without ip address don't change the rules
+	if opCode != watch.Deleted && pod.Status.PodIP == "" {
+		return
+	}
+
+	npLog.Debugf("process [%s] pod %s/%s", opCode, pod.Namespace, pod.Name)
+	k8sNet.resyncNetworkPolicies(pod)
+}
+
 func (k8sNet *k8sContext) processK8sEvent(opCode watch.EventType, eventObj interface{}) {
 	if k8sNet.isLeader() != true {
 		return
@@ -333,6 +952,9 @@ func (k8sNet *k8sContext) processK8sEvent(opCode watch.EventType, eventObj inter
 
 	case *v1.NetworkPolicy:
 		k8sNet.processK8sNetworkPolicy(opCode, objType)
+
+	case *core_v1.Pod:
+		k8sNet.processK8sPod(opCode, objType)
 	}
 }
 
@@ -353,10 +975,22 @@ func (k8sNet *k8sContext) watchK8sEvents(errChan chan error) {
 	selCase = append(selCase, reflect.SelectCase{Dir: reflect.SelectRecv,
 		Chan: reflect.ValueOf(npWatch.ResultChan())})
 
+	podWatch, err := k8sNet.k8sClientSet.CoreV1().Pods("").Watch(meta_v1.ListOptions{})
+	if err != nil {
+		npWatch.Stop()
+		errChan <- fmt.Errorf("failed to watch pods, %s", err)
+		return
+	}
+
+	selCase = append(selCase, reflect.SelectCase{Dir: reflect.SelectRecv,
+		Chan: reflect.ValueOf(podWatch.ResultChan())})
+
 	for {
 		_, recVal, ok := reflect.Select(selCase)
 		if !ok {
 			// channel closed, trigger restart
+			npWatch.Stop()
+			podWatch.Stop()
 			errChan <- fmt.Errorf("channel closed to k8s api server")
 			return
 		}
@@ -388,7 +1022,8 @@ func InitK8SServiceWatch(listenAddr string, isLeader func() bool) error {
 		npLog.Fatalf("failed to init K8S client, %v", err)
 		return err
 	}
-	kubeNet := k8sContext{contivClient: contivClient, k8sClientSet: k8sClientSet, isLeader: isLeader}
+	kubeNet := k8sContext{contivClient: contivClient, k8sClientSet: k8sClientSet, isLeader: isLeader,
+		nwPolicies: make(map[string]*v1.NetworkPolicy), nwPolicyIPs: make(map[string]map[string]bool)}
 
 	go kubeNet.handleK8sEvents()
 	return nil
@@ -16,6 +16,10 @@ import (
 	"github.com/gorilla/mux"
 	"golang.org/x/net/context"
 	"hash/fnv"
+	core_v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"net/http"
 	"os"
 	"testing"
@@ -105,6 +109,174 @@ func TestGetIsolationPolicy(t *testing.T) {
 	}
 }
 
+func TestGetContivIPAddress(t *testing.T) {
+	tData := []struct {
+		addr    string
+		contiv  string
+		isValid bool
+	}{
+		{"10.1.1.1", "10.1.1.1", true},
+		{"10.1.1.1/32", "10.1.1.1", true},
+		{"10.1.1.0/24", "10.1.1.0/24", true},
+		{"10.1.1.7/24", "10.1.1.0/24", true},
+		{"0.0.0.0/0", "", true},
+		{"2001:db8::/64", "", false},
+		{"abc", "", false},
+	}
+
+	for _, d := range tData {
+		addr, ok := getContivIPAddress(d.addr)
+		assertOnTrue(t, addr != d.contiv || ok != d.isValid,
+			fmt.Sprintf("received [%s, %v] expected %+v", addr, ok, d))
+	}
+}
+
+func TestBuildNetworkPolicyRules(t *testing.T) {
+	tcp := core_v1.ProtocolTCP
+	udp := core_v1.ProtocolUDP
+	port80 := intstr.FromInt(80)
+	port53 := intstr.FromInt(53)
+	namedPort := intstr.FromString("http")
+
+	np := &v1.NetworkPolicy{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: v1.NetworkPolicySpec{
+			Ingress: []v1.NetworkPolicyIngressRule{
+				{
+					Ports: []v1.NetworkPolicyPort{{Protocol: &tcp, Port: &port80},
+						{Protocol: &tcp, Port: &namedPort}},
+					From: []v1.NetworkPolicyPeer{
+						{PodSelector: &meta_v1.LabelSelector{}},
+						{IPBlock: &v1.IPBlock{CIDR: "172.16.0.0/16",
+							Except: []string{"172.16.1.0/24"}}},
+					},
+				},
+			},
+			Egress: []v1.NetworkPolicyEgressRule{
+				{
+					Ports: []v1.NetworkPolicyPort{{Protocol: &udp, Port: &port53}},
+				},
+			},
+		},
+	}
+
+	podIPs := func(peer *v1.NetworkPolicyPeer) []string {
+		return []string{"10.1.2.10"}
+	}
+
+	rules := buildNetworkPolicyRules(np, "default-np-web", []string{}, podIPs)
+	assertOnTrue(t, len(rules) != 0, fmt.Sprintf("expected no rules without pods, got %d", len(rules)))
+
+	rules = buildNetworkPolicyRules(np, "default-np-web", []string{"10.1.2.3", "10.1.2.4"}, podIPs)
+
+	type ruleMatch struct {
+		direction string
+		from      string
+		to        string
+		protocol  string
+		port      int
+		action    string
+		priority  int
+	}
+	expRules := []ruleMatch{}
+	for _, target := range []string{"10.1.2.3", "10.1.2.4"} {
+		expRules = append(expRules,
+			ruleMatch{"in", "", target, "", 0, "deny", npDenyPriority},
+			ruleMatch{"in", "10.1.2.10", target, "tcp", 80, "allow", npAllowPriority},
+			ruleMatch{"out", target, "", "", 0, "deny", npDenyPriority},
+			ruleMatch{"out", target, "", "udp", 53, "allow", npAllowPriority})
+
+		// the ip block is allowed without its exception
+		for _, block := range []string{"172.16.128.0/17", "172.16.64.0/18", "172.16.32.0/19",
+			"172.16.16.0/20", "172.16.8.0/21", "172.16.4.0/22", "172.16.2.0/23", "172.16.0.0/24"} {
+			expRules = append(expRules,
+				ruleMatch{"in", block, target, "tcp", 80, "allow", npAllowPriority})
+		}
+	}
+	assertOnTrue(t, len(rules) != len(expRules), fmt.Sprintf("expected %d rules, got %d", len(expRules), len(rules)))
+
+	for _, e := range expRules {
+		found := false
+		for _, r := range rules {
+			if (ruleMatch{r.Direction, r.FromIpAddress, r.ToIpAddress, r.Protocol,
+				r.Port, r.Action, r.Priority}) == e {
+				assertOnTrue(t, r.PolicyName != "default-np-web", fmt.Sprintf("invalid policy in %+v", r))
+				found = true
+			}
+		}
+		assertOnTrue(t, !found, fmt.Sprintf("rule %+v not found", e))
+	}
+
+	// rule-ids are stable across translations
+	for ruleID := range buildNetworkPolicyRules(np, "default-np-web", []string{"10.1.2.3", "10.1.2.4"}, podIPs) {
+		_, ok := rules[ruleID]
+		assertOnTrue(t, !ok, fmt.Sprintf("rule-id %s changed", ruleID))
+	}
+}
+
+func TestGetIPBlockAddresses(t *testing.T) {
+	tData := []struct {
+		cidr   string
+		except []string
+		addrs  []string
+	}{
+		{"10.1.0.0/16", nil, []string{"10.1.0.0/16"}},
+		{"10.1.0.0/16", []string{"10.2.0.0/24"}, []string{"10.1.0.0/16"}},
+		{"10.1.0.0/16", []string{"10.0.0.0/8"}, []string{}},
+		{"10.1.0.0/16", []string{"10.1.0.0/17"}, []string{"10.1.128.0/17"}},
+		{"10.1.0.0/24", []string{"10.1.0.4/30"},
+			[]string{"10.1.0.128/25", "10.1.0.64/26", "10.1.0.32/27", "10.1.0.16/28",
+				"10.1.0.8/29", "10.1.0.0/30"}},
+		{"10.1.0.0/24", []string{"10.1.0.0/25", "10.1.0.128/26"}, []string{"10.1.0.192/26"}},
+		{"0.0.0.0/0", []string{"128.0.0.0/1"}, []string{"0.0.0.0/1"}},
+		{"2001:db8::/64", nil, nil},
+	}
+
+	for _, d := range tData {
+		addrs := getIPBlockAddresses(&v1.IPBlock{CIDR: d.cidr, Except: d.except})
+		assertOnTrue(t, fmt.Sprint(addrs) != fmt.Sprint(d.addrs),
+			fmt.Sprintf("received %v expected %+v", addrs, d))
+	}
+}
+
+func TestNetworkPolicySelectsPod(t *testing.T) {
+	np := &v1.NetworkPolicy{
+		ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: v1.NetworkPolicySpec{
+			PodSelector: meta_v1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Ingress: []v1.NetworkPolicyIngressRule{
+				{
+					From: []v1.NetworkPolicyPeer{
+						{PodSelector: &meta_v1.LabelSelector{MatchLabels: map[string]string{"app": "client"}}},
+						{NamespaceSelector: &meta_v1.LabelSelector{MatchLabels: map[string]string{"env": "test"}}},
+						{IPBlock: &v1.IPBlock{CIDR: "172.16.0.0/16"}},
+					},
+				},
+			},
+		},
+	}
+
+	tData := []struct {
+		ns       string
+		labels   map[string]string
+		nsLabels map[string]string
+		selected bool
+	}{
+		{"default", map[string]string{"app": "web"}, nil, true},
+		{"default", map[string]string{"app": "client"}, nil, true},
+		{"default", map[string]string{"app": "db"}, nil, false},
+		{"other", map[string]string{"app": "web"}, nil, false},
+		{"other", map[string]string{"app": "client"}, map[string]string{"env": "prod"}, false},
+		{"other", map[string]string{"app": "db"}, map[string]string{"env": "test"}, true},
+	}
+
+	for _, d := range tData {
+		pod := &core_v1.Pod{ObjectMeta: meta_v1.ObjectMeta{Namespace: d.ns, Labels: d.labels}}
+		selected := networkPolicySelectsPod(np, pod, d.nsLabels)
+		assertOnTrue(t, selected != d.selected, fmt.Sprintf("received %v expected %+v", selected, d))
+	}
+}
+
 const (
 	netmasterTestURL       = "http://localhost:9230"
 	netmasterTestListenURL = ":9230"
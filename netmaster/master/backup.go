@@ -0,0 +1,324 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/objdb/modeldb"
+	"github.com/contiv/netplugin/utils"
+	"github.com/contiv/netplugin/utils/netutils"
+	"github.com/contiv/netplugin/version"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// BackupVersion is the version of the backup archive format
+const BackupVersion = 1
+
+// backupObjectTypes are the contiv model collections in a backup, every
+// collection of contivModel has to be listed
+var backupObjectTypes = []string{
+	"aciGw",
+	"addressSet",
+	"appProfile",
+	"Bgp",
+	"endpointGroup",
+	"extContractsGroup",
+	"global",
+	"mirrorSession",
+	"netprofile",
+	"network",
+	"policy",
+	"rule",
+	"serviceLB",
+	"tenant",
+	"volume",
+	"volumeProfile",
+}
+
+// Backup is an archive of the contiv model objects and the state records
+// of netmaster, like resource bitsets, IP allocations and endpoints. State
+// records keep their schema version, and are upgraded when restored.
+type Backup struct {
+	Version          int                          `json:"version"`
+	NetmasterVersion string                       `json:"netmasterVersion"`
+	Created          string                       `json:"created"`
+	Objects          map[string][]json.RawMessage `json:"objects"` // model objects by type
+	States           map[string][]json.RawMessage `json:"states"`  // state records by key prefix
+}
+
+// RestoreResponse is the result of a restore
+type RestoreResponse struct {
+	NumObjects int `json:"numObjects"`
+	NumStates  int `json:"numStates"`
+}
+
+// isKeyNotFound checks for the missing key errors of the objdb and state
+// store clients
+func isKeyNotFound(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "key not found")
+}
+
+// BackupHandler returns a backup of the cluster store
+func BackupHandler(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateBackup(stateDriver)
+}
+
+// RestoreHandler restores a backup into an empty cluster store
+func RestoreHandler(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	var backup Backup
+
+	// Get object from the request
+	err := json.NewDecoder(r.Body).Decode(&backup)
+	if err != nil {
+		log.Errorf("Error decoding RestoreHandler. Err %v", err)
+		return nil, err
+	}
+
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	return RestoreBackup(stateDriver, &backup)
+}
+
+// CreateBackup reads the model objects and the registered state records
+// from the cluster store. The keys are read one directory at a time, so no
+// configuration changes should be made while a backup is taken.
+func CreateBackup(stateDriver core.StateDriver) (*Backup, error) {
+	backup := &Backup{
+		Version:          BackupVersion,
+		NetmasterVersion: version.Get().Version,
+		Created:          time.Now().UTC().Format(time.RFC3339),
+		Objects:          make(map[string][]json.RawMessage),
+		States:           make(map[string][]json.RawMessage),
+	}
+
+	for _, objType := range backupObjectTypes {
+		objList, err := modeldb.ReadAllObj(objType)
+		if err != nil && !isKeyNotFound(err) {
+			log.Errorf("Error reading %s objects. Err: %v", objType, err)
+			return nil, err
+		}
+
+		for _, obj := range objList {
+			backup.Objects[objType] = append(backup.Objects[objType], json.RawMessage(obj))
+		}
+	}
+
+	for _, keyPrefix := range core.SchemaPrefixes() {
+		records, err := stateDriver.ReadAll(keyPrefix)
+		if err != nil && !isKeyNotFound(err) {
+			log.Errorf("Error reading state under %s. Err: %v", keyPrefix, err)
+			return nil, err
+		}
+
+		for _, record := range records {
+			backup.States[keyPrefix] = append(backup.States[keyPrefix], json.RawMessage(record))
+		}
+	}
+
+	log.Infof("Created backup of %d object types and %d state types",
+		len(backup.Objects), len(backup.States))
+
+	return backup, nil
+}
+
+// backupObject is a model object in a backup
+type backupObject struct {
+	objType string
+	key     string
+	value   json.RawMessage
+}
+
+// decodeBackup validates a backup, and returns its objects and states
+func decodeBackup(stateDriver core.StateDriver, backup *Backup) ([]backupObject, []core.State, error) {
+	if backup.Version != BackupVersion {
+		return nil, nil, core.Errorf("unsupported backup version %d, expecting %d",
+			backup.Version, BackupVersion)
+	}
+
+	knownTypes := make(map[string]bool)
+	for _, objType := range backupObjectTypes {
+		knownTypes[objType] = true
+	}
+
+	objects := []backupObject{}
+	for objType, objList := range backup.Objects {
+		if !knownTypes[objType] {
+			return nil, nil, core.Errorf("unknown object type %q in backup", objType)
+		}
+
+		for _, value := range objList {
+			obj := struct {
+				Key string `json:"key"`
+			}{}
+			if err := json.Unmarshal(value, &obj); err != nil || obj.Key == "" {
+				return nil, nil, core.Errorf("invalid %s object %s in backup", objType, value)
+			}
+			objects = append(objects, backupObject{objType: objType, key: obj.Key, value: value})
+		}
+	}
+
+	states := []core.State{}
+	for keyPrefix, records := range backup.States {
+		encodedStates := [][]byte{}
+		for _, record := range records {
+			encodedStates = append(encodedStates, record)
+		}
+
+		decoded, err := core.DecodeStates(stateDriver, keyPrefix, encodedStates)
+		if err != nil {
+			return nil, nil, err
+		}
+		states = append(states, decoded...)
+	}
+
+	if err := checkBackupAllocations(states); err != nil {
+		return nil, nil, err
+	}
+
+	return objects, states, nil
+}
+
+// checkBackupAllocations verifies that the endpoints in a backup are on its
+// networks, and that their addresses are allocated
+func checkBackupAllocations(states []core.State) error {
+	nwCfgs := make(map[string]*mastercfg.CfgNetworkState)
+	epgCfgs := make(map[string]*mastercfg.EndpointGroupState)
+	for _, state := range states {
+		switch s := state.(type) {
+		case *mastercfg.CfgNetworkState:
+			nwCfgs[s.ID] = s
+		case *mastercfg.EndpointGroupState:
+			epgCfgs[s.ID] = s
+		}
+	}
+
+	for _, state := range states {
+		epCfg, ok := state.(*mastercfg.CfgEndpointState)
+		if !ok {
+			continue
+		}
+
+		nwCfg, ok := nwCfgs[epCfg.NetID]
+		if !ok {
+			return core.Errorf("endpoint %s is on network %s, which is not in the backup",
+				epCfg.ID, epCfg.NetID)
+		}
+		if epCfg.IPAddress == "" || nwCfg.SubnetIP == "" {
+			continue
+		}
+
+		ipAddrValue, err := netutils.GetIPNumber(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, epCfg.IPAddress)
+		if err != nil {
+			return core.Errorf("endpoint %s has an invalid address %s for network %s",
+				epCfg.ID, epCfg.IPAddress, nwCfg.ID)
+		}
+
+		allocated := nwCfg.IPAllocMap.Test(ipAddrValue)
+		if epgCfg, ok := epgCfgs[epCfg.EndpointGroupKey]; ok && len(epgCfg.IPPool) > 0 {
+			allocated = epgCfg.EPGIPAllocMap.Test(ipAddrValue)
+		}
+		if !allocated {
+			return core.Errorf("address %s of endpoint %s is not allocated in network %s",
+				epCfg.IPAddress, epCfg.ID, nwCfg.ID)
+		}
+	}
+
+	return nil
+}
+
+// checkEmptyStore makes sure the cluster store has no networks or endpoints
+// a restore would overwrite
+func checkEmptyStore(stateDriver core.StateDriver) error {
+	objList, err := modeldb.ReadAllObj("network")
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+	if len(objList) > 0 {
+		return core.Errorf("cluster store has %d networks, backups are only restored on an empty cluster",
+			len(objList))
+	}
+
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = stateDriver
+	nwCfgs, err := nwCfg.ReadAll()
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+
+	epCfg := &mastercfg.CfgEndpointState{}
+	epCfg.StateDriver = stateDriver
+	epCfgs, err := epCfg.ReadAll()
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+
+	if len(nwCfgs) > 0 || len(epCfgs) > 0 {
+		return core.Errorf("cluster store has network or endpoint state, backups are only restored on an empty cluster")
+	}
+
+	return nil
+}
+
+// RestoreBackup validates a backup, and writes its states and objects into
+// an empty cluster store. Objects and states netmaster creates on startup,
+// like the global config and the default tenant, are overwritten. Netmaster
+// has to be restarted afterwards to load the restored objects.
+func RestoreBackup(stateDriver core.StateDriver, backup *Backup) (*RestoreResponse, error) {
+	objects, states, err := decodeBackup(stateDriver, backup)
+	if err != nil {
+		log.Errorf("Invalid backup. Err: %v", err)
+		return nil, err
+	}
+
+	if err := checkEmptyStore(stateDriver); err != nil {
+		return nil, err
+	}
+
+	// states are written first, so that the objects dont refer to missing
+	// allocations if the restore fails
+	for _, state := range states {
+		if err := state.Write(); err != nil {
+			log.Errorf("Error restoring state %+v. Err: %v", state, err)
+			return nil, err
+		}
+	}
+
+	for _, obj := range objects {
+		if err := modeldb.WriteObj(obj.objType, obj.key, &obj.value); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Infof("Restored %d objects and %d states from a backup created at %s by netmaster %s",
+		len(objects), len(states), backup.Created, backup.NetmasterVersion)
+
+	return &RestoreResponse{NumObjects: len(objects), NumStates: len(states)}, nil
+}
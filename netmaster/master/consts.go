@@ -24,4 +24,16 @@ const (
 	GetServiceRESTEndpoint = "service"
 	//GetServicesRESTEndpoint is the REST endpoint to request info of all services
 	GetServicesRESTEndpoint = "services"
+	// PolicySimulateRESTEndpoint is the REST endpoint to evaluate a flow against the policies
+	PolicySimulateRESTEndpoint = "policy/simulate"
+	// BackupRESTEndpoint is the REST endpoint to export a backup of the cluster store
+	BackupRESTEndpoint = "backup"
+	// RestoreRESTEndpoint is the REST endpoint to restore a backup into an empty cluster store
+	RestoreRESTEndpoint = "restore"
+	// DiagnoseRESTEndpoint is the REST endpoint to check the consistency of the cluster state
+	DiagnoseRESTEndpoint = "diagnose"
+	// DiagnoseRepairRESTEndpoint is the REST endpoint to check and repair the cluster state
+	DiagnoseRepairRESTEndpoint = "diagnose/repair"
+	// EncryptionRotateRESTEndpoint is the REST endpoint to rotate the tunnel encryption key
+	EncryptionRotateRESTEndpoint = "encryption/rotate"
 )
@@ -0,0 +1,749 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/contiv/netplugin/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/drivers"
+	"github.com/contiv/netplugin/netmaster/docknet"
+	"github.com/contiv/netplugin/netmaster/gstate"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/netmaster/resources"
+	"github.com/contiv/netplugin/objdb"
+	"github.com/contiv/netplugin/objdb/modeldb"
+	"github.com/contiv/netplugin/utils"
+	"github.com/contiv/netplugin/utils/netutils"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Kinds of issues found by Diagnose
+const (
+	IssueMissingNetworkState     = "missingNetworkState"     // network object without state
+	IssueOrphanNetworkState      = "orphanNetworkState"      // network state without an object
+	IssueMissingGroupState       = "missingGroupState"       // endpoint group object without state
+	IssueOrphanGroupState        = "orphanGroupState"        // endpoint group state without an object
+	IssueOrphanEndpoint          = "orphanEndpoint"          // endpoint on a missing network or group
+	IssueEpCountMismatch         = "epCountMismatch"         // endpoint count differs from the endpoints
+	IssueUnallocatedAddress      = "unallocatedAddress"      // endpoint address free in the ip bitset
+	IssueUnallocatedPktTag       = "unallocatedPktTag"       // network vlan or vxlan free in the resource bitset
+	IssueOrphanDocknet           = "orphanDocknet"           // docker network of a missing network or group
+	IssueStaleOperEndpoint       = "staleOperEndpoint"       // agent endpoint state without an endpoint
+	IssueStaleDatapathEndpoint   = "staleDatapathEndpoint"   // agent datapath endpoint without an endpoint
+	IssueMissingDatapathEndpoint = "missingDatapathEndpoint" // endpoint missing from its agent's datapath
+)
+
+// DiagnoseIssue is an inconsistency between the contiv model objects, the
+// netmaster state and the state of the agents
+type DiagnoseIssue struct {
+	Kind        string `json:"kind"`
+	Object      string `json:"object"`
+	Message     string `json:"message"`
+	Repairable  bool   `json:"repairable"`
+	Repaired    bool   `json:"repaired"`
+	RepairError string `json:"repairError,omitempty"`
+
+	repair func() error
+}
+
+// DiagnoseReport is the result of a consistency check
+type DiagnoseReport struct {
+	Issues            []*DiagnoseIssue `json:"issues"`
+	NumRepaired       int              `json:"numRepaired"`
+	UnreachableAgents []string         `json:"unreachableAgents"`
+}
+
+// issueList sorts issues by kind and object
+type issueList []*DiagnoseIssue
+
+func (l issueList) Len() int      { return len(l) }
+func (l issueList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l issueList) Less(i, j int) bool {
+	if l[i].Kind != l[j].Kind {
+		return l[i].Kind < l[j].Kind
+	}
+	return l[i].Object < l[j].Object
+}
+
+// diagnosis holds the objects and states a consistency check compares. The
+// model objects are keyed by the ID of their state.
+type diagnosis struct {
+	stateDriver core.StateDriver
+	agents      []objdb.ServiceInfo
+	networks    map[string]*contivModel.Network
+	groups      map[string]*contivModel.EndpointGroup
+	nwCfgs      map[string]*mastercfg.CfgNetworkState
+	epgCfgs     map[string]*mastercfg.EndpointGroupState
+	epCfgs      map[string]*mastercfg.CfgEndpointState
+	epOpers     map[string]*drivers.OperEndpointState
+	dnetOpers   []*docknet.DnetOperState
+	vlanOper    *resources.AutoVLANOperResource
+	vxlanOper   *resources.AutoVXLANOperResource
+	vxlanStart  uint
+	issues      []*DiagnoseIssue
+}
+
+// newDiagnosis returns an empty diagnosis
+func newDiagnosis(stateDriver core.StateDriver, agents []objdb.ServiceInfo) *diagnosis {
+	return &diagnosis{
+		stateDriver: stateDriver,
+		agents:      agents,
+		networks:    make(map[string]*contivModel.Network),
+		groups:      make(map[string]*contivModel.EndpointGroup),
+		nwCfgs:      make(map[string]*mastercfg.CfgNetworkState),
+		epgCfgs:     make(map[string]*mastercfg.EndpointGroupState),
+		epCfgs:      make(map[string]*mastercfg.CfgEndpointState),
+		epOpers:     make(map[string]*drivers.OperEndpointState),
+		issues:      []*DiagnoseIssue{},
+	}
+}
+
+// addIssue records an issue. repair is nil for issues that can't be
+// repaired automatically.
+func (d *diagnosis) addIssue(kind, object string, repair func() error, format string, args ...interface{}) {
+	d.issues = append(d.issues, &DiagnoseIssue{
+		Kind:       kind,
+		Object:     object,
+		Message:    fmt.Sprintf(format, args...),
+		Repairable: repair != nil,
+		repair:     repair,
+	})
+}
+
+// Diagnose cross-checks the contiv model objects, the netmaster state, the
+// resource bitsets, the docker network state and the endpoints of the
+// agents. When repair is set, the issues that can be fixed are repaired;
+// nothing else should change the configuration while it runs.
+func Diagnose(stateDriver core.StateDriver, agents []objdb.ServiceInfo, repair bool) (*DiagnoseReport, error) {
+	d := newDiagnosis(stateDriver, agents)
+	if err := d.load(); err != nil {
+		log.Errorf("Error reading the cluster state. Err: %v", err)
+		return nil, err
+	}
+
+	d.checkNetworks()
+	d.checkGroups()
+	d.checkEndpoints()
+	d.checkPktTags()
+	d.checkDocknets()
+	d.checkOperEndpoints()
+	unreachable := d.checkAgents()
+
+	sort.Sort(issueList(d.issues))
+	report := &DiagnoseReport{Issues: d.issues, UnreachableAgents: unreachable}
+
+	if repair {
+		for _, issue := range report.Issues {
+			if issue.repair == nil {
+				continue
+			}
+
+			if err := issue.repair(); err != nil {
+				log.Errorf("Error repairing %s %s. Err: %v", issue.Kind, issue.Object, err)
+				issue.RepairError = err.Error()
+				continue
+			}
+
+			log.Infof("Repaired %s %s", issue.Kind, issue.Object)
+			issue.Repaired = true
+			report.NumRepaired++
+		}
+	}
+
+	return report, nil
+}
+
+// load reads the model objects and the states
+func (d *diagnosis) load() error {
+	objList, err := modeldb.ReadAllObj("network")
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+	for _, obj := range objList {
+		network := &contivModel.Network{}
+		if err := json.Unmarshal([]byte(obj), network); err != nil {
+			return err
+		}
+		d.networks[mastercfg.GetNwCfgKey(network.NetworkName, network.TenantName)] = network
+	}
+
+	objList, err = modeldb.ReadAllObj("endpointGroup")
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+	for _, obj := range objList {
+		group := &contivModel.EndpointGroup{}
+		if err := json.Unmarshal([]byte(obj), group); err != nil {
+			return err
+		}
+		d.groups[mastercfg.GetEndpointGroupKey(group.GroupName, group.TenantName)] = group
+	}
+
+	if err := d.loadEndpointStates(); err != nil {
+		return err
+	}
+
+	epOper := &drivers.OperEndpointState{}
+	epOper.StateDriver = d.stateDriver
+	epOpers, err := epOper.ReadAll()
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+	for _, state := range epOpers {
+		epOper := state.(*drivers.OperEndpointState)
+		d.epOpers[epOper.ID] = epOper
+	}
+
+	dnetOper := &docknet.DnetOperState{}
+	dnetOper.StateDriver = d.stateDriver
+	dnetOpers, err := dnetOper.ReadAll()
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+	for _, state := range dnetOpers {
+		d.dnetOpers = append(d.dnetOpers, state.(*docknet.DnetOperState))
+	}
+
+	// the resources are missing until the global config is created
+	vlanOper := &resources.AutoVLANOperResource{}
+	vlanOper.StateDriver = d.stateDriver
+	if err := vlanOper.Read("global"); err == nil {
+		d.vlanOper = vlanOper
+	} else if !isKeyNotFound(err) {
+		return err
+	}
+
+	vxlanOper := &resources.AutoVXLANOperResource{}
+	vxlanOper.StateDriver = d.stateDriver
+	if err := vxlanOper.Read("global"); err == nil {
+		d.vxlanOper = vxlanOper
+	} else if !isKeyNotFound(err) {
+		return err
+	}
+
+	gOper := &gstate.Oper{}
+	gOper.StateDriver = d.stateDriver
+	if err := gOper.Read(""); err == nil {
+		d.vxlanStart = gOper.FreeVXLANsStart
+	} else if !isKeyNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// loadEndpointStates reads the network, endpoint group and endpoint states
+func (d *diagnosis) loadEndpointStates() error {
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = d.stateDriver
+	nwCfgs, err := nwCfg.ReadAll()
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+	for _, state := range nwCfgs {
+		nwCfg := state.(*mastercfg.CfgNetworkState)
+		d.nwCfgs[nwCfg.ID] = nwCfg
+	}
+
+	epgCfg := &mastercfg.EndpointGroupState{}
+	epgCfg.StateDriver = d.stateDriver
+	epgCfgs, err := epgCfg.ReadAll()
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+	for _, state := range epgCfgs {
+		epgCfg := state.(*mastercfg.EndpointGroupState)
+		d.epgCfgs[epgCfg.ID] = epgCfg
+	}
+
+	epCfg := &mastercfg.CfgEndpointState{}
+	epCfg.StateDriver = d.stateDriver
+	epCfgs, err := epCfg.ReadAll()
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+	for _, state := range epCfgs {
+		epCfg := state.(*mastercfg.CfgEndpointState)
+		d.epCfgs[epCfg.ID] = epCfg
+	}
+
+	return nil
+}
+
+// checkNetworks compares the network objects with their states
+func (d *diagnosis) checkNetworks() {
+	for id, network := range d.networks {
+		if _, ok := d.nwCfgs[id]; !ok {
+			d.addIssue(IssueMissingNetworkState, network.Key, nil,
+				"network has no state %s, delete and create it again", id)
+		}
+	}
+
+	for id := range d.nwCfgs {
+		if _, ok := d.networks[id]; !ok {
+			d.addIssue(IssueOrphanNetworkState, id, nil,
+				"network state has no network object")
+		}
+	}
+}
+
+// checkGroups compares the endpoint group objects with their states
+func (d *diagnosis) checkGroups() {
+	for id, group := range d.groups {
+		if _, ok := d.epgCfgs[id]; !ok {
+			d.addIssue(IssueMissingGroupState, group.Key, nil,
+				"endpoint group has no state %s, delete and create it again", id)
+		}
+	}
+
+	for id := range d.epgCfgs {
+		if _, ok := d.groups[id]; !ok {
+			d.addIssue(IssueOrphanGroupState, id, nil,
+				"endpoint group state has no endpoint group object")
+		}
+	}
+}
+
+// checkEndpoints checks that the endpoints are on existing networks and
+// groups, that their addresses are allocated, and that the endpoint counts
+// of the networks and groups match the endpoints
+func (d *diagnosis) checkEndpoints() {
+	for _, epCfg := range d.epCfgs {
+		nwCfg, ok := d.nwCfgs[epCfg.NetID]
+		if !ok {
+			d.addIssue(IssueOrphanEndpoint, epCfg.ID, d.clearEndpoint(epCfg, nil),
+				"endpoint is on network %s, which has no state", epCfg.NetID)
+			continue
+		}
+
+		var epgCfg *mastercfg.EndpointGroupState
+		if epCfg.EndpointGroupKey != "" {
+			epgCfg, ok = d.epgCfgs[epCfg.EndpointGroupKey]
+			if !ok {
+				d.addIssue(IssueOrphanEndpoint, epCfg.ID, d.clearEndpoint(epCfg, nwCfg),
+					"endpoint is in endpoint group %s, which has no state", epCfg.EndpointGroupKey)
+				continue
+			}
+		}
+
+		d.checkEndpointAddress(epCfg, nwCfg, epgCfg)
+	}
+
+	nwCounts, epgCounts := d.endpointCounts()
+	for id, nwCfg := range d.nwCfgs {
+		if nwCfg.EpCount != nwCounts[id] {
+			d.addIssue(IssueEpCountMismatch, id, d.setNetworkEpCount(id),
+				"network endpoint count is %d, it has %d endpoints", nwCfg.EpCount, nwCounts[id])
+		}
+	}
+
+	for id, epgCfg := range d.epgCfgs {
+		if epgCfg.EpCount != epgCounts[id] {
+			d.addIssue(IssueEpCountMismatch, id, d.setGroupEpCount(id),
+				"endpoint group endpoint count is %d, it has %d endpoints", epgCfg.EpCount, epgCounts[id])
+		}
+	}
+}
+
+// endpointCounts returns the number of endpoints on each network and in
+// each endpoint group. Endpoints on a network or in a group without state
+// are not counted, they are removed by their own repair.
+func (d *diagnosis) endpointCounts() (map[string]int, map[string]int) {
+	nwCounts := make(map[string]int)
+	epgCounts := make(map[string]int)
+
+	for _, epCfg := range d.epCfgs {
+		if _, ok := d.nwCfgs[epCfg.NetID]; !ok {
+			continue
+		}
+		if epCfg.EndpointGroupKey != "" {
+			if _, ok := d.epgCfgs[epCfg.EndpointGroupKey]; !ok {
+				continue
+			}
+			epgCounts[epCfg.EndpointGroupKey]++
+		}
+		nwCounts[epCfg.NetID]++
+	}
+
+	return nwCounts, epgCounts
+}
+
+// checkEndpointAddress checks that the address of an endpoint is allocated
+// in its network, or in the ip pool of its endpoint group
+func (d *diagnosis) checkEndpointAddress(epCfg *mastercfg.CfgEndpointState, nwCfg *mastercfg.CfgNetworkState,
+	epgCfg *mastercfg.EndpointGroupState) {
+	if epCfg.IPAddress == "" || nwCfg.SubnetIP == "" {
+		return
+	}
+
+	ipAddrValue, err := netutils.GetIPNumber(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, epCfg.IPAddress)
+	if err != nil {
+		d.addIssue(IssueUnallocatedAddress, epCfg.ID, nil,
+			"endpoint address %s is not in the subnet of network %s", epCfg.IPAddress, nwCfg.ID)
+		return
+	}
+
+	if epgCfg != nil && len(epgCfg.IPPool) > 0 {
+		if !epgCfg.EPGIPAllocMap.Test(ipAddrValue) {
+			d.addIssue(IssueUnallocatedAddress, epCfg.ID, d.reserveAddress(nwCfg, epgCfg, ipAddrValue),
+				"endpoint address %s is not allocated in the ip pool of endpoint group %s",
+				epCfg.IPAddress, epgCfg.ID)
+		}
+		return
+	}
+
+	if !nwCfg.IPAllocMap.Test(ipAddrValue) {
+		d.addIssue(IssueUnallocatedAddress, epCfg.ID, d.reserveAddress(nwCfg, nil, ipAddrValue),
+			"endpoint address %s is not allocated in network %s", epCfg.IPAddress, nwCfg.ID)
+	}
+}
+
+// checkPktTags checks that the vlans and vxlans of the networks and groups
+// are allocated in the resource bitsets
+func (d *diagnosis) checkPktTags() {
+	for id, nwCfg := range d.nwCfgs {
+		switch nwCfg.PktTagType {
+		case "vlan":
+			d.checkVLAN(id, uint(nwCfg.PktTag))
+		case "vxlan", "geneve":
+			if d.vxlanOper == nil || uint(nwCfg.ExtPktTag) < d.vxlanStart {
+				continue
+			}
+			vxlan := uint(nwCfg.ExtPktTag) - d.vxlanStart
+			if d.vxlanOper.FreeVXLANs.Test(vxlan) || d.vxlanOper.FreeLocalVLANs.Test(uint(nwCfg.PktTag)) {
+				d.addIssue(IssueUnallocatedPktTag, id, d.reserveVXLAN(vxlan, uint(nwCfg.PktTag)),
+					"vxlan %d or local vlan %d of the network is free", nwCfg.ExtPktTag, nwCfg.PktTag)
+			}
+		}
+	}
+
+	// groups only have their own vlans in aci mode, otherwise they have
+	// the vlan of their network
+	for id, epgCfg := range d.epgCfgs {
+		if epgCfg.PktTagType == "vlan" {
+			d.checkVLAN(id, uint(epgCfg.PktTag))
+		}
+	}
+}
+
+// checkVLAN checks that the vlan of a network or group is allocated
+func (d *diagnosis) checkVLAN(id string, vlan uint) {
+	if d.vlanOper == nil || vlan == 0 || !d.vlanOper.FreeVLANs.Test(vlan) {
+		return
+	}
+
+	d.addIssue(IssueUnallocatedPktTag, id, d.reserveVLAN(vlan), "vlan %d is free", vlan)
+}
+
+// checkDocknets checks that the docker networks are of existing networks
+// and groups
+func (d *diagnosis) checkDocknets() {
+	for _, dnetOper := range d.dnetOpers {
+		nwID := mastercfg.GetNwCfgKey(dnetOper.NetworkName, dnetOper.TenantName)
+		epgID := mastercfg.GetEndpointGroupKey(dnetOper.ServiceName, dnetOper.TenantName)
+
+		if _, ok := d.nwCfgs[nwID]; !ok {
+			d.addIssue(IssueOrphanDocknet, dnetOper.ID, d.deleteDocknet(dnetOper),
+				"docker network is of network %s, which has no state", nwID)
+		} else if _, ok := d.epgCfgs[epgID]; epgID != "" && !ok {
+			d.addIssue(IssueOrphanDocknet, dnetOper.ID, d.deleteDocknet(dnetOper),
+				"docker network is of endpoint group %s, which has no state", epgID)
+		}
+	}
+}
+
+// checkOperEndpoints checks that the endpoints the agents created are
+// still configured
+func (d *diagnosis) checkOperEndpoints() {
+	for id, epOper := range d.epOpers {
+		if _, ok := d.epCfgs[id]; !ok {
+			d.addIssue(IssueStaleOperEndpoint, id, d.reclaimEndpoint(epOper),
+				"agent on host %s has an endpoint that is not configured", epOper.HomingHost)
+		}
+	}
+}
+
+// checkAgents compares the local endpoints in the datapath of each agent
+// with the endpoints homed on its host. It returns the hosts of the agents
+// that couldn't be inspected.
+func (d *diagnosis) checkAgents() []string {
+	unreachable := []string{}
+
+	for _, agent := range d.agents {
+		localAddrs, err := getAgentEndpoints(agent.HostAddr)
+		if err != nil {
+			log.Errorf("Error inspecting agent on host %s. Err: %v", agent.Hostname, err)
+			unreachable = append(unreachable, agent.Hostname)
+			continue
+		}
+		if localAddrs == nil {
+			// driver without an ofnet datapath
+			continue
+		}
+
+		cfgAddrs := make(map[string]bool)
+		for _, epCfg := range d.epCfgs {
+			nwCfg, ok := d.nwCfgs[epCfg.NetID]
+			if epCfg.HomingHost != agent.Hostname || epCfg.IPAddress == "" ||
+				!ok || nwCfg.NwType == "infra" {
+				continue
+			}
+
+			cfgAddrs[epCfg.IPAddress] = true
+			if !localAddrs[epCfg.IPAddress] {
+				d.addIssue(IssueMissingDatapathEndpoint, epCfg.ID, nil,
+					"endpoint %s is not in the datapath of the agent on host %s",
+					epCfg.IPAddress, agent.Hostname)
+			}
+		}
+
+		for ipAddr := range localAddrs {
+			if !cfgAddrs[ipAddr] {
+				d.addIssue(IssueStaleDatapathEndpoint, agent.Hostname+"/"+ipAddr, nil,
+					"agent has a local endpoint %s that is not configured on its host", ipAddr)
+			}
+		}
+	}
+
+	return unreachable
+}
+
+// agentSwitchState is the part of the ofnet state in the inspect output of
+// an agent the consistency check uses
+type agentSwitchState struct {
+	LocalIP    string `json:"LocalIp"`
+	EndpointDb map[string]struct {
+		IPAddr       string `json:"IpAddr"`
+		OriginatorIP string `json:"OriginatorIp"`
+	}
+}
+
+// agentTimeout limits the time to get the endpoints of an agent, so that a
+// hung agent cant block the diagnosis
+const agentTimeout = 10 * time.Second
+
+// agentClient is the http client used to get the endpoints of the agents
+var agentClient = &http.Client{Timeout: agentTimeout}
+
+// getAgentEndpoints returns the addresses of the local endpoints in the
+// datapath of the agent on a host. It returns nil if the agent's driver has
+// no ofnet datapath.
+func getAgentEndpoints(hostAddr string) (map[string]bool, error) {
+	url := "http://" + hostAddr + ":9090/inspect/driver"
+	r, err := agentClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	response, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, core.Errorf("%s: %s", r.Status, response)
+	}
+
+	driverState := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(response, &driverState); err != nil {
+		return nil, err
+	}
+
+	var localAddrs map[string]bool
+	for _, switchName := range []string{"vlan", "vxlan"} {
+		switchJSON, ok := driverState[switchName]
+		if !ok {
+			continue
+		}
+
+		var switchState agentSwitchState
+		if err := json.Unmarshal(switchJSON, &switchState); err != nil {
+			return nil, err
+		}
+
+		if localAddrs == nil {
+			localAddrs = make(map[string]bool)
+		}
+		for _, ep := range switchState.EndpointDb {
+			if ep.OriginatorIP == switchState.LocalIP && ep.IPAddr != "" {
+				localAddrs[ep.IPAddr] = true
+			}
+		}
+	}
+
+	return localAddrs, nil
+}
+
+// clearEndpoint returns a repair that removes an endpoint on a missing
+// network or group, and frees its address if its network exists
+func (d *diagnosis) clearEndpoint(epCfg *mastercfg.CfgEndpointState,
+	nwCfg *mastercfg.CfgNetworkState) func() error {
+	return func() error {
+		if nwCfg != nil && epCfg.IPAddress != "" && nwCfg.SubnetIP != "" {
+			if err := networkReleaseAddress(nwCfg, nil, epCfg.IPAddress); err != nil {
+				return err
+			}
+		}
+
+		return epCfg.Clear()
+	}
+}
+
+// recountEndpoints returns the endpoint counts of the networks and endpoint
+// groups in the state store
+func recountEndpoints(sd core.StateDriver) (map[string]int, map[string]int, error) {
+	d := newDiagnosis(sd, nil)
+	if err := d.loadEndpointStates(); err != nil {
+		return nil, nil, err
+	}
+
+	nwCounts, epgCounts := d.endpointCounts()
+	return nwCounts, epgCounts, nil
+}
+
+// setNetworkEpCount returns a repair that sets the endpoint count of a
+// network. The endpoints are counted again when it runs, and the count is
+// only written if the network wasnt changed meanwhile.
+func (d *diagnosis) setNetworkEpCount(id string) func() error {
+	return func() error {
+		return core.UpdateStates(d.stateDriver, func(txn core.StateDriver) error {
+			nwCfg := &mastercfg.CfgNetworkState{}
+			nwCfg.StateDriver = txn
+			if err := nwCfg.Read(id); err != nil {
+				return err
+			}
+
+			nwCounts, _, err := recountEndpoints(txn)
+			if err != nil {
+				return err
+			}
+			if nwCfg.EpCount == nwCounts[id] {
+				return nil
+			}
+
+			nwCfg.EpCount = nwCounts[id]
+			return nwCfg.Write()
+		})
+	}
+}
+
+// setGroupEpCount returns a repair that sets the endpoint count of an
+// endpoint group. The endpoints are counted again when it runs, and the
+// count is only written if the group wasnt changed meanwhile.
+func (d *diagnosis) setGroupEpCount(id string) func() error {
+	return func() error {
+		return core.UpdateStates(d.stateDriver, func(txn core.StateDriver) error {
+			epgCfg := &mastercfg.EndpointGroupState{}
+			epgCfg.StateDriver = txn
+			if err := epgCfg.Read(id); err != nil {
+				return err
+			}
+
+			_, epgCounts, err := recountEndpoints(txn)
+			if err != nil {
+				return err
+			}
+			if epgCfg.EpCount == epgCounts[id] {
+				return nil
+			}
+
+			epgCfg.EpCount = epgCounts[id]
+			return epgCfg.Write()
+		})
+	}
+}
+
+// reserveAddress returns a repair that marks an endpoint address as
+// allocated in its network, or in the ip pool of epgCfg if it is set
+func (d *diagnosis) reserveAddress(nwCfg *mastercfg.CfgNetworkState, epgCfg *mastercfg.EndpointGroupState,
+	ipAddrValue uint) func() error {
+	return func() error {
+		return updateNetworkState(nwCfg, epgCfg, func(nwCfg *mastercfg.CfgNetworkState,
+			epgCfg *mastercfg.EndpointGroupState) error {
+			if epgCfg != nil {
+				epgCfg.EPGIPAllocMap.Set(ipAddrValue)
+				return epgCfg.Write()
+			}
+
+			nwCfg.IPAllocMap.Set(ipAddrValue)
+			return nwCfg.Write()
+		})
+	}
+}
+
+// reserveVLAN returns a repair that marks a vlan as allocated
+func (d *diagnosis) reserveVLAN(vlan uint) func() error {
+	return func() error {
+		return core.UpdateStates(d.stateDriver, func(txn core.StateDriver) error {
+			oper := &resources.AutoVLANOperResource{}
+			oper.StateDriver = txn
+			if err := oper.Read("global"); err != nil {
+				return err
+			}
+
+			oper.FreeVLANs.Clear(vlan)
+			return oper.Write()
+		})
+	}
+}
+
+// reserveVXLAN returns a repair that marks a vxlan and its local vlan as
+// allocated
+func (d *diagnosis) reserveVXLAN(vxlan, localVLAN uint) func() error {
+	return func() error {
+		return core.UpdateStates(d.stateDriver, func(txn core.StateDriver) error {
+			oper := &resources.AutoVXLANOperResource{}
+			oper.StateDriver = txn
+			if err := oper.Read("global"); err != nil {
+				return err
+			}
+
+			oper.FreeVXLANs.Clear(vxlan)
+			oper.FreeLocalVLANs.Clear(localVLAN)
+			return oper.Write()
+		})
+	}
+}
+
+// deleteDocknet returns a repair that removes an orphaned docker network
+func (d *diagnosis) deleteDocknet(dnetOper *docknet.DnetOperState) func() error {
+	return func() error {
+		return docknet.DeleteDockNet(dnetOper.TenantName, dnetOper.NetworkName, dnetOper.ServiceName)
+	}
+}
+
+// reclaimEndpoint returns a repair that asks the agent on the host of a
+// stale endpoint to delete it
+func (d *diagnosis) reclaimEndpoint(epOper *drivers.OperEndpointState) func() error {
+	return func() error {
+		for _, agent := range d.agents {
+			if agent.Hostname == epOper.HomingHost {
+				epDelURL := "http://" + agent.HostAddr + ":9090/debug/reclaimEndpoint/" + epOper.ID
+				return utils.HTTPDel(epDelURL)
+			}
+		}
+
+		return core.Errorf("no agent is running on host %s", epOper.HomingHost)
+	}
+}
@@ -0,0 +1,143 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/utils"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// encryptionMutex serializes the updates of the encryption state
+var encryptionMutex sync.Mutex
+
+// GetEncryption returns the encryption state of the tunnels, defaulting to
+// no encryption when it was never set
+func GetEncryption(stateDriver core.StateDriver) (*mastercfg.CfgEncryptionState, error) {
+	encCfg := &mastercfg.CfgEncryptionState{}
+	encCfg.StateDriver = stateDriver
+	err := encCfg.Read(mastercfg.EncryptionStateID)
+	if err != nil {
+		if core.ErrIfKeyExists(err) != nil {
+			return nil, err
+		}
+		encCfg.ID = mastercfg.EncryptionStateID
+		encCfg.Mode = mastercfg.EncryptionModeNone
+	}
+
+	return encCfg, nil
+}
+
+// SetEncryption sets the encryption mode of the tunnels between the hosts.
+// Enabling IPsec starts a new key generation, the hosts keep their keys as
+// long as the mode doesn't change.
+func SetEncryption(stateDriver core.StateDriver, mode string) error {
+	encryptionMutex.Lock()
+	defer encryptionMutex.Unlock()
+
+	encCfg, err := GetEncryption(stateDriver)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case mastercfg.EncryptionModeIPsec:
+		if encCfg.Mode == mode {
+			return nil
+		}
+		encCfg.KeyGeneration++
+	case mastercfg.EncryptionModeNone:
+		if encCfg.Mode == mode {
+			return nil
+		}
+	default:
+		return core.Errorf("invalid encryption mode %q", mode)
+	}
+
+	log.Infof("Setting tunnel encryption to %s, key generation %d", mode, encCfg.KeyGeneration)
+
+	encCfg.Mode = mode
+	return encCfg.Write()
+}
+
+// RotateEncryptionKey starts a new key generation. The hosts publish new
+// keys for it, and move the tunnel to a peer to the new generation once
+// both ends have seen the new key of the other. The old keys are kept until
+// every peer has moved to the new generation.
+func RotateEncryptionKey(stateDriver core.StateDriver) (*mastercfg.CfgEncryptionState, error) {
+	encryptionMutex.Lock()
+	defer encryptionMutex.Unlock()
+
+	encCfg, err := GetEncryption(stateDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	if encCfg.Mode != mastercfg.EncryptionModeIPsec {
+		return nil, core.Errorf("tunnel encryption is not enabled")
+	}
+
+	encCfg.KeyGeneration++
+
+	log.Infof("Rotating the tunnel encryption keys, key generation %d", encCfg.KeyGeneration)
+
+	if err := encCfg.Write(); err != nil {
+		return nil, err
+	}
+
+	return encCfg, nil
+}
+
+// DeleteEncryption removes the encryption state
+func DeleteEncryption(stateDriver core.StateDriver) error {
+	encryptionMutex.Lock()
+	defer encryptionMutex.Unlock()
+
+	encCfg := &mastercfg.CfgEncryptionState{}
+	encCfg.StateDriver = stateDriver
+	if err := encCfg.Read(mastercfg.EncryptionStateID); err != nil {
+		return nil
+	}
+
+	return encCfg.Clear()
+}
+
+// EncryptionRotateResponse is the response of a key rotation
+type EncryptionRotateResponse struct {
+	Mode          string `json:"mode"`
+	KeyGeneration int    `json:"key-generation"`
+}
+
+// RotateEncryptionKeyHandler is the REST handler rotating the tunnel keys
+func RotateEncryptionKeyHandler(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	encCfg, err := RotateEncryptionKey(stateDriver)
+	if err != nil {
+		log.Errorf("Error rotating the encryption key. Err: %v", err)
+		return nil, err
+	}
+
+	return &EncryptionRotateResponse{Mode: encCfg.Mode, KeyGeneration: encCfg.KeyGeneration}, nil
+}
@@ -160,17 +160,10 @@ func CreateEndpoint(stateDriver core.StateDriver, nwCfg *mastercfg.CfgNetworkSta
 		if epCfg.EndpointGroupKey != "" {
 			epgCfg := &mastercfg.EndpointGroupState{}
 			epgCfg.StateDriver = stateDriver
-			err = epgCfg.Read(epCfg.EndpointGroupKey)
+			epgCfg.ID = epCfg.EndpointGroupKey
+			err = epgCfg.IncrEpCount()
 			if err != nil {
-				log.Errorf("Error reading Epg info for EP: %+v. Error: %v", ep, err)
-				return nil, err
-			}
-
-			epgCfg.EpCount++
-
-			err = epgCfg.Write()
-			if err != nil {
-				log.Errorf("Error saving epg state: %+v", epgCfg)
+				log.Errorf("Error updating Epg info for EP: %+v. Error: %v", ep, err)
 				return nil, err
 			}
 		}
@@ -209,6 +202,7 @@ func CreateEndpoints(stateDriver core.StateDriver, tenant *intent.ConfigTenant)
 			return err
 		}
 
+		// CreateEndpoint saves the network changes it makes
 		for _, ep := range network.Endpoints {
 			epReq := CreateEndpointRequest{}
 			epReq.ConfigEP = ep
@@ -218,12 +212,6 @@ func CreateEndpoints(stateDriver core.StateDriver, tenant *intent.ConfigTenant)
 				return err
 			}
 		}
-
-		err = nwCfg.Write()
-		if err != nil {
-			log.Errorf("error writing nw config. Error: %s", err)
-			return err
-		}
 	}
 
 	return err
@@ -319,25 +307,15 @@ func DeleteEndpointID(stateDriver core.StateDriver, epID string) (*mastercfg.Cfg
 		if epCfg.EndpointGroupKey != "" {
 			epgCfg := &mastercfg.EndpointGroupState{}
 			epgCfg.StateDriver = stateDriver
-			err = epgCfg.Read(epCfg.EndpointGroupKey)
+			epgCfg.ID = epCfg.EndpointGroupKey
+			err = epgCfg.DecrEpCount()
 			if err != nil {
-				log.Errorf("Error reading EPG for endpoint: %+v", epCfg)
-			}
-
-			epgCfg.EpCount--
-
-			// write updated epg state
-			err = epgCfg.Write()
-			if err != nil {
-				log.Errorf("error writing epg config. Error: %s", err)
+				log.Errorf("error updating epg config for endpoint: %+v. Error: %s", epCfg, err)
 			}
 		}
 
 		// decrement ep count
-		nwCfg.EpCount--
-
-		// write modified nw state
-		err = nwCfg.Write()
+		err = nwCfg.DecrEpCount()
 		if err != nil {
 			log.Errorf("error writing nw config. Error: %s", err)
 		}
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"strconv"
 
+	"github.com/contiv/netplugin/contivmodel"
 	"github.com/contiv/netplugin/core"
 	"github.com/contiv/netplugin/netmaster/docknet"
 	"github.com/contiv/netplugin/netmaster/gstate"
@@ -164,10 +165,18 @@ func CreateEndpointGroup(tenantName, networkName, groupName, ipPool, cfgdTag str
 	}
 
 	if len(ipPool) > 0 {
-		// mark range as used
-		netutils.SetIPAddrRange(&nwCfg.IPAllocMap, ipPool, nwCfg.SubnetIP, nwCfg.SubnetLen)
-
-		if err := nwCfg.Write(); err != nil {
+		// mark range as used, checking again that no address in it was
+		// allocated meanwhile
+		err := updateNetworkState(nwCfg, nil, func(nwCfg *mastercfg.CfgNetworkState,
+			_ *mastercfg.EndpointGroupState) error {
+			if err := netutils.TestIPAddrRange(&nwCfg.IPAllocMap, ipPool, nwCfg.SubnetIP,
+				nwCfg.SubnetLen); err != nil {
+				return err
+			}
+			netutils.SetIPAddrRange(&nwCfg.IPAllocMap, ipPool, nwCfg.SubnetIP, nwCfg.SubnetLen)
+			return nwCfg.Write()
+		})
+		if err != nil {
 			return fmt.Errorf("updating epg ipaddress in network failed: %s", err)
 		}
 		netutils.InitSubnetBitset(&epgCfg.EPGIPAllocMap, nwCfg.SubnetLen)
@@ -235,8 +244,12 @@ func DeleteEndpointGroup(tenantName, groupName string) error {
 
 	// mark it as unused
 	if len(epgCfg.IPPool) > 0 {
-		netutils.ClearIPAddrRange(&nwCfg.IPAllocMap, epgCfg.IPPool, nwCfg.SubnetIP, nwCfg.SubnetLen)
-		if err = nwCfg.Write(); err != nil {
+		err = updateNetworkState(nwCfg, nil, func(nwCfg *mastercfg.CfgNetworkState,
+			_ *mastercfg.EndpointGroupState) error {
+			netutils.ClearIPAddrRange(&nwCfg.IPAllocMap, epgCfg.IPPool, nwCfg.SubnetIP, nwCfg.SubnetLen)
+			return nwCfg.Write()
+		})
+		if err != nil {
 			log.Errorf("error writing nw config after releasing subnet. Error: %v", err)
 			return err
 		}
@@ -255,8 +268,9 @@ func DeleteEndpointGroup(tenantName, groupName string) error {
 	return nil
 }
 
-//UpdateEndpointGroup updates the endpointgroups
-func UpdateEndpointGroup(bandwidth, groupName, tenantName string, Dscp, burst int) error {
+//UpdateEndpointGroup updates the endpointgroups with the netprofile attached
+//to them, a nil netprofile detaches it
+func UpdateEndpointGroup(groupName, tenantName string, profile *contivModel.Netprofile) error {
 
 	// Get the state driver - get the etcd driver state
 	stateDriver, err := utils.GetStateDriver()
@@ -280,9 +294,15 @@ func UpdateEndpointGroup(bandwidth, groupName, tenantName string, Dscp, burst in
 	}
 
 	//update the epGroup state
-	epCfg.DSCP = Dscp
-	epCfg.Bandwidth = bandwidth
-	epCfg.Burst = burst
+	if profile == nil {
+		profile = &contivModel.Netprofile{}
+	}
+	epCfg.DSCP = profile.DSCP
+	epCfg.Bandwidth = profile.Bandwidth
+	epCfg.Burst = profile.Burst
+	epCfg.IngressRate = profile.IngressRate
+	epCfg.EgressRate = profile.EgressRate
+	epCfg.EgressMinRate = profile.EgressMinRate
 
 	//Write to etcd
 	return epCfg.Write()
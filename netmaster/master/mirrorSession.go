@@ -0,0 +1,115 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package master
+
+import (
+	"github.com/contiv/netplugin/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// validateMirrorSession checks that a mirror session selects its endpoints
+// one way and has a destination matching its type
+func validateMirrorSession(session *contivModel.MirrorSession) error {
+	selectors := 0
+	if len(session.Endpoints) != 0 {
+		selectors++
+	}
+	if session.EndpointGroup != "" {
+		selectors++
+	}
+	if session.NetworkName != "" {
+		selectors++
+	}
+	if selectors != 1 {
+		return core.Errorf("mirror session must select either endpoints, an endpoint group or a network")
+	}
+
+	switch session.DestinationType {
+	case mastercfg.MirrorDestLocal:
+		if session.DestinationPort == "" {
+			return core.Errorf("local mirror session requires a destination port")
+		}
+		if session.CollectorIp != "" {
+			return core.Errorf("local mirror session can't have a collector")
+		}
+	case mastercfg.MirrorDestGRE, mastercfg.MirrorDestERSPAN:
+		if session.CollectorIp == "" {
+			return core.Errorf("%s mirror session requires a collector IP address", session.DestinationType)
+		}
+		if session.DestinationPort != "" {
+			return core.Errorf("%s mirror session can't have a destination port", session.DestinationType)
+		}
+	default:
+		return core.Errorf("invalid mirror destination type %q", session.DestinationType)
+	}
+
+	if session.ErspanId != 0 && session.DestinationType != mastercfg.MirrorDestERSPAN {
+		return core.Errorf("erspan id is only valid for erspan mirror sessions")
+	}
+	if session.DestinationType == mastercfg.MirrorDestERSPAN && session.ErspanId == 0 {
+		return core.Errorf("erspan mirror session requires an erspan id")
+	}
+
+	return nil
+}
+
+// CreateMirrorSession writes the state of a mirror session, the netplugins
+// program it on the hosts of the mirrored endpoints. An existing session is
+// updated.
+func CreateMirrorSession(stateDriver core.StateDriver, session *contivModel.MirrorSession) error {
+	if err := validateMirrorSession(session); err != nil {
+		return err
+	}
+
+	direction := session.Direction
+	if direction == "" {
+		direction = mastercfg.MirrorDirectionBoth
+	}
+
+	mirrorCfg := &mastercfg.CfgMirrorSessionState{
+		Tenant:          session.TenantName,
+		Endpoints:       session.Endpoints,
+		EndpointGroup:   session.EndpointGroup,
+		Network:         session.NetworkName,
+		Direction:       direction,
+		DestinationType: session.DestinationType,
+		DestinationPort: session.DestinationPort,
+		CollectorIP:     session.CollectorIp,
+		ErspanID:        session.ErspanId,
+	}
+	mirrorCfg.StateDriver = stateDriver
+	mirrorCfg.ID = mastercfg.GetMirrorSessionKey(session.TenantName, session.SessionName)
+
+	log.Infof("Writing mirror session %s: %+v", mirrorCfg.ID, mirrorCfg)
+
+	return mirrorCfg.Write()
+}
+
+// DeleteMirrorSession removes the state of a mirror session
+func DeleteMirrorSession(stateDriver core.StateDriver, tenantName, sessionName string) error {
+	mirrorCfg := &mastercfg.CfgMirrorSessionState{}
+	mirrorCfg.StateDriver = stateDriver
+	err := mirrorCfg.Read(mastercfg.GetMirrorSessionKey(tenantName, sessionName))
+	if err != nil {
+		log.Errorf("Error reading mirror session %s:%s. Err: %v", tenantName, sessionName, err)
+		return err
+	}
+
+	return mirrorCfg.Clear()
+}
@@ -146,6 +146,13 @@ func CreateGlobal(stateDriver core.StateDriver, gc *intent.ConfigGlobal) error {
 		masterGc.PvtSubnet = gc.PvtSubnet
 	}
 
+	if gc.Encryption != "" {
+		err = SetEncryption(stateDriver, gc.Encryption)
+		if err != nil {
+			return err
+		}
+	}
+
 	if len(gcfgUpdateList) > 0 {
 		// Delete old state
 
@@ -248,6 +255,13 @@ func UpdateGlobal(stateDriver core.StateDriver, gc *intent.ConfigGlobal) error {
 		masterGc.PvtSubnet = gc.PvtSubnet
 	}
 
+	if gc.Encryption != "" {
+		err = SetEncryption(stateDriver, gc.Encryption)
+		if err != nil {
+			return err
+		}
+	}
+
 	if len(gcfgUpdateList) > 0 {
 		// Delete old state
 
@@ -316,7 +330,7 @@ func DeleteGlobal(stateDriver core.StateDriver) error {
 		}
 	}
 
-	return nil
+	return DeleteEncryption(stateDriver)
 }
 
 // CreateTenant sets the tenant's state according to the passed ConfigTenant.
@@ -17,12 +17,14 @@ package master
 
 import (
 	"encoding/json"
+	"sort"
 	"strings"
 	"testing"
 
 	log "github.com/Sirupsen/logrus"
 
 	"fmt"
+	"github.com/contiv/netplugin/contivmodel"
 	"github.com/contiv/netplugin/core"
 	"github.com/contiv/netplugin/netmaster/gstate"
 	"github.com/contiv/netplugin/netmaster/intent"
@@ -30,6 +32,8 @@ import (
 	"github.com/contiv/netplugin/netmaster/resources"
 	"github.com/contiv/netplugin/state"
 	"github.com/contiv/netplugin/utils"
+	"github.com/contiv/netplugin/utils/netutils"
+	"github.com/jainvipin/bitset"
 )
 
 var fakeDriver *state.FakeStateDriver
@@ -258,6 +262,182 @@ func TestVxlanConfig(t *testing.T) {
 	verifyKeys(t, keys)
 }
 
+func TestGeneveConfig(t *testing.T) {
+	cfgBytes := []byte(`{
+    "Tenants" : [{
+        "Name"                  : "tenant-one",
+        "Networks"  : [{
+            "Name"              : "orange",
+            "PktTagType"        : "geneve",
+            "PktTag"            : 2000,
+            "SubnetCIDR"        : "10.1.1.1/24",
+            "Gateway"           : "10.1.1.254"
+        },
+        {
+            "Name"              : "purple",
+            "PktTagType"        : "vxlan",
+            "PktTag"            : 2001,
+            "SubnetCIDR"        : "10.1.2.1/24",
+            "Gateway"           : "10.1.2.254"
+        }]
+    }]}`)
+
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	applyConfig(t, cfgBytes)
+
+	// geneve networks get a vni and a local vlan, like vxlan networks
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = fakeDriver
+	if err := nwCfg.Read("orange.tenant-one"); err != nil {
+		t.Fatalf("unable to locate network orange. Err: %v", err)
+	}
+	if nwCfg.PktTagType != "geneve" || nwCfg.ExtPktTag != 2000 || nwCfg.PktTag == 0 {
+		t.Fatalf("geneve network state {%+v} is not allocated", nwCfg)
+	}
+
+	purpleCfg := &mastercfg.CfgNetworkState{}
+	purpleCfg.StateDriver = fakeDriver
+	if err := purpleCfg.Read("purple.tenant-one"); err != nil {
+		t.Fatalf("unable to locate network purple. Err: %v", err)
+	}
+	if purpleCfg.PktTag == nwCfg.PktTag {
+		t.Fatalf("geneve and vxlan networks share local vlan %d", nwCfg.PktTag)
+	}
+
+	// the vni is freed with the network
+	if _, err := resources.NewStateResourceManager(fakeDriver); err != nil {
+		log.Fatalf("state store initialization failed. Error: %s", err)
+	}
+	defer func() { resources.ReleaseStateResourceManager() }()
+
+	if err := DeleteNetworkID(fakeDriver, "orange.tenant-one"); err != nil {
+		t.Fatalf("error '%s' deleting network orange", err)
+	}
+	gCfg := &gstate.Cfg{}
+	gCfg.StateDriver = fakeDriver
+	if err := gCfg.Read(""); err != nil {
+		t.Fatalf("error '%s' reading global config", err)
+	}
+	if _, _, err := gCfg.AllocVXLAN(2000); err != nil {
+		t.Fatalf("vxlan 2000 of the deleted geneve network is not free. Err: %v", err)
+	}
+}
+
+func TestEncryptionConfig(t *testing.T) {
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	if _, err := RotateEncryptionKey(fakeDriver); err == nil {
+		t.Fatalf("key rotation succeeded without encryption")
+	}
+
+	if err := SetEncryption(fakeDriver, "wireguard"); err == nil {
+		t.Fatalf("invalid encryption mode was accepted")
+	}
+
+	if err := SetEncryption(fakeDriver, mastercfg.EncryptionModeIPsec); err != nil {
+		t.Fatalf("error enabling encryption. Err: %v", err)
+	}
+	encCfg, err := GetEncryption(fakeDriver)
+	if err != nil {
+		t.Fatalf("error reading encryption state. Err: %v", err)
+	}
+	if !encCfg.Enabled() || encCfg.KeyGeneration != 1 {
+		t.Fatalf("encryption state {%+v} is not enabled", encCfg)
+	}
+
+	// enabling it again keeps the keys
+	if err := SetEncryption(fakeDriver, mastercfg.EncryptionModeIPsec); err != nil {
+		t.Fatalf("error enabling encryption. Err: %v", err)
+	}
+	sameCfg, err := GetEncryption(fakeDriver)
+	if err != nil || sameCfg.KeyGeneration != 1 {
+		t.Fatalf("encryption key generation changed {%+v}. Err: %v", sameCfg, err)
+	}
+
+	rotatedCfg, err := RotateEncryptionKey(fakeDriver)
+	if err != nil {
+		t.Fatalf("error rotating the key. Err: %v", err)
+	}
+	if rotatedCfg.KeyGeneration != 2 {
+		t.Fatalf("key was not rotated {%+v}", rotatedCfg)
+	}
+
+	if err := SetEncryption(fakeDriver, mastercfg.EncryptionModeNone); err != nil {
+		t.Fatalf("error disabling encryption. Err: %v", err)
+	}
+	encCfg, err = GetEncryption(fakeDriver)
+	if err != nil || encCfg.Enabled() {
+		t.Fatalf("encryption state {%+v} is not disabled. Err: %v", encCfg, err)
+	}
+
+	// enabling it again starts a new key generation
+	if err := SetEncryption(fakeDriver, mastercfg.EncryptionModeIPsec); err != nil {
+		t.Fatalf("error enabling encryption. Err: %v", err)
+	}
+	encCfg, err = GetEncryption(fakeDriver)
+	if err != nil || !encCfg.Enabled() || encCfg.KeyGeneration != 3 {
+		t.Fatalf("encryption state {%+v} is not enabled with a new key generation. Err: %v", encCfg, err)
+	}
+
+	if err := DeleteEncryption(fakeDriver); err != nil {
+		t.Fatalf("error deleting encryption state. Err: %v", err)
+	}
+	verifyKeysDoNotExist(t, []string{"encryption/" + mastercfg.EncryptionStateID})
+}
+
+func TestMirrorSessionConfig(t *testing.T) {
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	invalid := []contivModel.MirrorSession{
+		{TenantName: "default", SessionName: "tap", DestinationType: "local", DestinationPort: "ids0"},
+		{TenantName: "default", SessionName: "tap", EndpointGroup: "web", NetworkName: "net1",
+			DestinationType: "local", DestinationPort: "ids0"},
+		{TenantName: "default", SessionName: "tap", EndpointGroup: "web", DestinationType: "local"},
+		{TenantName: "default", SessionName: "tap", EndpointGroup: "web", DestinationType: "gre"},
+		{TenantName: "default", SessionName: "tap", EndpointGroup: "web", DestinationType: "gre",
+			CollectorIp: "10.1.1.1", ErspanId: 10},
+		{TenantName: "default", SessionName: "tap", EndpointGroup: "web", DestinationType: "erspan",
+			CollectorIp: "10.1.1.1"},
+	}
+	for _, session := range invalid {
+		if err := CreateMirrorSession(fakeDriver, &session); err == nil {
+			t.Fatalf("invalid mirror session {%+v} was accepted", session)
+		}
+	}
+
+	session := &contivModel.MirrorSession{
+		TenantName:      "default",
+		SessionName:     "tap",
+		EndpointGroup:   "web",
+		DestinationType: "erspan",
+		CollectorIp:     "10.1.1.1",
+		ErspanId:        10,
+	}
+	if err := CreateMirrorSession(fakeDriver, session); err != nil {
+		t.Fatalf("error creating mirror session. Err: %v", err)
+	}
+	verifyKeys(t, []string{"mirrorSession/default:tap"})
+
+	mirrorCfg := &mastercfg.CfgMirrorSessionState{}
+	mirrorCfg.StateDriver = fakeDriver
+	if err := mirrorCfg.Read("default:tap"); err != nil {
+		t.Fatalf("error reading mirror session state. Err: %v", err)
+	}
+	if mirrorCfg.Direction != mastercfg.MirrorDirectionBoth || mirrorCfg.ErspanID != 10 ||
+		mirrorCfg.CollectorIP != "10.1.1.1" {
+		t.Fatalf("unexpected mirror session state {%+v}", mirrorCfg)
+	}
+
+	if err := DeleteMirrorSession(fakeDriver, "default", "tap"); err != nil {
+		t.Fatalf("error deleting mirror session. Err: %v", err)
+	}
+	verifyKeysDoNotExist(t, []string{"mirrorSession/default:tap"})
+}
+
 func TestVxlanConfigWithLateHostBindings(t *testing.T) {
 	cfgBytes := []byte(`{
     "Tenants" : [{
@@ -690,6 +870,147 @@ func TestGetAllocatedIPs(t *testing.T) {
 	}
 }
 
+func TestUpdateNetwork(t *testing.T) {
+	cfgBytes := []byte(`{
+    "Tenants" : [{
+        "Name"                      : "teaone",
+        "Networks"  : [{
+            "Name"                : "orange",
+            "SubnetCIDR"          : "10.1.1.0/24",
+            "Gateway"             : "10.1.1.254",
+            "Endpoints" : [{
+                "Container"       : "myContainer1"
+            },
+            {
+                "Container"       : "myContainer2"
+            },
+            {
+                "Container"       : "myContainer3"
+            }]
+        },
+        {
+            "Name"                : "apple",
+            "SubnetCIDR"          : "10.2.1.0/24",
+            "Gateway"             : "10.2.1.254"
+        }]
+    }]}`)
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	applyConfig(t, cfgBytes)
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = fakeDriver
+	networkID := "orange.teaone"
+
+	cfg := &intent.Config{}
+	if err := json.Unmarshal(cfgBytes, cfg); err != nil {
+		t.Fatalf("error '%s' parsing config '%s'\n", err, cfgBytes)
+	}
+	network := cfg.Tenants[0].Networks[0]
+	network.Endpoints = nil
+
+	// gateway colliding with an endpoint address
+	network.SubnetCIDR = "10.1.0.0/23"
+	network.Gateway = "10.1.1.1"
+	if err := UpdateNetwork(network, fakeDriver, "teaone"); err == nil {
+		t.Fatalf("network update with gateway %s succeeded", network.Gateway)
+	}
+
+	// range leaving out allocated endpoint addresses
+	network.SubnetCIDR = "10.1.1.10-10.1.1.20/24"
+	network.Gateway = "10.1.1.254"
+	if err := UpdateNetwork(network, fakeDriver, "teaone"); err == nil {
+		t.Fatalf("network update with range %s succeeded", network.SubnetCIDR)
+	}
+
+	// grow the subnet and move the gateway
+	network.SubnetCIDR = "10.1.0.0/23"
+	network.Gateway = "10.1.0.1"
+	network.CfgdTag = "orange-net"
+	if err := UpdateNetwork(network, fakeDriver, "teaone"); err != nil {
+		t.Fatalf("error '%s' updating network", err)
+	}
+
+	if err := nwCfg.Read(networkID); err != nil {
+		t.Fatalf("unable to locate network: %s", networkID)
+	}
+	if nwCfg.SubnetIP != "10.1.0.0" || nwCfg.SubnetLen != 23 || nwCfg.Gateway != "10.1.0.1" ||
+		nwCfg.NetworkTag != "orange-net" {
+		t.Fatalf("network state {%+v} did not match the update", nwCfg)
+	}
+
+	expectedAllocedIPs := "10.1.0.1, 10.1.1.1-10.1.1.3"
+	allocedIPs := ListAllocatedIPs(nwCfg)
+	if allocedIPs != expectedAllocedIPs {
+		t.Fatalf("got allocated ips '%s' expected '%s'", allocedIPs, expectedAllocedIPs)
+	}
+
+	// tag used by another network
+	network.CfgdTag = "apple.teaone"
+	if err := UpdateNetwork(network, fakeDriver, "teaone"); err == nil {
+		t.Fatalf("network update with tag %s succeeded", network.CfgdTag)
+	}
+	network.CfgdTag = "orange-net"
+
+	// encap can not be changed
+	network.PktTagType = "vxlan"
+	if err := UpdateNetwork(network, fakeDriver, "teaone"); err == nil {
+		t.Fatalf("network update with encap %s succeeded", network.PktTagType)
+	}
+}
+
+func TestNetworkMtu(t *testing.T) {
+	cfgBytes := []byte(`{
+    "Tenants" : [{
+        "Name"                      : "teaone",
+        "Networks"  : [{
+            "Name"                : "orange",
+            "PktTagType"          : "vxlan",
+            "SubnetCIDR"          : "10.1.1.0/24",
+            "Mtu"                 : 1400
+        }]
+    }]}`)
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	applyConfig(t, cfgBytes)
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = fakeDriver
+	if err := nwCfg.Read("orange.teaone"); err != nil {
+		t.Fatalf("unable to locate network orange.teaone. Err: %v", err)
+	}
+	if nwCfg.Mtu != 1400 {
+		t.Fatalf("network mtu %d didn't match the configured mtu", nwCfg.Mtu)
+	}
+
+	network := intent.ConfigNetwork{
+		Name:       "apple",
+		PktTagType: "vxlan",
+		SubnetCIDR: "10.1.2.0/24",
+		Mtu:        500,
+	}
+	if err := CreateNetwork(network, fakeDriver, "teaone"); err == nil {
+		t.Fatalf("network create with mtu %d succeeded", network.Mtu)
+	}
+
+	network.IPv6SubnetCIDR = "2016:0630::/120"
+	network.Mtu = 1200
+	if err := CreateNetwork(network, fakeDriver, "teaone"); err == nil {
+		t.Fatalf("IPv6 network create with mtu %d succeeded", network.Mtu)
+	}
+
+	// the mtu of the endpoints can not be changed
+	network = intent.ConfigNetwork{
+		Name:       "orange",
+		PktTagType: "vxlan",
+		SubnetCIDR: "10.1.1.0/24",
+		Mtu:        1450,
+	}
+	if err := UpdateNetwork(network, fakeDriver, "teaone"); err == nil {
+		t.Fatalf("network update with mtu %d succeeded", network.Mtu)
+	}
+}
+
 func assertOnTrue(t *testing.T, c bool, msg string) {
 	if c {
 		t.Fatalf("%s", msg)
@@ -716,3 +1037,167 @@ func TestGetNwAndEpgFromAddrReq(t *testing.T) {
 		assertOnTrue(t, e != d.epgName, fmt.Sprintf("epgname mismatch [%s] != [%s]", e, d.epgName))
 	}
 }
+
+func TestCheckBackupAllocations(t *testing.T) {
+	nwCfg := &mastercfg.CfgNetworkState{SubnetIP: "10.1.1.0", SubnetLen: 24}
+	nwCfg.ID = "net1.default"
+	nwCfg.IPAllocMap = *bitset.New(256)
+	nwCfg.IPAllocMap.Set(5)
+
+	epCfg := &mastercfg.CfgEndpointState{NetID: nwCfg.ID, IPAddress: "10.1.1.5"}
+	epCfg.ID = "ep1"
+	if err := checkBackupAllocations([]core.State{nwCfg, epCfg}); err != nil {
+		t.Fatalf("Backup with allocated endpoint addresses failed the check. Error: %s", err)
+	}
+
+	epCfg.IPAddress = "10.1.1.6"
+	if err := checkBackupAllocations([]core.State{nwCfg, epCfg}); err == nil {
+		t.Fatalf("Backup with an unallocated endpoint address passed the check")
+	}
+
+	epCfg.NetID = "net2.default"
+	if err := checkBackupAllocations([]core.State{nwCfg, epCfg}); err == nil {
+		t.Fatalf("Backup with an endpoint on a missing network passed the check")
+	}
+}
+
+func TestDiagnoseEndpoints(t *testing.T) {
+	d := newDiagnosis(nil, nil)
+
+	nwCfg := &mastercfg.CfgNetworkState{SubnetIP: "10.1.1.0", SubnetLen: 24, EpCount: 1}
+	nwCfg.ID = "net1.default"
+	nwCfg.IPAllocMap = *bitset.New(256)
+	nwCfg.IPAllocMap.Set(5)
+	d.nwCfgs[nwCfg.ID] = nwCfg
+
+	epCfg := &mastercfg.CfgEndpointState{NetID: nwCfg.ID, IPAddress: "10.1.1.5"}
+	epCfg.ID = "ep1"
+	d.epCfgs[epCfg.ID] = epCfg
+
+	d.checkEndpoints()
+	if len(d.issues) != 0 {
+		t.Fatalf("Consistent endpoints reported issues: %+v", d.issues[0])
+	}
+
+	orphanEp := &mastercfg.CfgEndpointState{NetID: "net2.default", IPAddress: "10.1.2.5"}
+	orphanEp.ID = "ep2"
+	d.epCfgs[orphanEp.ID] = orphanEp
+	epCfg.IPAddress = "10.1.1.6"
+
+	d.checkEndpoints()
+	sort.Sort(issueList(d.issues))
+	if len(d.issues) != 2 ||
+		d.issues[0].Kind != IssueOrphanEndpoint || d.issues[0].Object != "ep2" || !d.issues[0].Repairable ||
+		d.issues[1].Kind != IssueUnallocatedAddress || d.issues[1].Object != "ep1" {
+		t.Fatalf("Unexpected issues for an orphan endpoint and an unallocated address: %+v", d.issues)
+	}
+
+	d.issues = []*DiagnoseIssue{}
+	nwCfg.EpCount = 3
+	epCfg.IPAddress = "10.1.1.5"
+	d.checkEndpoints()
+	if len(d.issues) != 2 || d.issues[1].Kind != IssueEpCountMismatch || d.issues[1].Object != nwCfg.ID {
+		t.Fatalf("Endpoint count mismatch was not reported: %+v", d.issues)
+	}
+}
+
+func TestDiagnoseRepairEpCount(t *testing.T) {
+	initFakeStateDriver(t)
+	defer deinitFakeStateDriver()
+
+	nwCfg := &mastercfg.CfgNetworkState{EpCount: 5}
+	nwCfg.StateDriver = fakeDriver
+	nwCfg.ID = "net1.default"
+	if err := nwCfg.Write(); err != nil {
+		t.Fatalf("error writing network state. Error: %s", err)
+	}
+	for _, id := range []string{"ep1", "ep2"} {
+		epCfg := &mastercfg.CfgEndpointState{NetID: nwCfg.ID}
+		epCfg.StateDriver = fakeDriver
+		epCfg.ID = id
+		if err := epCfg.Write(); err != nil {
+			t.Fatalf("error writing endpoint state. Error: %s", err)
+		}
+	}
+
+	d := newDiagnosis(fakeDriver, nil)
+	if err := d.loadEndpointStates(); err != nil {
+		t.Fatalf("error loading states. Error: %s", err)
+	}
+	d.checkEndpoints()
+	if len(d.issues) != 1 || d.issues[0].Kind != IssueEpCountMismatch {
+		t.Fatalf("Endpoint count mismatch was not reported: %+v", d.issues)
+	}
+
+	// an endpoint created after the check is counted by the repair
+	epCfg := &mastercfg.CfgEndpointState{NetID: nwCfg.ID}
+	epCfg.StateDriver = fakeDriver
+	epCfg.ID = "ep3"
+	if err := epCfg.Write(); err != nil {
+		t.Fatalf("error writing endpoint state. Error: %s", err)
+	}
+	if err := d.issues[0].repair(); err != nil {
+		t.Fatalf("error repairing the endpoint count. Error: %s", err)
+	}
+
+	if err := nwCfg.Read(nwCfg.ID); err != nil || nwCfg.EpCount != 3 {
+		t.Fatalf("network endpoint count %d after the repair, expected 3. Error: %v", nwCfg.EpCount, err)
+	}
+}
+
+func TestEpgPoolAllocEtcd(t *testing.T) {
+	// allocations from an endpoint group pool write the network and the
+	// endpoint group state in one etcd v2 commit
+	driver := &state.EtcdStateDriver{KeysAPI: state.NewFakeEtcdKeysAPI()}
+	ipPool := "10.1.1.10-10.1.1.20"
+
+	nwCfg := &mastercfg.CfgNetworkState{SubnetIP: "10.1.1.0", SubnetLen: 24}
+	nwCfg.ID = "net1.default"
+	nwCfg.StateDriver = driver
+	netutils.InitSubnetBitset(&nwCfg.IPAllocMap, nwCfg.SubnetLen)
+	netutils.SetIPAddrRange(&nwCfg.IPAllocMap, ipPool, nwCfg.SubnetIP, nwCfg.SubnetLen)
+	if err := nwCfg.Write(); err != nil {
+		t.Fatalf("error writing network state. Error: %s", err)
+	}
+
+	epgCfg := &mastercfg.EndpointGroupState{GroupName: "group1", TenantName: "default", IPPool: ipPool}
+	epgCfg.ID = "group1:default"
+	epgCfg.StateDriver = driver
+	netutils.InitSubnetBitset(&epgCfg.EPGIPAllocMap, nwCfg.SubnetLen)
+	netutils.SetBitsOutsideRange(&epgCfg.EPGIPAllocMap, ipPool, nwCfg.SubnetLen)
+	if err := epgCfg.Write(); err != nil {
+		t.Fatalf("error writing endpoint group state. Error: %s", err)
+	}
+
+	ipAddress, err := networkAllocAddress(nwCfg, epgCfg, "", false)
+	if err != nil || ipAddress != "10.1.1.10" {
+		t.Fatalf("allocated %q from the pool, error %v, expected 10.1.1.10", ipAddress, err)
+	}
+	ipAddress, err = networkAllocAddress(nwCfg, epgCfg, "10.1.1.15", false)
+	if err != nil || ipAddress != "10.1.1.15" {
+		t.Fatalf("allocated %q from the pool, error %v, expected 10.1.1.15", ipAddress, err)
+	}
+
+	// both states are committed
+	storedNw := &mastercfg.CfgNetworkState{}
+	storedNw.StateDriver = driver
+	storedEpg := &mastercfg.EndpointGroupState{}
+	storedEpg.StateDriver = driver
+	if err := storedNw.Read(nwCfg.ID); err != nil || storedNw.EpAddrCount != 1 {
+		t.Fatalf("network state %+v, error %v, expected one allocated address", storedNw, err)
+	}
+	if err := storedEpg.Read(epgCfg.ID); err != nil ||
+		!storedEpg.EPGIPAllocMap.Test(10) || !storedEpg.EPGIPAllocMap.Test(15) {
+		t.Fatalf("endpoint group state %+v, error %v, expected allocated addresses", storedEpg, err)
+	}
+
+	if err := networkReleaseAddress(nwCfg, epgCfg, "10.1.1.10"); err != nil {
+		t.Fatalf("error releasing address. Error: %s", err)
+	}
+	if err := storedNw.Read(nwCfg.ID); err != nil || storedNw.EpAddrCount != 0 {
+		t.Fatalf("network state %+v, error %v, expected no allocated address", storedNw, err)
+	}
+	if err := storedEpg.Read(epgCfg.ID); err != nil || storedEpg.EPGIPAllocMap.Test(10) {
+		t.Fatalf("endpoint group state %+v, error %v, expected a released address", storedEpg, err)
+	}
+}
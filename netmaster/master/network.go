@@ -25,18 +25,36 @@ import (
 	"github.com/contiv/netplugin/netmaster/intent"
 	"github.com/contiv/netplugin/netmaster/mastercfg"
 	"github.com/contiv/netplugin/utils/netutils"
+	"github.com/jainvipin/bitset"
 
 	log "github.com/Sirupsen/logrus"
 )
 
 func checkPktTagType(pktTagType string) error {
-	if pktTagType != "" && pktTagType != "vlan" && pktTagType != "vxlan" {
+	if pktTagType != "" && pktTagType != "vlan" && pktTagType != "vxlan" && pktTagType != "geneve" {
 		return core.Errorf("invalid pktTagType")
 	}
 
 	return nil
 }
 
+// checkNetworkMtu checks the configured MTU of a network, zero picks the
+// MTU from the host uplinks
+func checkNetworkMtu(network intent.ConfigNetwork) error {
+	if network.Mtu == 0 {
+		return nil
+	}
+
+	if network.Mtu < netutils.MinLinkMtu {
+		return core.Errorf("network mtu %d is lower than %d", network.Mtu, netutils.MinLinkMtu)
+	}
+	if network.IPv6SubnetCIDR != "" && network.Mtu < netutils.MinIPv6LinkMtu {
+		return core.Errorf("network mtu %d is lower than %d required by IPv6", network.Mtu, netutils.MinIPv6LinkMtu)
+	}
+
+	return nil
+}
+
 func validateNetworkConfig(tenant *intent.ConfigTenant) error {
 	var err error
 
@@ -66,6 +84,11 @@ func validateNetworkConfig(tenant *intent.ConfigTenant) error {
 				return core.Errorf("invalid IP")
 			}
 		}
+
+		err = checkNetworkMtu(network)
+		if err != nil {
+			return err
+		}
 	}
 
 	return err
@@ -100,6 +123,11 @@ func CreateNetwork(network intent.ConfigNetwork, stateDriver core.StateDriver, t
 		return err
 	}
 
+	err = checkNetworkMtu(network)
+	if err != nil {
+		return err
+	}
+
 	ipv6Subnet, ipv6SubnetLen, _ := netutils.ParseCIDR(network.IPv6SubnetCIDR)
 
 	// if there is no label given generate one for the network
@@ -119,6 +147,7 @@ func CreateNetwork(network intent.ConfigNetwork, stateDriver core.StateDriver, t
 		IPv6Subnet:    ipv6Subnet,
 		IPv6SubnetLen: ipv6SubnetLen,
 		NetworkTag:    nwTag,
+		Mtu:           network.Mtu,
 	}
 
 	nwCfg.ID = networkID
@@ -164,7 +193,8 @@ func CreateNetwork(network intent.ConfigNetwork, stateDriver core.StateDriver, t
 		if err != nil {
 			return err
 		}
-	} else if nwCfg.PktTagType == "vxlan" {
+	} else if nwCfg.PktTagType == "vxlan" || nwCfg.PktTagType == "geneve" {
+		// geneve networks share the vxlan ids and local vlans
 		extPktTag, pktTag, err = gCfg.AllocVXLAN(reqPktTag)
 		if err != nil {
 			return err
@@ -202,6 +232,245 @@ func CreateNetwork(network intent.ConfigNetwork, stateDriver core.StateDriver, t
 	return nil
 }
 
+// UpdateNetwork applies gateway, ip range and tag changes to an existing
+// network. Addresses already handed out to endpoints and endpoint groups must
+// remain valid in the updated network; the change is rejected otherwise.
+func UpdateNetwork(network intent.ConfigNetwork, stateDriver core.StateDriver, tenantName string) error {
+	gstate.GlobalMutex.Lock()
+	defer gstate.GlobalMutex.Unlock()
+
+	networkID := network.Name + "." + tenantName
+	nwCfg := &mastercfg.CfgNetworkState{}
+	nwCfg.StateDriver = stateDriver
+	err := nwCfg.Read(networkID)
+	if err != nil {
+		log.Errorf("network %s is not operational", networkID)
+		return err
+	}
+
+	if network.NwType != nwCfg.NwType || network.PktTagType != nwCfg.PktTagType {
+		return core.Errorf("network type and encap of network %s can not be changed", networkID)
+	}
+
+	ipv6Subnet, ipv6SubnetLen, _ := netutils.ParseCIDR(network.IPv6SubnetCIDR)
+	if ipv6Subnet != nwCfg.IPv6Subnet || ipv6SubnetLen != nwCfg.IPv6SubnetLen {
+		return core.Errorf("ipv6 subnet of network %s can not be changed", networkID)
+	}
+
+	if network.Mtu != nwCfg.Mtu {
+		return core.Errorf("mtu of network %s can not be changed", networkID)
+	}
+
+	subnetIP, subnetLen, err := netutils.ParseCIDR(network.SubnetCIDR)
+	if err != nil {
+		return err
+	}
+	subnetAddr := netutils.GetSubnetAddr(subnetIP, subnetLen)
+	ipAddrRange := netutils.GetIPAddrRange(subnetIP, subnetLen)
+
+	var epgCfgs []*mastercfg.EndpointGroupState
+	if subnetAddr != nwCfg.SubnetIP || subnetLen != nwCfg.SubnetLen ||
+		ipAddrRange != nwCfg.IPAddrRange || network.Gateway != nwCfg.Gateway {
+		// docker networks are created with a fixed ipam config
+		aci, _ := IsAciConfigured()
+		if GetClusterMode() == core.Docker && !aci && nwCfg.NwType != "infra" {
+			return core.Errorf("subnet and gateway of network %s can not be changed in docker mode", networkID)
+		}
+
+		err = netutils.ValidateNetworkRangeParams(subnetIP, subnetLen)
+		if err != nil {
+			return err
+		}
+
+		epgCfgs, err = updateNetworkAddrRange(nwCfg, subnetIP, subnetLen, network.Gateway)
+		if err != nil {
+			log.Errorf("Error updating address range of network %s. Err: %v", networkID, err)
+			return err
+		}
+	}
+
+	if network.IPv6Gateway != nwCfg.IPv6Gateway {
+		if nwCfg.IPv6Gateway != "" {
+			hostID, err := netutils.GetIPv6HostID(nwCfg.IPv6Subnet, nwCfg.IPv6SubnetLen, nwCfg.IPv6Gateway)
+			if err == nil {
+				delete(nwCfg.IPv6AllocMap, hostID)
+			}
+		}
+
+		if network.IPv6Gateway != "" {
+			hostID, err := netutils.GetIPv6HostID(nwCfg.IPv6Subnet, nwCfg.IPv6SubnetLen, network.IPv6Gateway)
+			if err != nil {
+				log.Errorf("Error parsing gateway address %s. Err: %v", network.IPv6Gateway, err)
+				return err
+			}
+			if nwCfg.IPv6AllocMap[hostID] {
+				return core.Errorf("gateway address %s is already in use", network.IPv6Gateway)
+			}
+			netutils.ReserveIPv6HostID(hostID, &nwCfg.IPv6AllocMap)
+		}
+		nwCfg.IPv6Gateway = network.IPv6Gateway
+	}
+
+	nwTag := network.CfgdTag
+	if nwTag == "" {
+		nwTag = network.Name + "." + tenantName
+	}
+	if nwTag != nwCfg.NetworkTag {
+		if err := checkNetworkTagUnique(stateDriver, networkID, nwTag); err != nil {
+			return err
+		}
+	}
+	nwCfg.NetworkTag = nwTag
+
+	for _, epgCfg := range epgCfgs {
+		if err := epgCfg.Write(); err != nil {
+			log.Errorf("error writing epg config. Error: %s", err)
+			return err
+		}
+	}
+
+	return nwCfg.Write()
+}
+
+// checkNetworkTagUnique verifies no network other than networkID uses a tag
+func checkNetworkTagUnique(stateDriver core.StateDriver, networkID, nwTag string) error {
+	readNet := &mastercfg.CfgNetworkState{}
+	readNet.StateDriver = stateDriver
+	nwList, err := readNet.ReadAll()
+	if err != nil && !strings.Contains(err.Error(), "key not found") {
+		return err
+	}
+
+	for _, nwState := range nwList {
+		nwCfg := nwState.(*mastercfg.CfgNetworkState)
+		if nwCfg.ID != networkID && nwCfg.NetworkTag == nwTag {
+			return core.Errorf("tag %s is already used by network %s", nwTag, nwCfg.ID)
+		}
+	}
+
+	return nil
+}
+
+// updateNetworkAddrRange rebuilds the address allocation maps of a network
+// and its endpoint groups for a new subnet, range and gateway. Allocated
+// addresses keep their allocation in the new maps. Updated endpoint groups
+// are returned for the caller to save.
+func updateNetworkAddrRange(nwCfg *mastercfg.CfgNetworkState, subnetIP string, subnetLen uint,
+	gateway string) ([]*mastercfg.EndpointGroupState, error) {
+	subnetAddr := netutils.GetSubnetAddr(subnetIP, subnetLen)
+
+	readEpg := &mastercfg.EndpointGroupState{}
+	readEpg.StateDriver = nwCfg.StateDriver
+	epgList, err := readEpg.ReadAll()
+	if err != nil && !strings.Contains(err.Error(), "key not found") {
+		return nil, err
+	}
+
+	epgCfgs := []*mastercfg.EndpointGroupState{}
+	for _, epgState := range epgList {
+		epgCfg := epgState.(*mastercfg.EndpointGroupState)
+		if epgCfg.NetworkName == nwCfg.NetworkName && epgCfg.TenantName == nwCfg.Tenant &&
+			len(epgCfg.IPPool) > 0 {
+			epgCfgs = append(epgCfgs, epgCfg)
+		}
+	}
+
+	// addresses in the old map that are not reserved, gateway or epg pools
+	// belong to endpoints
+	oldReserved := bitset.BitSet{}
+	netutils.InitSubnetBitset(&oldReserved, nwCfg.SubnetLen)
+	netutils.SetBitsOutsideRange(&oldReserved, nwCfg.IPAddrRange, nwCfg.SubnetLen)
+	if nwCfg.Gateway != "" {
+		gwValue, err := netutils.GetIPNumber(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, nwCfg.Gateway)
+		if err == nil {
+			oldReserved.Set(gwValue)
+		}
+	}
+	for _, epgCfg := range epgCfgs {
+		netutils.SetIPAddrRange(&oldReserved, epgCfg.IPPool, nwCfg.SubnetIP, nwCfg.SubnetLen)
+	}
+
+	// gateway may be outside the address range, but not outside the subnet
+	ipAllocMap := bitset.BitSet{}
+	netutils.InitSubnetBitset(&ipAllocMap, subnetLen)
+	if gateway != "" {
+		if err := reserveNetworkAddress(&ipAllocMap, subnetAddr, subnetLen, gateway); err != nil {
+			return nil, err
+		}
+	}
+	if strings.Contains(subnetIP, "-") {
+		netutils.SetBitsOutsideRange(&ipAllocMap, subnetIP, subnetLen)
+	}
+
+	for idx, found := nwCfg.IPAllocMap.NextSet(0); found; idx, found = nwCfg.IPAllocMap.NextSet(idx + 1) {
+		if oldReserved.Test(idx) {
+			continue
+		}
+		ipAddress, err := netutils.GetSubnetIP(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, idx)
+		if err != nil {
+			// beyond the subnet capacity
+			break
+		}
+		if err := reserveNetworkAddress(&ipAllocMap, subnetAddr, subnetLen, ipAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	epgAllocMaps := make([]bitset.BitSet, len(epgCfgs))
+	for i, epgCfg := range epgCfgs {
+		if err := netutils.TestIPAddrRange(&ipAllocMap, epgCfg.IPPool, subnetAddr, subnetLen); err != nil {
+			return nil, core.Errorf("ip-pool %s of group %s is not within the network: %v",
+				epgCfg.IPPool, epgCfg.GroupName, err)
+		}
+		netutils.SetIPAddrRange(&ipAllocMap, epgCfg.IPPool, subnetAddr, subnetLen)
+
+		netutils.InitSubnetBitset(&epgAllocMaps[i], subnetLen)
+		netutils.SetBitsOutsideRange(&epgAllocMaps[i], epgCfg.IPPool, subnetLen)
+
+		addrRangeList := strings.Split(epgCfg.IPPool, "-")
+		hostMin, _ := netutils.GetIPNumber(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, addrRangeList[0])
+		hostMax, _ := netutils.GetIPNumber(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, addrRangeList[1])
+		for idx := hostMin; idx <= hostMax; idx++ {
+			if !epgCfg.EPGIPAllocMap.Test(idx) {
+				continue
+			}
+			ipAddress, _ := netutils.GetSubnetIP(nwCfg.SubnetIP, nwCfg.SubnetLen, 32, idx)
+			newIdx, err := netutils.GetIPNumber(subnetAddr, subnetLen, 32, ipAddress)
+			if err != nil {
+				return nil, err
+			}
+			epgAllocMaps[i].Set(newIdx)
+		}
+	}
+
+	for i, epgCfg := range epgCfgs {
+		epgCfg.EPGIPAllocMap = epgAllocMaps[i]
+	}
+
+	nwCfg.SubnetIP = subnetAddr
+	nwCfg.SubnetLen = subnetLen
+	nwCfg.IPAddrRange = netutils.GetIPAddrRange(subnetIP, subnetLen)
+	nwCfg.Gateway = gateway
+	nwCfg.IPAllocMap = ipAllocMap
+
+	return epgCfgs, nil
+}
+
+// reserveNetworkAddress marks an address as used in the allocation map,
+// failing if the address is outside the subnet range or already in use
+func reserveNetworkAddress(ipAllocMap *bitset.BitSet, subnetAddr string, subnetLen uint, ipAddress string) error {
+	ipAddrValue, err := netutils.GetIPNumber(subnetAddr, subnetLen, 32, ipAddress)
+	if err != nil {
+		return core.Errorf("address %s is outside subnet %s/%d", ipAddress, subnetAddr, subnetLen)
+	}
+	if ipAllocMap.Test(ipAddrValue) {
+		return core.Errorf("address %s is not available in subnet %s/%d", ipAddress, subnetAddr, subnetLen)
+	}
+	ipAllocMap.Set(ipAddrValue)
+
+	return nil
+}
+
 // CreateNetworks creates the necessary virtual networks for the tenant
 // provided by ConfigTenant.
 func CreateNetworks(stateDriver core.StateDriver, tenant *intent.ConfigTenant) error {
@@ -229,7 +498,7 @@ func freeNetworkResources(stateDriver core.StateDriver, nwCfg *mastercfg.CfgNetw
 		if err != nil {
 			return err
 		}
-	} else if nwCfg.PktTagType == "vxlan" {
+	} else if nwCfg.PktTagType == "vxlan" || nwCfg.PktTagType == "geneve" {
 		log.Infof("freeing vlan %d vxlan %d", nwCfg.PktTag, nwCfg.ExtPktTag)
 		err = gCfg.FreeVXLAN(uint(nwCfg.ExtPktTag), uint(nwCfg.PktTag))
 		if err != nil {
@@ -342,10 +611,72 @@ func ListAvailableIPs(nwCfg *mastercfg.CfgNetworkState) string {
 	return netutils.ListAvailableIPs(nwCfg.IPAllocMap, nwCfg.SubnetIP, nwCfg.SubnetLen)
 }
 
+// updateNetworkState runs update on the current state of a network and its
+// endpoint group, which may be nil, and commits the changes with versioned
+// writes. The update is run again if the states were changed meanwhile, so
+// concurrent allocations, or a netmaster that lost its leadership, cant
+// hand out an address twice. nwCfg and epgCfg are set to the new states.
+func updateNetworkState(nwCfg *mastercfg.CfgNetworkState, epgCfg *mastercfg.EndpointGroupState,
+	update func(nwCfg *mastercfg.CfgNetworkState, epgCfg *mastercfg.EndpointGroupState) error) error {
+	var txnNwCfg *mastercfg.CfgNetworkState
+	var txnEpgCfg *mastercfg.EndpointGroupState
+
+	err := core.UpdateStates(nwCfg.StateDriver, func(txn core.StateDriver) error {
+		txnNwCfg = &mastercfg.CfgNetworkState{}
+		txnNwCfg.StateDriver = txn
+		if err := txnNwCfg.Read(nwCfg.ID); err != nil {
+			return err
+		}
+
+		txnEpgCfg = nil
+		if epgCfg != nil {
+			txnEpgCfg = &mastercfg.EndpointGroupState{}
+			txnEpgCfg.StateDriver = txn
+			if err := txnEpgCfg.Read(epgCfg.ID); err != nil {
+				return err
+			}
+		}
+
+		return update(txnNwCfg, txnEpgCfg)
+	})
+	if err != nil {
+		return err
+	}
+
+	txnNwCfg.StateDriver = nwCfg.StateDriver
+	*nwCfg = *txnNwCfg
+	if epgCfg != nil {
+		txnEpgCfg.StateDriver = epgCfg.StateDriver
+		*epgCfg = *txnEpgCfg
+	}
+
+	return nil
+}
+
 // Allocate an address from the network
 func networkAllocAddress(nwCfg *mastercfg.CfgNetworkState, epgCfg *mastercfg.EndpointGroupState,
 	reqAddr string, isIPv6 bool) (string, error) {
 	var ipAddress string
+
+	err := updateNetworkState(nwCfg, epgCfg, func(nwCfg *mastercfg.CfgNetworkState,
+		epgCfg *mastercfg.EndpointGroupState) error {
+		var err error
+		ipAddress, err = allocAddress(nwCfg, epgCfg, reqAddr, isIPv6)
+		return err
+	})
+	if err != nil {
+		log.Errorf("error allocating address in network %s. Error: %s", nwCfg.ID, err)
+		return "", err
+	}
+
+	return ipAddress, nil
+}
+
+// allocAddress allocates an address in the network and endpoint group
+// state, and writes them
+func allocAddress(nwCfg *mastercfg.CfgNetworkState, epgCfg *mastercfg.EndpointGroupState,
+	reqAddr string, isIPv6 bool) (string, error) {
+	var ipAddress string
 	var ipAddrValue uint
 	var found bool
 	var err error
@@ -462,6 +793,21 @@ func networkAllocAddress(nwCfg *mastercfg.CfgNetworkState, epgCfg *mastercfg.End
 
 // networkReleaseAddress release the ip address
 func networkReleaseAddress(nwCfg *mastercfg.CfgNetworkState, epgCfg *mastercfg.EndpointGroupState, ipAddress string) error {
+	err := updateNetworkState(nwCfg, epgCfg, func(nwCfg *mastercfg.CfgNetworkState,
+		epgCfg *mastercfg.EndpointGroupState) error {
+		return releaseAddress(nwCfg, epgCfg, ipAddress)
+	})
+	if err != nil {
+		log.Errorf("error releasing address %s in network %s. Error: %s", ipAddress, nwCfg.ID, err)
+		return err
+	}
+
+	return nil
+}
+
+// releaseAddress frees an address in the network and endpoint group state,
+// and writes them
+func releaseAddress(nwCfg *mastercfg.CfgNetworkState, epgCfg *mastercfg.EndpointGroupState, ipAddress string) error {
 	isIPv6 := netutils.IsIPv6(ipAddress)
 	if isIPv6 {
 		hostID, err := netutils.GetIPv6HostID(nwCfg.SubnetIP, nwCfg.SubnetLen, ipAddress)
@@ -16,6 +16,12 @@ limitations under the License.
 package master
 
 import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
 	"github.com/contiv/netplugin/contivmodel"
 	"github.com/contiv/netplugin/core"
 	"github.com/contiv/netplugin/netmaster/mastercfg"
@@ -183,3 +189,331 @@ func PolicyDelRule(policy *contivModel.Policy, rule *contivModel.Rule) error {
 
 	return nil
 }
+
+// prepareRuleUpdates compiles the new version of a rule for all endpoint
+// groups using its policy
+func prepareRuleUpdates(policy *contivModel.Policy, rule *contivModel.Rule) ([]*mastercfg.RuleUpdate, error) {
+	var ruleUpdates []*mastercfg.RuleUpdate
+	for epgKey := range policy.LinkSets.EndpointGroups {
+		gpKey := epgKey + ":" + policy.Key
+
+		// Find the epg policy
+		gp := mastercfg.FindEpgPolicy(gpKey)
+		if gp == nil {
+			log.Errorf("Failed to find the epg policy %s", gpKey)
+			return nil, core.Errorf("epg policy not found")
+		}
+
+		ruleUpdate, err := gp.PrepareRuleUpdate(rule)
+		if err != nil {
+			log.Errorf("Error compiling the rule %s in epg policy %s. Err: %v", rule.Key, gp.EpgPolicyKey, err)
+			return nil, err
+		}
+		ruleUpdates = append(ruleUpdates, ruleUpdate)
+	}
+
+	return ruleUpdates, nil
+}
+
+// applyRuleUpdates installs compiled rule updates and saves their epg
+// policies. If any update fails, the ones already applied are reverted to
+// the rules they replaced.
+func applyRuleUpdates(ruleUpdates []*mastercfg.RuleUpdate) error {
+	var applied []*mastercfg.RuleUpdate
+	revert := func() {
+		for i := len(applied) - 1; i >= 0; i-- {
+			gp := applied[i].Policy
+			if err := applied[i].Revert(); err != nil {
+				log.Errorf("Error reverting a rule in epg policy %s. Err: %v", gp.EpgPolicyKey, err)
+				continue
+			}
+			gp.Write()
+		}
+	}
+
+	for _, ruleUpdate := range ruleUpdates {
+		gp := ruleUpdate.Policy
+		err := ruleUpdate.Apply()
+		if err != nil {
+			log.Errorf("Error updating a rule in epg policy %s. Err: %v", gp.EpgPolicyKey, err)
+			revert()
+			return err
+		}
+		applied = append(applied, ruleUpdate)
+
+		// Save the policy state
+		err = gp.Write()
+		if err != nil {
+			log.Errorf("Error writing policy %s to state store. Err: %v", gp.EpgPolicyKey, err)
+			revert()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PolicyUpdateRule replaces a rule in existing policy with its new version.
+// Either all endpoint groups using the policy get the new rule or none does.
+func PolicyUpdateRule(policy *contivModel.Policy, rule *contivModel.Rule) error {
+	// Dont install policies in ACI mode
+	if !isPolicyEnabled() {
+		return nil
+	}
+
+	ruleUpdates, err := prepareRuleUpdates(policy, rule)
+	if err != nil {
+		return err
+	}
+
+	return applyRuleUpdates(ruleUpdates)
+}
+
+// PolicyUpdateAddressSet recompiles all rules matching an address set after
+// its addresses have changed. The rules themselves are not modified. Every
+// rule is compiled before any is installed, and if installing fails, the
+// rules are restored as they were compiled for the old addresses.
+func PolicyUpdateAddressSet(addrSet *contivModel.AddressSet) error {
+	// Dont install policies in ACI mode
+	if !isPolicyEnabled() {
+		return nil
+	}
+
+	var ruleUpdates []*mastercfg.RuleUpdate
+	for ruleKey := range addrSet.LinkSets.Rules {
+		rule := contivModel.FindRule(ruleKey)
+		if rule == nil {
+			log.Errorf("Error finding rule %s of address set %s", ruleKey, addrSet.Key)
+			return core.Errorf("rule not found")
+		}
+
+		policyKey := rule.TenantName + ":" + rule.PolicyName
+		policy := contivModel.FindPolicy(policyKey)
+		if policy == nil {
+			log.Errorf("Error finding policy %s", policyKey)
+			return core.Errorf("policy not found")
+		}
+
+		updates, err := prepareRuleUpdates(policy, rule)
+		if err != nil {
+			log.Errorf("Error recompiling rule %s for address set %s. Err: %v", rule.Key, addrSet.Key, err)
+			return err
+		}
+		ruleUpdates = append(ruleUpdates, updates...)
+	}
+
+	return applyRuleUpdates(ruleUpdates)
+}
+
+// policyUpdateRules recompiles the rules of a policy selected by a filter.
+// All replacement rules are compiled before any is installed, so that a
+// compile error leaves every rule as it is, and a failed install reverts
+// the rules already swapped to their old version.
+func policyUpdateRules(policy *contivModel.Policy, filter func(*contivModel.Rule) bool) error {
+	// Dont install policies in ACI mode
+	if !isPolicyEnabled() {
+		return nil
+	}
+
+	var ruleUpdates []*mastercfg.RuleUpdate
+	for ruleKey := range policy.LinkSets.Rules {
+		rule := contivModel.FindRule(ruleKey)
+		if rule == nil {
+			log.Errorf("Error finding rule %s of policy %s", ruleKey, policy.Key)
+			return core.Errorf("rule not found")
+		}
+
+		if !filter(rule) {
+			continue
+		}
+
+		updates, err := prepareRuleUpdates(policy, rule)
+		if err != nil {
+			log.Errorf("Error recompiling rule %s for policy %s. Err: %v", rule.Key, policy.Key, err)
+			return err
+		}
+		ruleUpdates = append(ruleUpdates, updates...)
+	}
+
+	return applyRuleUpdates(ruleUpdates)
+}
+
+// PolicyUpdateMode recompiles the deny rules of a policy after its mode has
+// changed, so that they are enforced or only audited. All rules are
+// compiled for the new mode first, then swapped together. Rules are replaced
+// in place, the action of their flows changes without a gap.
+func PolicyUpdateMode(policy *contivModel.Policy) error {
+	// only deny rules depend on the mode
+	return policyUpdateRules(policy, func(rule *contivModel.Rule) bool {
+		return rule.Action == "deny"
+	})
+}
+
+// PolicyUpdateBaseline recompiles all rules of a policy after it became or
+// stopped being the baseline policy, so that its rules move above the
+// tenant rules or back among them. New flows are installed before the old
+// ones are removed.
+func PolicyUpdateBaseline(policy *contivModel.Policy) error {
+	return policyUpdateRules(policy, func(rule *contivModel.Rule) bool {
+		return true
+	})
+}
+
+// PolicySimulateRequest describes a flow to evaluate against the policies.
+// Source and destination can each be an endpoint, an endpoint group or an
+// IP address
+type PolicySimulateRequest struct {
+	TenantName        string `json:"tenantName"`
+	FromEndpointID    string `json:"fromEndpointID,omitempty"`
+	FromEndpointGroup string `json:"fromEndpointGroup,omitempty"`
+	FromIpAddress     string `json:"fromIpAddress,omitempty"`
+	ToEndpointID      string `json:"toEndpointID,omitempty"`
+	ToEndpointGroup   string `json:"toEndpointGroup,omitempty"`
+	ToIpAddress       string `json:"toIpAddress,omitempty"`
+	Protocol          string `json:"protocol,omitempty"`
+	Port              int    `json:"port,omitempty"`
+}
+
+// PolicySimulateResponse has the verdict for a simulated flow
+type PolicySimulateResponse struct {
+	Action        string `json:"action"`                  // allow, deny or audit
+	TenantName    string `json:"tenantName,omitempty"`    // tenant of the matching policy
+	PolicyName    string `json:"policyName,omitempty"`    // matching policy, empty if no rule matched
+	EndpointGroup string `json:"endpointGroup,omitempty"` // group the matching policy is attached to
+	RuleID        string `json:"ruleId,omitempty"`        // matching rule
+	Priority      int    `json:"priority,omitempty"`      // priority of the matching rule
+	OfnetRuleID   string `json:"ofnetRuleId,omitempty"`   // compiled rule that matched
+}
+
+// PolicySimulateHandler evaluates a flow against the compiled policies
+func PolicySimulateHandler(w http.ResponseWriter, r *http.Request, vars map[string]string) (interface{}, error) {
+	var simReq PolicySimulateRequest
+
+	// Get object from the request
+	err := json.NewDecoder(r.Body).Decode(&simReq)
+	if err != nil {
+		log.Errorf("Error decoding PolicySimulateHandler. Err %v", err)
+		return nil, err
+	}
+
+	log.Infof("Received PolicySimulateRequest: %+v", simReq)
+
+	return PolicySimulate(&simReq)
+}
+
+// PolicySimulate returns the verdict the policy table would give the first
+// packet of a flow
+func PolicySimulate(simReq *PolicySimulateRequest) (*PolicySimulateResponse, error) {
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	if simReq.TenantName == "" {
+		simReq.TenantName = "default"
+	}
+
+	flow := &mastercfg.PolicyFlow{}
+
+	flow.SrcEndpointGroup, flow.SrcIpAddr, err = resolveSimulateAddr(stateDriver, simReq.TenantName,
+		simReq.FromEndpointID, simReq.FromEndpointGroup, simReq.FromIpAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	flow.DstEndpointGroup, flow.DstIpAddr, err = resolveSimulateAddr(stateDriver, simReq.TenantName,
+		simReq.ToEndpointID, simReq.ToEndpointGroup, simReq.ToIpAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	switch simReq.Protocol {
+	case "tcp":
+		flow.IpProtocol = 6
+	case "udp":
+		flow.IpProtocol = 17
+	case "icmp":
+		flow.IpProtocol = 1
+	case "igmp":
+		flow.IpProtocol = 2
+	default:
+		proto, err := strconv.Atoi(simReq.Protocol)
+		if err != nil || proto < 0 || proto > 255 {
+			return nil, core.Errorf("invalid protocol %q", simReq.Protocol)
+		}
+		flow.IpProtocol = uint8(proto)
+	}
+
+	if simReq.Port < 0 || simReq.Port > 65535 {
+		return nil, core.Errorf("invalid port %d", simReq.Port)
+	}
+	if simReq.Port != 0 && flow.IpProtocol != 6 && flow.IpProtocol != 17 {
+		return nil, core.Errorf("port can only be specified for tcp or udp")
+	}
+	flow.DstPort = uint16(simReq.Port)
+
+	verdict, err := mastercfg.SimulateFlow(stateDriver, flow)
+	if err != nil {
+		return nil, err
+	}
+
+	simResp := &PolicySimulateResponse{Action: verdict.Action}
+	if verdict.Rule != nil {
+		simResp.TenantName = verdict.Rule.TenantName
+		simResp.PolicyName = verdict.Rule.PolicyName
+		simResp.RuleID = verdict.Rule.RuleID
+		simResp.Priority = verdict.Rule.Priority
+		simResp.OfnetRuleID = verdict.OfnetRule.RuleId
+
+		// epg policy key is tenant:group:tenant:policy
+		keys := strings.Split(verdict.EpgPolicyKey, ":")
+		if len(keys) > 1 {
+			simResp.EndpointGroup = keys[1]
+		}
+	}
+
+	return simResp, nil
+}
+
+// resolveSimulateAddr finds the endpoint group and ip address of one end
+// of a simulated flow
+func resolveSimulateAddr(stateDriver core.StateDriver, tenantName, epID, epgName, ipAddr string) (int, string, error) {
+	if ipAddr != "" && net.ParseIP(ipAddr) == nil {
+		return 0, "", core.Errorf("invalid IP address %s", ipAddr)
+	}
+
+	// the endpoint group gives the group, the ip address is optional
+	if epgName != "" {
+		epgID, err := mastercfg.GetEndpointGroupID(stateDriver, epgName, tenantName)
+		return epgID, ipAddr, err
+	}
+
+	if epID == "" && ipAddr == "" {
+		return 0, "", core.Errorf("endpoint, endpoint group or IP address is required")
+	}
+
+	// find the endpoint by its id or ip address
+	readEp := &mastercfg.CfgEndpointState{}
+	readEp.StateDriver = stateDriver
+	epCfgs, err := readEp.ReadAll()
+	if err != nil && !strings.Contains(err.Error(), "key not found") {
+		return 0, "", err
+	}
+
+	for _, epCfg := range epCfgs {
+		ep := epCfg.(*mastercfg.CfgEndpointState)
+		if epID != "" && (ep.EndpointID == epID || ep.ID == epID || ep.ContainerID == epID) {
+			return ep.EndpointGroupID, ep.IPAddress, nil
+		}
+		if epID == "" && (ep.IPAddress == ipAddr || ep.IPv6Address == ipAddr) {
+			return ep.EndpointGroupID, ipAddr, nil
+		}
+	}
+
+	if epID != "" {
+		return 0, "", core.Errorf("endpoint %s not found", epID)
+	}
+
+	// external address, not in any endpoint group
+	return 0, ipAddr, nil
+}
@@ -0,0 +1,221 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/contiv/netplugin/core"
+	"golang.org/x/crypto/curve25519"
+)
+
+const (
+	encryptionConfigPathPrefix     = StateConfigPath + "encryption/"
+	encryptionConfigPath           = encryptionConfigPathPrefix + "%s"
+	encryptionHostConfigPathPrefix = StateConfigPath + "encryption-hosts/"
+	encryptionHostConfigPath       = encryptionHostConfigPathPrefix + "%s"
+
+	// EncryptionStateID is the id of the cluster wide encryption state
+	EncryptionStateID = "global"
+
+	// EncryptionModeNone disables the encryption of the tunnels
+	EncryptionModeNone = "none"
+	// EncryptionModeIPsec encrypts the tunnels in IPsec transport mode
+	EncryptionModeIPsec = "ipsec"
+)
+
+// CfgEncryptionState is the encryption of the tunnels between the hosts.
+// Keys are rotated by incrementing the key generation, the hosts then
+// generate and publish new keys for it. The state holds no secret.
+type CfgEncryptionState struct {
+	core.CommonState
+	Mode          string `json:"mode"`
+	KeyGeneration int    `json:"key-generation"`
+}
+
+// EncryptionPeerStatus is the encryption status of the tunnel to a peer
+type EncryptionPeerStatus struct {
+	Peer          string `json:"peer"`
+	Interface     string `json:"interface"`
+	State         string `json:"state"`
+	KeyGeneration int    `json:"key-generation"`
+}
+
+// Enabled returns true if the tunnels are to be encrypted
+func (s *CfgEncryptionState) Enabled() bool {
+	return s.Mode == EncryptionModeIPsec && s.KeyGeneration > 0
+}
+
+// Write the state
+func (s *CfgEncryptionState) Write() error {
+	key := fmt.Sprintf(encryptionConfigPath, s.ID)
+	return s.StateDriver.WriteState(key, s, json.Marshal)
+}
+
+// Read the state in for a given ID.
+func (s *CfgEncryptionState) Read(id string) error {
+	key := fmt.Sprintf(encryptionConfigPath, id)
+	return s.StateDriver.ReadState(key, s, json.Unmarshal)
+}
+
+// ReadAll reads all the state for encryption configurations and returns it.
+func (s *CfgEncryptionState) ReadAll() ([]core.State, error) {
+	return s.StateDriver.ReadAllState(encryptionConfigPathPrefix, s, json.Unmarshal)
+}
+
+// Clear removes the configuration from the state store.
+func (s *CfgEncryptionState) Clear() error {
+	key := fmt.Sprintf(encryptionConfigPath, s.ID)
+	return s.StateDriver.ClearState(key)
+}
+
+// WatchAll state transitions and send them through the channel.
+func (s *CfgEncryptionState) WatchAll(rsps chan core.WatchState) error {
+	return s.StateDriver.WatchAllState(encryptionConfigPathPrefix, s, json.Unmarshal,
+		rsps)
+}
+
+// EncryptionHostKey is the public key of a host for a key generation
+type EncryptionHostKey struct {
+	Generation int    `json:"generation"`
+	PublicKey  string `json:"public-key"`
+}
+
+// CfgEncryptionHostState is the public keys a host accepts tunnels with,
+// by key generation. Its ID is the VTEP IP of the host. The private keys
+// never leave the host, the key of the tunnel between two hosts is agreed
+// on from the private key of one and the public key of the other. Seen
+// acknowledges the latest key generation of each peer the host has a
+// public key of, by VTEP IP. A tunnel is only moved to a key generation
+// once both ends have seen the key of the other.
+type CfgEncryptionHostState struct {
+	core.CommonState
+	Keys []EncryptionHostKey `json:"keys"`
+	Seen map[string]int      `json:"seen"`
+}
+
+// PublicKey returns the public key of a key generation, or an empty string
+func (s *CfgEncryptionHostState) PublicKey(generation int) string {
+	for _, key := range s.Keys {
+		if key.Generation == generation {
+			return key.PublicKey
+		}
+	}
+
+	return ""
+}
+
+// LatestGeneration returns the latest key generation the host has a public
+// key of, or 0
+func (s *CfgEncryptionHostState) LatestGeneration() int {
+	generation := 0
+	for _, key := range s.Keys {
+		if key.Generation > generation {
+			generation = key.Generation
+		}
+	}
+
+	return generation
+}
+
+// Write the state
+func (s *CfgEncryptionHostState) Write() error {
+	key := fmt.Sprintf(encryptionHostConfigPath, s.ID)
+	return s.StateDriver.WriteState(key, s, json.Marshal)
+}
+
+// Read the state in for a given ID.
+func (s *CfgEncryptionHostState) Read(id string) error {
+	key := fmt.Sprintf(encryptionHostConfigPath, id)
+	return s.StateDriver.ReadState(key, s, json.Unmarshal)
+}
+
+// ReadAll reads all the host encryption states and returns them.
+func (s *CfgEncryptionHostState) ReadAll() ([]core.State, error) {
+	return s.StateDriver.ReadAllState(encryptionHostConfigPathPrefix, s, json.Unmarshal)
+}
+
+// Clear removes the state from the state store.
+func (s *CfgEncryptionHostState) Clear() error {
+	key := fmt.Sprintf(encryptionHostConfigPath, s.ID)
+	return s.StateDriver.ClearState(key)
+}
+
+// WatchAll state transitions and send them through the channel.
+func (s *CfgEncryptionHostState) WatchAll(rsps chan core.WatchState) error {
+	return s.StateDriver.WatchAllState(encryptionHostConfigPathPrefix, s, json.Unmarshal,
+		rsps)
+}
+
+// NewEncryptionKey generates the private key of a host
+func NewEncryptionKey() (string, error) {
+	var private [32]byte
+	if _, err := rand.Read(private[:]); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(private[:]), nil
+}
+
+// EncryptionPublicKey returns the public key of a private key
+func EncryptionPublicKey(privateKey string) (string, error) {
+	private, err := decodeEncryptionKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	var public [32]byte
+	curve25519.ScalarBaseMult(&public, private)
+	return hex.EncodeToString(public[:]), nil
+}
+
+// decodeEncryptionKey decodes a hex encoded curve25519 key
+func decodeEncryptionKey(key string) (*[32]byte, error) {
+	var decoded [32]byte
+	b, err := hex.DecodeString(key)
+	if err != nil || len(b) != len(decoded) {
+		return nil, core.Errorf("invalid encryption key")
+	}
+	copy(decoded[:], b)
+
+	return &decoded, nil
+}
+
+// PeerKey returns the pre-shared key of the tunnel between two hosts, from
+// the private key of one and the public key of the other. Both ends derive
+// the same key, and no other host can.
+func PeerKey(privateKey, peerPublicKey string) (string, error) {
+	private, err := decodeEncryptionKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	peerPublic, err := decodeEncryptionKey(peerPublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	var shared [32]byte
+	curve25519.ScalarMult(&shared, private, peerPublic)
+
+	mac := hmac.New(sha256.New, shared[:])
+	mac.Write([]byte("contiv tunnel psk"))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
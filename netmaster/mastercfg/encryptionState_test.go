@@ -0,0 +1,193 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"testing"
+
+	"github.com/contiv/netplugin/core"
+)
+
+const (
+	encryptionCfgKey = encryptionConfigPathPrefix + EncryptionStateID
+)
+
+type testEncryptionStateDriver struct{}
+
+var encryptionStateDriver = &testEncryptionStateDriver{}
+
+func (d *testEncryptionStateDriver) Init(instInfo *core.InstanceInfo) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEncryptionStateDriver) Deinit() {
+}
+
+func (d *testEncryptionStateDriver) Write(key string, value []byte) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEncryptionStateDriver) Read(key string) ([]byte, error) {
+	return []byte{}, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEncryptionStateDriver) ReadAll(baseKey string) ([][]byte, error) {
+	return [][]byte{}, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEncryptionStateDriver) WatchAll(baseKey string, rsps chan [2][]byte) error {
+	return core.Errorf("not supported")
+}
+
+func (d *testEncryptionStateDriver) validateKey(key string) error {
+	if key != encryptionCfgKey {
+		return core.Errorf("Unexpected key. recvd: %s expected: %s ",
+			key, encryptionCfgKey)
+	}
+
+	return nil
+}
+
+func (d *testEncryptionStateDriver) ClearState(key string) error {
+	return d.validateKey(key)
+}
+
+func (d *testEncryptionStateDriver) ReadState(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) error {
+	return d.validateKey(key)
+}
+
+func (d *testEncryptionStateDriver) ReadAllState(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) ([]core.State, error) {
+	return nil, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEncryptionStateDriver) WatchAllState(baseKey string, sType core.State,
+	unmarshal func([]byte, interface{}) error, rsps chan core.WatchState) error {
+	return core.Errorf("not supported")
+}
+
+func (d *testEncryptionStateDriver) WriteState(key string, value core.State,
+	marshal func(interface{}) ([]byte, error)) error {
+	return d.validateKey(key)
+}
+
+func (d *testEncryptionStateDriver) ReadVersion(key string) ([]byte, uint64, error) {
+	return []byte{}, 0, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEncryptionStateDriver) WriteCAS(key string, value []byte, version uint64) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEncryptionStateDriver) Commit(ops []core.StateOp) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEncryptionStateDriver) ReadStateVersion(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	return 0, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEncryptionStateDriver) WriteStateCAS(key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func TestCfgEncryptionStateRead(t *testing.T) {
+	encCfg := &CfgEncryptionState{}
+	encCfg.StateDriver = encryptionStateDriver
+
+	err := encCfg.Read(EncryptionStateID)
+	if err != nil {
+		t.Fatalf("read config state failed. Error: %s", err)
+	}
+}
+
+func TestCfgEncryptionStateWrite(t *testing.T) {
+	encCfg := &CfgEncryptionState{}
+	encCfg.StateDriver = encryptionStateDriver
+	encCfg.ID = EncryptionStateID
+
+	err := encCfg.Write()
+	if err != nil {
+		t.Fatalf("write config state failed. Error: %s", err)
+	}
+}
+
+func TestCfgEncryptionStateClear(t *testing.T) {
+	encCfg := &CfgEncryptionState{}
+	encCfg.StateDriver = encryptionStateDriver
+	encCfg.ID = EncryptionStateID
+
+	err := encCfg.Clear()
+	if err != nil {
+		t.Fatalf("clear config state failed. Error: %s", err)
+	}
+}
+
+// newTestEncryptionKey generates a key pair
+func newTestEncryptionKey(t *testing.T) (string, string) {
+	privateKey, err := NewEncryptionKey()
+	if err != nil {
+		t.Fatalf("error generating encryption key. Error: %s", err)
+	}
+	publicKey, err := EncryptionPublicKey(privateKey)
+	if err != nil {
+		t.Fatalf("error getting public key. Error: %s", err)
+	}
+
+	return privateKey, publicKey
+}
+
+// testPeerKey derives a peer key
+func testPeerKey(t *testing.T, privateKey, peerPublicKey string) string {
+	key, err := PeerKey(privateKey, peerPublicKey)
+	if err != nil || key == "" {
+		t.Fatalf("error deriving peer key. Error: %v", err)
+	}
+
+	return key
+}
+
+func TestEncryptionPeerKey(t *testing.T) {
+	private1, public1 := newTestEncryptionKey(t)
+	private2, public2 := newTestEncryptionKey(t)
+	private3, public3 := newTestEncryptionKey(t)
+
+	key := testPeerKey(t, private1, public2)
+	if key != testPeerKey(t, private2, public1) {
+		t.Fatalf("peer keys of both ends don't match")
+	}
+	if key == testPeerKey(t, private1, public3) || key == testPeerKey(t, private3, public2) {
+		t.Fatalf("peer keys of different peers match")
+	}
+
+	if _, err := PeerKey(private1, "0123"); err == nil {
+		t.Fatalf("peer key derived from an invalid public key")
+	}
+}
+
+func TestCfgEncryptionHostStatePublicKey(t *testing.T) {
+	hostCfg := &CfgEncryptionHostState{Keys: []EncryptionHostKey{
+		{Generation: 1, PublicKey: "key1"},
+		{Generation: 2, PublicKey: "key2"},
+	}}
+
+	if hostCfg.PublicKey(2) != "key2" || hostCfg.PublicKey(3) != "" {
+		t.Fatalf("invalid public keys of host state %+v", hostCfg)
+	}
+}
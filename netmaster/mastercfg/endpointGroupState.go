@@ -39,6 +39,9 @@ type EndpointGroupState struct {
 	DSCP            int           `json:"DSCP"`
 	Bandwidth       string        `json:"Bandwidth"`
 	Burst           int           `json:"Burst"`
+	IngressRate     string        `json:"IngressRate"`
+	EgressRate      string        `json:"EgressRate"`
+	EgressMinRate   string        `json:"EgressMinRate"`
 	IPPool          string        `json:"IPPool"`
 	EPGIPAllocMap   bitset.BitSet `json:"epgIpAllocMap"`
 	GroupTag        string        `json:"groupTag"`
@@ -73,6 +76,41 @@ func (s *EndpointGroupState) Clear() error {
 	return s.StateDriver.ClearState(key)
 }
 
+// IncrEpCount increments the endpoint count
+func (s *EndpointGroupState) IncrEpCount() error {
+	return s.addEpCount(1)
+}
+
+// DecrEpCount decrements the endpoint count
+func (s *EndpointGroupState) DecrEpCount() error {
+	return s.addEpCount(-1)
+}
+
+// addEpCount changes the endpoint count of the stored endpoint group with a
+// versioned write, so that address allocations made after the group was
+// read are kept. The group is set to the new state.
+func (s *EndpointGroupState) addEpCount(delta int) error {
+	var epgCfg *EndpointGroupState
+
+	err := core.UpdateStates(s.StateDriver, func(txn core.StateDriver) error {
+		epgCfg = &EndpointGroupState{}
+		epgCfg.StateDriver = txn
+		if err := epgCfg.Read(s.ID); err != nil {
+			return err
+		}
+
+		epgCfg.EpCount += delta
+		return epgCfg.Write()
+	})
+	if err != nil {
+		return err
+	}
+
+	epgCfg.StateDriver = s.StateDriver
+	*s = *epgCfg
+	return nil
+}
+
 // GetEndpointGroupKey returns endpoint group key
 func GetEndpointGroupKey(groupName, tenantName string) string {
 	if groupName == "" || tenantName == "" {
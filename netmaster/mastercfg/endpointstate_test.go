@@ -86,6 +86,28 @@ func (d *testEpStateDriver) WriteState(key string, value core.State,
 	return d.validateKey(key)
 }
 
+func (d *testEpStateDriver) ReadVersion(key string) ([]byte, uint64, error) {
+	return []byte{}, 0, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEpStateDriver) WriteCAS(key string, value []byte, version uint64) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEpStateDriver) Commit(ops []core.StateOp) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEpStateDriver) ReadStateVersion(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	return 0, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testEpStateDriver) WriteStateCAS(key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
 func TestCfgEndpointStateRead(t *testing.T) {
 	epCfg := &CfgEndpointState{}
 	epCfg.StateDriver = epStateDriver
@@ -84,6 +84,28 @@ func (d *testglobalStateDriver) WriteState(key string, value core.State,
 	return d.validateKey(key)
 }
 
+func (d *testglobalStateDriver) ReadVersion(key string) ([]byte, uint64, error) {
+	return []byte{}, 0, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testglobalStateDriver) WriteCAS(key string, value []byte, version uint64) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testglobalStateDriver) Commit(ops []core.StateOp) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testglobalStateDriver) ReadStateVersion(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	return 0, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testglobalStateDriver) WriteStateCAS(key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
 func TestGlobConfigRead(t *testing.T) {
 	gcCfg := &GlobConfig{}
 	gcCfg.StateDriver = gcStateDriver
@@ -0,0 +1,109 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/contiv/netplugin/core"
+)
+
+const (
+	mirrorSessionConfigPathPrefix = StateConfigPath + "mirrorSession/"
+	mirrorSessionConfigPath       = mirrorSessionConfigPathPrefix + "%s"
+
+	// MirrorDirectionBoth mirrors the traffic sent and received by the endpoints
+	MirrorDirectionBoth = "both"
+	// MirrorDirectionIngress mirrors the traffic sent to the endpoints
+	MirrorDirectionIngress = "ingress"
+	// MirrorDirectionEgress mirrors the traffic sent by the endpoints
+	MirrorDirectionEgress = "egress"
+
+	// MirrorDestLocal sends the mirrored traffic to a local OVS port
+	MirrorDestLocal = "local"
+	// MirrorDestGRE sends the mirrored traffic to a collector over GRE
+	MirrorDestGRE = "gre"
+	// MirrorDestERSPAN sends the mirrored traffic to a collector over ERSPAN
+	MirrorDestERSPAN = "erspan"
+)
+
+// CfgMirrorSessionState is the mirroring of the traffic of a set of
+// endpoints. The endpoints are selected by id, endpoint group or network.
+type CfgMirrorSessionState struct {
+	core.CommonState
+	Tenant          string   `json:"tenant"`
+	Endpoints       []string `json:"endpoints"`
+	EndpointGroup   string   `json:"endpointGroup"`
+	Network         string   `json:"network"`
+	Direction       string   `json:"direction"`
+	DestinationType string   `json:"destinationType"`
+	DestinationPort string   `json:"destinationPort"`
+	CollectorIP     string   `json:"collectorIP"`
+	ErspanID        int      `json:"erspanID"`
+}
+
+// GetMirrorSessionKey returns the mirror session key
+func GetMirrorSessionKey(tenantName, sessionName string) string {
+	return tenantName + ":" + sessionName
+}
+
+// MatchesEndpoint returns true if the endpoint is mirrored by the session
+func (s *CfgMirrorSessionState) MatchesEndpoint(ep *CfgEndpointState) bool {
+	switch {
+	case s.EndpointGroup != "":
+		return ep.EndpointGroupKey == GetEndpointGroupKey(s.EndpointGroup, s.Tenant)
+	case s.Network != "":
+		return ep.NetID == GetNwCfgKey(s.Network, s.Tenant)
+	}
+
+	for _, id := range s.Endpoints {
+		if id != "" && (id == ep.EndpointID || id == ep.ContainerID || id == ep.EPCommonName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Write the state
+func (s *CfgMirrorSessionState) Write() error {
+	key := fmt.Sprintf(mirrorSessionConfigPath, s.ID)
+	return s.StateDriver.WriteState(key, s, json.Marshal)
+}
+
+// Read the state in for a given ID.
+func (s *CfgMirrorSessionState) Read(id string) error {
+	key := fmt.Sprintf(mirrorSessionConfigPath, id)
+	return s.StateDriver.ReadState(key, s, json.Unmarshal)
+}
+
+// ReadAll reads all the state for mirror session configurations and returns it.
+func (s *CfgMirrorSessionState) ReadAll() ([]core.State, error) {
+	return s.StateDriver.ReadAllState(mirrorSessionConfigPathPrefix, s, json.Unmarshal)
+}
+
+// Clear removes the configuration from the state store.
+func (s *CfgMirrorSessionState) Clear() error {
+	key := fmt.Sprintf(mirrorSessionConfigPath, s.ID)
+	return s.StateDriver.ClearState(key)
+}
+
+// WatchAll state transitions and send them through the channel.
+func (s *CfgMirrorSessionState) WatchAll(rsps chan core.WatchState) error {
+	return s.StateDriver.WatchAllState(mirrorSessionConfigPathPrefix, s, json.Unmarshal,
+		rsps)
+}
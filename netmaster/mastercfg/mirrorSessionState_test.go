@@ -0,0 +1,56 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"testing"
+)
+
+func TestCfgMirrorSessionStateMatchesEndpoint(t *testing.T) {
+	ep := &CfgEndpointState{
+		NetID:            GetNwCfgKey("net1", "default"),
+		EndpointID:       "ep1",
+		EndpointGroupKey: GetEndpointGroupKey("web", "default"),
+		ContainerID:      "0123456789ab",
+		EPCommonName:     "web-1",
+	}
+
+	matching := []CfgMirrorSessionState{
+		{Tenant: "default", EndpointGroup: "web"},
+		{Tenant: "default", Network: "net1"},
+		{Tenant: "default", Endpoints: []string{"ep1"}},
+		{Tenant: "default", Endpoints: []string{"ep2", "0123456789ab"}},
+		{Tenant: "default", Endpoints: []string{"web-1"}},
+	}
+	for _, session := range matching {
+		if !session.MatchesEndpoint(ep) {
+			t.Fatalf("session %+v doesn't match endpoint %+v", session, ep)
+		}
+	}
+
+	notMatching := []CfgMirrorSessionState{
+		{Tenant: "default", EndpointGroup: "db"},
+		{Tenant: "blue", EndpointGroup: "web"},
+		{Tenant: "default", Network: "net2"},
+		{Tenant: "default", Endpoints: []string{"ep2", ""}},
+		{Tenant: "default"},
+	}
+	for _, session := range notMatching {
+		if session.MatchesEndpoint(ep) {
+			t.Fatalf("session %+v matches endpoint %+v", session, ep)
+		}
+	}
+}
@@ -62,6 +62,7 @@ type CfgNetworkState struct {
 	IPv6AllocMap  map[string]bool `json:"ipv6AllocMap"`
 	IPv6LastHost  string          `json:"ipv6LastHost"`
 	NetworkTag    string          `json:"networkTag"`
+	Mtu           int             `json:"mtu"`
 }
 
 // Write the state.
@@ -95,14 +96,37 @@ func (s *CfgNetworkState) Clear() error {
 
 // IncrEpCount Increments endpoint count
 func (s *CfgNetworkState) IncrEpCount() error {
-	s.EpCount++
-	return s.Write()
+	return s.addEpCount(1)
 }
 
 // DecrEpCount decrements endpoint count
 func (s *CfgNetworkState) DecrEpCount() error {
-	s.EpCount--
-	return s.Write()
+	return s.addEpCount(-1)
+}
+
+// addEpCount changes the endpoint count of the stored network with a
+// versioned write, so that address allocations made after the network was
+// read are kept. The network is set to the new state.
+func (s *CfgNetworkState) addEpCount(delta int) error {
+	var nwCfg *CfgNetworkState
+
+	err := core.UpdateStates(s.StateDriver, func(txn core.StateDriver) error {
+		nwCfg = &CfgNetworkState{}
+		nwCfg.StateDriver = txn
+		if err := nwCfg.Read(s.ID); err != nil {
+			return err
+		}
+
+		nwCfg.EpCount += delta
+		return nwCfg.Write()
+	})
+	if err != nil {
+		return err
+	}
+
+	nwCfg.StateDriver = s.StateDriver
+	*s = *nwCfg
+	return nil
 }
 
 //GetNwCfgKey returns the key for network state
@@ -0,0 +1,198 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"net"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/contiv/netplugin/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/utils/netutils"
+	"github.com/contiv/ofnet"
+)
+
+// PolicyFlow is the first packet of a flow to evaluate against the policies
+type PolicyFlow struct {
+	SrcEndpointGroup int    // Source endpoint group, zero if unknown
+	DstEndpointGroup int    // Destination endpoint group, zero if unknown
+	SrcIpAddr        string // Source IP address
+	DstIpAddr        string // Destination IP address
+	IpProtocol       uint8  // IP protocol number
+	SrcPort          uint16 // Source port, zero if unknown
+	DstPort          uint16 // Destination port
+}
+
+// PolicyVerdict is the result of evaluating a flow against the policies
+type PolicyVerdict struct {
+	Action       string                 // allow, deny or audit
+	EpgPolicyKey string                 // epg policy of the matching rule
+	Rule         *contivModel.Rule      // matching policy rule, nil if no rule matched
+	OfnetRule    *ofnet.OfnetPolicyRule // compiled rule that matched
+}
+
+// SimulateFlow evaluates a flow against all epg policies in the state store
+func SimulateFlow(stateDriver core.StateDriver, flow *PolicyFlow) (*PolicyVerdict, error) {
+	gp := new(EpgPolicy)
+	gp.StateDriver = stateDriver
+	gpCfgs, err := gp.ReadAll()
+	if err != nil && !strings.Contains(err.Error(), "key not found") {
+		log.Errorf("Error reading epg policies. Err: %v", err)
+		return nil, err
+	}
+
+	var epgps []*EpgPolicy
+	for _, gpCfg := range gpCfgs {
+		epgps = append(epgps, gpCfg.(*EpgPolicy))
+	}
+
+	return evaluateFlow(epgps, flow), nil
+}
+
+// evaluateFlow finds the rule the policy table would apply to a flow. All
+// rules share one table, so the highest priority match wins. When matches
+// overlap at the same priority, the datapath behavior is undefined and the
+// most restrictive rule is reported.
+func evaluateFlow(epgps []*EpgPolicy, flow *PolicyFlow) *PolicyVerdict {
+	// packets that dont match any rule go to the next table
+	verdict := &PolicyVerdict{Action: "allow"}
+
+	for _, gp := range epgps {
+		for _, ruleMap := range gp.RuleMaps {
+			for _, ofnetRule := range ruleMap.OfnetRules {
+				if !ofnetRuleMatchesFlow(ofnetRule, flow) {
+					continue
+				}
+
+				if verdict.OfnetRule != nil && !ofnetRuleIsPreferred(ofnetRule, verdict.OfnetRule) {
+					continue
+				}
+
+				verdict.Action = ofnetRule.Action
+				verdict.EpgPolicyKey = gp.EpgPolicyKey
+				verdict.Rule = ruleMap.Rule
+				verdict.OfnetRule = ofnetRule
+			}
+		}
+	}
+
+	return verdict
+}
+
+// ofnetRuleIsPreferred checks if r1 would be applied instead of r2
+func ofnetRuleIsPreferred(r1, r2 *ofnet.OfnetPolicyRule) bool {
+	if r1.Priority != r2.Priority {
+		return r1.Priority > r2.Priority
+	}
+	if actionRank(r1.Action) != actionRank(r2.Action) {
+		return actionRank(r1.Action) > actionRank(r2.Action)
+	}
+
+	// keep the result stable
+	return r1.RuleId < r2.RuleId
+}
+
+// actionRank orders rule actions from the least to the most restrictive
+func actionRank(action string) int {
+	switch action {
+	case "deny":
+		return 2
+	case "audit":
+		return 1
+	}
+	return 0
+}
+
+// ofnetRuleMatchesFlow checks if an ofnet rule matches the first packet of a flow
+func ofnetRuleMatchesFlow(rule *ofnet.OfnetPolicyRule, flow *PolicyFlow) bool {
+	// endpoint groups
+	if rule.SrcEndpointGroup != 0 && rule.SrcEndpointGroup != flow.SrcEndpointGroup {
+		return false
+	}
+	if rule.DstEndpointGroup != 0 && rule.DstEndpointGroup != flow.DstEndpointGroup {
+		return false
+	}
+
+	// rules match either IPv4 or IPv6 packets
+	ruleIsIPv6 := netutils.IsIPv6(rule.SrcIpAddr) || netutils.IsIPv6(rule.DstIpAddr)
+	flowIsIPv6 := netutils.IsIPv6(flow.SrcIpAddr) || netutils.IsIPv6(flow.DstIpAddr)
+	if ruleIsIPv6 != flowIsIPv6 {
+		return false
+	}
+
+	// ip addresses
+	if rule.SrcIpAddr != "" && !ipAddrMatches(rule.SrcIpAddr, flow.SrcIpAddr) {
+		return false
+	}
+	if rule.DstIpAddr != "" && !ipAddrMatches(rule.DstIpAddr, flow.DstIpAddr) {
+		return false
+	}
+
+	// protocol
+	if rule.IpProtocol != 0 && rule.IpProtocol != flow.IpProtocol {
+		return false
+	}
+
+	// ports
+	if rule.SrcPort != 0 && !portMatches(rule.SrcPort, rule.SrcPortMask, flow.SrcPort) {
+		return false
+	}
+	if rule.DstPort != 0 && !portMatches(rule.DstPort, rule.DstPortMask, flow.DstPort) {
+		return false
+	}
+
+	// the first packet of a tcp flow has syn set and ack cleared
+	if rule.IpProtocol == 6 && rule.TcpFlags != "" {
+		switch rule.TcpFlags {
+		case "syn", "syn,!ack":
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// ipAddrMatches checks if an ip address is within a rule address or subnet
+func ipAddrMatches(ruleAddr, ipAddr string) bool {
+	ip := net.ParseIP(ipAddr)
+	if ip == nil {
+		return false
+	}
+
+	if !strings.Contains(ruleAddr, "/") {
+		return ip.Equal(net.ParseIP(ruleAddr))
+	}
+
+	_, ipNet, err := net.ParseCIDR(ruleAddr)
+	if err != nil {
+		return false
+	}
+
+	return ipNet.Contains(ip)
+}
+
+// portMatches checks a port against a rule port and mask. A zero mask is an
+// exact match, same as in ofnet
+func portMatches(rulePort, ruleMask, port uint16) bool {
+	if ruleMask == 0 {
+		ruleMask = 0xffff
+	}
+
+	return rulePort&ruleMask == port&ruleMask
+}
@@ -0,0 +1,117 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"testing"
+
+	"github.com/contiv/netplugin/contivmodel"
+	"github.com/contiv/ofnet"
+)
+
+func newTestRuleMap(ruleID string, ofnetRules ...*ofnet.OfnetPolicyRule) *RuleMap {
+	ruleMap := &RuleMap{
+		Rule:       &contivModel.Rule{TenantName: "default", PolicyName: "policy1", RuleID: ruleID},
+		OfnetRules: make(map[string]*ofnet.OfnetPolicyRule),
+	}
+	for _, ofnetRule := range ofnetRules {
+		ruleMap.OfnetRules[ofnetRule.RuleId] = ofnetRule
+	}
+
+	return ruleMap
+}
+
+func TestEvaluateFlow(t *testing.T) {
+	gp := &EpgPolicy{
+		EpgPolicyKey:    "default:group1:default:policy1",
+		EndpointGroupID: 1,
+		RuleMaps: map[string]*RuleMap{
+			// allow tcp 80 into group1
+			"default:policy1:1": newTestRuleMap("1", &ofnet.OfnetPolicyRule{
+				RuleId:           "r1",
+				Priority:         10,
+				DstEndpointGroup: 1,
+				IpProtocol:       6,
+				DstPort:          80,
+				Action:           "allow",
+			}),
+			// deny tcp into group1
+			"default:policy1:2": newTestRuleMap("2", &ofnet.OfnetPolicyRule{
+				RuleId:           "r2",
+				Priority:         1,
+				DstEndpointGroup: 1,
+				IpProtocol:       6,
+				TcpFlags:         "syn,!ack",
+				Action:           "deny",
+			}),
+			// deny ports 30000-30015 from a subnet
+			"default:policy1:3": newTestRuleMap("3", &ofnet.OfnetPolicyRule{
+				RuleId:           "r3",
+				Priority:         10,
+				DstEndpointGroup: 1,
+				SrcIpAddr:        "10.2.0.0/16",
+				IpProtocol:       6,
+				DstPort:          30000,
+				DstPortMask:      0xfff0,
+				Action:           "deny",
+			}),
+		},
+	}
+	epgps := []*EpgPolicy{gp}
+
+	testData := []struct {
+		flow    PolicyFlow
+		action  string
+		ruleID  string
+		matched bool
+	}{
+		{PolicyFlow{DstEndpointGroup: 1, IpProtocol: 6, DstPort: 80}, "allow", "1", true},
+		{PolicyFlow{DstEndpointGroup: 1, IpProtocol: 6, DstPort: 443}, "deny", "2", true},
+		{PolicyFlow{DstEndpointGroup: 2, IpProtocol: 6, DstPort: 443}, "allow", "", false},
+		{PolicyFlow{DstEndpointGroup: 1, IpProtocol: 17, DstPort: 53}, "allow", "", false},
+		{PolicyFlow{DstEndpointGroup: 1, SrcIpAddr: "10.2.1.1", IpProtocol: 6, DstPort: 30010}, "deny", "3", true},
+		{PolicyFlow{DstEndpointGroup: 1, SrcIpAddr: "10.3.1.1", IpProtocol: 6, DstPort: 30010}, "deny", "2", true},
+		// ipv4 rules dont match ipv6 packets
+		{PolicyFlow{DstEndpointGroup: 1, SrcIpAddr: "2001:db8::1", IpProtocol: 6, DstPort: 80}, "allow", "", false},
+	}
+
+	for _, td := range testData {
+		verdict := evaluateFlow(epgps, &td.flow)
+		if verdict.Action != td.action {
+			t.Fatalf("Flow %+v got action %s, expected %s", td.flow, verdict.Action, td.action)
+		}
+		if (verdict.Rule != nil) != td.matched {
+			t.Fatalf("Flow %+v matched rule %+v, expected match: %v", td.flow, verdict.Rule, td.matched)
+		}
+		if td.matched && verdict.Rule.RuleID != td.ruleID {
+			t.Fatalf("Flow %+v matched rule %s, expected %s", td.flow, verdict.Rule.RuleID, td.ruleID)
+		}
+	}
+
+	// deny wins when rules overlap at the same priority
+	gp.RuleMaps["default:policy1:4"] = newTestRuleMap("4", &ofnet.OfnetPolicyRule{
+		RuleId:           "r4",
+		Priority:         10,
+		DstEndpointGroup: 1,
+		IpProtocol:       6,
+		DstPort:          80,
+		Action:           "deny",
+	})
+	verdict := evaluateFlow(epgps, &PolicyFlow{DstEndpointGroup: 1, IpProtocol: 6, DstPort: 80})
+	if verdict.Action != "deny" || verdict.Rule.RuleID != "4" {
+		t.Fatalf("Overlapping rules got verdict %+v", verdict)
+	}
+}
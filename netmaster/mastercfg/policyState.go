@@ -20,11 +20,13 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 
 	"github.com/contiv/netplugin/contivmodel"
 	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/utils/netutils"
 	"github.com/contiv/ofnet"
 )
 
@@ -33,10 +35,15 @@ const (
 	policyConfigPath       = policyConfigPathPrefix + "%s"
 )
 
+// BaselinePriorityOffset moves baseline policy rules above all tenant rules,
+// whose priorities are at most 100
+const BaselinePriorityOffset = 100
+
 // RuleMap maps a policy rule to list of ofnet rules
 type RuleMap struct {
 	Rule       *contivModel.Rule                 // policy rule
 	OfnetRules map[string]*ofnet.OfnetPolicyRule // Ofnet rules associated with this policy rule
+	Generation int                               // incremented every time the rule is updated
 }
 
 // EpgPolicy has an instance of policy attached to an endpoint group
@@ -137,8 +144,9 @@ func restoreEpgPolicies(stateDriver core.StateDriver) error {
 				// delete the entry from the map so that we can add it back
 				delete(epgp.RuleMaps, ruleKey)
 
-				// Add the rule to epg Policy
-				err := epgp.AddRule(ruleMap.Rule)
+				// Add the rule to epg Policy, keep the generation so that
+				// ofnet rule ids stay the same
+				err := epgp.addRule(ruleMap.Rule, ruleMap.Generation)
 				if err != nil {
 					log.Errorf("Error restoring rule %s. Err: %v", ruleKey, err)
 					return err
@@ -163,12 +171,37 @@ func (gp *EpgPolicy) Delete() error {
 	return gp.Clear()
 }
 
-// createOfnetRule creates a directional ofnet rule
-func (gp *EpgPolicy) createOfnetRule(rule *contivModel.Rule, dir string) (*ofnet.OfnetPolicyRule, error) {
+// ParseOfnetRuleID returns the keys of the epg policy and the policy rule
+// an ofnet rule was created for
+func ParseOfnetRuleID(ofnetRuleID string) (string, string, error) {
+	// ofnet rule ids are <epgpKey>:<ruleKey>:<dir>[:...], where epgpKey is
+	// tenant:group:tenant:policy and ruleKey is tenant:policy:ruleId
+	fields := strings.Split(ofnetRuleID, ":")
+	if len(fields) < 8 {
+		return "", "", core.Errorf("invalid ofnet rule id %s", ofnetRuleID)
+	}
+
+	return strings.Join(fields[0:4], ":"), strings.Join(fields[4:7], ":"), nil
+}
+
+// createOfnetRule creates a directional ofnet rule for a port match
+func (gp *EpgPolicy) createOfnetRule(rule *contivModel.Rule, dir string, generation int,
+	portMatch netutils.PortMatch) (*ofnet.OfnetPolicyRule, error) {
 	var remoteEpgID int
 	var err error
 
 	ruleID := gp.EpgPolicyKey + ":" + rule.Key + ":" + dir
+	if generation > 0 {
+		// updated rules get a new id, so that they can co-exist with
+		// the rule they replace
+		ruleID = ruleID + ":" + strconv.Itoa(generation)
+	}
+
+	// a mask of all ones is an exact port match in ofnet
+	portMask := portMatch.Mask
+	if portMask == 0xffff {
+		portMask = 0
+	}
 
 	// Create an ofnet rule
 	ofnetRule := new(ofnet.OfnetPolicyRule)
@@ -176,6 +209,16 @@ func (gp *EpgPolicy) createOfnetRule(rule *contivModel.Rule, dir string) (*ofnet
 	ofnetRule.Priority = rule.Priority
 	ofnetRule.Action = rule.Action
 
+	// baseline rules are evaluated ahead of tenant rules
+	if policyIsBaseline(rule) {
+		ofnetRule.Priority += BaselinePriorityOffset
+	}
+
+	// deny rules of policies in audit mode only count the traffic
+	if rule.Action == "deny" && policyIsAudited(rule) {
+		ofnetRule.Action = "audit"
+	}
+
 	// See if user specified an endpoint Group in the rule
 	if rule.FromEndpointGroup != "" {
 		remoteEpgID, err = GetEndpointGroupID(stateStore, rule.FromEndpointGroup, rule.TenantName)
@@ -244,10 +287,11 @@ func (gp *EpgPolicy) createOfnetRule(rule *contivModel.Rule, dir string) (*ofnet
 		}
 
 		// set port numbers
-		ofnetRule.DstPort = uint16(rule.Port)
+		ofnetRule.DstPort = portMatch.Port
+		ofnetRule.DstPortMask = portMask
 
 		// set tcp flags
-		if rule.Protocol == "tcp" && rule.Port == 0 {
+		if rule.Protocol == "tcp" && !ruleHasPorts(rule) {
 			ofnetRule.TcpFlags = "syn,!ack"
 		}
 	case "inTx":
@@ -262,7 +306,8 @@ func (gp *EpgPolicy) createOfnetRule(rule *contivModel.Rule, dir string) (*ofnet
 		}
 
 		// set port numbers
-		ofnetRule.SrcPort = uint16(rule.Port)
+		ofnetRule.SrcPort = portMatch.Port
+		ofnetRule.SrcPortMask = portMask
 	case "outRx":
 		// Set src/dest endpoint group
 		ofnetRule.DstEndpointGroup = gp.EndpointGroupID
@@ -270,9 +315,13 @@ func (gp *EpgPolicy) createOfnetRule(rule *contivModel.Rule, dir string) (*ofnet
 
 		// Set src/dest IP Address
 		ofnetRule.SrcIpAddr = rule.ToIpAddress
+		if len(rule.FromIpAddress) > 0 {
+			ofnetRule.DstIpAddr = rule.FromIpAddress
+		}
 
 		// set port numbers
-		ofnetRule.SrcPort = uint16(rule.Port)
+		ofnetRule.SrcPort = portMatch.Port
+		ofnetRule.SrcPortMask = portMask
 	case "outTx":
 		// Set src/dest endpoint group
 		ofnetRule.SrcEndpointGroup = gp.EndpointGroupID
@@ -280,63 +329,195 @@ func (gp *EpgPolicy) createOfnetRule(rule *contivModel.Rule, dir string) (*ofnet
 
 		// Set src/dest IP Address
 		ofnetRule.DstIpAddr = rule.ToIpAddress
+		if len(rule.FromIpAddress) > 0 {
+			ofnetRule.SrcIpAddr = rule.FromIpAddress
+		}
 
 		// set port numbers
-		ofnetRule.DstPort = uint16(rule.Port)
+		ofnetRule.DstPort = portMatch.Port
+		ofnetRule.DstPortMask = portMask
 
 		// set tcp flags
-		if rule.Protocol == "tcp" && rule.Port == 0 {
+		if rule.Protocol == "tcp" && !ruleHasPorts(rule) {
 			ofnetRule.TcpFlags = "syn,!ack"
 		}
 	default:
 		log.Fatalf("Unknown rule direction %s", dir)
 	}
 
+	return ofnetRule, nil
+}
+
+// installOfnetRule adds an ofnet rule to policyDB and netplugin agents
+func installOfnetRule(ofnetRule *ofnet.OfnetPolicyRule) error {
 	// Add the Rule to policyDB
-	err = ofnetMaster.AddRule(ofnetRule)
+	err := ofnetMaster.AddRule(ofnetRule)
 	if err != nil {
 		log.Errorf("Error creating rule {%+v}. Err: %v", ofnetRule, err)
-		return nil, err
+		return err
 	}
 
 	// Send AddRule to netplugin agents
 	err = addPolicyRuleState(ofnetRule)
 	if err != nil {
 		log.Errorf("Error creating rule {%+v}. Err: %v", ofnetRule, err)
-		return nil, err
+		ofnetMaster.DelRule(ofnetRule)
+		return err
 	}
 
 	log.Infof("Added rule {%+v} to policyDB", ofnetRule)
 
-	return ofnetRule, nil
+	return nil
 }
 
-// AddRule adds a rule to epg policy
-func (gp *EpgPolicy) AddRule(rule *contivModel.Rule) error {
-	var dirs []string
+// replaceOfnetRule replaces an ofnet rule with one matching the same traffic
+func replaceOfnetRule(oldRule, newRule *ofnet.OfnetPolicyRule) error {
+	// Replace the rule in policyDB
+	err := ofnetMaster.ReplaceRule(oldRule, newRule)
+	if err != nil {
+		log.Errorf("Error replacing rule {%+v} with {%+v}. Err: %v", oldRule, newRule, err)
+		return err
+	}
 
-	// check if the rule exists already
-	if gp.RuleMaps[rule.Key] != nil {
-		// FIXME: see if we can update the rule
-		return core.Errorf("Rule already exists")
+	// Update the rule state of netplugin agents
+	err = addPolicyRuleState(newRule)
+	if err != nil {
+		log.Errorf("Error creating rule {%+v}. Err: %v", newRule, err)
+		return err
+	}
+	err = delPolicyRuleState(oldRule)
+	if err != nil {
+		log.Errorf("Error deleting the ofnet rule {%+v}. Err: %v", oldRule, err)
+	}
+
+	log.Infof("Replaced rule {%+v} with {%+v} in policyDB", oldRule, newRule)
+
+	return nil
+}
+
+// uninstallOfnetRule removes an ofnet rule from policyDB and netplugin agents
+func uninstallOfnetRule(ofnetRule *ofnet.OfnetPolicyRule) {
+	log.Infof("Deleting rule {%+v} from policyDB", ofnetRule)
+
+	// Delete the rule from policyDB
+	err := ofnetMaster.DelRule(ofnetRule)
+	if err != nil {
+		log.Errorf("Error deleting the ofnet rule {%+v}. Err: %v", ofnetRule, err)
+	}
+
+	// Send DelRule to netplugin agents
+	err = delPolicyRuleState(ofnetRule)
+	if err != nil {
+		log.Errorf("Error deleting the ofnet rule {%+v}. Err: %v", ofnetRule, err)
 	}
+}
+
+// ofnetRuleMatchIsSame checks if two ofnet rules match the same traffic.
+// Such rules map to the same flow and can not be installed together.
+func ofnetRuleMatchIsSame(r1, r2 *ofnet.OfnetPolicyRule) bool {
+	m1, m2 := *r1, *r2
+	m1.RuleId, m2.RuleId = "", ""
+	m1.Action, m2.Action = "", ""
+	return m1 == m2
+}
+
+// policyIsAudited checks if the policy of a rule is in audit mode
+func policyIsAudited(rule *contivModel.Rule) bool {
+	policy := contivModel.FindPolicy(rule.TenantName + ":" + rule.PolicyName)
+	return policy != nil && policy.Mode == "audit"
+}
+
+// policyIsBaseline checks if the policy of a rule is the global baseline policy
+func policyIsBaseline(rule *contivModel.Rule) bool {
+	global := contivModel.FindGlobal("global")
+	return global != nil && global.BaselinePolicy != "" &&
+		rule.TenantName == "default" && rule.PolicyName == global.BaselinePolicy
+}
+
+// ruleHasPorts checks if a rule matches on tcp/udp ports
+func ruleHasPorts(rule *contivModel.Rule) bool {
+	return rule.Port != 0 || rule.Ports != ""
+}
+
+// rulePortMatches returns the masked port matches of a rule. Rules
+// without ports get a single empty match.
+func rulePortMatches(rule *contivModel.Rule) ([]netutils.PortMatch, error) {
+	ports := rule.Ports
+	if rule.Port != 0 {
+		if ports != "" {
+			ports = strconv.Itoa(rule.Port) + "," + ports
+		} else {
+			ports = strconv.Itoa(rule.Port)
+		}
+	}
+
+	if ports == "" {
+		return []netutils.PortMatch{{}}, nil
+	}
+
+	return netutils.ParsePortList(ports)
+}
+
+// ruleAddressMatches returns a copy of the rule for each address in the
+// address set the rule matches. Addresses of a different IP family than
+// the rule's own IP address can never match and are skipped. Rules
+// without an address set are returned as is.
+func ruleAddressMatches(rule *contivModel.Rule) ([]*contivModel.Rule, error) {
+	setName := rule.FromAddressSet
+	otherAddr := rule.ToIpAddress
+	if setName == "" {
+		setName = rule.ToAddressSet
+		otherAddr = rule.FromIpAddress
+	}
+	if setName == "" {
+		return []*contivModel.Rule{rule}, nil
+	}
+
+	setKey := rule.TenantName + ":" + setName
+	addrSet := contivModel.FindAddressSet(setKey)
+	if addrSet == nil {
+		log.Errorf("Address set %s not found", setKey)
+		return nil, errors.New("the address set wasn't found")
+	}
+
+	rules := []*contivModel.Rule{}
+	for _, addr := range addrSet.Addresses {
+		if otherAddr != "" && netutils.IsIPv6(addr) != netutils.IsIPv6(otherAddr) {
+			continue
+		}
+
+		addrRule := *rule
+		if rule.FromAddressSet != "" {
+			addrRule.FromIpAddress = addr
+		} else {
+			addrRule.ToIpAddress = addr
+		}
+		rules = append(rules, &addrRule)
+	}
+
+	return rules, nil
+}
+
+// ruleDirections returns the directional ofnet rules needed for a rule
+func ruleDirections(rule *contivModel.Rule) []string {
+	var dirs []string
 
 	// Figure out all the directional rules we need to install
 	switch rule.Direction {
 	case "in":
-		if (rule.Protocol == "udp" || rule.Protocol == "tcp") && rule.Port != 0 {
+		if (rule.Protocol == "udp" || rule.Protocol == "tcp") && ruleHasPorts(rule) {
 			dirs = []string{"inRx", "inTx"}
 		} else {
 			dirs = []string{"inRx"}
 		}
 	case "out":
-		if (rule.Protocol == "udp" || rule.Protocol == "tcp") && rule.Port != 0 {
+		if (rule.Protocol == "udp" || rule.Protocol == "tcp") && ruleHasPorts(rule) {
 			dirs = []string{"outRx", "outTx"}
 		} else {
 			dirs = []string{"outTx"}
 		}
 	case "both":
-		if (rule.Protocol == "udp" || rule.Protocol == "tcp") && rule.Port != 0 {
+		if (rule.Protocol == "udp" || rule.Protocol == "tcp") && ruleHasPorts(rule) {
 			dirs = []string{"inRx", "inTx", "outRx", "outTx"}
 		} else {
 			dirs = []string{"inRx", "outTx"}
@@ -344,25 +525,200 @@ func (gp *EpgPolicy) AddRule(rule *contivModel.Rule) error {
 
 	}
 
+	return dirs
+}
+
+// createRuleMap builds all ofnet rules of a policy rule
+func (gp *EpgPolicy) createRuleMap(rule *contivModel.Rule, generation int) (*RuleMap, error) {
 	// create a ruleMap
 	ruleMap := new(RuleMap)
 	ruleMap.OfnetRules = make(map[string]*ofnet.OfnetPolicyRule)
 	ruleMap.Rule = rule
+	ruleMap.Generation = generation
+
+	portMatches, err := rulePortMatches(rule)
+	if err != nil {
+		log.Errorf("Error parsing ports of rule {%+v}. Err: %v", rule, err)
+		return nil, err
+	}
+
+	addrRules, err := ruleAddressMatches(rule)
+	if err != nil {
+		log.Errorf("Error finding addresses of rule {%+v}. Err: %v", rule, err)
+		return nil, err
+	}
+
+	// Create ofnet rules, one per direction, address and port match
+	for _, dir := range ruleDirections(rule) {
+		for addrIdx, addrRule := range addrRules {
+			for portIdx, portMatch := range portMatches {
+				ofnetRule, err := gp.createOfnetRule(addrRule, dir, generation, portMatch)
+				if err != nil {
+					log.Errorf("Error creating %s ofnet rule for {%+v}. Err: %v", dir, rule, err)
+					return nil, err
+				}
+				if len(addrRules) > 1 {
+					ofnetRule.RuleId = ofnetRule.RuleId + ":a" + strconv.Itoa(addrIdx)
+				}
+				if len(portMatches) > 1 {
+					ofnetRule.RuleId = ofnetRule.RuleId + ":p" + strconv.Itoa(portIdx)
+				}
+
+				// add it to the rule map
+				ruleMap.OfnetRules[ofnetRule.RuleId] = ofnetRule
+			}
+		}
+	}
+
+	return ruleMap, nil
+}
+
+// AddRule adds a rule to epg policy
+func (gp *EpgPolicy) AddRule(rule *contivModel.Rule) error {
+	return gp.addRule(rule, 0)
+}
+
+// addRule adds a rule with specific generation to epg policy
+func (gp *EpgPolicy) addRule(rule *contivModel.Rule, generation int) error {
+	// check if the rule exists already
+	if gp.RuleMaps[rule.Key] != nil {
+		return core.Errorf("Rule already exists")
+	}
+
+	ruleMap, err := gp.createRuleMap(rule, generation)
+	if err != nil {
+		return err
+	}
+
+	// Install ofnet rules
+	installed := []*ofnet.OfnetPolicyRule{}
+	for _, ofnetRule := range ruleMap.OfnetRules {
+		if err := installOfnetRule(ofnetRule); err != nil {
+			for _, r := range installed {
+				uninstallOfnetRule(r)
+			}
+			return err
+		}
+		installed = append(installed, ofnetRule)
+	}
+
+	// save the rulemap
+	gp.RuleMaps[rule.Key] = ruleMap
+
+	return nil
+}
+
+// RuleUpdate is a new version of a rule in an epg policy. It is compiled
+// when prepared, and only installed when applied.
+type RuleUpdate struct {
+	Policy *EpgPolicy // epg policy of the rule
+	oldMap *RuleMap   // installed version of the rule
+	newMap *RuleMap   // compiled new version of the rule
+}
+
+// PrepareRuleUpdate compiles the new version of a rule in epg policy,
+// without installing it
+func (gp *EpgPolicy) PrepareRuleUpdate(rule *contivModel.Rule) (*RuleUpdate, error) {
+	// check if the rule exists
+	oldMap := gp.RuleMaps[rule.Key]
+	if oldMap == nil {
+		return nil, core.Errorf("Rule does not exists")
+	}
+
+	newMap, err := gp.createRuleMap(rule, oldMap.Generation+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RuleUpdate{Policy: gp, oldMap: oldMap, newMap: newMap}, nil
+}
+
+// Apply installs the new version of the rule in place of the old one
+func (ru *RuleUpdate) Apply() error {
+	return ru.Policy.swapRuleMap(ru.oldMap, ru.newMap)
+}
+
+// Revert restores the old version of an applied rule. The old ofnet rules
+// are installed as they were, they are not compiled again.
+func (ru *RuleUpdate) Revert() error {
+	return ru.Policy.swapRuleMap(ru.newMap, ru.oldMap)
+}
+
+// UpdateRule replaces a rule in epg policy with its new version.
+// New ofnet rules are installed before the old ones are removed, so
+// traffic is always matched by either version of the rule. Rules that
+// differ only in action map to the same flow, those are replaced in place.
+func (gp *EpgPolicy) UpdateRule(rule *contivModel.Rule) error {
+	ruleUpdate, err := gp.PrepareRuleUpdate(rule)
+	if err != nil {
+		return err
+	}
+
+	return ruleUpdate.Apply()
+}
+
+// swapRuleMap installs the ofnet rules of a rule map and removes the ones
+// of the rule map it replaces
+func (gp *EpgPolicy) swapRuleMap(oldMap, newMap *RuleMap) error {
+	// find old ofnet rules that conflict with new ones
+	conflicts := make(map[string]*ofnet.OfnetPolicyRule)
+	for newID, newRule := range newMap.OfnetRules {
+		for _, oldRule := range oldMap.OfnetRules {
+			if ofnetRuleMatchIsSame(newRule, oldRule) {
+				conflicts[newID] = oldRule
+			}
+		}
+	}
 
-	// Create ofnet rules
-	for _, dir := range dirs {
-		ofnetRule, err := gp.createOfnetRule(rule, dir)
+	installed := []*ofnet.OfnetPolicyRule{}
+	replaced := make(map[string]*ofnet.OfnetPolicyRule)
+	rollback := func() {
+		for _, r := range installed {
+			uninstallOfnetRule(r)
+		}
+		for newID, oldRule := range replaced {
+			if err := replaceOfnetRule(newMap.OfnetRules[newID], oldRule); err != nil {
+				log.Errorf("Error restoring rule {%+v}. Err: %v", oldRule, err)
+			}
+		}
+	}
+
+	// install the new rules, conflicting ones replace the old rule in place
+	for newID, newRule := range newMap.OfnetRules {
+		var err error
+		if oldRule, isConflict := conflicts[newID]; isConflict {
+			err = replaceOfnetRule(oldRule, newRule)
+			if err == nil {
+				replaced[newID] = oldRule
+			}
+		} else {
+			err = installOfnetRule(newRule)
+			if err == nil {
+				installed = append(installed, newRule)
+			}
+		}
 		if err != nil {
-			log.Errorf("Error creating %s ofnet rule for {%+v}. Err: %v", dir, rule, err)
+			log.Errorf("Error updating rule %s in epg policy %s. Err: %v", newMap.Rule.Key, gp.EpgPolicyKey, err)
+			rollback()
 			return err
 		}
+	}
 
-		// add it to the rule map
-		ruleMap.OfnetRules[ofnetRule.RuleId] = ofnetRule
+	// remove the rest of old rules
+	for _, oldRule := range oldMap.OfnetRules {
+		isReplaced := false
+		for _, r := range replaced {
+			if r.RuleId == oldRule.RuleId {
+				isReplaced = true
+			}
+		}
+		if !isReplaced {
+			uninstallOfnetRule(oldRule)
+		}
 	}
 
 	// save the rulemap
-	gp.RuleMaps[rule.Key] = ruleMap
+	gp.RuleMaps[newMap.Rule.Key] = newMap
 
 	return nil
 }
@@ -377,19 +733,7 @@ func (gp *EpgPolicy) DelRule(rule *contivModel.Rule) error {
 
 	// Delete each ofnet rule under this policy rule
 	for _, ofnetRule := range ruleMap.OfnetRules {
-		log.Infof("Deleting rule {%+v} from policyDB", ofnetRule)
-
-		// Delete the rule from policyDB
-		err := ofnetMaster.DelRule(ofnetRule)
-		if err != nil {
-			log.Errorf("Error deleting the ofnet rule {%+v}. Err: %v", ofnetRule, err)
-		}
-
-		// Send DelRule to netplugin agents
-		err = delPolicyRuleState(ofnetRule)
-		if err != nil {
-			log.Errorf("Error deleting the ofnet rule {%+v}. Err: %v", ofnetRule, err)
-		}
+		uninstallOfnetRule(ofnetRule)
 	}
 
 	// delete the cache
@@ -0,0 +1,99 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import (
+	"testing"
+
+	"github.com/contiv/netplugin/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/state"
+	"github.com/contiv/ofnet"
+)
+
+// checkRuleState verifies which ofnet rules of a policy rule are installed
+func checkRuleState(t *testing.T, ruleID string, installed bool) {
+	ruleCfg := &CfgPolicyRule{}
+	ruleCfg.StateDriver = stateStore
+	err := ruleCfg.Read(ruleID)
+	if installed && err != nil {
+		t.Fatalf("Rule %s not installed. Err: %v", ruleID, err)
+	}
+	if !installed && err == nil {
+		t.Fatalf("Rule %s still installed", ruleID)
+	}
+}
+
+func TestRuleUpdateRevert(t *testing.T) {
+	stateDriver := &state.FakeStateDriver{}
+	stateDriver.Init(&core.InstanceInfo{})
+	ofm := ofnet.NewOfnetMaster("127.0.0.1", 9051)
+	defer ofm.Delete()
+	stateStore = stateDriver
+	ofnetMaster = ofm
+
+	gp := &EpgPolicy{
+		EpgPolicyKey:    "default:group1:default:policy1",
+		EndpointGroupID: 1,
+		RuleMaps:        make(map[string]*RuleMap),
+	}
+	rule := &contivModel.Rule{
+		Key:        "default:policy1:1",
+		TenantName: "default",
+		PolicyName: "policy1",
+		RuleID:     "1",
+		Direction:  "in",
+		Priority:   1,
+		Protocol:   "tcp",
+		Port:       80,
+		Action:     "allow",
+	}
+	if err := gp.AddRule(rule); err != nil {
+		t.Fatalf("Error adding rule. Err: %v", err)
+	}
+	oldID := gp.EpgPolicyKey + ":" + rule.Key + ":inRx"
+	newID := oldID + ":1"
+
+	newRule := *rule
+	newRule.Priority = 2
+	ruleUpdate, err := gp.PrepareRuleUpdate(&newRule)
+	if err != nil {
+		t.Fatalf("Error preparing rule update. Err: %v", err)
+	}
+
+	// preparing only compiles the rule
+	checkRuleState(t, oldID, true)
+	checkRuleState(t, newID, false)
+
+	if err := ruleUpdate.Apply(); err != nil {
+		t.Fatalf("Error applying rule update. Err: %v", err)
+	}
+	checkRuleState(t, oldID, false)
+	checkRuleState(t, newID, true)
+	if gp.RuleMaps[rule.Key].OfnetRules[newID].Priority != 2 {
+		t.Fatalf("Rule not updated: %+v", gp.RuleMaps[rule.Key])
+	}
+
+	// the old ofnet rules are installed as they were
+	if err := ruleUpdate.Revert(); err != nil {
+		t.Fatalf("Error reverting rule update. Err: %v", err)
+	}
+	checkRuleState(t, oldID, true)
+	checkRuleState(t, newID, false)
+	if gp.RuleMaps[rule.Key].OfnetRules[oldID].Priority != 1 {
+		t.Fatalf("Rule not reverted: %+v", gp.RuleMaps[rule.Key])
+	}
+}
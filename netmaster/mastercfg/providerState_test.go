@@ -86,6 +86,28 @@ func (d *testSvcProviderStateDriver) WriteState(key string, value core.State,
 	return d.validateKey(key)
 }
 
+func (d *testSvcProviderStateDriver) ReadVersion(key string) ([]byte, uint64, error) {
+	return []byte{}, 0, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testSvcProviderStateDriver) WriteCAS(key string, value []byte, version uint64) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testSvcProviderStateDriver) Commit(ops []core.StateOp) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testSvcProviderStateDriver) ReadStateVersion(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	return 0, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testSvcProviderStateDriver) WriteStateCAS(key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
 func TestSvcProviderRead(t *testing.T) {
 	svcProviderCfg := &SvcProvider{}
 	svcProviderCfg.StateDriver = svcProviderStateDriver
@@ -0,0 +1,35 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mastercfg
+
+import "github.com/contiv/netplugin/core"
+
+// register the schemas of the config states. Migrations of a state are
+// added to its RegisterSchema call when its fields change.
+func init() {
+	core.RegisterSchema(networkConfigPathPrefix, &CfgNetworkState{})
+	core.RegisterSchema(endpointConfigPathPrefix, &CfgEndpointState{})
+	core.RegisterSchema(epGroupConfigPathPrefix, &EndpointGroupState{})
+	core.RegisterSchema(policyConfigPathPrefix, &EpgPolicy{})
+	core.RegisterSchema(policyRuleConfigPathPrefix, &CfgPolicyRule{})
+	core.RegisterSchema(serviceLBConfigPathPrefix, &CfgServiceLBState{})
+	core.RegisterSchema(svcProviderPathPrefix, &SvcProvider{})
+	core.RegisterSchema(bgpConfigPathPrefix, &CfgBgpState{})
+	core.RegisterSchema(globalConfigPathPrefix, &GlobConfig{})
+	core.RegisterSchema(encryptionConfigPathPrefix, &CfgEncryptionState{})
+	core.RegisterSchema(encryptionHostConfigPathPrefix, &CfgEncryptionHostState{})
+	core.RegisterSchema(mirrorSessionConfigPathPrefix, &CfgMirrorSessionState{})
+}
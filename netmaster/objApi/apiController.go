@@ -101,6 +101,8 @@ func NewAPIController(router *mux.Router, objdbClient objdb.API, configs *APICon
 	contivModel.RegisterEndpointCallbacks(ctrler)
 	contivModel.RegisterNetprofileCallbacks(ctrler)
 	contivModel.RegisterAciGwCallbacks(ctrler)
+	contivModel.RegisterAddressSetCallbacks(ctrler)
+	contivModel.RegisterMirrorSessionCallbacks(ctrler)
 	// Register routes
 	contivModel.AddRoutes(router)
 
@@ -194,6 +196,19 @@ func (ac *APIController) GlobalGetOper(global *contivModel.GlobalInspect) error
 	global.Oper.VlansInUse = vlansInUse
 	global.Oper.VxlansInUse = vxlansInUse
 	global.Oper.ClusterMode = master.GetClusterMode()
+
+	encCfg, err := master.GetEncryption(stateDriver)
+	if err != nil {
+		log.Errorf("Error obtaining the encryption state")
+		return err
+	}
+
+	global.Oper.EncryptionKeyGeneration = encCfg.KeyGeneration
+	global.Oper.EncryptionStatus, err = ac.getEncryptionStatus()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -215,6 +230,7 @@ func (ac *APIController) GlobalCreate(global *contivModel.Global) error {
 		FwdMode:     global.FwdMode,
 		ArpMode:     global.ArpMode,
 		PvtSubnet:   global.PvtSubnet,
+		Encryption:  global.Encryption,
 	}
 
 	// Create the object
@@ -224,6 +240,26 @@ func (ac *APIController) GlobalCreate(global *contivModel.Global) error {
 		return err
 	}
 
+	// attach the baseline policy to all endpoint groups
+	if global.BaselinePolicy != "" {
+		epgs, err := allEndpointGroups()
+		if err != nil {
+			return err
+		}
+
+		err = updateImplicitPolicy(epgs, "", baselinePolicyKey(global.BaselinePolicy))
+		if err != nil {
+			log.Errorf("Error attaching baseline policy %s. Err: %v", global.BaselinePolicy, err)
+			return err
+		}
+
+		// groups listing the policy explicitly have its rules at tenant priorities
+		err = updateBaselineRules("", global.BaselinePolicy)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -296,11 +332,49 @@ func (ac *APIController) GlobalUpdate(global, params *contivModel.Global) error
 		}
 		globalCfg.PvtSubnet = params.PvtSubnet
 	}
+	if global.Encryption != params.Encryption {
+		globalCfg.Encryption = params.Encryption
+		if globalCfg.Encryption == "" {
+			globalCfg.Encryption = mastercfg.EncryptionModeNone
+		}
+	}
+
+	// move all endpoint groups to the new baseline policy
+	oldBaseline := global.BaselinePolicy
+	var epgs []*contivModel.EndpointGroup
+	if global.BaselinePolicy != params.BaselinePolicy {
+		epgs, err = allEndpointGroups()
+		if err != nil {
+			return err
+		}
+
+		global.BaselinePolicy = params.BaselinePolicy
+		err = updateImplicitPolicy(epgs, baselinePolicyKey(oldBaseline), baselinePolicyKey(params.BaselinePolicy))
+		if err != nil {
+			log.Errorf("Error changing baseline policy to %s. Err: %v", params.BaselinePolicy, err)
+			global.BaselinePolicy = oldBaseline
+			return err
+		}
+
+		// move the rules of both policies to their new priorities
+		err = updateBaselineRules(oldBaseline, params.BaselinePolicy)
+		if err != nil {
+			global.BaselinePolicy = oldBaseline
+			updateImplicitPolicy(epgs, baselinePolicyKey(params.BaselinePolicy), baselinePolicyKey(oldBaseline))
+			updateBaselineRules(params.BaselinePolicy, oldBaseline)
+			return err
+		}
+	}
 
 	// Create the object
 	err = master.UpdateGlobal(stateDriver, &globalCfg)
 	if err != nil {
 		log.Errorf("Error creating global config {%+v}. Err: %v", global, err)
+		if oldBaseline != global.BaselinePolicy {
+			global.BaselinePolicy = oldBaseline
+			updateImplicitPolicy(epgs, baselinePolicyKey(params.BaselinePolicy), baselinePolicyKey(oldBaseline))
+			updateBaselineRules(params.BaselinePolicy, oldBaseline)
+		}
 		return err
 	}
 
@@ -310,6 +384,7 @@ func (ac *APIController) GlobalUpdate(global, params *contivModel.Global) error
 	global.FwdMode = params.FwdMode
 	global.ArpMode = params.ArpMode
 	global.PvtSubnet = params.PvtSubnet
+	global.Encryption = params.Encryption
 
 	return nil
 }
@@ -607,6 +682,9 @@ func endpointGroupCleanup(endpointGroup *contivModel.EndpointGroup) error {
 		policy.Write()
 	}
 
+	// Detach the tenant default and baseline policies
+	detachImplicitPolicies(endpointGroup)
+
 	// Cleanup any external contracts
 	err = cleanupExternalContracts(endpointGroup)
 	if err != nil {
@@ -691,6 +769,14 @@ func (ac *APIController) EndpointGroupCreate(endpointGroup *contivModel.Endpoint
 		}
 	}
 
+	// attach the tenant default and baseline policies
+	err = attachImplicitPolicies(endpointGroup)
+	if err != nil {
+		log.Errorf("Error attaching default policies to epg %s. Err: %v", endpointGroup.Key, err)
+		endpointGroupCleanup(endpointGroup)
+		return err
+	}
+
 	// If endpoint group is to be attached to any netprofile, then attach the netprofile and create links and linksets.
 	if endpointGroup.NetProfile != "" {
 		profileKey := GetNetprofileKey(endpointGroup.TenantName, endpointGroup.NetProfile)
@@ -699,9 +785,8 @@ func (ac *APIController) EndpointGroupCreate(endpointGroup *contivModel.Endpoint
 			log.Errorf("Error finding netprofile: %s", profileKey)
 			return errors.New("netprofile not found")
 		}
-
 		// attach NetProfile to epg
-		err = master.UpdateEndpointGroup(netprofile.Bandwidth, endpointGroup.GroupName, endpointGroup.TenantName, netprofile.DSCP, netprofile.Burst)
+		err = master.UpdateEndpointGroup(endpointGroup.GroupName, endpointGroup.TenantName, netprofile)
 		if err != nil {
 			log.Errorf("Error attaching NetProfile %s to epg %s", endpointGroup.NetProfile, endpointGroup.Key)
 			endpointGroupCleanup(endpointGroup)
@@ -806,7 +891,7 @@ func (ac *APIController) EndpointGroupUpdate(endpointGroup, params *contivModel.
 		}
 
 		// attach NetProfile to epg
-		err := master.UpdateEndpointGroup("", endpointGroup.GroupName, endpointGroup.TenantName, 0, 0)
+		err := master.UpdateEndpointGroup(endpointGroup.GroupName, endpointGroup.TenantName, nil)
 		if err != nil {
 			log.Errorf("Error attaching NetProfile %s to epg %s", endpointGroup.NetProfile, endpointGroup.Key)
 			endpointGroupCleanup(endpointGroup)
@@ -832,9 +917,8 @@ func (ac *APIController) EndpointGroupUpdate(endpointGroup, params *contivModel.
 			log.Errorf("Error finding netprofile: %s", paramsKey)
 			return errors.New("netprofile not found")
 		}
-
 		// attach NetProfile to epg
-		err := master.UpdateEndpointGroup(netprofile.Bandwidth, endpointGroup.GroupName, endpointGroup.TenantName, netprofile.DSCP, netprofile.Burst)
+		err := master.UpdateEndpointGroup(endpointGroup.GroupName, endpointGroup.TenantName, netprofile)
 		if err != nil {
 			log.Errorf("Error attaching NetProfile %s to epg %s", params.NetProfile, endpointGroup.Key)
 			endpointGroupCleanup(endpointGroup)
@@ -886,6 +970,11 @@ func (ac *APIController) EndpointGroupUpdate(endpointGroup, params *contivModel.
 				return core.Errorf("Policy not found")
 			}
 
+			// keep the tenant default and baseline policies attached
+			if stringInSlice(policyKey, implicitPolicyKeys(endpointGroup)) {
+				continue
+			}
+
 			// detach policy to epg
 			err := master.PolicyDetach(endpointGroup, policy)
 			if err != nil && err != master.EpgPolicyExists {
@@ -1115,6 +1204,7 @@ func (ac *APIController) NetworkCreate(network *contivModel.Network) error {
 		IPv6SubnetCIDR: network.Ipv6Subnet,
 		IPv6Gateway:    network.Ipv6Gateway,
 		CfgdTag:        network.CfgdTag,
+		Mtu:            network.Mtu,
 	}
 
 	// Create the network
@@ -1195,7 +1285,84 @@ func (ac *APIController) NetworkGetOper(network *contivModel.NetworkInspect) err
 // NetworkUpdate updates network
 func (ac *APIController) NetworkUpdate(network, params *contivModel.Network) error {
 	log.Infof("Received NetworkUpdate: %+v, params: %+v", network, params)
-	return core.Errorf("Cant change network parameters after its created")
+
+	// only gateway, subnet range and tag can be changed on a live network
+	if params.Encap != network.Encap || params.NwType != network.NwType ||
+		params.PktTag != network.PktTag || params.Ipv6Subnet != network.Ipv6Subnet ||
+		params.Mtu != network.Mtu {
+		return core.Errorf("Cant change encap, network type, pkt-tag, ipv6 subnet or mtu of network %s",
+			network.NetworkName)
+	}
+
+	tenant := contivModel.FindTenant(network.TenantName)
+	if tenant == nil {
+		return core.Errorf("Tenant not found")
+	}
+
+	if params.Subnet != network.Subnet || params.Gateway != network.Gateway {
+		for key := range tenant.LinkSets.Networks {
+			networkDetail := contivModel.FindNetwork(key)
+			if networkDetail == nil {
+				log.Errorf("Network key %s not found", key)
+				return fmt.Errorf("network key %s not found", key)
+			}
+			if networkDetail.Key == network.Key {
+				continue
+			}
+
+			if params.Subnet != "" && networkDetail.Subnet != "" &&
+				netutils.IsOverlappingSubnet(params.Subnet, networkDetail.Subnet) {
+				log.Errorf("Overlapping of Networks")
+				return errors.New("network " + networkDetail.NetworkName + " conflicts with subnet " + params.Subnet)
+			}
+		}
+
+		// In swarm-mode work-flow, docker network ipam config can not be changed
+		if master.GetClusterMode() == core.SwarmMode {
+			docknet, err := docknet.GetDocknetState(network.TenantName, network.NetworkName, "")
+			if err == nil {
+				return fmt.Errorf("cannot change subnet of network %s mapped to docker network %s",
+					network.NetworkName, docknet.DocknetUUID)
+			}
+			if !strings.Contains(strings.ToLower(err.Error()), "key not found") {
+				log.Errorf("Error getting docknet state for %s.%s. (retval = %s)",
+					network.TenantName, network.NetworkName, err.Error())
+				return err
+			}
+		}
+	}
+
+	// Get the state driver
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return err
+	}
+
+	networkCfg := intent.ConfigNetwork{
+		Name:           network.NetworkName,
+		NwType:         params.NwType,
+		PktTagType:     params.Encap,
+		PktTag:         params.PktTag,
+		SubnetCIDR:     params.Subnet,
+		Gateway:        params.Gateway,
+		IPv6SubnetCIDR: params.Ipv6Subnet,
+		IPv6Gateway:    params.Ipv6Gateway,
+		CfgdTag:        params.CfgdTag,
+		Mtu:            params.Mtu,
+	}
+
+	err = master.UpdateNetwork(networkCfg, stateDriver, network.TenantName)
+	if err != nil {
+		log.Errorf("Error updating network {%+v}. Err: %v", network, err)
+		return err
+	}
+
+	network.Subnet = params.Subnet
+	network.Gateway = params.Gateway
+	network.Ipv6Gateway = params.Ipv6Gateway
+	network.CfgdTag = params.CfgdTag
+
+	return nil
 }
 
 // NetworkDelete deletes network
@@ -1258,6 +1425,28 @@ func (ac *APIController) NetworkDelete(network *contivModel.Network) error {
 	return tenant.Write()
 }
 
+// checkNetprofileRates checks that the egress bandwidth a netprofile
+// guarantees doesn't exceed the rate its egress traffic is shaped to, and
+// that the egress isn't both policed and shaped. Both are done on the port
+// of the endpoint.
+func checkNetprofileRates(profile *contivModel.Netprofile) error {
+	if profile.Bandwidth != "" && (profile.EgressRate != "" || profile.EgressMinRate != "") {
+		return core.Errorf("bandwidth %s can't be set along with egress shaping, use the egress rate",
+			profile.Bandwidth)
+	}
+
+	if profile.EgressMinRate == "" || profile.EgressRate == "" {
+		return nil
+	}
+
+	if netutils.ConvertBandwidth(profile.EgressMinRate) > netutils.ConvertBandwidth(profile.EgressRate) {
+		return core.Errorf("egress minimum rate %s exceeds the egress rate %s",
+			profile.EgressMinRate, profile.EgressRate)
+	}
+
+	return nil
+}
+
 // NetprofileCreate creates the network rule
 func (ac *APIController) NetprofileCreate(netProfile *contivModel.Netprofile) error {
 	log.Infof("Received NetprofileCreate: %+v", netProfile)
@@ -1271,6 +1460,10 @@ func (ac *APIController) NetprofileCreate(netProfile *contivModel.Netprofile) er
 		return core.Errorf("Invalid Burst size. burst size > 1500 bytes")
 	}
 
+	if err := checkNetprofileRates(netProfile); err != nil {
+		return err
+	}
+
 	tenant := contivModel.FindTenant(netProfile.TenantName)
 	if tenant == nil {
 		return core.Errorf("Tenant not found")
@@ -1297,9 +1490,16 @@ func (ac *APIController) NetprofileUpdate(profile, params *contivModel.Netprofil
 	if params.Burst > 0 && params.Burst < 2 {
 		return core.Errorf("Invalid Burst size. burst size must be > 1500 bytes")
 	}
+	if err := checkNetprofileRates(params); err != nil {
+		return err
+	}
+
 	profile.Bandwidth = params.Bandwidth
 	profile.DSCP = params.DSCP
 	profile.Burst = params.Burst
+	profile.IngressRate = params.IngressRate
+	profile.EgressRate = params.EgressRate
+	profile.EgressMinRate = params.EgressMinRate
 
 	for key := range profile.LinkSets.EndpointGroups {
 		// Find the corresponding epg
@@ -1308,7 +1508,7 @@ func (ac *APIController) NetprofileUpdate(profile, params *contivModel.Netprofil
 			return core.Errorf("EndpointGroups not found")
 		}
 
-		err := master.UpdateEndpointGroup(params.Bandwidth, epg.GroupName, epg.TenantName, params.DSCP, params.Burst)
+		err := master.UpdateEndpointGroup(epg.GroupName, epg.TenantName, profile)
 		if err != nil {
 			log.Errorf("Error updating the EndpointGroups: %s. Err: %v", epg.GroupName, err)
 		}
@@ -1437,12 +1637,39 @@ func (ac *APIController) PolicyGetOper(policy *contivModel.PolicyInspect) error
 
 	policy.Oper.NumEndpoints = policyEPCount
 
+	// Get the rule hit counters from all hosts
+	ruleStats, unreachable, err := ac.getPolicyRuleStats(&policy.Config)
+	if err != nil {
+		log.Errorf("Error getting rule stats for policy %s. Err: %v", policy.Config.Key, err)
+	} else {
+		policy.Oper.RuleStats = ruleStats
+		policy.Oper.UnreachableHosts = unreachable
+	}
+
 	return nil
 }
 
 // PolicyUpdate updates policy
 func (ac *APIController) PolicyUpdate(policy, params *contivModel.Policy) error {
 	log.Infof("Received PolicyUpdate: %+v, params: %+v", policy, params)
+
+	if params.Mode == policy.Mode {
+		return nil
+	}
+
+	// switch the deny rules between enforce and audit
+	oldMode := policy.Mode
+	policy.Mode = params.Mode
+
+	err := master.PolicyUpdateMode(policy)
+	if err != nil {
+		log.Errorf("Error updating mode of policy %s. Err: %v", policy.Key, err)
+
+		// the rules were restored for the old mode
+		policy.Mode = oldMode
+		return err
+	}
+
 	return nil
 }
 
@@ -1461,6 +1688,15 @@ func (ac *APIController) PolicyDelete(policy *contivModel.Policy) error {
 		return core.Errorf("Policy is being used")
 	}
 
+	// Check if the policy is a tenant default or the baseline policy
+	if tenant.DefaultPolicy == policy.PolicyName {
+		return core.Errorf("Policy is the default policy of tenant %s", tenant.TenantName)
+	}
+	global := contivModel.FindGlobal("global")
+	if global != nil && policy.TenantName == baselinePolicyTenant && global.BaselinePolicy == policy.PolicyName {
+		return core.Errorf("Policy is the baseline policy")
+	}
+
 	// Delete all associated Rules
 	for key := range policy.LinkSets.Rules {
 		// delete the rule
@@ -1526,36 +1762,58 @@ func syncAppProfile(profMap map[string]bool) {
 	}
 }
 
-// RuleCreate Creates the rule within a policy
-func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
-	log.Infof("Received RuleCreate: %+v", rule)
+// validateRule verifies rule parameters and returns the endpoint group
+// matched by the rule, if any
+func validateRule(rule *contivModel.Rule) (*contivModel.EndpointGroup, error) {
 	var epg *contivModel.EndpointGroup
 	epg = nil
 
 	// verify parameter values
 	if rule.Direction == "in" {
-		if rule.ToNetwork != "" || rule.ToEndpointGroup != "" {
-			return errors.New("can not specify 'to' parameters in incoming rule")
+		if rule.ToNetwork != "" || rule.ToEndpointGroup != "" || rule.ToAddressSet != "" {
+			return nil, errors.New("can not specify 'to' parameters in incoming rule")
+		}
+		if rule.FromAddressSet != "" && (rule.FromNetwork != "" || rule.FromIpAddress != "" || rule.FromEndpointGroup != "") {
+			return nil, errors.New("can not specify both from address set and other from parameters")
 		}
 		if rule.FromNetwork != "" && rule.FromIpAddress != "" {
-			return errors.New("can not specify both from network and from ip address")
+			return nil, errors.New("can not specify both from network and from ip address")
 		}
 
 		if rule.FromNetwork != "" && rule.FromEndpointGroup != "" {
-			return errors.New("can not specify both from network and from EndpointGroup")
+			return nil, errors.New("can not specify both from network and from EndpointGroup")
 		}
 	} else if rule.Direction == "out" {
-		if rule.FromNetwork != "" || rule.FromEndpointGroup != "" || rule.FromIpAddress != "" {
-			return errors.New("can not specify 'from' parameters in outgoing rule")
+		if rule.FromNetwork != "" || rule.FromEndpointGroup != "" || rule.FromAddressSet != "" {
+			return nil, errors.New("can not specify 'from' parameters in outgoing rule")
+		}
+		if rule.ToAddressSet != "" && (rule.ToNetwork != "" || rule.ToIpAddress != "" || rule.ToEndpointGroup != "") {
+			return nil, errors.New("can not specify both to address set and other to parameters")
 		}
 		if rule.ToNetwork != "" && rule.ToIpAddress != "" {
-			return errors.New("can not specify both to-network and to-ip address")
+			return nil, errors.New("can not specify both to-network and to-ip address")
 		}
 		if rule.ToNetwork != "" && rule.ToEndpointGroup != "" {
-			return errors.New("can not specify both to-network and to-EndpointGroup")
+			return nil, errors.New("can not specify both to-network and to-EndpointGroup")
 		}
 	} else {
-		return errors.New("invalid direction for the rule")
+		return nil, errors.New("invalid direction for the rule")
+	}
+
+	// port lists and ranges are only valid for tcp and udp
+	if rule.Ports != "" {
+		if rule.Protocol != "tcp" && rule.Protocol != "udp" {
+			return nil, errors.New("port list requires tcp or udp protocol")
+		}
+		if _, err := netutils.ParsePortList(rule.Ports); err != nil {
+			return nil, err
+		}
+	}
+
+	// Make sure address sets referred exist
+	if getRuleAddressSetKey(rule) != "" && findRuleAddressSet(rule) == nil {
+		log.Errorf("Error finding address set %s", getRuleAddressSetKey(rule))
+		return nil, errors.New("address set not found")
 	}
 
 	// Make sure endpoint groups and networks referred exists.
@@ -1565,7 +1823,7 @@ func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
 		epg = contivModel.FindEndpointGroup(epgKey)
 		if epg == nil {
 			log.Errorf("Error finding endpoint group %s", epgKey)
-			return errors.New("endpoint group not found")
+			return nil, errors.New("endpoint group not found")
 		}
 	} else if rule.ToEndpointGroup != "" {
 		epgKey := rule.TenantName + ":" + rule.ToEndpointGroup
@@ -1574,7 +1832,7 @@ func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
 		epg = contivModel.FindEndpointGroup(epgKey)
 		if epg == nil {
 			log.Errorf("Error finding endpoint group %s", epgKey)
-			return errors.New("endpoint group not found")
+			return nil, errors.New("endpoint group not found")
 		}
 	} else if rule.FromNetwork != "" {
 		netKey := rule.TenantName + ":" + rule.FromNetwork
@@ -1582,7 +1840,7 @@ func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
 		net := contivModel.FindNetwork(netKey)
 		if net == nil {
 			log.Errorf("Network %s not found", netKey)
-			return errors.New("from Network not found")
+			return nil, errors.New("from Network not found")
 		}
 	} else if rule.ToNetwork != "" {
 		netKey := rule.TenantName + ":" + rule.ToNetwork
@@ -1590,70 +1848,152 @@ func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
 		net := contivModel.FindNetwork(netKey)
 		if net == nil {
 			log.Errorf("Network %s not found", netKey)
-			return errors.New("to Network not found")
+			return nil, errors.New("to Network not found")
 		}
 	}
 
-	policyKey := GetpolicyKey(rule.TenantName, rule.PolicyName)
+	return epg, nil
+}
 
-	// find the policy
+// getRuleAddressSetKey returns the key of the address set matched by a
+// rule, if any
+func getRuleAddressSetKey(rule *contivModel.Rule) string {
+	if rule.FromAddressSet != "" {
+		return rule.TenantName + ":" + rule.FromAddressSet
+	}
+	if rule.ToAddressSet != "" {
+		return rule.TenantName + ":" + rule.ToAddressSet
+	}
+	return ""
+}
+
+// findRuleAddressSet returns the address set matched by a rule, if any
+func findRuleAddressSet(rule *contivModel.Rule) *contivModel.AddressSet {
+	setKey := getRuleAddressSetKey(rule)
+	if setKey == "" {
+		return nil
+	}
+	return contivModel.FindAddressSet(setKey)
+}
+
+// validateRuleEndpointIP verifies 'toIpAddress' of incoming rules and
+// 'fromIpAddress' of outgoing rules is an endpoint in the endpoint group
+// the policy is attached to
+func validateRuleEndpointIP(rule *contivModel.Rule, policy *contivModel.Policy) error {
+	epIPAddress := ""
+	switch rule.Direction {
+	case "in":
+		epIPAddress = rule.ToIpAddress
+	case "out":
+		epIPAddress = rule.FromIpAddress
+	}
+	if epIPAddress == "" {
+		return nil
+	}
+
+	// rules from k8s network policy
+	// verify the endpoint address is part of epg and subnet
+	if len(policy.LinkSets.EndpointGroups) != 1 {
+		errMsg := fmt.Errorf("failed to configure %s, %d endpoint groups linked to policy %s ",
+			epIPAddress,
+			len(policy.LinkSets.EndpointGroups),
+			rule.PolicyName)
+		log.Error(errMsg)
+		return errMsg
+	}
+	epgKey := ""
+	for key := range policy.LinkSets.EndpointGroups {
+		epgKey = strings.Replace(key, rule.TenantName+":", "", 1) + ":" + rule.TenantName
+	}
+
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		log.Errorf("failed to configure %s, %s", epIPAddress, err)
+		return fmt.Errorf("failed to configure %s, unable to connect to key-value store",
+			epIPAddress)
+	}
+	readEp := &mastercfg.CfgEndpointState{}
+	readEp.StateDriver = stateDriver
+	epCfgs, err := readEp.ReadAll()
+	if err != nil {
+		log.Errorf("failed to configure %s, %s", epIPAddress, err)
+		return fmt.Errorf("failed to configure %s, unable to read endpoint state", epIPAddress)
+	}
+
+	// TODO: cache ip <--> epg for performance
+	if func(epgName string) bool {
+		for _, epCfg := range epCfgs {
+			if ep, ok := epCfg.(*mastercfg.CfgEndpointState); ok {
+				if ep.IPAddress == epIPAddress && ep.EndpointGroupKey == epgName {
+					return true
+				}
+			}
+		}
+		return false
+	}(epgKey) != true {
+		errMsg := fmt.Errorf("failed to configure %s, ip address is not in epg %s",
+			epIPAddress,
+			epgKey)
+		log.Error(errMsg)
+		return errMsg
+	}
+
+	return nil
+}
+
+// RuleGetOper returns the hit counters of a rule summed across all hosts
+func (ac *APIController) RuleGetOper(rule *contivModel.RuleInspect) error {
+	log.Infof("Received RuleInspect: %+v", rule)
+
+	policyKey := GetpolicyKey(rule.Config.TenantName, rule.Config.PolicyName)
 	policy := contivModel.FindPolicy(policyKey)
 	if policy == nil {
 		log.Errorf("Error finding policy %s", policyKey)
 		return core.Errorf("Policy not found")
 	}
 
-	if rule.Direction == "in" && rule.ToIpAddress != "" {
-		// rules from k8s network policy
-		// verify 'toIpAddress' is part of epg and subnet
-		if len(policy.LinkSets.EndpointGroups) != 1 {
-			errMsg := fmt.Errorf("failed to configure %s, %d endpoint groups linked to policy %s ",
-				rule.ToIpAddress,
-				len(policy.LinkSets.EndpointGroups),
-				rule.PolicyName)
-			log.Error(errMsg)
-			return errMsg
-		}
-		epgKey := ""
-		for key := range policy.LinkSets.EndpointGroups {
-			epgKey = strings.Replace(key, rule.TenantName+":", "", 1) + ":" + rule.TenantName
-		}
+	ruleStats, unreachable, err := ac.getPolicyRuleStats(policy)
+	if err != nil {
+		return err
+	}
 
-		stateDriver, err := utils.GetStateDriver()
-		if err != nil {
-			log.Errorf("failed to configure %s, %s", rule.ToIpAddress, err)
-			return fmt.Errorf("failed to configure %s, unable to connect to key-value store",
-				rule.ToIpAddress)
-		}
-		readEp := &mastercfg.CfgEndpointState{}
-		readEp.StateDriver = stateDriver
-		epCfgs, err := readEp.ReadAll()
-		if err != nil {
-			log.Errorf("failed to configure %s, %s", rule.ToIpAddress, err)
-			return fmt.Errorf("failed to configure %s, unable to read endpoint state", rule.ToIpAddress)
+	// counters of unreachable hosts are missing from the sums
+	rule.Oper.RuleID = rule.Config.RuleID
+	rule.Oper.UnreachableHosts = unreachable
+	for _, stat := range ruleStats {
+		if stat.RuleID == rule.Config.RuleID {
+			rule.Oper.Packets += stat.Packets
+			rule.Oper.Bytes += stat.Bytes
 		}
+	}
 
-		// TODO: cache ip <--> epg for performance
-		if func(epgName string) bool {
-			for _, epCfg := range epCfgs {
-				if ep, ok := epCfg.(*mastercfg.CfgEndpointState); ok {
-					if ep.IPAddress == rule.ToIpAddress && ep.EndpointGroupKey == epgName {
-						return true
-					}
-				}
-			}
-			return false
-		}(epgKey) != true {
-			errMsg := fmt.Errorf("failed to configure %s, ip address is not in epg %s",
-				rule.ToIpAddress,
-				epgKey)
-			log.Error(errMsg)
-			return errMsg
-		}
+	return nil
+}
+
+// RuleCreate Creates the rule within a policy
+func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
+	log.Infof("Received RuleCreate: %+v", rule)
+
+	epg, err := validateRule(rule)
+	if err != nil {
+		return err
+	}
+
+	policyKey := GetpolicyKey(rule.TenantName, rule.PolicyName)
+
+	// find the policy
+	policy := contivModel.FindPolicy(policyKey)
+	if policy == nil {
+		log.Errorf("Error finding policy %s", policyKey)
+		return core.Errorf("Policy not found")
+	}
+
+	if err := validateRuleEndpointIP(rule, policy); err != nil {
+		return err
 	}
 
 	// Trigger policyDB Update
-	err := master.PolicyAddRule(policy, rule)
+	err = master.PolicyAddRule(policy, rule)
 	if err != nil {
 		log.Errorf("Error adding rule %s to policy %s. Err: %v", rule.Key, policy.Key, err)
 		return err
@@ -1677,6 +2017,15 @@ func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
 		}
 	}
 
+	// link the rule to the address set it matches
+	if addrSet := findRuleAddressSet(rule); addrSet != nil {
+		modeldb.AddLinkSet(&addrSet.LinkSets.Rules, rule)
+		err = addrSet.Write()
+		if err != nil {
+			return err
+		}
+	}
+
 	// Update any affected app profiles
 	pMap := getAffectedProfs(policy, epg)
 	syncAppProfile(pMap)
@@ -1687,7 +2036,103 @@ func (ac *APIController) RuleCreate(rule *contivModel.Rule) error {
 // RuleUpdate updates the rule within a policy
 func (ac *APIController) RuleUpdate(rule, params *contivModel.Rule) error {
 	log.Infof("Received RuleUpdate: %+v, params: %+v", rule, params)
-	return errors.New("can not update a rule after its created")
+
+	// build the new version of the rule, links are kept as is
+	newRule := *rule
+	newRule.Action = params.Action
+	newRule.Direction = params.Direction
+	newRule.FromAddressSet = params.FromAddressSet
+	newRule.FromEndpointGroup = params.FromEndpointGroup
+	newRule.FromIpAddress = params.FromIpAddress
+	newRule.FromNetwork = params.FromNetwork
+	newRule.Port = params.Port
+	newRule.Ports = params.Ports
+	newRule.Priority = params.Priority
+	newRule.Protocol = params.Protocol
+	newRule.ToAddressSet = params.ToAddressSet
+	newRule.ToEndpointGroup = params.ToEndpointGroup
+	newRule.ToIpAddress = params.ToIpAddress
+	newRule.ToNetwork = params.ToNetwork
+
+	epg, err := validateRule(&newRule)
+	if err != nil {
+		return err
+	}
+
+	policyKey := GetpolicyKey(rule.TenantName, rule.PolicyName)
+
+	// find the policy
+	policy := contivModel.FindPolicy(policyKey)
+	if policy == nil {
+		log.Errorf("Error finding policy %s", policyKey)
+		return core.Errorf("Policy not found")
+	}
+
+	if err := validateRuleEndpointIP(&newRule, policy); err != nil {
+		return err
+	}
+
+	// Trigger policyDB Update
+	oldRule := *rule
+	*rule = newRule
+	err = master.PolicyUpdateRule(policy, rule)
+	if err != nil {
+		log.Errorf("Error updating rule %s in policy %s. Err: %v", rule.Key, policy.Key, err)
+		*rule = oldRule
+		return err
+	}
+
+	// move the link to matching epg
+	var oldEpg *contivModel.EndpointGroup
+	oldEpgKey := rule.Links.MatchEndpointGroup.ObjKey
+	if oldEpgKey != "" && (epg == nil || epg.Key != oldEpgKey) {
+		oldEpg = contivModel.FindEndpointGroup(oldEpgKey)
+		if oldEpg != nil {
+			modeldb.RemoveLinkSet(&oldEpg.LinkSets.MatchRules, rule)
+			err = oldEpg.Write()
+			if err != nil {
+				return err
+			}
+		}
+		modeldb.RemoveLink(&rule.Links.MatchEndpointGroup, oldEpg)
+	}
+	if epg != nil && epg.Key != oldEpgKey {
+		modeldb.AddLinkSet(&epg.LinkSets.MatchRules, rule)
+		modeldb.AddLink(&rule.Links.MatchEndpointGroup, epg)
+		err = epg.Write()
+		if err != nil {
+			return err
+		}
+	}
+
+	// move the link to matching address set
+	if getRuleAddressSetKey(&oldRule) != getRuleAddressSetKey(rule) {
+		if oldSet := findRuleAddressSet(&oldRule); oldSet != nil {
+			modeldb.RemoveLinkSet(&oldSet.LinkSets.Rules, rule)
+			err = oldSet.Write()
+			if err != nil {
+				return err
+			}
+		}
+		if addrSet := findRuleAddressSet(rule); addrSet != nil {
+			modeldb.AddLinkSet(&addrSet.LinkSets.Rules, rule)
+			err = addrSet.Write()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Update any affected app profiles
+	pMap := getAffectedProfs(policy, epg)
+	if oldEpg != nil {
+		for prof := range getAffectedProfs(policy, oldEpg) {
+			pMap[prof] = true
+		}
+	}
+	syncAppProfile(pMap)
+
+	return nil
 }
 
 // RuleDelete deletes the rule within a policy
@@ -1729,6 +2174,15 @@ func (ac *APIController) RuleDelete(rule *contivModel.Rule) error {
 		return err
 	}
 
+	// unlink the rule from matching address set
+	if addrSet := findRuleAddressSet(rule); addrSet != nil {
+		modeldb.RemoveLinkSet(&addrSet.LinkSets.Rules, rule)
+		err = addrSet.Write()
+		if err != nil {
+			return err
+		}
+	}
+
 	// Update any affected app profiles
 	pMap := getAffectedProfs(policy, epg)
 	syncAppProfile(pMap)
@@ -1736,6 +2190,215 @@ func (ac *APIController) RuleDelete(rule *contivModel.Rule) error {
 	return nil
 }
 
+// validateAddresses verifies all addresses of an address set
+func validateAddresses(addresses []string) error {
+	for _, addr := range addresses {
+		if err := netutils.ValidateIPOrCIDR(addr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddressSetCreate creates an address set
+func (ac *APIController) AddressSetCreate(addrSet *contivModel.AddressSet) error {
+	log.Infof("Received AddressSetCreate: %+v", addrSet)
+
+	// Make sure tenant exists
+	if addrSet.TenantName == "" {
+		return core.Errorf("Invalid tenant name")
+	}
+
+	tenant := contivModel.FindTenant(addrSet.TenantName)
+	if tenant == nil {
+		return core.Errorf("Tenant not found")
+	}
+
+	if err := validateAddresses(addrSet.Addresses); err != nil {
+		return err
+	}
+
+	// Setup links
+	modeldb.AddLink(&addrSet.Links.Tenant, tenant)
+	modeldb.AddLinkSet(&tenant.LinkSets.AddressSets, addrSet)
+
+	// Save the tenant too since we added the links
+	err := tenant.Write()
+	if err != nil {
+		log.Errorf("Error updating tenant state(%+v). Err: %v", tenant, err)
+		return err
+	}
+
+	return nil
+}
+
+// AddressSetUpdate updates the addresses of an address set and recompiles
+// all rules matching it
+func (ac *APIController) AddressSetUpdate(addrSet, params *contivModel.AddressSet) error {
+	log.Infof("Received AddressSetUpdate: %+v, params: %+v", addrSet, params)
+
+	if err := validateAddresses(params.Addresses); err != nil {
+		return err
+	}
+
+	oldAddresses := addrSet.Addresses
+	addrSet.Addresses = params.Addresses
+
+	err := master.PolicyUpdateAddressSet(addrSet)
+	if err != nil {
+		log.Errorf("Error updating address set %s. Err: %v", addrSet.Key, err)
+
+		// the rules were restored for the old addresses
+		addrSet.Addresses = oldAddresses
+		return err
+	}
+
+	return nil
+}
+
+// AddressSetDelete deletes an address set
+func (ac *APIController) AddressSetDelete(addrSet *contivModel.AddressSet) error {
+	log.Infof("Received AddressSetDelete: %+v", addrSet)
+
+	// Check if any rule is using the address set
+	if len(addrSet.LinkSets.Rules) != 0 {
+		return core.Errorf("address set is being used by %d rules", len(addrSet.LinkSets.Rules))
+	}
+
+	// Remove the links from tenant
+	tenant := contivModel.FindTenant(addrSet.TenantName)
+	if tenant == nil {
+		return core.Errorf("Tenant %s not found", addrSet.TenantName)
+	}
+
+	modeldb.RemoveLinkSet(&tenant.LinkSets.AddressSets, addrSet)
+	return tenant.Write()
+}
+
+// validateMirrorSource checks that the endpoint group or network mirrored
+// by a session exists
+func validateMirrorSource(session *contivModel.MirrorSession) error {
+	if session.EndpointGroup != "" {
+		epgKey := session.TenantName + ":" + session.EndpointGroup
+		if contivModel.FindEndpointGroup(epgKey) == nil {
+			return core.Errorf("endpoint group %s not found", epgKey)
+		}
+	}
+
+	if session.NetworkName != "" {
+		netKey := session.TenantName + ":" + session.NetworkName
+		if contivModel.FindNetwork(netKey) == nil {
+			return core.Errorf("network %s not found", netKey)
+		}
+	}
+
+	return nil
+}
+
+// MirrorSessionCreate creates a mirror session
+func (ac *APIController) MirrorSessionCreate(session *contivModel.MirrorSession) error {
+	log.Infof("Received MirrorSessionCreate: %+v", session)
+
+	// Make sure tenant exists
+	if session.TenantName == "" {
+		return core.Errorf("Invalid tenant name")
+	}
+
+	tenant := contivModel.FindTenant(session.TenantName)
+	if tenant == nil {
+		return core.Errorf("Tenant not found")
+	}
+
+	if err := validateMirrorSource(session); err != nil {
+		return err
+	}
+
+	// Get the state driver
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return err
+	}
+
+	err = master.CreateMirrorSession(stateDriver, session)
+	if err != nil {
+		log.Errorf("Error creating mirror session {%+v}. Err: %v", session, err)
+		return err
+	}
+
+	// Setup links
+	modeldb.AddLink(&session.Links.Tenant, tenant)
+	modeldb.AddLinkSet(&tenant.LinkSets.MirrorSessions, session)
+
+	// Save the tenant too since we added the links
+	err = tenant.Write()
+	if err != nil {
+		log.Errorf("Error updating tenant state(%+v). Err: %v", tenant, err)
+		return err
+	}
+
+	return nil
+}
+
+// MirrorSessionUpdate updates the endpoints and destination of a mirror
+// session
+func (ac *APIController) MirrorSessionUpdate(session, params *contivModel.MirrorSession) error {
+	log.Infof("Received MirrorSessionUpdate: %+v, params: %+v", session, params)
+
+	if err := validateMirrorSource(params); err != nil {
+		return err
+	}
+
+	// Get the state driver
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return err
+	}
+
+	err = master.CreateMirrorSession(stateDriver, params)
+	if err != nil {
+		log.Errorf("Error updating mirror session {%+v}. Err: %v", params, err)
+		return err
+	}
+
+	session.Endpoints = params.Endpoints
+	session.EndpointGroup = params.EndpointGroup
+	session.NetworkName = params.NetworkName
+	session.Direction = params.Direction
+	session.DestinationType = params.DestinationType
+	session.DestinationPort = params.DestinationPort
+	session.CollectorIp = params.CollectorIp
+	session.ErspanId = params.ErspanId
+
+	return nil
+}
+
+// MirrorSessionDelete deletes a mirror session
+func (ac *APIController) MirrorSessionDelete(session *contivModel.MirrorSession) error {
+	log.Infof("Received MirrorSessionDelete: %+v", session)
+
+	// Get the state driver
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return err
+	}
+
+	err = master.DeleteMirrorSession(stateDriver, session.TenantName, session.SessionName)
+	if err != nil {
+		log.Errorf("Error deleting mirror session %s. Err: %v", session.Key, err)
+		return err
+	}
+
+	// Remove the links from tenant
+	tenant := contivModel.FindTenant(session.TenantName)
+	if tenant == nil {
+		return core.Errorf("Tenant %s not found", session.TenantName)
+	}
+
+	modeldb.RemoveLinkSet(&tenant.LinkSets.MirrorSessions, session)
+	return tenant.Write()
+}
+
 // TenantCreate creates a tenant
 func (ac *APIController) TenantCreate(tenant *contivModel.Tenant) error {
 	log.Infof("Received TenantCreate: %+v", tenant)
@@ -1744,6 +2407,11 @@ func (ac *APIController) TenantCreate(tenant *contivModel.Tenant) error {
 		return core.Errorf("Invalid tenant name")
 	}
 
+	// policies are created in an existing tenant
+	if tenant.DefaultPolicy != "" {
+		return core.Errorf("Default policy can only be set after the tenant is created")
+	}
+
 	// Get the state driver
 	stateDriver, err := utils.GetStateDriver()
 	if err != nil {
@@ -1919,7 +2587,33 @@ func (ac *APIController) TenantGetOper(tenant *contivModel.TenantInspect) error
 func (ac *APIController) TenantUpdate(tenant, params *contivModel.Tenant) error {
 	log.Infof("Received TenantUpdate: %+v, params: %+v", tenant, params)
 
-	return core.Errorf("Cant change tenant parameters after its created")
+	// only the default policy can be changed
+	if tenant.DefaultNetwork != params.DefaultNetwork {
+		return core.Errorf("Cant change tenant parameters after its created")
+	}
+	if tenant.DefaultPolicy == params.DefaultPolicy {
+		return nil
+	}
+
+	var oldPolicyKey, newPolicyKey string
+	if tenant.DefaultPolicy != "" {
+		oldPolicyKey = GetpolicyKey(tenant.TenantName, tenant.DefaultPolicy)
+	}
+	if params.DefaultPolicy != "" {
+		newPolicyKey = GetpolicyKey(tenant.TenantName, params.DefaultPolicy)
+	}
+
+	// move all endpoint groups to the new default policy
+	oldPolicy := tenant.DefaultPolicy
+	tenant.DefaultPolicy = params.DefaultPolicy
+	err := updateImplicitPolicy(tenantEndpointGroups(tenant), oldPolicyKey, newPolicyKey)
+	if err != nil {
+		log.Errorf("Error changing default policy of tenant %s. Err: %v", tenant.TenantName, err)
+		tenant.DefaultPolicy = oldPolicy
+		return err
+	}
+
+	return nil
 }
 
 // TenantDelete deletes a tenant
@@ -1950,6 +2644,16 @@ func (ac *APIController) TenantDelete(tenant *contivModel.Tenant) error {
 		return core.Errorf("cannot delete %s has %d policies",
 			tenant.TenantName, policyCount)
 	}
+	setCount := len(tenant.LinkSets.AddressSets)
+	if setCount != 0 {
+		return core.Errorf("cannot delete %s has %d address sets",
+			tenant.TenantName, setCount)
+	}
+	mirrorCount := len(tenant.LinkSets.MirrorSessions)
+	if mirrorCount != 0 {
+		return core.Errorf("cannot delete %s has %d mirror sessions",
+			tenant.TenantName, mirrorCount)
+	}
 	npCount := len(tenant.LinkSets.NetProfiles)
 	if npCount != 0 {
 		return core.Errorf("Cannot delete %s has %d netprofiles", tenant.TenantName, npCount)
@@ -2372,5 +3076,14 @@ func validateGlobalConfig(netmode string) error {
 	if strings.ToLower(netmode) == "vxlan" && globalConfig.Vxlans == "" {
 		return errors.New("global vxlan range is not set")
 	}
+	if strings.ToLower(netmode) == "geneve" {
+		// geneve networks use vxlan ids
+		if globalConfig.Vxlans == "" {
+			return errors.New("global vxlan range is not set")
+		}
+		if globalConfig.FwdMode != "bridge" {
+			return errors.New("geneve encap requires bridge forwarding mode")
+		}
+	}
 	return nil
 }
@@ -0,0 +1,274 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objApi
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/contivmodel"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/netmaster/master"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/objdb/modeldb"
+	"github.com/contiv/netplugin/utils"
+)
+
+// Tenant default policies and the global baseline policy are attached to
+// endpoint groups implicitly, along with the policies listed in the group.
+
+// baselinePolicyTenant is the tenant of the global baseline policy
+const baselinePolicyTenant = "default"
+
+// implicitPolicyKeys returns the keys of the policies an endpoint group gets
+// from its tenant and the global config
+func implicitPolicyKeys(epg *contivModel.EndpointGroup) []string {
+	var keys []string
+
+	tenant := contivModel.FindTenant(epg.TenantName)
+	if tenant != nil && tenant.DefaultPolicy != "" {
+		keys = append(keys, GetpolicyKey(epg.TenantName, tenant.DefaultPolicy))
+	}
+
+	global := contivModel.FindGlobal("global")
+	if global != nil && global.BaselinePolicy != "" {
+		keys = append(keys, baselinePolicyKey(global.BaselinePolicy))
+	}
+
+	return keys
+}
+
+// baselinePolicyKey returns the key of a baseline policy, empty if its not set
+func baselinePolicyKey(policyName string) string {
+	if policyName == "" {
+		return ""
+	}
+
+	return GetpolicyKey(baselinePolicyTenant, policyName)
+}
+
+// policyIsAttached checks if a policy is attached to an endpoint group
+func policyIsAttached(epg *contivModel.EndpointGroup, policy *contivModel.Policy) bool {
+	_, found := epg.LinkSets.Policies[policy.Key]
+	return found
+}
+
+// policyIsExplicit checks if a policy is listed in an endpoint group
+func policyIsExplicit(epg *contivModel.EndpointGroup, policy *contivModel.Policy) bool {
+	return policy.TenantName == epg.TenantName && stringInSlice(policy.PolicyName, epg.Policies)
+}
+
+// attachPolicy attaches a policy to an endpoint group, unless its attached already
+func attachPolicy(epg *contivModel.EndpointGroup, policy *contivModel.Policy) error {
+	if policyIsAttached(epg, policy) {
+		return nil
+	}
+
+	err := master.PolicyAttach(epg, policy)
+	if err != nil && err != master.EpgPolicyExists {
+		log.Errorf("Error attaching policy %s to epg %s. Err: %v", policy.Key, epg.Key, err)
+		return err
+	}
+
+	// establish Links
+	modeldb.AddLinkSet(&policy.LinkSets.EndpointGroups, epg)
+	modeldb.AddLinkSet(&epg.LinkSets.Policies, policy)
+
+	return policy.Write()
+}
+
+// detachPolicy detaches a policy from an endpoint group
+func detachPolicy(epg *contivModel.EndpointGroup, policy *contivModel.Policy) error {
+	err := master.PolicyDetach(epg, policy)
+	if err != nil {
+		log.Errorf("Error detaching policy %s from epg %s. Err: %v", policy.Key, epg.Key, err)
+		return err
+	}
+
+	// Remove links
+	modeldb.RemoveLinkSet(&policy.LinkSets.EndpointGroups, epg)
+	modeldb.RemoveLinkSet(&epg.LinkSets.Policies, policy)
+
+	return policy.Write()
+}
+
+// attachImplicitPolicies attaches the tenant default and baseline policies
+// to a new endpoint group
+func attachImplicitPolicies(epg *contivModel.EndpointGroup) error {
+	for _, policyKey := range implicitPolicyKeys(epg) {
+		policy := contivModel.FindPolicy(policyKey)
+		if policy == nil {
+			log.Errorf("Could not find policy %s", policyKey)
+			return core.Errorf("Policy not found")
+		}
+
+		if err := attachPolicy(epg, policy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// detachImplicitPolicies detaches the tenant default and baseline policies
+// from an endpoint group being deleted
+func detachImplicitPolicies(epg *contivModel.EndpointGroup) {
+	for _, policyKey := range implicitPolicyKeys(epg) {
+		policy := contivModel.FindPolicy(policyKey)
+		if policy == nil || !policyIsAttached(epg, policy) {
+			continue
+		}
+
+		if err := detachPolicy(epg, policy); err != nil {
+			log.Errorf("Error detaching policy %s from epg %s", policyKey, epg.Key)
+		}
+	}
+}
+
+// keepPolicy checks if an endpoint group still needs a policy, because its
+// listed in the group or it still gets it from its tenant or global config
+func keepPolicy(epg *contivModel.EndpointGroup, policy *contivModel.Policy) bool {
+	return policyIsExplicit(epg, policy) || stringInSlice(policy.Key, implicitPolicyKeys(epg))
+}
+
+// updateImplicitPolicy moves endpoint groups from an old default or baseline
+// policy to a new one. The new policy is attached before the old one is
+// detached, so that the groups are never left without either. The tenant or
+// global config must already refer to the new policy.
+func updateImplicitPolicy(epgs []*contivModel.EndpointGroup, oldPolicyKey, newPolicyKey string) error {
+	var oldPolicy, newPolicy *contivModel.Policy
+	if oldPolicyKey != "" {
+		oldPolicy = contivModel.FindPolicy(oldPolicyKey)
+	}
+	if newPolicyKey != "" {
+		newPolicy = contivModel.FindPolicy(newPolicyKey)
+		if newPolicy == nil {
+			log.Errorf("Could not find policy %s", newPolicyKey)
+			return core.Errorf("Policy %s not found", newPolicyKey)
+		}
+	}
+
+	// attach the new policy
+	if newPolicy != nil {
+		var attached []*contivModel.EndpointGroup
+		for _, epg := range epgs {
+			if policyIsAttached(epg, newPolicy) {
+				continue
+			}
+
+			if err := attachPolicy(epg, newPolicy); err != nil {
+				for _, aEpg := range attached {
+					detachPolicy(aEpg, newPolicy)
+					aEpg.Write()
+				}
+				return err
+			}
+			attached = append(attached, epg)
+
+			if err := epg.Write(); err != nil {
+				log.Errorf("Error writing epg %s. Err: %v", epg.Key, err)
+			}
+		}
+	}
+
+	// detach the old one where its no longer needed
+	if oldPolicy != nil {
+		for _, epg := range epgs {
+			if !policyIsAttached(epg, oldPolicy) || keepPolicy(epg, oldPolicy) {
+				continue
+			}
+
+			if err := detachPolicy(epg, oldPolicy); err != nil {
+				log.Errorf("Error detaching policy %s from epg %s", oldPolicyKey, epg.Key)
+				continue
+			}
+
+			if err := epg.Write(); err != nil {
+				log.Errorf("Error writing epg %s. Err: %v", epg.Key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// updateBaselineRules recompiles the rules of the old and new baseline
+// policies, in the endpoint groups that list them explicitly as well. The
+// global config must already refer to the new policy.
+func updateBaselineRules(oldPolicyName, newPolicyName string) error {
+	for _, policyName := range []string{oldPolicyName, newPolicyName} {
+		if policyName == "" {
+			continue
+		}
+
+		policy := contivModel.FindPolicy(baselinePolicyKey(policyName))
+		if policy == nil {
+			continue
+		}
+
+		if err := master.PolicyUpdateBaseline(policy); err != nil {
+			log.Errorf("Error updating rules of policy %s. Err: %v", policy.Key, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tenantEndpointGroups returns all endpoint groups of a tenant
+func tenantEndpointGroups(tenant *contivModel.Tenant) []*contivModel.EndpointGroup {
+	var epgs []*contivModel.EndpointGroup
+	for epgKey := range tenant.LinkSets.EndpointGroups {
+		epg := contivModel.FindEndpointGroup(epgKey)
+		if epg == nil {
+			log.Errorf("Could not find epg %s", epgKey)
+			continue
+		}
+
+		epgs = append(epgs, epg)
+	}
+
+	return epgs
+}
+
+// allEndpointGroups returns the endpoint groups of all tenants
+func allEndpointGroups() ([]*contivModel.EndpointGroup, error) {
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	readEpg := &mastercfg.EndpointGroupState{}
+	readEpg.StateDriver = stateDriver
+	epgCfgs, err := readEpg.ReadAll()
+	if core.ErrIfKeyExists(err) != nil {
+		log.Errorf("Error reading endpoint groups. Err: %v", err)
+		return nil, err
+	}
+
+	var epgs []*contivModel.EndpointGroup
+	for _, epgCfg := range epgCfgs {
+		epgState := epgCfg.(*mastercfg.EndpointGroupState)
+		epgKey := epgState.TenantName + ":" + epgState.GroupName
+		epg := contivModel.FindEndpointGroup(epgKey)
+		if epg == nil {
+			log.Errorf("Could not find epg %s", epgKey)
+			continue
+		}
+
+		epgs = append(epgs, epg)
+	}
+
+	return epgs, nil
+}
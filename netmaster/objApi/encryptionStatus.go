@@ -0,0 +1,65 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objApi
+
+import (
+	"fmt"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+)
+
+// getHostEncryptionStatus fetches the encryption status of the tunnels
+// from the netplugin on a host
+func getHostEncryptionStatus(hostAddr string) ([]mastercfg.EncryptionPeerStatus, error) {
+	var status []mastercfg.EncryptionPeerStatus
+
+	if err := getNetpluginObject(hostAddr, "encryptionstatus", &status); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// getEncryptionStatus returns the encryption status of the tunnels of all
+// netplugins, one "host: peer state" line per tunnel
+func (ac *APIController) getEncryptionStatus() ([]string, error) {
+	srvList, err := ac.objdbClient.GetService("netplugin")
+	if err != nil {
+		log.Errorf("Error getting netplugin nodes. Err: %v", err)
+		return nil, err
+	}
+
+	statusList := []string{}
+	for _, srv := range srvList {
+		status, err := getHostEncryptionStatus(srv.HostAddr)
+		if err != nil {
+			// dont fail the inspect because one host is unreachable
+			log.Errorf("Error getting encryption status from host %s. Err: %v", srv.Hostname, err)
+			statusList = append(statusList, fmt.Sprintf("%s: unreachable", srv.Hostname))
+			continue
+		}
+
+		for _, peer := range status {
+			statusList = append(statusList, fmt.Sprintf("%s: %s %s %s (key generation %d)",
+				srv.Hostname, peer.Peer, peer.Interface, peer.State, peer.KeyGeneration))
+		}
+	}
+
+	sort.Strings(statusList)
+	return statusList, nil
+}
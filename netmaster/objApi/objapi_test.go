@@ -515,6 +515,43 @@ func checkCreateNetProfile(t *testing.T, expError bool, dscp, burst int, bandwid
 	}
 }
 
+// checkCreateShapedNetProfile creates a netprofile shaping the traffic
+func checkCreateShapedNetProfile(t *testing.T, expError bool, ingressRate, egressRate, egressMinRate, profileName, tenantName string) {
+	np := client.Netprofile{
+		IngressRate:   ingressRate,
+		EgressRate:    egressRate,
+		EgressMinRate: egressMinRate,
+		TenantName:    tenantName,
+		ProfileName:   profileName,
+	}
+	err := contivClient.NetprofilePost(&np)
+	if err != nil && !expError {
+		t.Fatalf("Error creating Netprofile {%+v}. Err: %v", np, err)
+	} else if err == nil && expError {
+		t.Fatalf("Create NetProfile {%+v} succeeded while expecing error", np)
+	}
+}
+
+// verifyEpgShaping verifies the shaping rates of an EPG state
+func verifyEpgShaping(t *testing.T, tenant, group, ingressRate, egressRate, egressMinRate string) {
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		t.Fatalf("Error getting the state of etcd driver %v", err)
+	}
+
+	epCfg := mastercfg.EndpointGroupState{}
+	epCfg.StateDriver = stateDriver
+	err = epCfg.Read(group + ":" + tenant)
+	if err != nil {
+		t.Fatalf("Error finding endpointgroup %s:%s. Err: %v", group, tenant, err)
+	}
+
+	if epCfg.IngressRate != ingressRate || epCfg.EgressRate != egressRate || epCfg.EgressMinRate != egressMinRate {
+		t.Fatalf("Endpoint group %s has rates %s/%s/%s, expected %s/%s/%s", epCfg.GroupName,
+			epCfg.IngressRate, epCfg.EgressRate, epCfg.EgressMinRate, ingressRate, egressRate, egressMinRate)
+	}
+}
+
 func checkverifyNetProfile(t *testing.T, expError bool, dscp, burst int, bandwidth, profileName, tenantName string) {
 	paramsKey := GetNetprofileKey(tenantName, profileName)
 	netprofile := contivModel.FindNetprofile(paramsKey)
@@ -1215,6 +1252,37 @@ func TestNetworkAddDelete(t *testing.T) {
 	checkDeleteNetwork(t, true, "default", "contiv")
 }
 
+// TestNetworkUpdate tests changing gateway, subnet range and tag of a network
+func TestNetworkUpdate(t *testing.T) {
+	// ensure global configs set
+	checkGlobalSet(t, false, "default", "1-4094", "1-10000", "bridge", "proxy", "172.19.0.0/16")
+
+	checkCreateNetwork(t, false, "default", "contiv", "", "vxlan", "10.1.1.10-10.1.1.20/24", "10.1.1.254", 1, "", "", "")
+	verifyNetworkState(t, "default", "contiv", "data", "vxlan", "10.1.1.10", "10.1.1.254", 24, 1, 1, "", "", 0)
+
+	// grow the range and move the gateway
+	checkCreateNetwork(t, false, "default", "contiv", "", "vxlan", "10.1.1.10-10.1.1.100/24", "10.1.1.1", 1, "", "", "")
+	verifyNetworkState(t, "default", "contiv", "data", "vxlan", "10.1.1.10", "10.1.1.1", 24, 1, 1, "", "", 0)
+
+	// grow the subnet and change the tag
+	checkCreateNetwork(t, false, "default", "contiv", "", "vxlan", "10.1.0.0/23", "10.1.1.1", 1, "", "", "contiv-net")
+	verifyNetworkState(t, "default", "contiv", "data", "vxlan", "10.1.0.0", "10.1.1.1", 23, 1, 1, "", "", 0)
+
+	// gateway outside the subnet
+	checkCreateNetwork(t, true, "default", "contiv", "", "vxlan", "10.1.0.0/23", "10.1.2.1", 1, "", "", "")
+
+	// encap and pkt-tag can not be changed
+	checkCreateNetwork(t, true, "default", "contiv", "", "vlan", "10.1.0.0/23", "10.1.1.1", 1, "", "", "")
+	checkCreateNetwork(t, true, "default", "contiv", "", "vxlan", "10.1.0.0/23", "10.1.1.1", 2, "", "", "")
+
+	// overlapping subnet with another network
+	checkCreateNetwork(t, false, "default", "contiv2", "", "vxlan", "10.2.1.0/24", "10.2.1.254", 2, "", "", "")
+	checkCreateNetwork(t, true, "default", "contiv2", "", "vxlan", "10.1.0.0/16", "10.1.1.254", 2, "", "", "")
+
+	checkDeleteNetwork(t, false, "default", "contiv2")
+	checkDeleteNetwork(t, false, "default", "contiv")
+}
+
 func TestDynamicGlobalVlanRange(t *testing.T) {
 	// ensure global configs set
 	checkGlobalSet(t, false, "default", "1-4094", "1-10000", "bridge", "proxy", "172.19.0.0/16")
@@ -1537,8 +1605,8 @@ func TestPolicyRules(t *testing.T) {
 	checkCreateRule(t, false, "default", "policy1", "to-ip", "in", "", "", "10.2.1.11", "", "", "10.1.1.15", "tcp", "allow", 1, 80)
 	checkCreateEpg(t, false, "default", "contiv", "group1", []string{}, []string{}, "")
 
-	// verify duplicate rule id fails
-	checkCreateRule(t, true, "default", "policy1", "1", "in", "", "", "", "", "", "", "tcp", "allow", 1, 80)
+	// verify duplicate rule id updates the rule
+	checkCreateRule(t, false, "default", "policy1", "1", "in", "", "", "", "", "", "", "tcp", "allow", 1, 80)
 
 	// verify unknown directions fail
 	checkCreateRule(t, true, "default", "policy1", "100", "both", "", "", "", "", "", "", "tcp", "allow", 1, 0)
@@ -1604,6 +1672,594 @@ func TestPolicyRules(t *testing.T) {
 	checkDeleteNetwork(t, false, "default", "contiv")
 }
 
+// verifyEpgPolicyRule verifies the rule in epg policy has expected ports and action
+func verifyEpgPolicyRule(t *testing.T, tenant, group, policy, ruleID string, port int, action string) {
+	epgpKey := tenant + ":" + group + ":" + tenant + ":" + policy
+	ruleKey := tenant + ":" + policy + ":" + ruleID
+
+	gp := mastercfg.FindEpgPolicy(epgpKey)
+	if gp == nil {
+		t.Fatalf("Error finding EPG policy %s", epgpKey)
+	}
+
+	ruleMap := gp.RuleMaps[ruleKey]
+	if ruleMap == nil {
+		t.Fatalf("Rule %s not found in EPG policy %s", ruleKey, epgpKey)
+	}
+
+	if len(ruleMap.OfnetRules) == 0 {
+		t.Fatalf("No ofnet rules for rule %s in EPG policy %s", ruleKey, epgpKey)
+	}
+
+	for _, ofnetRule := range ruleMap.OfnetRules {
+		if ofnetRule.Action != action {
+			t.Fatalf("ofnet rule %+v has action %s, expected %s", ofnetRule, ofnetRule.Action, action)
+		}
+		if int(ofnetRule.DstPort) != port && int(ofnetRule.SrcPort) != port {
+			t.Fatalf("ofnet rule %+v doesnt match port %d", ofnetRule, port)
+		}
+	}
+}
+
+// TestPolicyRuleUpdate tests updating rules of a policy attached to EPGs
+func TestPolicyRuleUpdate(t *testing.T) {
+	// ensure global configs set
+	checkGlobalSet(t, false, "default", "1-4094", "1-10000", "bridge", "proxy", "172.19.0.0/16")
+
+	checkCreateNetwork(t, false, "default", "contiv", "data", "vxlan", "10.1.1.1/16", "10.1.1.254", 1, "", "", "")
+	checkCreatePolicy(t, false, "default", "policy1")
+	checkCreateRule(t, false, "default", "policy1", "1", "in", "", "", "", "", "", "", "tcp", "allow", 1, 80)
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{"policy1"}, []string{}, "")
+	checkCreateEpg(t, false, "default", "contiv", "group2", []string{"policy1"}, []string{}, "")
+	checkCreateEpg(t, false, "default", "contiv", "group3", []string{}, []string{}, "")
+	verifyEpgPolicyRule(t, "default", "group1", "policy1", "1", 80, "allow")
+
+	// update port, priority and action
+	checkCreateRule(t, false, "default", "policy1", "1", "in", "", "", "", "", "", "", "tcp", "allow", 2, 8080)
+	verifyEpgPolicyRule(t, "default", "group1", "policy1", "1", 8080, "allow")
+	verifyEpgPolicyRule(t, "default", "group2", "policy1", "1", 8080, "allow")
+	checkCreateRule(t, false, "default", "policy1", "1", "in", "", "", "", "", "", "", "tcp", "deny", 2, 8080)
+	verifyEpgPolicyRule(t, "default", "group1", "policy1", "1", 8080, "deny")
+	verifyEpgPolicyRule(t, "default", "group2", "policy1", "1", 8080, "deny")
+
+	// update matching epg and verify links are moved
+	checkCreateRule(t, false, "default", "policy1", "1", "in", "", "group3", "", "", "", "", "tcp", "deny", 2, 8080)
+	epg3 := contivModel.FindEndpointGroup("default:group3")
+	if epg3 == nil || epg3.LinkSets.MatchRules["default:policy1:1"].ObjKey == "" {
+		t.Fatalf("rule not linked to epg group3")
+	}
+	checkCreateRule(t, false, "default", "policy1", "1", "in", "", "", "", "", "", "", "tcp", "deny", 2, 8080)
+	if _, ok := epg3.LinkSets.MatchRules["default:policy1:1"]; ok {
+		t.Fatalf("rule still linked to epg group3")
+	}
+
+	// verify invalid updates fail and keep the rule as is
+	checkCreateRule(t, true, "default", "policy1", "1", "xyz", "", "", "", "", "", "", "tcp", "allow", 1, 80)
+	checkCreateRule(t, true, "default", "policy1", "1", "in", "", "invalid", "", "", "", "", "tcp", "allow", 1, 80)
+	verifyEpgPolicyRule(t, "default", "group1", "policy1", "1", 8080, "deny")
+
+	// cleanup
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{}, []string{}, "")
+	checkCreateEpg(t, false, "default", "contiv", "group2", []string{}, []string{}, "")
+	checkDeleteRule(t, false, "default", "policy1", "1")
+	checkDeletePolicy(t, false, "default", "policy1")
+	checkDeleteEpg(t, false, "default", "contiv", "group1")
+	checkDeleteEpg(t, false, "default", "contiv", "group2")
+	checkDeleteEpg(t, false, "default", "contiv", "group3")
+	checkDeleteNetwork(t, false, "default", "contiv")
+}
+
+// checkCreateRulePorts creates an incoming rule matching a port list
+func checkCreateRulePorts(t *testing.T, expError bool, tenant, policy, ruleID, proto, ports string) {
+	rule := client.Rule{
+		TenantName: tenant,
+		PolicyName: policy,
+		RuleID:     ruleID,
+		Direction:  "in",
+		Priority:   1,
+		Protocol:   proto,
+		Ports:      ports,
+		Action:     "allow",
+	}
+	err := contivClient.RulePost(&rule)
+	if err != nil && !expError {
+		t.Fatalf("Error creating rule {%+v}. Err: %v", rule, err)
+	} else if err == nil && expError {
+		t.Fatalf("Create rule {%+v} succeeded while expecting error", rule)
+	}
+}
+
+// TestPolicyRulePorts tests rules matching port lists and ranges
+func TestPolicyRulePorts(t *testing.T) {
+	// ensure global configs set
+	checkGlobalSet(t, false, "default", "1-4094", "1-10000", "bridge", "proxy", "172.19.0.0/16")
+
+	checkCreateNetwork(t, false, "default", "contiv", "data", "vxlan", "10.1.1.1/16", "10.1.1.254", 1, "", "", "")
+	checkCreatePolicy(t, false, "default", "policy1")
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{"policy1"}, []string{}, "")
+
+	// 80 and 443 are exact matches, the range needs five masked matches
+	checkCreateRulePorts(t, false, "default", "policy1", "1", "tcp", "80,443,30000-32767")
+	gp := mastercfg.FindEpgPolicy("default:group1:default:policy1")
+	if gp == nil {
+		t.Fatalf("Error finding EPG policy")
+	}
+	ruleMap := gp.RuleMaps["default:policy1:1"]
+	if ruleMap == nil || len(ruleMap.OfnetRules) != 14 {
+		t.Fatalf("Unexpected ofnet rules for port range rule: %+v", ruleMap)
+	}
+	for _, ofnetRule := range ruleMap.OfnetRules {
+		if ofnetRule.DstPort == 30000 && ofnetRule.DstPortMask != 0xfff0 {
+			t.Fatalf("ofnet rule %+v has wrong port mask", ofnetRule)
+		}
+	}
+
+	// verify invalid port lists fail
+	checkCreateRulePorts(t, true, "default", "policy1", "2", "icmp", "80,443")
+	checkCreateRulePorts(t, true, "default", "policy1", "2", "tcp", "80-70")
+	checkCreateRulePorts(t, true, "default", "policy1", "2", "udp", "70000")
+
+	// cleanup
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{}, []string{}, "")
+	checkDeleteRule(t, false, "default", "policy1", "1")
+	checkDeletePolicy(t, false, "default", "policy1")
+	checkDeleteEpg(t, false, "default", "contiv", "group1")
+	checkDeleteNetwork(t, false, "default", "contiv")
+}
+
+// checkCreateAddressSet creates an address set and checks for error
+func checkCreateAddressSet(t *testing.T, expError bool, tenant, setName string, addrs []string) {
+	addrSet := client.AddressSet{
+		TenantName:     tenant,
+		AddressSetName: setName,
+		Addresses:      addrs,
+	}
+	err := contivClient.AddressSetPost(&addrSet)
+	if err != nil && !expError {
+		t.Fatalf("Error creating address set {%+v}. Err: %v", addrSet, err)
+	} else if err == nil && expError {
+		t.Fatalf("Create address set {%+v} succeeded while expecting error", addrSet)
+	}
+}
+
+// verifyAddressSetRule verifies the ofnet rules of a rule match the addresses
+func verifyAddressSetRule(t *testing.T, gpKey, ruleKey string, addrs []string) {
+	gp := mastercfg.FindEpgPolicy(gpKey)
+	if gp == nil {
+		t.Fatalf("Error finding EPG policy %s", gpKey)
+	}
+	ruleMap := gp.RuleMaps[ruleKey]
+	if ruleMap == nil || len(ruleMap.OfnetRules) != len(addrs) {
+		t.Fatalf("Rule %s has ofnet rules %+v, expected addresses %v", ruleKey, ruleMap, addrs)
+	}
+	for _, addr := range addrs {
+		found := false
+		for _, ofnetRule := range ruleMap.OfnetRules {
+			if ofnetRule.SrcIpAddr == addr {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("No ofnet rule of %s matches address %s", ruleKey, addr)
+		}
+	}
+}
+
+// TestAddressSets tests rules matching address sets
+func TestAddressSets(t *testing.T) {
+	// ensure global configs set
+	checkGlobalSet(t, false, "default", "1-4094", "1-10000", "bridge", "proxy", "172.19.0.0/16")
+
+	checkCreateNetwork(t, false, "default", "contiv", "data", "vxlan", "10.1.1.1/16", "10.1.1.254", 1, "", "", "")
+	checkCreatePolicy(t, false, "default", "policy1")
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{"policy1"}, []string{}, "")
+
+	// verify invalid addresses and unknown tenants fail
+	checkCreateAddressSet(t, true, "default", "corp", []string{"10.1.1.0/33"})
+	checkCreateAddressSet(t, true, "default", "corp", []string{"corp-host"})
+	checkCreateAddressSet(t, true, "tenant1", "corp", []string{"10.1.1.0/24"})
+
+	checkCreateAddressSet(t, false, "default", "corp", []string{"10.10.0.0/16", "10.20.0.0/16", "2001:db8::/32"})
+
+	// verify rules can only refer to existing address sets in the right direction
+	rule := client.Rule{
+		TenantName:     "default",
+		PolicyName:     "policy1",
+		RuleID:         "1",
+		Direction:      "in",
+		Priority:       1,
+		FromAddressSet: "invalid",
+		Action:         "allow",
+	}
+	if err := contivClient.RulePost(&rule); err == nil {
+		t.Fatalf("Rule with unknown address set succeeded")
+	}
+	rule.FromAddressSet = ""
+	rule.ToAddressSet = "corp"
+	if err := contivClient.RulePost(&rule); err == nil {
+		t.Fatalf("Incoming rule with to address set succeeded")
+	}
+	rule.ToAddressSet = ""
+	rule.FromAddressSet = "corp"
+	if err := contivClient.RulePost(&rule); err != nil {
+		t.Fatalf("Error creating rule {%+v}. Err: %v", rule, err)
+	}
+
+	gpKey := "default:group1:default:policy1"
+	verifyAddressSetRule(t, gpKey, "default:policy1:1", []string{"10.10.0.0/16", "10.20.0.0/16", "2001:db8::/32"})
+
+	// update the addresses and verify the rule is recompiled
+	checkCreateAddressSet(t, false, "default", "corp", []string{"10.30.0.0/16"})
+	verifyAddressSetRule(t, gpKey, "default:policy1:1", []string{"10.30.0.0/16"})
+	if contivModel.FindRule("default:policy1:1").FromAddressSet != "corp" {
+		t.Fatalf("rule modified by address set update")
+	}
+
+	// verify address set in use can not be deleted
+	if err := contivClient.AddressSetDelete("default", "corp"); err == nil {
+		t.Fatalf("Deleting address set in use succeeded")
+	}
+
+	// cleanup
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{}, []string{}, "")
+	checkDeleteRule(t, false, "default", "policy1", "1")
+	if err := contivClient.AddressSetDelete("default", "corp"); err != nil {
+		t.Fatalf("Error deleting address set. Err: %v", err)
+	}
+	checkDeletePolicy(t, false, "default", "policy1")
+	checkDeleteEpg(t, false, "default", "contiv", "group1")
+	checkDeleteNetwork(t, false, "default", "contiv")
+}
+
+// checkCreateMirrorSession creates a mirror session and checks for error
+func checkCreateMirrorSession(t *testing.T, expError bool, session client.MirrorSession) {
+	err := contivClient.MirrorSessionPost(&session)
+	if err != nil && !expError {
+		t.Fatalf("Error creating mirror session {%+v}. Err: %v", session, err)
+	} else if err == nil && expError {
+		t.Fatalf("Create mirror session {%+v} succeeded while expecting error", session)
+	}
+}
+
+// TestMirrorSessions tests creating, updating and deleting mirror sessions
+func TestMirrorSessions(t *testing.T) {
+	// ensure global configs set
+	checkGlobalSet(t, false, "default", "1-4094", "1-10000", "bridge", "proxy", "172.19.0.0/16")
+
+	checkCreateNetwork(t, false, "default", "contiv", "data", "vxlan", "10.1.1.1/16", "10.1.1.254", 1, "", "", "")
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{}, []string{}, "")
+
+	// verify unknown sources, tenants and invalid destinations fail
+	checkCreateMirrorSession(t, true, client.MirrorSession{TenantName: "default", SessionName: "tap",
+		EndpointGroup: "invalid", DestinationPort: "ids0"})
+	checkCreateMirrorSession(t, true, client.MirrorSession{TenantName: "default", SessionName: "tap",
+		NetworkName: "invalid", DestinationPort: "ids0"})
+	checkCreateMirrorSession(t, true, client.MirrorSession{TenantName: "tenant1", SessionName: "tap",
+		Endpoints: []string{"web-1"}, DestinationPort: "ids0"})
+	checkCreateMirrorSession(t, true, client.MirrorSession{TenantName: "default", SessionName: "tap",
+		EndpointGroup: "group1", DestinationType: "gre"})
+	checkCreateMirrorSession(t, true, client.MirrorSession{TenantName: "default", SessionName: "tap",
+		EndpointGroup: "group1", DestinationType: "erspan", CollectorIp: "10.10.1.1", ErspanId: 2048})
+
+	checkCreateMirrorSession(t, false, client.MirrorSession{TenantName: "default", SessionName: "tap",
+		EndpointGroup: "group1", DestinationPort: "ids0"})
+
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		t.Fatalf("Error getting state driver. Err: %v", err)
+	}
+	mirrorCfg := &mastercfg.CfgMirrorSessionState{}
+	mirrorCfg.StateDriver = stateDriver
+	if err := mirrorCfg.Read("default:tap"); err != nil {
+		t.Fatalf("Error reading mirror session state. Err: %v", err)
+	}
+	if mirrorCfg.EndpointGroup != "group1" || mirrorCfg.DestinationType != "local" ||
+		mirrorCfg.Direction != "both" {
+		t.Fatalf("Unexpected mirror session state {%+v}", mirrorCfg)
+	}
+
+	// update the session to a remote collector
+	checkCreateMirrorSession(t, false, client.MirrorSession{TenantName: "default", SessionName: "tap",
+		NetworkName: "contiv", Direction: "ingress", DestinationType: "gre", CollectorIp: "10.10.1.1"})
+	if err := mirrorCfg.Read("default:tap"); err != nil {
+		t.Fatalf("Error reading mirror session state. Err: %v", err)
+	}
+	if mirrorCfg.Network != "contiv" || mirrorCfg.EndpointGroup != "" || mirrorCfg.CollectorIP != "10.10.1.1" ||
+		mirrorCfg.Direction != "ingress" {
+		t.Fatalf("Mirror session state {%+v} was not updated", mirrorCfg)
+	}
+	if contivModel.FindMirrorSession("default:tap").CollectorIp != "10.10.1.1" {
+		t.Fatalf("Mirror session object was not updated")
+	}
+
+	// cleanup
+	if err := contivClient.MirrorSessionDelete("default", "tap"); err != nil {
+		t.Fatalf("Error deleting mirror session. Err: %v", err)
+	}
+	if err := mirrorCfg.Read("default:tap"); err == nil {
+		t.Fatalf("Mirror session state still exists after delete")
+	}
+	checkDeleteEpg(t, false, "default", "contiv", "group1")
+	checkDeleteNetwork(t, false, "default", "contiv")
+}
+
+// checkPolicyMode sets the mode of a policy and checks for error
+func checkPolicyMode(t *testing.T, expError bool, tenant, policy, mode string) {
+	pol := client.Policy{
+		TenantName: tenant,
+		PolicyName: policy,
+		Mode:       mode,
+	}
+	err := contivClient.PolicyPost(&pol)
+	if err != nil && !expError {
+		t.Fatalf("Error setting policy mode {%+v}. Err: %v", pol, err)
+	} else if err == nil && expError {
+		t.Fatalf("Set policy mode {%+v} succeeded while expecting error", pol)
+	}
+}
+
+// verifyRuleActions verifies the actions of the ofnet rules of a rule
+func verifyRuleActions(t *testing.T, gpKey, ruleKey, action string) {
+	gp := mastercfg.FindEpgPolicy(gpKey)
+	if gp == nil {
+		t.Fatalf("Error finding EPG policy %s", gpKey)
+	}
+	ruleMap := gp.RuleMaps[ruleKey]
+	if ruleMap == nil || len(ruleMap.OfnetRules) == 0 {
+		t.Fatalf("Error finding ofnet rules of %s", ruleKey)
+	}
+	for _, ofnetRule := range ruleMap.OfnetRules {
+		if ofnetRule.Action != action {
+			t.Fatalf("ofnet rule %+v has action %s, expected %s", ofnetRule, ofnetRule.Action, action)
+		}
+	}
+}
+
+// TestPolicyAuditMode tests switching policies between enforce and audit
+func TestPolicyAuditMode(t *testing.T) {
+	// ensure global configs set
+	checkGlobalSet(t, false, "default", "1-4094", "1-10000", "bridge", "proxy", "172.19.0.0/16")
+
+	checkCreateNetwork(t, false, "default", "contiv", "data", "vxlan", "10.1.1.1/16", "10.1.1.254", 1, "", "", "")
+	checkCreatePolicy(t, false, "default", "policy1")
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{"policy1"}, []string{}, "")
+	checkCreateRule(t, false, "default", "policy1", "1", "in", "", "", "", "", "", "", "tcp", "allow", 2, 80)
+	checkCreateRule(t, false, "default", "policy1", "2", "in", "", "", "", "", "", "", "tcp", "deny", 1, 0)
+
+	// policies are enforced by default
+	pol, err := contivClient.PolicyGet("default", "policy1")
+	if err != nil || pol.Mode != "enforce" {
+		t.Fatalf("Unexpected policy %+v. Err: %v", pol, err)
+	}
+	gpKey := "default:group1:default:policy1"
+	verifyRuleActions(t, gpKey, "default:policy1:1", "allow")
+	verifyRuleActions(t, gpKey, "default:policy1:2", "deny")
+
+	// deny rules are audited, allow rules dont change
+	checkPolicyMode(t, false, "default", "policy1", "audit")
+	verifyRuleActions(t, gpKey, "default:policy1:1", "allow")
+	verifyRuleActions(t, gpKey, "default:policy1:2", "audit")
+
+	// rules added in audit mode are audited too
+	checkCreateRule(t, false, "default", "policy1", "3", "in", "", "", "", "", "", "", "udp", "deny", 1, 0)
+	verifyRuleActions(t, gpKey, "default:policy1:3", "audit")
+
+	// back to enforce
+	checkPolicyMode(t, false, "default", "policy1", "enforce")
+	verifyRuleActions(t, gpKey, "default:policy1:2", "deny")
+	verifyRuleActions(t, gpKey, "default:policy1:3", "deny")
+
+	// invalid mode
+	checkPolicyMode(t, true, "default", "policy1", "monitor")
+
+	// cleanup
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{}, []string{}, "")
+	checkDeleteRule(t, false, "default", "policy1", "1")
+	checkDeleteRule(t, false, "default", "policy1", "2")
+	checkDeleteRule(t, false, "default", "policy1", "3")
+	checkDeletePolicy(t, false, "default", "policy1")
+	checkDeleteEpg(t, false, "default", "contiv", "group1")
+	checkDeleteNetwork(t, false, "default", "contiv")
+}
+
+// checkTenantDefaultPolicy sets the default policy of a tenant
+func checkTenantDefaultPolicy(t *testing.T, expError bool, tenantName, policy string) {
+	tenant, err := contivClient.TenantGet(tenantName)
+	if err != nil {
+		t.Fatalf("Error getting tenant %s. Err: %v", tenantName, err)
+	}
+
+	tenant.DefaultPolicy = policy
+	err = contivClient.TenantPost(tenant)
+	if err != nil && !expError {
+		t.Fatalf("Error setting tenant default policy {%+v}. Err: %v", tenant, err)
+	} else if err == nil && expError {
+		t.Fatalf("Set tenant default policy {%+v} succeeded while expecting error", tenant)
+	}
+}
+
+// checkBaselinePolicy sets the global baseline policy
+func checkBaselinePolicy(t *testing.T, expError bool, policy string) {
+	gl, err := contivClient.GlobalGet("global")
+	if err != nil {
+		t.Fatalf("Error getting global object. Err: %v", err)
+	}
+
+	gl.BaselinePolicy = policy
+	err = contivClient.GlobalPost(gl)
+	if err != nil && !expError {
+		t.Fatalf("Error setting baseline policy {%+v}. Err: %v", gl, err)
+	} else if err == nil && expError {
+		t.Fatalf("Set baseline policy {%+v} succeeded while expecting error", gl)
+	}
+}
+
+// verifyRulePriority verifies the priority of the ofnet rules of a rule
+func verifyRulePriority(t *testing.T, gpKey, ruleKey string, priority int) {
+	gp := mastercfg.FindEpgPolicy(gpKey)
+	if gp == nil {
+		t.Fatalf("Error finding EPG policy %s", gpKey)
+	}
+	ruleMap := gp.RuleMaps[ruleKey]
+	if ruleMap == nil || len(ruleMap.OfnetRules) == 0 {
+		t.Fatalf("Error finding ofnet rules of %s", ruleKey)
+	}
+	for _, ofnetRule := range ruleMap.OfnetRules {
+		if ofnetRule.Priority != priority {
+			t.Fatalf("ofnet rule %+v has priority %d, expected %d", ofnetRule, ofnetRule.Priority, priority)
+		}
+	}
+}
+
+// TestDefaultPolicies tests tenant default and global baseline policies
+func TestDefaultPolicies(t *testing.T) {
+	// ensure global configs set
+	checkGlobalSet(t, false, "default", "1-4094", "1-10000", "bridge", "proxy", "172.19.0.0/16")
+
+	checkCreateNetwork(t, false, "default", "contiv", "data", "vxlan", "10.1.1.1/16", "10.1.1.254", 1, "", "", "")
+	checkCreatePolicy(t, false, "default", "tenantPolicy")
+	checkCreateRule(t, false, "default", "tenantPolicy", "1", "in", "", "", "", "", "", "", "tcp", "deny", 1, 0)
+	checkCreatePolicy(t, false, "default", "baseline")
+	checkCreateRule(t, false, "default", "baseline", "1", "out", "", "", "", "", "", "", "udp", "allow", 1, 53)
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{}, []string{}, "")
+
+	// tenants cant be created with a default policy
+	tenant := client.Tenant{TenantName: "tenant1", DefaultPolicy: "tenantPolicy"}
+	if err := contivClient.TenantPost(&tenant); err == nil {
+		t.Fatalf("Create tenant {%+v} succeeded while expecting error", tenant)
+	}
+
+	// default policy must exist
+	checkTenantDefaultPolicy(t, true, "default", "unknown")
+	checkBaselinePolicy(t, true, "unknown")
+
+	// existing and new groups get the tenant default policy
+	checkTenantDefaultPolicy(t, false, "default", "tenantPolicy")
+	verifyEpgPolicy(t, "default", "contiv", "group1", "tenantPolicy")
+	checkCreateEpg(t, false, "default", "contiv", "group2", []string{}, []string{}, "")
+	verifyEpgPolicy(t, "default", "contiv", "group2", "tenantPolicy")
+
+	// setting the same default policy again is a no-op
+	checkTenantDefaultPolicy(t, false, "default", "tenantPolicy")
+	verifyEpgPolicy(t, "default", "contiv", "group1", "tenantPolicy")
+
+	// a group listing the policy before it becomes the baseline
+	checkCreateEpg(t, false, "default", "contiv", "group3", []string{"baseline"}, []string{}, "")
+	verifyRulePriority(t, "default:group3:default:baseline", "default:baseline:1", 1)
+
+	// baseline rules take precedence over tenant rules
+	checkBaselinePolicy(t, false, "baseline")
+	verifyEpgPolicy(t, "default", "contiv", "group1", "baseline")
+	verifyEpgPolicy(t, "default", "contiv", "group2", "baseline")
+	verifyRulePriority(t, "default:group1:default:tenantPolicy", "default:tenantPolicy:1", 1)
+	verifyRulePriority(t, "default:group1:default:baseline", "default:baseline:1", 1+mastercfg.BaselinePriorityOffset)
+	verifyRulePriority(t, "default:group3:default:baseline", "default:baseline:1", 1+mastercfg.BaselinePriorityOffset)
+
+	// policies in use as defaults cant be deleted
+	checkDeletePolicy(t, true, "default", "tenantPolicy")
+	checkDeletePolicy(t, true, "default", "baseline")
+
+	// removing a default policy from a group keeps it attached
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{"tenantPolicy"}, []string{}, "")
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{}, []string{}, "")
+	verifyEpgPolicy(t, "default", "contiv", "group1", "tenantPolicy")
+
+	// groups keep explicitly attached policies when the default changes
+	checkCreateEpg(t, false, "default", "contiv", "group2", []string{"tenantPolicy"}, []string{}, "")
+	checkTenantDefaultPolicy(t, false, "default", "")
+	checkEpgPolicyDeleted(t, "default", "contiv", "group1", "tenantPolicy")
+	verifyEpgPolicy(t, "default", "contiv", "group2", "tenantPolicy")
+
+	checkBaselinePolicy(t, false, "")
+	checkEpgPolicyDeleted(t, "default", "contiv", "group1", "baseline")
+	checkEpgPolicyDeleted(t, "default", "contiv", "group2", "baseline")
+	verifyEpgPolicy(t, "default", "contiv", "group3", "baseline")
+	verifyRulePriority(t, "default:group3:default:baseline", "default:baseline:1", 1)
+
+	// cleanup
+	checkDeleteEpg(t, false, "default", "contiv", "group1")
+	checkDeleteEpg(t, false, "default", "contiv", "group2")
+	checkDeleteEpg(t, false, "default", "contiv", "group3")
+	checkDeleteRule(t, false, "default", "tenantPolicy", "1")
+	checkDeleteRule(t, false, "default", "baseline", "1")
+	checkDeletePolicy(t, false, "default", "tenantPolicy")
+	checkDeletePolicy(t, false, "default", "baseline")
+	checkDeleteNetwork(t, false, "default", "contiv")
+}
+
+// TestPolicyRuleStats tests aggregation of rule hit counters
+func TestPolicyRuleStats(t *testing.T) {
+	// ensure global configs set
+	checkGlobalSet(t, false, "default", "1-4094", "1-10000", "bridge", "proxy", "172.19.0.0/16")
+
+	checkCreateNetwork(t, false, "default", "contiv", "data", "vxlan", "10.1.1.1/16", "10.1.1.254", 1, "", "", "")
+	checkCreatePolicy(t, false, "default", "policy1")
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{"policy1"}, []string{}, "")
+	checkCreateRule(t, false, "default", "policy1", "1", "in", "", "", "", "", "", "", "tcp", "allow", 1, 80)
+	checkCreateRule(t, false, "default", "policy1", "2", "in", "", "", "", "", "", "", "", "deny", 1, 0)
+
+	policy := contivModel.FindPolicy("default:policy1")
+	if policy == nil {
+		t.Fatalf("Error finding policy")
+	}
+	epgps, err := readEpgPolicies(policy)
+	if err != nil || len(epgps) != 1 {
+		t.Fatalf("Error reading epg policies %+v. Err: %v", epgps, err)
+	}
+
+	// report every ofnet rule on host1 and only rule 1 on host2
+	hostStats := map[string]map[string]*ofnet.OfnetPolicyRuleStats{
+		"host1": {},
+		"host2": {},
+	}
+	numOfnetRules := make(map[string]int)
+	for ruleKey, ruleMap := range epgps[0].RuleMaps {
+		numOfnetRules[ruleMap.Rule.RuleID] = len(ruleMap.OfnetRules)
+		for ofnetRuleID := range ruleMap.OfnetRules {
+			hostStats["host1"][ofnetRuleID] = &ofnet.OfnetPolicyRuleStats{
+				RuleId:      ofnetRuleID,
+				PacketCount: 10,
+				ByteCount:   1000,
+			}
+			if ruleKey == "default:policy1:1" {
+				hostStats["host2"][ofnetRuleID] = &ofnet.OfnetPolicyRuleStats{
+					RuleId:      ofnetRuleID,
+					PacketCount: 1,
+					ByteCount:   100,
+				}
+			}
+		}
+	}
+
+	expStats := []contivModel.RuleOper{
+		{RuleID: "1", Host: "host1", Packets: 10 * numOfnetRules["1"], Bytes: 1000 * numOfnetRules["1"]},
+		{RuleID: "1", Host: "host2", Packets: numOfnetRules["1"], Bytes: 100 * numOfnetRules["1"]},
+		{RuleID: "2", Host: "host1", Packets: 10 * numOfnetRules["2"], Bytes: 1000 * numOfnetRules["2"]},
+	}
+	ruleStats := aggregateRuleStats(epgps, hostStats)
+	if !reflect.DeepEqual(ruleStats, expStats) {
+		t.Fatalf("Rule stats %+v did not match expected %+v", ruleStats, expStats)
+	}
+
+	// inspect must succeed even when no host reports stats
+	_, err = contivClient.PolicyInspect("default", "policy1")
+	if err != nil {
+		t.Fatalf("Error inspecting policy. Err: %v", err)
+	}
+	_, err = contivClient.RuleInspect("default", "policy1", "1")
+	if err != nil {
+		t.Fatalf("Error inspecting rule. Err: %v", err)
+	}
+
+	// cleanup
+	checkCreateEpg(t, false, "default", "contiv", "group1", []string{}, []string{}, "")
+	checkDeleteRule(t, false, "default", "policy1", "1")
+	checkDeleteRule(t, false, "default", "policy1", "2")
+	checkDeletePolicy(t, false, "default", "policy1")
+	checkDeleteEpg(t, false, "default", "contiv", "group1")
+	checkDeleteNetwork(t, false, "default", "contiv")
+}
+
 // TestEpgPolicies tests attaching policy to EPG
 func TestEpgPolicies(t *testing.T) {
 	// ensure global configs set
@@ -2001,6 +2657,62 @@ func TestNetprofile(t *testing.T) {
 	checkDeleteTenant(t, false, "blue")
 }
 
+// TestNetprofileShaping tests the shaping rates of netprofiles and their
+// update on the attached endpoint groups
+func TestNetprofileShaping(t *testing.T) {
+	// ensure global configs set
+	checkGlobalSet(t, false, "default", "1-4094", "1-10000", "bridge", "proxy", "172.19.0.0/16")
+
+	checkCreateNetwork(t, false, "default", "shape-net", "data", "vlan", "10.1.3.1/24", "10.1.3.254", 1, "", "", "")
+	checkCreateShapedNetProfile(t, false, "1gbps", "100mbps", "10mbps", "shaped", "default")
+	checkCreateEpgNp(t, false, "default", "shaped", "shape-net", "g1", []string{})
+	verifyEpgShaping(t, "default", "g1", "1gbps", "100mbps", "10mbps")
+
+	//verify that the rates are updated on the attached epg
+	checkCreateShapedNetProfile(t, false, "500mbps", "200mbps", "50mbps", "shaped", "default")
+	verifyEpgShaping(t, "default", "g1", "500mbps", "200mbps", "50mbps")
+
+	//verify that the guaranteed rate can't exceed the egress rate
+	checkCreateShapedNetProfile(t, true, "", "10mbps", "20mbps", "shaped", "default")
+	verifyEpgShaping(t, "default", "g1", "500mbps", "200mbps", "50mbps")
+	checkCreateShapedNetProfile(t, true, "", "1mbps", "1gbps", "shaped2", "default")
+
+	//verify that rates are validated and can be given alone
+	checkCreateShapedNetProfile(t, true, "10 ms", "", "", "shaped2", "default")
+	checkCreateShapedNetProfile(t, false, "", "", "20mbps", "shaped2", "default")
+
+	//verify that detaching the netprofile clears the rates
+	checkCreateEpgNp(t, false, "default", "", "shape-net", "g1", []string{})
+	verifyEpgShaping(t, "default", "g1", "", "", "")
+
+	checkDeleteEpg(t, false, "default", "shape-net", "g1")
+	checkDeleteNetProfile(t, false, "shaped", "default")
+	checkDeleteNetProfile(t, false, "shaped2", "default")
+	checkDeleteNetwork(t, false, "default", "shape-net")
+
+	//verify that the egress is shaped on vxlan networks
+	checkCreateNetwork(t, false, "default", "shape-vxlan", "data", "vxlan", "10.1.4.1/24", "10.1.4.254", 1, "", "", "")
+	checkCreateShapedNetProfile(t, false, "", "100mbps", "10mbps", "egress", "default")
+	checkCreateEpgNp(t, false, "default", "egress", "shape-vxlan", "g2", []string{})
+	verifyEpgShaping(t, "default", "g2", "", "100mbps", "10mbps")
+
+	//verify that the egress can't be both policed and shaped
+	np := client.Netprofile{
+		Bandwidth:   "100mbps",
+		EgressRate:  "100mbps",
+		TenantName:  "default",
+		ProfileName: "egress",
+	}
+	if err := contivClient.NetprofilePost(&np); err == nil {
+		t.Fatalf("Create NetProfile {%+v} succeeded while expecing error", np)
+	}
+	verifyEpgShaping(t, "default", "g2", "", "100mbps", "10mbps")
+
+	checkDeleteEpg(t, false, "default", "shape-vxlan", "g2")
+	checkDeleteNetProfile(t, false, "egress", "default")
+	checkDeleteNetwork(t, false, "default", "shape-vxlan")
+}
+
 func TestServiceProviderUpdate(t *testing.T) {
 	// ensure global configs set
 	checkGlobalSet(t, false, "default", "1-4094", "1-10000", "bridge", "proxy", "172.19.0.0/16")
@@ -0,0 +1,215 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objApi
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/contivmodel"
+	"github.com/contiv/netplugin/netmaster/mastercfg"
+	"github.com/contiv/netplugin/utils"
+	"github.com/contiv/ofnet"
+)
+
+// Some utility functions to collect policy rule hit counters from netplugins
+
+// ruleOperList sorts rule stats by rule id and host
+type ruleOperList []contivModel.RuleOper
+
+func (l ruleOperList) Len() int      { return len(l) }
+func (l ruleOperList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l ruleOperList) Less(i, j int) bool {
+	if l[i].RuleID != l[j].RuleID {
+		return l[i].RuleID < l[j].RuleID
+	}
+	return l[i].Host < l[j].Host
+}
+
+// netpluginRequestTimeout bounds the requests to the netplugin REST api, so
+// that a hung host does not block an inspect
+const netpluginRequestTimeout = 5 * time.Second
+
+var netpluginClient = &http.Client{Timeout: netpluginRequestTimeout}
+
+// getNetpluginObject fetches a json object from the netplugin REST api on a host
+func getNetpluginObject(hostAddr, path string, obj interface{}) error {
+	url := "http://" + hostAddr + ":9090/" + path
+	r, err := netpluginClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	switch {
+	case r.StatusCode == int(404):
+		return errors.New("page not found")
+	case r.StatusCode == int(403):
+		return errors.New("access denied")
+	case r.StatusCode == int(500):
+		response, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return err
+		}
+		return errors.New(string(response))
+	case r.StatusCode != int(200):
+		log.Debugf("GET Status '%s' status code %d \n", r.Status, r.StatusCode)
+		return errors.New(r.Status)
+	}
+
+	response, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(response, obj)
+}
+
+// getHostPolicyStats fetches policy rule stats from the netplugin on a host
+func getHostPolicyStats(hostAddr string) (map[string]*ofnet.OfnetPolicyRuleStats, error) {
+	var stats map[string]*ofnet.OfnetPolicyRuleStats
+
+	if err := getNetpluginObject(hostAddr, "policystats", &stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// getAllHostPolicyStats fetches policy rule stats from all netplugins, keyed
+// by host name. Hosts are queried in parallel, the ones that can not be
+// reached are returned separately.
+func (ac *APIController) getAllHostPolicyStats() (map[string]map[string]*ofnet.OfnetPolicyRuleStats, []string, error) {
+	srvList, err := ac.objdbClient.GetService("netplugin")
+	if err != nil {
+		log.Errorf("Error getting netplugin nodes. Err: %v", err)
+		return nil, nil, err
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	hostStats := make(map[string]map[string]*ofnet.OfnetPolicyRuleStats)
+	unreachable := []string{}
+	for _, srv := range srvList {
+		wg.Add(1)
+		go func(hostName, hostAddr string) {
+			defer wg.Done()
+
+			stats, err := getHostPolicyStats(hostAddr)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				// dont fail the inspect because one host is unreachable
+				log.Errorf("Error getting policy stats from host %s. Err: %v", hostName, err)
+				unreachable = append(unreachable, hostName)
+				return
+			}
+			hostStats[hostName] = stats
+		}(srv.Hostname, srv.HostAddr)
+	}
+	wg.Wait()
+
+	sort.Strings(unreachable)
+	return hostStats, unreachable, nil
+}
+
+// readEpgPolicies reads the epg policies created for a policy
+func readEpgPolicies(policy *contivModel.Policy) ([]*mastercfg.EpgPolicy, error) {
+	stateDriver, err := utils.GetStateDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	var epgps []*mastercfg.EpgPolicy
+	for epgKey := range policy.LinkSets.EndpointGroups {
+		epgp := &mastercfg.EpgPolicy{}
+		epgp.StateDriver = stateDriver
+		if err := epgp.Read(epgKey + ":" + policy.Key); err != nil {
+			log.Warnf("Error reading epg policy for %s. Err: %v", epgKey, err)
+			continue
+		}
+
+		epgps = append(epgps, epgp)
+	}
+
+	return epgps, nil
+}
+
+// aggregateRuleStats adds up the ofnet rule stats reported by each host
+// into per host stats for each policy rule
+func aggregateRuleStats(epgps []*mastercfg.EpgPolicy,
+	hostStats map[string]map[string]*ofnet.OfnetPolicyRuleStats) []contivModel.RuleOper {
+	ruleStats := make(map[string]*contivModel.RuleOper)
+
+	for host, stats := range hostStats {
+		for _, epgp := range epgps {
+			for ruleKey, ruleMap := range epgp.RuleMaps {
+				for ofnetRuleID := range ruleMap.OfnetRules {
+					stat, ok := stats[ofnetRuleID]
+					if !ok {
+						continue
+					}
+
+					statKey := ruleKey + "|" + host
+					if ruleStats[statKey] == nil {
+						ruleStats[statKey] = &contivModel.RuleOper{
+							RuleID: ruleMap.Rule.RuleID,
+							Host:   host,
+						}
+					}
+					ruleStats[statKey].Packets += int(stat.PacketCount)
+					ruleStats[statKey].Bytes += int(stat.ByteCount)
+				}
+			}
+		}
+	}
+
+	operList := ruleOperList{}
+	for _, oper := range ruleStats {
+		operList = append(operList, *oper)
+	}
+	sort.Sort(operList)
+
+	return operList
+}
+
+// getPolicyRuleStats returns per host hit counters for all rules in a
+// policy, and the hosts whose counters could not be read
+func (ac *APIController) getPolicyRuleStats(policy *contivModel.Policy) ([]contivModel.RuleOper, []string, error) {
+	epgps, err := readEpgPolicies(policy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// policy is not applied anywhere
+	if len(epgps) == 0 {
+		return nil, nil, nil
+	}
+
+	hostStats, unreachable, err := ac.getAllHostPolicyStats()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return aggregateRuleStats(epgps, hostStats), unreachable, nil
+}
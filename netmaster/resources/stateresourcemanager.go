@@ -29,7 +29,8 @@ var resourceRegistry = map[string]reflect.Type{
 
 // StateResourceManager implements the core.ResourceManager interface.
 // It manages the resources in a logically centralized manner using serialized
-// writes to underlying state store.
+// writes to underlying state store. Allocations use versioned writes, so that
+// a netmaster that lost its leadership can't hand out values twice.
 type StateResourceManager struct {
 	stateDriver core.StateDriver
 }
@@ -181,7 +182,6 @@ func (rm *StateResourceManager) GetResourceList(id, desc string) (uint, string)
 // AllocateResourceVal yields the core.Resource for the id and description.
 func (rm *StateResourceManager) AllocateResourceVal(id, desc string, reqValue interface{}) (interface{},
 	error) {
-	// XXX: need to take care of distibuted updates, locks etc here
 	rsrc, alreadyExists, err := rm.findResource(id, desc)
 	if err != nil {
 		return nil, err
@@ -198,7 +198,6 @@ func (rm *StateResourceManager) AllocateResourceVal(id, desc string, reqValue in
 // DeallocateResourceVal removes a value from the resource.
 func (rm *StateResourceManager) DeallocateResourceVal(id, desc string,
 	value interface{}) error {
-	// XXX: need to take care of distibuted updates, locks etc here
 	rsrc, alreadyExists, err := rm.findResource(id, desc)
 	if err != nil {
 		return err
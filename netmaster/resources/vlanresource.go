@@ -47,6 +47,11 @@ type AutoVLANCfgResource struct {
 	VLANs *bitset.BitSet `json:"vlans"`
 }
 
+func init() {
+	core.RegisterSchema(vLANResourceConfigPathPrefix, &AutoVLANCfgResource{})
+	core.RegisterSchema(vLANResourceOperPathPrefix, &AutoVLANOperResource{})
+}
+
 // Write the state.
 func (r *AutoVLANCfgResource) Write() error {
 	key := fmt.Sprintf(vLANResourceConfigPath, r.ID)
@@ -129,21 +134,25 @@ func (r *AutoVLANCfgResource) Reinit(rsrcCfg interface{}) error {
 		}
 	}()
 
-	oper := &AutoVLANOperResource{}
-	oper.StateDriver = r.StateDriver
-	oper.ID = r.ID
-	err = oper.Read(r.ID)
-	if err != nil {
-		return err
-	}
+	err = core.UpdateStates(r.StateDriver, func(txn core.StateDriver) error {
+		oper := &AutoVLANOperResource{}
+		oper.StateDriver = txn
+		oper.ID = r.ID
+		err := oper.Read(r.ID)
+		if err != nil {
+			return err
+		}
 
-	prevVLANs.InPlaceSymmetricDifference(oper.FreeVLANs)
-	oper.FreeVLANs = r.VLANs
-	for i, e := prevVLANs.NextSet(0); e; i, e = prevVLANs.NextSet(i + 1) {
-		oper.FreeVLANs.Clear(i)
-	}
+		allocated := prevVLANs.SymmetricDifference(oper.FreeVLANs)
+		oper.FreeVLANs = r.VLANs.Clone()
+		for i, e := allocated.NextSet(0); e; i, e = allocated.NextSet(i + 1) {
+			oper.FreeVLANs.Clear(i)
+		}
 
-	return oper.Write()
+		return oper.Write()
+	})
+
+	return err
 }
 
 // Description is a description of this resource. returns AutoVLANResource.
@@ -211,29 +220,34 @@ func (r *AutoVLANCfgResource) GetList() (uint, string) {
 
 // Allocate a resource.
 func (r *AutoVLANCfgResource) Allocate(reqVal interface{}) (interface{}, error) {
-	oper := &AutoVLANOperResource{}
-	oper.StateDriver = r.StateDriver
-	err := oper.Read(r.ID)
-	if err != nil {
-		return nil, err
-	}
-
 	var vlan uint
-	if (reqVal != nil) && (reqVal.(uint) != 0) {
-		vlan = reqVal.(uint)
-		if !oper.FreeVLANs.Test(vlan) {
-			return nil, fmt.Errorf("requested vlan not available - vlan:%d", vlan)
+
+	// the free vlans are written only if no one changed them since they
+	// were read, so a vlan is never handed out twice
+	err := core.UpdateStates(r.StateDriver, func(txn core.StateDriver) error {
+		oper := &AutoVLANOperResource{}
+		oper.StateDriver = txn
+		err := oper.Read(r.ID)
+		if err != nil {
+			return err
 		}
-	} else {
-		ok := false
-		vlan, ok = oper.FreeVLANs.NextSet(0)
-		if !ok {
-			return nil, errors.New("no vlans available")
+
+		if (reqVal != nil) && (reqVal.(uint) != 0) {
+			vlan = reqVal.(uint)
+			if !oper.FreeVLANs.Test(vlan) {
+				return fmt.Errorf("requested vlan not available - vlan:%d", vlan)
+			}
+		} else {
+			ok := false
+			vlan, ok = oper.FreeVLANs.NextSet(0)
+			if !ok {
+				return errors.New("no vlans available")
+			}
 		}
-	}
-	oper.FreeVLANs.Clear(vlan)
+		oper.FreeVLANs.Clear(vlan)
 
-	err = oper.Write()
+		return oper.Write()
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -242,23 +256,26 @@ func (r *AutoVLANCfgResource) Allocate(reqVal interface{}) (interface{}, error)
 
 // Deallocate the resource.
 func (r *AutoVLANCfgResource) Deallocate(value interface{}) error {
-	oper := &AutoVLANOperResource{}
-	oper.StateDriver = r.StateDriver
-	err := oper.Read(r.ID)
-	if err != nil {
-		return err
-	}
-
 	vlan, ok := value.(uint)
 	if !ok {
 		return core.Errorf("Invalid type for vlan value")
 	}
-	if oper.FreeVLANs.Test(vlan) {
-		return nil
-	}
-	oper.FreeVLANs.Set(vlan)
 
-	return oper.Write()
+	return core.UpdateStates(r.StateDriver, func(txn core.StateDriver) error {
+		oper := &AutoVLANOperResource{}
+		oper.StateDriver = txn
+		err := oper.Read(r.ID)
+		if err != nil {
+			return err
+		}
+
+		if oper.FreeVLANs.Test(vlan) {
+			return nil
+		}
+		oper.FreeVLANs.Set(vlan)
+
+		return oper.Write()
+	})
 }
 
 // AutoVLANOperResource is an implementation of core.State.
@@ -356,6 +356,28 @@ func (d *testVlanRsrcStateDriver) WriteState(key string, value core.State,
 	return d.validate(key, value, vLANResourceOperWrite)
 }
 
+func (d *testVlanRsrcStateDriver) ReadVersion(key string) ([]byte, uint64, error) {
+	return nil, 0, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testVlanRsrcStateDriver) WriteCAS(key string, value []byte, version uint64) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testVlanRsrcStateDriver) Commit(ops []core.StateOp) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testVlanRsrcStateDriver) ReadStateVersion(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	return 1, d.ReadState(key, value, unmarshal)
+}
+
+func (d *testVlanRsrcStateDriver) WriteStateCAS(key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return d.WriteState(key, value, marshal)
+}
+
 func TestAutoVLANCfgResourceInit(t *testing.T) {
 	rsrc := &AutoVLANCfgResource{}
 	rsrc.StateDriver = vlanRsrcStateDriver
@@ -49,6 +49,11 @@ type AutoVXLANCfgResource struct {
 	FreeVXLANsStart uint           `json:"FreeVXLANsStart"`
 }
 
+func init() {
+	core.RegisterSchema(vXLANResourceConfigPathPrefix, &AutoVXLANCfgResource{})
+	core.RegisterSchema(vXLANResourceOperPathPrefix, &AutoVXLANOperResource{})
+}
+
 // VXLANVLANPair Pairs a VXLAN tag with a VLAN tag.
 type VXLANVLANPair struct {
 	VXLAN uint
@@ -145,26 +150,30 @@ func (r *AutoVXLANCfgResource) Reinit(rsrcCfg interface{}) error {
 		}
 	}()
 
-	oper := &AutoVXLANOperResource{}
-	oper.StateDriver = r.StateDriver
-	oper.ID = r.ID
+	err = core.UpdateStates(r.StateDriver, func(txn core.StateDriver) error {
+		oper := &AutoVXLANOperResource{}
+		oper.StateDriver = txn
+		oper.ID = r.ID
 
-	err = oper.Read(r.ID)
-	if err != nil {
-		return err
-	}
+		err := oper.Read(r.ID)
+		if err != nil {
+			return err
+		}
 
-	prevVXLANs.InPlaceSymmetricDifference(oper.FreeVXLANs)
+		allocated := prevVXLANs.SymmetricDifference(oper.FreeVXLANs)
 
-	oper.FreeVXLANs = r.VXLANs
-	for i, e := prevVXLANs.NextSet(0); e; i, e = prevVXLANs.NextSet(i + 1) {
-		vxlan := i + prevFreeStart
-		oper.FreeVXLANs.Clear(vxlan - r.FreeVXLANsStart)
-	}
+		oper.FreeVXLANs = r.VXLANs.Clone()
+		for i, e := allocated.NextSet(0); e; i, e = allocated.NextSet(i + 1) {
+			vxlan := i + prevFreeStart
+			oper.FreeVXLANs.Clear(vxlan - r.FreeVXLANsStart)
+		}
 
-	oper.FreeLocalVLANs = oper.FreeLocalVLANs.Intersection(r.LocalVLANs)
+		oper.FreeLocalVLANs = oper.FreeLocalVLANs.Intersection(r.LocalVLANs)
 
-	return oper.Write()
+		return oper.Write()
+	})
+
+	return err
 }
 
 // Description is a string description of the resource. Returns AutoVXLANResource.
@@ -224,36 +233,42 @@ func (r *AutoVXLANCfgResource) GetList() (uint, string) {
 
 // Allocate allocates a new resource.
 func (r *AutoVXLANCfgResource) Allocate(reqVal interface{}) (interface{}, error) {
-	oper := &AutoVXLANOperResource{}
-	oper.StateDriver = r.StateDriver
-	err := oper.Read(r.ID)
-	if err != nil {
-		return nil, err
-	}
+	var vxlan, vlan uint
+
+	// the free vxlans and vlans are written only if no one changed them
+	// since they were read, so a pair is never handed out twice
+	err := core.UpdateStates(r.StateDriver, func(txn core.StateDriver) error {
+		oper := &AutoVXLANOperResource{}
+		oper.StateDriver = txn
+		err := oper.Read(r.ID)
+		if err != nil {
+			return err
+		}
 
-	var vxlan uint
-	if (reqVal != nil) && (reqVal.(uint) != 0) {
-		vxlan = reqVal.(uint)
-		if !oper.FreeVXLANs.Test(vxlan) {
-			return nil, fmt.Errorf("requested vxlan not available")
+		if (reqVal != nil) && (reqVal.(uint) != 0) {
+			vxlan = reqVal.(uint)
+			if !oper.FreeVXLANs.Test(vxlan) {
+				return fmt.Errorf("requested vxlan not available")
+			}
+		} else {
+			ok := false
+			vxlan, ok = oper.FreeVXLANs.NextSet(0)
+			if !ok {
+				return errors.New("no vxlans available")
+			}
 		}
-	} else {
+
 		ok := false
-		vxlan, ok = oper.FreeVXLANs.NextSet(0)
+		vlan, ok = oper.FreeLocalVLANs.NextSet(0)
 		if !ok {
-			return nil, errors.New("no vxlans available")
+			return errors.New("no local vlans available")
 		}
-	}
 
-	vlan, ok := oper.FreeLocalVLANs.NextSet(0)
-	if !ok {
-		return nil, errors.New("no local vlans available")
-	}
-
-	oper.FreeVXLANs.Clear(vxlan)
-	oper.FreeLocalVLANs.Clear(vlan)
+		oper.FreeVXLANs.Clear(vxlan)
+		oper.FreeLocalVLANs.Clear(vlan)
 
-	err = oper.Write()
+		return oper.Write()
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -262,23 +277,26 @@ func (r *AutoVXLANCfgResource) Allocate(reqVal interface{}) (interface{}, error)
 
 // Deallocate removes and cleans up a resource.
 func (r *AutoVXLANCfgResource) Deallocate(value interface{}) error {
-	oper := &AutoVXLANOperResource{}
-	oper.StateDriver = r.StateDriver
-	err := oper.Read(r.ID)
-	if err != nil {
-		return err
-	}
-
 	pair, ok := value.(VXLANVLANPair)
 	if !ok {
 		return core.Errorf("Invalid type for vxlan-vlan pair")
 	}
-	vxlan := pair.VXLAN
-	oper.FreeVXLANs.Set(vxlan)
-	vlan := pair.VLAN
-	oper.FreeLocalVLANs.Set(vlan)
 
-	return oper.Write()
+	return core.UpdateStates(r.StateDriver, func(txn core.StateDriver) error {
+		oper := &AutoVXLANOperResource{}
+		oper.StateDriver = txn
+		err := oper.Read(r.ID)
+		if err != nil {
+			return err
+		}
+
+		vxlan := pair.VXLAN
+		oper.FreeVXLANs.Set(vxlan)
+		vlan := pair.VLAN
+		oper.FreeLocalVLANs.Set(vlan)
+
+		return oper.Write()
+	})
 }
 
 // AutoVXLANOperResource is an implementation of core.State
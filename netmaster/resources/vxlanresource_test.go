@@ -421,6 +421,28 @@ func (d *testVXLANRsrcStateDriver) WriteState(key string, value core.State,
 	return d.validate(key, value, vXLANResourceOpWrite)
 }
 
+func (d *testVXLANRsrcStateDriver) ReadVersion(key string) ([]byte, uint64, error) {
+	return nil, 0, core.Errorf("Shouldn't be called!")
+}
+
+func (d *testVXLANRsrcStateDriver) WriteCAS(key string, value []byte, version uint64) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testVXLANRsrcStateDriver) Commit(ops []core.StateOp) error {
+	return core.Errorf("Shouldn't be called!")
+}
+
+func (d *testVXLANRsrcStateDriver) ReadStateVersion(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	return 1, d.ReadState(key, value, unmarshal)
+}
+
+func (d *testVXLANRsrcStateDriver) WriteStateCAS(key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return d.WriteState(key, value, marshal)
+}
+
 func TestAutoVXLANCfgResourceInit(t *testing.T) {
 	rsrc := &AutoVXLANCfgResource{}
 	rsrc.StateDriver = vxlanRsrcStateDriver
@@ -26,7 +26,6 @@ import (
 	"github.com/contiv/netplugin/mgmtfn/dockplugin"
 	"github.com/contiv/netplugin/mgmtfn/k8splugin"
 	"github.com/contiv/netplugin/mgmtfn/mesosplugin"
-	"github.com/contiv/netplugin/netmaster/mastercfg"
 	"github.com/contiv/netplugin/netplugin/cluster"
 	"github.com/contiv/netplugin/netplugin/plugin"
 	"github.com/contiv/netplugin/utils"
@@ -38,10 +37,14 @@ import (
 	"golang.org/x/net/context"
 )
 
+// interval between the reconciliations of the data path
+const reconcileInterval = 5 * time.Minute
+
 // Agent holds the netplugin agent state
 type Agent struct {
 	netPlugin    *plugin.NetPlugin // driver plugin
 	pluginConfig *plugin.Config    // plugin configuration
+	watchErr     chan error        // errors of the state watches
 }
 
 // NewAgent creates a new netplugin agent
@@ -84,6 +87,7 @@ func NewAgent(pluginConfig *plugin.Config) *Agent {
 	agent := &Agent{
 		netPlugin:    netPlugin,
 		pluginConfig: pluginConfig,
+		watchErr:     make(chan error, 1),
 	}
 
 	return agent
@@ -94,77 +98,44 @@ func (ag *Agent) Plugin() *plugin.NetPlugin {
 	return ag.netPlugin
 }
 
-// ProcessCurrentState processes current state as read from stateStore
+// ProcessCurrentState processes current state as read from stateStore.
+// The state watches are started one at a time, in the order the state has to
+// be restored in, eg. networks before endpoints. Each watch sends the
+// existing state first, and the next watch is started once it is processed.
+// Changes made meanwhile are sent by the watches afterwards, so none are
+// missed. The encryption is restored first, so that the tunnels to the peers
+// are encrypted when they are created.
 func (ag *Agent) ProcessCurrentState() error {
 	opts := ag.pluginConfig.Instance
-	readNet := &mastercfg.CfgNetworkState{}
-	readNet.StateDriver = ag.netPlugin.StateDriver
-	netCfgs, err := readNet.ReadAll()
-	if err == nil {
-		for idx, netCfg := range netCfgs {
-			net := netCfg.(*mastercfg.CfgNetworkState)
-			log.Debugf("read net key[%d] %s, populating state \n", idx, net.ID)
-			processNetEvent(ag.netPlugin, net, false, opts)
-			if net.NwType == "infra" {
-				processInfraNwCreate(ag.netPlugin, net, opts)
-			}
-		}
-	}
 
-	readEp := &mastercfg.CfgEndpointState{}
-	readEp.StateDriver = ag.netPlugin.StateDriver
-	epCfgs, err := readEp.ReadAll()
-	if err == nil {
-		for idx, epCfg := range epCfgs {
-			ep := epCfg.(*mastercfg.CfgEndpointState)
-			log.Debugf("read ep key[%d] %s, populating state \n", idx, ep.ID)
-			processEpState(ag.netPlugin, opts, ep.ID)
-		}
-	}
-
-	readBgp := &mastercfg.CfgBgpState{}
-	readBgp.StateDriver = ag.netPlugin.StateDriver
-	bgpCfgs, err := readBgp.ReadAll()
-	if err == nil {
-		for idx, bgpCfg := range bgpCfgs {
-			bgp := bgpCfg.(*mastercfg.CfgBgpState)
-			log.Debugf("read bgp key[%d] %s, populating state \n", idx, bgp.Hostname)
-			processBgpEvent(ag.netPlugin, opts, bgp.Hostname, false)
-		}
-	}
-
-	readEpg := mastercfg.EndpointGroupState{}
-	readEpg.StateDriver = ag.netPlugin.StateDriver
-	epgCfgs, err := readEpg.ReadAll()
-	if err == nil {
-		for idx, epgCfg := range epgCfgs {
-			epg := epgCfg.(*mastercfg.EndpointGroupState)
-			log.Infof("Read epg key[%d] %s, populating state \n", idx, epg.GroupName)
-			processEpgEvent(ag.netPlugin, opts, epg.ID, false)
-		}
+	stateHandlers := []func(*plugin.NetPlugin, core.InstanceInfo, chan bool, chan error){
+		handleEncryptionEvents,
+		handleEncryptionHostEvents,
+		handleNetworkEvents,
+		handleEndpointEvents,
+		handleBgpEvents,
+		handleEpgEvents,
+		handleServiceLBEvents,
+		handleSvcProviderUpdEvents,
+		handleGlobalCfgEvents,
+		handlePolicyRuleEvents,
+		handleMirrorSessionEvents,
 	}
 
-	readServiceLb := &mastercfg.CfgServiceLBState{}
-	readServiceLb.StateDriver = ag.netPlugin.StateDriver
-	serviceLbCfgs, err := readServiceLb.ReadAll()
-	if err == nil {
-		for idx, serviceLbCfg := range serviceLbCfgs {
-			serviceLb := serviceLbCfg.(*mastercfg.CfgServiceLBState)
-			log.Debugf("read svc key[%d] %s for tenant %s, populating state \n", idx,
-				serviceLb.ServiceName, serviceLb.Tenant)
-			processServiceLBEvent(ag.netPlugin, serviceLb, false)
-		}
-	}
-
-	readSvcProviders := &mastercfg.SvcProvider{}
-	readSvcProviders.StateDriver = ag.netPlugin.StateDriver
-	svcProviders, err := readSvcProviders.ReadAll()
-	if err == nil {
-		for idx, providers := range svcProviders {
-			svcProvider := providers.(*mastercfg.SvcProvider)
-			log.Infof("read svc provider[%d] %s , populating state \n", idx,
-				svcProvider.ServiceName)
-			processSvcProviderUpdEvent(ag.netPlugin, svcProvider, false)
+	for _, handler := range stateHandlers {
+		synced := make(chan bool, 1)
+		go handler(ag.netPlugin, opts, synced, ag.watchErr)
+
+		select {
+		case <-synced:
+		case err := <-ag.watchErr:
+			log.Errorf("Error processing current state. Error: %s", err)
+			// let HandleEvents see it too
+			select {
+			case ag.watchErr <- err:
+			default:
+			}
+			return err
 		}
 	}
 
@@ -184,9 +155,24 @@ func (ag *Agent) PostInit() error {
 	// start service REST requests
 	ag.serveRequests()
 
+	// reconcile the data path now that the current state is restored
+	go ag.reconcileLoop()
+
 	return nil
 }
 
+// reconcileLoop reconciles the data path with the state at startup, and
+// periodically after that
+func (ag *Agent) reconcileLoop() {
+	for {
+		if err := ag.netPlugin.Reconcile(); err != nil {
+			log.Errorf("Error reconciling the data path. Err: %v", err)
+		}
+
+		time.Sleep(reconcileInterval)
+	}
+}
+
 func (ag *Agent) monitorDockerEvents(de chan error) {
 	// watch for docker events
 	docker, err := dockerclient.NewClient("unix:///var/run/docker.sock", "", nil, nil)
@@ -203,26 +189,10 @@ func (ag *Agent) monitorDockerEvents(de chan error) {
 	go ag.handleDockerEvents(events, errs)
 }
 
-// HandleEvents handles events
+// HandleEvents handles events. The state watches are started by
+// ProcessCurrentState.
 func (ag *Agent) HandleEvents() error {
-	opts := ag.pluginConfig.Instance
-	recvErr := make(chan error, 1)
-
-	go handleNetworkEvents(ag.netPlugin, opts, recvErr)
-
-	go handleBgpEvents(ag.netPlugin, opts, recvErr)
-
-	go handleEndpointEvents(ag.netPlugin, opts, recvErr)
-
-	go handleEpgEvents(ag.netPlugin, opts, recvErr)
-
-	go handleServiceLBEvents(ag.netPlugin, opts, recvErr)
-
-	go handleSvcProviderUpdEvents(ag.netPlugin, opts, recvErr)
-
-	go handleGlobalCfgEvents(ag.netPlugin, opts, recvErr)
-
-	go handlePolicyRuleEvents(ag.netPlugin, opts, recvErr)
+	recvErr := ag.watchErr
 
 	if ag.pluginConfig.Instance.PluginMode == core.Docker ||
 		ag.pluginConfig.Instance.PluginMode == core.SwarmMode {
@@ -258,6 +228,26 @@ func (ag *Agent) serveRequests() {
 		w.Header().Set("Content-Type", "application/json")
 		w.Write(stats)
 	})
+	s.HandleFunc("/policystats", func(w http.ResponseWriter, r *http.Request) {
+		stats, err := ag.netPlugin.GetPolicyRuleStats()
+		if err != nil {
+			log.Errorf("Error fetching policy stats from driver. Err: %v", err)
+			http.Error(w, "Error fetching policy stats from driver", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(stats)
+	})
+	s.HandleFunc("/encryptionstatus", func(w http.ResponseWriter, r *http.Request) {
+		status, err := ag.netPlugin.GetEncryptionStatus()
+		if err != nil {
+			log.Errorf("Error fetching encryption status from driver. Err: %v", err)
+			http.Error(w, "Error fetching encryption status from driver", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(status)
+	})
 	s.HandleFunc("/inspect/driver", func(w http.ResponseWriter, r *http.Request) {
 		driverState, err := ag.netPlugin.InspectState()
 		if err != nil {
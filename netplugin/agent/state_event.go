@@ -65,7 +65,7 @@ func addVxGWRoutes(netPlugin *plugin.NetPlugin, gwIP string) {
 	}
 	for _, netCfg := range netCfgs {
 		net := netCfg.(*mastercfg.CfgNetworkState)
-		if net.NwType != "infra" && net.PktTagType == "vxlan" {
+		if net.NwType != "infra" && (net.PktTagType == "vxlan" || net.PktTagType == "geneve") {
 			route := fmt.Sprintf("%s/%d", net.SubnetIP, net.SubnetLen)
 			err = netutils.AddIPRoute(route, gwIP)
 			if err != nil {
@@ -97,7 +97,7 @@ func delVxGWRoutes(netPlugin *plugin.NetPlugin, gwIP string) {
 	}
 	for _, netCfg := range netCfgs {
 		net := netCfg.(*mastercfg.CfgNetworkState)
-		if net.NwType != "infra" && net.PktTagType == "vxlan" {
+		if net.NwType != "infra" && (net.PktTagType == "vxlan" || net.PktTagType == "geneve") {
 			route := fmt.Sprintf("%s/%d", net.SubnetIP, net.SubnetLen)
 			err = netutils.DelIPRoute(route, gwIP)
 			if err != nil {
@@ -209,7 +209,7 @@ func processNetEvent(netPlugin *plugin.NetPlugin, nwCfg *mastercfg.CfgNetworkSta
 
 	gwIP := ""
 	route := fmt.Sprintf("%s/%d", nwCfg.SubnetIP, nwCfg.SubnetLen)
-	if nwCfg.NwType != "infra" && nwCfg.PktTagType == "vxlan" {
+	if nwCfg.NwType != "infra" && (nwCfg.PktTagType == "vxlan" || nwCfg.PktTagType == "geneve") {
 		gwIP, _ = getVxGWIP(netPlugin, nwCfg.Tenant, opts.HostLabel)
 	}
 	operStr := ""
@@ -236,6 +236,44 @@ func processNetEvent(netPlugin *plugin.NetPlugin, nwCfg *mastercfg.CfgNetworkSta
 	return
 }
 
+// processNetUpdateEvent handles changes to an existing network. Only the
+// subnet and gateways need reprogramming, other network state changes
+// (address allocation, tags) are ignored here.
+func processNetUpdateEvent(netPlugin *plugin.NetPlugin, prevCfg, nwCfg *mastercfg.CfgNetworkState,
+	opts core.InstanceInfo) {
+	if prevCfg.SubnetIP == nwCfg.SubnetIP && prevCfg.SubnetLen == nwCfg.SubnetLen &&
+		prevCfg.Gateway == nwCfg.Gateway && prevCfg.IPv6Gateway == nwCfg.IPv6Gateway {
+		log.Debugf("No subnet or gateway change on network %q", nwCfg.ID)
+		return
+	}
+
+	// driver reads the updated network state, existing vlan/vni mappings
+	// are left as is
+	err := netPlugin.UpdateNetwork(nwCfg.ID, prevCfg.Gateway, prevCfg.IPv6Gateway)
+	if err != nil {
+		log.Errorf("Network %s operation update failed. Error: %s", nwCfg.ID, err)
+		return
+	}
+
+	// move the host access route for vxlan networks to the new subnet
+	if nwCfg.NwType != "infra" && (nwCfg.PktTagType == "vxlan" || nwCfg.PktTagType == "geneve") &&
+		(prevCfg.SubnetIP != nwCfg.SubnetIP || prevCfg.SubnetLen != nwCfg.SubnetLen) {
+		gwIP, err := getVxGWIP(netPlugin, nwCfg.Tenant, opts.HostLabel)
+		if err == nil && gwIP != "" {
+			prevRoute := fmt.Sprintf("%s/%d", prevCfg.SubnetIP, prevCfg.SubnetLen)
+			route := fmt.Sprintf("%s/%d", nwCfg.SubnetIP, nwCfg.SubnetLen)
+			if err := netutils.DelIPRoute(prevRoute, gwIP); err != nil {
+				log.Errorf("Deleting route %s --> %s: err: %v", prevRoute, gwIP, err)
+			}
+			if err := netutils.AddIPRoute(route, gwIP); err != nil {
+				log.Errorf("Adding route %s --> %s: err: %v", route, gwIP, err)
+			}
+		}
+	}
+
+	log.Infof("Network %s operation update succeeded", nwCfg.ID)
+}
+
 // processEpState restores endpoint state
 func processEpState(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, epID string) error {
 	// take a lock in netplugin to ensure we are programming one event at a time.
@@ -529,11 +567,59 @@ func processPolicyRuleState(netPlugin *plugin.NetPlugin, opts core.InstanceInfo,
 	return err
 }
 
-func processStateEvent(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, rsps chan core.WatchState) {
+// processEncryptionEvent applies the tunnel encryption state, its removal
+// disables the encryption. It is also applied when the public keys of a
+// host change.
+func processEncryptionEvent(netPlugin *plugin.NetPlugin, id string) error {
+	err := netPlugin.UpdateEncryption(id)
+	if err != nil {
+		log.Errorf("Encryption %s update operation failed. Error: %s", id, err)
+		return err
+	}
+
+	log.Infof("Encryption %s update operation succeeded", id)
+	return nil
+}
+
+// processMirrorSessionEvent programs a created or updated mirror session,
+// or removes a deleted one
+func processMirrorSessionEvent(netPlugin *plugin.NetPlugin, sessionID string, isDelete bool) error {
+	if isDelete {
+		err := netPlugin.DelMirrorSession(sessionID)
+		if err != nil {
+			log.Errorf("Mirror session %s delete operation failed. Error: %s", sessionID, err)
+			return err
+		}
+		log.Infof("Mirror session %s delete operation succeeded", sessionID)
+		return nil
+	}
+
+	err := netPlugin.AddMirrorSession(sessionID)
+	if err != nil {
+		log.Errorf("Mirror session %s create operation failed. Error: %s", sessionID, err)
+		return err
+	}
+	log.Infof("Mirror session %s create operation succeeded", sessionID)
+	return nil
+}
+
+// processStateEvent processes the events of a state watch. The watch sends
+// the existing state first, synced is signalled once it is processed.
+func processStateEvent(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, rsps chan core.WatchState, synced chan bool) {
+	inSync := false
 	for {
 		// block on change notifications
 		rsp := <-rsps
 
+		// end of the existing state
+		if rsp.Curr == nil && rsp.Prev == nil {
+			if !inSync {
+				inSync = true
+				synced <- true
+			}
+			continue
+		}
+
 		// For now we deal with only create and delete events
 		currentState := rsp.Curr
 		isDelete := false
@@ -568,9 +654,14 @@ func processStateEvent(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, rsps
 				processGlobalConfigUpdEvent(netPlugin, opts, prevCfg, gCfg)
 			}
 
-			// Ignore modify event on network state
 			if nwCfg, ok := currentState.(*mastercfg.CfgNetworkState); ok {
-				log.Debugf("Received a modify event on network %q, ignoring it", nwCfg.ID)
+				prevCfg, ok := rsp.Prev.(*mastercfg.CfgNetworkState)
+				if !ok {
+					log.Errorf("Invalid previous state %+v of network %q", rsp.Prev, nwCfg.ID)
+					continue
+				}
+				log.Infof("Received update for network: %q", nwCfg.ID)
+				processNetUpdateEvent(netPlugin, prevCfg, nwCfg, opts)
 				continue
 			}
 
@@ -592,7 +683,12 @@ func processStateEvent(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, rsps
 		}
 		if epCfg, ok := currentState.(*mastercfg.CfgEndpointState); ok {
 			log.Infof("Received %q for Endpoint: %q", eventStr, epCfg.ID)
-			processRemoteEpState(netPlugin, opts, epCfg, isDelete)
+			if !inSync {
+				// restore the existing local endpoints too
+				processEpState(netPlugin, opts, epCfg.ID)
+			} else {
+				processRemoteEpState(netPlugin, opts, epCfg, isDelete)
+			}
 		}
 		if bgpCfg, ok := currentState.(*mastercfg.CfgBgpState); ok {
 			log.Infof("Received %q for Bgp: %q", eventStr, bgpCfg.Hostname)
@@ -617,81 +713,120 @@ func processStateEvent(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, rsps
 			log.Infof("Received %q for PolicyRule: %q", eventStr, ruleCfg.RuleId)
 			processPolicyRuleState(netPlugin, opts, ruleCfg.RuleId, isDelete)
 		}
+		if encCfg, ok := currentState.(*mastercfg.CfgEncryptionState); ok {
+			log.Infof("Received %q for encryption: %q", eventStr, encCfg.ID)
+			processEncryptionEvent(netPlugin, encCfg.ID)
+		}
+		if hostCfg, ok := currentState.(*mastercfg.CfgEncryptionHostState); ok {
+			log.Infof("Received %q for encryption keys of host: %q", eventStr, hostCfg.ID)
+			processEncryptionEvent(netPlugin, mastercfg.EncryptionStateID)
+		}
+		if mirrorCfg, ok := currentState.(*mastercfg.CfgMirrorSessionState); ok {
+			log.Infof("Received %q for mirror session: %q", eventStr, mirrorCfg.ID)
+			processMirrorSessionEvent(netPlugin, mirrorCfg.ID, isDelete)
+		}
 	}
 }
 
-func handleNetworkEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, retErr chan error) {
+func handleNetworkEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, synced chan bool, retErr chan error) {
 	rsps := make(chan core.WatchState)
-	go processStateEvent(netPlugin, opts, rsps)
+	go processStateEvent(netPlugin, opts, rsps, synced)
 	cfg := mastercfg.CfgNetworkState{}
 	cfg.StateDriver = netPlugin.StateDriver
 	retErr <- cfg.WatchAll(rsps)
 	log.Errorf("Error from handleNetworkEvents")
 }
 
-func handleBgpEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, recvErr chan error) {
+func handleBgpEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, synced chan bool, recvErr chan error) {
 
 	rsps := make(chan core.WatchState)
-	go processStateEvent(netPlugin, opts, rsps)
+	go processStateEvent(netPlugin, opts, rsps, synced)
 	cfg := mastercfg.CfgBgpState{}
 	cfg.StateDriver = netPlugin.StateDriver
 	recvErr <- cfg.WatchAll(rsps)
 	log.Errorf("Error from handleBgpEvents")
 }
 
-func handleEndpointEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, retErr chan error) {
+func handleEndpointEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, synced chan bool, retErr chan error) {
 	rsps := make(chan core.WatchState)
-	go processStateEvent(netPlugin, opts, rsps)
+	go processStateEvent(netPlugin, opts, rsps, synced)
 	cfg := mastercfg.CfgEndpointState{}
 	cfg.StateDriver = netPlugin.StateDriver
 	retErr <- cfg.WatchAll(rsps)
 	log.Errorf("Error from handleEndpointEvents")
 }
 
-func handleEpgEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, recvErr chan error) {
+func handleEpgEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, synced chan bool, recvErr chan error) {
 
 	rsps := make(chan core.WatchState)
-	go processStateEvent(netPlugin, opts, rsps)
+	go processStateEvent(netPlugin, opts, rsps, synced)
 	cfg := mastercfg.EndpointGroupState{}
 	cfg.StateDriver = netPlugin.StateDriver
 	recvErr <- cfg.WatchAll(rsps)
 	log.Errorf("Error from handleEpgEvents")
 }
 
-func handleServiceLBEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, recvErr chan error) {
+func handleServiceLBEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, synced chan bool, recvErr chan error) {
 
 	rsps := make(chan core.WatchState)
-	go processStateEvent(netPlugin, opts, rsps)
+	go processStateEvent(netPlugin, opts, rsps, synced)
 	cfg := mastercfg.CfgServiceLBState{}
 	cfg.StateDriver = netPlugin.StateDriver
 	recvErr <- cfg.WatchAll(rsps)
 	log.Errorf("Error from handleLBEvents")
 }
 
-func handleSvcProviderUpdEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, recvErr chan error) {
+func handleSvcProviderUpdEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, synced chan bool, recvErr chan error) {
 	rsps := make(chan core.WatchState)
-	go processStateEvent(netPlugin, opts, rsps)
+	go processStateEvent(netPlugin, opts, rsps, synced)
 	cfg := mastercfg.SvcProvider{}
 	cfg.StateDriver = netPlugin.StateDriver
 	recvErr <- cfg.WatchAll(rsps)
 	log.Errorf("Error from handleSvcProviderUpdEvents")
 }
 
-func handleGlobalCfgEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, recvErr chan error) {
+func handleGlobalCfgEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, synced chan bool, recvErr chan error) {
 
 	rsps := make(chan core.WatchState)
-	go processStateEvent(netPlugin, opts, rsps)
+	go processStateEvent(netPlugin, opts, rsps, synced)
 	cfg := mastercfg.GlobConfig{}
 	cfg.StateDriver = netPlugin.StateDriver
 	recvErr <- cfg.WatchAll(rsps)
 	log.Errorf("Error from handleGlobalCfgEvents")
 }
 
-func handlePolicyRuleEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, retErr chan error) {
+func handlePolicyRuleEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, synced chan bool, retErr chan error) {
 	rsps := make(chan core.WatchState)
-	go processStateEvent(netPlugin, opts, rsps)
+	go processStateEvent(netPlugin, opts, rsps, synced)
 	cfg := mastercfg.CfgPolicyRule{}
 	cfg.StateDriver = netPlugin.StateDriver
 	retErr <- cfg.WatchAll(rsps)
 	log.Errorf("Error from handlePolicyRuleEvents")
 }
+
+func handleEncryptionEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, synced chan bool, retErr chan error) {
+	rsps := make(chan core.WatchState)
+	go processStateEvent(netPlugin, opts, rsps, synced)
+	cfg := mastercfg.CfgEncryptionState{}
+	cfg.StateDriver = netPlugin.StateDriver
+	retErr <- cfg.WatchAll(rsps)
+	log.Errorf("Error from handleEncryptionEvents")
+}
+
+func handleEncryptionHostEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, synced chan bool, retErr chan error) {
+	rsps := make(chan core.WatchState)
+	go processStateEvent(netPlugin, opts, rsps, synced)
+	cfg := mastercfg.CfgEncryptionHostState{}
+	cfg.StateDriver = netPlugin.StateDriver
+	retErr <- cfg.WatchAll(rsps)
+	log.Errorf("Error from handleEncryptionHostEvents")
+}
+
+func handleMirrorSessionEvents(netPlugin *plugin.NetPlugin, opts core.InstanceInfo, synced chan bool, retErr chan error) {
+	rsps := make(chan core.WatchState)
+	go processStateEvent(netPlugin, opts, rsps, synced)
+	cfg := mastercfg.CfgMirrorSessionState{}
+	cfg.StateDriver = netPlugin.StateDriver
+	retErr <- cfg.WatchAll(rsps)
+	log.Errorf("Error from handleMirrorSessionEvents")
+}
@@ -614,22 +614,6 @@ func (ens *NetpluginNameServer) NsLookup(nsq []byte, vrfPtr *string) ([]byte, er
 
 }
 
-func (ens *NetpluginNameServer) readStateStore() {
-	svc := mastercfg.CfgServiceLBState{}
-	if st, err := ens.stateDriver.ReadAllState(ens.svcKeyPath, &svc, json.Unmarshal); err == nil {
-		for _, s := range st {
-			ens.addService(s)
-		}
-	}
-
-	ep := mastercfg.CfgEndpointState{}
-	if st, err := ens.stateDriver.ReadAllState(ens.epKeyPath, &ep, json.Unmarshal); err == nil {
-		for _, s := range st {
-			ens.addEndpoint(s)
-		}
-	}
-}
-
 func (ens *NetpluginNameServer) processStateEvent() {
 	for {
 		select {
@@ -639,7 +623,10 @@ func (ens *NetpluginNameServer) processStateEvent() {
 			go ens.startSvcWatch()
 
 		case svcState := <-ens.svcChan:
-			if svcState.Prev == nil {
+			if svcState.Curr == nil && svcState.Prev == nil {
+				// end of the existing services
+				continue
+			} else if svcState.Prev == nil {
 				ens.addService(svcState.Curr)
 			} else if svcState.Curr == nil {
 				ens.delService(svcState.Prev)
@@ -655,7 +642,10 @@ func (ens *NetpluginNameServer) processStateEvent() {
 
 		case state := <-ens.epChan:
 			dnsLog.Infof("endpoint event %+v", state)
-			if state.Prev == nil {
+			if state.Curr == nil && state.Prev == nil {
+				// end of the existing endpoints
+				continue
+			} else if state.Prev == nil {
 				ens.addEndpoint(state.Curr)
 			} else if state.Curr == nil {
 				ens.delEndpoint(state.Prev)
@@ -710,9 +700,9 @@ func (ens *NetpluginNameServer) Init(sd core.StateDriver) error {
 	ens.epKeyPath = mastercfg.StateConfigPath + "eps/"
 	ens.svcKeyPath = mastercfg.StateConfigPath + "serviceLB/"
 	go ens.processStateEvent()
+	// the watches send the existing services and endpoints first
 	go ens.startSvcWatch()
 	go ens.startEndpointWatch()
-	ens.readStateStore()
 	dnsLog.Infof("nameserver started")
 	return nil
 }
@@ -71,6 +71,28 @@ func (ds *dummyState) WriteState(key string, value core.State,
 	marshal func(interface{}) ([]byte, error)) error {
 	return nil
 }
+
+func (ds *dummyState) ReadVersion(key string) ([]byte, uint64, error) {
+	return []byte{}, 0, nil
+}
+
+func (ds *dummyState) WriteCAS(key string, value []byte, version uint64) error {
+	return nil
+}
+
+func (ds *dummyState) Commit(ops []core.StateOp) error {
+	return nil
+}
+
+func (ds *dummyState) ReadStateVersion(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	return 0, nil
+}
+
+func (ds *dummyState) WriteStateCAS(key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return nil
+}
 func (ds *dummyState) ReadState(key string, value core.State,
 	unmarshal func([]byte, interface{}) error) error {
 	return nil
@@ -16,6 +16,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -32,6 +33,9 @@ import (
 
 const binName = "netplugin"
 
+// uplinkRateRegexp matches the rates of the uplink rate option
+var uplinkRateRegexp = regexp.MustCompile("^[1-9][0-9]* ?[kmgKMG](bps)?$")
+
 func startNetPlugin(pluginConfig *plugin.Config) {
 	// Create a new agent
 	ag := agent.NewAgent(pluginConfig)
@@ -68,6 +72,14 @@ func initNetPluginConfig(ctx *cli.Context) (*plugin.Config, error) {
 	}
 
 	// 4. validate and set other optional configs
+	netDriver := ctx.String("driver")
+	switch netDriver {
+	case utils.OvsNameStr, utils.VppNameStr, utils.LinuxNameStr:
+	default:
+		return nil, fmt.Errorf("unsupported network driver %q, options: [ovs, vpp, linux]", netDriver)
+	}
+	logrus.Infof("Using netplugin network driver: %v", netDriver)
+
 	hostLabel := ctx.String("host")
 	var configErr error
 	if hostLabel == "" {
@@ -104,9 +116,22 @@ func initNetPluginConfig(ctx *cli.Context) (*plugin.Config, error) {
 	vxlanPort := ctx.Int("vxlan-port")
 	logrus.Infof("Using netplugin vxlan port: %v", vxlanPort)
 
+	flowLog := ctx.String("flowlog")
+	if flowLog != "" {
+		logrus.Infof("Using netplugin flow log: %v", flowLog)
+	}
+
+	uplinkRate := ctx.String("uplink-rate")
+	if uplinkRate != "" {
+		if !uplinkRateRegexp.MatchString(uplinkRate) {
+			return nil, fmt.Errorf("invalid uplink rate %q, expecting a rate like 10gbps", uplinkRate)
+		}
+		logrus.Infof("Using netplugin uplink rate: %v", uplinkRate)
+	}
+
 	return &plugin.Config{
 		Drivers: plugin.Drivers{
-			Network: utils.OvsNameStr,
+			Network: netDriver,
 			State:   dbConfigs.StoreDriver,
 		},
 		Instance: core.InstanceInfo{
@@ -117,6 +142,8 @@ func initNetPluginConfig(ctx *cli.Context) (*plugin.Config, error) {
 			DbURL:        dbConfigs.StoreURL,
 			PluginMode:   netConfigs.Mode,
 			VxlanUDPPort: vxlanPort,
+			FlowLog:      flowLog,
+			UplinkRate:   uplinkRate,
 			FwdMode:      netConfigs.ForwardMode, // TODO: pass in network mode
 		},
 	}, nil
@@ -132,6 +159,12 @@ func main() {
 			EnvVar: "CONTIV_NETPLUGIN_HOST",
 			Usage:  "set netplugin host to identify itself (default: <host-name-reported-by-the-kernel>)",
 		},
+		cli.StringFlag{
+			Name:   "driver, net-driver",
+			Value:  utils.OvsNameStr,
+			EnvVar: "CONTIV_NETPLUGIN_DRIVER",
+			Usage:  "set netplugin network driver, options: [ovs, vpp, linux]",
+		},
 		cli.StringFlag{
 			Name:   "vtep-ip",
 			EnvVar: "CONTIV_NETPLUGIN_VTEP_IP",
@@ -153,6 +186,16 @@ func main() {
 			EnvVar: "CONTIV_NETPLUGIN_VXLAN_PORT",
 			Usage:  "set netplugin VXLAN port",
 		},
+		cli.StringFlag{
+			Name:   "flowlog",
+			EnvVar: "CONTIV_NETPLUGIN_FLOWLOG",
+			Usage:  "log the first packet of connections hitting policy rules to a file, syslog or a syslog url in format protocol://ip:port",
+		},
+		cli.StringFlag{
+			Name:   "uplink-rate",
+			EnvVar: "CONTIV_NETPLUGIN_UPLINK_RATE",
+			Usage:  "rate of the vlan uplinks whose link speed is unknown and that the shaped endpoints share, e.g. 10gbps. Egress min rates are only guaranteed up to this rate",
+		},
 	}
 	app.Flags = utils.FlattenFlags(netpluginFlags, utils.BuildDBFlags(binName), utils.BuildNetworkFlags(binName), utils.BuildLogFlags(binName))
 	sort.Sort(cli.FlagsByName(app.Flags))
@@ -118,6 +118,13 @@ func (p *NetPlugin) CreateNetwork(id string) error {
 	return p.NetworkDriver.CreateNetwork(id)
 }
 
+// UpdateNetwork applies the subnet and gateway changes of a network.
+func (p *NetPlugin) UpdateNetwork(id, prevGateway, prevIPv6Gateway string) error {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.UpdateNetwork(id, prevGateway, prevIPv6Gateway)
+}
+
 // DeleteNetwork deletes a network provided by the ID.
 func (p *NetPlugin) DeleteNetwork(id, subnet, nwType, encap string, pktTag, extPktTag int, Gw string, tenant string) error {
 	p.Lock()
@@ -254,6 +261,13 @@ func (p *NetPlugin) GetEndpointStats() ([]byte, error) {
 	return p.NetworkDriver.GetEndpointStats()
 }
 
+// GetPolicyRuleStats returns hit counters for all policy rules
+func (p *NetPlugin) GetPolicyRuleStats() ([]byte, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.GetPolicyRuleStats()
+}
+
 // InspectState returns current state of the plugin
 func (p *NetPlugin) InspectState() ([]byte, error) {
 	p.Lock()
@@ -387,3 +401,38 @@ func (p *NetPlugin) DelPolicyRule(id string) error {
 	defer p.Unlock()
 	return p.NetworkDriver.DelPolicyRule(id)
 }
+
+// UpdateEncryption updates the encryption of the tunnels to the peers
+func (p *NetPlugin) UpdateEncryption(id string) error {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.UpdateEncryption(id)
+}
+
+// GetEncryptionStatus returns the encryption status of the tunnels
+func (p *NetPlugin) GetEncryptionStatus() ([]byte, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.GetEncryptionStatus()
+}
+
+// Reconcile reconciles the data path with the state
+func (p *NetPlugin) Reconcile() error {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.Reconcile()
+}
+
+// AddMirrorSession adds or updates a mirror session
+func (p *NetPlugin) AddMirrorSession(id string) error {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.AddMirrorSession(id)
+}
+
+// DelMirrorSession deletes a mirror session
+func (p *NetPlugin) DelMirrorSession(id string) error {
+	p.Lock()
+	defer p.Unlock()
+	return p.NetworkDriver.DelMirrorSession(id)
+}
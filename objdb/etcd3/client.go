@@ -0,0 +1,525 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package etcd3 is a minimal etcd v3 client. It talks to the grpc json
+// gateway every etcd v3 server runs on its client port, so that the grpc
+// client stack does not need to be vendored.
+package etcd3
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Errors returned by the client
+var (
+	ErrKeyNotFound        = errors.New("key not found")
+	ErrLeaseNotFound      = errors.New("lease not found")
+	ErrCompacted          = errors.New("required revision has been compacted")
+	ErrClusterUnavailable = errors.New("etcd cluster is unavailable")
+)
+
+// api versions of the json gateway, newest first. etcd 3.4 and later serve
+// /v3, 3.3 serves /v3beta and 3.2 only /v3alpha.
+var apiPrefixes = []string{"/v3", "/v3beta", "/v3alpha"}
+
+const requestTimeout = 20 * time.Second
+
+// KeyValue is a key and its value with the revisions it was created and
+// last modified at
+type KeyValue struct {
+	Key            string
+	Value          string
+	CreateRevision int64
+	ModRevision    int64
+	Version        int64
+	Lease          int64
+}
+
+// Client is an etcd v3 client
+type Client struct {
+	endpoints  []string // http urls of the cluster members
+	apiPrefix  string   // api version path of the json gateway
+	httpClient *http.Client
+	mutex      sync.Mutex
+	current    int // index of the endpoint in use
+}
+
+// New creates a client for a list of endpoints and checks it can reach the
+// cluster. etcd3:// urls are accepted as http:// urls.
+func New(endpoints []string) (*Client, error) {
+	c := &Client{
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+				Dial: (&net.Dialer{
+					Timeout:   5 * time.Second,
+					KeepAlive: 30 * time.Second,
+				}).Dial,
+				TLSHandshakeTimeout: 5 * time.Second,
+			},
+		},
+	}
+
+	for _, endpoint := range endpoints {
+		epURL, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		switch epURL.Scheme {
+		case "etcd3", "etcd":
+			epURL.Scheme = "http"
+		case "http", "https":
+		default:
+			return nil, fmt.Errorf("invalid etcd URL scheme %q", epURL.Scheme)
+		}
+		c.endpoints = append(c.endpoints, strings.TrimSuffix(epURL.String(), "/"))
+	}
+	if len(c.endpoints) == 0 {
+		c.endpoints = []string{"http://127.0.0.1:2379"}
+	}
+
+	// find the api version the cluster serves
+	for _, prefix := range apiPrefixes {
+		c.apiPrefix = prefix
+		_, _, err := c.Get("/")
+		if err == nil || err == ErrKeyNotFound {
+			return c, nil
+		}
+		if err != errNotFound {
+			return nil, err
+		}
+	}
+
+	return nil, errors.New("etcd does not serve the v3 json api")
+}
+
+// Get reads a key. It returns the key and the revision of the cluster.
+func (c *Client) Get(key string) (*KeyValue, int64, error) {
+	var resp rangeResponse
+	err := c.post("/kv/range", &rangeRequest{Key: []byte(key)}, &resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, int64(resp.Header.Revision), ErrKeyNotFound
+	}
+
+	return resp.Kvs[0].keyValue(), int64(resp.Header.Revision), nil
+}
+
+// GetPrefix reads all keys starting with prefix, sorted by key. It returns
+// the keys and the revision of the cluster.
+func (c *Client) GetPrefix(prefix string) ([]*KeyValue, int64, error) {
+	var resp rangeResponse
+	err := c.post("/kv/range", newPrefixRange(prefix), &resp)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return keyValues(resp.Kvs), int64(resp.Header.Revision), nil
+}
+
+// Put writes a key. lease is zero for keys that dont expire.
+func (c *Client) Put(key, value string, lease int64) error {
+	req := &putRequest{Key: []byte(key), Value: []byte(value), Lease: int64Str(lease)}
+	return c.post("/kv/put", req, &putResponse{})
+}
+
+// Delete removes a key. It returns the deleted key, or ErrKeyNotFound.
+func (c *Client) Delete(key string) (*KeyValue, error) {
+	var resp deleteRangeResponse
+	err := c.post("/kv/deleterange", &deleteRangeRequest{Key: []byte(key), PrevKv: true}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.PrevKvs) == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	return resp.PrevKvs[0].keyValue(), nil
+}
+
+// Compare targets of a transaction
+const (
+	CompareVersion = "VERSION"
+	CompareCreate  = "CREATE"
+	CompareMod     = "MOD"
+	CompareValue   = "VALUE"
+)
+
+// Compare is a condition of a transaction, the key must be equal to the
+// field of the target
+type Compare struct {
+	Key            string
+	Target         string
+	Version        int64
+	CreateRevision int64
+	ModRevision    int64
+	Value          string
+}
+
+// Operation types of a transaction
+const (
+	OpGet    = "get"
+	OpPut    = "put"
+	OpDelete = "delete"
+)
+
+// Op is an operation of a transaction
+type Op struct {
+	Type  string
+	Key   string
+	Value string
+	Lease int64
+}
+
+// TxnResponse is the result of a transaction
+type TxnResponse struct {
+	Succeeded bool          // all compares were equal
+	Revision  int64         // revision of the cluster after the transaction
+	Kvs       [][]*KeyValue // keys read by the get operations, by operation
+}
+
+// Txn runs the success operations if all compares are equal, and the
+// failure operations otherwise, atomically
+func (c *Client) Txn(cmps []Compare, success, failure []Op) (*TxnResponse, error) {
+	req := &txnRequest{}
+	for _, cmp := range cmps {
+		req.Compare = append(req.Compare, newCompare(cmp))
+	}
+	for _, op := range success {
+		req.Success = append(req.Success, newRequestOp(op))
+	}
+	for _, op := range failure {
+		req.Failure = append(req.Failure, newRequestOp(op))
+	}
+
+	var resp txnResponse
+	if err := c.post("/kv/txn", req, &resp); err != nil {
+		return nil, err
+	}
+
+	txnResp := &TxnResponse{
+		Succeeded: resp.Succeeded,
+		Revision:  int64(resp.Header.Revision),
+	}
+	for _, opResp := range resp.Responses {
+		if opResp.ResponseRange != nil {
+			txnResp.Kvs = append(txnResp.Kvs, keyValues(opResp.ResponseRange.Kvs))
+		} else {
+			txnResp.Kvs = append(txnResp.Kvs, nil)
+		}
+	}
+
+	return txnResp, nil
+}
+
+// Grant creates a lease that expires after ttl seconds
+func (c *Client) Grant(ttl int64) (int64, error) {
+	var resp leaseGrantResponse
+	if err := c.post("/lease/grant", &leaseRequest{TTL: int64Str(ttl)}, &resp); err != nil {
+		return 0, err
+	}
+	if resp.Error != "" {
+		return 0, errors.New(resp.Error)
+	}
+
+	return int64(resp.ID), nil
+}
+
+// KeepAliveOnce renews a lease. It returns ErrLeaseNotFound if the lease
+// has expired already.
+func (c *Client) KeepAliveOnce(lease int64) error {
+	var resp struct {
+		Result leaseRequest `json:"result"`
+	}
+	if err := c.post("/lease/keepalive", &leaseRequest{ID: int64Str(lease)}, &resp); err != nil {
+		return err
+	}
+	if resp.Result.TTL <= 0 {
+		return ErrLeaseNotFound
+	}
+
+	return nil
+}
+
+// Revoke removes a lease and all keys attached to it
+func (c *Client) Revoke(lease int64) error {
+	err := c.post("/kv/lease/revoke", &leaseRequest{ID: int64Str(lease)}, &struct{}{})
+	if err == ErrLeaseNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// Event types
+const (
+	EventPut    = "PUT"
+	EventDelete = "DELETE"
+)
+
+// Event is a change to a key
+type Event struct {
+	Type   string
+	Kv     *KeyValue
+	PrevKv *KeyValue // value before the change, nil for new keys
+}
+
+// WatchResponse is a batch of events at one revision, or a watch error
+type WatchResponse struct {
+	Revision int64
+	Events   []*Event
+	Err      error
+}
+
+// Watch watches a key, or all keys starting with it when prefix is set,
+// from startRev on. A zero startRev watches from the current revision.
+// The watch is resumed after the last event received when the connection is
+// lost. The channel is closed when ctx is canceled, or after ErrCompacted is
+// sent when the revision to resume from was compacted. Callers then read
+// the keys again and watch from the revision they were read at.
+func (c *Client) Watch(ctx context.Context, key string, prefix bool, startRev int64) <-chan WatchResponse {
+	respCh := make(chan WatchResponse, 1)
+
+	createReq := watchCreateRequest{Key: []byte(key), PrevKv: true}
+	if prefix {
+		createReq.RangeEnd = prefixRangeEnd(key)
+	}
+
+	go func() {
+		defer close(respCh)
+
+		nextRev := startRev
+		for {
+			createReq.StartRevision = int64Str(nextRev)
+			err := c.watchStream(ctx, &createReq, func(result *watchResult) bool {
+				if result.CompactRevision != 0 {
+					return false
+				}
+
+				// a watch from the current revision is pinned to it once
+				// created, so that it resumes from there
+				if result.Created && nextRev == 0 {
+					nextRev = int64(result.Header.Revision) + 1
+				}
+				if len(result.Events) == 0 {
+					return true
+				}
+
+				// the header revision can be ahead of the events, resume
+				// right after the last one
+				resp := WatchResponse{Revision: int64(result.Header.Revision)}
+				for _, ev := range result.Events {
+					event := ev.event()
+					resp.Events = append(resp.Events, event)
+					if event.Kv != nil && event.Kv.ModRevision >= nextRev {
+						nextRev = event.Kv.ModRevision + 1
+					}
+				}
+
+				select {
+				case respCh <- resp:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+
+			if ctx.Err() != nil {
+				return
+			}
+			if err == ErrCompacted {
+				log.Warnf("etcd watch on %s lost revision %d to a compaction", key, nextRev)
+				select {
+				case respCh <- WatchResponse{Err: ErrCompacted}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			log.Warnf("etcd watch on %s stopped, resuming at revision %d. Err: %v", key, nextRev, err)
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return respCh
+}
+
+// watchStream runs a watch until ctx is canceled, the stream ends or the
+// handler returns false
+func (c *Client) watchStream(ctx context.Context, createReq *watchCreateRequest, handler func(*watchResult) bool) error {
+	body, err := json.Marshal(&watchRequest{CreateRequest: createReq})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.endpoint()+c.apiPrefix+"/watch", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.nextEndpoint()
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Result *watchResult `json:"result"`
+			Error  *gwError     `json:"error"`
+		}
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return errors.New("watch stream closed")
+			}
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error.err()
+		}
+		if msg.Result == nil {
+			continue
+		}
+		if msg.Result.Canceled && msg.Result.CompactRevision == 0 {
+			return errors.New("watch canceled by the server")
+		}
+		if !handler(msg.Result) {
+			if msg.Result.CompactRevision != 0 {
+				return ErrCompacted
+			}
+			return nil
+		}
+	}
+}
+
+// errNotFound is returned when the api version is not served
+var errNotFound = errors.New("not found")
+
+// post sends a request to the json gateway, trying all endpoints until one
+// of them responds
+func (c *Client) post(path string, reqBody, respBody interface{}) error {
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(c.endpoints); i++ {
+		endpoint := c.endpoint()
+
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		req, err := http.NewRequest("POST", endpoint+c.apiPrefix+path, bytes.NewReader(body))
+		if err != nil {
+			cancel()
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.httpClient.Do(req.WithContext(ctx))
+		if err != nil {
+			cancel()
+			log.Warnf("Error connecting to etcd %s. Err: %v", endpoint, err)
+			c.nextEndpoint()
+			continue
+		}
+
+		err = decodeResponse(resp, respBody)
+		resp.Body.Close()
+		cancel()
+
+		return err
+	}
+
+	return ErrClusterUnavailable
+}
+
+// decodeResponse decodes a response body, or the error it carries
+func decodeResponse(resp *http.Response, respBody interface{}) error {
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(respBody)
+}
+
+// responseError converts an error response of the gateway. Paths of api
+// versions the server doesnt serve return a plain text 404.
+func responseError(resp *http.Response) error {
+	var gwErr gwError
+	if err := json.NewDecoder(resp.Body).Decode(&gwErr); err != nil {
+		if resp.StatusCode == http.StatusNotFound {
+			return errNotFound
+		}
+		return fmt.Errorf("etcd returned %s", resp.Status)
+	}
+
+	return gwErr.err()
+}
+
+// endpoint returns the endpoint in use
+func (c *Client) endpoint() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.endpoints[c.current]
+}
+
+// nextEndpoint fails over to the next endpoint
+func (c *Client) nextEndpoint() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.current = (c.current + 1) % len(c.endpoints)
+}
+
+// prefixRangeEnd returns the end of the range of keys starting with prefix
+func prefixRangeEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+
+	// all keys
+	return []byte{0}
+}
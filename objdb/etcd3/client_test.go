@@ -0,0 +1,524 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package etcd3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// fakeGateway is an in-memory etcd serving the v3beta json gateway
+type fakeGateway struct {
+	mutex    sync.Mutex
+	revision int64
+	kvs      map[string]*wireKeyValue
+	leases   map[int64]bool
+	history  []*wireEvent
+	changed  *sync.Cond
+
+	compactRev int64 // revisions up to this one are compacted
+	maxEvents  int   // max events per watch message, 0 for no limit
+	dropWatch  int   // messages after which the next watch stream is closed
+	watches    int   // watch streams created
+}
+
+func newFakeGateway() *fakeGateway {
+	gw := &fakeGateway{
+		revision: 1,
+		kvs:      make(map[string]*wireKeyValue),
+		leases:   make(map[int64]bool),
+	}
+	gw.changed = sync.NewCond(&gw.mutex)
+
+	return gw
+}
+
+func (gw *fakeGateway) header() responseHeader {
+	return responseHeader{Revision: int64Str(gw.revision)}
+}
+
+func inRange(key string, start, end []byte) bool {
+	if len(end) == 0 {
+		return key == string(start)
+	}
+	return key >= string(start) && (bytes.Equal(end, []byte{0}) || key < string(end))
+}
+
+func (gw *fakeGateway) rangeKvs(req *rangeRequest) []*wireKeyValue {
+	var keys []string
+	for key := range gw.kvs {
+		if inRange(key, req.Key, req.RangeEnd) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var kvs []*wireKeyValue
+	for _, key := range keys {
+		kvs = append(kvs, gw.kvs[key])
+	}
+	return kvs
+}
+
+func (gw *fakeGateway) put(req *putRequest) {
+	gw.revision++
+	kv := &wireKeyValue{Key: req.Key, Value: req.Value, Lease: req.Lease,
+		CreateRevision: int64Str(gw.revision), ModRevision: int64Str(gw.revision), Version: 1}
+	prev := gw.kvs[string(req.Key)]
+	if prev != nil {
+		kv.CreateRevision = prev.CreateRevision
+		kv.Version = prev.Version + 1
+	}
+	gw.kvs[string(req.Key)] = kv
+	gw.history = append(gw.history, &wireEvent{Type: "", Kv: kv, PrevKv: prev})
+	gw.changed.Broadcast()
+}
+
+func (gw *fakeGateway) del(key string) *wireKeyValue {
+	prev := gw.kvs[key]
+	if prev == nil {
+		return nil
+	}
+	gw.revision++
+	delete(gw.kvs, key)
+	gw.history = append(gw.history, &wireEvent{Type: EventDelete,
+		Kv: &wireKeyValue{Key: prev.Key, ModRevision: int64Str(gw.revision)}, PrevKv: prev})
+	gw.changed.Broadcast()
+	return prev
+}
+
+func (gw *fakeGateway) compare(cmp *wireCompare) bool {
+	kv := gw.kvs[string(cmp.Key)]
+	switch cmp.Target {
+	case CompareCreate:
+		if kv == nil {
+			return *cmp.CreateRevision == 0
+		}
+		return kv.CreateRevision == *cmp.CreateRevision
+	case CompareValue:
+		return kv != nil && bytes.Equal(kv.Value, cmp.Value)
+	}
+	return false
+}
+
+func (gw *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/v3beta/") {
+		http.NotFound(w, r)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v3beta")
+
+	if path == "/watch" {
+		gw.serveWatch(w, r)
+		return
+	}
+
+	gw.mutex.Lock()
+	defer gw.mutex.Unlock()
+
+	var resp interface{}
+	decoder := json.NewDecoder(r.Body)
+	switch path {
+	case "/kv/range":
+		var req rangeRequest
+		decoder.Decode(&req)
+		resp = &rangeResponse{Header: gw.header(), Kvs: gw.rangeKvs(&req)}
+	case "/kv/put":
+		var req putRequest
+		decoder.Decode(&req)
+		if req.Lease != 0 && !gw.leases[int64(req.Lease)] {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(&gwError{Error: "etcdserver: requested lease not found", Code: 5})
+			return
+		}
+		gw.put(&req)
+		resp = &putResponse{Header: gw.header()}
+	case "/kv/deleterange":
+		var req deleteRangeRequest
+		decoder.Decode(&req)
+		delResp := &deleteRangeResponse{}
+		if prev := gw.del(string(req.Key)); prev != nil {
+			delResp.PrevKvs = []*wireKeyValue{prev}
+		}
+		delResp.Header = gw.header()
+		resp = delResp
+	case "/kv/txn":
+		var req txnRequest
+		decoder.Decode(&req)
+		txnResp := &txnResponse{Succeeded: true}
+		for _, cmp := range req.Compare {
+			txnResp.Succeeded = txnResp.Succeeded && gw.compare(cmp)
+		}
+		ops := req.Success
+		if !txnResp.Succeeded {
+			ops = req.Failure
+		}
+		for _, op := range ops {
+			opResp := &responseOp{}
+			switch {
+			case op.RequestPut != nil:
+				gw.put(op.RequestPut)
+			case op.RequestDeleteRange != nil:
+				gw.del(string(op.RequestDeleteRange.Key))
+			case op.RequestRange != nil:
+				opResp.ResponseRange = &rangeResponse{Kvs: gw.rangeKvs(op.RequestRange)}
+			}
+			txnResp.Responses = append(txnResp.Responses, opResp)
+		}
+		txnResp.Header = gw.header()
+		resp = txnResp
+	case "/lease/grant":
+		var req leaseRequest
+		decoder.Decode(&req)
+		id := int64(len(gw.leases) + 100)
+		gw.leases[id] = true
+		resp = &leaseGrantResponse{ID: int64Str(id), TTL: req.TTL}
+	case "/lease/keepalive":
+		var req leaseRequest
+		decoder.Decode(&req)
+		result := leaseRequest{ID: req.ID}
+		if gw.leases[int64(req.ID)] {
+			result.TTL = 10
+		}
+		resp = &struct {
+			Result leaseRequest `json:"result"`
+		}{result}
+	case "/kv/lease/revoke":
+		var req leaseRequest
+		decoder.Decode(&req)
+		delete(gw.leases, int64(req.ID))
+		for key, kv := range gw.kvs {
+			if kv.Lease == req.ID {
+				gw.del(key)
+			}
+		}
+		resp = &struct{}{}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveWatch streams the events from the start revision on
+func (gw *fakeGateway) serveWatch(w http.ResponseWriter, r *http.Request) {
+	var req watchRequest
+	json.NewDecoder(r.Body).Decode(&req)
+	createReq := req.CreateRequest
+
+	encoder := json.NewEncoder(w)
+	send := func(result *watchResult) error {
+		err := encoder.Encode(&struct {
+			Result *watchResult `json:"result"`
+		}{result})
+		w.(http.Flusher).Flush()
+		return err
+	}
+
+	gw.mutex.Lock()
+	nextRev := int64(createReq.StartRevision)
+	if nextRev != 0 && nextRev <= gw.compactRev {
+		gw.mutex.Unlock()
+		send(&watchResult{Canceled: true, CompactRevision: int64Str(gw.compactRev)})
+		return
+	}
+	if nextRev == 0 {
+		nextRev = gw.revision + 1
+	}
+	created := &watchResult{Header: gw.header(), Created: true}
+	drop := gw.dropWatch
+	gw.dropWatch = 0
+	gw.watches++
+	gw.mutex.Unlock()
+
+	if err := send(created); err != nil {
+		return
+	}
+	for sent := 1; drop == 0 || sent < drop; sent++ {
+		gw.mutex.Lock()
+		for gw.revision < nextRev {
+			gw.changed.Wait()
+		}
+
+		result := &watchResult{Header: gw.header()}
+		for _, ev := range gw.history {
+			if int64(ev.Kv.ModRevision) >= nextRev && inRange(string(ev.Kv.Key), createReq.Key, createReq.RangeEnd) {
+				result.Events = append(result.Events, ev)
+			}
+		}
+		nextRev = gw.revision + 1
+		if gw.maxEvents != 0 && len(result.Events) > gw.maxEvents {
+			result.Events = result.Events[:gw.maxEvents]
+			nextRev = int64(result.Events[gw.maxEvents-1].Kv.ModRevision) + 1
+		}
+		gw.mutex.Unlock()
+
+		if len(result.Events) == 0 {
+			sent--
+			continue
+		}
+		if err := send(result); err != nil {
+			return
+		}
+	}
+}
+
+func newTestClient(t *testing.T) (*Client, *fakeGateway) {
+	gw := newFakeGateway()
+	server := httptest.NewServer(gw)
+
+	client, err := New([]string{strings.Replace(server.URL, "http://", "etcd3://", 1)})
+	if err != nil {
+		t.Fatalf("Error creating client. Err: %v", err)
+	}
+	if client.apiPrefix != "/v3beta" {
+		t.Fatalf("Client uses api %s, expected /v3beta", client.apiPrefix)
+	}
+
+	return client, gw
+}
+
+func TestPrefixRangeEnd(t *testing.T) {
+	testData := map[string][]byte{
+		"/contiv.io/": []byte("/contiv.io0"),
+		"a\xff":       []byte("b"),
+		"\xff\xff":    {0},
+	}
+	for prefix, expEnd := range testData {
+		if end := prefixRangeEnd(prefix); !bytes.Equal(end, expEnd) {
+			t.Fatalf("Range end of %q is %q, expected %q", prefix, end, expEnd)
+		}
+	}
+}
+
+func TestKeyValues(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	if _, _, err := client.Get("/contiv.io/state/nets/net1"); err != ErrKeyNotFound {
+		t.Fatalf("Get of a missing key returned %v", err)
+	}
+
+	for _, key := range []string{"/contiv.io/state/nets/net2", "/contiv.io/state/nets/net1", "/contiv.io/state/eps/ep1"} {
+		if err := client.Put(key, "value of "+key, 0); err != nil {
+			t.Fatalf("Error writing key %s. Err: %v", key, err)
+		}
+	}
+
+	kv, rev, err := client.Get("/contiv.io/state/nets/net1")
+	if err != nil || kv.Value != "value of /contiv.io/state/nets/net1" || rev != 4 {
+		t.Fatalf("Get returned %+v at revision %d. Err: %v", kv, rev, err)
+	}
+
+	kvs, _, err := client.GetPrefix("/contiv.io/state/nets/")
+	if err != nil || len(kvs) != 2 || kvs[0].Key != "/contiv.io/state/nets/net1" || kvs[1].Key != "/contiv.io/state/nets/net2" {
+		t.Fatalf("GetPrefix returned %+v. Err: %v", kvs, err)
+	}
+
+	prev, err := client.Delete("/contiv.io/state/nets/net1")
+	if err != nil || prev.Value != "value of /contiv.io/state/nets/net1" {
+		t.Fatalf("Delete returned %+v. Err: %v", prev, err)
+	}
+	if _, err := client.Delete("/contiv.io/state/nets/net1"); err != ErrKeyNotFound {
+		t.Fatalf("Delete of a missing key returned %v", err)
+	}
+}
+
+func TestTxnAndLeases(t *testing.T) {
+	client, _ := newTestClient(t)
+	key := "/contiv.io/lock/netmaster"
+
+	lease, err := client.Grant(30)
+	if err != nil {
+		t.Fatalf("Error creating lease. Err: %v", err)
+	}
+
+	createKey := func(value string) *TxnResponse {
+		resp, err := client.Txn(
+			[]Compare{{Key: key, Target: CompareCreate, CreateRevision: 0}},
+			[]Op{{Type: OpPut, Key: key, Value: value, Lease: lease}},
+			[]Op{{Type: OpGet, Key: key}})
+		if err != nil {
+			t.Fatalf("Error running txn. Err: %v", err)
+		}
+		return resp
+	}
+
+	if resp := createKey("holder1"); !resp.Succeeded {
+		t.Fatalf("Creating key failed: %+v", resp)
+	}
+	resp := createKey("holder2")
+	if resp.Succeeded || len(resp.Kvs) != 1 || resp.Kvs[0][0].Value != "holder1" {
+		t.Fatalf("Creating existing key returned %+v", resp)
+	}
+
+	if err := client.KeepAliveOnce(lease); err != nil {
+		t.Fatalf("Error renewing lease. Err: %v", err)
+	}
+
+	// revoking the lease removes the key
+	if err := client.Revoke(lease); err != nil {
+		t.Fatalf("Error revoking lease. Err: %v", err)
+	}
+	if _, _, err := client.Get(key); err != ErrKeyNotFound {
+		t.Fatalf("Key with revoked lease still exists. Err: %v", err)
+	}
+	if err := client.KeepAliveOnce(lease); err != ErrLeaseNotFound {
+		t.Fatalf("Renewing revoked lease returned %v", err)
+	}
+	if err := client.Put(key, "holder1", lease); err != ErrLeaseNotFound {
+		t.Fatalf("Put with revoked lease returned %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	client.Put("/contiv.io/state/nets/net1", "v1", 0)
+	_, rev, _ := client.Get("/")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchCh := client.Watch(ctx, "/contiv.io/state/nets/", true, rev+1)
+
+	client.Put("/contiv.io/state/eps/ep1", "ep", 0)
+	client.Put("/contiv.io/state/nets/net1", "v2", 0)
+	client.Delete("/contiv.io/state/nets/net1")
+
+	var events []*Event
+	for len(events) < 2 {
+		select {
+		case resp := <-watchCh:
+			if resp.Err != nil {
+				t.Fatalf("Watch failed. Err: %v", resp.Err)
+			}
+			events = append(events, resp.Events...)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for watch events, got %+v", events)
+		}
+	}
+
+	if events[0].Type != EventPut || events[0].Kv.Value != "v2" || events[0].PrevKv.Value != "v1" {
+		t.Fatalf("Unexpected modify event %+v", events[0])
+	}
+	if events[1].Type != EventDelete || events[1].PrevKv.Value != "v2" {
+		t.Fatalf("Unexpected delete event %+v", events[1])
+	}
+}
+
+// recvEvents receives count events from a watch
+func recvEvents(t *testing.T, watchCh <-chan WatchResponse, count int) []*Event {
+	var events []*Event
+	for len(events) < count {
+		select {
+		case resp, ok := <-watchCh:
+			if !ok || resp.Err != nil {
+				t.Fatalf("Watch failed. Err: %v", resp.Err)
+			}
+			events = append(events, resp.Events...)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for watch events, got %+v", events)
+		}
+	}
+
+	return events
+}
+
+func TestWatchResume(t *testing.T) {
+	client, gw := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the header revision is ahead of the events sent before the stream
+	// is lost, the watch resumes after the last event
+	gw.mutex.Lock()
+	gw.maxEvents = 1
+	gw.dropWatch = 2
+	gw.mutex.Unlock()
+
+	_, rev, _ := client.Get("/")
+	client.Put("/contiv.io/state/nets/net1", "v1", 0)
+	client.Put("/contiv.io/state/nets/net2", "v1", 0)
+	client.Put("/contiv.io/state/nets/net3", "v1", 0)
+	watchCh := client.Watch(ctx, "/contiv.io/state/nets/", true, rev+1)
+
+	events := recvEvents(t, watchCh, 3)
+	for i, ev := range events {
+		if key := fmt.Sprintf("/contiv.io/state/nets/net%d", i+1); ev.Kv.Key != key {
+			t.Fatalf("Event %d is for %s, expected %s", i, ev.Kv.Key, key)
+		}
+	}
+}
+
+func TestWatchResumeCurrent(t *testing.T) {
+	client, gw := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the stream is lost right after the watch is created
+	gw.mutex.Lock()
+	gw.dropWatch = 1
+	gw.mutex.Unlock()
+
+	watchCh := client.Watch(ctx, "/contiv.io/state/nets/", true, 0)
+	for created := false; !created; time.Sleep(10 * time.Millisecond) {
+		gw.mutex.Lock()
+		created = gw.watches != 0
+		gw.mutex.Unlock()
+	}
+
+	// changes made before the watch is resumed are not missed
+	client.Put("/contiv.io/state/nets/net1", "v1", 0)
+	events := recvEvents(t, watchCh, 1)
+	if events[0].Kv.Key != "/contiv.io/state/nets/net1" {
+		t.Fatalf("Unexpected event %+v", events[0])
+	}
+}
+
+func TestWatchCompacted(t *testing.T) {
+	client, gw := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client.Put("/contiv.io/state/nets/net1", "v1", 0)
+	gw.mutex.Lock()
+	gw.compactRev = gw.revision
+	gw.mutex.Unlock()
+
+	watchCh := client.Watch(ctx, "/contiv.io/state/nets/", true, 1)
+	select {
+	case resp := <-watchCh:
+		if resp.Err != ErrCompacted {
+			t.Fatalf("Watch of a compacted revision returned %+v", resp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the compaction error")
+	}
+
+	if _, ok := <-watchCh; ok {
+		t.Fatalf("Watch channel not closed after the compaction error")
+	}
+}
@@ -0,0 +1,257 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package etcd3
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// json encoding of the etcd v3 messages. The gateway encodes bytes fields
+// as base64 and int64 fields as strings, and leaves out fields with
+// default values.
+
+// int64Str is an int64 encoded as a json string
+type int64Str int64
+
+func (i int64Str) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(strconv.FormatInt(int64(i), 10))), nil
+}
+
+func (i *int64Str) UnmarshalJSON(data []byte) error {
+	str := strings.Trim(string(data), "\"")
+	val, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	*i = int64Str(val)
+	return nil
+}
+
+type responseHeader struct {
+	Revision int64Str `json:"revision"`
+}
+
+type wireKeyValue struct {
+	Key            []byte   `json:"key"`
+	Value          []byte   `json:"value,omitempty"`
+	CreateRevision int64Str `json:"create_revision,omitempty"`
+	ModRevision    int64Str `json:"mod_revision,omitempty"`
+	Version        int64Str `json:"version,omitempty"`
+	Lease          int64Str `json:"lease,omitempty"`
+}
+
+func (kv *wireKeyValue) keyValue() *KeyValue {
+	return &KeyValue{
+		Key:            string(kv.Key),
+		Value:          string(kv.Value),
+		CreateRevision: int64(kv.CreateRevision),
+		ModRevision:    int64(kv.ModRevision),
+		Version:        int64(kv.Version),
+		Lease:          int64(kv.Lease),
+	}
+}
+
+func keyValues(kvs []*wireKeyValue) []*KeyValue {
+	var list []*KeyValue
+	for _, kv := range kvs {
+		list = append(list, kv.keyValue())
+	}
+
+	return list
+}
+
+type rangeRequest struct {
+	Key        []byte `json:"key"`
+	RangeEnd   []byte `json:"range_end,omitempty"`
+	SortOrder  string `json:"sort_order,omitempty"`
+	SortTarget string `json:"sort_target,omitempty"`
+}
+
+func newPrefixRange(prefix string) *rangeRequest {
+	return &rangeRequest{
+		Key:        []byte(prefix),
+		RangeEnd:   prefixRangeEnd(prefix),
+		SortOrder:  "ASCEND",
+		SortTarget: "KEY",
+	}
+}
+
+type rangeResponse struct {
+	Header responseHeader  `json:"header"`
+	Kvs    []*wireKeyValue `json:"kvs"`
+}
+
+type putRequest struct {
+	Key   []byte   `json:"key"`
+	Value []byte   `json:"value"`
+	Lease int64Str `json:"lease,omitempty"`
+}
+
+type putResponse struct {
+	Header responseHeader `json:"header"`
+}
+
+type deleteRangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+	PrevKv   bool   `json:"prev_kv,omitempty"`
+}
+
+type deleteRangeResponse struct {
+	Header  responseHeader  `json:"header"`
+	PrevKvs []*wireKeyValue `json:"prev_kvs"`
+}
+
+type wireCompare struct {
+	Result         string    `json:"result"`
+	Target         string    `json:"target"`
+	Key            []byte    `json:"key"`
+	Version        *int64Str `json:"version,omitempty"`
+	CreateRevision *int64Str `json:"create_revision,omitempty"`
+	ModRevision    *int64Str `json:"mod_revision,omitempty"`
+	Value          []byte    `json:"value,omitempty"`
+}
+
+func newCompare(cmp Compare) *wireCompare {
+	wc := &wireCompare{Result: "EQUAL", Target: cmp.Target, Key: []byte(cmp.Key)}
+
+	switch cmp.Target {
+	case CompareVersion:
+		val := int64Str(cmp.Version)
+		wc.Version = &val
+	case CompareCreate:
+		val := int64Str(cmp.CreateRevision)
+		wc.CreateRevision = &val
+	case CompareMod:
+		val := int64Str(cmp.ModRevision)
+		wc.ModRevision = &val
+	case CompareValue:
+		wc.Value = []byte(cmp.Value)
+	}
+
+	return wc
+}
+
+type requestOp struct {
+	RequestRange       *rangeRequest       `json:"request_range,omitempty"`
+	RequestPut         *putRequest         `json:"request_put,omitempty"`
+	RequestDeleteRange *deleteRangeRequest `json:"request_delete_range,omitempty"`
+}
+
+func newRequestOp(op Op) *requestOp {
+	switch op.Type {
+	case OpPut:
+		return &requestOp{RequestPut: &putRequest{Key: []byte(op.Key), Value: []byte(op.Value), Lease: int64Str(op.Lease)}}
+	case OpDelete:
+		return &requestOp{RequestDeleteRange: &deleteRangeRequest{Key: []byte(op.Key)}}
+	default:
+		return &requestOp{RequestRange: &rangeRequest{Key: []byte(op.Key)}}
+	}
+}
+
+type responseOp struct {
+	ResponseRange *rangeResponse `json:"response_range"`
+}
+
+type txnRequest struct {
+	Compare []*wireCompare `json:"compare,omitempty"`
+	Success []*requestOp   `json:"success,omitempty"`
+	Failure []*requestOp   `json:"failure,omitempty"`
+}
+
+type txnResponse struct {
+	Header    responseHeader `json:"header"`
+	Succeeded bool           `json:"succeeded"`
+	Responses []*responseOp  `json:"responses"`
+}
+
+type leaseRequest struct {
+	ID  int64Str `json:"ID,omitempty"`
+	TTL int64Str `json:"TTL,omitempty"`
+}
+
+type leaseGrantResponse struct {
+	ID    int64Str `json:"ID"`
+	TTL   int64Str `json:"TTL"`
+	Error string   `json:"error"`
+}
+
+type watchCreateRequest struct {
+	Key           []byte   `json:"key"`
+	RangeEnd      []byte   `json:"range_end,omitempty"`
+	StartRevision int64Str `json:"start_revision,omitempty"`
+	PrevKv        bool     `json:"prev_kv,omitempty"`
+}
+
+type watchRequest struct {
+	CreateRequest *watchCreateRequest `json:"create_request"`
+}
+
+type wireEvent struct {
+	Type   string        `json:"type"`
+	Kv     *wireKeyValue `json:"kv"`
+	PrevKv *wireKeyValue `json:"prev_kv"`
+}
+
+func (ev *wireEvent) event() *Event {
+	// put is the default event type, and is left out
+	event := &Event{Type: EventPut}
+	if ev.Type == EventDelete {
+		event.Type = EventDelete
+	}
+	if ev.Kv != nil {
+		event.Kv = ev.Kv.keyValue()
+	}
+	if ev.PrevKv != nil {
+		event.PrevKv = ev.PrevKv.keyValue()
+	}
+
+	return event
+}
+
+type watchResult struct {
+	Header          responseHeader `json:"header"`
+	Created         bool           `json:"created"`
+	Canceled        bool           `json:"canceled"`
+	CompactRevision int64Str       `json:"compact_revision"`
+	Events          []*wireEvent   `json:"events"`
+}
+
+// gwError is an error returned by the gateway
+type gwError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+func (e *gwError) err() error {
+	msg := e.Error
+	if msg == "" {
+		msg = e.Message
+	}
+
+	switch {
+	case strings.Contains(msg, "lease not found"):
+		return ErrLeaseNotFound
+	case strings.Contains(msg, "has been compacted"):
+		return ErrCompacted
+	}
+
+	return errors.New(msg)
+}
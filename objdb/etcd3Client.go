@@ -0,0 +1,174 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objdb
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/objdb/etcd3"
+)
+
+type etcd3Plugin struct {
+	mutex *sync.Mutex
+}
+
+// Etcd3Client has etcd v3 client state
+type Etcd3Client struct {
+	client *etcd3.Client
+
+	serviceDb map[string]*etcd3ServiceState
+	mutex     sync.Mutex
+}
+
+// Register the plugin
+func init() {
+	RegisterPlugin("etcd3", &etcd3Plugin{mutex: new(sync.Mutex)})
+}
+
+// Initialize the etcd v3 client
+func (ep *etcd3Plugin) NewClient(endpoints []string) (API, error) {
+	var err error
+	var ec = new(Etcd3Client)
+
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	// Create a new client, this makes sure we can read from etcd
+	ec.client, err = etcd3.New(endpoints)
+	if err != nil {
+		log.Errorf("Failed to connect to etcd. Err: %v", err)
+		return nil, err
+	}
+
+	// Initialize service DB
+	ec.serviceDb = make(map[string]*etcd3ServiceState)
+
+	return ec, nil
+}
+
+// GetObj Get an object
+func (ep *Etcd3Client) GetObj(key string, retVal interface{}) error {
+	keyName := "/contiv.io/obj/" + key
+
+	var kv *etcd3.KeyValue
+	var err error
+	for i := 0; i < maxEtcdRetries; i++ {
+		kv, _, err = ep.client.Get(keyName)
+		if err != etcd3.ErrClusterUnavailable {
+			break
+		}
+
+		// Retry after a delay
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		log.Errorf("Error getting key %s. Err: %v", keyName, err)
+		return err
+	}
+
+	// Parse JSON response
+	if err := json.Unmarshal([]byte(kv.Value), retVal); err != nil {
+		log.Errorf("Error parsing object %s, Err %v", kv.Value, err)
+		return err
+	}
+
+	return nil
+}
+
+// ListDir Get a list of objects in a directory
+func (ep *Etcd3Client) ListDir(key string) ([]string, error) {
+	keyName := "/contiv.io/obj/" + key
+	if !strings.HasSuffix(keyName, "/") {
+		keyName += "/"
+	}
+
+	var kvs []*etcd3.KeyValue
+	var err error
+	for i := 0; i < maxEtcdRetries; i++ {
+		kvs, _, err = ep.client.GetPrefix(keyName)
+		if err != etcd3.ErrClusterUnavailable {
+			break
+		}
+
+		// Retry after a delay
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// keys are flat in v3, return all values under the directory
+	var retList []string
+	for _, kv := range kvs {
+		retList = append(retList, kv.Value)
+	}
+
+	return retList, nil
+}
+
+// SetObj Save an object, create if it doesnt exist
+func (ep *Etcd3Client) SetObj(key string, value interface{}) error {
+	keyName := "/contiv.io/obj/" + key
+
+	// JSON format the object
+	jsonVal, err := json.Marshal(value)
+	if err != nil {
+		log.Errorf("Json conversion error. Err %v", err)
+		return err
+	}
+
+	for i := 0; i < maxEtcdRetries; i++ {
+		err = ep.client.Put(keyName, string(jsonVal[:]), 0)
+		if err != etcd3.ErrClusterUnavailable {
+			break
+		}
+
+		// Retry after a delay
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		log.Errorf("Error setting key %s, Err: %v", keyName, err)
+		return err
+	}
+
+	return nil
+}
+
+// DelObj Remove an object
+func (ep *Etcd3Client) DelObj(key string) error {
+	keyName := "/contiv.io/obj/" + key
+
+	var err error
+	for i := 0; i < maxEtcdRetries; i++ {
+		_, err = ep.client.Delete(keyName)
+		if err != etcd3.ErrClusterUnavailable {
+			break
+		}
+
+		// Retry after a delay
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		log.Errorf("Error removing key %s, Err: %v", keyName, err)
+		return err
+	}
+
+	return nil
+}
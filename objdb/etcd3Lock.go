@@ -0,0 +1,401 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objdb
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/objdb/etcd3"
+)
+
+// Lock object. The lock key is attached to a lease, so that it goes away
+// when the holder stops refreshing the lease.
+type etcd3Lock struct {
+	name        string
+	keyName     string
+	myID        string
+	isAcquired  bool
+	isReleased  bool
+	holderID    string
+	ttl         time.Duration
+	timeout     uint64
+	lease       int64 // lease of the lock key, while acquired
+	eventChan   chan LockEvent
+	stopChan    chan bool
+	client      *etcd3.Client
+	watchCtx    context.Context
+	watchCancel context.CancelFunc
+	mutex       *sync.Mutex
+}
+
+// NewLock Create a new lock
+func (ep *Etcd3Client) NewLock(name string, myID string, ttl uint64) (LockInterface, error) {
+	watchCtx, watchCancel := context.WithCancel(context.Background())
+	// Create a lock
+	return &etcd3Lock{
+		name:        name,
+		keyName:     "/contiv.io/lock/" + name,
+		myID:        myID,
+		ttl:         time.Duration(ttl) * time.Second,
+		client:      ep.client,
+		eventChan:   make(chan LockEvent, 1),
+		stopChan:    make(chan bool, 1),
+		watchCtx:    watchCtx,
+		watchCancel: watchCancel,
+		mutex:       new(sync.Mutex),
+	}, nil
+}
+
+// Acquire a lock
+func (lk *etcd3Lock) Acquire(timeout uint64) error {
+	lk.mutex.Lock()
+	defer lk.mutex.Unlock()
+	lk.timeout = timeout
+
+	// Acquire in background
+	go lk.acquireLock()
+
+	return nil
+}
+
+// Release a lock
+func (lk *etcd3Lock) Release() error {
+	lk.mutex.Lock()
+	defer lk.mutex.Unlock()
+
+	// Mark this as released
+	lk.isReleased = true
+
+	// Send stop signal on stop channel
+	lk.stopChan <- true
+
+	// If the lock was acquired, release it. Revoking the lease deletes the key
+	if lk.isAcquired {
+		if err := lk.client.Revoke(lk.lease); err != nil {
+			log.Errorf("Error revoking lease of lock %s. Err: %v", lk.keyName, err)
+		} else {
+			log.Infof("Released lock %s", lk.keyName)
+		}
+
+		lk.isAcquired = false
+	}
+
+	return nil
+}
+
+// Kill Stops a lock without releasing it.
+// Let the lease expiry release it
+// Note: This is for debug/test purposes only
+func (lk *etcd3Lock) Kill() error {
+	lk.mutex.Lock()
+	defer lk.mutex.Unlock()
+	// Mark this as released
+	lk.isReleased = true
+
+	// Send stop signal on stop channel
+	lk.stopChan <- true
+
+	return nil
+}
+
+// EventChan Returns event channel
+func (lk *etcd3Lock) EventChan() <-chan LockEvent {
+	lk.mutex.Lock()
+	defer lk.mutex.Unlock()
+	return lk.eventChan
+}
+
+// IsAcquired Checks if the lock is acquired
+func (lk *etcd3Lock) IsAcquired() bool {
+	lk.mutex.Lock()
+	defer lk.mutex.Unlock()
+	return lk.isAcquired
+}
+
+// GetHolder Gets current lock holder's ID
+func (lk *etcd3Lock) GetHolder() string {
+	lk.mutex.Lock()
+	defer lk.mutex.Unlock()
+
+	kv, _, err := lk.client.Get(lk.keyName)
+	if err != nil {
+		log.Warnf("Could not get current holder for lock %s", lk.name)
+		return ""
+	}
+
+	return kv.Value
+}
+
+// *********************** Internal functions *************
+// Try acquiring a lock.
+// This assumes its called in its own go routine
+func (lk *etcd3Lock) acquireLock() {
+	defer lk.watchCancel()
+
+	// Wait in this loop forever till lock times out or released
+	for {
+		acquired, holder, rev, err := lk.tryAcquire()
+		if err != nil {
+			log.Errorf("Error acquiring lock %s. Err: %v", lk.keyName, err)
+			// Retry after a second in case of error
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-lk.stopChan:
+				return
+			}
+		}
+
+		if acquired {
+			lk.mutex.Lock()
+			// Successfully acquired the lock
+			lk.isAcquired = true
+			lk.holderID = lk.myID
+			lk.mutex.Unlock()
+
+			// Send acquired message to event channel
+			lk.eventChan <- LockEvent{EventType: LockAcquired}
+
+			// refresh it
+			lk.refreshLock(rev)
+		} else {
+			log.Debugf("Lock %s already acquired by %s", lk.keyName, holder)
+
+			lk.mutex.Lock()
+			// Set the current holder's ID
+			lk.holderID = holder
+			lk.mutex.Unlock()
+
+			// Wait for changes on the lock
+			lk.waitForLock(rev)
+		}
+
+		lk.mutex.Lock()
+		// If lock is released, we are done, else go back and try to acquire it
+		if lk.isReleased {
+			lk.mutex.Unlock()
+			return
+		}
+		lk.mutex.Unlock()
+	}
+}
+
+// tryAcquire creates the lock key if it doesnt exist. It returns the current
+// holder and the revision the key was read at.
+func (lk *etcd3Lock) tryAcquire() (bool, string, int64, error) {
+	lease, err := lk.client.Grant(int64(lk.ttl / time.Second))
+	if err != nil {
+		return false, "", 0, err
+	}
+
+	// create the key if it doesnt exist, or take it over if we already hold
+	// it from before a restart
+	for _, cmp := range []etcd3.Compare{
+		{Key: lk.keyName, Target: etcd3.CompareCreate, CreateRevision: 0},
+		{Key: lk.keyName, Target: etcd3.CompareValue, Value: lk.myID},
+	} {
+		resp, err := lk.client.Txn([]etcd3.Compare{cmp},
+			[]etcd3.Op{{Type: etcd3.OpPut, Key: lk.keyName, Value: lk.myID, Lease: lease}},
+			[]etcd3.Op{{Type: etcd3.OpGet, Key: lk.keyName}})
+		if err != nil {
+			lk.client.Revoke(lease)
+			return false, "", 0, err
+		}
+
+		if resp.Succeeded {
+			log.Infof("Acquired lock %s", lk.keyName)
+			lk.mutex.Lock()
+			lk.lease = lease
+			lk.mutex.Unlock()
+			return true, lk.myID, resp.Revision, nil
+		}
+
+		// the key was deleted in between, try creating it again
+		if len(resp.Kvs) == 0 || len(resp.Kvs[0]) == 0 {
+			continue
+		}
+
+		if holder := resp.Kvs[0][0].Value; holder != lk.myID {
+			lk.client.Revoke(lease)
+			return false, holder, resp.Revision, nil
+		}
+	}
+
+	lk.client.Revoke(lease)
+	return false, "", 0, nil
+}
+
+// We couldnt acquire lock, Wait for the holder to release it
+func (lk *etcd3Lock) waitForLock(rev int64) {
+	// If timeout is not specified, set it to high value
+	timeoutIntvl := time.Second * time.Duration(20000)
+	if lk.timeout != 0 {
+		timeoutIntvl = time.Second * time.Duration(lk.timeout)
+	}
+
+	log.Infof("Waiting to acquire lock (%s/%s)", lk.name, lk.myID)
+
+	// Create a timer
+	timer := time.NewTimer(timeoutIntvl)
+	defer timer.Stop()
+
+	watchCtx, watchCancel := context.WithCancel(lk.watchCtx)
+	defer watchCancel()
+	watchCh := lk.client.Watch(watchCtx, lk.keyName, false, rev+1)
+
+	// Wait for changes
+	for {
+		select {
+		case <-timer.C:
+			lk.mutex.Lock()
+			if lk.timeout != 0 {
+				lk.mutex.Unlock()
+				log.Infof("Lock timeout on lock %s/%s", lk.name, lk.myID)
+
+				lk.eventChan <- LockEvent{EventType: LockAcquireTimeout}
+
+				log.Infof("Lock acquire timed out. Stopping lock")
+
+				// Release the lock
+				lk.Release()
+
+				return
+			}
+			lk.mutex.Unlock()
+		case watchResp, ok := <-watchCh:
+			if !ok || watchResp.Err != nil {
+				log.Infof("Watch on lock %s stopped, retrying to acquire lock", lk.keyName)
+				return
+			}
+
+			for _, ev := range watchResp.Events {
+				if ev.Type == etcd3.EventDelete {
+					log.Infof("Retrying to acquire lock")
+					return
+				}
+			}
+		case <-lk.stopChan:
+			log.Infof("Stopping lock")
+			return
+		}
+	}
+}
+
+// Refresh lock
+func (lk *etcd3Lock) refreshLock(rev int64) {
+	// Refresh interval is 1/3rd of TTL
+	refreshIntvl := lk.ttl / 3
+	lastRefresh := time.Now()
+	nextRefresh := refreshIntvl
+
+	watchCtx, watchCancel := context.WithCancel(lk.watchCtx)
+	defer watchCancel()
+	watchCh := lk.client.Watch(watchCtx, lk.keyName, false, rev+1)
+
+	lockLost := func() {
+		lk.mutex.Lock()
+		// releasing the lock deletes the key too
+		if lk.isReleased {
+			lk.mutex.Unlock()
+			return
+		}
+		// We are not master anymore
+		lk.isAcquired = false
+		lk.mutex.Unlock()
+
+		// Send lock lost event
+		lk.eventChan <- LockEvent{EventType: LockLost}
+	}
+
+	// resumeWatch checks we still hold the lock after the watch lost
+	// changes to a compaction, and watches from the revision it was read at
+	resumeWatch := func() bool {
+		kv, rev, err := lk.client.Get(lk.keyName)
+		if err == etcd3.ErrKeyNotFound || (err == nil && kv.Value != lk.myID) {
+			log.Infof("Holder %s lost the lock %s", lk.myID, lk.name)
+			lockLost()
+			return false
+		}
+		if err != nil {
+			// try again on the next refresh
+			log.Errorf("Error reading lock %s. Err: %v", lk.keyName, err)
+			watchCh = nil
+			return true
+		}
+
+		watchCh = lk.client.Watch(watchCtx, lk.keyName, false, rev+1)
+		return true
+	}
+
+	// Loop forever
+	for {
+		select {
+		case <-time.After(nextRefresh):
+			lk.mutex.Lock()
+			lease := lk.lease
+			lk.mutex.Unlock()
+
+			// Renew the lease of the lock. It is only lost once the lease
+			// expired, so keep retrying until then.
+			err := lk.client.KeepAliveOnce(lease)
+			if err != nil {
+				if err == etcd3.ErrLeaseNotFound || time.Since(lastRefresh) >= lk.ttl {
+					log.Errorf("Error refreshing lock %s. Err: %v", lk.keyName, err)
+					lockLost()
+					return
+				}
+
+				log.Warnf("Error refreshing lock %s, retrying. Err: %v", lk.keyName, err)
+				nextRefresh = time.Second
+				if refreshIntvl < nextRefresh {
+					nextRefresh = refreshIntvl
+				}
+				continue
+			}
+
+			lastRefresh = time.Now()
+			nextRefresh = refreshIntvl
+			log.Debugf("Refreshed lease on lock %s", lk.keyName)
+
+			if watchCh == nil && !resumeWatch() {
+				return
+			}
+		case watchResp, ok := <-watchCh:
+			if !ok || watchResp.Err != nil {
+				if !resumeWatch() {
+					return
+				}
+				continue
+			}
+
+			// See if we lost the lock
+			for _, ev := range watchResp.Events {
+				if ev.Type == etcd3.EventDelete || ev.Kv.Value != lk.myID {
+					log.Infof("Holder %s lost the lock %s", lk.myID, lk.name)
+					lockLost()
+					return
+				}
+			}
+		case <-lk.stopChan:
+			log.Infof("Stopping lock")
+			return
+		}
+	}
+}
@@ -0,0 +1,374 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objdb
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/objdb/etcd3"
+)
+
+// Service state
+type etcd3ServiceState struct {
+	ServiceName string        // Name of the service
+	KeyName     string        // Service key name
+	TTL         time.Duration // TTL for the service
+	HostAddr    string        // Host name or IP address where its running
+	Port        int           // Port number where its listening
+	Hostname    string        // Host name where its running
+
+	// Channel to stop lease refresh
+	stopChan chan bool
+}
+
+// RegisterService Register a service
+// Service is registered with a lease of ttl seconds and a goroutine is
+// created to refresh the lease.
+func (ep *Etcd3Client) RegisterService(serviceInfo ServiceInfo) error {
+	keyName := "/contiv.io/service/" + serviceInfo.ServiceName + "/" +
+		serviceInfo.HostAddr + ":" + strconv.Itoa(serviceInfo.Port)
+	ttl := time.Duration(serviceInfo.TTL) * time.Second
+
+	log.Infof("Registering service key: %s, value: %+v", keyName, serviceInfo)
+
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	// if there is a previously registered service, stop refreshing it
+	if ep.serviceDb[keyName] != nil {
+		ep.serviceDb[keyName].stopChan <- true
+	}
+
+	// JSON format the object
+	jsonVal, err := json.Marshal(serviceInfo)
+	if err != nil {
+		log.Errorf("Json conversion error. Err %v", err)
+		return err
+	}
+
+	// create service state
+	srvState := etcd3ServiceState{
+		ServiceName: serviceInfo.ServiceName,
+		KeyName:     keyName,
+		TTL:         ttl,
+		HostAddr:    serviceInfo.HostAddr,
+		Port:        serviceInfo.Port,
+		stopChan:    make(chan bool, 1),
+		Hostname:    serviceInfo.Hostname,
+	}
+
+	// Run refresh in background
+	go ep.refreshService(&srvState, string(jsonVal[:]))
+
+	// Store it in DB
+	ep.serviceDb[keyName] = &srvState
+
+	return nil
+}
+
+// GetService lists all end points for a service
+func (ep *Etcd3Client) GetService(name string) ([]ServiceInfo, error) {
+	keyName := "/contiv.io/service/" + name + "/"
+
+	_, srvcList, err := ep.getServiceState(keyName)
+	return srvcList, err
+}
+
+// getServiceState reads all instances of a service, and the revision they
+// were read at
+func (ep *Etcd3Client) getServiceState(key string) (int64, []ServiceInfo, error) {
+	var srvcList []ServiceInfo
+	retryCount := 0
+
+	kvs, rev, err := ep.client.GetPrefix(key)
+	for err == etcd3.ErrClusterUnavailable {
+		// Retry after a delay
+		retryCount++
+		if retryCount%16 == 0 {
+			log.Warnf("%v -- Retrying...", err)
+		}
+
+		time.Sleep(time.Second)
+		kvs, rev, err = ep.client.GetPrefix(key)
+	}
+	if err != nil {
+		log.Errorf("Error getting key %s. Err: %v", key, err)
+		return 0, nil, err
+	}
+
+	// Parse each instance
+	for _, kv := range kvs {
+		var respSrvc ServiceInfo
+		// Parse JSON response
+		err = json.Unmarshal([]byte(kv.Value), &respSrvc)
+		if err != nil {
+			log.Errorf("Error parsing object %s, Err %v", kv.Value, err)
+			return 0, nil, err
+		}
+
+		srvcList = append(srvcList, respSrvc)
+	}
+
+	return rev, srvcList, nil
+}
+
+// WatchService Watch for a service
+func (ep *Etcd3Client) WatchService(name string, eventCh chan WatchServiceEvent, stopCh chan bool) error {
+	keyName := "/contiv.io/service/" + name + "/"
+
+	// Create watch context
+	watchCtx, watchCancel := context.WithCancel(context.Background())
+
+	go func() {
+		var srvMap = make(map[string]ServiceInfo)
+
+		// Get current state and the revision to watch from
+		rev, srvcList, err := ep.getServiceState(keyName)
+		if err != nil {
+			log.Fatalf("Unable to watch service key: %s - %v", keyName, err)
+		}
+
+		// walk each service and inject it as an add event
+		for _, srvInfo := range srvcList {
+			log.Debugf("Sending service add event: %+v", srvInfo)
+			eventCh <- WatchServiceEvent{
+				EventType:   WatchServiceEventAdd,
+				ServiceInfo: srvInfo,
+			}
+
+			srvMap[srvInfo.HostAddr+":"+strconv.Itoa(srvInfo.Port)] = srvInfo
+		}
+
+		log.Infof("Watching for service: %s at revision %v", keyName, rev+1)
+		watchCh := ep.client.Watch(watchCtx, keyName, true, rev+1)
+
+		for {
+			select {
+			case watchResp, ok := <-watchCh:
+				if !ok {
+					return
+				}
+				if watchResp.Err == etcd3.ErrCompacted {
+					// changes were lost, read the instances again and
+					// resume from the revision they were read at
+					rev, err = ep.resyncServices(keyName, srvMap, eventCh)
+					if err == nil {
+						log.Infof("Resyncing watch for service: %s at revision %v", keyName, rev+1)
+						watchCh = ep.client.Watch(watchCtx, keyName, true, rev+1)
+						continue
+					}
+				}
+				if watchResp.Err != nil {
+					log.Errorf("Error %v during watch. Watch thread exiting", watchResp.Err)
+					eventCh <- WatchServiceEvent{EventType: WatchServiceEventError}
+					return
+				}
+
+				for _, ev := range watchResp.Events {
+					ep.handleServiceEvent(ev, srvMap, eventCh)
+				}
+			case stopReq := <-stopCh:
+				if stopReq {
+					// Stop watch and return
+					log.Infof("Stopping watch on %s", keyName)
+					watchCancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// resyncServices reads all instances of a service and sends the adds and
+// deletes missed by the watch. It returns the revision the instances were
+// read at.
+func (ep *Etcd3Client) resyncServices(key string, srvMap map[string]ServiceInfo, eventCh chan WatchServiceEvent) (int64, error) {
+	rev, srvcList, err := ep.getServiceState(key)
+	if err != nil {
+		return 0, err
+	}
+
+	current := make(map[string]ServiceInfo)
+	for _, srvInfo := range srvcList {
+		srvKey := srvInfo.HostAddr + ":" + strconv.Itoa(srvInfo.Port)
+		current[srvKey] = srvInfo
+		if _, ok := srvMap[srvKey]; ok {
+			continue
+		}
+
+		log.Infof("Sending service add event: %+v", srvInfo)
+		eventCh <- WatchServiceEvent{
+			EventType:   WatchServiceEventAdd,
+			ServiceInfo: srvInfo,
+		}
+		srvMap[srvKey] = srvInfo
+	}
+
+	for srvKey, srvInfo := range srvMap {
+		if _, ok := current[srvKey]; ok {
+			continue
+		}
+
+		log.Infof("Sending service del event: %+v", srvInfo)
+		eventCh <- WatchServiceEvent{
+			EventType:   WatchServiceEventDel,
+			ServiceInfo: srvInfo,
+		}
+		delete(srvMap, srvKey)
+	}
+
+	return rev, nil
+}
+
+// handleServiceEvent converts a watch event to a service event
+func (ep *Etcd3Client) handleServiceEvent(ev *etcd3.Event, srvMap map[string]ServiceInfo, eventCh chan WatchServiceEvent) {
+	var srvInfo ServiceInfo
+
+	switch ev.Type {
+	case etcd3.EventPut:
+		// Note that a put doesnt exactly mean a new service end point.
+		// If a service restarts and re-registers before it expired, we'll
+		// receive a put again.
+		if ev.PrevKv != nil {
+			return
+		}
+
+		if err := json.Unmarshal([]byte(ev.Kv.Value), &srvInfo); err != nil {
+			log.Errorf("Error parsing object %s, Err %v", ev.Kv.Value, err)
+			return
+		}
+		srvKey := srvInfo.HostAddr + ":" + strconv.Itoa(srvInfo.Port)
+		if _, ok := srvMap[srvKey]; ok {
+			return
+		}
+
+		log.Infof("Sending service add event: %+v", srvInfo)
+		eventCh <- WatchServiceEvent{
+			EventType:   WatchServiceEventAdd,
+			ServiceInfo: srvInfo,
+		}
+
+		// save it in cache
+		srvMap[srvKey] = srvInfo
+	case etcd3.EventDelete:
+		// deletes and lease expiry look the same
+		if ev.PrevKv == nil {
+			return
+		}
+
+		if err := json.Unmarshal([]byte(ev.PrevKv.Value), &srvInfo); err != nil {
+			log.Errorf("Error parsing object %s, Err %v", ev.PrevKv.Value, err)
+			return
+		}
+
+		log.Infof("Sending service del event: %+v", srvInfo)
+		eventCh <- WatchServiceEvent{
+			EventType:   WatchServiceEventDel,
+			ServiceInfo: srvInfo,
+		}
+
+		// remove it from cache
+		delete(srvMap, srvInfo.HostAddr+":"+strconv.Itoa(srvInfo.Port))
+	}
+}
+
+// DeregisterService Deregister a service
+// This removes the service from the registry and stops the refresh groutine
+func (ep *Etcd3Client) DeregisterService(serviceInfo ServiceInfo) error {
+	keyName := "/contiv.io/service/" + serviceInfo.ServiceName + "/" +
+		serviceInfo.HostAddr + ":" + strconv.Itoa(serviceInfo.Port)
+
+	ep.mutex.Lock()
+	defer ep.mutex.Unlock()
+
+	// Find it in the database
+	srvState := ep.serviceDb[keyName]
+	if srvState == nil {
+		log.Errorf("Could not find the service in db %s", keyName)
+		return errors.New("Service not found")
+	}
+
+	// stop the refresh thread and delete service
+	srvState.stopChan <- true
+	delete(ep.serviceDb, keyName)
+
+	// Delete the service instance
+	_, err := ep.client.Delete(keyName)
+	if err != nil && err != etcd3.ErrKeyNotFound {
+		log.Errorf("Error deleting key %s. Err: %v", keyName, err)
+		return err
+	}
+
+	return nil
+}
+
+// Keep refreshing the lease of the service every ttl/3. A new lease is
+// created when the old one expired, eg. while etcd was unreachable.
+func (ep *Etcd3Client) refreshService(srvState *etcd3ServiceState, keyVal string) {
+	var lease int64
+
+	register := func() {
+		var err error
+		lease, err = ep.client.Grant(int64(srvState.TTL / time.Second))
+		if err != nil {
+			log.Errorf("Error creating lease for key %s, Err: %v", srvState.KeyName, err)
+			lease = 0
+			return
+		}
+
+		err = ep.client.Put(srvState.KeyName, keyVal, lease)
+		if err != nil {
+			log.Errorf("Error setting key %s, Err: %v", srvState.KeyName, err)
+			ep.client.Revoke(lease)
+			lease = 0
+		}
+	}
+
+	register()
+
+	// Loop forever
+	for {
+		select {
+		case <-time.After(srvState.TTL / 3):
+			log.Debugf("Refreshing key: %s", srvState.KeyName)
+
+			if lease == 0 {
+				register()
+				continue
+			}
+
+			err := ep.client.KeepAliveOnce(lease)
+			if err == etcd3.ErrLeaseNotFound {
+				log.Warnf("Lease of key %s expired, registering again", srvState.KeyName)
+				register()
+			} else if err != nil {
+				log.Errorf("Error refreshing key %s, Err: %v", srvState.KeyName, err)
+			}
+
+		case <-srvState.stopChan:
+			log.Infof("Stop refreshing key: %s", srvState.KeyName)
+			return
+		}
+	}
+}
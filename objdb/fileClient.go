@@ -0,0 +1,140 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objdb
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/objdb/filedb"
+)
+
+type filePlugin struct {
+	mutex *sync.Mutex
+}
+
+// FileClient has the state of a client of a local file store
+type FileClient struct {
+	db *filedb.DB
+
+	serviceDb map[string]*fileServiceState
+	mutex     sync.Mutex
+}
+
+// Register the plugin
+func init() {
+	RegisterPlugin("file", &filePlugin{mutex: new(sync.Mutex)})
+}
+
+// Initialize the file store client. The endpoint is the directory of the
+// store, eg. file:///var/lib/contiv
+func (fp *filePlugin) NewClient(endpoints []string) (API, error) {
+	var err error
+	var fc = new(FileClient)
+
+	fp.mutex.Lock()
+	defer fp.mutex.Unlock()
+
+	if len(endpoints) != 1 {
+		return nil, errors.New("file store needs exactly one directory")
+	}
+
+	path, err := filedb.PathFromURL(endpoints[0])
+	if err != nil {
+		log.Errorf("Invalid file store url %s. Err: %v", endpoints[0], err)
+		return nil, err
+	}
+
+	fc.db, err = filedb.Open(path)
+	if err != nil {
+		log.Errorf("Failed to open file store %s. Err: %v", path, err)
+		return nil, err
+	}
+
+	// Initialize service DB
+	fc.serviceDb = make(map[string]*fileServiceState)
+
+	return fc, nil
+}
+
+// GetObj Get an object
+func (fc *FileClient) GetObj(key string, retVal interface{}) error {
+	keyName := "/contiv.io/obj/" + key
+
+	kv, err := fc.db.Get(keyName)
+	if err != nil {
+		log.Errorf("Error getting key %s. Err: %v", keyName, err)
+		return err
+	}
+
+	// Parse JSON response
+	if err := json.Unmarshal([]byte(kv.Value), retVal); err != nil {
+		log.Errorf("Error parsing object %s, Err %v", kv.Value, err)
+		return err
+	}
+
+	return nil
+}
+
+// ListDir Get a list of objects in a directory
+func (fc *FileClient) ListDir(key string) ([]string, error) {
+	keyName := "/contiv.io/obj/" + key
+
+	kvs, err := fc.db.List(keyName)
+	if err != nil {
+		return nil, err
+	}
+
+	var retList []string
+	for _, kv := range kvs {
+		retList = append(retList, kv.Value)
+	}
+
+	return retList, nil
+}
+
+// SetObj Save an object, create if it doesnt exist
+func (fc *FileClient) SetObj(key string, value interface{}) error {
+	keyName := "/contiv.io/obj/" + key
+
+	// JSON format the object
+	jsonVal, err := json.Marshal(value)
+	if err != nil {
+		log.Errorf("Json conversion error. Err %v", err)
+		return err
+	}
+
+	if err := fc.db.Put(keyName, string(jsonVal[:]), 0); err != nil {
+		log.Errorf("Error setting key %s, Err: %v", keyName, err)
+		return err
+	}
+
+	return nil
+}
+
+// DelObj Remove an object
+func (fc *FileClient) DelObj(key string) error {
+	keyName := "/contiv.io/obj/" + key
+
+	if _, err := fc.db.Delete(keyName); err != nil {
+		log.Errorf("Error removing key %s, Err: %v", keyName, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,300 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objdb
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/objdb/filedb"
+)
+
+// Lock object. The lock key has a ttl, so that it goes away when the
+// holder stops refreshing it.
+type fileLock struct {
+	name       string
+	keyName    string
+	myID       string
+	isAcquired bool
+	isReleased bool
+	holderID   string
+	ttl        time.Duration
+	timeout    uint64
+	eventChan  chan LockEvent
+	stopChan   chan bool
+	db         *filedb.DB
+	mutex      *sync.Mutex
+}
+
+// NewLock Create a new lock
+func (fc *FileClient) NewLock(name string, myID string, ttl uint64) (LockInterface, error) {
+	// Create a lock
+	return &fileLock{
+		name:      name,
+		keyName:   "/contiv.io/lock/" + name,
+		myID:      myID,
+		ttl:       time.Duration(ttl) * time.Second,
+		db:        fc.db,
+		eventChan: make(chan LockEvent, 1),
+		stopChan:  make(chan bool, 1),
+		mutex:     new(sync.Mutex),
+	}, nil
+}
+
+// Acquire a lock
+func (lk *fileLock) Acquire(timeout uint64) error {
+	lk.mutex.Lock()
+	defer lk.mutex.Unlock()
+	lk.timeout = timeout
+
+	// Acquire in background
+	go lk.acquireLock()
+
+	return nil
+}
+
+// Release a lock
+func (lk *fileLock) Release() error {
+	lk.mutex.Lock()
+	defer lk.mutex.Unlock()
+
+	// Mark this as released
+	lk.isReleased = true
+
+	// Send stop signal on stop channel
+	lk.stopChan <- true
+
+	// If the lock was acquired, release it
+	if lk.isAcquired {
+		_, _, err := lk.db.DeleteIf(lk.keyName, lk.isHolder)
+		if err != nil {
+			log.Errorf("Error deleting key %s. Err: %v", lk.keyName, err)
+		} else {
+			log.Infof("Released lock %s", lk.keyName)
+		}
+
+		lk.isAcquired = false
+	}
+
+	return nil
+}
+
+// Kill Stops a lock without releasing it.
+// Let the ttl expiry release it
+// Note: This is for debug/test purposes only
+func (lk *fileLock) Kill() error {
+	lk.mutex.Lock()
+	defer lk.mutex.Unlock()
+	// Mark this as released
+	lk.isReleased = true
+
+	// Send stop signal on stop channel
+	lk.stopChan <- true
+
+	return nil
+}
+
+// EventChan Returns event channel
+func (lk *fileLock) EventChan() <-chan LockEvent {
+	lk.mutex.Lock()
+	defer lk.mutex.Unlock()
+	return lk.eventChan
+}
+
+// IsAcquired Checks if the lock is acquired
+func (lk *fileLock) IsAcquired() bool {
+	lk.mutex.Lock()
+	defer lk.mutex.Unlock()
+	return lk.isAcquired
+}
+
+// GetHolder Gets current lock holder's ID
+func (lk *fileLock) GetHolder() string {
+	lk.mutex.Lock()
+	defer lk.mutex.Unlock()
+
+	kv, err := lk.db.Get(lk.keyName)
+	if err != nil {
+		log.Warnf("Could not get current holder for lock %s", lk.name)
+		return ""
+	}
+
+	return kv.Value
+}
+
+// *********************** Internal functions *************
+
+// isHolder checks if we hold the lock
+func (lk *fileLock) isHolder(cur *filedb.KeyValue) bool {
+	return cur != nil && cur.Value == lk.myID
+}
+
+// Try acquiring a lock.
+// This assumes its called in its own go routine
+func (lk *fileLock) acquireLock() {
+	// Wait in this loop forever till lock times out or released
+	for {
+		// create the key if it doesnt exist, or take it over if we already
+		// hold it from before a restart
+		acquired, cur, err := lk.db.PutIf(lk.keyName, lk.myID, lk.ttl,
+			func(cur *filedb.KeyValue) bool {
+				return cur == nil || lk.isHolder(cur)
+			})
+		if err != nil {
+			log.Errorf("Error acquiring lock %s. Err: %v", lk.keyName, err)
+			// Retry after a second in case of error
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-lk.stopChan:
+				return
+			}
+		}
+
+		if acquired {
+			log.Infof("Acquired lock %s", lk.keyName)
+
+			lk.mutex.Lock()
+			// Successfully acquired the lock
+			lk.isAcquired = true
+			lk.holderID = lk.myID
+			lk.mutex.Unlock()
+
+			// Send acquired message to event channel
+			lk.eventChan <- LockEvent{EventType: LockAcquired}
+
+			// refresh it
+			lk.refreshLock()
+		} else {
+			log.Debugf("Lock %s already acquired by %s", lk.keyName, cur.Value)
+
+			lk.mutex.Lock()
+			// Set the current holder's ID
+			lk.holderID = cur.Value
+			lk.mutex.Unlock()
+
+			// Wait for changes on the lock
+			lk.waitForLock()
+		}
+
+		lk.mutex.Lock()
+		// If lock is released, we are done, else go back and try to acquire it
+		if lk.isReleased {
+			lk.mutex.Unlock()
+			return
+		}
+		lk.mutex.Unlock()
+	}
+}
+
+// We couldnt acquire lock, Wait for the holder to release it
+func (lk *fileLock) waitForLock() {
+	// If timeout is not specified, set it to high value
+	timeoutIntvl := time.Second * time.Duration(20000)
+	if lk.timeout != 0 {
+		timeoutIntvl = time.Second * time.Duration(lk.timeout)
+	}
+
+	log.Infof("Waiting to acquire lock (%s/%s)", lk.name, lk.myID)
+
+	// Create a timer
+	timer := time.NewTimer(timeoutIntvl)
+	defer timer.Stop()
+
+	watchStop := make(chan bool)
+	defer close(watchStop)
+
+	_, watchCh, err := lk.db.Watch(lk.keyName, watchStop)
+	if err != nil {
+		log.Errorf("Error watching lock %s. Err: %v", lk.keyName, err)
+		time.Sleep(time.Second)
+		return
+	}
+
+	// Wait for changes
+	for {
+		select {
+		case <-timer.C:
+			lk.mutex.Lock()
+			if lk.timeout != 0 {
+				lk.mutex.Unlock()
+				log.Infof("Lock timeout on lock %s/%s", lk.name, lk.myID)
+
+				lk.eventChan <- LockEvent{EventType: LockAcquireTimeout}
+
+				log.Infof("Lock acquire timed out. Stopping lock")
+
+				// Release the lock
+				lk.Release()
+
+				return
+			}
+			lk.mutex.Unlock()
+		case ev := <-watchCh:
+			// deletes and ttl expiry look the same
+			if ev.Type == filedb.EventDelete {
+				log.Infof("Retrying to acquire lock")
+				return
+			}
+		case <-lk.stopChan:
+			log.Infof("Stopping lock")
+			return
+		}
+	}
+}
+
+// Refresh lock
+func (lk *fileLock) refreshLock() {
+	// Refresh interval is 1/3rd of TTL
+	refreshIntvl := lk.ttl / 3
+
+	// Loop forever
+	for {
+		select {
+		case <-time.After(refreshIntvl):
+			// Renew the ttl of the lock, unless someone else took it
+			refreshed, _, err := lk.db.PutIf(lk.keyName, lk.myID, lk.ttl, lk.isHolder)
+			if err != nil {
+				log.Errorf("Error refreshing lock %s. Err: %v", lk.keyName, err)
+				continue
+			}
+			if refreshed {
+				log.Debugf("Refreshed ttl on lock %s", lk.keyName)
+				continue
+			}
+
+			log.Infof("Holder %s lost the lock %s", lk.myID, lk.name)
+
+			lk.mutex.Lock()
+			// releasing the lock deletes the key too
+			if lk.isReleased {
+				lk.mutex.Unlock()
+				return
+			}
+			// We are not master anymore
+			lk.isAcquired = false
+			lk.mutex.Unlock()
+
+			// Send lock lost event
+			lk.eventChan <- LockEvent{EventType: LockLost}
+			return
+		case <-lk.stopChan:
+			log.Infof("Stopping lock")
+			return
+		}
+	}
+}
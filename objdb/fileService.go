@@ -0,0 +1,250 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objdb
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/objdb/filedb"
+)
+
+// Service state
+type fileServiceState struct {
+	ServiceName string        // Name of the service
+	KeyName     string        // Service key name
+	TTL         time.Duration // TTL for the service
+	HostAddr    string        // Host name or IP address where its running
+	Port        int           // Port number where its listening
+	Hostname    string        // Host name where its running
+
+	// Channel to stop ttl refresh
+	stopChan chan bool
+}
+
+// RegisterService Register a service
+// Service is registered with a ttl and a goroutine is created to refresh
+// the ttl.
+func (fc *FileClient) RegisterService(serviceInfo ServiceInfo) error {
+	keyName := "/contiv.io/service/" + serviceInfo.ServiceName + "/" +
+		serviceInfo.HostAddr + ":" + strconv.Itoa(serviceInfo.Port)
+	ttl := time.Duration(serviceInfo.TTL) * time.Second
+
+	log.Infof("Registering service key: %s, value: %+v", keyName, serviceInfo)
+
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	// if there is a previously registered service, stop refreshing it
+	if fc.serviceDb[keyName] != nil {
+		fc.serviceDb[keyName].stopChan <- true
+	}
+
+	// JSON format the object
+	jsonVal, err := json.Marshal(serviceInfo)
+	if err != nil {
+		log.Errorf("Json conversion error. Err %v", err)
+		return err
+	}
+
+	// Set it via the store
+	err = fc.db.Put(keyName, string(jsonVal[:]), ttl)
+	if err != nil {
+		log.Errorf("Error setting key %s, Err: %v", keyName, err)
+		return err
+	}
+
+	// create service state
+	srvState := fileServiceState{
+		ServiceName: serviceInfo.ServiceName,
+		KeyName:     keyName,
+		TTL:         ttl,
+		HostAddr:    serviceInfo.HostAddr,
+		Port:        serviceInfo.Port,
+		stopChan:    make(chan bool, 1),
+		Hostname:    serviceInfo.Hostname,
+	}
+
+	// Run refresh in background
+	go fc.refreshService(&srvState, string(jsonVal[:]))
+
+	// Store it in DB
+	fc.serviceDb[keyName] = &srvState
+
+	return nil
+}
+
+// GetService lists all end points for a service
+func (fc *FileClient) GetService(name string) ([]ServiceInfo, error) {
+	keyName := "/contiv.io/service/" + name
+
+	kvs, err := fc.db.List(keyName)
+	if err != nil {
+		log.Errorf("Error getting key %s. Err: %v", keyName, err)
+		return nil, err
+	}
+
+	var srvcList []ServiceInfo
+	for _, kv := range kvs {
+		var respSrvc ServiceInfo
+		// Parse JSON response
+		err = json.Unmarshal([]byte(kv.Value), &respSrvc)
+		if err != nil {
+			log.Errorf("Error parsing object %s, Err %v", kv.Value, err)
+			return nil, err
+		}
+
+		srvcList = append(srvcList, respSrvc)
+	}
+
+	return srvcList, nil
+}
+
+// WatchService Watch for a service
+func (fc *FileClient) WatchService(name string, eventCh chan WatchServiceEvent, stopCh chan bool) error {
+	keyName := "/contiv.io/service/" + name
+
+	// Get the current state, and watch for changes after it
+	watchStop := make(chan bool)
+	kvs, watchCh, err := fc.db.Watch(keyName, watchStop)
+	if err != nil {
+		log.Errorf("Unable to watch service key: %s - %v", keyName, err)
+		return err
+	}
+
+	go func() {
+		defer close(watchStop)
+
+		var srvMap = make(map[string]ServiceInfo)
+
+		// walk each service and inject it as an add event
+		for _, kv := range kvs {
+			fc.handleServiceEvent(&filedb.Event{Type: filedb.EventPut, Kv: kv}, srvMap, eventCh)
+		}
+
+		log.Infof("Watching for service: %s", keyName)
+
+		for {
+			select {
+			case ev := <-watchCh:
+				fc.handleServiceEvent(ev, srvMap, eventCh)
+			case stopReq := <-stopCh:
+				if stopReq {
+					// Stop watch and return
+					log.Infof("Stopping watch on %s", keyName)
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// handleServiceEvent converts a watch event to a service event
+func (fc *FileClient) handleServiceEvent(ev *filedb.Event, srvMap map[string]ServiceInfo, eventCh chan WatchServiceEvent) {
+	var srvInfo ServiceInfo
+
+	switch ev.Type {
+	case filedb.EventPut:
+		if err := json.Unmarshal([]byte(ev.Kv.Value), &srvInfo); err != nil {
+			log.Errorf("Error parsing object %s, Err %v", ev.Kv.Value, err)
+			return
+		}
+		srvKey := srvInfo.HostAddr + ":" + strconv.Itoa(srvInfo.Port)
+		if _, ok := srvMap[srvKey]; ok {
+			return
+		}
+
+		log.Infof("Sending service add event: %+v", srvInfo)
+		eventCh <- WatchServiceEvent{
+			EventType:   WatchServiceEventAdd,
+			ServiceInfo: srvInfo,
+		}
+
+		// save it in cache
+		srvMap[srvKey] = srvInfo
+	case filedb.EventDelete:
+		// deletes and ttl expiry look the same
+		if err := json.Unmarshal([]byte(ev.PrevKv.Value), &srvInfo); err != nil {
+			log.Errorf("Error parsing object %s, Err %v", ev.PrevKv.Value, err)
+			return
+		}
+
+		log.Infof("Sending service del event: %+v", srvInfo)
+		eventCh <- WatchServiceEvent{
+			EventType:   WatchServiceEventDel,
+			ServiceInfo: srvInfo,
+		}
+
+		// remove it from cache
+		delete(srvMap, srvInfo.HostAddr+":"+strconv.Itoa(srvInfo.Port))
+	}
+}
+
+// DeregisterService Deregister a service
+// This removes the service from the registry and stops the refresh groutine
+func (fc *FileClient) DeregisterService(serviceInfo ServiceInfo) error {
+	keyName := "/contiv.io/service/" + serviceInfo.ServiceName + "/" +
+		serviceInfo.HostAddr + ":" + strconv.Itoa(serviceInfo.Port)
+
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+
+	// Find it in the database
+	srvState := fc.serviceDb[keyName]
+	if srvState == nil {
+		log.Errorf("Could not find the service in db %s", keyName)
+		return errors.New("Service not found")
+	}
+
+	// stop the refresh thread and delete service
+	srvState.stopChan <- true
+	delete(fc.serviceDb, keyName)
+
+	// Delete the service instance
+	_, err := fc.db.Delete(keyName)
+	if err != nil && err != filedb.ErrKeyNotFound {
+		log.Errorf("Error deleting key %s. Err: %v", keyName, err)
+		return err
+	}
+
+	return nil
+}
+
+// Keep refreshing the service every 1/3rd of its ttl. The key is written
+// again, so that it comes back if it expired meanwhile.
+func (fc *FileClient) refreshService(srvState *fileServiceState, keyVal string) {
+	// Loop forever
+	for {
+		select {
+		case <-time.After(srvState.TTL / 3):
+			log.Debugf("Refreshing key: %s", srvState.KeyName)
+
+			err := fc.db.Put(srvState.KeyName, keyVal, srvState.TTL)
+			if err != nil {
+				log.Errorf("Error refreshing key %s, Err: %v", srvState.KeyName, err)
+			}
+
+		case <-srvState.stopChan:
+			log.Infof("Stop refreshing key: %s", srvState.KeyName)
+			return
+		}
+	}
+}
@@ -0,0 +1,477 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package filedb is a key-value store kept in a local directory, one file
+// per key. It lets netmaster and netplugin run on a single node without an
+// external etcd or consul. Processes sharing the directory see each others
+// changes; watchers in the writing process are notified right away, and
+// other processes pick changes up by polling.
+package filedb
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Errors returned by the store
+var (
+	ErrKeyNotFound = errors.New("key not found")
+	ErrInvalidKey  = errors.New("invalid key")
+)
+
+// interval at which watchers look for changes made by other processes
+var pollInterval = time.Second
+
+const (
+	lockFileName = ".lock" // flock serializing writers across processes
+	tmpDirName   = ".tmp"  // new files are written here, then renamed
+)
+
+// KeyValue is a key and its value
+type KeyValue struct {
+	Key     string
+	Value   string
+	Version int64     // changes on every write of the key
+	Expires time.Time // zero for keys that dont expire
+}
+
+// record is the file content of a key
+type record struct {
+	Value   string `json:"value"`
+	Version int64  `json:"version,omitempty"`
+	Expires int64  `json:"expires,omitempty"` // unix nano
+}
+
+// DB is a store in a directory. All users of a directory in a process
+// share one DB.
+type DB struct {
+	root     string
+	mutex    sync.Mutex // serializes writers in this process
+	lockFile *os.File
+
+	watchMutex sync.Mutex
+	watchers   map[*watcher]bool
+}
+
+var (
+	dbs      = make(map[string]*DB)
+	dbsMutex sync.Mutex
+)
+
+// Open opens the store in a directory, creating it if needed
+func Open(root string) (*DB, error) {
+	root = filepath.Clean(root)
+	if !filepath.IsAbs(root) {
+		return nil, errors.New("store path must be absolute")
+	}
+
+	dbsMutex.Lock()
+	defer dbsMutex.Unlock()
+
+	if db, ok := dbs[root]; ok {
+		return db, nil
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, tmpDirName), 0700); err != nil {
+		return nil, err
+	}
+
+	lockFile, err := os.OpenFile(filepath.Join(root, lockFileName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{
+		root:     root,
+		lockFile: lockFile,
+		watchers: make(map[*watcher]bool),
+	}
+	dbs[root] = db
+
+	log.Infof("Using file store in %s", root)
+
+	return db, nil
+}
+
+// normalizeKey makes keys absolute, like etcd does
+func normalizeKey(key string) string {
+	if strings.HasPrefix(key, "/") {
+		return key
+	}
+
+	return "/" + key
+}
+
+// keyPath returns the file of a key. Keys are slash separated paths;
+// names starting with a dot are reserved.
+func (db *DB) keyPath(key string) (string, error) {
+	for _, name := range strings.Split(strings.TrimPrefix(key, "/"), "/") {
+		if name == "" || strings.HasPrefix(name, ".") {
+			return "", ErrInvalidKey
+		}
+	}
+
+	return filepath.Join(db.root, filepath.FromSlash(key)), nil
+}
+
+// lock serializes writers across goroutines and processes
+func (db *DB) lock() error {
+	db.mutex.Lock()
+	if err := syscall.Flock(int(db.lockFile.Fd()), syscall.LOCK_EX); err != nil {
+		db.mutex.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (db *DB) unlock() {
+	syscall.Flock(int(db.lockFile.Fd()), syscall.LOCK_UN)
+	db.mutex.Unlock()
+}
+
+// readKey reads a key from its file. Expired keys are not found.
+func readKey(key, path string) (*KeyValue, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) || isDirErr(err) {
+			return nil, ErrKeyNotFound
+		}
+		return nil, err
+	}
+
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		log.Errorf("Error parsing key %s. Err: %v", key, err)
+		return nil, err
+	}
+
+	kv := &KeyValue{Key: key, Value: rec.Value, Version: rec.Version}
+	if kv.Version == 0 {
+		// written before keys had versions, 0 is kept for missing keys
+		kv.Version = 1
+	}
+	if rec.Expires != 0 {
+		kv.Expires = time.Unix(0, rec.Expires)
+		if time.Now().After(kv.Expires) {
+			return nil, ErrKeyNotFound
+		}
+	}
+
+	return kv, nil
+}
+
+func isDirErr(err error) bool {
+	pathErr, ok := err.(*os.PathError)
+	return ok && pathErr.Err == syscall.EISDIR
+}
+
+// Get reads a key
+func (db *DB) Get(key string) (*KeyValue, error) {
+	key = normalizeKey(key)
+	path, err := db.keyPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return readKey(key, path)
+}
+
+// List reads all keys under a directory, sorted by key. Listing a key
+// returns the key itself.
+func (db *DB) List(dir string) ([]*KeyValue, error) {
+	dir = strings.TrimSuffix(normalizeKey(dir), "/")
+	dirPath, err := db.keyPath(dir + "/x")
+	if err != nil {
+		return nil, err
+	}
+	dirPath = filepath.Dir(dirPath)
+
+	var kvs []*KeyValue
+	err = filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		// a single key is listed as itself
+		key := dir
+		if path != dirPath {
+			key = dir + "/" + filepath.ToSlash(strings.TrimPrefix(path, dirPath+string(filepath.Separator)))
+		}
+		kv, err := readKey(key, path)
+		if err == ErrKeyNotFound {
+			// deleted or expired meanwhile
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		kvs = append(kvs, kv)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(byKey(kvs))
+	return kvs, nil
+}
+
+type byKey []*KeyValue
+
+func (s byKey) Len() int           { return len(s) }
+func (s byKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byKey) Less(i, j int) bool { return s[i].Key < s[j].Key }
+
+// Put writes a key. Keys with a ttl are removed when they are not written
+// again within ttl.
+func (db *DB) Put(key, value string, ttl time.Duration) error {
+	_, _, err := db.PutIf(key, value, ttl, nil)
+	return err
+}
+
+// PutIf writes a key if cond returns true for its current value, which is
+// nil for missing keys. The condition is checked and the key written
+// atomically. It returns if the key was written and its value before.
+func (db *DB) PutIf(key, value string, ttl time.Duration, cond func(cur *KeyValue) bool) (bool, *KeyValue, error) {
+	key = normalizeKey(key)
+	path, err := db.keyPath(key)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if err := db.lock(); err != nil {
+		return false, nil, err
+	}
+	defer db.unlock()
+
+	cur, err := readKey(key, path)
+	if err != nil && err != ErrKeyNotFound {
+		return false, nil, err
+	}
+	if cond != nil && !cond(cur) {
+		return false, cur, nil
+	}
+
+	rec := record{Value: value, Version: nextVersion(cur)}
+	if ttl != 0 {
+		rec.Expires = time.Now().Add(ttl).UnixNano()
+	}
+	if err := db.writeFile(path, &rec); err != nil {
+		log.Errorf("Error writing key %s. Err: %v", key, err)
+		return false, cur, err
+	}
+
+	kv := &KeyValue{Key: key, Value: value, Version: rec.Version}
+	if rec.Expires != 0 {
+		kv.Expires = time.Unix(0, rec.Expires)
+	}
+	db.notifyWatchers(change{key: key, kv: kv})
+	return true, cur, nil
+}
+
+// nextVersion returns the version of the next write of a key. Versions are
+// write times, made to increase on every write of the key, so a key that
+// is deleted and created again doesnt get an old version back.
+func nextVersion(cur *KeyValue) int64 {
+	version := time.Now().UnixNano()
+	if cur != nil && version <= cur.Version {
+		version = cur.Version + 1
+	}
+
+	return version
+}
+
+// writeFile replaces the file of a key atomically
+func (db *DB) writeFile(path string, rec *record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Join(db.root, tmpDirName), "key")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+// Delete removes a key. It returns the value the key had.
+func (db *DB) Delete(key string) (*KeyValue, error) {
+	deleted, cur, err := db.DeleteIf(key, nil)
+	if err == nil && !deleted {
+		err = ErrKeyNotFound
+	}
+
+	return cur, err
+}
+
+// DeleteIf removes a key if cond returns true for its current value. It
+// returns if the key was removed and its value before.
+func (db *DB) DeleteIf(key string, cond func(cur *KeyValue) bool) (bool, *KeyValue, error) {
+	key = normalizeKey(key)
+	path, err := db.keyPath(key)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if err := db.lock(); err != nil {
+		return false, nil, err
+	}
+	defer db.unlock()
+
+	cur, err := readKey(key, path)
+	if err == ErrKeyNotFound {
+		// clean up expired keys
+		os.Remove(path)
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	if cond != nil && !cond(cur) {
+		return false, cur, nil
+	}
+
+	if err := os.Remove(path); err != nil {
+		return false, cur, err
+	}
+
+	db.notifyWatchers(change{key: key})
+	return true, cur, nil
+}
+
+// Op is a write or delete of a key in a Commit. The key must be at Version
+// for the commit to succeed, a Version of 0 meaning it must not exist.
+type Op struct {
+	Key     string
+	Value   string
+	Delete  bool
+	Version int64
+}
+
+// Commit applies all ops if every key is at the version of its op. The
+// versions are checked and the keys written under the store lock, but the
+// files are replaced one by one, so readers not taking the lock can see
+// part of a commit. It returns if the ops were applied.
+func (db *DB) Commit(ops []Op) (bool, error) {
+	paths := []string{}
+	for i := range ops {
+		ops[i].Key = normalizeKey(ops[i].Key)
+		path, err := db.keyPath(ops[i].Key)
+		if err != nil {
+			return false, err
+		}
+		paths = append(paths, path)
+	}
+
+	if err := db.lock(); err != nil {
+		return false, err
+	}
+	defer db.unlock()
+
+	curs := []*KeyValue{}
+	for i, op := range ops {
+		cur, err := readKey(op.Key, paths[i])
+		if err != nil && err != ErrKeyNotFound {
+			return false, err
+		}
+		if (cur == nil && op.Version != 0) || (cur != nil && cur.Version != op.Version) {
+			return false, nil
+		}
+		curs = append(curs, cur)
+	}
+
+	changes := []change{}
+	for i, op := range ops {
+		var err error
+		c := change{key: op.Key}
+		if op.Delete {
+			err = os.Remove(paths[i])
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+			rec := &record{Value: op.Value, Version: nextVersion(curs[i])}
+			err = db.writeFile(paths[i], rec)
+			c.kv = &KeyValue{Key: op.Key, Value: op.Value, Version: rec.Version}
+		}
+		if err != nil {
+			log.Errorf("Error committing key %s. Err: %v", op.Key, err)
+			db.notifyWatchers(changes...)
+			return false, err
+		}
+		changes = append(changes, c)
+	}
+
+	db.notifyWatchers(changes...)
+	return true, nil
+}
+
+// PathFromURL returns the directory of a file store url, like
+// file:///var/lib/contiv. Plain absolute paths are accepted too.
+func PathFromURL(storeURL string) (string, error) {
+	path := storeURL
+	if idx := strings.Index(storeURL, "://"); idx >= 0 {
+		path = storeURL[idx+len("://"):]
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		return "", errors.New("file store url must have an absolute path, eg. file:///var/lib/contiv")
+	}
+
+	return path, nil
+}
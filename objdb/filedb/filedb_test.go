@@ -0,0 +1,272 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filedb
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func openTestDB(t *testing.T) (*DB, func()) {
+	dir, err := ioutil.TempDir("", "filedb")
+	if err != nil {
+		t.Fatalf("Error creating temp dir. Err: %v", err)
+	}
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Error opening store. Err: %v", err)
+	}
+
+	return db, func() { os.RemoveAll(dir) }
+}
+
+func TestPathFromURL(t *testing.T) {
+	for storeURL, expPath := range map[string]string{
+		"file:///var/lib/contiv": "/var/lib/contiv",
+		"http:///var/lib/contiv": "/var/lib/contiv",
+		"/var/lib/contiv":        "/var/lib/contiv",
+	} {
+		path, err := PathFromURL(storeURL)
+		if err != nil || path != expPath {
+			t.Fatalf("PathFromURL(%s) returned %q, %v. Expected: %q", storeURL, path, err, expPath)
+		}
+	}
+
+	for _, storeURL := range []string{"file://var/lib", "var/lib"} {
+		if _, err := PathFromURL(storeURL); err == nil {
+			t.Fatalf("PathFromURL(%s) succeeded", storeURL)
+		}
+	}
+}
+
+func TestKeyValues(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	if db2, err := Open(db.root + "/"); err != nil || db2 != db {
+		t.Fatalf("Store was not shared")
+	}
+
+	for _, key := range []string{"", "/", "/a/../b", "/a//b", "/.lock", "a/"} {
+		if err := db.Put(key, "v", 0); err != ErrInvalidKey {
+			t.Fatalf("Put of invalid key %q returned %v", key, err)
+		}
+	}
+
+	if _, err := db.Get("/dir/k1"); err != ErrKeyNotFound {
+		t.Fatalf("Get of missing key returned %v", err)
+	}
+
+	for key, value := range map[string]string{
+		"/dir/k1":     "v1",
+		"/dir/k2":     "v2",
+		"/dir/sub/k3": "v3",
+		"/dir2/k4":    "v4",
+	} {
+		if err := db.Put(key, value, 0); err != nil {
+			t.Fatalf("Error writing key %s. Err: %v", key, err)
+		}
+	}
+
+	if err := db.Put("/dir/k1", "v1.1", 0); err != nil {
+		t.Fatalf("Error updating key. Err: %v", err)
+	}
+	kv, err := db.Get("/dir/k1")
+	if err != nil || kv.Value != "v1.1" {
+		t.Fatalf("Get returned %+v, %v", kv, err)
+	}
+
+	kvs, err := db.List("/dir/")
+	if err != nil {
+		t.Fatalf("Error listing keys. Err: %v", err)
+	}
+	expKeys := []string{"/dir/k1", "/dir/k2", "/dir/sub/k3"}
+	if len(kvs) != len(expKeys) {
+		t.Fatalf("List returned %d keys. Expected: %v", len(kvs), expKeys)
+	}
+	for idx, kv := range kvs {
+		if kv.Key != expKeys[idx] {
+			t.Fatalf("List returned key %s. Expected: %s", kv.Key, expKeys[idx])
+		}
+	}
+
+	if kvs, err := db.List("/dir/k2"); err != nil || len(kvs) != 1 || kvs[0].Key != "/dir/k2" {
+		t.Fatalf("List of a key returned %+v, %v", kvs, err)
+	}
+	if kvs, err := db.List("/none"); err != nil || len(kvs) != 0 {
+		t.Fatalf("List of a missing directory returned %+v, %v", kvs, err)
+	}
+
+	prev, err := db.Delete("/dir/k2")
+	if err != nil || prev.Value != "v2" {
+		t.Fatalf("Delete returned %+v, %v", prev, err)
+	}
+	if _, err := db.Delete("/dir/k2"); err != ErrKeyNotFound {
+		t.Fatalf("Delete of missing key returned %v", err)
+	}
+}
+
+func TestTTLAndConditions(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	isHolder := func(id string) func(*KeyValue) bool {
+		return func(cur *KeyValue) bool { return cur != nil && cur.Value == id }
+	}
+	isFree := func(cur *KeyValue) bool { return cur == nil }
+
+	ok, _, err := db.PutIf("/lock/l1", "node1", 200*time.Millisecond, isFree)
+	if err != nil || !ok {
+		t.Fatalf("Error taking lock. ok: %v, Err: %v", ok, err)
+	}
+
+	ok, cur, err := db.PutIf("/lock/l1", "node2", 200*time.Millisecond, isFree)
+	if err != nil || ok || cur.Value != "node1" {
+		t.Fatalf("Lock was taken twice. cur: %+v, Err: %v", cur, err)
+	}
+
+	if deleted, _, err := db.DeleteIf("/lock/l1", isHolder("node2")); err != nil || deleted {
+		t.Fatalf("Lock was released by another node. Err: %v", err)
+	}
+
+	// let the key expire
+	time.Sleep(300 * time.Millisecond)
+	if _, err := db.Get("/lock/l1"); err != ErrKeyNotFound {
+		t.Fatalf("Expired key was found. Err: %v", err)
+	}
+	if kvs, err := db.List("/lock"); err != nil || len(kvs) != 0 {
+		t.Fatalf("Expired key was listed. kvs: %+v, Err: %v", kvs, err)
+	}
+
+	ok, _, err = db.PutIf("/lock/l1", "node2", 0, isFree)
+	if err != nil || !ok {
+		t.Fatalf("Error taking expired lock. ok: %v, Err: %v", ok, err)
+	}
+
+	if deleted, _, err := db.DeleteIf("/lock/l1", isHolder("node2")); err != nil || !deleted {
+		t.Fatalf("Error releasing lock. Err: %v", err)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	if err := db.Put("/a", "1", 0); err != nil {
+		t.Fatalf("Error writing key. Err: %v", err)
+	}
+	a, err := db.Get("/a")
+	if err != nil || a.Version == 0 {
+		t.Fatalf("Error reading key version. kv: %+v, Err: %v", a, err)
+	}
+
+	ok, err := db.Commit([]Op{{Key: "/a", Value: "2", Version: a.Version}, {Key: "/b", Value: "x"}})
+	if err != nil || !ok {
+		t.Fatalf("Error committing. ok: %v, Err: %v", ok, err)
+	}
+
+	// the stale version of /a fails the whole commit
+	ok, err = db.Commit([]Op{{Key: "/b", Delete: true, Version: 1}, {Key: "/a", Value: "3", Version: a.Version}})
+	if err != nil || ok {
+		t.Fatalf("Stale commit was applied. Err: %v", err)
+	}
+	if kv, err := db.Get("/a"); err != nil || kv.Value != "2" || kv.Version == a.Version {
+		t.Fatalf("Unexpected key after commit. kv: %+v, Err: %v", kv, err)
+	}
+	b, err := db.Get("/b")
+	if err != nil || b.Value != "x" {
+		t.Fatalf("Unexpected key after commit. kv: %+v, Err: %v", b, err)
+	}
+
+	ok, err = db.Commit([]Op{{Key: "/b", Delete: true, Version: b.Version}})
+	if err != nil || !ok {
+		t.Fatalf("Error committing delete. ok: %v, Err: %v", ok, err)
+	}
+	if _, err := db.Get("/b"); err != ErrKeyNotFound {
+		t.Fatalf("Deleted key was found. Err: %v", err)
+	}
+}
+
+func expectEvent(t *testing.T, watchCh <-chan *Event, evType, key, value, prevValue string) {
+	select {
+	case ev := <-watchCh:
+		if ev.Type != evType || ev.Kv.Key != key {
+			t.Fatalf("Received %s on %s. Expected: %s on %s", ev.Type, ev.Kv.Key, evType, key)
+		}
+		if evType == EventPut && ev.Kv.Value != value {
+			t.Fatalf("Received value %s. Expected: %s", ev.Kv.Value, value)
+		}
+		if (ev.PrevKv == nil && prevValue != "") || (ev.PrevKv != nil && ev.PrevKv.Value != prevValue) {
+			t.Fatalf("Received previous value %+v. Expected: %q", ev.PrevKv, prevValue)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for %s on %s", evType, key)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	if err := db.Put("/state/k0", "v0", 0); err != nil {
+		t.Fatalf("Error writing key. Err: %v", err)
+	}
+
+	stopCh := make(chan bool)
+	kvs, watchCh, err := db.Watch("/state/", stopCh)
+	if err != nil {
+		t.Fatalf("Error watching keys. Err: %v", err)
+	}
+	if len(kvs) != 1 || kvs[0].Key != "/state/k0" {
+		t.Fatalf("Watch returned keys %+v. Expected: /state/k0", kvs)
+	}
+
+	// changes in this process are seen right away
+	db.Put("/state/k1", "v1", 0)
+	expectEvent(t, watchCh, EventPut, "/state/k1", "v1", "")
+
+	db.Put("/state/k1", "v1.1", 0)
+	expectEvent(t, watchCh, EventPut, "/state/k1", "v1.1", "v1")
+
+	db.Put("/other/k2", "v2", 0)
+	db.Delete("/state/k0")
+	expectEvent(t, watchCh, EventDelete, "/state/k0", "", "v0")
+
+	// every write is sent, even if the key is gone before the watcher runs
+	db.Put("/state/k3", "v3", 0)
+	db.Delete("/state/k3")
+	expectEvent(t, watchCh, EventPut, "/state/k3", "v3", "")
+	expectEvent(t, watchCh, EventDelete, "/state/k3", "", "v3")
+
+	// expiry is seen when polling
+	pollInterval = 50 * time.Millisecond
+	defer func() { pollInterval = time.Second }()
+	_, watchCh, err = db.Watch("/service", stopCh)
+	if err != nil {
+		t.Fatalf("Error watching keys. Err: %v", err)
+	}
+
+	db.Put("/service/s1", "s1", 200*time.Millisecond)
+	expectEvent(t, watchCh, EventPut, "/service/s1", "s1", "")
+	expectEvent(t, watchCh, EventDelete, "/service/s1", "", "s1")
+
+	close(stopCh)
+	for range watchCh {
+	}
+}
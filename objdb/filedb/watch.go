@@ -0,0 +1,217 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package filedb
+
+import (
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Event types
+const (
+	EventPut    = "PUT"
+	EventDelete = "DELETE"
+)
+
+// Event is a change to a key. PrevKv is nil for new keys.
+type Event struct {
+	Type   string
+	Kv     *KeyValue
+	PrevKv *KeyValue
+}
+
+// change is a write of a key in this process, kv is nil for deletes
+type change struct {
+	key string
+	kv  *KeyValue
+}
+
+// watcher tracks the keys under a directory. Writes in this process are
+// sent to it, changes made by other processes and expiries are found by
+// comparing the keys to the directory contents.
+type watcher struct {
+	dir      string
+	keys     map[string]*KeyValue
+	pending  []change // writes not seen by the watcher yet, under watchMutex
+	notifyCh chan struct{}
+}
+
+// inDir checks if a key is under the watched directory
+func (w *watcher) inDir(key string) bool {
+	return key == w.dir || strings.HasPrefix(key, w.dir+"/")
+}
+
+// notifyWatchers sends the changed keys to the watchers of their directory.
+// It is called with the store locked, so changes are queued in order.
+func (db *DB) notifyWatchers(changes ...change) {
+	db.watchMutex.Lock()
+	defer db.watchMutex.Unlock()
+
+	for w := range db.watchers {
+		notify := false
+		for _, c := range changes {
+			if w.inDir(c.key) {
+				w.pending = append(w.pending, c)
+				notify = true
+			}
+		}
+		if !notify {
+			continue
+		}
+
+		select {
+		case w.notifyCh <- struct{}{}:
+		default:
+			// already notified
+		}
+	}
+}
+
+// Watch watches the keys under a directory until stopCh is signalled. It
+// returns the current keys, and a channel that gets all changes made after
+// they were read, which is closed when the watch stops. Keys whose ttl runs
+// out are seen as deleted. Writes that dont change the value of a key are
+// not reported.
+func (db *DB) Watch(dir string, stopCh <-chan bool) ([]*KeyValue, <-chan *Event, error) {
+	dir = strings.TrimSuffix(normalizeKey(dir), "/")
+
+	w := &watcher{
+		dir:      dir,
+		keys:     make(map[string]*KeyValue),
+		notifyCh: make(chan struct{}, 1),
+	}
+
+	// writers in this process are held off, so that the watcher gets all
+	// writes made after the keys are read
+	db.mutex.Lock()
+	kvs, err := db.List(dir)
+	if err != nil {
+		db.mutex.Unlock()
+		return nil, nil, err
+	}
+	for _, kv := range kvs {
+		w.keys[kv.Key] = kv
+	}
+
+	db.watchMutex.Lock()
+	db.watchers[w] = true
+	db.watchMutex.Unlock()
+	db.mutex.Unlock()
+
+	eventCh := make(chan *Event, 1)
+	go db.runWatch(w, eventCh, stopCh)
+
+	return kvs, eventCh, nil
+}
+
+func (db *DB) runWatch(w *watcher, eventCh chan *Event, stopCh <-chan bool) {
+	defer func() {
+		db.watchMutex.Lock()
+		delete(db.watchers, w)
+		db.watchMutex.Unlock()
+
+		close(eventCh)
+	}()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		var events []*Event
+		select {
+		case <-stopCh:
+			return
+		case <-w.notifyCh:
+			events = db.pendingChanges(w)
+		case <-ticker.C:
+			events = db.changes(w)
+		}
+
+		for _, ev := range events {
+			select {
+			case eventCh <- ev:
+			case <-stopCh:
+				return
+			}
+		}
+	}
+}
+
+// pendingChanges returns the events of the writes sent to the watcher
+func (db *DB) pendingChanges(w *watcher) []*Event {
+	db.watchMutex.Lock()
+	changes := w.pending
+	w.pending = nil
+	db.watchMutex.Unlock()
+
+	events := []*Event{}
+	for _, c := range changes {
+		prev := w.keys[c.key]
+		if c.kv == nil {
+			if prev != nil {
+				events = append(events, &Event{Type: EventDelete, Kv: &KeyValue{Key: c.key}, PrevKv: prev})
+				delete(w.keys, c.key)
+			}
+			continue
+		}
+
+		// ttl refreshes dont change the value, and are not reported
+		if prev == nil || prev.Value != c.kv.Value {
+			events = append(events, &Event{Type: EventPut, Kv: c.kv, PrevKv: prev})
+		}
+		w.keys[c.key] = c.kv
+	}
+
+	return events
+}
+
+// changes lists the directory again, and returns the differences to the
+// keys the watcher has seen. This finds the changes made by other
+// processes, and keys that expired.
+func (db *DB) changes(w *watcher) []*Event {
+	// take the writes of this process first, and hold off new ones
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	events := db.pendingChanges(w)
+
+	kvs, err := db.List(w.dir)
+	if err != nil {
+		log.Errorf("Error listing %s for watch. Err: %v", w.dir, err)
+		return events
+	}
+
+	keys := make(map[string]*KeyValue)
+	for _, kv := range kvs {
+		keys[kv.Key] = kv
+
+		prev := w.keys[kv.Key]
+		// ttl refreshes dont change the value, and are not reported
+		if prev == nil || prev.Value != kv.Value {
+			events = append(events, &Event{Type: EventPut, Kv: kv, PrevKv: prev})
+		}
+	}
+
+	for key, prev := range w.keys {
+		if keys[key] == nil {
+			events = append(events, &Event{Type: EventDelete, Kv: &KeyValue{Key: key}, PrevKv: prev})
+		}
+	}
+
+	w.keys = keys
+	return events
+}
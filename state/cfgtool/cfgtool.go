@@ -27,6 +27,9 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/contiv/netplugin/core"
 	"github.com/contiv/netplugin/drivers"
+	"github.com/contiv/netplugin/drivers/linuxd"
+	"github.com/contiv/netplugin/drivers/ovsd"
+	"github.com/contiv/netplugin/drivers/vppd"
 	"github.com/contiv/netplugin/netmaster/docknet"
 	"github.com/contiv/netplugin/netmaster/gstate"
 	"github.com/contiv/netplugin/netmaster/mastercfg"
@@ -46,7 +49,9 @@ func initStateDriver(clusterStore string) (core.StateDriver, error) {
 	// Make sure we support the statestore type
 	switch stateStore {
 	case utils.EtcdNameStr:
+	case utils.Etcd3NameStr:
 	case utils.ConsulNameStr:
+	case utils.FileNameStr:
 	default:
 		return nil, core.Errorf("Unsupported state-store %q", stateStore)
 	}
@@ -192,7 +197,9 @@ func processState(stateDriver core.StateDriver, stateName, stateID, fieldName, s
 	typeRegistry[reflect.TypeOf(resources.AutoVLANOperResource{}).Name()] = &resources.AutoVLANOperResource{}
 	typeRegistry[reflect.TypeOf(resources.AutoVXLANCfgResource{}).Name()] = &resources.AutoVXLANCfgResource{}
 	typeRegistry[reflect.TypeOf(resources.AutoVXLANOperResource{}).Name()] = &resources.AutoVXLANOperResource{}
-	typeRegistry[reflect.TypeOf(drivers.OvsDriverOperState{}).Name()] = &drivers.OvsDriverOperState{}
+	typeRegistry[reflect.TypeOf(ovsd.OvsDriverOperState{}).Name()] = &ovsd.OvsDriverOperState{}
+	typeRegistry[reflect.TypeOf(vppd.VppDriverOperState{}).Name()] = &vppd.VppDriverOperState{}
+	typeRegistry[reflect.TypeOf(linuxd.LinuxDriverOperState{}).Name()] = &linuxd.LinuxDriverOperState{}
 	typeRegistry[reflect.TypeOf(drivers.OperEndpointState{}).Name()] = &drivers.OperEndpointState{}
 	typeRegistry[reflect.TypeOf(docknet.DnetOperState{}).Name()] = &docknet.DnetOperState{}
 
@@ -298,6 +305,46 @@ func processState(stateDriver core.StateDriver, stateName, stateID, fieldName, s
 	return nil
 }
 
+// processMigrate handles `-migrate` command
+func processMigrate(stateDriver core.StateDriver, dryRun bool) error {
+	var statuses []core.SchemaStatus
+	var err error
+
+	if dryRun {
+		statuses, err = core.GetSchemaStatus(stateDriver)
+	} else {
+		statuses, err = core.MigrateStates(stateDriver)
+	}
+	if err != nil {
+		return err
+	}
+
+	numOutdated := 0
+	for _, status := range statuses {
+		if status.NumOutdated == 0 {
+			continue
+		}
+		numOutdated += status.NumOutdated
+
+		fmt.Printf("%s: %d of %d records to schema version %d\n",
+			status.KeyPrefix, status.NumOutdated, status.NumRecords, status.Version)
+		for _, m := range status.Pending {
+			fmt.Printf("	version %d: %s\n", m.Version, m.Description)
+		}
+	}
+
+	switch {
+	case numOutdated == 0:
+		fmt.Printf("All records are at the current schema versions\n")
+	case dryRun:
+		fmt.Printf("%d records need to be migrated\n", numOutdated)
+	default:
+		fmt.Printf("Migrated %d records\n", numOutdated)
+	}
+
+	return nil
+}
+
 func main() {
 	var rsrcName string
 	var clusterStore string
@@ -305,6 +352,9 @@ func main() {
 	var stateName string
 	var stateID string
 	var fieldName string
+	var etcd3URL string
+	var migrate bool
+	var dryRun bool
 
 	// parse all commandline args
 	flagSet := flag.NewFlagSet("cfgtool", flag.ExitOnError)
@@ -316,6 +366,10 @@ func main() {
 		fmt.Fprintf(os.Stderr, "	%s -state GlobConfig -id global -field FwdMode -set routing\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "%s -resource <vlan|vxlan> -set <new-range>\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "	%s -resource vlan -set 1-10\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "%s -cluster-store <etcd-url> -migrate-etcd3 <etcd3-url>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "	%s -cluster-store etcd://127.0.0.1:2379 -migrate-etcd3 http://127.0.0.1:2379\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "%s -migrate [-dry-run]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "	%s -cluster-store etcd://127.0.0.1:2379 -migrate -dry-run\n", os.Args[0])
 	}
 
 	flagSet.StringVar(&rsrcName,
@@ -342,13 +396,34 @@ func main() {
 		"field",
 		"",
 		"State Field to modify")
+	flagSet.StringVar(&etcd3URL,
+		"migrate-etcd3",
+		"",
+		"Copy the etcd v2 keys of the cluster store to this etcd v3 url")
+	flagSet.BoolVar(&migrate,
+		"migrate",
+		false,
+		"Migrate the state in the cluster store to the current schema versions")
+	flagSet.BoolVar(&dryRun,
+		"dry-run",
+		false,
+		"Only show the records -migrate would upgrade")
 	if err := flagSet.Parse(os.Args[1:]); err != nil {
 		log.Errorf("Error parsing commandline args: %v", err)
 		return
 	}
 
+	// handle `-migrate-etcd3` command
+	if etcd3URL != "" {
+		if err := migrateEtcd3(clusterStore, etcd3URL); err != nil {
+			log.Fatalf("Error migrating to etcd v3. Err: %v", err)
+		}
+
+		return
+	}
+
 	// check if we have sufficient args
-	if (rsrcName == "" && stateName == "") ||
+	if (rsrcName == "" && stateName == "" && !migrate) ||
 		(stateName != "" && stateID == "") ||
 		(stateName != "" && stateID != "" && setVal != "" && fieldName == "") {
 		flagSet.Usage()
@@ -361,6 +436,15 @@ func main() {
 		log.Fatalf("Failed to init state-store. Error: %s", err)
 	}
 
+	// handle `-migrate` command
+	if migrate {
+		if err := processMigrate(stateDriver, dryRun); err != nil {
+			log.Fatalf("Error migrating state. Err: %v", err)
+		}
+
+		return
+	}
+
 	// Initialize resource manager
 	resmgr, err := resources.NewStateResourceManager(stateDriver)
 	if err != nil || resmgr == nil {
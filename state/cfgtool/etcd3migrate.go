@@ -0,0 +1,112 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/objdb/etcd3"
+	"github.com/contiv/netplugin/state"
+	"github.com/coreos/etcd/client"
+)
+
+// root of the contiv keyspace
+const contivKeyRoot = "/contiv.io"
+
+// keys that are recreated by the running services, and are tied to v2 ttls
+var etcd3SkippedKeys = []string{
+	contivKeyRoot + "/service/",
+	contivKeyRoot + "/lock/",
+}
+
+// migrateEtcd3 copies the contiv keys of an etcd v2 cluster store to an
+// etcd v3 cluster. netmaster and netplugin should be stopped while the
+// keys are copied.
+func migrateEtcd3(clusterStore, etcd3URL string) error {
+	if !strings.HasPrefix(clusterStore, "etcd://") {
+		return core.Errorf("Cluster store %q is not an etcd v2 store", clusterStore)
+	}
+
+	v2Driver := &state.EtcdStateDriver{}
+	if err := v2Driver.Init(&core.InstanceInfo{DbURL: clusterStore}); err != nil {
+		log.Errorf("Error connecting to etcd v2 store %s. Err: %v", clusterStore, err)
+		return err
+	}
+
+	v3Client, err := etcd3.New([]string{etcd3URL})
+	if err != nil {
+		log.Errorf("Error connecting to etcd v3 store %s. Err: %v", etcd3URL, err)
+		return err
+	}
+
+	resp, err := v2Driver.KeysAPI.Get(context.Background(), contivKeyRoot,
+		&client.GetOptions{Recursive: true, Sort: true, Quorum: true})
+	if err != nil {
+		if client.IsKeyNotFound(err) {
+			fmt.Printf("No keys found under %s\n", contivKeyRoot)
+			return nil
+		}
+		log.Errorf("Error reading keys from %s. Err: %v", clusterStore, err)
+		return err
+	}
+
+	numCopied, numSkipped := 0, 0
+	err = walkEtcdNodes(resp.Node, func(node *client.Node) error {
+		for _, prefix := range etcd3SkippedKeys {
+			if strings.HasPrefix(node.Key, prefix) {
+				numSkipped++
+				return nil
+			}
+		}
+
+		if err := v3Client.Put(node.Key, node.Value, 0); err != nil {
+			log.Errorf("Error writing key %s. Err: %v", node.Key, err)
+			return err
+		}
+
+		log.Debugf("Copied key %s", node.Key)
+		numCopied++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Copied %d keys from %s to %s, skipped %d service and lock keys\n",
+		numCopied, clusterStore, etcd3URL, numSkipped)
+
+	return nil
+}
+
+// walkEtcdNodes calls fn for all keys under a v2 directory
+func walkEtcdNodes(node *client.Node, fn func(*client.Node) error) error {
+	if !node.Dir {
+		return fn(node)
+	}
+
+	for _, innerNode := range node.Nodes {
+		if err := walkEtcdNodes(innerNode, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -167,6 +167,7 @@ func (d *ConsulStateDriver) ReadAll(baseKey string) ([][]byte, error) {
 
 func (d *ConsulStateDriver) channelConsulEvents(baseKey string, kvCache map[string]*api.KVPair,
 	consulRsps chan api.KVPairs, rsps chan [2][]byte, retErr chan error, stop chan bool) {
+	synced := false
 	for {
 		select {
 		// block on change notifications
@@ -210,6 +211,13 @@ func (d *ConsulStateDriver) channelConsulEvents(baseKey string, kvCache map[stri
 				}
 			}
 
+			// the first keys received are the existing ones, tell the
+			// watcher it has seen them all
+			if !synced {
+				rsps <- [2][]byte{nil, nil}
+				synced = true
+			}
+
 		case <-stop:
 			log.Infof("Stop request received")
 			return
@@ -217,7 +225,9 @@ func (d *ConsulStateDriver) channelConsulEvents(baseKey string, kvCache map[stri
 	}
 }
 
-// WatchAll state transitions from baseKey
+// WatchAll state transitions from baseKey. The keys under baseKey are sent
+// first as creates, followed by an empty response, and then the changes
+// made after they were read.
 func (d *ConsulStateDriver) WatchAll(baseKey string, rsps chan [2][]byte) error {
 	baseKey = processKey(baseKey)
 	consulRsps := make(chan api.KVPairs, 1)
@@ -226,7 +236,8 @@ func (d *ConsulStateDriver) WatchAll(baseKey string, rsps chan [2][]byte) error
 
 	// Consul returns all the keys as return value of List(). The following maps helps
 	// track the state that has been seen and used to appropriately generate
-	// create, modify and delete events
+	// create, modify and delete events. It starts empty, so that the existing
+	// keys are sent as creates.
 	kvCache := map[string]*api.KVPair{}
 	// read with index=0 to fetch all existing keys
 	var waitIndex uint64
@@ -241,12 +252,10 @@ func (d *ConsulStateDriver) WatchAll(baseKey string, rsps chan [2][]byte) error
 	if kvs == nil {
 		kvs = api.KVPairs{}
 	}
-	for _, kv := range kvs {
-		kvCache[kv.Key] = kv
-	}
 	waitIndex = qm.LastIndex
 
 	go d.channelConsulEvents(baseKey, kvCache, consulRsps, rsps, recvErr, stop)
+	consulRsps <- kvs
 
 	for {
 		select {
@@ -289,30 +298,27 @@ func (d *ConsulStateDriver) ClearState(key string) error {
 // ReadState reads key into a core.State with the unmarshaling function.
 func (d *ConsulStateDriver) ReadState(key string, value core.State,
 	unmarshal func([]byte, interface{}) error) error {
-	key = processKey(key)
 	encodedState, err := d.Read(key)
 	if err != nil {
 		return err
 	}
 
-	return unmarshal(encodedState, value)
+	return core.DecodeState(key, encodedState, value, unmarshal)
 }
 
 // ReadAllState Reads all the state from baseKey and returns a list of core.State.
 func (d *ConsulStateDriver) ReadAllState(baseKey string, sType core.State,
 	unmarshal func([]byte, interface{}) error) ([]core.State, error) {
-	baseKey = processKey(baseKey)
 	return readAllStateCommon(d, baseKey, sType, unmarshal)
 }
 
 // WatchAllState watches all state from the baseKey.
 func (d *ConsulStateDriver) WatchAllState(baseKey string, sType core.State,
 	unmarshal func([]byte, interface{}) error, rsps chan core.WatchState) error {
-	baseKey = processKey(baseKey)
 	byteRsps := make(chan [2][]byte, 1)
 	recvErr := make(chan error, 1)
 
-	go channelStateEvents(d, sType, unmarshal, byteRsps, rsps, recvErr)
+	go channelStateEvents(d, baseKey, sType, unmarshal, byteRsps, rsps, recvErr)
 
 	err := d.WatchAll(baseKey, byteRsps)
 	if err != nil {
@@ -327,11 +333,132 @@ func (d *ConsulStateDriver) WatchAllState(baseKey string, sType core.State,
 // WriteState writes a value of core.State into a key with a given marshaling function.
 func (d *ConsulStateDriver) WriteState(key string, value core.State,
 	marshal func(interface{}) ([]byte, error)) error {
-	key = processKey(key)
-	encodedState, err := marshal(value)
+	encodedState, err := core.EncodeState(key, value, marshal)
 	if err != nil {
 		return err
 	}
 
 	return d.Write(key, encodedState)
 }
+
+// isConsulRetryable checks if a request failed on a connection problem
+func isConsulRetryable(err error) bool {
+	return api.IsServerError(err) || strings.Contains(err.Error(), "EOF") ||
+		strings.Contains(err.Error(), "connection refused")
+}
+
+// ReadVersion reads a key and its consul modify index.
+func (d *ConsulStateDriver) ReadVersion(key string) ([]byte, uint64, error) {
+	key = processKey(key)
+
+	var err error
+	var kv *api.KVPair
+
+	for i := 0; i < maxConsulRetries; i++ {
+		kv, _, err = d.Client.KV().Get(key, nil)
+		if err != nil {
+			if isConsulRetryable(err) {
+				time.Sleep(time.Second)
+				continue
+			}
+
+			return []byte{}, 0, err
+		}
+
+		if kv == nil {
+			return []byte{}, 0, core.Errorf("key not found")
+		}
+
+		return kv.Value, kv.ModifyIndex, nil
+	}
+
+	return []byte{}, 0, err
+}
+
+// WriteCAS writes key if it is still at the modify index version. Consul
+// check-and-set writes with an index of 0 only create keys, like WriteCAS.
+func (d *ConsulStateDriver) WriteCAS(key string, value []byte, version uint64) error {
+	key = processKey(key)
+
+	var err error
+	var written bool
+
+	for i := 0; i < maxConsulRetries; i++ {
+		written, _, err = d.Client.KV().CAS(&api.KVPair{Key: key, Value: value, ModifyIndex: version}, nil)
+		if err != nil && isConsulRetryable(err) {
+			// Retry after a delay
+			time.Sleep(time.Second)
+			continue
+		}
+		if err == nil && !written {
+			return core.ErrStateChanged
+		}
+
+		return err
+	}
+
+	return err
+}
+
+// consulTxnOp is an operation of the consul transaction api, which the
+// vendored api client doesnt have yet
+type consulTxnOp struct {
+	KV *consulTxnKVOp
+}
+
+type consulTxnKVOp struct {
+	Verb  string
+	Key   string
+	Value []byte
+	Index uint64
+}
+
+// Commit applies the ops in a consul transaction, as check-and-set writes
+// and deletes. Consul rejects the whole transaction if any index is stale.
+// Transactions need consul 0.7 or later.
+func (d *ConsulStateDriver) Commit(ops []core.StateOp) error {
+	txnOps := []*consulTxnOp{}
+	for _, op := range ops {
+		kvOp := &consulTxnKVOp{Verb: "cas", Key: processKey(op.Key), Value: op.Value, Index: op.Version}
+		if op.Value == nil {
+			kvOp.Verb = "delete-cas"
+		}
+		txnOps = append(txnOps, &consulTxnOp{KV: kvOp})
+	}
+
+	var err error
+
+	for i := 0; i < maxConsulRetries; i++ {
+		_, err = d.Client.Raw().Write("/v1/txn", txnOps, nil, nil)
+		if err == nil {
+			return nil
+		}
+
+		// a rolled back transaction is reported as a conflict
+		if strings.Contains(err.Error(), "Unexpected response code: 409") {
+			return core.ErrStateChanged
+		}
+
+		if isConsulRetryable(err) {
+			// Retry after a delay
+			time.Sleep(time.Second)
+			continue
+		}
+
+		return err
+	}
+
+	return err
+}
+
+// ReadStateVersion reads key into a core.State, and returns its version.
+func (d *ConsulStateDriver) ReadStateVersion(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	return readStateVersionCommon(d, key, value, unmarshal)
+}
+
+// WriteStateCAS writes a core.State into key if it is still at version.
+func (d *ConsulStateDriver) WriteStateCAS(key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return writeStateCASCommon(d, key, value, version, marshal)
+}
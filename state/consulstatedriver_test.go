@@ -98,3 +98,23 @@ func TestConsulStateDriverWatchAllStateDelete(t *testing.T) {
 	driver := setupConsulDriver(t)
 	commonTestStateDriverWatchAllStateDelete(t, driver)
 }
+
+func TestConsulStateDriverWatchAllStateExisting(t *testing.T) {
+	driver := setupConsulDriver(t)
+	commonTestStateDriverWatchAllStateExisting(t, driver)
+}
+
+func TestConsulStateDriverWriteCAS(t *testing.T) {
+	driver := setupConsulDriver(t)
+	commonTestStateDriverWriteCAS(t, driver)
+}
+
+func TestConsulStateDriverCommit(t *testing.T) {
+	driver := setupConsulDriver(t)
+	commonTestStateDriverCommit(t, driver)
+}
+
+func TestConsulStateDriverUpdateStates(t *testing.T) {
+	driver := setupConsulDriver(t)
+	commonTestStateDriverUpdateStates(t, driver)
+}
@@ -0,0 +1,404 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/objdb/etcd3"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Etcd3StateDriverConfig encapsulates the etcd v3 endpoints used to
+// communicate with it.
+type Etcd3StateDriverConfig struct {
+	Etcd struct {
+		Machines []string
+	}
+}
+
+// Etcd3StateDriver implements the StateDriver interface for the etcd v3 api
+type Etcd3StateDriver struct {
+	Client *etcd3.Client
+}
+
+// Init the driver with a core.Config.
+func (d *Etcd3StateDriver) Init(instInfo *core.InstanceInfo) error {
+	var err error
+
+	if instInfo == nil || instInfo.DbURL == "" {
+		return errors.New("no etcd config found")
+	}
+
+	// TODO: support multi-endpoints
+	d.Client, err = etcd3.New([]string{instInfo.DbURL})
+	if err != nil {
+		log.Errorf("error creating etcd v3 client. Err: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// Deinit is currently a no-op.
+func (d *Etcd3StateDriver) Deinit() {}
+
+// Write state to key with value.
+func (d *Etcd3StateDriver) Write(key string, value []byte) error {
+	var err error
+
+	for i := 0; i < maxEtcdRetries; i++ {
+		err = d.Client.Put(key, string(value[:]), 0)
+		if err == etcd3.ErrClusterUnavailable {
+			// Retry after a delay
+			time.Sleep(time.Second)
+			continue
+		}
+
+		return err
+	}
+
+	return err
+}
+
+// Read state from key.
+func (d *Etcd3StateDriver) Read(key string) ([]byte, error) {
+	var err error
+	var kv *etcd3.KeyValue
+
+	for i := 0; i < maxEtcdRetries; i++ {
+		kv, _, err = d.Client.Get(key)
+		if err == nil {
+			return []byte(kv.Value), nil
+		}
+
+		if err == etcd3.ErrKeyNotFound {
+			return []byte{}, core.Errorf("key not found")
+		}
+
+		if err == etcd3.ErrClusterUnavailable {
+			// Retry after a delay
+			time.Sleep(time.Second)
+			continue
+		}
+
+		return []byte{}, err
+	}
+
+	return []byte{}, err
+}
+
+// dirPrefix returns the prefix of the keys in a directory
+func dirPrefix(baseKey string) string {
+	if strings.HasSuffix(baseKey, "/") {
+		return baseKey
+	}
+
+	return baseKey + "/"
+}
+
+// isDirEntry checks if a key is directly in a directory, like the nodes
+// of a v2 directory
+func isDirEntry(prefix, key string) bool {
+	return strings.HasPrefix(key, prefix) && !strings.Contains(key[len(prefix):], "/")
+}
+
+// ReadAll state from baseKey.
+func (d *Etcd3StateDriver) ReadAll(baseKey string) ([][]byte, error) {
+	var err error
+	var kvs []*etcd3.KeyValue
+	prefix := dirPrefix(baseKey)
+
+	for i := 0; i < maxEtcdRetries; i++ {
+		kvs, _, err = d.Client.GetPrefix(prefix)
+		if err == etcd3.ErrClusterUnavailable {
+			// Retry after a delay
+			time.Sleep(time.Second)
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return [][]byte{}, err
+	}
+
+	values := [][]byte{}
+	for _, kv := range kvs {
+		if isDirEntry(prefix, kv.Key) {
+			values = append(values, []byte(kv.Value))
+		}
+	}
+
+	return values, nil
+}
+
+// readAllKeys reads all keys under a prefix, and the revision they were
+// read at
+func (d *Etcd3StateDriver) readAllKeys(prefix string) (watchCache, int64, error) {
+	var err error
+	var kvs []*etcd3.KeyValue
+	var rev int64
+
+	for i := 0; i < maxEtcdRetries; i++ {
+		kvs, rev, err = d.Client.GetPrefix(prefix)
+		if err == etcd3.ErrClusterUnavailable {
+			// Retry after a delay
+			time.Sleep(time.Second)
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	values := watchCache{}
+	for _, kv := range kvs {
+		values[kv.Key] = []byte(kv.Value)
+	}
+
+	return values, rev, nil
+}
+
+// channelEtcd3Events translates v3 watch events to the current and previous
+// values WatchAll returns. It returns when the watch stops.
+func channelEtcd3Events(watchCh <-chan etcd3.WatchResponse, sent watchCache, rsps chan [2][]byte) {
+	for watchResp := range watchCh {
+		if watchResp.Err != nil {
+			log.Errorf("Error %v during watch", watchResp.Err)
+			continue
+		}
+
+		for _, ev := range watchResp.Events {
+			rsp := [2][]byte{nil, nil}
+			eventStr := "create"
+			if ev.Type == etcd3.EventPut {
+				rsp[0] = []byte(ev.Kv.Value)
+			}
+			if ev.PrevKv != nil {
+				rsp[1] = []byte(ev.PrevKv.Value)
+				if ev.Type == etcd3.EventPut {
+					eventStr = "modify"
+				} else {
+					eventStr = "delete"
+				}
+			}
+			if rsp[0] == nil && rsp[1] == nil {
+				// dont mistake it for the end of the existing state
+				continue
+			}
+
+			log.Debugf("Received %q for key: %s at revision %d", eventStr, ev.Kv.Key, watchResp.Revision)
+			sent.update(ev.Kv.Key, rsp[0])
+			//channel the translated response
+			rsps <- rsp
+		}
+	}
+}
+
+// WatchAll state transitions from baseKey. The keys under baseKey are sent
+// first as creates, followed by an empty response, and then the changes
+// made after the revision they were read at. The watch resumes from the
+// last revision seen when the connection to etcd is lost, so that no
+// changes are missed.
+func (d *Etcd3StateDriver) WatchAll(baseKey string, rsps chan [2][]byte) error {
+	prefix := dirPrefix(baseKey)
+
+	kvs, rev, err := d.readAllKeys(prefix)
+	if err != nil {
+		log.Errorf("etcd watch failed. Err: %v", err)
+		return err
+	}
+
+	go d.watchAll(prefix, kvs, rev, rsps)
+
+	return nil
+}
+
+// watchAll runs a watch, and resyncs it if the revision to resume from was
+// compacted
+func (d *Etcd3StateDriver) watchAll(prefix string, sent watchCache, rev int64, rsps chan [2][]byte) {
+	sent.sendSnapshot(rsps)
+
+	for {
+		watchCh := d.Client.Watch(context.Background(), prefix, true, rev+1)
+		channelEtcd3Events(watchCh, sent, rsps)
+
+		// read all keys again, and send what changed meanwhile
+		log.Warnf("Watch on %s lost changes after a compaction, resyncing it", prefix)
+		for {
+			kvs, newRev, err := d.readAllKeys(prefix)
+			if err == nil {
+				sent.resync(kvs, rsps)
+				rev = newRev
+				break
+			}
+
+			log.Errorf("Error %v resyncing watch", err)
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// ClearState removes key from etcd
+func (d *Etcd3StateDriver) ClearState(key string) error {
+	_, err := d.Client.Delete(key)
+	if err == etcd3.ErrKeyNotFound {
+		return core.Errorf("key not found")
+	}
+
+	return err
+}
+
+// ReadState reads key into a core.State with the unmarshaling function.
+func (d *Etcd3StateDriver) ReadState(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) error {
+	encodedState, err := d.Read(key)
+	if err != nil {
+		return err
+	}
+
+	return core.DecodeState(key, encodedState, value, unmarshal)
+}
+
+// ReadAllState Reads all the state from baseKey and returns a list of core.State.
+func (d *Etcd3StateDriver) ReadAllState(baseKey string, sType core.State,
+	unmarshal func([]byte, interface{}) error) ([]core.State, error) {
+	return readAllStateCommon(d, baseKey, sType, unmarshal)
+}
+
+// WatchAllState watches all state from the baseKey.
+func (d *Etcd3StateDriver) WatchAllState(baseKey string, sType core.State,
+	unmarshal func([]byte, interface{}) error, rsps chan core.WatchState) error {
+	byteRsps := make(chan [2][]byte, 1)
+	recvErr := make(chan error, 1)
+
+	err := d.WatchAll(baseKey, byteRsps)
+	if err != nil {
+		log.Errorf("WatchAll returned %v", err)
+		return err
+	}
+
+	for {
+		go channelStateEvents(d, baseKey, sType, unmarshal, byteRsps, rsps, recvErr)
+
+		err = <-recvErr
+		log.Errorf("Err from channelStateEvents %v", err)
+		time.Sleep(time.Second)
+	}
+}
+
+// WriteState writes a value of core.State into a key with a given marshaling function.
+func (d *Etcd3StateDriver) WriteState(key string, value core.State,
+	marshal func(interface{}) ([]byte, error)) error {
+	encodedState, err := core.EncodeState(key, value, marshal)
+	if err != nil {
+		return err
+	}
+
+	return d.Write(key, encodedState)
+}
+
+// ReadVersion reads a key and its mod revision.
+func (d *Etcd3StateDriver) ReadVersion(key string) ([]byte, uint64, error) {
+	var err error
+	var kv *etcd3.KeyValue
+
+	for i := 0; i < maxEtcdRetries; i++ {
+		kv, _, err = d.Client.Get(key)
+		if err == nil {
+			return []byte(kv.Value), uint64(kv.ModRevision), nil
+		}
+
+		if err == etcd3.ErrKeyNotFound {
+			return []byte{}, 0, core.Errorf("key not found")
+		}
+
+		if err == etcd3.ErrClusterUnavailable {
+			// Retry after a delay
+			time.Sleep(time.Second)
+			continue
+		}
+
+		return []byte{}, 0, err
+	}
+
+	return []byte{}, 0, err
+}
+
+// WriteCAS writes key if it is still at the mod revision version.
+func (d *Etcd3StateDriver) WriteCAS(key string, value []byte, version uint64) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	return d.Commit([]core.StateOp{{Key: key, Value: value, Version: version}})
+}
+
+// Commit applies the ops in a transaction that compares the mod revision
+// of every key. Missing keys have a mod revision of 0.
+func (d *Etcd3StateDriver) Commit(ops []core.StateOp) error {
+	cmps := []etcd3.Compare{}
+	txnOps := []etcd3.Op{}
+	for _, op := range ops {
+		cmps = append(cmps, etcd3.Compare{Key: op.Key, Target: etcd3.CompareMod,
+			ModRevision: int64(op.Version)})
+		if op.Value == nil {
+			txnOps = append(txnOps, etcd3.Op{Type: etcd3.OpDelete, Key: op.Key})
+		} else {
+			txnOps = append(txnOps, etcd3.Op{Type: etcd3.OpPut, Key: op.Key, Value: string(op.Value)})
+		}
+	}
+
+	var err error
+	var resp *etcd3.TxnResponse
+
+	for i := 0; i < maxEtcdRetries; i++ {
+		resp, err = d.Client.Txn(cmps, txnOps, nil)
+		if err == etcd3.ErrClusterUnavailable {
+			// Retry after a delay
+			time.Sleep(time.Second)
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return core.ErrStateChanged
+	}
+
+	return nil
+}
+
+// ReadStateVersion reads key into a core.State, and returns its version.
+func (d *Etcd3StateDriver) ReadStateVersion(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	return readStateVersionCommon(d, key, value, unmarshal)
+}
+
+// WriteStateCAS writes a core.State into key if it is still at version.
+func (d *Etcd3StateDriver) WriteStateCAS(key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return writeStateCASCommon(d, key, value, version, marshal)
+}
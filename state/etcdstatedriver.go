@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
 	"reflect"
 	"time"
 
@@ -33,6 +34,11 @@ import (
 const (
 	ctxTimeout     = 20 * time.Second // etcd timeout
 	maxEtcdRetries = 10               // Max times to retry in case of failure
+
+	commitLockKey        = "/contiv.io/lock/statecommit" // lock of the multi-key commits
+	commitLockTTL        = time.Minute                   // expiry of the commit lock
+	commitLockRetryDelay = 100 * time.Millisecond        // wait for a running commit
+	maxCommitLockTries   = 300                           // Max times to try the commit lock
 )
 
 // EtcdStateDriverConfig encapsulates the etcd endpoints used to communicate
@@ -176,13 +182,78 @@ func (d *EtcdStateDriver) ReadAll(baseKey string) ([][]byte, error) {
 	return [][]byte{}, err
 }
 
-func (d *EtcdStateDriver) channelEtcdEvents(watcher client.Watcher, rsps chan [2][]byte) {
+// readAllNodes reads all keys under baseKey, and the etcd index they were
+// read at
+func (d *EtcdStateDriver) readAllNodes(baseKey string) (watchCache, uint64, error) {
+	var err error
+	var resp *client.Response
+	kvs := watchCache{}
+
+	for i := 0; i < maxEtcdRetries; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+		resp, err = d.KeysAPI.Get(ctx, baseKey, &client.GetOptions{Recursive: true, Quorum: true})
+		cancel()
+		if err == nil {
+			addEtcdNodes(resp.Node, kvs)
+			return kvs, resp.Index, nil
+		}
+
+		// nothing to read yet, watch from the index of the error
+		if etcdErr, ok := err.(client.Error); ok && etcdErr.Code == client.ErrorCodeKeyNotFound {
+			return kvs, etcdErr.Index, nil
+		}
+
+		if err.Error() == client.ErrClusterUnavailable.Error() {
+			// Retry after a delay
+			time.Sleep(time.Second)
+			continue
+		}
+
+		return nil, 0, err
+	}
+
+	return nil, 0, err
+}
+
+// addEtcdNodes adds the keys in a directory and its subdirectories
+func addEtcdNodes(node *client.Node, kvs watchCache) {
+	if !node.Dir {
+		kvs[node.Key] = []byte(node.Value)
+		return
+	}
+
+	for _, innerNode := range node.Nodes {
+		addEtcdNodes(innerNode, kvs)
+	}
+}
+
+func (d *EtcdStateDriver) channelEtcdEvents(baseKey string, sent watchCache, index uint64, rsps chan [2][]byte) {
+	sent.sendSnapshot(rsps)
+
+	watcher := d.KeysAPI.Watcher(baseKey, &client.WatcherOptions{AfterIndex: index, Recursive: true})
 	for {
 		// block on change notifications
 		etcdRsp, err := watcher.Next(context.Background())
 		if err != nil {
-			log.Errorf("Error %v during watch", err)
-			time.Sleep(time.Second)
+			etcdErr, ok := err.(client.Error)
+			if !ok || etcdErr.Code != client.ErrorCodeEventIndexCleared {
+				log.Errorf("Error %v during watch", err)
+				time.Sleep(time.Second)
+				continue
+			}
+
+			// etcd doesnt have the changes since our index anymore. Read
+			// all keys again, and send what changed meanwhile
+			log.Warnf("Watch on %s lost changes, resyncing it", baseKey)
+			kvs, newIndex, err := d.readAllNodes(baseKey)
+			if err != nil {
+				log.Errorf("Error %v resyncing watch", err)
+				time.Sleep(time.Second)
+				continue
+			}
+
+			sent.resync(kvs, rsps)
+			watcher = d.KeysAPI.Watcher(baseKey, &client.WatcherOptions{AfterIndex: newIndex, Recursive: true})
 			continue
 		}
 
@@ -205,22 +276,30 @@ func (d *EtcdStateDriver) channelEtcdEvents(watcher client.Watcher, rsps chan [2
 				eventStr = "delete"
 			}
 		}
+		if rsp[0] == nil && rsp[1] == nil {
+			// eg. directory changes, dont mistake them for the end of the
+			// existing state
+			continue
+		}
 
 		log.Debugf("Received %q for key: %s", eventStr, etcdRsp.Node.Key)
+		sent.update(etcdRsp.Node.Key, rsp[0])
 		//channel the translated response
 		rsps <- rsp
 	}
 }
 
-// WatchAll state transitions from baseKey
+// WatchAll state transitions from baseKey. The keys under baseKey are sent
+// first as creates, followed by an empty response, and then the changes
+// made after they were read.
 func (d *EtcdStateDriver) WatchAll(baseKey string, rsps chan [2][]byte) error {
-	watcher := d.KeysAPI.Watcher(baseKey, &client.WatcherOptions{Recursive: true})
-	if watcher == nil {
-		log.Errorf("etcd watch failed.")
-		return errors.New("etcd watch failed")
+	kvs, index, err := d.readAllNodes(baseKey)
+	if err != nil {
+		log.Errorf("etcd watch failed. Err: %v", err)
+		return err
 	}
 
-	go d.channelEtcdEvents(watcher, rsps)
+	go d.channelEtcdEvents(baseKey, kvs, index, rsps)
 
 	return nil
 }
@@ -242,7 +321,31 @@ func (d *EtcdStateDriver) ReadState(key string, value core.State,
 		return err
 	}
 
-	return unmarshal(encodedState, value)
+	return core.DecodeState(key, encodedState, value, unmarshal)
+}
+
+// readStateVersionCommon reads key into a core.State with the unmarshaling
+// function, and returns the version of the key.
+func readStateVersionCommon(d core.StateDriver, key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	encodedState, version, err := d.ReadVersion(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, core.DecodeState(key, encodedState, value, unmarshal)
+}
+
+// writeStateCASCommon writes a core.State into key with the marshaling
+// function, if the key is still at version.
+func writeStateCASCommon(d core.StateDriver, key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	encodedState, err := core.EncodeState(key, value, marshal)
+	if err != nil {
+		return err
+	}
+
+	return d.WriteCAS(key, encodedState, version)
 }
 
 // readAllStateCommon reads and unmarshals (given a function) all state into a
@@ -260,7 +363,7 @@ func readAllStateCommon(d core.StateDriver, baseKey string, sType core.State,
 	}
 	for _, byteValue := range byteValues {
 		value := reflect.New(stateType)
-		err = unmarshal(byteValue, value.Interface())
+		err = core.DecodeState(baseKey, byteValue, value.Interface(), unmarshal)
 		if err != nil {
 			return nil, err
 		}
@@ -292,7 +395,7 @@ func (d *EtcdStateDriver) ReadAllState(baseKey string, sType core.State,
 // specified type and unmarshals (given a function) all changes and puts then on
 // channel of core.WatchState objects.
 // XXX: move this to some common file
-func channelStateEvents(d core.StateDriver, sType core.State,
+func channelStateEvents(d core.StateDriver, baseKey string, sType core.State,
 	unmarshal func([]byte, interface{}) error,
 	byteRsps chan [2][]byte, rsps chan core.WatchState, retErr chan error) {
 	for {
@@ -306,7 +409,7 @@ func channelStateEvents(d core.StateDriver, sType core.State,
 			}
 			stateType := reflect.TypeOf(sType)
 			value := reflect.New(stateType)
-			err := unmarshal(byteRsp[i], value.Interface())
+			err := core.DecodeState(baseKey, byteRsp[i], value.Interface(), unmarshal)
 			if err != nil {
 				log.Errorf("unmarshal error: %v", err)
 				retErr <- err
@@ -345,7 +448,7 @@ func (d *EtcdStateDriver) WatchAllState(baseKey string, sType core.State,
 	}
 
 	for {
-		go channelStateEvents(d, sType, unmarshal, byteRsps, rsps, recvErr)
+		go channelStateEvents(d, baseKey, sType, unmarshal, byteRsps, rsps, recvErr)
 
 		err = <-recvErr
 		log.Errorf("Err from channelStateEvents %v", err)
@@ -356,10 +459,232 @@ func (d *EtcdStateDriver) WatchAllState(baseKey string, sType core.State,
 // WriteState writes a value of core.State into a key with a given marshaling function.
 func (d *EtcdStateDriver) WriteState(key string, value core.State,
 	marshal func(interface{}) ([]byte, error)) error {
-	encodedState, err := marshal(value)
+	encodedState, err := core.EncodeState(key, value, marshal)
 	if err != nil {
 		return err
 	}
 
 	return d.Write(key, encodedState)
 }
+
+// ReadVersion reads a key and its etcd modified index.
+func (d *EtcdStateDriver) ReadVersion(key string) ([]byte, uint64, error) {
+	var err error
+	var resp *client.Response
+
+	for i := 0; i < maxEtcdRetries; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+		resp, err = d.KeysAPI.Get(ctx, key, &client.GetOptions{Quorum: true})
+		cancel()
+		if err == nil {
+			return []byte(resp.Node.Value), resp.Node.ModifiedIndex, nil
+		}
+
+		if client.IsKeyNotFound(err) {
+			return []byte{}, 0, core.Errorf("key not found")
+		}
+
+		if err.Error() == client.ErrClusterUnavailable.Error() {
+			// Retry after a delay
+			time.Sleep(time.Second)
+			continue
+		}
+
+		return []byte{}, 0, err
+	}
+
+	return []byte{}, 0, err
+}
+
+// isEtcdConflict checks if a conditional write or delete failed because
+// the key was changed
+func isEtcdConflict(err error) bool {
+	etcdErr, ok := err.(client.Error)
+	return ok && (etcdErr.Code == client.ErrorCodeTestFailed ||
+		etcdErr.Code == client.ErrorCodeNodeExist ||
+		etcdErr.Code == client.ErrorCodeKeyNotFound)
+}
+
+// casOp applies an op if its key is at the version of the op. It returns
+// the etcd response, which has the previous value of the key, or nil if
+// nothing was changed.
+func (d *EtcdStateDriver) casOp(op core.StateOp) (*client.Response, error) {
+	var err error
+	var resp *client.Response
+
+	for i := 0; i < maxEtcdRetries; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+		switch {
+		case op.Value == nil && op.Version == 0:
+			// the key must not exist, there is nothing to delete
+			_, err = d.KeysAPI.Get(ctx, op.Key, &client.GetOptions{Quorum: true})
+			if err == nil {
+				err = core.ErrStateChanged
+			} else if client.IsKeyNotFound(err) {
+				err = nil
+			}
+		case op.Value == nil:
+			resp, err = d.KeysAPI.Delete(ctx, op.Key, &client.DeleteOptions{PrevIndex: op.Version})
+		case op.Version == 0:
+			resp, err = d.KeysAPI.Set(ctx, op.Key, string(op.Value), &client.SetOptions{PrevExist: client.PrevNoExist})
+		default:
+			resp, err = d.KeysAPI.Set(ctx, op.Key, string(op.Value), &client.SetOptions{PrevIndex: op.Version})
+		}
+		cancel()
+
+		if err != nil && err.Error() == client.ErrClusterUnavailable.Error() {
+			// Retry after a delay
+			time.Sleep(time.Second)
+			continue
+		}
+		if isEtcdConflict(err) {
+			return nil, core.ErrStateChanged
+		}
+
+		return resp, err
+	}
+
+	return nil, err
+}
+
+// WriteCAS writes key if it is still at the etcd modified index version.
+func (d *EtcdStateDriver) WriteCAS(key string, value []byte, version uint64) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	_, err := d.casOp(core.StateOp{Key: key, Value: value, Version: version})
+	return err
+}
+
+// lockCommits takes the lock that serializes multi-key commits, and returns
+// the token to release it with. The lock expires, so that a netmaster
+// failing in a commit doesnt block the others.
+func (d *EtcdStateDriver) lockCommits() (string, error) {
+	var err error
+	token := fmt.Sprintf("%d.%d", os.Getpid(), time.Now().UnixNano())
+
+	for i := 0; i < maxCommitLockTries; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+		_, err = d.KeysAPI.Set(ctx, commitLockKey, token,
+			&client.SetOptions{PrevExist: client.PrevNoExist, TTL: commitLockTTL})
+		cancel()
+		if err == nil {
+			return token, nil
+		}
+
+		if !isEtcdConflict(err) && err.Error() != client.ErrClusterUnavailable.Error() {
+			return "", err
+		}
+
+		// another commit is running
+		time.Sleep(commitLockRetryDelay)
+	}
+
+	log.Errorf("Error taking the commit lock %s. Err: %v", commitLockKey, err)
+	return "", core.Errorf("timed out waiting for the commit lock")
+}
+
+// unlockCommits releases the commit lock, if it is still ours
+func (d *EtcdStateDriver) unlockCommits(token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+	defer cancel()
+
+	_, err := d.KeysAPI.Delete(ctx, commitLockKey, &client.DeleteOptions{PrevValue: token})
+	if err != nil {
+		log.Warnf("Error releasing the commit lock %s. Err: %v", commitLockKey, err)
+	}
+}
+
+// checkOp checks that the key of an op is still at the version of the op
+func (d *EtcdStateDriver) checkOp(op core.StateOp) error {
+	_, version, err := d.ReadVersion(op.Key)
+	if core.ErrIfKeyExists(err) != nil {
+		return err
+	}
+
+	if version != op.Version {
+		return core.ErrStateChanged
+	}
+
+	return nil
+}
+
+// undoOp reverts an op applied by casOp, using the etcd response of the op
+func (d *EtcdStateDriver) undoOp(op core.StateOp, resp *client.Response) error {
+	if resp == nil {
+		// nothing was changed
+		return nil
+	}
+
+	var undo core.StateOp
+	if op.Value == nil {
+		// write the deleted key back
+		undo = core.StateOp{Key: op.Key, Value: []byte(resp.PrevNode.Value)}
+	} else {
+		// restore the previous value, or delete the key created
+		undo = core.StateOp{Key: op.Key, Version: resp.Node.ModifiedIndex}
+		if resp.PrevNode != nil {
+			undo.Value = []byte(resp.PrevNode.Value)
+		}
+	}
+
+	_, err := d.casOp(undo)
+	return err
+}
+
+// Commit applies ops with compare-and-swap. The etcd v2 api has no multi-key
+// transactions, so commits of more than one key are serialized by a lock.
+// The versions of all keys are checked before any op is applied, and if a
+// writer that doesnt take the lock changes a key meanwhile, the ops already
+// applied are undone. Readers may see a commit partially applied.
+func (d *EtcdStateDriver) Commit(ops []core.StateOp) error {
+	switch len(ops) {
+	case 0:
+		return nil
+	case 1:
+		_, err := d.casOp(ops[0])
+		return err
+	}
+
+	token, err := d.lockCommits()
+	if err != nil {
+		return err
+	}
+	defer d.unlockCommits(token)
+
+	for _, op := range ops {
+		if err := d.checkOp(op); err != nil {
+			return err
+		}
+	}
+
+	resps := []*client.Response{}
+	for _, op := range ops {
+		resp, err := d.casOp(op)
+		if err != nil {
+			log.Errorf("Error committing %s, undoing the commit. Err: %v", op.Key, err)
+			for i := len(resps) - 1; i >= 0; i-- {
+				if err := d.undoOp(ops[i], resps[i]); err != nil {
+					log.Errorf("Error undoing the commit of %s. Err: %v", ops[i].Key, err)
+				}
+			}
+			return err
+		}
+		resps = append(resps, resp)
+	}
+
+	return nil
+}
+
+// ReadStateVersion reads key into a core.State, and returns its version.
+func (d *EtcdStateDriver) ReadStateVersion(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	return readStateVersionCommon(d, key, value, unmarshal)
+}
+
+// WriteStateCAS writes a core.State into key if it is still at version.
+func (d *EtcdStateDriver) WriteStateCAS(key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return writeStateCASCommon(d, key, value, version, marshal)
+}
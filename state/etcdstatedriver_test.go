@@ -18,6 +18,8 @@ package state
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -272,6 +274,37 @@ func TestEtcdStateDriverReadStateAfterClear(t *testing.T) {
 	commonTestStateDriverReadStateAfterClear(t, driver)
 }
 
+// recvWatchState returns the next event of a watch
+func recvWatchState(t *testing.T, stateCh chan core.WatchState, recvErr chan error,
+	timer <-chan time.Time) core.WatchState {
+	select {
+	case watchState := <-stateCh:
+		return watchState
+	case err := <-recvErr:
+		t.Fatalf("Watch failed. Error: %s", err)
+	case <-timer:
+		t.Fatalf("timed out waiting for events")
+	}
+
+	return core.WatchState{}
+}
+
+// recvExistingState returns the existing state a watch sends first
+func recvExistingState(t *testing.T, stateCh chan core.WatchState, recvErr chan error,
+	timer <-chan time.Time) []*testState {
+	states := []*testState{}
+	for {
+		watchState := recvWatchState(t, stateCh, recvErr, timer)
+		if watchState.Curr == nil && watchState.Prev == nil {
+			return states
+		}
+		if watchState.Prev != nil {
+			t.Fatalf("Existing state sent with prev state %+v, expected to be nil", watchState.Prev)
+		}
+		states = append(states, watchState.Curr.(*testState))
+	}
+}
+
 func commonTestStateDriverWatchAllStateCreate(t *testing.T, d core.StateDriver) {
 	state := &testState{IntField: 1234, StrField: "testString"}
 	baseKey := "create"
@@ -289,8 +322,10 @@ func commonTestStateDriverWatchAllStateCreate(t *testing.T, d core.StateDriver)
 		}
 	}(stateCh, recvErr)
 
-	// trigger create after a slight pause to ensure that events are not missed
-	time.Sleep(time.Second)
+	if states := recvExistingState(t, stateCh, recvErr, timer); len(states) != 0 {
+		t.Fatalf("Received existing state %+v, expected none", states)
+	}
+
 	err := d.WriteState(key, state, json.Marshal)
 	if err != nil {
 		t.Fatalf("failed to write state. Error: %s", err)
@@ -352,8 +387,11 @@ func commonTestStateDriverWatchAllStateModify(t *testing.T, d core.StateDriver)
 		}
 	}(stateCh, recvErr)
 
-	// trigger modify after a slight pause to ensure that events are not missed
-	time.Sleep(time.Second)
+	states := recvExistingState(t, stateCh, recvErr, timer)
+	if len(states) != 1 || states[0].IntField != state.IntField || states[0].StrField != state.StrField {
+		t.Fatalf("Existing state mismatch. Expctd: %+v, Rcvd: %+v", state, states)
+	}
+
 	err = d.WriteState(key, modState, json.Marshal)
 	if err != nil {
 		t.Fatalf("failed to write state. Error: %s", err)
@@ -415,8 +453,11 @@ func commonTestStateDriverWatchAllStateDelete(t *testing.T, d core.StateDriver)
 		}
 	}(stateCh, recvErr)
 
-	// trigger delete after a slight pause to ensure that events are not missed
-	time.Sleep(time.Second)
+	states := recvExistingState(t, stateCh, recvErr, timer)
+	if len(states) != 1 || states[0].IntField != state.IntField || states[0].StrField != state.StrField {
+		t.Fatalf("Existing state mismatch. Expctd: %+v, Rcvd: %+v", state, states)
+	}
+
 	err = d.ClearState(key)
 	if err != nil {
 		t.Fatalf("failed to clear state. Error: %s", err)
@@ -451,3 +492,276 @@ func TestEtcdStateDriverWatchAllStateDelete(t *testing.T) {
 	driver := setupEtcdDriver(t)
 	commonTestStateDriverWatchAllStateDelete(t, driver)
 }
+
+func commonTestStateDriverWatchAllStateExisting(t *testing.T, d core.StateDriver) {
+	baseKey := "existing"
+	states := []*testState{
+		{IntField: 1, StrField: "first"},
+		{IntField: 2, StrField: "second"},
+	}
+	newState := &testState{IntField: 3, StrField: "third"}
+
+	for idx, state := range states {
+		key := fmt.Sprintf("%s/testKeyWatchAll%d", baseKey, idx)
+		if err := d.WriteState(key, state, json.Marshal); err != nil {
+			t.Fatalf("failed to write state. Error: %s", err)
+		}
+		defer d.ClearState(key)
+	}
+
+	recvErr := make(chan error, 1)
+	stateCh := make(chan core.WatchState, 1)
+	timer := time.After(waitTimeout)
+
+	go func(rsps chan core.WatchState, retErr chan error) {
+		err := d.WatchAllState(baseKey, newState, json.Unmarshal, stateCh)
+		if err != nil {
+			retErr <- err
+			return
+		}
+	}(stateCh, recvErr)
+
+	// write right away, the change must be sent after the existing state
+	newKey := baseKey + "/testKeyWatchAll2"
+	if err := d.WriteState(newKey, newState, json.Marshal); err != nil {
+		t.Fatalf("failed to write state. Error: %s", err)
+	}
+	defer d.ClearState(newKey)
+
+	rcvdStates := recvExistingState(t, stateCh, recvErr, timer)
+	if len(rcvdStates) < len(states) {
+		t.Fatalf("Existing state mismatch. Expctd: %+v, Rcvd: %+v", states, rcvdStates)
+	}
+	for idx, state := range states {
+		if rcvdStates[idx].IntField != state.IntField || rcvdStates[idx].StrField != state.StrField {
+			t.Fatalf("Existing state mismatch. Expctd: %+v, Rcvd: %+v", state, rcvdStates[idx])
+		}
+	}
+
+	// the new state is either part of the existing state, or sent after it
+	if len(rcvdStates) == len(states) {
+		watchState := recvWatchState(t, stateCh, recvErr, timer)
+		rcvdStates = append(rcvdStates, watchState.Curr.(*testState))
+	}
+	if len(rcvdStates) != len(states)+1 || rcvdStates[len(states)].IntField != newState.IntField {
+		t.Fatalf("Watch state mismatch. Expctd: %+v, Rcvd: %+v", newState, rcvdStates)
+	}
+}
+
+func TestEtcdStateDriverWatchAllStateExisting(t *testing.T) {
+	driver := setupEtcdDriver(t)
+	commonTestStateDriverWatchAllStateExisting(t, driver)
+}
+
+func commonTestStateDriverWriteCAS(t *testing.T, d core.StateDriver) {
+	key := "TestKeyWriteCAS"
+	d.ClearState(key)
+	defer d.ClearState(key)
+
+	if err := d.WriteCAS(key, []byte("first"), 0); err != nil {
+		t.Fatalf("failed to create key. Error: %s", err)
+	}
+	if err := d.WriteCAS(key, []byte("again"), 0); err != core.ErrStateChanged {
+		t.Fatalf("creating an existing key returned %v, expected a conflict", err)
+	}
+
+	value, version, err := d.ReadVersion(key)
+	if err != nil {
+		t.Fatalf("failed to read key. Error: %s", err)
+	}
+	if string(value) != "first" || version == 0 {
+		t.Fatalf("read %q at version %d, expected \"first\" at a version", value, version)
+	}
+
+	if err := d.WriteCAS(key, []byte("second"), version); err != nil {
+		t.Fatalf("failed to update key. Error: %s", err)
+	}
+	if err := d.WriteCAS(key, []byte("stale"), version); err != core.ErrStateChanged {
+		t.Fatalf("stale update returned %v, expected a conflict", err)
+	}
+
+	value, newVersion, err := d.ReadVersion(key)
+	if err != nil {
+		t.Fatalf("failed to read key. Error: %s", err)
+	}
+	if string(value) != "second" || newVersion == version {
+		t.Fatalf("read %q at version %d, expected \"second\" at a new version", value, newVersion)
+	}
+}
+
+func TestEtcdStateDriverWriteCAS(t *testing.T) {
+	driver := setupEtcdDriver(t)
+	commonTestStateDriverWriteCAS(t, driver)
+}
+
+func commonTestStateDriverCommit(t *testing.T, d core.StateDriver) {
+	key1 := "TestKeyCommit1"
+	key2 := "TestKeyCommit2"
+	for _, key := range []string{key1, key2} {
+		d.ClearState(key)
+		defer d.ClearState(key)
+	}
+
+	if err := d.Write(key1, []byte("1")); err != nil {
+		t.Fatalf("failed to write key. Error: %s", err)
+	}
+	_, version1, err := d.ReadVersion(key1)
+	if err != nil {
+		t.Fatalf("failed to read key. Error: %s", err)
+	}
+
+	err = d.Commit([]core.StateOp{
+		{Key: key1, Value: []byte("2"), Version: version1},
+		{Key: key2, Value: []byte("new"), Version: 0},
+	})
+	if err != nil {
+		t.Fatalf("failed to commit. Error: %s", err)
+	}
+
+	_, version2, err := d.ReadVersion(key2)
+	if err != nil {
+		t.Fatalf("failed to read key. Error: %s", err)
+	}
+
+	// the stale version of key1 fails the whole commit
+	err = d.Commit([]core.StateOp{
+		{Key: key2, Version: version2},
+		{Key: key1, Value: []byte("3"), Version: version1},
+	})
+	if err != core.ErrStateChanged {
+		t.Fatalf("stale commit returned %v, expected a conflict", err)
+	}
+
+	value1, version1, err := d.ReadVersion(key1)
+	if err != nil || string(value1) != "2" {
+		t.Fatalf("read %q, %v for key1, expected \"2\"", value1, err)
+	}
+	if value2, err := d.Read(key2); err != nil || string(value2) != "new" {
+		t.Fatalf("read %q, %v for key2, expected \"new\"", value2, err)
+	}
+
+	err = d.Commit([]core.StateOp{
+		{Key: key1, Version: version1},
+		{Key: key2, Version: version2},
+	})
+	if err != nil {
+		t.Fatalf("failed to commit deletes. Error: %s", err)
+	}
+	for _, key := range []string{key1, key2} {
+		if _, err := d.Read(key); core.ErrIfKeyExists(err) != nil || err == nil {
+			t.Fatalf("read of deleted key %s returned %v, expected key not found", key, err)
+		}
+	}
+}
+
+func TestEtcdStateDriverCommit(t *testing.T) {
+	driver := setupEtcdDriver(t)
+	commonTestStateDriverCommit(t, driver)
+}
+
+func TestFakeEtcdStateDriverCommit(t *testing.T) {
+	keysAPI := NewFakeEtcdKeysAPI()
+	driver := &EtcdStateDriver{KeysAPI: keysAPI}
+	commonTestStateDriverCommit(t, driver)
+
+	key1 := "TestKeyCommit1"
+	key2 := "TestKeyCommit2"
+	for _, key := range []string{key1, key2} {
+		if err := driver.Write(key, []byte("1")); err != nil {
+			t.Fatalf("failed to write key. Error: %s", err)
+		}
+	}
+	_, version1, _ := driver.ReadVersion(key1)
+	_, version2, _ := driver.ReadVersion(key2)
+
+	// a writer that doesnt take the lock changes key2 after the versions
+	// were checked, the write of key1 is undone
+	keysAPI.OnSet = func(key string) {
+		if key == key2 {
+			keysAPI.OnSet = nil
+			driver.Write(key2, []byte("other"))
+		}
+	}
+	err := driver.Commit([]core.StateOp{
+		{Key: key1, Value: []byte("2"), Version: version1},
+		{Key: key2, Value: []byte("2"), Version: version2},
+	})
+	if err != core.ErrStateChanged {
+		t.Fatalf("conflicting commit returned %v, expected a conflict", err)
+	}
+	if value1, err := driver.Read(key1); err != nil || string(value1) != "1" {
+		t.Fatalf("read %q, %v for key1, expected \"1\"", value1, err)
+	}
+	if value2, err := driver.Read(key2); err != nil || string(value2) != "other" {
+		t.Fatalf("read %q, %v for key2, expected \"other\"", value2, err)
+	}
+
+	// the commit lock is released
+	if _, err := driver.Read(commitLockKey); err == nil {
+		t.Fatalf("commit lock %s not released", commitLockKey)
+	}
+}
+
+func commonTestStateDriverUpdateStates(t *testing.T, d core.StateDriver) {
+	key := "TestKeyUpdateStates"
+	d.ClearState(key)
+	defer d.ClearState(key)
+
+	numUpdaters := 4
+	numUpdates := 5
+
+	// concurrent increments must not overwrite each other
+	var wg sync.WaitGroup
+	errCh := make(chan error, numUpdaters)
+	for i := 0; i < numUpdaters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < numUpdates; j++ {
+				err := core.UpdateStates(d, func(txn core.StateDriver) error {
+					state := &testState{}
+					err := txn.ReadState(key, state, json.Unmarshal)
+					if core.ErrIfKeyExists(err) != nil {
+						return err
+					}
+					state.IntField++
+					return txn.WriteState(key, state, json.Marshal)
+				})
+				if err == core.ErrStateChanged {
+					// too much contention, try again
+					j--
+					continue
+				}
+				if err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errCh:
+		t.Fatalf("failed to update state. Error: %s", err)
+	default:
+	}
+
+	state := &testState{}
+	if err := d.ReadState(key, state, json.Unmarshal); err != nil {
+		t.Fatalf("failed to read state. Error: %s", err)
+	}
+	if state.IntField != numUpdaters*numUpdates {
+		t.Fatalf("state has %d updates, expected %d", state.IntField, numUpdaters*numUpdates)
+	}
+}
+
+func TestEtcdStateDriverUpdateStates(t *testing.T) {
+	driver := setupEtcdDriver(t)
+	commonTestStateDriverUpdateStates(t, driver)
+}
+
+func TestFakeEtcdStateDriverUpdateStates(t *testing.T) {
+	driver := &EtcdStateDriver{KeysAPI: NewFakeEtcdKeysAPI()}
+	commonTestStateDriverUpdateStates(t, driver)
+}
@@ -0,0 +1,166 @@
+package state
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/coreos/etcd/client"
+	// the keys api is declared with the context package of the etcd client
+	"github.com/coreos/etcd/Godeps/_workspace/src/golang.org/x/net/context"
+)
+
+// FakeEtcdKeysAPI implements an in-memory etcd v2 client.KeysAPI for use
+// with unit-tests of the EtcdStateDriver. It supports the conditional sets
+// and deletes of the driver, TTLs are ignored and watches are not supported.
+type FakeEtcdKeysAPI struct {
+	// OnSet is called before a key is set or deleted, tests use it to
+	// change keys in the middle of a commit
+	OnSet func(key string)
+
+	mutex sync.Mutex
+	index uint64 // etcd index of the last change
+	nodes map[string]*client.Node
+}
+
+// NewFakeEtcdKeysAPI creates an empty in-memory etcd v2 keys api
+func NewFakeEtcdKeysAPI() *FakeEtcdKeysAPI {
+	return &FakeEtcdKeysAPI{nodes: make(map[string]*client.Node)}
+}
+
+func (k *FakeEtcdKeysAPI) etcdError(code int, key string) error {
+	return client.Error{Code: code, Cause: key, Index: k.index}
+}
+
+// checkPrev checks the conditions of a set or delete on the current node
+func (k *FakeEtcdKeysAPI) checkPrev(key string, node *client.Node, prevExist client.PrevExistType,
+	prevIndex uint64, prevValue string) error {
+	switch {
+	case node == nil && (prevExist == client.PrevExist || prevIndex != 0 || prevValue != ""):
+		return k.etcdError(client.ErrorCodeKeyNotFound, key)
+	case node != nil && prevExist == client.PrevNoExist:
+		return k.etcdError(client.ErrorCodeNodeExist, key)
+	case node != nil && prevIndex != 0 && node.ModifiedIndex != prevIndex:
+		return k.etcdError(client.ErrorCodeTestFailed, key)
+	case node != nil && prevValue != "" && node.Value != prevValue:
+		return k.etcdError(client.ErrorCodeTestFailed, key)
+	}
+
+	return nil
+}
+
+// Get returns a key, or the keys in a directory
+func (k *FakeEtcdKeysAPI) Get(ctx context.Context, key string, opts *client.GetOptions) (*client.Response, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if node, ok := k.nodes[key]; ok {
+		nodeCopy := *node
+		return &client.Response{Action: "get", Node: &nodeCopy, Index: k.index}, nil
+	}
+
+	dir := &client.Node{Key: key, Dir: true}
+	keys := []string{}
+	for nodeKey := range k.nodes {
+		if strings.HasPrefix(nodeKey, strings.TrimSuffix(key, "/")+"/") {
+			keys = append(keys, nodeKey)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, k.etcdError(client.ErrorCodeKeyNotFound, key)
+	}
+
+	sort.Strings(keys)
+	for _, nodeKey := range keys {
+		nodeCopy := *k.nodes[nodeKey]
+		dir.Nodes = append(dir.Nodes, &nodeCopy)
+	}
+
+	return &client.Response{Action: "get", Node: dir, Index: k.index}, nil
+}
+
+// Set writes a key, if the conditions of opts are met
+func (k *FakeEtcdKeysAPI) Set(ctx context.Context, key, value string, opts *client.SetOptions) (*client.Response, error) {
+	if k.OnSet != nil {
+		k.OnSet(key)
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if opts == nil {
+		opts = &client.SetOptions{}
+	}
+
+	prevNode := k.nodes[key]
+	if err := k.checkPrev(key, prevNode, opts.PrevExist, opts.PrevIndex, opts.PrevValue); err != nil {
+		return nil, err
+	}
+
+	k.index++
+	node := &client.Node{Key: key, Value: value, CreatedIndex: k.index, ModifiedIndex: k.index}
+	if prevNode != nil {
+		node.CreatedIndex = prevNode.CreatedIndex
+	}
+	k.nodes[key] = node
+
+	nodeCopy := *node
+	return &client.Response{Action: "set", Node: &nodeCopy, PrevNode: prevNode, Index: k.index}, nil
+}
+
+// Delete removes a key, if the conditions of opts are met
+func (k *FakeEtcdKeysAPI) Delete(ctx context.Context, key string, opts *client.DeleteOptions) (*client.Response, error) {
+	if k.OnSet != nil {
+		k.OnSet(key)
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if opts == nil {
+		opts = &client.DeleteOptions{}
+	}
+
+	prevNode := k.nodes[key]
+	if prevNode == nil {
+		return nil, k.etcdError(client.ErrorCodeKeyNotFound, key)
+	}
+	if err := k.checkPrev(key, prevNode, client.PrevIgnore, opts.PrevIndex, opts.PrevValue); err != nil {
+		return nil, err
+	}
+
+	k.index++
+	delete(k.nodes, key)
+
+	node := &client.Node{Key: key, CreatedIndex: prevNode.CreatedIndex, ModifiedIndex: k.index}
+	return &client.Response{Action: "delete", Node: node, PrevNode: prevNode, Index: k.index}, nil
+}
+
+// Create writes a key that must not exist
+func (k *FakeEtcdKeysAPI) Create(ctx context.Context, key, value string) (*client.Response, error) {
+	return k.Set(ctx, key, value, &client.SetOptions{PrevExist: client.PrevNoExist})
+}
+
+// CreateInOrder is not supported
+func (k *FakeEtcdKeysAPI) CreateInOrder(ctx context.Context, dir, value string,
+	opts *client.CreateInOrderOptions) (*client.Response, error) {
+	return nil, errors.New("not supported")
+}
+
+// Update writes a key that must exist
+func (k *FakeEtcdKeysAPI) Update(ctx context.Context, key, value string) (*client.Response, error) {
+	return k.Set(ctx, key, value, &client.SetOptions{PrevExist: client.PrevExist})
+}
+
+// fakeEtcdWatcher fails every watch
+type fakeEtcdWatcher struct{}
+
+func (w fakeEtcdWatcher) Next(ctx context.Context) (*client.Response, error) {
+	return nil, errors.New("not supported")
+}
+
+// Watcher is not supported, its watches fail
+func (k *FakeEtcdKeysAPI) Watcher(key string, opts *client.WatcherOptions) client.Watcher {
+	return fakeEtcdWatcher{}
+}
@@ -9,7 +9,8 @@ import (
 )
 
 type valueData struct {
-	value []byte
+	value   []byte
+	version uint64
 }
 
 // FakeStateDriverConfig represents the configuration of the fake statedriver,
@@ -20,6 +21,7 @@ type FakeStateDriverConfig struct{}
 // unit-tests
 type FakeStateDriver struct {
 	TestState map[string]valueData
+	version   uint64 // version of the last write
 }
 
 // Init the driver
@@ -36,8 +38,8 @@ func (d *FakeStateDriver) Deinit() {
 
 // Write value to key
 func (d *FakeStateDriver) Write(key string, value []byte) error {
-	val := valueData{value: value}
-	d.TestState[key] = val
+	d.version++
+	d.TestState[key] = valueData{value: value, version: d.version}
 
 	return nil
 }
@@ -85,7 +87,7 @@ func (d *FakeStateDriver) ReadState(key string, value core.State,
 		return err
 	}
 
-	return unmarshal(encodedState, value)
+	return core.DecodeState(key, encodedState, value, unmarshal)
 }
 
 // ReadAllState reads all state from baseKey of a given type
@@ -103,7 +105,7 @@ func (d *FakeStateDriver) WatchAllState(baseKey string, sType core.State,
 // WriteState writes a core.State to key.
 func (d *FakeStateDriver) WriteState(key string, value core.State,
 	marshal func(interface{}) ([]byte, error)) error {
-	encodedState, err := marshal(value)
+	encodedState, err := core.EncodeState(key, value, marshal)
 	if err != nil {
 		return err
 	}
@@ -111,6 +113,56 @@ func (d *FakeStateDriver) WriteState(key string, value core.State,
 	return d.Write(key, encodedState)
 }
 
+// ReadVersion reads value and version from key
+func (d *FakeStateDriver) ReadVersion(key string) ([]byte, uint64, error) {
+	if val, ok := d.TestState[key]; ok {
+		return val.value, val.version, nil
+	}
+
+	return []byte{}, 0, core.Errorf("key not found! key: %v", key)
+}
+
+// WriteCAS writes value to key if it is at version
+func (d *FakeStateDriver) WriteCAS(key string, value []byte, version uint64) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	return d.Commit([]core.StateOp{{Key: key, Value: value, Version: version}})
+}
+
+// Commit applies the ops if all keys are at their versions
+func (d *FakeStateDriver) Commit(ops []core.StateOp) error {
+	for _, op := range ops {
+		if d.TestState[op.Key].version != op.Version {
+			return core.ErrStateChanged
+		}
+	}
+
+	for _, op := range ops {
+		if op.Value == nil {
+			delete(d.TestState, op.Key)
+		} else {
+			d.Write(op.Key, op.Value)
+		}
+	}
+
+	return nil
+}
+
+// ReadStateVersion unmarshals state into a core.State, and returns its
+// version
+func (d *FakeStateDriver) ReadStateVersion(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	return readStateVersionCommon(d, key, value, unmarshal)
+}
+
+// WriteStateCAS writes a core.State to key if it is at version
+func (d *FakeStateDriver) WriteStateCAS(key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return writeStateCASCommon(d, key, value, version, marshal)
+}
+
 // DumpState is a debugging tool.
 func (d *FakeStateDriver) DumpState() {
 	for key := range d.TestState {
@@ -0,0 +1,252 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"errors"
+	"time"
+
+	"github.com/contiv/netplugin/core"
+	"github.com/contiv/netplugin/objdb/filedb"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// FileStateDriverConfig encapsulates the directory of a local file store
+type FileStateDriverConfig struct {
+	File struct {
+		Path string
+	}
+}
+
+// FileStateDriver implements the StateDriver interface for a store in a
+// local directory, used by single node setups
+type FileStateDriver struct {
+	DB *filedb.DB
+}
+
+// Init the driver with a core.Config.
+func (d *FileStateDriver) Init(instInfo *core.InstanceInfo) error {
+	if instInfo == nil || instInfo.DbURL == "" {
+		return errors.New("no file store config found")
+	}
+
+	path, err := filedb.PathFromURL(instInfo.DbURL)
+	if err != nil {
+		return err
+	}
+
+	d.DB, err = filedb.Open(path)
+	if err != nil {
+		log.Errorf("error opening file store %s. Err: %v", path, err)
+		return err
+	}
+
+	return nil
+}
+
+// Deinit is currently a no-op.
+func (d *FileStateDriver) Deinit() {}
+
+// Write state to key with value.
+func (d *FileStateDriver) Write(key string, value []byte) error {
+	return d.DB.Put(key, string(value[:]), 0)
+}
+
+// Read state from key.
+func (d *FileStateDriver) Read(key string) ([]byte, error) {
+	kv, err := d.DB.Get(key)
+	if err == filedb.ErrKeyNotFound {
+		return []byte{}, core.Errorf("key not found")
+	}
+	if err != nil {
+		return []byte{}, err
+	}
+
+	return []byte(kv.Value), nil
+}
+
+// ReadAll state from baseKey.
+func (d *FileStateDriver) ReadAll(baseKey string) ([][]byte, error) {
+	prefix := dirPrefix(baseKey)
+
+	kvs, err := d.DB.List(prefix)
+	if err != nil {
+		return [][]byte{}, err
+	}
+
+	values := [][]byte{}
+	for _, kv := range kvs {
+		if isDirEntry(prefix, kv.Key) {
+			values = append(values, []byte(kv.Value))
+		}
+	}
+
+	return values, nil
+}
+
+// WatchAll state transitions from baseKey. The keys under baseKey are sent
+// first as creates, followed by an empty response, and then the changes
+// made after they were read.
+func (d *FileStateDriver) WatchAll(baseKey string, rsps chan [2][]byte) error {
+	kvs, watchCh, err := d.DB.Watch(dirPrefix(baseKey), make(chan bool))
+	if err != nil {
+		log.Errorf("file store watch failed. Err: %v", err)
+		return err
+	}
+
+	go func() {
+		sent := watchCache{}
+		for _, kv := range kvs {
+			sent[kv.Key] = []byte(kv.Value)
+		}
+		sent.sendSnapshot(rsps)
+
+		for ev := range watchCh {
+			rsp := [2][]byte{nil, nil}
+			eventStr := "create"
+			if ev.Type == filedb.EventPut {
+				rsp[0] = []byte(ev.Kv.Value)
+			}
+			if ev.PrevKv != nil {
+				rsp[1] = []byte(ev.PrevKv.Value)
+				if ev.Type == filedb.EventPut {
+					eventStr = "modify"
+				} else {
+					eventStr = "delete"
+				}
+			}
+
+			log.Debugf("Received %q for key: %s", eventStr, ev.Kv.Key)
+			//channel the translated response
+			rsps <- rsp
+		}
+	}()
+
+	return nil
+}
+
+// ClearState removes key from the store
+func (d *FileStateDriver) ClearState(key string) error {
+	_, err := d.DB.Delete(key)
+	if err == filedb.ErrKeyNotFound {
+		return core.Errorf("key not found")
+	}
+
+	return err
+}
+
+// ReadState reads key into a core.State with the unmarshaling function.
+func (d *FileStateDriver) ReadState(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) error {
+	encodedState, err := d.Read(key)
+	if err != nil {
+		return err
+	}
+
+	return core.DecodeState(key, encodedState, value, unmarshal)
+}
+
+// ReadAllState Reads all the state from baseKey and returns a list of core.State.
+func (d *FileStateDriver) ReadAllState(baseKey string, sType core.State,
+	unmarshal func([]byte, interface{}) error) ([]core.State, error) {
+	return readAllStateCommon(d, baseKey, sType, unmarshal)
+}
+
+// WatchAllState watches all state from the baseKey.
+func (d *FileStateDriver) WatchAllState(baseKey string, sType core.State,
+	unmarshal func([]byte, interface{}) error, rsps chan core.WatchState) error {
+	byteRsps := make(chan [2][]byte, 1)
+	recvErr := make(chan error, 1)
+
+	err := d.WatchAll(baseKey, byteRsps)
+	if err != nil {
+		log.Errorf("WatchAll returned %v", err)
+		return err
+	}
+
+	for {
+		go channelStateEvents(d, baseKey, sType, unmarshal, byteRsps, rsps, recvErr)
+
+		err = <-recvErr
+		log.Errorf("Err from channelStateEvents %v", err)
+		time.Sleep(time.Second)
+	}
+}
+
+// WriteState writes a value of core.State into a key with a given marshaling function.
+func (d *FileStateDriver) WriteState(key string, value core.State,
+	marshal func(interface{}) ([]byte, error)) error {
+	encodedState, err := core.EncodeState(key, value, marshal)
+	if err != nil {
+		return err
+	}
+
+	return d.Write(key, encodedState)
+}
+
+// ReadVersion reads a key and its version.
+func (d *FileStateDriver) ReadVersion(key string) ([]byte, uint64, error) {
+	kv, err := d.DB.Get(key)
+	if err == filedb.ErrKeyNotFound {
+		return []byte{}, 0, core.Errorf("key not found")
+	}
+	if err != nil {
+		return []byte{}, 0, err
+	}
+
+	return []byte(kv.Value), uint64(kv.Version), nil
+}
+
+// WriteCAS writes key if it is still at version.
+func (d *FileStateDriver) WriteCAS(key string, value []byte, version uint64) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	return d.Commit([]core.StateOp{{Key: key, Value: value, Version: version}})
+}
+
+// Commit applies the ops if all keys are at their versions.
+func (d *FileStateDriver) Commit(ops []core.StateOp) error {
+	dbOps := []filedb.Op{}
+	for _, op := range ops {
+		dbOps = append(dbOps, filedb.Op{Key: op.Key, Value: string(op.Value),
+			Delete: op.Value == nil, Version: int64(op.Version)})
+	}
+
+	committed, err := d.DB.Commit(dbOps)
+	if err != nil {
+		return err
+	}
+	if !committed {
+		return core.ErrStateChanged
+	}
+
+	return nil
+}
+
+// ReadStateVersion reads key into a core.State, and returns its version.
+func (d *FileStateDriver) ReadStateVersion(key string, value core.State,
+	unmarshal func([]byte, interface{}) error) (uint64, error) {
+	return readStateVersionCommon(d, key, value, unmarshal)
+}
+
+// WriteStateCAS writes a core.State into key if it is still at version.
+func (d *FileStateDriver) WriteStateCAS(key string, value core.State, version uint64,
+	marshal func(interface{}) ([]byte, error)) error {
+	return writeStateCASCommon(d, key, value, version, marshal)
+}
@@ -0,0 +1,143 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/contiv/netplugin/core"
+)
+
+func setupFileDriver(t *testing.T) (*FileStateDriver, func()) {
+	dir, err := ioutil.TempDir("", "filestate")
+	if err != nil {
+		t.Fatalf("error creating temp dir. Error: %s", err)
+	}
+	instInfo := core.InstanceInfo{DbURL: "file://" + dir}
+
+	driver := &FileStateDriver{}
+
+	err = driver.Init(&instInfo)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("driver init failed. Error: %s", err)
+		return nil, nil
+	}
+
+	return driver, func() { os.RemoveAll(dir) }
+}
+
+func TestFileStateDriverInit(t *testing.T) {
+	_, cleanup := setupFileDriver(t)
+	cleanup()
+}
+
+func TestFileStateDriverInitInvalidConfig(t *testing.T) {
+	driver := &FileStateDriver{}
+	commonTestStateDriverInitInvalidConfig(t, driver)
+}
+
+func TestFileStateDriverWrite(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverWrite(t, driver)
+}
+
+func TestFileStateDriverRead(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverRead(t, driver)
+}
+
+func TestFileStateDriverWriteState(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverWriteState(t, driver)
+}
+
+func TestFileStateDriverWriteStateForUpdate(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverWriteStateForUpdate(t, driver)
+}
+
+func TestFileStateDriverClearState(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverClearState(t, driver)
+}
+
+func TestFileStateDriverReadState(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverReadState(t, driver)
+}
+
+func TestFileStateDriverReadStateAfterUpdate(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverReadStateAfterUpdate(t, driver)
+}
+
+func TestFileStateDriverReadStateAfterClear(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverReadStateAfterClear(t, driver)
+}
+
+func TestFileStateDriverWatchAllStateCreate(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverWatchAllStateCreate(t, driver)
+}
+
+func TestFileStateDriverWatchAllStateModify(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverWatchAllStateModify(t, driver)
+}
+
+func TestFileStateDriverWatchAllStateDelete(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverWatchAllStateDelete(t, driver)
+}
+
+func TestFileStateDriverWatchAllStateExisting(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverWatchAllStateExisting(t, driver)
+}
+
+func TestFileStateDriverWriteCAS(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverWriteCAS(t, driver)
+}
+
+func TestFileStateDriverCommit(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverCommit(t, driver)
+}
+
+func TestFileStateDriverUpdateStates(t *testing.T) {
+	driver, cleanup := setupFileDriver(t)
+	defer cleanup()
+	commonTestStateDriverUpdateStates(t, driver)
+}
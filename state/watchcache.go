@@ -0,0 +1,85 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// watchCache tracks the values of the keys a watch has sent. It is used to
+// send the existing keys when a watch starts, and to resync a watch that
+// lost changes, eg. when the revision it watched from was compacted.
+type watchCache map[string][]byte
+
+// sortedKeys returns the keys of the cache in order, so that watchers see
+// the existing state in the same order every time
+func (c watchCache) sortedKeys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// sendSnapshot sends all keys as creates, followed by the empty response
+// that tells the watcher it has seen the existing state
+func (c watchCache) sendSnapshot(rsps chan [2][]byte) {
+	for _, key := range c.sortedKeys() {
+		log.Debugf("Sending existing key: %s", key)
+		rsps <- [2][]byte{c[key], nil}
+	}
+
+	rsps <- [2][]byte{nil, nil}
+}
+
+// update records a change to a key. A nil value deletes it.
+func (c watchCache) update(key string, value []byte) {
+	if value == nil {
+		delete(c, key)
+	} else {
+		c[key] = value
+	}
+}
+
+// resync sends the changes between the keys that were sent and the current
+// keys, and makes the current keys the ones that were sent
+func (c watchCache) resync(kvs map[string][]byte, rsps chan [2][]byte) {
+	for _, key := range c.sortedKeys() {
+		if _, ok := kvs[key]; !ok {
+			log.Infof("Resync: key %s was deleted", key)
+			rsps <- [2][]byte{nil, c[key]}
+			delete(c, key)
+		}
+	}
+
+	curr := watchCache(kvs)
+	for _, key := range curr.sortedKeys() {
+		prev, ok := c[key]
+		if ok && bytes.Equal(prev, kvs[key]) {
+			continue
+		}
+
+		// prev is nil for new keys
+		log.Infof("Resync: key %s was changed", key)
+		rsps <- [2][]byte{kvs[key], prev}
+		c[key] = kvs[key]
+	}
+}
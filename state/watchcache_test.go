@@ -0,0 +1,71 @@
+/***
+Copyright 2017 Cisco Systems Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"testing"
+)
+
+func recvAll(rsps chan [2][]byte) [][2]string {
+	var rcvd [][2]string
+	for {
+		select {
+		case rsp := <-rsps:
+			rcvd = append(rcvd, [2]string{string(rsp[0]), string(rsp[1])})
+		default:
+			return rcvd
+		}
+	}
+}
+
+func checkRsps(t *testing.T, rcvd, expected [][2]string) {
+	if len(rcvd) != len(expected) {
+		t.Fatalf("Received %v. Expected: %v", rcvd, expected)
+	}
+	for idx := range expected {
+		if rcvd[idx] != expected[idx] {
+			t.Fatalf("Received %v. Expected: %v", rcvd, expected)
+		}
+	}
+}
+
+func TestWatchCache(t *testing.T) {
+	rsps := make(chan [2][]byte, 10)
+	sent := watchCache{
+		"/a/2": []byte("v2"),
+		"/a/1": []byte("v1"),
+		"/a/3": []byte("v3"),
+	}
+
+	// existing keys are sent in order, followed by the end marker
+	sent.sendSnapshot(rsps)
+	checkRsps(t, recvAll(rsps), [][2]string{{"v1", ""}, {"v2", ""}, {"v3", ""}, {"", ""}})
+
+	sent.update("/a/3", nil)
+	sent.update("/a/4", []byte("v4"))
+
+	// deletes are sent first, then changes and new keys
+	sent.resync(map[string][]byte{
+		"/a/2": []byte("v2"),
+		"/a/4": []byte("v4.1"),
+		"/a/5": []byte("v5"),
+	}, rsps)
+	checkRsps(t, recvAll(rsps), [][2]string{{"", "v1"}, {"v4.1", "v4"}, {"v5", ""}})
+
+	if len(sent) != 3 || string(sent["/a/4"]) != "v4.1" {
+		t.Fatalf("Cache not updated after resync: %v", sent)
+	}
+}
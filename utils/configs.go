@@ -32,16 +32,6 @@ func BuildNetworkFlags(binary string) []cli.Flag {
 			EnvVar: fmt.Sprintf("CONTIV_%s_FORWARD_MODE", binUpper),
 			Usage:  fmt.Sprintf("set %s forwarding network mode, options: [bridge, routing]", binLower),
 		},
-		/*
-			// only ovs is supported
-			// TODO: turn it on when having more than one backend supported
-			cli.StringFlag {
-				Name: "driver, net-driver",
-				Value: "ovs",
-				EnvVar: "CONTIV_NETPLUGIN_DRIVER",
-				Usage: "set netplugin key-value store url, options: [ovs, vpp]",
-			}
-		*/
 	}
 }
 
@@ -62,11 +52,21 @@ func BuildDBFlags(binary string) []cli.Flag {
 			EnvVar: fmt.Sprintf("CONTIV_%s_ETCD_ENDPOINTS", binUpper),
 			Usage:  fmt.Sprintf("a comma-delimited list of %s etcd endpoints (default: http://127.0.0.1:2379)", binLower),
 		},
+		cli.StringFlag{
+			Name:   "etcd3-endpoints, etcd3",
+			EnvVar: fmt.Sprintf("CONTIV_%s_ETCD3_ENDPOINTS", binUpper),
+			Usage:  fmt.Sprintf("a comma-delimited list of %s etcd endpoints, using the etcd v3 api", binLower),
+		},
 		cli.StringFlag{
 			Name:   "consul-endpoints, consul",
 			EnvVar: fmt.Sprintf("CONTIV_%s_CONSUL_ENDPOINTS", binUpper),
 			Usage:  fmt.Sprintf("a comma-delimited list of %s consul endpoints", binLower),
 		},
+		cli.StringFlag{
+			Name:   "cluster-store",
+			EnvVar: fmt.Sprintf("CONTIV_%s_CLUSTER_STORE", binUpper),
+			Usage:  fmt.Sprintf("set %s state store url, options: [etcd://<ip:port>, etcd3://<ip:port>, consul://<ip:port>, file:///<path>]", binLower),
+		},
 	}
 }
 
@@ -180,17 +180,31 @@ func ValidateDBOptions(binary string, ctx *cli.Context) (*DBConfigs, error) {
 	var storeURL string
 	var storeURLs string
 	etcdURLs := ctx.String("etcd")
+	etcd3URLs := ctx.String("etcd3")
 	consulURLs := ctx.String("consul")
+	clusterStore := ctx.String("cluster-store")
 
-	if etcdURLs != "" && consulURLs != "" {
-		return nil, fmt.Errorf("ambiguous %s db endpoints, both etcd and consul specified: etcd: %s, consul: %s", binary, etcdURLs, consulURLs)
-	} else if etcdURLs == "" && consulURLs == "" {
+	numStores := 0
+	for _, urls := range []string{etcdURLs, etcd3URLs, consulURLs, clusterStore} {
+		if urls != "" {
+			numStores++
+		}
+	}
+
+	if numStores > 1 {
+		return nil, fmt.Errorf("ambiguous %s db endpoints, more than one of etcd, etcd3, consul and cluster-store specified: etcd: %s, etcd3: %s, consul: %s, cluster-store: %s", binary, etcdURLs, etcd3URLs, consulURLs, clusterStore)
+	} else if clusterStore != "" {
+		return parseClusterStore(binary, clusterStore)
+	} else if numStores == 0 {
 		// if neither etcd or consul is set, try etcd at http://127.0.0.1:2379
 		storeDriver = "etcd"
 		storeURLs = "http://127.0.0.1:2379"
 	} else if etcdURLs != "" {
 		storeDriver = "etcd"
 		storeURLs = etcdURLs
+	} else if etcd3URLs != "" {
+		storeDriver = "etcd3"
+		storeURLs = etcd3URLs
 	} else {
 		storeDriver = "consul"
 		storeURLs = consulURLs
@@ -216,6 +230,38 @@ func ValidateDBOptions(binary string, ctx *cli.Context) (*DBConfigs, error) {
 	}, nil
 }
 
+// parseClusterStore selects the state store from a cluster store url. The
+// file store is used by its path, the others by their http endpoint.
+func parseClusterStore(binary, clusterStore string) (*DBConfigs, error) {
+	parts := strings.SplitN(clusterStore, "://", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid %s cluster store url: %s", binary, clusterStore)
+	}
+
+	storeDriver := parts[0]
+	storeURL := "http://" + parts[1]
+	switch storeDriver {
+	case EtcdNameStr, Etcd3NameStr, ConsulNameStr:
+		if _, err := url.Parse(storeURL); err != nil {
+			return nil, fmt.Errorf("invalid %s cluster store url: %s", binary, clusterStore)
+		}
+	case FileNameStr:
+		if !strings.HasPrefix(parts[1], "/") {
+			return nil, fmt.Errorf("invalid %s cluster store url: %s, expected file:///<path>", binary, clusterStore)
+		}
+		storeURL = clusterStore
+	default:
+		return nil, fmt.Errorf("unsupported %s cluster store: %s", binary, storeDriver)
+	}
+
+	logrus.Infof("Using %s state db endpoints: %v: %v", binary, storeDriver, storeURL)
+
+	return &DBConfigs{
+		StoreDriver: storeDriver,
+		StoreURL:    storeURL,
+	}, nil
+}
+
 // ValidateNetworkOptions returns error if network options are not valid
 func ValidateNetworkOptions(binary string, ctx *cli.Context) (*NetworkConfigs, error) {
 	// 1. validate and set plugin mode
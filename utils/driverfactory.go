@@ -5,6 +5,7 @@ import (
 
 	"github.com/contiv/netplugin/core"
 	"github.com/contiv/netplugin/drivers"
+	"github.com/contiv/netplugin/drivers/linuxd"
 	"github.com/contiv/netplugin/drivers/ovsd"
 	"github.com/contiv/netplugin/drivers/vppd"
 	"github.com/contiv/netplugin/state"
@@ -27,6 +28,10 @@ var networkDriverRegistry = map[string]driverConfigTypes{
 		DriverType: reflect.TypeOf(vppd.VppDriver{}),
 		ConfigType: reflect.TypeOf(vppd.VppDriver{}),
 	},
+	LinuxNameStr: {
+		DriverType: reflect.TypeOf(linuxd.LinuxDriver{}),
+		ConfigType: reflect.TypeOf(linuxd.LinuxDriver{}),
+	},
 	// fakedriver is used for tests, so not exposing a public name for it.
 	"fakedriver": {
 		DriverType: reflect.TypeOf(drivers.FakeNetEpDriver{}),
@@ -39,10 +44,18 @@ var stateDriverRegistry = map[string]driverConfigTypes{
 		DriverType: reflect.TypeOf(state.EtcdStateDriver{}),
 		ConfigType: reflect.TypeOf(state.EtcdStateDriverConfig{}),
 	},
+	Etcd3NameStr: {
+		DriverType: reflect.TypeOf(state.Etcd3StateDriver{}),
+		ConfigType: reflect.TypeOf(state.Etcd3StateDriverConfig{}),
+	},
 	ConsulNameStr: {
 		DriverType: reflect.TypeOf(state.ConsulStateDriver{}),
 		ConfigType: reflect.TypeOf(state.ConsulStateDriverConfig{}),
 	},
+	FileNameStr: {
+		DriverType: reflect.TypeOf(state.FileStateDriver{}),
+		ConfigType: reflect.TypeOf(state.FileStateDriverConfig{}),
+	},
 	// fakestate-driver is used for tests, so not exposing a public name for it.
 	"fakedriver": {
 		DriverType: reflect.TypeOf(state.FakeStateDriver{}),
@@ -53,12 +66,18 @@ var stateDriverRegistry = map[string]driverConfigTypes{
 const (
 	// EtcdNameStr is a string constant for etcd state-store
 	EtcdNameStr = "etcd"
+	// Etcd3NameStr is a string constant for the etcd v3 state-store
+	Etcd3NameStr = "etcd3"
 	// ConsulNameStr is a string constant for consul state-store
 	ConsulNameStr = "consul"
+	// FileNameStr is a string constant for the local file state-store
+	FileNameStr = "file"
 	// OvsNameStr is a string constant for ovs driver
 	OvsNameStr = "ovs"
 	// VppNameStr is a string constant for vpp driver
 	VppNameStr = "vpp"
+	// LinuxNameStr is a string constant for the linux kernel driver
+	LinuxNameStr = "linux"
 )
 
 var (
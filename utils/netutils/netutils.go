@@ -24,6 +24,7 @@ import (
 	"net"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"unsafe"
@@ -171,6 +172,23 @@ func ValidateNetworkRangeParams(ipRange string, subnetLen uint) error {
 // IsIPv6 Checks if the address string is IPv6 address
 func IsIPv6(ip string) bool { return strings.Contains(ip, ":") }
 
+// ValidateIPOrCIDR checks that the address is an IPv4 or IPv6 address,
+// with or without a prefix length
+func ValidateIPOrCIDR(addr string) error {
+	if strings.Contains(addr, "/") {
+		if _, _, err := net.ParseCIDR(addr); err != nil {
+			return core.Errorf("invalid CIDR %s", addr)
+		}
+		return nil
+	}
+
+	if net.ParseIP(addr) == nil {
+		return core.Errorf("invalid IP address %s", addr)
+	}
+
+	return nil
+}
+
 // InitSubnetBitset initializes a bit set with 2^(32 - subnetLen) bits
 func InitSubnetBitset(b *bitset.BitSet, subnetLen uint) {
 	maxSize := (1 << (32 - subnetLen)) - 1
@@ -626,6 +644,92 @@ func ParseTagRanges(ranges string, tagType string) ([]TagRange, error) {
 	return tagRanges, nil
 }
 
+// tagRangeList sorts tag ranges by their min value
+type tagRangeList []TagRange
+
+func (l tagRangeList) Len() int           { return len(l) }
+func (l tagRangeList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l tagRangeList) Less(i, j int) bool { return l[i].Min < l[j].Min }
+
+// PortMatch represents a masked tcp/udp port match. A mask of 0xffff
+// matches exactly one port.
+type PortMatch struct {
+	Port uint16
+	Mask uint16
+}
+
+// ParsePortList takes a string such as 80,443,30000-32767 and turns it into
+// the minimal series of PortMatch covering all the ports.
+func ParsePortList(ports string) ([]PortMatch, error) {
+	portRanges := []TagRange{}
+	for _, onePortStr := range strings.Split(ports, ",") {
+		onePortStr = strings.Trim(onePortStr, " ")
+		portNums := strings.Split(onePortStr, "-")
+		if len(portNums) > 2 {
+			return nil, core.Errorf("invalid ports %s, correct '80,443,8000-8080'",
+				onePortStr)
+		}
+
+		portRange := TagRange{}
+		min, err := strconv.Atoi(portNums[0])
+		if err != nil {
+			return nil, core.Errorf("invalid port %s conversion error '%s'",
+				portNums[0], err)
+		}
+		portRange.Min, portRange.Max = min, min
+		if len(portNums) == 2 {
+			portRange.Max, err = strconv.Atoi(portNums[1])
+			if err != nil {
+				return nil, core.Errorf("invalid port %s conversion error '%s'",
+					portNums[1], err)
+			}
+		}
+
+		if portRange.Min > portRange.Max {
+			return nil, core.Errorf("invalid range %s, min is greater than max",
+				onePortStr)
+		}
+		if portRange.Min < 1 || portRange.Max > 65535 {
+			return nil, core.Errorf("invalid port %s, ports must be in 1-65535",
+				onePortStr)
+		}
+		portRanges = append(portRanges, portRange)
+	}
+
+	// merge overlapping and adjacent ranges
+	sort.Sort(tagRangeList(portRanges))
+	merged := []TagRange{}
+	for _, portRange := range portRanges {
+		last := len(merged) - 1
+		if last >= 0 && portRange.Min <= merged[last].Max+1 {
+			if portRange.Max > merged[last].Max {
+				merged[last].Max = portRange.Max
+			}
+			continue
+		}
+		merged = append(merged, portRange)
+	}
+
+	// split each range into the largest aligned power of two blocks
+	portMatches := []PortMatch{}
+	for _, portRange := range merged {
+		port := portRange.Min
+		for port <= portRange.Max {
+			size := 1
+			for port%(size*2) == 0 && port+size*2-1 <= portRange.Max {
+				size = size * 2
+			}
+			portMatches = append(portMatches, PortMatch{
+				Port: uint16(port),
+				Mask: uint16(0xffff &^ (size - 1)),
+			})
+			port += size
+		}
+	}
+
+	return portMatches, nil
+}
+
 // ParseCIDR parses a CIDR string into a gateway IP and length.
 func ParseCIDR(cidrStr string) (string, uint, error) {
 	strs := strings.Split(cidrStr, "/")
@@ -779,6 +883,59 @@ func GetHostLowestLinkMtu() (int, error) {
 	return lowestMTU, nil
 }
 
+const (
+	// VxlanEncapOverhead is the size of the vxlan encap
+	// (inner eth header(14) + outer IP(20) outer UDP(8) + vxlan header(8))
+	VxlanEncapOverhead = 50
+	// GeneveEncapOverhead is the size of the geneve encap carrying the
+	// source EPG option (vxlan overhead + option header(4) + option(4))
+	GeneveEncapOverhead = 58
+	// MinLinkMtu is the smallest MTU accepted on a network
+	MinLinkMtu = 576
+	// MinIPv6LinkMtu is the smallest MTU of the IPv6 links
+	MinIPv6LinkMtu = 1280
+)
+
+// GetEncapOverhead returns the bytes an encap adds to the endpoint packets
+func GetEncapOverhead(encap string) int {
+	switch encap {
+	case "vxlan":
+		return VxlanEncapOverhead
+	case "geneve":
+		return GeneveEncapOverhead
+	}
+
+	return 0
+}
+
+// GetNetworkMtu returns the MTU of the endpoints of a network. A network
+// without a configured MTU uses the uplink MTU minus its encap overhead, a
+// configured MTU is lowered to it when the encapsulated packets wouldn't fit
+// the uplink.
+func GetNetworkMtu(mtu, uplinkMtu int, encap string) int {
+	maxMtu := uplinkMtu - GetEncapOverhead(encap)
+	if mtu == 0 {
+		return maxMtu
+	}
+
+	if mtu > maxMtu {
+		log.Warnf("Network mtu %d is larger than the uplink mtu %d allows with %s encap, using %d",
+			mtu, uplinkMtu, encap, maxMtu)
+		return maxMtu
+	}
+
+	return mtu
+}
+
+// SetInterfaceMtu sets the MTU of an interface
+func SetInterfaceMtu(name string, mtu int) error {
+	iface, err := netlink.LinkByName(name)
+	if err != nil {
+		return err
+	}
+	return netlink.LinkSetMTU(iface, mtu)
+}
+
 // IsAddrLocal check if an address is local
 func IsAddrLocal(findAddr string) bool {
 	// get the local addr list
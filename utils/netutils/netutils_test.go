@@ -18,6 +18,7 @@ package netutils
 import (
 	"fmt"
 	"github.com/jainvipin/bitset"
+	"reflect"
 	"testing"
 )
 
@@ -287,6 +288,57 @@ func TestInvalidMinMaxVxlan(t *testing.T) {
 	}
 }
 
+func TestParsePortList(t *testing.T) {
+	testData := []struct {
+		ports   string
+		matches []PortMatch
+	}{
+		{"80", []PortMatch{{80, 0xffff}}},
+		{"443,80", []PortMatch{{80, 0xffff}, {443, 0xffff}}},
+		{"8000-8003", []PortMatch{{8000, 0xfffc}}},
+		{"8000-8004,8003", []PortMatch{{8000, 0xfffc}, {8004, 0xffff}}},
+		{"1-7", []PortMatch{{1, 0xffff}, {2, 0xfffe}, {4, 0xfffc}}},
+		{"30000-32767", []PortMatch{{30000, 0xfff0}, {30016, 0xffc0}, {30080, 0xff80},
+			{30208, 0xfe00}, {30720, 0xf800}}},
+		{"1-65535", []PortMatch{{1, 0xffff}, {2, 0xfffe}, {4, 0xfffc}, {8, 0xfff8},
+			{16, 0xfff0}, {32, 0xffe0}, {64, 0xffc0}, {128, 0xff80}, {256, 0xff00},
+			{512, 0xfe00}, {1024, 0xfc00}, {2048, 0xf800}, {4096, 0xf000},
+			{8192, 0xe000}, {16384, 0xc000}, {32768, 0x8000}}},
+	}
+
+	for _, d := range testData {
+		matches, err := ParsePortList(d.ports)
+		if err != nil {
+			t.Fatalf("error '%s' parsing valid port list '%s'", err, d.ports)
+		}
+		if !reflect.DeepEqual(matches, d.matches) {
+			t.Fatalf("port list '%s' parsed to %v, expected %v", d.ports, matches, d.matches)
+		}
+	}
+}
+
+func TestParseInvalidPortList(t *testing.T) {
+	for _, ports := range []string{"", "0", "65536", "80,,443", "100-90", "10-20-30", "http"} {
+		if _, err := ParsePortList(ports); err == nil {
+			t.Fatalf("successfully parsed invalid port list '%s'", ports)
+		}
+	}
+}
+
+func TestValidateIPOrCIDR(t *testing.T) {
+	for _, addr := range []string{"10.1.1.1", "10.1.0.0/16", "2001:db8::1", "2001:db8::/32"} {
+		if err := ValidateIPOrCIDR(addr); err != nil {
+			t.Fatalf("error '%s' validating address '%s'", err, addr)
+		}
+	}
+
+	for _, addr := range []string{"", "10.1.1", "10.1.0.0/33", "2001:db8::/129", "host"} {
+		if err := ValidateIPOrCIDR(addr); err == nil {
+			t.Fatalf("successfully validated invalid address '%s'", addr)
+		}
+	}
+}
+
 type testSubnetAllocInfo struct {
 	subnetIP       string
 	subnetLen      uint
@@ -542,3 +594,25 @@ func TestIsOverlappingSubnet(t *testing.T) {
 	}
 
 }
+
+func TestGetNetworkMtu(t *testing.T) {
+	testMtu := []struct {
+		mtu   int
+		encap string
+		exp   int
+	}{
+		{mtu: 0, encap: "vlan", exp: 1500},
+		{mtu: 0, encap: "vxlan", exp: 1450},
+		{mtu: 0, encap: "geneve", exp: 1442},
+		{mtu: 1400, encap: "vxlan", exp: 1400},
+		{mtu: 1450, encap: "vxlan", exp: 1450},
+		{mtu: 1500, encap: "vxlan", exp: 1450},
+		{mtu: 1500, encap: "geneve", exp: 1442},
+		{mtu: 9000, encap: "vlan", exp: 1500},
+	}
+
+	for _, i := range testMtu {
+		mtu := GetNetworkMtu(i.mtu, 1500, i.encap)
+		assertOnTrue(t, mtu != i.exp, fmt.Sprintf("%+v got %d", i, mtu))
+	}
+}